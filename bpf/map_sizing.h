@@ -1,8 +1,8 @@
 #ifndef MAP_SIZING_H
 #define MAP_SIZING_H
 
-// TODO: make this user-configurable and modify the value from the userspace when
-// loading the maps with the Cilium library
+// Default sizes of the maps. They are overridden from the user space when loading the maps, according
+// to the ebpf.map_sizes configuration. Keep them in sync with pkg/internal/ebpf/tracer_linux.go.
 #define MAX_CONCURRENT_REQUESTS 10000 // 10000 requests per second max for a single traced process
 #define MAX_CONCURRENT_SHARED_REQUESTS                                                             \
     30000 // 10 * MAX_CONCURRENT_REQUESTS total ongoing requests, for maps shared among multiple tracers, e.g. pinned maps
@@ -16,9 +16,11 @@ import (
 	"github.com/grafana/beyla/pkg/export/instrumentations"
 	"github.com/grafana/beyla/pkg/export/otel"
 	"github.com/grafana/beyla/pkg/export/prom"
+	"github.com/grafana/beyla/pkg/internal/admin"
 	"github.com/grafana/beyla/pkg/internal/filter"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/infraolly/process"
+	"github.com/grafana/beyla/pkg/internal/kube"
 	"github.com/grafana/beyla/pkg/internal/traces"
 	"github.com/grafana/beyla/pkg/kubeflags"
 	"github.com/grafana/beyla/pkg/services"
@@ -44,9 +46,26 @@ var DefaultConfig = Config{
 	LogLevel:         "INFO",
 	EnforceSysCaps:   false,
 	EBPF: config.EPPFTracer{
-		BatchLength:        100,
-		BatchTimeout:       time.Second,
-		HTTPRequestTimeout: 30 * time.Second,
+		BatchLength:            100,
+		BatchTimeout:           time.Second,
+		HTTPRequestTimeout:     30 * time.Second,
+		RingbufWatchdogTimeout: 30 * time.Second,
+		DWARF: config.DWARFLimits{
+			MaxSize: 1 << 30,
+			Timeout: 30 * time.Second,
+		},
+		ElasticsearchPorts: []int{9200},
+		JWTSubject:         config.JWTSubject{Claim: "sub"},
+		CaptureBodies: config.BodyCapture{
+			SampleRatio: 0.01,
+		},
+		MapSizes: config.BPFMapSizes{
+			GoMaxSharedRequests:      30000,
+			GoMaxRequests:            10000,
+			GenericMaxSharedRequests: 30000,
+			GenericMaxRequests:       10000,
+			FullCheckInterval:        30 * time.Second,
+		},
 	},
 	Grafana: otel.GrafanaConfig{
 		OTLP: otel.GrafanaOTLP{
@@ -83,11 +102,18 @@ var DefaultConfig = Config{
 		Instrumentations: []string{
 			instrumentations.InstrumentationALL,
 		},
+		SDKReceiver: otel.SDKReceiverConfig{
+			Address:     "127.0.0.1",
+			MergeWindow: 10 * time.Second,
+			Tolerance:   10 * time.Millisecond,
+		},
+		BackendGracePeriod: 30 * time.Second,
 	},
 	Prometheus: prom.PrometheusConfig{
-		Path:     "/metrics",
-		Buckets:  otel.DefaultBuckets,
-		Features: []string{otel.FeatureApplication},
+		Path:        "/metrics",
+		TargetsPath: "/api/v1/targets",
+		Buckets:     otel.DefaultBuckets,
+		Features:    []string{otel.FeatureApplication},
 		Instrumentations: []string{
 			instrumentations.InstrumentationALL,
 		},
@@ -101,6 +127,13 @@ var DefaultConfig = Config{
 			Port: 0, // disabled by default
 			Path: "/internal/metrics",
 		},
+		BPFStats: imetrics.BPFStatsConfig{
+			Interval: 15 * time.Second,
+		},
+		OverheadBenchmark: imetrics.OverheadBenchmarkConfig{
+			Interval: 5 * time.Minute,
+			Requests: 100,
+		},
 	},
 	Attributes: Attributes{
 		InstanceID: traces.InstanceIDConfig{
@@ -114,8 +147,15 @@ var DefaultConfig = Config{
 		HostID: HostIDConfig{
 			FetchTimeout: 500 * time.Millisecond,
 		},
+		Tenant: transform.TenantConfig{MaxTenants: 100},
 	},
-	Routes:       &transform.RoutesConfig{Unmatch: transform.UnmatchHeuristic},
+	Routes: &transform.RoutesConfig{Unmatch: transform.UnmatchHeuristic},
+	SpanDedup: transform.SpanDedupConfig{
+		Tolerance:  5 * time.Millisecond,
+		ExpireTime: 10 * time.Second,
+	},
+	ServiceMesh:  transform.ServiceMeshConfig{MatchWindow: 500 * time.Millisecond},
+	StateDump:    debug.StateDumpConfig{LastSpans: 20},
 	NetworkFlows: defaultNetworkConfig,
 	Processes: process.CollectConfig{
 		RunMode:  process.RunModePrivileged,
@@ -123,6 +163,13 @@ var DefaultConfig = Config{
 	},
 	Discovery: services.DiscoveryConfig{
 		ExcludeOTelInstrumentedServices: true,
+		LibraryRescanInterval:           10 * time.Second,
+	},
+	LeaderElection: kube.LeaderElectionConfig{
+		LeaseName:     "beyla-service-graph",
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
 	},
 }
 
@@ -142,11 +189,30 @@ type Config struct {
 	// Routes is an optional node. If not set, data will be directly forwarded to exporters.
 	Routes       *transform.RoutesConfig       `yaml:"routes"`
 	NameResolver *transform.NameResolverConfig `yaml:"name_resolver"`
-	Metrics      otel.MetricsConfig            `yaml:"otel_metrics_export"`
-	Traces       otel.TracesConfig             `yaml:"otel_traces_export"`
-	Prometheus   prom.PrometheusConfig         `yaml:"prometheus_export"`
-	Printer      debug.PrintEnabled            `yaml:"print_traces" env:"BEYLA_PRINT_TRACES"`
-	TracePrinter debug.TracePrinter            `yaml:"trace_printer" env:"BEYLA_TRACE_PRINTER"`
+	// SpanDedup removes the duplicate spans that are captured from multiple capture points
+	SpanDedup transform.SpanDedupConfig `yaml:"span_dedup"`
+	// ServiceMesh reports the real peers of the requests that cross the sidecar proxy of a service mesh
+	ServiceMesh  transform.ServiceMeshConfig `yaml:"service_mesh"`
+	Metrics      otel.MetricsConfig          `yaml:"otel_metrics_export"`
+	Traces       otel.TracesConfig           `yaml:"otel_traces_export"`
+	Prometheus   prom.PrometheusConfig       `yaml:"prometheus_export"`
+	Printer      debug.PrintEnabled          `yaml:"print_traces" env:"BEYLA_PRINT_TRACES"`
+	TracePrinter debug.TracePrinter          `yaml:"trace_printer" env:"BEYLA_TRACE_PRINTER"`
+
+	// SilenceDetection reports the instrumented processes that stop producing spans while they are alive
+	SilenceDetection transform.SilenceDetectionConfig `yaml:"silence_detection"`
+
+	// SpanStatus overrides which HTTP response status codes are reported as errors
+	SpanStatus transform.SpanStatusConfig `yaml:"span_status"`
+
+	// GRPCGateway names the HTTP spans of the grpc-gateway services after the gRPC methods that they invoke
+	GRPCGateway transform.GRPCGatewayConfig `yaml:"grpc_gateway"`
+
+	// SpanNames overrides the names of the HTTP spans after the logical operations of the applications
+	SpanNames transform.SpanNamesConfig `yaml:"span_names"`
+
+	// StateDump writes the in-memory state of the pipeline to a file on SIGQUIT or SIGUSR1
+	StateDump debug.StateDumpConfig `yaml:"state_dump"`
 
 	// Exec allows selecting the instrumented executable whose complete path contains the Exec value.
 	Exec       services.RegexpAttr `yaml:"executable_name" env:"BEYLA_EXECUTABLE_NAME"`
@@ -165,6 +231,13 @@ type Config struct {
 	// Discovery configuration
 	Discovery services.DiscoveryConfig `yaml:"discovery"`
 
+	// LeaderElection allows running redundant Beyla instances where only the leader
+	// exports the aggregated service graph metrics
+	LeaderElection kube.LeaderElectionConfig `yaml:"leader_election"`
+
+	// Admin enables a local HTTP API to inspect and control the running instance
+	Admin admin.Config `yaml:"admin"`
+
 	LogLevel string `yaml:"log_level" env:"BEYLA_LOG_LEVEL"`
 
 	// Check for required system capabilities and bail if they are not
@@ -206,6 +279,8 @@ type Attributes struct {
 	InstanceID traces.InstanceIDConfig       `yaml:"instance_id"`
 	Select     attributes.Selection          `yaml:"select"`
 	HostID     HostIDConfig                  `yaml:"host_id"`
+	Rename     attributes.Renames            `yaml:"rename"`
+	Tenant     transform.TenantConfig        `yaml:"tenant"`
 }
 
 type HostIDConfig struct {
@@ -238,6 +313,9 @@ func (c *Config) Validate() error {
 	if c.EBPF.BatchLength == 0 {
 		return ConfigError("BEYLA_BPF_BATCH_LENGTH must be at least 1")
 	}
+	if c.LeaderElection.Enable && c.LeaderElection.LeaseName == "" {
+		return ConfigError("leader_election.lease_name can't be empty if leader election is enabled")
+	}
 	if c.Attributes.Kubernetes.InformersSyncTimeout == 0 {
 		return ConfigError("BEYLA_KUBE_INFORMERS_SYNC_TIMEOUT duration must be greater than 0s")
 	}
@@ -22,6 +22,7 @@ import (
 	"github.com/grafana/beyla/pkg/export/prom"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/infraolly/process"
+	"github.com/grafana/beyla/pkg/internal/kube"
 	"github.com/grafana/beyla/pkg/internal/netolly/transform/cidr"
 	"github.com/grafana/beyla/pkg/internal/traces"
 	"github.com/grafana/beyla/pkg/kubeflags"
@@ -111,9 +112,26 @@ network:
 		Printer:          false,
 		TracePrinter:     "json",
 		EBPF: config.EPPFTracer{
-			BatchLength:        100,
-			BatchTimeout:       time.Second,
-			HTTPRequestTimeout: 30 * time.Second,
+			BatchLength:            100,
+			BatchTimeout:           time.Second,
+			HTTPRequestTimeout:     30 * time.Second,
+			RingbufWatchdogTimeout: 30 * time.Second,
+			DWARF: config.DWARFLimits{
+				MaxSize: 1 << 30,
+				Timeout: 30 * time.Second,
+			},
+			ElasticsearchPorts: []int{9200},
+			JWTSubject:         config.JWTSubject{Claim: "sub"},
+			CaptureBodies: config.BodyCapture{
+				SampleRatio: 0.01,
+			},
+			MapSizes: config.BPFMapSizes{
+				GoMaxSharedRequests:      30000,
+				GoMaxRequests:            10000,
+				GenericMaxSharedRequests: 30000,
+				GenericMaxRequests:       10000,
+				FullCheckInterval:        30 * time.Second,
+			},
 		},
 		Grafana: otel.GrafanaConfig{
 			OTLP: otel.GrafanaOTLP{
@@ -148,10 +166,17 @@ network:
 			Instrumentations: []string{
 				instrumentations.InstrumentationALL,
 			},
+			SDKReceiver: otel.SDKReceiverConfig{
+				Address:     "127.0.0.1",
+				MergeWindow: 10 * time.Second,
+				Tolerance:   10 * time.Millisecond,
+			},
+			BackendGracePeriod: 30 * time.Second,
 		},
 		Prometheus: prom.PrometheusConfig{
-			Path:     "/metrics",
-			Features: []string{otel.FeatureApplication},
+			Path:        "/metrics",
+			TargetsPath: "/api/v1/targets",
+			Features:    []string{otel.FeatureApplication},
 			Instrumentations: []string{
 				instrumentations.InstrumentationALL,
 			},
@@ -166,6 +191,13 @@ network:
 				Port: 3210,
 				Path: "/internal/metrics",
 			},
+			BPFStats: imetrics.BPFStatsConfig{
+				Interval: 15 * time.Second,
+			},
+			OverheadBenchmark: imetrics.OverheadBenchmarkConfig{
+				Interval: 5 * time.Minute,
+				Requests: 100,
+			},
 		},
 		Attributes: Attributes{
 			InstanceID: traces.InstanceIDConfig{
@@ -181,6 +213,7 @@ network:
 				Override:     "the-host-id",
 				FetchTimeout: 4 * time.Second,
 			},
+			Tenant: transform.TenantConfig{MaxTenants: 100},
 			Select: attributes.Selection{
 				attributes.BeylaNetworkFlow.Section: attributes.InclusionLists{
 					Include: []string{"foo", "bar"},
@@ -191,6 +224,12 @@ network:
 		Routes: &transform.RoutesConfig{
 			Unmatch: transform.UnmatchHeuristic,
 		},
+		SpanDedup: transform.SpanDedupConfig{
+			Tolerance:  5 * time.Millisecond,
+			ExpireTime: 10 * time.Second,
+		},
+		ServiceMesh: transform.ServiceMeshConfig{MatchWindow: 500 * time.Millisecond},
+		StateDump:   debug.StateDumpConfig{LastSpans: 20},
 		NameResolver: &transform.NameResolverConfig{
 			Sources:  []string{"k8s", "dns"},
 			CacheLen: 1024,
@@ -202,6 +241,13 @@ network:
 		},
 		Discovery: services.DiscoveryConfig{
 			ExcludeOTelInstrumentedServices: true,
+			LibraryRescanInterval:           10 * time.Second,
+		},
+		LeaderElection: kube.LeaderElectionConfig{
+			LeaseName:     "beyla-service-graph",
+			LeaseDuration: 15 * time.Second,
+			RenewDeadline: 10 * time.Second,
+			RetryPeriod:   2 * time.Second,
 		},
 	}, cfg)
 }
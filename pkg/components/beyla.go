@@ -8,6 +8,8 @@ import (
 
 	"github.com/grafana/beyla/pkg/beyla"
 	"github.com/grafana/beyla/pkg/export/attributes"
+	"github.com/grafana/beyla/pkg/export/otel"
+	"github.com/grafana/beyla/pkg/internal/admin"
 	"github.com/grafana/beyla/pkg/internal/appolly"
 	"github.com/grafana/beyla/pkg/internal/connector"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
@@ -20,7 +22,10 @@ import (
 // RunBeyla in the foreground process. This is a blocking function and won't exit
 // until both the AppO11y and NetO11y components end
 func RunBeyla(ctx context.Context, cfg *beyla.Config) error {
-	ctxInfo := buildCommonContextInfo(ctx, cfg)
+	ctxInfo, err := buildCommonContextInfo(ctx, cfg)
+	if err != nil {
+		return err
+	}
 
 	wg := sync.WaitGroup{}
 	app := cfg.Enabled(beyla.FeatureAppO11y)
@@ -102,7 +107,7 @@ func mustSkip(cfg *beyla.Config) string {
 // from the user-provided configuration
 func buildCommonContextInfo(
 	ctx context.Context, config *beyla.Config,
-) *global.ContextInfo {
+) (*global.ContextInfo, error) {
 	promMgr := &connector.PrometheusManager{}
 	ctxInfo := &global.ContextInfo{
 		Prometheus: promMgr,
@@ -115,30 +120,88 @@ func buildCommonContextInfo(
 			MetaCacheAddr:     config.Attributes.Kubernetes.MetaCacheAddress,
 		}),
 	}
-	switch {
-	case config.InternalMetrics.Prometheus.Port != 0:
-		slog.Debug("reporting internal metrics as Prometheus")
-		ctxInfo.Metrics = imetrics.NewPrometheusReporter(&config.InternalMetrics.Prometheus, promMgr, nil)
-		// Prometheus manager also has its own internal metrics, so we need to pass the imetrics reporter
-		// TODO: remove this dependency cycle and let prommgr to create and return the PrometheusReporter
-		promMgr.InstrumentWith(ctxInfo.Metrics)
-	case config.Prometheus.Registry != nil:
-		slog.Debug("reporting internal metrics with Prometheus Registry")
-		ctxInfo.Metrics = imetrics.NewPrometheusReporter(&config.InternalMetrics.Prometheus, nil, config.Prometheus.Registry)
-	default:
-		slog.Debug("not reporting internal metrics")
-		ctxInfo.Metrics = imetrics.NoopReporter{}
-	}
 
 	attributeGroups(config, ctxInfo)
 
+	ctxInfo.MetricRenamer = attributes.NewRenamer(config.Attributes.Rename)
+	if ctxInfo.MetricRenamer != nil {
+		promMgr.RenameWith(ctxInfo.MetricRenamer)
+	}
+
+	if config.LeaderElection.Enable {
+		if err := startLeaderElection(ctx, config, ctxInfo); err != nil {
+			return nil, fmt.Errorf("can't start leader election: %w", err)
+		}
+	}
+
 	if config.Attributes.HostID.Override == "" {
 		ctxInfo.FetchHostID(ctx, config.Attributes.HostID.FetchTimeout)
 	} else {
 		ctxInfo.HostID = config.Attributes.HostID.Override
 	}
 
-	return ctxInfo
+	// the internal metrics reporters are instantiated after the host ID is known,
+	// as the OTEL reporter needs it as a resource attribute
+	ctxInfo.Metrics = internalMetricsReporter(ctx, config, ctxInfo, promMgr)
+
+	ctxInfo.Admin = admin.New(&config.Admin)
+	ctxInfo.Metrics = ctxInfo.Admin.Reporter(ctxInfo.Metrics)
+	ctxInfo.Admin.Start(ctx)
+
+	return ctxInfo, nil
+}
+
+// internalMetricsReporter returns a Reporter that forwards the internal metrics to all the
+// enabled reporters, or a NoopReporter if none is enabled.
+func internalMetricsReporter(
+	ctx context.Context, config *beyla.Config, ctxInfo *global.ContextInfo, promMgr *connector.PrometheusManager,
+) imetrics.Reporter {
+	var reporters []imetrics.Reporter
+	switch {
+	case config.InternalMetrics.Prometheus.Port != 0:
+		slog.Debug("reporting internal metrics as Prometheus")
+		reporters = append(reporters,
+			imetrics.NewPrometheusReporter(&config.InternalMetrics.Prometheus, promMgr, nil))
+	case config.Prometheus.Registry != nil:
+		slog.Debug("reporting internal metrics with Prometheus Registry")
+		reporters = append(reporters,
+			imetrics.NewPrometheusReporter(&config.InternalMetrics.Prometheus, nil, config.Prometheus.Registry))
+	}
+	if config.InternalMetrics.OTEL.Enable {
+		if otelReporter, err := otel.NewInternalMetricsReporter(ctx, ctxInfo.HostID, &config.Metrics); err != nil {
+			slog.Error("can't instantiate OTEL internal metrics reporter. Ignoring", "error", err)
+		} else {
+			slog.Debug("reporting internal metrics as OpenTelemetry")
+			reporters = append(reporters, otelReporter)
+		}
+	}
+	if len(reporters) == 0 {
+		slog.Debug("not reporting internal metrics")
+	}
+	reporter := imetrics.NewDroppedSpansLogger(&config.InternalMetrics.DroppedSpans,
+		imetrics.NewMultiReporter(reporters...))
+	if config.InternalMetrics.Prometheus.Port != 0 {
+		// Prometheus manager also has its own internal metrics, so we need to pass the imetrics reporter
+		// TODO: remove this dependency cycle and let prommgr to create and return the PrometheusReporter
+		promMgr.InstrumentWith(reporter)
+	}
+	return reporter
+}
+
+// startLeaderElection joins the group of redundant Beyla instances that compete for exporting the
+// service graph metrics. It fails if the Kubernetes API is not accessible, as exporting the service
+// graph metrics from all the instances would double count them.
+func startLeaderElection(ctx context.Context, config *beyla.Config, ctxInfo *global.ContextInfo) error {
+	client, err := ctxInfo.K8sInformer.KubeClient()
+	if err != nil {
+		return fmt.Errorf("accessing the Kubernetes API: %w", err)
+	}
+	leader := kube.NewLeaderElector(&config.LeaderElection)
+	if err := leader.Run(ctx, client); err != nil {
+		return err
+	}
+	ctxInfo.ServiceGraphLeader = leader
+	return nil
 }
 
 // attributeGroups specifies, based in the provided configuration, which groups of attributes
@@ -162,4 +225,7 @@ func attributeGroups(config *beyla.Config, ctxInfo *global.ContextInfo) {
 	if config.NetworkFlows.CIDRs.Enabled() {
 		ctxInfo.MetricAttributeGroups.Add(attributes.GroupNetCIDR)
 	}
+	if config.Attributes.Tenant.Enabled() {
+		ctxInfo.MetricAttributeGroups.Add(attributes.GroupTenant)
+	}
 }
@@ -18,6 +18,12 @@ type EPPFTracer struct {
 	// BatchTimeout specifies the timeout to forward the data batch if it didn't
 	// reach the BatchLength size
 	BatchTimeout time.Duration `yaml:"batch_timeout" env:"BEYLA_BPF_BATCH_TIMEOUT"`
+	// RingbufWatchdogTimeout is the maximum time that a ring buffer reader can wait for new events
+	// before it is flushed, forcing it to read any event that didn't wake it up. Zero disables it.
+	RingbufWatchdogTimeout time.Duration `yaml:"ringbuf_watchdog_timeout" env:"BEYLA_BPF_RINGBUF_WATCHDOG_TIMEOUT"`
+	// RingbufPollTimeout is the maximum time that a ring buffer reader waits for a wakeup before
+	// reading the events that are pending in the ring buffer. Zero waits indefinitely.
+	RingbufPollTimeout time.Duration `yaml:"ringbuf_poll_timeout" env:"BEYLA_BPF_RINGBUF_POLL_TIMEOUT"`
 
 	// If enabled, the kprobes based HTTP request tracking will start tracking the request
 	// headers to process any 'Traceparent' fields.
@@ -25,6 +31,13 @@ type EPPFTracer struct {
 
 	HTTPRequestTimeout time.Duration `yaml:"http_request_timeout" env:"BEYLA_BPF_HTTP_REQUEST_TIMEOUT"`
 
+	// InstrumentInlined also attaches the Go probes to the call sites where the compiler inlined the
+	// instrumented functions, as found in the DWARF information of the executables
+	InstrumentInlined bool `yaml:"instrument_inlined" env:"BEYLA_BPF_INSTRUMENT_INLINED"`
+
+	// DWARF limits the resources that are spent parsing the debug information of the Go executables
+	DWARF DWARFLimits `yaml:"dwarf"`
+
 	// Enables Linux Traffic Control probes for context propagation
 	UseTCForCP bool `yaml:"traffic_control_context_propagation" env:"BEYLA_BPF_TC_CP"`
 
@@ -36,4 +49,89 @@ type EPPFTracer struct {
 
 	// Optimises for getting requests information immediately when request response is seen
 	HighRequestVolume bool `yaml:"high_request_volume" env:"BEYLA_BPF_HIGH_REQUEST_VOLUME"`
+
+	// CaptureHeaders is the case-insensitive list of HTTP request headers that are attached to the spans,
+	// when they are found in the first 192 bytes of the request that are captured by the eBPF probes.
+	// The response headers are not captured.
+	CaptureHeaders []string `yaml:"capture_headers" env:"BEYLA_CAPTURE_HEADERS" envSeparator:","`
+
+	// HealthCheckPaths are the URL paths of the HTTP health-check endpoints. The server requests to these
+	// paths are only counted in the internal metrics, and they are discarded before any span is created
+	HealthCheckPaths []string `yaml:"health_check_paths" env:"BEYLA_BPF_HEALTH_CHECK_PATHS" envSeparator:","`
+
+	// HTTPProxies lists the addresses of the forward proxies, as IPs or "ip:port" pairs, whose HTTP client
+	// spans are attributed to the target host in the Host header of the requests instead of the proxy
+	HTTPProxies []string `yaml:"http_proxies" env:"BEYLA_BPF_HTTP_PROXIES" envSeparator:","`
+
+	// ElasticsearchPorts are the server ports of the Elasticsearch and OpenSearch clusters. The HTTP client
+	// requests with the shape of their REST API are only reported as Elasticsearch spans if they target one
+	// of these ports, or if they are sent by an Elasticsearch or OpenSearch client library
+	ElasticsearchPorts []int `yaml:"elasticsearch_ports" env:"BEYLA_BPF_ELASTICSEARCH_PORTS" envSeparator:","`
+
+	// JWTSubject attaches a hash of the subject of the bearer tokens of the HTTP requests to the server spans
+	JWTSubject JWTSubject `yaml:"jwt_subject"`
+
+	// CaptureBodies attaches the first bytes of the HTTP request bodies to a sampled subset of the spans
+	CaptureBodies BodyCapture `yaml:"capture_bodies"`
+
+	// MapSizes of the eBPF maps that track the in-flight connections and requests
+	MapSizes BPFMapSizes `yaml:"map_sizes"`
+}
+
+// JWTSubject configures the attribution of the HTTP server spans to the principal that authenticated the
+// request, for abuse investigations. The claims of the JWT in the Authorization header are not verified,
+// and only a keyed hash of the subject is reported, so the raw identities don't reach the traces.
+// The Authorization header must fit in the first 192 bytes of the request, which most JWTs exceed.
+type JWTSubject struct {
+	// Enable the attribution of the server spans to the subject of their bearer token
+	Enable bool `yaml:"enable" env:"BEYLA_BPF_JWT_SUBJECT_ENABLE"`
+	// Claim of the JWT payload that identifies the principal
+	Claim string `yaml:"claim" env:"BEYLA_BPF_JWT_SUBJECT_CLAIM"`
+	// HashKey is the secret key of the HMAC of the subjects, which prevents guessing the subjects from
+	// their hashes. Without a key, a plain SHA-256 hash is reported.
+	HashKey string `yaml:"hash_key" env:"BEYLA_BPF_JWT_SUBJECT_HASH_KEY"`
+}
+
+// BodyCapture configures the capture of the HTTP request bodies, for debugging malformed payloads.
+// Only the part of the body that fits in the 192-byte request buffer of the eBPF probes, after the
+// request line and the headers, can be captured. The response bodies are not captured.
+type BodyCapture struct {
+	// Size is the maximum number of bytes of the body that are captured. Zero disables the capture.
+	// Values greater than 192 are lowered to 192.
+	Size int `yaml:"size" env:"BEYLA_CAPTURE_BODIES_SIZE"`
+	// SampleRatio is the fraction of the spans, between 0 and 1, whose body is captured
+	SampleRatio float64 `yaml:"sample_ratio" env:"BEYLA_CAPTURE_BODIES_SAMPLE_RATIO"`
+}
+
+// BPFMapSizes overrides the maximum number of entries of the eBPF maps that track the in-flight
+// requests of the instrumented processes, for each tracer. When these maps are full,
+// their least recently used entries are evicted and the spans of the evicted requests are lost, so
+// services with many concurrent connections (e.g. proxies) might require bigger maps.
+// Zero values keep the sizes that are compiled into the eBPF programs.
+type BPFMapSizes struct {
+	// GoMaxSharedRequests is the size of the maps of the Go tracer that hold the in-flight requests of
+	// all the instrumented processes, most of them shared with the generic tracer
+	GoMaxSharedRequests uint32 `yaml:"go_max_shared_requests" env:"BEYLA_BPF_GO_MAX_SHARED_REQUESTS"`
+	// GoMaxRequests is the size of the maps of the Go tracer that are keyed by goroutine or request
+	GoMaxRequests uint32 `yaml:"go_max_requests" env:"BEYLA_BPF_GO_MAX_REQUESTS"`
+	// GenericMaxSharedRequests is the size of the maps of the generic (kprobes) tracer that hold the
+	// in-flight requests of all the instrumented processes, most of them shared with the Go tracer
+	GenericMaxSharedRequests uint32 `yaml:"generic_max_shared_requests" env:"BEYLA_BPF_GENERIC_MAX_SHARED_REQUESTS"`
+	// GenericMaxRequests is the size of the maps of the generic (kprobes) tracer that are keyed by thread or request
+	GenericMaxRequests uint32 `yaml:"generic_max_requests" env:"BEYLA_BPF_GENERIC_MAX_REQUESTS"`
+	// FullCheckInterval is how often the occupation of the maps is checked, to report when they are full
+	// and their entries are being evicted. Zero disables the check.
+	FullCheckInterval time.Duration `yaml:"full_check_interval" env:"BEYLA_BPF_MAP_FULL_CHECK_INTERVAL"`
+}
+
+// DWARFLimits bound the memory and time that are spent reading the DWARF debug information of huge
+// Go executables. When they are exceeded, the struct field offsets that couldn't be read from the
+// DWARF information are taken from the offsets database that is embedded in Beyla.
+type DWARFLimits struct {
+	// MaxSize is the maximum uncompressed size, in bytes, of the DWARF sections that are loaded
+	// in memory. Zero means no limit.
+	MaxSize uint64 `yaml:"max_size" env:"BEYLA_BPF_DWARF_MAX_SIZE"`
+	// Timeout is the maximum time that is spent reading the DWARF information of an executable.
+	// Zero means no limit.
+	Timeout time.Duration `yaml:"timeout" env:"BEYLA_BPF_DWARF_TIMEOUT"`
 }
@@ -20,6 +20,7 @@ const (
 	GroupPeerInfo // TODO Beyla 2.0: remove when we remove ReportPeerInfo configuration option
 	GroupTarget   // TODO Beyla 2.0: remove when we remove ReportTarget configuration option
 	GroupTraces
+	GroupTenant
 )
 
 func (e *AttrGroups) Has(groups AttrGroups) bool {
@@ -118,6 +119,13 @@ func getDefinitions(groups AttrGroups) map[Section]AttrReportGroup {
 		},
 	}
 
+	var httpTenant = AttrReportGroup{
+		Disabled: !groups.Has(GroupTenant),
+		Attributes: map[attr.Name]Default{
+			attr.TenantID: true,
+		},
+	}
+
 	var serverInfo = AttrReportGroup{
 		Attributes: map[attr.Name]Default{
 			attr.ClientAddr: Default(peerInfoEnabled),
@@ -148,7 +156,7 @@ func getDefinitions(groups AttrGroups) map[Section]AttrReportGroup {
 	}
 
 	var httpCommon = AttrReportGroup{
-		SubGroups: []*AttrReportGroup{&httpRoutes, &deprecatedHTTPPath},
+		SubGroups: []*AttrReportGroup{&httpRoutes, &deprecatedHTTPPath, &httpTenant},
 		Attributes: map[attr.Name]Default{
 			attr.HTTPRequestMethod:      true,
 			attr.HTTPResponseStatusCode: true,
@@ -253,6 +261,13 @@ func getDefinitions(groups AttrGroups) map[Section]AttrReportGroup {
 				attr.ErrorType:   true,
 			},
 		},
+		DBClientRedisRedirects.Section: {
+			SubGroups: []*AttrReportGroup{&appAttributes, &appKubeAttributes},
+			Attributes: map[attr.Name]Default{
+				attr.DBOperation:         true,
+				attr.DBRedisRedirectType: true,
+			},
+		},
 		MessagingPublishDuration.Section: {
 			SubGroups: []*AttrReportGroup{&messagingAttributes},
 		},
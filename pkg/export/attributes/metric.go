@@ -61,6 +61,11 @@ var (
 		Prom:    "db_client_operation_duration_seconds",
 		OTEL:    "db.client.operation.duration",
 	}
+	DBClientRedisRedirects = Name{
+		Section: "db.client.redis.redirects",
+		Prom:    "db_client_redis_redirects_total",
+		OTEL:    "db.client.redis.redirects",
+	}
 	ProcessCPUTime = Name{
 		Section: "process.cpu.time",
 		Prom:    "process_cpu_time_seconds_total",
@@ -32,6 +32,7 @@ const (
 	ServerAddr             = Name("server.address")
 	ServerPort             = Name("server.port")
 	HTTPRequestBodySize    = Name("http.request.body.size")
+	HTTPResponseBodySize   = Name("http.response.body.size")
 	SpanKind               = Name("span.kind")
 	SpanName               = Name("span.name")
 	StatusCode             = Name("status.code")
@@ -46,6 +47,7 @@ const (
 	DBCollectionName       = Name("db.collection.name")
 	DBSystem               = Name(semconv.DBSystemKey)
 	ErrorType              = Name("error.type")
+	DBRedisRedirectType    = Name("db.redis.redirect.type")
 	RPCMethod              = Name(semconv.RPCMethodKey)
 	RPCSystem              = Name(semconv.RPCSystemKey)
 	RPCGRPCStatusCode      = Name(semconv.RPCGRPCStatusCodeKey)
@@ -53,6 +55,7 @@ const (
 	MessagingOpType        = Name("messaging.operation.type")
 	MessagingSystem        = Name(semconv.MessagingSystemKey)
 	MessagingDestination   = Name(semconv.MessagingDestinationNameKey)
+	TenantID               = Name("tenant.id")
 
 	K8sNamespaceName   = Name("k8s.namespace.name")
 	K8sPodName         = Name("k8s.pod.name")
@@ -0,0 +1,101 @@
+package attributes
+
+import (
+	"strings"
+)
+
+// Renames of the exported metric names and attribute keys, e.g. to conform to an
+// existing naming standard. The keys are the original metric or attribute names,
+// and the values are the names replacing them.
+// Both keys and values can be provided in Prometheus (underscores) or OpenTelemetry (dots)
+// notation.
+type Renames struct {
+	// Metrics to rename. The keys are normalized in the same way as the attributes.select
+	// section keys, so the metric unit and aggregation suffixes can be omitted, and they are
+	// kept in the exported names.
+	Metrics map[string]string `yaml:"metrics"`
+	// Attributes to rename, in any metric they are exported.
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// Renamer looks up the renamed exported names from a Renames configuration.
+// A nil *Renamer means that no name has to be renamed.
+type Renamer struct {
+	metrics    map[Section]string
+	attributes map[string]string
+}
+
+// NewRenamer normalizes the user-provided Renames for unified access from the exporters.
+// It returns nil if there is nothing to rename.
+func NewRenamer(cfg Renames) *Renamer {
+	if len(cfg.Metrics) == 0 && len(cfg.Attributes) == 0 {
+		return nil
+	}
+	r := &Renamer{
+		metrics:    make(map[Section]string, len(cfg.Metrics)),
+		attributes: make(map[string]string, len(cfg.Attributes)),
+	}
+	for from, to := range cfg.Metrics {
+		r.metrics[normalizeMetric(Section(from))] = to
+	}
+	for from, to := range cfg.Attributes {
+		r.attributes[asOTEL(from)] = to
+	}
+	return r
+}
+
+func asOTEL(str string) string {
+	return strings.ReplaceAll(str, "_", ".")
+}
+
+// MetricOTEL returns the name that replaces the passed OpenTelemetry metric name,
+// or the same name if it has not been renamed.
+func (r *Renamer) MetricOTEL(name string) string {
+	if newName, ok := r.metric(name); ok {
+		return newName
+	}
+	return name
+}
+
+// MetricProm returns the name that replaces the passed Prometheus metric name,
+// or the same name if it has not been renamed.
+func (r *Renamer) MetricProm(name string) string {
+	if newName, ok := r.metric(name); ok {
+		return asProm(newName)
+	}
+	return name
+}
+
+// metric returns the new name, keeping the unit and aggregation suffixes of the
+// original name (e.g. _seconds or _total)
+func (r *Renamer) metric(name string) (string, bool) {
+	section := normalizeMetric(Section(name))
+	newName, ok := r.metrics[section]
+	if !ok {
+		return "", false
+	}
+	// the normalized section is a prefix of the name, as the normalization
+	// just replaces characters and removes suffixes
+	if !strings.HasPrefix(asOTEL(name), string(section)) {
+		return newName, true
+	}
+	return newName + name[len(section):], true
+}
+
+// AttributeOTEL returns the key that replaces the passed OpenTelemetry attribute key,
+// or the same key if it has not been renamed.
+func (r *Renamer) AttributeOTEL(key string) string {
+	if newKey, ok := r.attributes[asOTEL(key)]; ok {
+		return newKey
+	}
+	return key
+}
+
+// AttributeProm returns the label name that replaces the passed Prometheus label name,
+// or the same name if it has not been renamed.
+func (r *Renamer) AttributeProm(label string) string {
+	if newKey, ok := r.attributes[asOTEL(label)]; ok {
+		return asProm(newKey)
+	}
+	return label
+}
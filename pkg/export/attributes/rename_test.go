@@ -0,0 +1,43 @@
+package attributes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenamer(t *testing.T) {
+	r := NewRenamer(Renames{
+		Metrics: map[string]string{
+			"http_server_request_duration_seconds": "acme.http.latency",
+			"beyla.network.flow":                   "acme.network.traffic",
+			"process.cpu.time":                     "acme_cpu",
+		},
+		Attributes: map[string]string{
+			"k8s.namespace.name":        "kube_namespace",
+			"http_response_status_code": "http.status",
+		},
+	})
+
+	assert.Equal(t, "acme.http.latency", r.MetricOTEL("http.server.request.duration"))
+	assert.Equal(t, "acme_http_latency_seconds", r.MetricProm("http_server_request_duration_seconds"))
+	// unit and aggregation suffixes are kept
+	assert.Equal(t, "acme.network.traffic.bytes", r.MetricOTEL("beyla.network.flow.bytes"))
+	assert.Equal(t, "acme_network_traffic_bytes_total", r.MetricProm("beyla_network_flow_bytes_total"))
+	assert.Equal(t, "acme_cpu_seconds_total", r.MetricProm("process_cpu_time_seconds_total"))
+	// not renamed
+	assert.Equal(t, "http.client.request.duration", r.MetricOTEL("http.client.request.duration"))
+	assert.Equal(t, "process_cpu_utilization_ratio", r.MetricProm("process_cpu_utilization_ratio"))
+
+	assert.Equal(t, "kube_namespace", r.AttributeOTEL("k8s.namespace.name"))
+	assert.Equal(t, "kube_namespace", r.AttributeProm("k8s_namespace_name"))
+	assert.Equal(t, "http.status", r.AttributeOTEL("http.response.status_code"))
+	assert.Equal(t, "http_status", r.AttributeProm("http_response_status_code"))
+	assert.Equal(t, "url.path", r.AttributeOTEL("url.path"))
+	assert.Equal(t, "url_path", r.AttributeProm("url_path"))
+}
+
+func TestRenamer_Empty(t *testing.T) {
+	assert.Nil(t, NewRenamer(Renames{}))
+	assert.Nil(t, NewRenamer(Renames{Metrics: map[string]string{}}))
+}
@@ -0,0 +1,193 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/mariomac/pipes/pipe"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func sdlog() *slog.Logger {
+	return slog.With("component", "debug.StateDump")
+}
+
+// StateDumpConfig configures the dump of the in-memory state of the pipeline to a file when
+// Beyla receives the SIGQUIT or SIGUSR1 signals, to analyze what was in flight when Beyla or
+// the telemetry backend misbehaved.
+type StateDumpConfig struct {
+	// Dir is the directory where the dump files are written. Empty disables the state dumps.
+	Dir string `yaml:"dir" env:"BEYLA_STATE_DUMP_DIR"`
+	// LastSpans is the number of most recent spans that are remembered for each service
+	LastSpans int `yaml:"last_spans" env:"BEYLA_STATE_DUMP_LAST_SPANS"`
+}
+
+func (c *StateDumpConfig) Enabled() bool {
+	return c != nil && c.Dir != ""
+}
+
+// injectable function for testing
+var stateDumpTimeNow = time.Now
+
+// QueueState contains the number of span batches that are pending to be processed in a queue
+type QueueState struct {
+	Name     string `json:"name"`
+	Batches  int    `json:"batches"`
+	Capacity int    `json:"capacity"`
+}
+
+// ServiceState contains the most recent spans of a service
+type ServiceState struct {
+	Name      string         `json:"name"`
+	Namespace string         `json:"namespace"`
+	UID       svc.UID        `json:"uid"`
+	SpansSeen uint64         `json:"spansSeen"`
+	LastSpans []request.Span `json:"lastSpans"`
+}
+
+// StateDump is the content of a state dump file
+type StateDump struct {
+	Time     time.Time      `json:"time"`
+	Queues   []QueueState   `json:"queues"`
+	Services []ServiceState `json:"services"`
+}
+
+type serviceSpans struct {
+	id    svc.ID
+	seen  uint64
+	spans []request.Span
+	// next is the position of the spans ring where the next span is stored
+	next int
+}
+
+// stateRecorder remembers the last spans of each service. It is not safe for concurrent access.
+type stateRecorder struct {
+	cfg       *StateDumpConfig
+	tracesCh  <-chan []request.Span
+	services  map[svc.UID]*serviceSpans
+	lastSpans int
+}
+
+// StateDumpNode records the last spans of each service, and writes them to a file in the configured
+// directory, with the depth of the pipeline queues, each time Beyla receives the SIGQUIT or SIGUSR1 signals.
+// The tracesCh argument is the input channel of the pipeline, whose pending batches are also reported.
+func StateDumpNode(cfg *StateDumpConfig, tracesCh <-chan []request.Span) pipe.FinalProvider[[]request.Span] {
+	return func() (pipe.FinalFunc[[]request.Span], error) {
+		if !cfg.Enabled() {
+			return pipe.IgnoreFinal[[]request.Span](), nil
+		}
+		if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating state dump directory: %w", err)
+		}
+		sr := newStateRecorder(cfg, tracesCh)
+		return sr.nodeLoop, nil
+	}
+}
+
+func newStateRecorder(cfg *StateDumpConfig, tracesCh <-chan []request.Span) *stateRecorder {
+	return &stateRecorder{
+		cfg:       cfg,
+		tracesCh:  tracesCh,
+		services:  map[svc.UID]*serviceSpans{},
+		lastSpans: max(cfg.LastSpans, 1),
+	}
+}
+
+func (sr *stateRecorder) nodeLoop(in <-chan []request.Span) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGQUIT, syscall.SIGUSR1)
+	defer signal.Stop(sigs)
+	for {
+		select {
+		case spans, ok := <-in:
+			if !ok {
+				return
+			}
+			sr.observe(spans)
+		case sig := <-sigs:
+			if file, err := sr.writeDump(sr.snapshot(in)); err != nil {
+				sdlog().Error("can't write state dump", "signal", sig, "error", err)
+			} else {
+				sdlog().Info("state dump written", "signal", sig, "file", file)
+			}
+		}
+	}
+}
+
+func (sr *stateRecorder) observe(spans []request.Span) {
+	for i := range spans {
+		span := &spans[i]
+		if span.InternalSignal() {
+			continue
+		}
+		entry, ok := sr.services[span.ServiceID.UID]
+		if !ok {
+			entry = &serviceSpans{id: span.ServiceID, spans: make([]request.Span, 0, sr.lastSpans)}
+			sr.services[span.ServiceID.UID] = entry
+		}
+		entry.seen++
+		if len(entry.spans) < sr.lastSpans {
+			entry.spans = append(entry.spans, *span)
+		} else {
+			entry.spans[entry.next] = *span
+		}
+		entry.next = (entry.next + 1) % sr.lastSpans
+	}
+}
+
+func (sr *stateRecorder) snapshot(in <-chan []request.Span) *StateDump {
+	dump := &StateDump{Time: stateDumpTimeNow()}
+	if sr.tracesCh != nil {
+		dump.Queues = append(dump.Queues, QueueState{Name: "traces_input", Batches: len(sr.tracesCh), Capacity: cap(sr.tracesCh)})
+	}
+	dump.Queues = append(dump.Queues, QueueState{Name: "exporters_input", Batches: len(in), Capacity: cap(in)})
+	for uid, entry := range sr.services {
+		state := ServiceState{
+			Name:      entry.id.Name,
+			Namespace: entry.id.Namespace,
+			UID:       uid,
+			SpansSeen: entry.seen,
+			LastSpans: make([]request.Span, 0, len(entry.spans)),
+		}
+		// from the oldest to the newest span
+		if len(entry.spans) == sr.lastSpans {
+			state.LastSpans = append(state.LastSpans, entry.spans[entry.next:]...)
+			state.LastSpans = append(state.LastSpans, entry.spans[:entry.next]...)
+		} else {
+			state.LastSpans = append(state.LastSpans, entry.spans...)
+		}
+		dump.Services = append(dump.Services, state)
+	}
+	sort.Slice(dump.Services, func(i, j int) bool {
+		if dump.Services[i].Namespace != dump.Services[j].Namespace {
+			return dump.Services[i].Namespace < dump.Services[j].Namespace
+		}
+		if dump.Services[i].Name != dump.Services[j].Name {
+			return dump.Services[i].Name < dump.Services[j].Name
+		}
+		return dump.Services[i].UID < dump.Services[j].UID
+	})
+	return dump
+}
+
+// writeDump writes the dump in a JSON file named after its time, and returns the file path
+func (sr *stateRecorder) writeDump(dump *StateDump) (string, error) {
+	content, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding state dump: %w", err)
+	}
+	file := filepath.Join(sr.cfg.Dir, "beyla-state-"+dump.Time.UTC().Format("20060102T150405.000Z")+".json")
+	if err := os.WriteFile(file, content, 0o600); err != nil {
+		return "", fmt.Errorf("writing state dump: %w", err)
+	}
+	return file, nil
+}
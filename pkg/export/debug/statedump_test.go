@@ -0,0 +1,76 @@
+package debug
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestStateRecorder(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 123_000_000, time.UTC)
+	stateDumpTimeNow = func() time.Time { return now }
+	defer func() { stateDumpTimeNow = time.Now }()
+
+	tracesCh := make(chan []request.Span, 10)
+	tracesCh <- []request.Span{{}}
+	in := make(chan []request.Span, 5)
+	in <- []request.Span{{}}
+	in <- []request.Span{{}}
+
+	dir := t.TempDir()
+	sr := newStateRecorder(&StateDumpConfig{Dir: dir, LastSpans: 2}, tracesCh)
+	foo := svc.ID{Name: "foo", Namespace: "shop", UID: "foo-1"}
+	bar := svc.ID{Name: "bar", Namespace: "shop", UID: "bar-1"}
+	sr.observe([]request.Span{
+		{Type: request.EventTypeHTTP, ServiceID: foo, Method: "GET", Path: "/a"},
+		{Type: request.EventTypeHTTP, ServiceID: bar, Method: "GET", Path: "/b"},
+		{Type: request.EventTypeProcessAlive, ServiceID: bar},
+	})
+	sr.observe([]request.Span{
+		{Type: request.EventTypeHTTP, ServiceID: foo, Method: "GET", Path: "/c"},
+		{Type: request.EventTypeHTTP, ServiceID: foo, Method: "POST", Path: "/d"},
+	})
+
+	dump := sr.snapshot(in)
+	assert.Equal(t, now, dump.Time)
+	assert.Equal(t, []QueueState{
+		{Name: "traces_input", Batches: 1, Capacity: 10},
+		{Name: "exporters_input", Batches: 2, Capacity: 5},
+	}, dump.Queues)
+	require.Len(t, dump.Services, 2)
+
+	// only the last spans are kept, from the oldest to the newest
+	assert.Equal(t, "bar", dump.Services[0].Name)
+	assert.Equal(t, uint64(1), dump.Services[0].SpansSeen)
+	require.Len(t, dump.Services[0].LastSpans, 1)
+	assert.Equal(t, "/b", dump.Services[0].LastSpans[0].Path)
+	assert.Equal(t, "foo", dump.Services[1].Name)
+	assert.Equal(t, uint64(3), dump.Services[1].SpansSeen)
+	require.Len(t, dump.Services[1].LastSpans, 2)
+	assert.Equal(t, "/c", dump.Services[1].LastSpans[0].Path)
+	assert.Equal(t, "/d", dump.Services[1].LastSpans[1].Path)
+
+	file, err := sr.writeDump(dump)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "beyla-state-20240506T070809.123Z.json"), file)
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var written map[string]any
+	require.NoError(t, json.Unmarshal(content, &written))
+	assert.Len(t, written["services"], 2)
+	assert.Len(t, written["queues"], 2)
+}
+
+func TestStateDumpNodeDisabled(t *testing.T) {
+	node, err := StateDumpNode(&StateDumpConfig{LastSpans: 20}, nil)()
+	require.NoError(t, err)
+	assert.Nil(t, node)
+}
@@ -85,6 +85,21 @@ func (ex *ExpiryMap[T]) DeleteExpired() []T {
 	return delEntries
 }
 
+// DeleteMatching removes the entries whose label values match the given function,
+// and returns them
+func (ex *ExpiryMap[T]) DeleteMatching(match func(labelValues []string) bool) []T {
+	ex.mt.Lock()
+	defer ex.mt.Unlock()
+	var entries []T
+	for k, e := range ex.entries {
+		if match(e.labelValues) {
+			entries = append(entries, e.val)
+			delete(ex.entries, k)
+		}
+	}
+	return entries
+}
+
 // DeleteAll cleans the map and returns a slice with its deleted elements
 func (ex *ExpiryMap[T]) DeleteAll() []T {
 	ex.mt.Lock()
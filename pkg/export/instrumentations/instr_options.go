@@ -9,6 +9,11 @@ const (
 	InstrumentationSQL   = "sql"
 	InstrumentationRedis = "redis"
 	InstrumentationKafka = "kafka"
+	InstrumentationFTP   = "ftp"
+	InstrumentationSSH   = "ssh"
+	InstrumentationTLS   = "tls"
+	InstrumentationAMQP  = "amqp"
+	InstrumentationNATS  = "nats"
 )
 
 const (
@@ -18,12 +23,18 @@ const (
 	flagSQL
 	flagRedis
 	flagKafka
+	flagFTP
+	flagSSH
+	flagTLS
+	flagAMQP
+	flagNATS
 )
 
 func strToFlag(str string) InstrumentationSelection {
 	switch str {
 	case InstrumentationALL:
-		return flagAll
+		// the TLS handshakes are not application requests, so they must be explicitly selected
+		return flagAll &^ flagTLS
 	case InstrumentationHTTP:
 		return flagHTTP
 	case InstrumentationGRPC:
@@ -34,6 +45,16 @@ func strToFlag(str string) InstrumentationSelection {
 		return flagRedis
 	case InstrumentationKafka:
 		return flagKafka
+	case InstrumentationFTP:
+		return flagFTP
+	case InstrumentationSSH:
+		return flagSSH
+	case InstrumentationTLS:
+		return flagTLS
+	case InstrumentationAMQP:
+		return flagAMQP
+	case InstrumentationNATS:
+		return flagNATS
 	}
 	return 0
 }
@@ -71,6 +92,26 @@ func (s InstrumentationSelection) KafkaEnabled() bool {
 	return s&flagKafka != 0
 }
 
+func (s InstrumentationSelection) AMQPEnabled() bool {
+	return s&flagAMQP != 0
+}
+
+func (s InstrumentationSelection) NATSEnabled() bool {
+	return s&flagNATS != 0
+}
+
 func (s InstrumentationSelection) MQEnabled() bool {
-	return s.KafkaEnabled()
+	return s.KafkaEnabled() || s.AMQPEnabled() || s.NATSEnabled()
+}
+
+func (s InstrumentationSelection) FTPEnabled() bool {
+	return s&flagFTP != 0
+}
+
+func (s InstrumentationSelection) SSHEnabled() bool {
+	return s&flagSSH != 0
+}
+
+func (s InstrumentationSelection) TLSEnabled() bool {
+	return s&flagTLS != 0
 }
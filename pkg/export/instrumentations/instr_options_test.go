@@ -24,6 +24,35 @@ func TestInstrumentationSelection(t *testing.T) {
 	assert.True(t, is.GRPCEnabled())
 	assert.True(t, is.KafkaEnabled())
 	assert.True(t, is.MQEnabled())
+	assert.False(t, is.FTPEnabled())
+	assert.False(t, is.SSHEnabled())
+
+	is = NewInstrumentationSelection([]string{"ftp"})
+	assert.False(t, is.HTTPEnabled())
+	assert.False(t, is.KafkaEnabled())
+	assert.True(t, is.FTPEnabled())
+	assert.False(t, is.SSHEnabled())
+
+	is = NewInstrumentationSelection([]string{"ssh"})
+	assert.False(t, is.HTTPEnabled())
+	assert.False(t, is.FTPEnabled())
+	assert.True(t, is.SSHEnabled())
+	assert.False(t, is.TLSEnabled())
+
+	is = NewInstrumentationSelection([]string{"tls"})
+	assert.False(t, is.HTTPEnabled())
+	assert.False(t, is.SSHEnabled())
+	assert.True(t, is.TLSEnabled())
+
+	is = NewInstrumentationSelection([]string{"amqp"})
+	assert.False(t, is.KafkaEnabled())
+	assert.True(t, is.AMQPEnabled())
+	assert.True(t, is.MQEnabled())
+
+	is = NewInstrumentationSelection([]string{"nats"})
+	assert.False(t, is.AMQPEnabled())
+	assert.True(t, is.NATSEnabled())
+	assert.True(t, is.MQEnabled())
 }
 
 func TestInstrumentationSelection_All(t *testing.T) {
@@ -35,6 +64,16 @@ func TestInstrumentationSelection_All(t *testing.T) {
 	assert.True(t, is.GRPCEnabled())
 	assert.True(t, is.KafkaEnabled())
 	assert.True(t, is.MQEnabled())
+	assert.True(t, is.FTPEnabled())
+	assert.True(t, is.SSHEnabled())
+	assert.False(t, is.TLSEnabled())
+	assert.True(t, is.AMQPEnabled())
+	assert.True(t, is.NATSEnabled())
+
+	// TLS must be explicitly selected
+	is = NewInstrumentationSelection([]string{"*", "tls"})
+	assert.True(t, is.HTTPEnabled())
+	assert.True(t, is.TLSEnabled())
 }
 
 func TestInstrumentationSelection_None(t *testing.T) {
@@ -46,4 +85,9 @@ func TestInstrumentationSelection_None(t *testing.T) {
 	assert.False(t, is.GRPCEnabled())
 	assert.False(t, is.KafkaEnabled())
 	assert.False(t, is.MQEnabled())
+	assert.False(t, is.FTPEnabled())
+	assert.False(t, is.SSHEnabled())
+	assert.False(t, is.TLSEnabled())
+	assert.False(t, is.AMQPEnabled())
+	assert.False(t, is.NATSEnabled())
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 
 	"github.com/grafana/beyla/pkg/export/attributes"
@@ -36,6 +38,51 @@ const (
 	ProtocolHTTPJSON     Protocol = "http/json"
 )
 
+// Compression algorithm of the payloads that are sent to the OTLP endpoint
+type Compression string
+
+const (
+	CompressionUnset Compression = ""
+	CompressionNone  Compression = "none"
+	CompressionGzip  Compression = "gzip"
+	CompressionZstd  Compression = "zstd"
+)
+
+func (c Compression) validate() error {
+	switch c {
+	case CompressionUnset, CompressionNone, CompressionGzip, CompressionZstd:
+		return nil
+	}
+	return fmt.Errorf("invalid compression value: %q. Accepted values are: %s, %s, %s",
+		c, CompressionNone, CompressionGzip, CompressionZstd)
+}
+
+func (c Compression) isCompressed() bool {
+	return c != CompressionUnset && c != CompressionNone
+}
+
+// OTLPCompatibility selects which OTLP fields are sent to the endpoint
+type OTLPCompatibility string
+
+const (
+	// OTLPCompatibilityAuto sends the latest OTLP fields, and falls back to the legacy field set
+	// for the endpoints that reject them
+	OTLPCompatibilityAuto OTLPCompatibility = "auto"
+	// OTLPCompatibilityLatest always sends the latest OTLP fields
+	OTLPCompatibilityLatest OTLPCompatibility = "latest"
+	// OTLPCompatibilityLegacy never sends the fields that were added in OTLP 0.17.0 or later
+	OTLPCompatibilityLegacy OTLPCompatibility = "legacy"
+)
+
+func (c OTLPCompatibility) validate() error {
+	switch c {
+	case "", OTLPCompatibilityAuto, OTLPCompatibilityLatest, OTLPCompatibilityLegacy:
+		return nil
+	}
+	return fmt.Errorf("invalid OTLP compatibility value: %q. Accepted values are: %s, %s, %s",
+		c, OTLPCompatibilityAuto, OTLPCompatibilityLatest, OTLPCompatibilityLegacy)
+}
+
 const (
 	envTracesProtocol  = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
 	envMetricsProtocol = "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"
@@ -183,7 +230,12 @@ func (rp *ReporterPool[K, T]) expireOldReporters() {
 		if !ok || now.Sub(v.lastAccess) < rp.ttl {
 			return
 		}
-		rp.pool.RemoveOldest()
+		if uid, _, _ := rp.pool.RemoveOldest(); uid == rp.lastServiceUID {
+			// the evicted reporter must not be reused if its service comes back
+			rp.lastServiceUID = ""
+			rp.lastService = nil
+			rp.lastReporter = nil
+		}
 	}
 }
 
@@ -211,6 +263,7 @@ type otlpOptions struct {
 	URLPath       string
 	SkipTLSVerify bool
 	HTTPHeaders   map[string]string
+	Compression   Compression
 }
 
 func (o *otlpOptions) AsMetricHTTP() []otlpmetrichttp.Option {
@@ -226,15 +279,22 @@ func (o *otlpOptions) AsMetricHTTP() []otlpmetrichttp.Option {
 	if o.SkipTLSVerify {
 		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}))
 	}
-	if len(o.HTTPHeaders) > 0 {
-		opts = append(opts, otlpmetrichttp.WithHeaders(o.HTTPHeaders))
+	// the OTEL SDK exporter sets its own User-Agent, but it is overridden by the headers
+	headers := map[string]string{"User-Agent": userAgent()}
+	maps.Copy(headers, o.HTTPHeaders)
+	opts = append(opts, otlpmetrichttp.WithHeaders(headers))
+	if o.Compression == CompressionGzip {
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
 	}
 	return opts
 }
 
-func (o *otlpOptions) AsMetricGRPC() []otlpmetricgrpc.Option {
+// AsMetricGRPC returns the options of the OTLP/GRPC metrics exporter. The extra dial options are
+// appended to the default ones, as the exporter only accepts them in a single option.
+func (o *otlpOptions) AsMetricGRPC(dialOpts ...grpc.DialOption) []otlpmetricgrpc.Option {
 	opts := []otlpmetricgrpc.Option{
 		otlpmetricgrpc.WithEndpoint(o.Endpoint),
+		otlpmetricgrpc.WithDialOption(append([]grpc.DialOption{grpc.WithUserAgent(userAgent())}, dialOpts...)...),
 	}
 	if o.Insecure {
 		opts = append(opts, otlpmetricgrpc.WithInsecure())
@@ -242,6 +302,10 @@ func (o *otlpOptions) AsMetricGRPC() []otlpmetricgrpc.Option {
 	if o.SkipTLSVerify {
 		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})))
 	}
+	if o.Compression.isCompressed() {
+		// the gzip and zstd gRPC compressors are registered by the collector's configgrpc package
+		opts = append(opts, otlpmetricgrpc.WithCompressor(string(o.Compression)))
+	}
 	return opts
 }
 
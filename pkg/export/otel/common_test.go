@@ -5,8 +5,10 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/beyla/pkg/internal/svc"
 )
@@ -16,14 +18,17 @@ func TestOtlpOptions_AsMetricHTTP(t *testing.T) {
 		in  otlpOptions
 		len int
 	}
+	// the User-Agent option is always set
 	testCases := []testCase{
-		{in: otlpOptions{Endpoint: "foo"}, len: 1},
-		{in: otlpOptions{Endpoint: "foo", Insecure: true}, len: 2},
-		{in: otlpOptions{Endpoint: "foo", URLPath: "/foo"}, len: 2},
-		{in: otlpOptions{Endpoint: "foo", SkipTLSVerify: true}, len: 2},
-		{in: otlpOptions{Endpoint: "foo", Insecure: true, SkipTLSVerify: true}, len: 3},
-		{in: otlpOptions{Endpoint: "foo", URLPath: "/foo", SkipTLSVerify: true}, len: 3},
-		{in: otlpOptions{Endpoint: "foo", URLPath: "/foo", Insecure: true, SkipTLSVerify: true}, len: 4},
+		{in: otlpOptions{Endpoint: "foo"}, len: 2},
+		{in: otlpOptions{Endpoint: "foo", Insecure: true}, len: 3},
+		{in: otlpOptions{Endpoint: "foo", URLPath: "/foo"}, len: 3},
+		{in: otlpOptions{Endpoint: "foo", SkipTLSVerify: true}, len: 3},
+		{in: otlpOptions{Endpoint: "foo", Insecure: true, SkipTLSVerify: true}, len: 4},
+		{in: otlpOptions{Endpoint: "foo", URLPath: "/foo", SkipTLSVerify: true}, len: 4},
+		{in: otlpOptions{Endpoint: "foo", URLPath: "/foo", Insecure: true, SkipTLSVerify: true}, len: 5},
+		{in: otlpOptions{Endpoint: "foo", Compression: CompressionGzip}, len: 3},
+		{in: otlpOptions{Endpoint: "foo", Compression: CompressionNone}, len: 2},
 	}
 	for _, tc := range testCases {
 		t.Run(fmt.Sprint(tc), func(t *testing.T) {
@@ -37,11 +42,14 @@ func TestOtlpOptions_AsMetricGRPC(t *testing.T) {
 		in  otlpOptions
 		len int
 	}
+	// the User-Agent option is always set
 	testCases := []testCase{
-		{in: otlpOptions{Endpoint: "foo"}, len: 1},
-		{in: otlpOptions{Endpoint: "foo", Insecure: true}, len: 2},
-		{in: otlpOptions{Endpoint: "foo", SkipTLSVerify: true}, len: 2},
-		{in: otlpOptions{Endpoint: "foo", Insecure: true, SkipTLSVerify: true}, len: 3},
+		{in: otlpOptions{Endpoint: "foo"}, len: 2},
+		{in: otlpOptions{Endpoint: "foo", Insecure: true}, len: 3},
+		{in: otlpOptions{Endpoint: "foo", SkipTLSVerify: true}, len: 3},
+		{in: otlpOptions{Endpoint: "foo", Insecure: true, SkipTLSVerify: true}, len: 4},
+		{in: otlpOptions{Endpoint: "foo", Compression: CompressionZstd}, len: 3},
+		{in: otlpOptions{Endpoint: "foo", Compression: CompressionNone}, len: 2},
 	}
 	for _, tc := range testCases {
 		t.Run(fmt.Sprint(tc), func(t *testing.T) {
@@ -186,3 +194,30 @@ func TestParseOTELEnvVar_nil(t *testing.T) {
 
 	assert.True(t, reflect.DeepEqual(actual, map[string]string{}))
 }
+
+func TestReporterPool_ExpiredServiceComesBack(t *testing.T) {
+	now := time.Now()
+	var created, evicted []svc.UID
+	pool := NewReporterPool[*svc.ID, svc.UID](10, time.Minute, func() time.Time { return now },
+		func(uid svc.UID, _ *expirable[svc.UID]) { evicted = append(evicted, uid) },
+		func(id *svc.ID) (svc.UID, error) {
+			created = append(created, id.UID)
+			return id.UID, nil
+		})
+
+	foo := &svc.ID{Name: "foo", UID: "foo-1"}
+	_, err := pool.For(foo)
+	require.NoError(t, err)
+	now = now.Add(30 * time.Second)
+	_, err = pool.For(foo)
+	require.NoError(t, err)
+	assert.Equal(t, []svc.UID{"foo-1"}, created)
+	assert.Empty(t, evicted)
+
+	// after the TTL, the last accessed reporter is evicted and must be created again
+	now = now.Add(2 * time.Minute)
+	_, err = pool.For(foo)
+	require.NoError(t, err)
+	assert.Equal(t, []svc.UID{"foo-1"}, evicted)
+	assert.Equal(t, []svc.UID{"foo-1", "foo-1"}, created)
+}
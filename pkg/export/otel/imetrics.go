@@ -0,0 +1,299 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	instrument "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
+
+	"github.com/grafana/beyla/pkg/buildinfo"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func imlog() *slog.Logger {
+	return slog.With("component", "otel.InternalMetricsReporter")
+}
+
+// InternalMetricsReporter is an internal metrics Reporter that exports to an OTEL collector.
+// It provides the same metrics as the imetrics.PrometheusReporter.
+type InternalMetricsReporter struct {
+	ctx                   context.Context
+	provider              *metric.MeterProvider
+	tracerFlushes         instrument.Float64Histogram
+	ringbufReaderStalls   instrument.Float64Counter
+	bpfProgramRunTime     instrument.Float64Counter
+	bpfProgramRuns        instrument.Int64Counter
+	bpfMapFull            instrument.Int64Counter
+	overheadBenchmark     instrument.Float64Gauge
+	otelMetricExports     instrument.Float64Counter
+	otelMetricExportErrs  instrument.Float64Counter
+	otelTraceExports      instrument.Float64Counter
+	otelTraceExportErrs   instrument.Float64Counter
+	otelExportRejected    instrument.Int64Counter
+	otelTracesDegraded    instrument.Int64Gauge
+	prometheusRequests    instrument.Float64Counter
+	instrumentedProcesses instrument.Int64UpDownCounter
+	silentProcesses       instrument.Int64UpDownCounter
+	spansDropped          instrument.Int64Counter
+	unknownProtocolBytes  instrument.Int64Counter
+	healthCheckRequests   instrument.Int64Counter
+	http2StreamResets     instrument.Int64Counter
+}
+
+var _ imetrics.Reporter = (*InternalMetricsReporter)(nil)
+
+// NewInternalMetricsReporter submits the internal metrics to the OTEL endpoint of the metrics
+// configuration, at its interval. The metrics exporter is not instrumented, to avoid accounting
+// the export of the internal metrics as application metrics exports.
+func NewInternalMetricsReporter(ctx context.Context, hostID string, cfg *MetricsConfig) (*InternalMetricsReporter, error) {
+	SetupInternalOTELSDKLogger(cfg.SDKLogLevel)
+	exporter, err := InstantiateMetricsExporter(ctx, cfg, nil, imlog())
+	if err != nil {
+		return nil, err
+	}
+	return newInternalMetricsReporter(ctx, hostID,
+		metric.NewPeriodicReader(exporter, metric.WithInterval(cfg.Interval)))
+}
+
+func newInternalMetricsReporter(ctx context.Context, hostID string, reader metric.Reader) (*InternalMetricsReporter, error) {
+	provider := metric.NewMeterProvider(
+		metric.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("beyla"),
+			semconv.ServiceVersion(buildinfo.Version),
+			semconv.TelemetrySDKNameKey.String("beyla"),
+			semconv.HostID(hostID),
+		)),
+		metric.WithReader(reader),
+	)
+	meter := provider.Meter(reporterName)
+	ir := &InternalMetricsReporter{ctx: ctx, provider: provider}
+	var err error
+	if ir.tracerFlushes, err = meter.Float64Histogram("beyla.ebpf.tracer.flushes",
+		instrument.WithDescription("Length of the groups of traces flushed from the eBPF tracer to the next pipeline stage"),
+		instrument.WithExplicitBucketBoundaries(0, 10, 20, 40, 80, 160, 320)); err != nil {
+		return nil, fmt.Errorf("creating beyla.ebpf.tracer.flushes: %w", err)
+	}
+	if ir.ringbufReaderStalls, err = meter.Float64Counter("beyla.ebpf.ringbuf.reader.stalls",
+		instrument.WithDescription("Times that an eBPF ring buffer reader was found stalled with pending events and flushed")); err != nil {
+		return nil, fmt.Errorf("creating beyla.ebpf.ringbuf.reader.stalls: %w", err)
+	}
+	if ir.bpfProgramRunTime, err = meter.Float64Counter("beyla.bpf.program.run.time",
+		instrument.WithDescription("CPU time spent in the kernel by each eBPF program loaded by Beyla"),
+		instrument.WithUnit("s")); err != nil {
+		return nil, fmt.Errorf("creating beyla.bpf.program.run.time: %w", err)
+	}
+	if ir.bpfProgramRuns, err = meter.Int64Counter("beyla.bpf.program.runs",
+		instrument.WithDescription("Number of times that each eBPF program loaded by Beyla has been run")); err != nil {
+		return nil, fmt.Errorf("creating beyla.bpf.program.runs: %w", err)
+	}
+	if ir.bpfMapFull, err = meter.Int64Counter("beyla.bpf.map.full",
+		instrument.WithDescription("Times that an eBPF map tracking in-flight requests was found full, evicting its oldest entries")); err != nil {
+		return nil, fmt.Errorf("creating beyla.bpf.map.full: %w", err)
+	}
+	if ir.overheadBenchmark, err = meter.Float64Gauge("beyla.overhead.benchmark.request.duration",
+		instrument.WithDescription("Median latency of the overhead self-benchmark requests, with and without Beyla instrumenting them"),
+		instrument.WithUnit("s")); err != nil {
+		return nil, fmt.Errorf("creating beyla.overhead.benchmark.request.duration: %w", err)
+	}
+	if ir.otelMetricExports, err = meter.Float64Counter("beyla.otel.metric.exports",
+		instrument.WithDescription("Length of the metric batches submitted to the remote OTEL collector")); err != nil {
+		return nil, fmt.Errorf("creating beyla.otel.metric.exports: %w", err)
+	}
+	if ir.otelMetricExportErrs, err = meter.Float64Counter("beyla.otel.metric.export.errors",
+		instrument.WithDescription("Error count on each failed OTEL metric export")); err != nil {
+		return nil, fmt.Errorf("creating beyla.otel.metric.export.errors: %w", err)
+	}
+	if ir.otelTraceExports, err = meter.Float64Counter("beyla.otel.trace.exports",
+		instrument.WithDescription("Length of the trace batches submitted to the remote OTEL collector")); err != nil {
+		return nil, fmt.Errorf("creating beyla.otel.trace.exports: %w", err)
+	}
+	if ir.otelTraceExportErrs, err = meter.Float64Counter("beyla.otel.trace.export.errors",
+		instrument.WithDescription("Error count on each failed OTEL trace export")); err != nil {
+		return nil, fmt.Errorf("creating beyla.otel.trace.export.errors: %w", err)
+	}
+	if ir.otelExportRejected, err = meter.Int64Counter("beyla.otel.export.rejected.items",
+		instrument.WithDescription("Spans or metric data points rejected by the remote OTEL collector in partially successful exports")); err != nil {
+		return nil, fmt.Errorf("creating beyla.otel.export.rejected.items: %w", err)
+	}
+	if ir.prometheusRequests, err = meter.Float64Counter("beyla.prometheus.http.requests",
+		instrument.WithDescription("Requests towards the Prometheus Scrape endpoint")); err != nil {
+		return nil, fmt.Errorf("creating beyla.prometheus.http.requests: %w", err)
+	}
+	if ir.instrumentedProcesses, err = meter.Int64UpDownCounter("beyla.instrumented.processes",
+		instrument.WithDescription("Instrumented processes by Beyla")); err != nil {
+		return nil, fmt.Errorf("creating beyla.instrumented.processes: %w", err)
+	}
+	if ir.otelTracesDegraded, err = meter.Int64Gauge("beyla.otel.traces.degraded",
+		instrument.WithDescription("1 if the traces are discarded because the traces endpoint has been unreachable since startup, 0 otherwise")); err != nil {
+		return nil, fmt.Errorf("creating beyla.otel.traces.degraded: %w", err)
+	}
+	if ir.silentProcesses, err = meter.Int64UpDownCounter("beyla.silent.processes",
+		instrument.WithDescription("Instrumented processes that stopped producing spans while they are still alive")); err != nil {
+		return nil, fmt.Errorf("creating beyla.silent.processes: %w", err)
+	}
+	if ir.spansDropped, err = meter.Int64Counter("beyla.dropped.spans",
+		instrument.WithDescription("Spans discarded by the Beyla pipeline before being exported, by reason")); err != nil {
+		return nil, fmt.Errorf("creating beyla.dropped.spans: %w", err)
+	}
+	if ir.unknownProtocolBytes, err = meter.Int64Counter("beyla.unknown.protocol.bytes",
+		instrument.WithDescription("Bytes of the TCP traffic whose application protocol couldn't be detected, by server port")); err != nil {
+		return nil, fmt.Errorf("creating beyla.unknown.protocol.bytes: %w", err)
+	}
+	if ir.healthCheckRequests, err = meter.Int64Counter("beyla.health_check.requests",
+		instrument.WithDescription("HTTP server requests to the configured health-check paths, which are not converted into spans, by service and path")); err != nil {
+		return nil, fmt.Errorf("creating beyla.health_check.requests: %w", err)
+	}
+	if ir.http2StreamResets, err = meter.Int64Counter("beyla.http2.stream.resets",
+		instrument.WithDescription("HTTP/2 streams abruptly terminated by a RST_STREAM frame, by error code and sender")); err != nil {
+		return nil, fmt.Errorf("creating beyla.http2.stream.resets: %w", err)
+	}
+	buildInfoAttrs := instrument.WithAttributes(
+		attribute.String("goarch", runtime.GOARCH),
+		attribute.String("goos", runtime.GOOS),
+		attribute.String("goversion", runtime.Version()),
+		attribute.String("version", buildinfo.Version),
+		attribute.String("revision", buildinfo.Revision),
+	)
+	if _, err = meter.Int64ObservableGauge("beyla.internal.build.info",
+		instrument.WithDescription("A metric with a constant '1' value labeled by version, revision, branch, "+
+			"goversion from which Beyla was built, the goos and goarch for the build."),
+		instrument.WithInt64Callback(func(_ context.Context, observer instrument.Int64Observer) error {
+			observer.Observe(1, buildInfoAttrs)
+			return nil
+		})); err != nil {
+		return nil, fmt.Errorf("creating beyla.internal.build.info: %w", err)
+	}
+	return ir, nil
+}
+
+// Start flushes and shuts down the metrics provider when the passed context is done
+func (ir *InternalMetricsReporter) Start(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		// the original context is already cancelled, so we use a new one for the shutdown
+		if err := ir.provider.Shutdown(context.Background()); err != nil {
+			imlog().Debug("error shutting down internal metrics provider", "error", err)
+		}
+	}()
+}
+
+func (ir *InternalMetricsReporter) TracerFlush(len int) {
+	ir.tracerFlushes.Record(ir.ctx, float64(len))
+}
+
+func (ir *InternalMetricsReporter) RingbufReaderStall() {
+	ir.ringbufReaderStalls.Add(ir.ctx, 1)
+}
+
+func (ir *InternalMetricsReporter) BPFProgramRun(tracer, program string, runTime time.Duration, runs uint64) {
+	attrs := instrument.WithAttributes(
+		attribute.String("tracer", tracer),
+		attribute.String("program", program),
+	)
+	ir.bpfProgramRunTime.Add(ir.ctx, runTime.Seconds(), attrs)
+	ir.bpfProgramRuns.Add(ir.ctx, int64(runs), attrs)
+}
+
+func (ir *InternalMetricsReporter) BPFMapFull(tracer, bpfMap string) {
+	ir.bpfMapFull.Add(ir.ctx, 1, instrument.WithAttributes(
+		attribute.String("tracer", tracer),
+		attribute.String("map", bpfMap),
+	))
+}
+
+func (ir *InternalMetricsReporter) OverheadBenchmark(uninstrumented, instrumented time.Duration) {
+	ir.overheadBenchmark.Record(ir.ctx, uninstrumented.Seconds(),
+		instrument.WithAttributes(attribute.Bool("instrumented", false)))
+	ir.overheadBenchmark.Record(ir.ctx, instrumented.Seconds(),
+		instrument.WithAttributes(attribute.Bool("instrumented", true)))
+}
+
+func (ir *InternalMetricsReporter) OTELMetricExport(len int) {
+	ir.otelMetricExports.Add(ir.ctx, float64(len))
+}
+
+func (ir *InternalMetricsReporter) OTELMetricExportError(err error, _ int) {
+	ir.otelMetricExportErrs.Add(ir.ctx, 1, instrument.WithAttributes(attribute.String("error", err.Error())))
+}
+
+func (ir *InternalMetricsReporter) OTELTraceExport(len int) {
+	ir.otelTraceExports.Add(ir.ctx, float64(len))
+}
+
+func (ir *InternalMetricsReporter) OTELTraceExportError(err error, _ string) {
+	ir.otelTraceExportErrs.Add(ir.ctx, 1, instrument.WithAttributes(attribute.String("error", err.Error())))
+}
+
+func (ir *InternalMetricsReporter) OTELTracesDegraded(degraded bool) {
+	value := int64(0)
+	if degraded {
+		value = 1
+	}
+	ir.otelTracesDegraded.Record(ir.ctx, value)
+}
+
+func (ir *InternalMetricsReporter) OTELExportRejected(signal, endpoint string, rejected int) {
+	ir.otelExportRejected.Add(ir.ctx, int64(rejected), instrument.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("endpoint", endpoint),
+	))
+}
+
+func (ir *InternalMetricsReporter) PrometheusRequest(port, path string) {
+	ir.prometheusRequests.Add(ir.ctx, 1, instrument.WithAttributes(
+		attribute.String("port", port),
+		attribute.String("path", path),
+	))
+}
+
+func (ir *InternalMetricsReporter) InstrumentProcess(service *svc.ID) {
+	ir.instrumentedProcesses.Add(ir.ctx, 1, instrumentedProcessAttrs(service))
+}
+
+func (ir *InternalMetricsReporter) UninstrumentProcess(service *svc.ID) {
+	ir.instrumentedProcesses.Add(ir.ctx, -1, instrumentedProcessAttrs(service))
+}
+
+func (ir *InternalMetricsReporter) ServiceSilenced(service *svc.ID) {
+	ir.silentProcesses.Add(ir.ctx, 1, instrumentedProcessAttrs(service))
+}
+
+func (ir *InternalMetricsReporter) ServiceResumed(service *svc.ID) {
+	ir.silentProcesses.Add(ir.ctx, -1, instrumentedProcessAttrs(service))
+}
+
+func (ir *InternalMetricsReporter) SpansDropped(reason string, count int, _ fmt.Stringer) {
+	ir.spansDropped.Add(ir.ctx, int64(count), instrument.WithAttributes(attribute.String("reason", reason)))
+}
+
+func (ir *InternalMetricsReporter) UnknownProtocolTraffic(serverPort int, bytes uint64) {
+	ir.unknownProtocolBytes.Add(ir.ctx, int64(bytes), instrument.WithAttributes(attribute.Int("server.port", serverPort)))
+}
+
+func (ir *InternalMetricsReporter) HealthCheckRequests(service *svc.ID, path string, count int) {
+	ir.healthCheckRequests.Add(ir.ctx, int64(count), instrument.WithAttributes(
+		semconv.ServiceName(service.Name),
+		semconv.ServiceNamespace(service.Namespace),
+		attribute.String("url.path", path)))
+}
+
+func (ir *InternalMetricsReporter) HTTP2StreamResets(errorCode, sender string, count int) {
+	ir.http2StreamResets.Add(ir.ctx, int64(count), instrument.WithAttributes(
+		attribute.String("error.code", errorCode), attribute.String("sender", sender)))
+}
+
+func instrumentedProcessAttrs(service *svc.ID) instrument.AddOption {
+	return instrument.WithAttributes(
+		semconv.ServiceName(service.Name),
+		semconv.ServiceNamespace(service.Namespace),
+		attribute.String("language", service.SDKLanguage.String()),
+	)
+}
@@ -0,0 +1,102 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// otelSnapshot returns a textual representation of the values of all the collected metrics
+func otelSnapshot(t *testing.T, reader metric.Reader) string {
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	sb := strings.Builder{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[float64]:
+				for _, dp := range data.DataPoints {
+					fmt.Fprintf(&sb, "%s %v %v\n", m.Name, dp.Attributes.ToSlice(), dp.Value)
+				}
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					fmt.Fprintf(&sb, "%s %v %v\n", m.Name, dp.Attributes.ToSlice(), dp.Value)
+				}
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					fmt.Fprintf(&sb, "%s %v %v\n", m.Name, dp.Attributes.ToSlice(), dp.Value)
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					fmt.Fprintf(&sb, "%s %v %v\n", m.Name, dp.Attributes.ToSlice(), dp.Value)
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					fmt.Fprintf(&sb, "%s %v %v\n", m.Name, dp.Attributes.ToSlice(), dp.Count)
+				}
+			default:
+				t.Fatalf("unexpected metric type for %s: %T", m.Name, m.Data)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// TestInternalMetricsReporter_Coverage verifies that every method of the imetrics.Reporter
+// interface is reflected in the exported OTEL metrics
+func TestInternalMetricsReporter_Coverage(t *testing.T) {
+	reader := metric.NewManualReader()
+	ir, err := newInternalMetricsReporter(context.Background(), "host-id", reader)
+	require.NoError(t, err)
+
+	last := otelSnapshot(t, reader)
+	assert.Contains(t, last, "beyla.internal.build.info")
+
+	rt := reflect.TypeOf((*imetrics.Reporter)(nil)).Elem()
+	rv := reflect.ValueOf(ir)
+	for i := 0; i < rt.NumMethod(); i++ {
+		method := rt.Method(i)
+		if method.Name == "Start" {
+			continue
+		}
+		var args []reflect.Value
+		for a := 0; a < method.Type.NumIn(); a++ {
+			switch in := method.Type.In(a); in.Kind() {
+			case reflect.Int, reflect.Int64, reflect.Uint64:
+				// also covers time.Duration arguments
+				args = append(args, reflect.ValueOf(3).Convert(in))
+			case reflect.String:
+				args = append(args, reflect.ValueOf("foo"))
+			case reflect.Bool:
+				args = append(args, reflect.ValueOf(true))
+			case reflect.Pointer:
+				require.Equal(t, reflect.TypeOf(&svc.ID{}), in, "unsupported argument in %s", method.Name)
+				args = append(args, reflect.ValueOf(&svc.ID{Name: "foo", SDKLanguage: svc.InstrumentableGolang}))
+			case reflect.Interface:
+				if in == reflect.TypeOf((*fmt.Stringer)(nil)).Elem() {
+					args = append(args, reflect.ValueOf(&svc.ID{Name: "foo"}))
+					break
+				}
+				require.Equal(t, reflect.TypeOf((*error)(nil)).Elem(), in, "unsupported argument in %s", method.Name)
+				args = append(args, reflect.ValueOf(errors.New("failed")))
+			default:
+				require.Failf(t, "unsupported argument", "%s in method %s", in, method.Name)
+			}
+		}
+		rv.MethodByName(method.Name).Call(args)
+		current := otelSnapshot(t, reader)
+		assert.NotEqual(t, last, current, "method %s did not update any metric", method.Name)
+		last = current
+	}
+}
@@ -17,14 +17,14 @@ type instrumentedMetricsExporter struct {
 }
 
 func (ie *instrumentedMetricsExporter) Export(ctx context.Context, md *metricdata.ResourceMetrics) error {
-	if err := ie.Exporter.Export(ctx, md); err != nil {
-		ie.internal.OTELMetricExportError(err)
-		return err
-	}
 	totalMetrics := 0
 	for _, scope := range md.ScopeMetrics {
 		totalMetrics += len(scope.Metrics)
 	}
+	if err := ie.Exporter.Export(ctx, md); err != nil {
+		ie.internal.OTELMetricExportError(err, totalMetrics)
+		return err
+	}
 	ie.internal.OTELMetricExport(totalMetrics)
 	return nil
 }
@@ -36,9 +36,20 @@ type instrumentedTracesExporter struct {
 
 func (ie *instrumentedTracesExporter) ExportSpans(ctx context.Context, ss []trace.ReadOnlySpan) error {
 	if err := ie.SpanExporter.ExportSpans(ctx, ss); err != nil {
-		ie.internal.OTELTraceExportError(err)
+		ie.internal.OTELTraceExportError(err, failedTraceID(ss))
 		return err
 	}
 	ie.internal.OTELTraceExport(len(ss))
 	return nil
 }
+
+// failedTraceID returns the ID of the first valid trace in the batch, to be used
+// as exemplar of the export errors
+func failedTraceID(ss []trace.ReadOnlySpan) string {
+	for _, s := range ss {
+		if sc := s.SpanContext(); sc.HasTraceID() {
+			return sc.TraceID().String()
+		}
+	}
+	return ""
+}
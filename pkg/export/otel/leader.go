@@ -0,0 +1,51 @@
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/grafana/beyla/pkg/internal/kube"
+)
+
+// leaderMetricsExporter drops the service graph metrics from the exported batches when the
+// current instance is not the leader. Standby instances keep aggregating the metrics, so they
+// can take over the export of their own accumulated values if the leader goes down.
+type leaderMetricsExporter struct {
+	metric.Exporter
+	leader *kube.LeaderElector
+}
+
+func isServiceGraphMetric(name string) bool {
+	switch name {
+	case ServiceGraphClient, ServiceGraphServer, ServiceGraphFailed, ServiceGraphTotal:
+		return true
+	}
+	return false
+}
+
+func (le *leaderMetricsExporter) Export(ctx context.Context, md *metricdata.ResourceMetrics) error {
+	if le.leader.IsLeader() {
+		return le.Exporter.Export(ctx, md)
+	}
+	filtered := metricdata.ResourceMetrics{
+		Resource:     md.Resource,
+		ScopeMetrics: make([]metricdata.ScopeMetrics, 0, len(md.ScopeMetrics)),
+	}
+	for _, sm := range md.ScopeMetrics {
+		fsm := metricdata.ScopeMetrics{Scope: sm.Scope}
+		for _, m := range sm.Metrics {
+			if !isServiceGraphMetric(m.Name) {
+				fsm.Metrics = append(fsm.Metrics, m)
+			}
+		}
+		if len(fsm.Metrics) > 0 {
+			filtered.ScopeMetrics = append(filtered.ScopeMetrics, fsm)
+		}
+	}
+	if len(filtered.ScopeMetrics) == 0 {
+		return nil
+	}
+	return le.Exporter.Export(ctx, &filtered)
+}
@@ -0,0 +1,115 @@
+package otel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/grafana/beyla/pkg/internal/kube"
+)
+
+type fakeMetricsExporter struct {
+	metric.Exporter
+	exported []*metricdata.ResourceMetrics
+}
+
+func (f *fakeMetricsExporter) Export(_ context.Context, md *metricdata.ResourceMetrics) error {
+	f.exported = append(f.exported, md)
+	return nil
+}
+
+func metricNames(md *metricdata.ResourceMetrics) []string {
+	var names []string
+	for _, sm := range md.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+func TestLeaderMetricsExporter(t *testing.T) {
+	md := &metricdata.ResourceMetrics{ScopeMetrics: []metricdata.ScopeMetrics{{
+		Metrics: []metricdata.Metrics{
+			{Name: "http.server.request.duration"},
+			{Name: ServiceGraphClient},
+			{Name: ServiceGraphTotal},
+		},
+	}, {
+		Metrics: []metricdata.Metrics{
+			{Name: ServiceGraphServer},
+			{Name: ServiceGraphFailed},
+		},
+	}}}
+
+	t.Run("disabled election: export everything", func(t *testing.T) {
+		fake := &fakeMetricsExporter{}
+		exp := leaderMetricsExporter{Exporter: fake}
+		require.NoError(t, exp.Export(context.Background(), md))
+		require.Len(t, fake.exported, 1)
+		assert.Equal(t, []string{"http.server.request.duration", ServiceGraphClient,
+			ServiceGraphTotal, ServiceGraphServer, ServiceGraphFailed}, metricNames(fake.exported[0]))
+	})
+
+	t.Run("standby instance: drop service graph metrics", func(t *testing.T) {
+		fake := &fakeMetricsExporter{}
+		exp := leaderMetricsExporter{Exporter: fake,
+			leader: kube.NewLeaderElector(&kube.LeaderElectionConfig{Enable: true})}
+		require.NoError(t, exp.Export(context.Background(), md))
+		require.Len(t, fake.exported, 1)
+		assert.Equal(t, []string{"http.server.request.duration"}, metricNames(fake.exported[0]))
+	})
+
+	t.Run("standby instance: skip empty batches", func(t *testing.T) {
+		fake := &fakeMetricsExporter{}
+		exp := leaderMetricsExporter{Exporter: fake,
+			leader: kube.NewLeaderElector(&kube.LeaderElectionConfig{Enable: true})}
+		require.NoError(t, exp.Export(context.Background(), &metricdata.ResourceMetrics{
+			ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{{Name: ServiceGraphTotal}}}},
+		}))
+		assert.Empty(t, fake.exported)
+	})
+}
+
+type shutdownCountingExporter struct {
+	fakeMetricsExporter
+	shutdowns int
+}
+
+func (f *shutdownCountingExporter) Temporality(k metric.InstrumentKind) metricdata.Temporality {
+	return metric.DefaultTemporalitySelector(k)
+}
+
+func (f *shutdownCountingExporter) Aggregation(k metric.InstrumentKind) metric.Aggregation {
+	return metric.DefaultAggregationSelector(k)
+}
+
+func (f *shutdownCountingExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (f *shutdownCountingExporter) Shutdown(context.Context) error {
+	f.shutdowns++
+	return nil
+}
+
+func TestSharedMetricsExporter(t *testing.T) {
+	fake := &shutdownCountingExporter{}
+	provider := metric.NewMeterProvider(metric.WithReader(
+		metric.NewPeriodicReader(sharedMetricsExporter{Exporter: fake}, metric.WithInterval(time.Hour))))
+	counter, err := provider.Meter("test").Int64Counter("requests")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	// shutting down the provider of an evicted service flushes its last data points,
+	// but keeps the exporter open for the rest of services
+	require.NoError(t, provider.Shutdown(context.Background()))
+	require.Len(t, fake.exported, 1)
+	assert.Equal(t, []string{"requests"}, metricNames(fake.exported[0]))
+	assert.Zero(t, fake.shutdowns)
+}
@@ -20,6 +20,7 @@ import (
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
+	"google.golang.org/grpc"
 
 	"github.com/grafana/beyla/pkg/export/attributes"
 	attr "github.com/grafana/beyla/pkg/export/attributes/names"
@@ -70,6 +71,9 @@ type MetricsConfig struct {
 	Protocol        Protocol `yaml:"protocol" env:"OTEL_EXPORTER_OTLP_PROTOCOL"`
 	MetricsProtocol Protocol `yaml:"-" env:"OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"`
 
+	Compression        Compression `yaml:"compression" env:"OTEL_EXPORTER_OTLP_COMPRESSION"`
+	MetricsCompression Compression `yaml:"-" env:"OTEL_EXPORTER_OTLP_METRICS_COMPRESSION"`
+
 	// InsecureSkipVerify is not standard, so we don't follow the same naming convention
 	InsecureSkipVerify bool `yaml:"insecure_skip_verify" env:"BEYLA_OTEL_INSECURE_SKIP_VERIFY"`
 
@@ -117,6 +121,13 @@ func (m *MetricsConfig) GetProtocol() Protocol {
 	return m.GuessProtocol()
 }
 
+func (m *MetricsConfig) GetCompression() Compression {
+	if m.MetricsCompression != "" {
+		return m.MetricsCompression
+	}
+	return m.Compression
+}
+
 func (m *MetricsConfig) GuessProtocol() Protocol {
 	// If no explicit protocol is set, we guess it it from the metrics enpdoint port
 	// (assuming it uses a standard port or a development-like form like 14317, 24317, 14318...)
@@ -180,6 +191,7 @@ type MetricsReporter struct {
 	attrGRPCServer            []attributes.Field[*request.Span, attribute.KeyValue]
 	attrGRPCClient            []attributes.Field[*request.Span, attribute.KeyValue]
 	attrDBClient              []attributes.Field[*request.Span, attribute.KeyValue]
+	attrDBRedisRedirects      []attributes.Field[*request.Span, attribute.KeyValue]
 	attrMessagingPublish      []attributes.Field[*request.Span, attribute.KeyValue]
 	attrMessagingProcess      []attributes.Field[*request.Span, attribute.KeyValue]
 	attrHTTPRequestSize       []attributes.Field[*request.Span, attribute.KeyValue]
@@ -199,6 +211,7 @@ type Metrics struct {
 	grpcDuration          *Expirer[*request.Span, instrument.Float64Histogram, float64]
 	grpcClientDuration    *Expirer[*request.Span, instrument.Float64Histogram, float64]
 	dbClientDuration      *Expirer[*request.Span, instrument.Float64Histogram, float64]
+	dbRedisRedirects      *Expirer[*request.Span, instrument.Int64Counter, int64]
 	msgPublishDuration    *Expirer[*request.Span, instrument.Float64Histogram, float64]
 	msgProcessDuration    *Expirer[*request.Span, instrument.Float64Histogram, float64]
 	httpRequestSize       *Expirer[*request.Span, instrument.Float64Histogram, float64]
@@ -277,6 +290,8 @@ func newMetricsReporter(
 	if is.DBEnabled() {
 		mr.attrDBClient = attributes.OpenTelemetryGetters(
 			request.SpanOTELGetters, mr.attributes.For(attributes.DBClientDuration))
+		mr.attrDBRedisRedirects = attributes.OpenTelemetryGetters(
+			request.SpanOTELGetters, mr.attributes.For(attributes.DBClientRedisRedirects))
 	}
 
 	if is.MQEnabled() {
@@ -297,17 +312,22 @@ func newMetricsReporter(
 			llog.Debug("evicting metrics reporter from cache")
 			v.value.cleanupAllMetricsInstances()
 			go func() {
-				if err := v.value.provider.ForceFlush(ctx); err != nil {
-					llog.Warn("error flushing evicted metrics provider", "error", err)
+				// flushes the last data points and stops the periodic reader of the evicted provider,
+				// so the services that are gone don't keep being collected and exported
+				if err := v.value.provider.Shutdown(ctx); err != nil {
+					llog.Warn("error shutting down evicted metrics provider", "error", err)
 				}
 			}()
 		}, mr.newMetricSet)
 	// Instantiate the OTLP HTTP or GRPC metrics exporter
-	exporter, err := InstantiateMetricsExporter(ctx, cfg, log)
+	exporter, err := InstantiateMetricsExporter(ctx, cfg, ctxInfo.Metrics, log)
 	if err != nil {
 		return nil, err
 	}
-	mr.exporter = instrumentMetricsExporter(ctxInfo.Metrics, exporter)
+	mr.exporter = instrumentMetricsExporter(ctxInfo.Metrics, renameMetricsExporter(ctxInfo.MetricRenamer, exporter))
+	if cfg.ServiceGraphMetricsEnabled() && ctxInfo.ServiceGraphLeader != nil {
+		mr.exporter = &leaderMetricsExporter{Exporter: mr.exporter, leader: ctxInfo.ServiceGraphLeader}
+	}
 
 	return &mr, nil
 }
@@ -436,6 +456,13 @@ func (mr *MetricsReporter) setupOtelMeters(m *Metrics, meter instrument.Meter) e
 		}
 		m.dbClientDuration = NewExpirer[*request.Span, instrument.Float64Histogram, float64](
 			m.ctx, dbClientDuration, mr.attrDBClient, timeNow, mr.cfg.TTL)
+
+		dbRedisRedirects, err := meter.Int64Counter(attributes.DBClientRedisRedirects.OTEL)
+		if err != nil {
+			return fmt.Errorf("creating db client redis redirects counter metric: %w", err)
+		}
+		m.dbRedisRedirects = NewExpirer[*request.Span, instrument.Int64Counter, int64](
+			m.ctx, dbRedisRedirects, mr.attrDBRedisRedirects, timeNow, mr.cfg.TTL)
 	}
 
 	if mr.is.MQEnabled() {
@@ -542,6 +569,17 @@ func (mr *MetricsReporter) setupGraphMeters(m *Metrics, meter instrument.Meter)
 	return nil
 }
 
+// sharedMetricsExporter wraps the exporter that is shared by the MeterProviders of all the services.
+// Shutting down the provider of an evicted service must not shut down the shared exporter, which
+// is closed with the MetricsReporter.
+type sharedMetricsExporter struct {
+	metric.Exporter
+}
+
+func (sharedMetricsExporter) Shutdown(context.Context) error {
+	return nil
+}
+
 func (mr *MetricsReporter) newMetricSet(service *svc.ID) (*Metrics, error) {
 	mlog := mlog().With("service", service)
 	mlog.Debug("creating new Metrics reporter")
@@ -550,7 +588,7 @@ func (mr *MetricsReporter) newMetricSet(service *svc.ID) (*Metrics, error) {
 
 	opts := []metric.Option{
 		metric.WithResource(resources),
-		metric.WithReader(metric.NewPeriodicReader(mr.exporter,
+		metric.WithReader(metric.NewPeriodicReader(sharedMetricsExporter{Exporter: mr.exporter},
 			metric.WithInterval(mr.cfg.Interval))),
 	}
 
@@ -612,8 +650,10 @@ func isExponentialAggregation(mc *MetricsConfig, mlog *slog.Logger) bool {
 	return false
 }
 
+// InstantiateMetricsExporter creates the OTLP metrics exporter. If the internal reporter is not nil,
+// the partial successes of the OTLP/GRPC exports are accounted on it.
 // TODO: restore as private
-func InstantiateMetricsExporter(ctx context.Context, cfg *MetricsConfig, log *slog.Logger) (metric.Exporter, error) {
+func InstantiateMetricsExporter(ctx context.Context, cfg *MetricsConfig, internal imetrics.Reporter, log *slog.Logger) (metric.Exporter, error) {
 	var err error
 	var exporter metric.Exporter
 	switch proto := cfg.GetProtocol(); proto {
@@ -624,7 +664,7 @@ func InstantiateMetricsExporter(ctx context.Context, cfg *MetricsConfig, log *sl
 		}
 	case ProtocolGRPC:
 		log.Debug("instantiating GRPC MetricsReporter", "protocol", proto)
-		if exporter, err = grpcMetricsExporter(ctx, cfg); err != nil {
+		if exporter, err = grpcMetricsExporter(ctx, cfg, internal); err != nil {
 			return nil, fmt.Errorf("can't instantiate OTEL GRPC metrics exporter: %w", err)
 		}
 	default:
@@ -646,12 +686,16 @@ func httpMetricsExporter(ctx context.Context, cfg *MetricsConfig) (metric.Export
 	return mexp, nil
 }
 
-func grpcMetricsExporter(ctx context.Context, cfg *MetricsConfig) (metric.Exporter, error) {
+func grpcMetricsExporter(ctx context.Context, cfg *MetricsConfig, internal imetrics.Reporter) (metric.Exporter, error) {
 	opts, err := getGRPCMetricEndpointOptions(cfg)
 	if err != nil {
 		return nil, err
 	}
-	mexp, err := otlpmetricgrpc.New(ctx, opts.AsMetricGRPC()...)
+	var dialOpts []grpc.DialOption
+	if internal != nil {
+		dialOpts = append(dialOpts, grpc.WithUnaryInterceptor(metricsPartialSuccessInterceptor(opts.Endpoint, internal)))
+	}
+	mexp, err := otlpmetricgrpc.New(ctx, opts.AsMetricGRPC(dialOpts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("creating GRPC metric exporter: %w", err)
 	}
@@ -799,8 +843,13 @@ func (r *Metrics) record(span *request.Span, mr *MetricsReporter) {
 			if mr.is.DBEnabled() {
 				dbClientDuration, attrs := r.dbClientDuration.ForRecord(span)
 				dbClientDuration.Record(r.ctx, duration, instrument.WithAttributeSet(attrs))
+				if span.RedisRedirect != nil {
+					dbRedisRedirects, attrs := r.dbRedisRedirects.ForRecord(span)
+					dbRedisRedirects.Add(r.ctx, 1, instrument.WithAttributeSet(attrs))
+				}
 			}
-		case request.EventTypeKafkaClient, request.EventTypeKafkaServer:
+		case request.EventTypeKafkaClient, request.EventTypeKafkaServer, request.EventTypeAMQPClient, request.EventTypeAMQPServer,
+			request.EventTypeNATSClient, request.EventTypeNATSServer:
 			if mr.is.MQEnabled() {
 				switch span.Method {
 				case request.MessagingPublish:
@@ -900,6 +949,16 @@ func getHTTPMetricEndpointOptions(cfg *MetricsConfig) (otlpOptions, error) {
 		opts.SkipTLSVerify = cfg.InsecureSkipVerify
 	}
 
+	opts.Compression = cfg.GetCompression()
+	if err := opts.Compression.validate(); err != nil {
+		return opts, err
+	}
+	if opts.Compression == CompressionZstd {
+		// the OTEL SDK HTTP metrics exporter only supports gzip
+		log.Warn("zstd compression is not supported by the HTTP metrics exporter. Using gzip instead")
+		opts.Compression = CompressionGzip
+	}
+
 	cfg.Grafana.setupOptions(&opts)
 
 	return opts, nil
@@ -925,6 +984,10 @@ func getGRPCMetricEndpointOptions(cfg *MetricsConfig) (otlpOptions, error) {
 		log.Debug("Setting InsecureSkipVerify")
 		opts.SkipTLSVerify = true
 	}
+	opts.Compression = cfg.GetCompression()
+	if err := opts.Compression.validate(); err != nil {
+		return opts, err
+	}
 	return opts, nil
 }
 
@@ -979,7 +1042,7 @@ func setMetricsProtocol(cfg *MetricsConfig) {
 	os.Setenv(envMetricsProtocol, string(cfg.GuessProtocol()))
 }
 
-func cleanupMetrics(ctx context.Context, m *Expirer[*request.Span, instrument.Float64Histogram, float64]) {
+func cleanupMetrics[M removableMetric[V], V any](ctx context.Context, m *Expirer[*request.Span, M, V]) {
 	if m != nil {
 		m.RemoveAllMetrics(ctx)
 	}
@@ -991,6 +1054,7 @@ func (r *Metrics) cleanupAllMetricsInstances() {
 	cleanupMetrics(r.ctx, r.grpcDuration)
 	cleanupMetrics(r.ctx, r.grpcClientDuration)
 	cleanupMetrics(r.ctx, r.dbClientDuration)
+	cleanupMetrics(r.ctx, r.dbRedisRedirects)
 	cleanupMetrics(r.ctx, r.msgPublishDuration)
 	cleanupMetrics(r.ctx, r.msgProcessDuration)
 	cleanupMetrics(r.ctx, r.httpRequestSize)
@@ -82,11 +82,12 @@ func NetMetricsExporterProvider(ctx context.Context, ctxInfo *global.ContextInfo
 func newMetricsExporter(ctx context.Context, ctxInfo *global.ContextInfo, cfg *NetMetricsConfig) (*netMetricsExporter, error) {
 	log := nmlog()
 	log.Debug("instantiating network metrics exporter provider")
-	exporter, err := InstantiateMetricsExporter(context.Background(), cfg.Metrics, log)
+	exporter, err := InstantiateMetricsExporter(context.Background(), cfg.Metrics, ctxInfo.Metrics, log)
 	if err != nil {
 		log.Error("", "error", err)
 		return nil, err
 	}
+	exporter = renameMetricsExporter(ctxInfo.MetricRenamer, exporter)
 
 	provider, err := newMeterProvider(newResource(ctxInfo.HostID), &exporter, cfg.Metrics.Interval)
 
@@ -182,11 +182,12 @@ func newProcMetricsExporter(
 			}()
 		}, mr.newMetricSet)
 
-	mr.exporter, err = InstantiateMetricsExporter(ctx, cfg.Metrics, log)
+	mr.exporter, err = InstantiateMetricsExporter(ctx, cfg.Metrics, ctxInfo.Metrics, log)
 	if err != nil {
 		log.Error("instantiating metrics exporter", "error", err)
 		return nil, err
 	}
+	mr.exporter = renameMetricsExporter(ctxInfo.MetricRenamer, mr.exporter)
 
 	return mr.Do, nil
 }
@@ -74,6 +74,30 @@ func TestHTTPMetricsEndpointOptions(t *testing.T) {
 	t.Run("testing with skip TLS verification", func(t *testing.T) {
 		testMetricsHTTPOptions(t, otlpOptions{Endpoint: "localhost:3232", URLPath: "/v1/metrics", SkipTLSVerify: true}, &mcfg)
 	})
+
+	mcfg = MetricsConfig{
+		CommonEndpoint:     "https://localhost:3232",
+		Compression:        CompressionNone,
+		MetricsCompression: CompressionGzip,
+		Instrumentations:   []string{instrumentations.InstrumentationHTTP},
+	}
+	t.Run("testing with compression", func(t *testing.T) {
+		testMetricsHTTPOptions(t, otlpOptions{Endpoint: "localhost:3232", URLPath: "/v1/metrics", Compression: CompressionGzip}, &mcfg)
+	})
+
+	mcfg = MetricsConfig{
+		CommonEndpoint:   "https://localhost:3232",
+		Compression:      CompressionZstd,
+		Instrumentations: []string{instrumentations.InstrumentationHTTP},
+	}
+	t.Run("zstd is not supported over HTTP", func(t *testing.T) {
+		testMetricsHTTPOptions(t, otlpOptions{Endpoint: "localhost:3232", URLPath: "/v1/metrics", Compression: CompressionGzip}, &mcfg)
+	})
+
+	t.Run("invalid compression", func(t *testing.T) {
+		_, err := getHTTPMetricEndpointOptions(&MetricsConfig{CommonEndpoint: "https://localhost:3232", Compression: "brotli"})
+		assert.Error(t, err)
+	})
 }
 
 func TestHTTPMetricsWithGrafanaOptions(t *testing.T) {
@@ -281,6 +305,21 @@ func TestGRPCMetricsEndpointOptions(t *testing.T) {
 	t.Run("testing with skip TLS verification", func(t *testing.T) {
 		testMetricsGRPCOptions(t, otlpOptions{Endpoint: "localhost:3232", SkipTLSVerify: true}, &mcfg)
 	})
+
+	mcfg = MetricsConfig{
+		CommonEndpoint:   "https://localhost:3232",
+		Compression:      CompressionZstd,
+		Instrumentations: []string{instrumentations.InstrumentationHTTP},
+	}
+
+	t.Run("testing with compression", func(t *testing.T) {
+		testMetricsGRPCOptions(t, otlpOptions{Endpoint: "localhost:3232", Compression: CompressionZstd}, &mcfg)
+	})
+
+	t.Run("invalid compression", func(t *testing.T) {
+		_, err := getGRPCMetricEndpointOptions(&MetricsConfig{CommonEndpoint: "https://localhost:3232", MetricsCompression: "brotli"})
+		assert.Error(t, err)
+	})
 }
 
 func testMetricsGRPCOptions(t *testing.T, expected otlpOptions, mcfg *MetricsConfig) {
@@ -623,7 +662,7 @@ func (f *fakeInternalMetrics) OTELMetricExport(len int) {
 	f.sum.Add(int32(len))
 }
 
-func (f *fakeInternalMetrics) OTELMetricExportError(_ error) {
+func (f *fakeInternalMetrics) OTELMetricExportError(_ error, _ int) {
 	fakeMux.Lock()
 	defer fakeMux.Unlock()
 	f.errs.Add(1)
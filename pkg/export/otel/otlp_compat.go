@@ -0,0 +1,191 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/beyla/pkg/buildinfo"
+)
+
+// beylaBuildInfo identifies Beyla in the User-Agent of the OTLP exporters from the OpenTelemetry Collector.
+// This way, the administrators of the OTLP endpoints can tell which Beyla versions send the rejected requests.
+var beylaBuildInfo = component.BuildInfo{
+	Command:     "beyla",
+	Description: "Beyla",
+	Version:     buildinfo.Version,
+}
+
+// userAgent returns the same User-Agent as the OTLP exporters from the OpenTelemetry Collector
+// when they are created with beylaBuildInfo. It overrides the User-Agent of the OTEL SDK exporters.
+func userAgent() string {
+	return fmt.Sprintf("%s/%s (%s/%s)",
+		beylaBuildInfo.Description, beylaBuildInfo.Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// compatNegotiator wraps the function that sends the traces batches to an OTLP endpoint, and removes the
+// fields from the latest OTLP versions according to the OTLPCompatibility configuration. In auto mode,
+// the fields are only removed after the endpoint rejected them.
+// The negotiated field set belongs to each exporter, and it is not shared with the other exporters.
+type compatNegotiator struct {
+	endpoint string
+	mode     OTLPCompatibility
+	consume  consumer.ConsumeTracesFunc
+
+	mt     sync.Mutex
+	legacy bool
+	// diagnosed avoids repeating the same diagnostics for each rejected request
+	diagnosed bool
+}
+
+func newCompatNegotiator(endpoint string, mode OTLPCompatibility, consume consumer.ConsumeTracesFunc) *compatNegotiator {
+	if mode == "" {
+		mode = OTLPCompatibilityAuto
+	}
+	return &compatNegotiator{endpoint: endpoint, mode: mode, consume: consume}
+}
+
+func (cn *compatNegotiator) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	if cn.mode == OTLPCompatibilityLegacy || (cn.mode == OTLPCompatibilityAuto && cn.isLegacy()) {
+		legacy, _ := legacyTraces(td)
+		err := cn.consume(ctx, legacy)
+		if isInvalidRequest(err) {
+			cn.diagnose(OTLPCompatibilityLegacy, err)
+		}
+		return err
+	}
+	err := cn.consume(ctx, td)
+	if !isInvalidRequest(err) {
+		return err
+	}
+	if cn.mode == OTLPCompatibilityLatest {
+		cn.diagnose(cn.mode, err)
+		return err
+	}
+	legacy, stripped := legacyTraces(td)
+	if !namesAnyField(err, stripped) {
+		// the rejection wasn't caused by the latest OTLP fields
+		cn.diagnose(OTLPCompatibilityLegacy, err)
+		return err
+	}
+	if legacyErr := cn.consume(ctx, legacy); legacyErr != nil {
+		if isInvalidRequest(legacyErr) {
+			cn.diagnose(OTLPCompatibilityLegacy, legacyErr)
+		}
+		return legacyErr
+	}
+	cn.setLegacy(err)
+	return nil
+}
+
+func (cn *compatNegotiator) isLegacy() bool {
+	cn.mt.Lock()
+	defer cn.mt.Unlock()
+	return cn.legacy
+}
+
+func (cn *compatNegotiator) setLegacy(rejection error) {
+	cn.mt.Lock()
+	defer cn.mt.Unlock()
+	if cn.legacy {
+		return
+	}
+	cn.legacy = true
+	tlog().Warn("OTLP endpoint rejected the fields from the latest OTLP versions, but accepted the legacy ones."+
+		" Sending the traces without them. Please consider upgrading the OTLP endpoint",
+		"endpoint", cn.endpoint, "error", rejection)
+}
+
+// diagnose logs, once per exporter, why the endpoint might have rejected the traces
+func (cn *compatNegotiator) diagnose(mode OTLPCompatibility, rejection error) {
+	cn.mt.Lock()
+	defer cn.mt.Unlock()
+	if cn.diagnosed {
+		return
+	}
+	cn.diagnosed = true
+	if mode == OTLPCompatibilityLatest {
+		tlog().Error("OTLP endpoint rejected the traces as invalid. If it is an old OpenTelemetry Collector or"+
+			" any other OTLP endpoint that does not support the latest OTLP versions, please set the"+
+			" otel_traces_export.otlp_compatibility option to 'auto' or 'legacy'",
+			"endpoint", cn.endpoint, "userAgent", userAgent(), "error", rejection)
+		return
+	}
+	tlog().Error("OTLP endpoint rejected the traces as invalid, even without the fields from the latest"+
+		" OTLP versions. Please check the OTLP endpoint logs, and that its version supports OTLP 0.16.0 or later",
+		"endpoint", cn.endpoint, "userAgent", userAgent(), "error", rejection)
+}
+
+// namesAnyField returns whether the rejection error mentions any of the given OTLP fields, so
+// the endpoint likely rejected the request because of them and not because of any other problem
+func namesAnyField(err error, fields []string) bool {
+	msg := strings.ToLower(err.Error())
+	for _, field := range fields {
+		if strings.Contains(msg, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// isInvalidRequest returns whether the OTLP exporter failed because the endpoint couldn't parse or validate
+// the request: HTTP 400 for OTLP/HTTP (which the exporter maps to InvalidArgument), or an InvalidArgument
+// status for OTLP/gRPC
+func isInvalidRequest(err error) bool {
+	if err == nil {
+		return false
+	}
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.InvalidArgument
+}
+
+// legacyTraces returns a copy of the traces without the fields that were added in OTLP 0.17.0 or later:
+// the span and link flags, and the instrumentation scope attributes. It also returns the names of the
+// removed fields, which are empty if the copy doesn't differ from the original traces.
+func legacyTraces(td ptrace.Traces) (ptrace.Traces, []string) {
+	legacy := ptrace.NewTraces()
+	td.CopyTo(legacy)
+	var stripped []string
+	for i := 0; i < legacy.ResourceSpans().Len(); i++ {
+		scopes := legacy.ResourceSpans().At(i).ScopeSpans()
+		for j := 0; j < scopes.Len(); j++ {
+			scope := scopes.At(j).Scope()
+			if scope.Attributes().Len() > 0 || scope.DroppedAttributesCount() > 0 {
+				scope.Attributes().Clear()
+				scope.SetDroppedAttributesCount(0)
+				stripped = appendField(stripped, "attributes")
+			}
+			spans := scopes.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				if span.Flags() != 0 {
+					span.SetFlags(0)
+					stripped = appendField(stripped, "flags")
+				}
+				for l := 0; l < span.Links().Len(); l++ {
+					if link := span.Links().At(l); link.Flags() != 0 {
+						link.SetFlags(0)
+						stripped = appendField(stripped, "flags")
+					}
+				}
+			}
+		}
+	}
+	return legacy, stripped
+}
+
+func appendField(fields []string, field string) []string {
+	if slices.Contains(fields, field) {
+		return fields
+	}
+	return append(fields, field)
+}
@@ -0,0 +1,199 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+)
+
+// legacyEndpoint fakes an OTLP endpoint that rejects the span flags, as they were added in OTLP 1.1.0
+type legacyEndpoint struct {
+	requests int
+	received []uint32
+}
+
+func (le *legacyEndpoint) consume(_ context.Context, td ptrace.Traces) error {
+	le.requests++
+	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	for i := 0; i < spans.Len(); i++ {
+		if spans.At(i).Flags() != 0 {
+			return consumererror.NewPermanent(status.New(codes.InvalidArgument,
+				"error exporting items, request to http://collector:4318/v1/traces responded with HTTP Status Code 400,"+
+					` Message=json: unknown field "flags"`).Err())
+		}
+	}
+	for i := 0; i < spans.Len(); i++ {
+		le.received = append(le.received, spans.At(i).Flags())
+	}
+	return nil
+}
+
+func sampledTraces() ptrace.Traces {
+	td := testTraces(2)
+	td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).SetFlags(1)
+	return td
+}
+
+func TestCompatNegotiator_Auto(t *testing.T) {
+	endpoint := &legacyEndpoint{}
+	cn := &compatNegotiator{endpoint: "collector", mode: OTLPCompatibilityAuto,
+		consume: endpoint.consume}
+
+	// the endpoint rejects the latest fields, so they are removed and the traces are sent again
+	td := sampledTraces()
+	require.NoError(t, cn.ConsumeTraces(context.Background(), td))
+	assert.Equal(t, 2, endpoint.requests)
+	assert.Equal(t, []uint32{0, 0}, endpoint.received)
+	assert.True(t, cn.isLegacy())
+	// the original traces are not modified
+	assert.Equal(t, uint32(1), td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Flags())
+
+	// the following traces are directly sent without the latest fields
+	endpoint.requests, endpoint.received = 0, nil
+	require.NoError(t, cn.ConsumeTraces(context.Background(), sampledTraces()))
+	assert.Equal(t, 1, endpoint.requests)
+	assert.Equal(t, []uint32{0, 0}, endpoint.received)
+}
+
+func TestCompatNegotiator_Latest(t *testing.T) {
+	endpoint := &legacyEndpoint{}
+	cn := &compatNegotiator{endpoint: "collector", mode: OTLPCompatibilityLatest,
+		consume: endpoint.consume}
+
+	err := cn.ConsumeTraces(context.Background(), sampledTraces())
+	require.Error(t, err)
+	assert.True(t, isInvalidRequest(err))
+	assert.Equal(t, 1, endpoint.requests)
+	assert.False(t, cn.isLegacy())
+	assert.True(t, cn.diagnosed)
+}
+
+func TestCompatNegotiator_Legacy(t *testing.T) {
+	endpoint := &legacyEndpoint{}
+	cn := &compatNegotiator{endpoint: "collector", mode: OTLPCompatibilityLegacy,
+		consume: endpoint.consume}
+
+	require.NoError(t, cn.ConsumeTraces(context.Background(), sampledTraces()))
+	assert.Equal(t, 1, endpoint.requests)
+	assert.Equal(t, []uint32{0, 0}, endpoint.received)
+}
+
+func TestCompatNegotiator_OtherErrors(t *testing.T) {
+	for _, err := range []error{
+		status.New(codes.Unavailable, "the collector is down").Err(),
+		status.New(codes.Unknown, "error exporting items, request to http://collector:4318/v1/traces responded with HTTP Status Code 500").Err(),
+	} {
+		t.Run(err.Error(), func(t *testing.T) {
+			requests := 0
+			cn := &compatNegotiator{endpoint: "collector", mode: OTLPCompatibilityAuto,
+				consume: func(_ context.Context, _ ptrace.Traces) error {
+					requests++
+					return err
+				}}
+
+			assert.Equal(t, err, cn.ConsumeTraces(context.Background(), sampledTraces()))
+			assert.Equal(t, 1, requests)
+			assert.False(t, cn.isLegacy())
+			assert.False(t, cn.diagnosed)
+		})
+	}
+}
+
+func TestCompatNegotiator_NothingToRemove(t *testing.T) {
+	invalid := status.New(codes.InvalidArgument, "invalid service name").Err()
+	requests := 0
+	cn := &compatNegotiator{endpoint: "collector", mode: OTLPCompatibilityAuto,
+		consume: func(_ context.Context, _ ptrace.Traces) error {
+			requests++
+			return invalid
+		}}
+
+	// the traces don't have any field from the latest OTLP versions, so they aren't sent again
+	assert.Equal(t, invalid, cn.ConsumeTraces(context.Background(), testTraces(2)))
+	assert.Equal(t, 1, requests)
+	assert.False(t, cn.isLegacy())
+	assert.True(t, cn.diagnosed)
+}
+
+func TestCompatNegotiator_UnrelatedRejection(t *testing.T) {
+	// e.g. an authenticating proxy or a body size limit
+	invalid := status.New(codes.InvalidArgument,
+		"error exporting items, request to http://collector:4318/v1/traces responded with HTTP Status Code 400").Err()
+	requests := 0
+	cn := &compatNegotiator{endpoint: "collector", mode: OTLPCompatibilityAuto,
+		consume: func(_ context.Context, _ ptrace.Traces) error {
+			requests++
+			return invalid
+		}}
+
+	// the error doesn't name the latest OTLP fields, so the traces aren't sent again without them
+	assert.Equal(t, invalid, cn.ConsumeTraces(context.Background(), sampledTraces()))
+	assert.Equal(t, 1, requests)
+	assert.False(t, cn.isLegacy())
+	assert.True(t, cn.diagnosed)
+}
+
+func TestLegacyTraces(t *testing.T) {
+	td := testTraces(1)
+	ss := td.ResourceSpans().At(0).ScopeSpans().At(0)
+	ss.Scope().SetName("beyla")
+	ss.Scope().Attributes().PutStr("foo", "bar")
+	ss.Scope().SetDroppedAttributesCount(3)
+	span := ss.Spans().At(0)
+	span.SetFlags(0x101)
+	link := span.Links().AppendEmpty()
+	link.SetFlags(1)
+
+	legacy, stripped := legacyTraces(td)
+	assert.Equal(t, []string{"attributes", "flags"}, stripped)
+	lss := legacy.ResourceSpans().At(0).ScopeSpans().At(0)
+	assert.Equal(t, "beyla", lss.Scope().Name())
+	assert.Zero(t, lss.Scope().Attributes().Len())
+	assert.Zero(t, lss.Scope().DroppedAttributesCount())
+	assert.Zero(t, lss.Spans().At(0).Flags())
+	assert.Zero(t, lss.Spans().At(0).Links().At(0).Flags())
+	assert.Equal(t, "0", lss.Spans().At(0).Name())
+
+	_, stripped = legacyTraces(testTraces(2, 1))
+	assert.Empty(t, stripped)
+}
+
+func TestOTLPCompatibilityValidation(t *testing.T) {
+	for _, c := range []OTLPCompatibility{"", OTLPCompatibilityAuto, OTLPCompatibilityLatest, OTLPCompatibilityLegacy} {
+		require.NoError(t, c.validate())
+	}
+	require.Error(t, OTLPCompatibility("1.0.0").validate())
+}
+
+func TestUserAgent(t *testing.T) {
+	assert.True(t, strings.HasPrefix(userAgent(), "Beyla/"))
+	set := getTraceSettings(&global.ContextInfo{}, nil)
+	assert.Equal(t, "Beyla", set.BuildInfo.Description)
+
+	userAgents := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		userAgents <- req.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	opts, err := getHTTPMetricEndpointOptions(&MetricsConfig{MetricsEndpoint: server.URL})
+	require.NoError(t, err)
+	exporter, err := otlpmetrichttp.New(context.Background(), opts.AsMetricHTTP()...)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Export(context.Background(), &metricdata.ResourceMetrics{}))
+	require.NoError(t, exporter.Shutdown(context.Background()))
+	assert.Equal(t, userAgent(), <-userAgents)
+}
@@ -0,0 +1,212 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/extension/auth"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+)
+
+// OTLP signals, as reported by the imetrics.Reporter OTELExportRejected method
+const (
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+)
+
+// partially successful exports are accepted by the OTLP endpoints, and the specification forbids
+// retrying them. Since the response doesn't tell which items were rejected, neither the exporters
+// nor Beyla can retry them, so they are just reported.
+// The exporters only log the partial successes, so they are read here from the export responses.
+
+// maxPartialSuccessBodySize bounds the OTLP/HTTP response bodies that are read to look for a
+// partial success. It is the same limit of the OTLP/HTTP exporter from the OpenTelemetry Collector.
+const maxPartialSuccessBodySize = 64 * 1024
+
+// partialSuccessExtensionID identifies the client extension that wraps the HTTP transport of the
+// OTLP/HTTP traces exporter. The exporter doesn't allow any other way to access its client.
+var partialSuccessExtensionID = component.MustNewID("beyla_partial_success")
+
+func reportTracesPartialSuccess(endpoint string, internal imetrics.Reporter, ps ptraceotlp.ExportPartialSuccess) {
+	if ps.RejectedSpans() == 0 && ps.ErrorMessage() == "" {
+		return
+	}
+	tlog().Warn("OTLP endpoint partially rejected the exported traces",
+		"endpoint", endpoint, "rejectedSpans", ps.RejectedSpans(), "message", ps.ErrorMessage())
+	internal.OTELExportRejected(signalTraces, endpoint, int(ps.RejectedSpans()))
+}
+
+// partialSuccessHost provides the OTLP/HTTP traces exporter with the client extension whose
+// transport reads the partial successes from the responses
+type partialSuccessHost struct {
+	extensions map[component.ID]component.Component
+}
+
+func newPartialSuccessHost(endpoint string, internal imetrics.Reporter) *partialSuccessHost {
+	return &partialSuccessHost{extensions: map[component.ID]component.Component{
+		partialSuccessExtensionID: auth.NewClient(auth.WithClientRoundTripper(
+			func(base http.RoundTripper) (http.RoundTripper, error) {
+				return &partialSuccessRoundTripper{base: base, endpoint: endpoint, internal: internal}, nil
+			})),
+	}}
+}
+
+func (h *partialSuccessHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+// partialSuccessAuth makes the OTLP/HTTP traces exporter wrap its transport with the
+// extension of the partialSuccessHost
+func partialSuccessAuth() *configauth.Authentication {
+	return &configauth.Authentication{AuthenticatorID: partialSuccessExtensionID}
+}
+
+// partialSuccessRoundTripper decodes the successful OTLP/HTTP traces export responses and reports
+// their partial successes. The read body is passed to the exporter unmodified.
+type partialSuccessRoundTripper struct {
+	base     http.RoundTripper
+	endpoint string
+	internal imetrics.Reporter
+}
+
+func (rt *partialSuccessRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 || resp.ContentLength == 0 {
+		return resp, err
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPartialSuccessBodySize))
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		// the exporter will find the same truncated body
+		return resp, nil
+	}
+	exportResp := ptraceotlp.NewExportResponse()
+	switch contentType := resp.Header.Get("Content-Type"); {
+	case strings.HasPrefix(contentType, "application/x-protobuf"):
+		err = exportResp.UnmarshalProto(body)
+	case strings.HasPrefix(contentType, "application/json"):
+		err = exportResp.UnmarshalJSON(body)
+	default:
+		return resp, nil
+	}
+	if err != nil {
+		tlog().Debug("can't decode OTLP traces export response", "endpoint", rt.endpoint, "error", err)
+		return resp, nil
+	}
+	reportTracesPartialSuccess(rt.endpoint, rt.internal, exportResp.PartialSuccess())
+	return resp, nil
+}
+
+// grpcTracesExporter submits the traces through OTLP/GRPC. It replaces the OTLP exporter from the
+// OpenTelemetry Collector, which doesn't give access to the export responses.
+type grpcTracesExporter struct {
+	cfg      *configgrpc.ClientConfig
+	settings component.TelemetrySettings
+	internal imetrics.Reporter
+	conn     *grpc.ClientConn
+	client   ptraceotlp.GRPCClient
+}
+
+func (e *grpcTracesExporter) start(ctx context.Context, host component.Host) error {
+	conn, err := e.cfg.ToClientConn(ctx, host, e.settings,
+		configgrpc.WithGrpcDialOption(grpc.WithUserAgent(userAgent())))
+	if err != nil {
+		return err
+	}
+	e.conn = conn
+	e.client = ptraceotlp.NewGRPCClient(conn)
+	return nil
+}
+
+func (e *grpcTracesExporter) shutdown(_ context.Context) error {
+	if e.conn != nil {
+		return e.conn.Close()
+	}
+	return nil
+}
+
+func (e *grpcTracesExporter) pushTraces(ctx context.Context, td ptrace.Traces) error {
+	resp, err := e.client.Export(ctx, ptraceotlp.NewExportRequestFromTraces(td),
+		grpc.WaitForReady(e.cfg.WaitForReady))
+	if err := grpcExportError(err); err != nil {
+		return err
+	}
+	reportTracesPartialSuccess(e.cfg.Endpoint, e.internal, resp.PartialSuccess())
+	return nil
+}
+
+// grpcExportError tells the exporter helper whether a failed export can be retried, and when.
+// It follows the retry rules of the OTLP specification, as the OpenTelemetry Collector exporter does.
+func grpcExportError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if ri, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = ri
+		}
+	}
+	switch st.Code() {
+	case codes.OK:
+		return nil
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+	case codes.ResourceExhausted:
+		// the endpoint can only recover from resource exhaustion if it tells when to retry
+		if retryInfo == nil {
+			return consumererror.NewPermanent(err)
+		}
+	default:
+		return consumererror.NewPermanent(err)
+	}
+	if retryInfo != nil && retryInfo.RetryDelay != nil {
+		if delay := retryInfo.RetryDelay.AsDuration(); delay > 0 {
+			return exporterhelper.NewThrottleRetry(err, delay)
+		}
+	}
+	return err
+}
+
+// metricsPartialSuccessInterceptor reports the partial successes from the responses of the
+// OTLP/GRPC metrics exporter. The OTLP/HTTP metrics exporter from the OTEL SDK doesn't give
+// access to its responses nor its HTTP client, so its partial successes are not accounted.
+func metricsPartialSuccessInterceptor(endpoint string, internal imetrics.Reporter) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+		resp, ok := reply.(*colmetricpb.ExportMetricsServiceResponse)
+		if !ok || resp.PartialSuccess == nil {
+			return nil
+		}
+		ps := resp.PartialSuccess
+		if ps.RejectedDataPoints == 0 && ps.ErrorMessage == "" {
+			return nil
+		}
+		mlog().Warn("OTLP endpoint partially rejected the exported metrics",
+			"endpoint", endpoint, "rejectedDataPoints", ps.RejectedDataPoints, "message", ps.ErrorMessage)
+		internal.OTELExportRejected(signalMetrics, endpoint, int(ps.RejectedDataPoints))
+		return nil
+	}
+}
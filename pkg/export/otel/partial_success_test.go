@@ -0,0 +1,149 @@
+package otel
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+)
+
+type rejectedItemsReporter struct {
+	imetrics.NoopReporter
+	mt       sync.Mutex
+	rejected map[string]int
+}
+
+func (r *rejectedItemsReporter) OTELExportRejected(signal, endpoint string, rejected int) {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	r.rejected[signal+" "+endpoint] += rejected
+}
+
+func (r *rejectedItemsReporter) get(key string) int {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	return r.rejected[key]
+}
+
+func partialSuccessResponse(rejected int64) ptraceotlp.ExportResponse {
+	resp := ptraceotlp.NewExportResponse()
+	resp.PartialSuccess().SetRejectedSpans(rejected)
+	resp.PartialSuccess().SetErrorMessage("spans too old")
+	return resp
+}
+
+func TestTracesPartialSuccess_HTTP(t *testing.T) {
+	// the exporter setup overrides the protocol environment variables
+	t.Setenv(envProtocol, "")
+	t.Setenv(envTracesProtocol, "")
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		body, err := partialSuccessResponse(3).MarshalProto()
+		require.NoError(t, err)
+		rw.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = rw.Write(body)
+	}))
+	defer server.Close()
+
+	reporter := &rejectedItemsReporter{rejected: map[string]int{}}
+	exp, err := getTracesExporter(context.Background(), TracesConfig{
+		TracesEndpoint:    server.URL,
+		TracesProtocol:    ProtocolHTTPProtobuf,
+		ReportersCacheLen: 16,
+	}, &global.ContextInfo{Metrics: reporter})
+	require.NoError(t, err)
+	require.NoError(t, exp.Start(context.Background(), nil))
+	require.NoError(t, exp.ConsumeTraces(context.Background(), testTraces(2, 1)))
+	require.NoError(t, exp.Shutdown(context.Background()))
+
+	assert.Equal(t, 3, reporter.get("traces "+server.URL))
+}
+
+type partialSuccessTracesServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+	response ptraceotlp.ExportResponse
+}
+
+func (s *partialSuccessTracesServer) Export(_ context.Context, _ ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	return s.response, nil
+}
+
+func TestTracesPartialSuccess_GRPC(t *testing.T) {
+	// the exporter setup overrides the protocol environment variables
+	t.Setenv(envProtocol, "")
+	t.Setenv(envTracesProtocol, "")
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	ptraceotlp.RegisterGRPCServer(server, &partialSuccessTracesServer{response: partialSuccessResponse(2)})
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	reporter := &rejectedItemsReporter{rejected: map[string]int{}}
+	exp, err := getTracesExporter(context.Background(), TracesConfig{
+		TracesEndpoint:    "http://" + lis.Addr().String(),
+		TracesProtocol:    ProtocolGRPC,
+		ReportersCacheLen: 16,
+	}, &global.ContextInfo{Metrics: reporter})
+	require.NoError(t, err)
+	require.NoError(t, exp.Start(context.Background(), nil))
+	require.NoError(t, exp.ConsumeTraces(context.Background(), testTraces(2, 1)))
+	require.NoError(t, exp.Shutdown(context.Background()))
+
+	assert.Equal(t, 2, reporter.get("traces http://"+lis.Addr().String()))
+}
+
+func TestGRPCExportError(t *testing.T) {
+	require.NoError(t, grpcExportError(nil))
+	assert.True(t, consumererror.IsPermanent(grpcExportError(status.Error(codes.InvalidArgument, "bad request"))))
+	assert.True(t, consumererror.IsPermanent(grpcExportError(status.Error(codes.ResourceExhausted, "too large"))))
+	assert.False(t, consumererror.IsPermanent(grpcExportError(status.Error(codes.Unavailable, "try later"))))
+}
+
+type partialSuccessMetricsServer struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+}
+
+func (s *partialSuccessMetricsServer) Export(_ context.Context, _ *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	return &colmetricpb.ExportMetricsServiceResponse{
+		PartialSuccess: &colmetricpb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: 4,
+			ErrorMessage:       "too many attributes",
+		},
+	}, nil
+}
+
+func TestMetricsPartialSuccess_GRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	colmetricpb.RegisterMetricsServiceServer(server, &partialSuccessMetricsServer{})
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	reporter := &rejectedItemsReporter{rejected: map[string]int{}}
+	exporter, err := grpcMetricsExporter(context.Background(),
+		&MetricsConfig{MetricsEndpoint: "http://" + lis.Addr().String(), Protocol: ProtocolGRPC}, reporter)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, exporter.Export(ctx, &metricdata.ResourceMetrics{}))
+	require.NoError(t, exporter.Shutdown(ctx))
+
+	assert.Equal(t, 4, reporter.get("metrics "+lis.Addr().String()))
+}
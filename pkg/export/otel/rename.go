@@ -0,0 +1,97 @@
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/grafana/beyla/pkg/export/attributes"
+)
+
+// renameMetricsExporter wraps the passed metrics exporter inside an exporter that renames the
+// metric names and attribute keys according to the user configuration. If there is nothing to
+// rename, the passed exporter is returned.
+func renameMetricsExporter(renamer *attributes.Renamer, in metric.Exporter) metric.Exporter {
+	if renamer == nil {
+		return in
+	}
+	return &renamingMetricsExporter{Exporter: in, renamer: renamer}
+}
+
+type renamingMetricsExporter struct {
+	metric.Exporter
+	renamer *attributes.Renamer
+}
+
+// Export renames the metrics in place. The SDK overwrites the names and the data points of the
+// passed ResourceMetrics on each collection, so the renaming is not accumulated.
+func (re *renamingMetricsExporter) Export(ctx context.Context, md *metricdata.ResourceMetrics) error {
+	for s := range md.ScopeMetrics {
+		metrics := md.ScopeMetrics[s].Metrics
+		for m := range metrics {
+			metrics[m].Name = re.renamer.MetricOTEL(metrics[m].Name)
+			re.renameData(metrics[m].Data)
+		}
+	}
+	return re.Exporter.Export(ctx, md)
+}
+
+func (re *renamingMetricsExporter) renameData(data metricdata.Aggregation) {
+	switch d := data.(type) {
+	case metricdata.Gauge[int64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = renameAttributes(re.renamer, &d.DataPoints[i].Attributes)
+		}
+	case metricdata.Gauge[float64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = renameAttributes(re.renamer, &d.DataPoints[i].Attributes)
+		}
+	case metricdata.Sum[int64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = renameAttributes(re.renamer, &d.DataPoints[i].Attributes)
+		}
+	case metricdata.Sum[float64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = renameAttributes(re.renamer, &d.DataPoints[i].Attributes)
+		}
+	case metricdata.Histogram[int64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = renameAttributes(re.renamer, &d.DataPoints[i].Attributes)
+		}
+	case metricdata.Histogram[float64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = renameAttributes(re.renamer, &d.DataPoints[i].Attributes)
+		}
+	case metricdata.ExponentialHistogram[int64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = renameAttributes(re.renamer, &d.DataPoints[i].Attributes)
+		}
+	case metricdata.ExponentialHistogram[float64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = renameAttributes(re.renamer, &d.DataPoints[i].Attributes)
+		}
+	}
+}
+
+// renameAttributes returns the same set if none of its keys has been renamed
+func renameAttributes(renamer *attributes.Renamer, set *attribute.Set) attribute.Set {
+	var renamed []attribute.KeyValue
+	for i, iter := 0, set.Iter(); iter.Next(); i++ {
+		kv := iter.Attribute()
+		newKey := renamer.AttributeOTEL(string(kv.Key))
+		if renamed == nil {
+			if newKey == string(kv.Key) {
+				continue
+			}
+			renamed = make([]attribute.KeyValue, 0, set.Len())
+			renamed = append(renamed, set.ToSlice()[:i]...)
+		}
+		renamed = append(renamed, attribute.KeyValue{Key: attribute.Key(newKey), Value: kv.Value})
+	}
+	if renamed == nil {
+		return *set
+	}
+	return attribute.NewSet(renamed...)
+}
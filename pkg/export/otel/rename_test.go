@@ -0,0 +1,52 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/grafana/beyla/pkg/export/attributes"
+)
+
+func TestRenameMetricsExporter(t *testing.T) {
+	fake := &fakeMetricsExporter{}
+	assert.Same(t, fake, renameMetricsExporter(nil, fake))
+
+	exp := renameMetricsExporter(attributes.NewRenamer(attributes.Renames{
+		Metrics:    map[string]string{"http.server.request.duration": "acme.http.latency"},
+		Attributes: map[string]string{"k8s.namespace.name": "kube_namespace"},
+	}), fake)
+
+	attrs := attribute.NewSet(
+		attribute.String("http.request.method", "GET"),
+		attribute.String("k8s.namespace.name", "default"),
+	)
+	untouched := attribute.NewSet(attribute.String("http.request.method", "GET"))
+	require.NoError(t, exp.Export(context.Background(), &metricdata.ResourceMetrics{
+		ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{{
+			Name: "http.server.request.duration",
+			Data: metricdata.Histogram[float64]{DataPoints: []metricdata.HistogramDataPoint[float64]{
+				{Attributes: attrs}, {Attributes: untouched},
+			}},
+		}, {
+			Name: "beyla.network.flow.bytes",
+			Data: metricdata.Sum[int64]{DataPoints: []metricdata.DataPoint[int64]{{Attributes: attrs}}},
+		}}}},
+	}))
+
+	require.Len(t, fake.exported, 1)
+	assert.Equal(t, []string{"acme.http.latency", "beyla.network.flow.bytes"}, metricNames(fake.exported[0]))
+	metrics := fake.exported[0].ScopeMetrics[0].Metrics
+	renamed := attribute.NewSet(
+		attribute.String("http.request.method", "GET"),
+		attribute.String("kube_namespace", "default"),
+	)
+	histogram := metrics[0].Data.(metricdata.Histogram[float64])
+	assert.Equal(t, renamed, histogram.DataPoints[0].Attributes)
+	assert.Equal(t, untouched, histogram.DataPoints[1].Attributes)
+	assert.Equal(t, renamed, metrics[1].Data.(metricdata.Sum[int64]).DataPoints[0].Attributes)
+}
@@ -3,6 +3,7 @@ package otel
 import (
 	"log/slog"
 	"strconv"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel/sdk/trace"
 )
@@ -48,3 +49,34 @@ func (s *Sampler) Implementation() trace.Sampler {
 		return defaultSampler()
 	}
 }
+
+// overridableSampler delegates to the configured sampler, unless its sampling ratio is
+// overridden at runtime (e.g. through the admin API)
+type overridableSampler struct {
+	configured trace.Sampler
+	current    atomic.Pointer[trace.Sampler]
+}
+
+func newOverridableSampler(configured trace.Sampler) *overridableSampler {
+	s := &overridableSampler{configured: configured}
+	s.current.Store(&configured)
+	return s
+}
+
+// setRatio replaces the configured sampler by a parent-based trace ID ratio sampler. A nil
+// ratio restores the configured sampler.
+func (s *overridableSampler) setRatio(ratio *float64) {
+	sampler := s.configured
+	if ratio != nil {
+		sampler = trace.ParentBased(trace.TraceIDRatioBased(*ratio))
+	}
+	s.current.Store(&sampler)
+}
+
+func (s *overridableSampler) ShouldSample(parameters trace.SamplingParameters) trace.SamplingResult {
+	return (*s.current.Load()).ShouldSample(parameters)
+}
+
+func (s *overridableSampler) Description() string {
+	return (*s.current.Load()).Description()
+}
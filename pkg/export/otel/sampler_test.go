@@ -50,3 +50,15 @@ func TestSamplerImplementation(t *testing.T) {
 		})
 	}
 }
+
+func TestOverridableSampler(t *testing.T) {
+	sampler := newOverridableSampler(trace.AlwaysSample())
+	assert.Equal(t, trace.AlwaysSample().Description(), sampler.Description())
+
+	ratio := 0.25
+	sampler.setRatio(&ratio)
+	assert.Equal(t, trace.ParentBased(trace.TraceIDRatioBased(0.25)).Description(), sampler.Description())
+
+	sampler.setRatio(nil)
+	assert.Equal(t, trace.AlwaysSample().Description(), sampler.Description())
+}
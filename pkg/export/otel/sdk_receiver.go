@@ -0,0 +1,387 @@
+package otel
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func srlog() *slog.Logger {
+	return slog.With("component", "otel.SDKReceiver")
+}
+
+const sdkReceiverPath = "/v1/traces"
+
+// SDKReceiverConfig configures an OTLP/HTTP endpoint that accepts the spans of the OpenTelemetry SDKs
+// of the instrumented applications. The SDK spans are forwarded to the traces endpoint, and the eBPF
+// spans that describe the same operations are dropped, so the traces of the applications with mixed
+// instrumentation keep the detail of the SDK spans and aren't duplicated.
+type SDKReceiverConfig struct {
+	// Port of the OTLP/HTTP endpoint, which accepts protobuf and JSON requests in the /v1/traces path.
+	// The receiver is disabled if the port is 0.
+	Port int `yaml:"port" env:"BEYLA_OTEL_TRACES_SDK_RECEIVER_PORT"`
+	// Address where the OTLP/HTTP endpoint listens. The receiver is not authenticated, so it only
+	// accepts local connections by default.
+	Address string `yaml:"address" env:"BEYLA_OTEL_TRACES_SDK_RECEIVER_ADDRESS"`
+	// MergeWindow is the time that the eBPF spans are retained before being exported, waiting for the
+	// SDK spans that duplicate them. It must be longer than the export interval of the SDKs.
+	MergeWindow time.Duration `yaml:"merge_window" env:"BEYLA_OTEL_TRACES_SDK_RECEIVER_MERGE_WINDOW"`
+	// Tolerance is the maximum difference in the start and end times of an SDK span and an eBPF span
+	// of the same service and kind to be considered the same operation
+	Tolerance time.Duration `yaml:"tolerance" env:"BEYLA_OTEL_TRACES_SDK_RECEIVER_TOLERANCE"`
+}
+
+func (c *SDKReceiverConfig) Enabled() bool {
+	return c != nil && c.Port != 0
+}
+
+// maxSDKIndexSpans bounds the number of SDK spans that are remembered. When it is reached, the
+// oldest spans are forgotten before their retention time expires.
+const maxSDKIndexSpans = 100_000
+
+type sdkTraceKey struct {
+	service string
+	kind    ptrace.SpanKind
+	traceID pcommon.TraceID
+}
+
+// sdkTimeKey groups the SDK spans by their start time, in buckets as wide as the tolerance, to
+// find the spans that match an eBPF span without trace context
+type sdkTimeKey struct {
+	service string
+	kind    ptrace.SpanKind
+	bucket  int64
+}
+
+type sdkSpan struct {
+	parentID pcommon.SpanID
+	start    time.Time
+	end      time.Time
+}
+
+// sdkIndexEntry records the insertion order of the spans, to forget them in the same order
+type sdkIndexEntry struct {
+	traceKey sdkTraceKey
+	timeKey  sdkTimeKey
+	received time.Time
+}
+
+// sdkSpanIndex remembers the SDK spans that have been received during the retention time, to find
+// the eBPF spans that duplicate them
+type sdkSpanIndex struct {
+	mt          sync.Mutex
+	tolerance   time.Duration
+	bucketWidth int64
+	retention   time.Duration
+	maxSpans    int
+	// spans of each service, kind and trace, ordered by reception time
+	byTrace map[sdkTraceKey][]sdkSpan
+	// spans of each service, kind and start time bucket, ordered by reception time
+	byTime map[sdkTimeKey][]sdkSpan
+	order  []sdkIndexEntry
+}
+
+func newSDKSpanIndex(cfg *SDKReceiverConfig) *sdkSpanIndex {
+	return &sdkSpanIndex{
+		tolerance:   cfg.Tolerance,
+		bucketWidth: int64(max(cfg.Tolerance, time.Millisecond)),
+		// the SDK spans might arrive after the eBPF spans were retained during the merge window, or before
+		// the eBPF spans are read
+		retention: 2 * cfg.MergeWindow,
+		maxSpans:  maxSDKIndexSpans,
+		byTrace:   map[sdkTraceKey][]sdkSpan{},
+		byTime:    map[sdkTimeKey][]sdkSpan{},
+	}
+}
+
+func (si *sdkSpanIndex) add(td ptrace.Traces, now time.Time) {
+	si.mt.Lock()
+	defer si.mt.Unlock()
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		service := ""
+		if name, ok := rs.Resource().Attributes().Get(string(semconv.ServiceNameKey)); ok {
+			service = name.AsString()
+		}
+		sss := rs.ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				if span.Kind() == ptrace.SpanKindInternal || span.Kind() == ptrace.SpanKindUnspecified {
+					continue
+				}
+				if len(si.order) >= si.maxSpans {
+					si.removeOldest()
+				}
+				s := sdkSpan{
+					parentID: span.ParentSpanID(),
+					start:    span.StartTimestamp().AsTime(),
+					end:      span.EndTimestamp().AsTime(),
+				}
+				entry := sdkIndexEntry{
+					traceKey: sdkTraceKey{service: service, kind: span.Kind(), traceID: span.TraceID()},
+					timeKey:  sdkTimeKey{service: service, kind: span.Kind(), bucket: si.bucket(s.start)},
+					received: now,
+				}
+				si.byTrace[entry.traceKey] = append(si.byTrace[entry.traceKey], s)
+				si.byTime[entry.timeKey] = append(si.byTime[entry.timeKey], s)
+				si.order = append(si.order, entry)
+			}
+		}
+	}
+}
+
+func (si *sdkSpanIndex) bucket(t time.Time) int64 {
+	return t.UnixNano() / si.bucketWidth
+}
+
+// covers returns whether an SDK span describes the same operation as the eBPF span. If the eBPF span
+// has trace context, the SDK span must belong to the same trace, and be either a server span with the
+// same parent, or a span of the same kind whose start and end times differ less than the configured
+// tolerance. Otherwise, only the start and end times of the SDK spans of the same kind are compared.
+func (si *sdkSpanIndex) covers(span *request.Span) bool {
+	si.mt.Lock()
+	defer si.mt.Unlock()
+	kind := ptrace.SpanKind(spanKind(span))
+	t := span.Timings()
+	if span.TraceID.IsValid() {
+		key := sdkTraceKey{service: span.ServiceID.Name, kind: kind, traceID: pcommon.TraceID(span.TraceID)}
+		for _, s := range si.byTrace[key] {
+			if kind == ptrace.SpanKindServer && !s.parentID.IsEmpty() && s.parentID == pcommon.SpanID(span.ParentSpanID) {
+				return true
+			}
+			if si.sameTimes(&s, &t) {
+				return true
+			}
+		}
+		return false
+	}
+	// the matching spans might be in the adjacent buckets, as the buckets are as wide as the tolerance
+	bucket := si.bucket(t.RequestStart)
+	for b := bucket - 1; b <= bucket+1; b++ {
+		for _, s := range si.byTime[sdkTimeKey{service: span.ServiceID.Name, kind: kind, bucket: b}] {
+			if si.sameTimes(&s, &t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (si *sdkSpanIndex) sameTimes(s *sdkSpan, t *request.Timings) bool {
+	return absDuration(s.start.Sub(t.RequestStart)) <= si.tolerance && absDuration(s.end.Sub(t.End)) <= si.tolerance
+}
+
+func (si *sdkSpanIndex) removeExpired(now time.Time) {
+	si.mt.Lock()
+	defer si.mt.Unlock()
+	for len(si.order) > 0 && now.Sub(si.order[0].received) > si.retention {
+		si.removeOldest()
+	}
+}
+
+// removeOldest forgets the first received span, which is also the first span of its keys
+func (si *sdkSpanIndex) removeOldest() {
+	entry := si.order[0]
+	si.order = si.order[1:]
+	if spans := si.byTrace[entry.traceKey]; len(spans) > 1 {
+		si.byTrace[entry.traceKey] = spans[1:]
+	} else {
+		delete(si.byTrace, entry.traceKey)
+	}
+	if spans := si.byTime[entry.timeKey]; len(spans) > 1 {
+		si.byTime[entry.timeKey] = spans[1:]
+	} else {
+		delete(si.byTime, entry.timeKey)
+	}
+}
+
+// sdkReceiver is the OTLP/HTTP traces endpoint for the SDKs of the instrumented applications
+type sdkReceiver struct {
+	index   *sdkSpanIndex
+	consume func(context.Context, ptrace.Traces) error
+}
+
+func (sr *sdkReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := readSDKRequest(rw, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.Is(err, errSDKRequestTooLarge) || errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(rw, err.Error(), status)
+		return
+	}
+
+	isJSON := strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
+	exportReq := ptraceotlp.NewExportRequest()
+	if isJSON {
+		err = exportReq.UnmarshalJSON(data)
+	} else {
+		err = exportReq.UnmarshalProto(data)
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	traces := exportReq.Traces()
+	sr.index.add(traces, time.Now())
+	if err := sr.consume(req.Context(), traces); err != nil {
+		srlog().Debug("error forwarding SDK traces", "error", err)
+		http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var resp []byte
+	if isJSON {
+		rw.Header().Set("Content-Type", "application/json")
+		resp, err = ptraceotlp.NewExportResponse().MarshalJSON()
+	} else {
+		rw.Header().Set("Content-Type", "application/x-protobuf")
+		resp, err = ptraceotlp.NewExportResponse().MarshalProto()
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(resp)
+}
+
+// maxSDKRequestSize bounds the size of the SDK requests, before and after their decompression,
+// so a single request can't exhaust the memory of Beyla. It is the same default limit as the
+// OTLP/HTTP receiver of the OpenTelemetry Collector.
+const maxSDKRequestSize = 20 * 1024 * 1024
+
+var errSDKRequestTooLarge = errors.New("decompressed request body too large")
+
+// readSDKRequest reads the body of an SDK request, decompressing it if needed
+func readSDKRequest(rw http.ResponseWriter, req *http.Request) ([]byte, error) {
+	body := io.Reader(http.MaxBytesReader(rw, req.Body, maxSDKRequestSize))
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		// reading one byte more than the limit tells whether the limit was exceeded
+		body = io.LimitReader(gz, maxSDKRequestSize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSDKRequestSize {
+		return nil, errSDKRequestTooLarge
+	}
+	return data, nil
+}
+
+// startSDKReceiver listens for the SDK spans in the configured port, and returns the function
+// that stops listening
+func startSDKReceiver(cfg *SDKReceiverConfig, receiver *sdkReceiver) func() {
+	mux := http.NewServeMux()
+	mux.Handle(sdkReceiverPath, receiver)
+	addr := net.JoinHostPort(cfg.Address, strconv.Itoa(cfg.Port))
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		srlog().Info("listening for SDK traces", "address", addr, "path", sdkReceiverPath)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			srlog().Error("SDK traces receiver stopped", "error", err)
+		}
+	}()
+	return func() {
+		if err := server.Close(); err != nil {
+			srlog().Debug("error closing SDK traces receiver", "error", err)
+		}
+	}
+}
+
+type pendingSpans struct {
+	exportTime time.Time
+	spans      []request.Span
+}
+
+// mergeSDKSpans retains the eBPF spans during the merge window and forwards them without the spans
+// that are covered by SDK spans. The retained spans are flushed when the input channel is closed.
+func mergeSDKSpans(
+	in <-chan []request.Span, cfg *SDKReceiverConfig, index *sdkSpanIndex, metrics imetrics.Reporter, export func([]request.Span),
+) {
+	flush := func(p *pendingSpans) {
+		fwd := make([]request.Span, 0, len(p.spans))
+		covered := spanDrops{}
+		for i := range p.spans {
+			if !p.spans[i].InternalSignal() && index.covers(&p.spans[i]) {
+				covered.add(&p.spans[i])
+				continue
+			}
+			fwd = append(fwd, p.spans[i])
+		}
+		covered.report(metrics, imetrics.DropReasonDuplicate)
+		if len(fwd) > 0 {
+			export(fwd)
+		}
+	}
+
+	ticker := time.NewTicker(mergeTickInterval(cfg.MergeWindow))
+	defer ticker.Stop()
+	var pending []pendingSpans
+	for {
+		select {
+		case spans, ok := <-in:
+			if !ok {
+				for i := range pending {
+					flush(&pending[i])
+				}
+				return
+			}
+			pending = append(pending, pendingSpans{exportTime: time.Now().Add(cfg.MergeWindow), spans: spans})
+		case now := <-ticker.C:
+			due := 0
+			for due < len(pending) && !now.Before(pending[due].exportTime) {
+				flush(&pending[due])
+				due++
+			}
+			pending = pending[due:]
+			index.removeExpired(now)
+		}
+	}
+}
+
+func mergeTickInterval(window time.Duration) time.Duration {
+	tick := window / 10
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	return min(tick, time.Second)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,257 @@
+package otel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+type testSDKSpan struct {
+	kind     ptrace.SpanKind
+	traceID  pcommon.TraceID
+	parentID pcommon.SpanID
+	start    time.Time
+	end      time.Time
+}
+
+func sdkTraces(service string, spans ...testSDKSpan) ptrace.Traces {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", service)
+	ss := rs.ScopeSpans().AppendEmpty()
+	for _, s := range spans {
+		span := ss.Spans().AppendEmpty()
+		span.SetKind(s.kind)
+		span.SetTraceID(s.traceID)
+		span.SetSpanID(pcommon.SpanID{9, 9, 9, 9, 9, 9, 9, 9})
+		span.SetParentSpanID(s.parentID)
+		span.SetStartTimestamp(pcommon.NewTimestampFromTime(s.start))
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(s.end))
+	}
+	return td
+}
+
+func TestSDKSpanIndex(t *testing.T) {
+	index := newSDKSpanIndex(&SDKReceiverConfig{MergeWindow: time.Second, Tolerance: 5 * time.Millisecond})
+
+	server := request.Span{
+		Type: request.EventTypeHTTP, ServiceID: svc.ID{Name: "orders"},
+		TraceID:      trace2.TraceID{1, 2, 3},
+		ParentSpanID: trace2.SpanID{4, 5, 6},
+		RequestStart: int64(time.Second), Start: int64(time.Second), End: int64(2 * time.Second),
+	}
+	client := request.Span{
+		Type: request.EventTypeHTTPClient, ServiceID: svc.ID{Name: "orders"},
+		RequestStart: int64(3 * time.Second), Start: int64(3 * time.Second), End: int64(4 * time.Second),
+	}
+	st := server.Timings()
+	ct := client.Timings()
+
+	now := time.Now()
+	index.add(sdkTraces("orders",
+		// the SDK server span started later than the request was read, but it has the same parent
+		testSDKSpan{kind: ptrace.SpanKindServer, traceID: pcommon.TraceID(server.TraceID),
+			parentID: pcommon.SpanID(server.ParentSpanID), start: st.RequestStart.Add(time.Second), end: st.End},
+		testSDKSpan{kind: ptrace.SpanKindClient, traceID: pcommon.TraceID{7},
+			start: ct.RequestStart.Add(-2 * time.Millisecond), end: ct.End.Add(time.Millisecond)},
+		testSDKSpan{kind: ptrace.SpanKindInternal, start: st.RequestStart, end: st.End},
+	), now)
+
+	assert.True(t, index.covers(&server))
+	assert.True(t, index.covers(&client))
+
+	otherParent := server
+	otherParent.ParentSpanID = trace2.SpanID{1}
+	assert.False(t, index.covers(&otherParent))
+
+	otherService := client
+	otherService.ServiceID.Name = "payments"
+	assert.False(t, index.covers(&otherService))
+
+	otherKind := client
+	otherKind.Type = request.EventTypeGRPC
+	assert.False(t, index.covers(&otherKind))
+
+	later := client
+	later.End += int64(10 * time.Millisecond)
+	assert.False(t, index.covers(&later))
+
+	// the eBPF spans with trace context are only compared with the SDK spans of the same trace
+	otherTrace := client
+	otherTrace.TraceID = trace2.TraceID{8}
+	assert.False(t, index.covers(&otherTrace))
+	sameTrace := client
+	sameTrace.TraceID = trace2.TraceID{7}
+	assert.True(t, index.covers(&sameTrace))
+
+	index.removeExpired(now.Add(time.Second))
+	assert.True(t, index.covers(&client))
+	index.removeExpired(now.Add(3 * time.Second))
+	assert.False(t, index.covers(&client))
+	assert.Empty(t, index.order)
+	assert.Empty(t, index.byTrace)
+	assert.Empty(t, index.byTime)
+}
+
+func TestSDKSpanIndex_Limit(t *testing.T) {
+	index := newSDKSpanIndex(&SDKReceiverConfig{MergeWindow: time.Second, Tolerance: 5 * time.Millisecond})
+	index.maxSpans = 2
+	start := time.Now()
+	span := func(traceID byte) request.Span {
+		return request.Span{Type: request.EventTypeHTTPClient, ServiceID: svc.ID{Name: "orders"},
+			TraceID:      trace2.TraceID{traceID},
+			RequestStart: int64(time.Second), Start: int64(time.Second), End: int64(2 * time.Second)}
+	}
+	first, second, third := span(1), span(2), span(3)
+	for _, s := range []*request.Span{&first, &second, &third} {
+		st := s.Timings()
+		index.add(sdkTraces("orders", testSDKSpan{kind: ptrace.SpanKindClient,
+			traceID: pcommon.TraceID(s.TraceID), start: st.RequestStart, end: st.End}), start)
+	}
+	assert.Len(t, index.order, 2)
+	assert.False(t, index.covers(&first))
+	assert.True(t, index.covers(&second))
+	assert.True(t, index.covers(&third))
+}
+
+func TestSDKReceiver(t *testing.T) {
+	index := newSDKSpanIndex(&SDKReceiverConfig{MergeWindow: time.Second})
+	var received []ptrace.Traces
+	receiver := &sdkReceiver{index: index, consume: func(_ context.Context, td ptrace.Traces) error {
+		received = append(received, td)
+		return nil
+	}}
+
+	td := sdkTraces("orders", testSDKSpan{kind: ptrace.SpanKindServer, traceID: pcommon.TraceID{1}})
+	exportReq := ptraceotlp.NewExportRequestFromTraces(td)
+	protoBody, err := exportReq.MarshalProto()
+	require.NoError(t, err)
+	jsonBody, err := exportReq.MarshalJSON()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{contentType: "application/x-protobuf", body: protoBody},
+		{contentType: "application/json", body: jsonBody},
+	} {
+		t.Run(tc.contentType, func(t *testing.T) {
+			received = nil
+			req := httptest.NewRequest(http.MethodPost, sdkReceiverPath, bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rw := httptest.NewRecorder()
+			receiver.ServeHTTP(rw, req)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, tc.contentType, rw.Header().Get("Content-Type"))
+			require.Len(t, received, 1)
+			assert.Equal(t, 1, received[0].SpanCount())
+		})
+	}
+	assert.Len(t, index.byTrace[sdkTraceKey{service: "orders", kind: ptrace.SpanKindServer, traceID: pcommon.TraceID{1}}], 2)
+
+	rw := httptest.NewRecorder()
+	receiver.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, sdkReceiverPath, bytes.NewReader([]byte("{"))))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	receiver.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, sdkReceiverPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+}
+
+func TestSDKReceiver_TooLarge(t *testing.T) {
+	consumed := 0
+	receiver := &sdkReceiver{index: newSDKSpanIndex(&SDKReceiverConfig{MergeWindow: time.Second}),
+		consume: func(_ context.Context, _ ptrace.Traces) error {
+			consumed++
+			return nil
+		}}
+	post := func(body []byte, gzipped bool) int {
+		req := httptest.NewRequest(http.MethodPost, sdkReceiverPath, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		if gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		rw := httptest.NewRecorder()
+		receiver.ServeHTTP(rw, req)
+		return rw.Code
+	}
+	compress := func(data []byte) []byte {
+		buf := bytes.Buffer{}
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		return buf.Bytes()
+	}
+
+	protoBody, err := ptraceotlp.NewExportRequestFromTraces(
+		sdkTraces("orders", testSDKSpan{kind: ptrace.SpanKindServer, traceID: pcommon.TraceID{1}})).MarshalProto()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, post(compress(protoBody), true))
+	assert.Equal(t, 1, consumed)
+
+	tooLarge := make([]byte, maxSDKRequestSize+1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(tooLarge, false))
+	// a small compressed body that exceeds the limit after its decompression
+	bomb := compress(tooLarge)
+	require.Less(t, len(bomb), maxSDKRequestSize/100)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(bomb, true))
+	assert.Equal(t, 1, consumed)
+}
+
+func TestMergeSDKSpans(t *testing.T) {
+	cfg := &SDKReceiverConfig{MergeWindow: 50 * time.Millisecond, Tolerance: time.Millisecond}
+	index := newSDKSpanIndex(cfg)
+	in := make(chan []request.Span, 10)
+	exported := make(chan []request.Span, 10)
+	go mergeSDKSpans(in, cfg, index, imetrics.NoopReporter{}, func(spans []request.Span) { exported <- spans })
+
+	duplicate := request.Span{Type: request.EventTypeHTTP, ServiceID: svc.ID{Name: "orders"}, Route: "/dup",
+		RequestStart: int64(time.Second), Start: int64(time.Second), End: int64(2 * time.Second)}
+	unique := request.Span{Type: request.EventTypeSQLClient, ServiceID: svc.ID{Name: "orders"}, Route: "/unique",
+		RequestStart: int64(time.Second), Start: int64(time.Second), End: int64(2 * time.Second)}
+	in <- []request.Span{duplicate, unique}
+
+	// the SDK span is received after the eBPF span, during the merge window
+	dt := duplicate.Timings()
+	index.add(sdkTraces("orders", testSDKSpan{kind: ptrace.SpanKindServer, start: dt.RequestStart, end: dt.End}), time.Now())
+
+	select {
+	case spans := <-exported:
+		require.Len(t, spans, 1)
+		assert.Equal(t, "/unique", spans[0].Route)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timeout while waiting for the merged spans")
+	}
+
+	close(in)
+
+	// the retained spans are flushed on close
+	cfg = &SDKReceiverConfig{MergeWindow: time.Hour}
+	in = make(chan []request.Span, 10)
+	go mergeSDKSpans(in, cfg, newSDKSpanIndex(cfg), imetrics.NoopReporter{}, func(spans []request.Span) { exported <- spans })
+	in <- []request.Span{unique}
+	close(in)
+	select {
+	case spans := <-exported:
+		require.Len(t, spans, 1)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timeout while waiting for the flushed spans")
+	}
+}
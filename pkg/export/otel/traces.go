@@ -2,17 +2,21 @@ package otel
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"maps"
 	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	expirable2 "github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/mariomac/pipes/pipe"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configcompression"
 	"go.opentelemetry.io/collector/config/configgrpc"
 	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/config/configopaque"
@@ -23,6 +27,7 @@ import (
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/exporterbatcher"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/exporter/exporterqueue"
 	"go.opentelemetry.io/collector/exporter/otlpexporter"
 	"go.opentelemetry.io/collector/exporter/otlphttpexporter"
 	"go.opentelemetry.io/collector/pdata/pcommon"
@@ -64,6 +69,12 @@ type TracesConfig struct {
 	Protocol       Protocol `yaml:"protocol" env:"OTEL_EXPORTER_OTLP_PROTOCOL"`
 	TracesProtocol Protocol `yaml:"-" env:"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"`
 
+	Compression       Compression `yaml:"compression" env:"OTEL_EXPORTER_OTLP_COMPRESSION"`
+	TracesCompression Compression `yaml:"-" env:"OTEL_EXPORTER_OTLP_TRACES_COMPRESSION"`
+
+	// OTLPCompatibility allows sending traces to endpoints that reject the fields from the latest OTLP versions
+	OTLPCompatibility OTLPCompatibility `yaml:"otlp_compatibility" env:"BEYLA_OTEL_TRACES_OTLP_COMPATIBILITY"`
+
 	// Allows configuration of which instrumentations should be enabled, e.g. http, grpc, sql...
 	Instrumentations []string `yaml:"instrumentations" env:"BEYLA_OTEL_TRACES_INSTRUMENTATIONS" envSeparator:","`
 
@@ -72,6 +83,14 @@ type TracesConfig struct {
 
 	Sampler Sampler `yaml:"sampler"`
 
+	// SDKReceiver accepts the spans of the OpenTelemetry SDKs of the instrumented applications, and merges
+	// them with the eBPF spans
+	SDKReceiver SDKReceiverConfig `yaml:"sdk_receiver"`
+
+	// BackendGracePeriod is the time that the traces endpoint can be unreachable since startup before Beyla
+	// discards the traces and keeps running with metrics only, until the endpoint is reachable. Zero disables it.
+	BackendGracePeriod time.Duration `yaml:"backend_grace_period" env:"BEYLA_OTEL_TRACES_BACKEND_GRACE_PERIOD"`
+
 	// Configuration options below this line will remain undocumented at the moment,
 	// but can be useful for performance-tuning of some customers.
 	MaxExportBatchSize int           `yaml:"max_export_batch_size" env:"BEYLA_OTLP_TRACES_MAX_EXPORT_BATCH_SIZE"`
@@ -115,6 +134,13 @@ func (m *TracesConfig) getProtocol() Protocol {
 	return m.guessProtocol()
 }
 
+func (m *TracesConfig) getCompression() Compression {
+	if m.TracesCompression != "" {
+		return m.TracesCompression
+	}
+	return m.Compression
+}
+
 func (m *TracesConfig) guessProtocol() Protocol {
 	// If no explicit protocol is set, we guess it it from the metrics enpdoint port
 	// (assuming it uses a standard port or a development-like form like 14317, 24317, 14318...)
@@ -170,16 +196,21 @@ func GetUserSelectedAttributes(attrs attributes.Selection) (map[attr.Name]struct
 }
 
 func (tr *tracesOTELReceiver) spanDiscarded(span *request.Span) bool {
-	return span.IgnoreTraces() || span.ServiceID.ExportsOTelTraces() || !tr.acceptSpan(span)
+	// when the SDK receiver is enabled, the applications that export their own traces are expected
+	// to export them to Beyla, which drops the eBPF spans that duplicate them
+	exportsTraces := span.ServiceID.ExportsOTelTraces() && !tr.cfg.SDKReceiver.Enabled()
+	return span.IgnoreTraces() || exportsTraces || !tr.acceptSpan(span)
 }
 
 func (tr *tracesOTELReceiver) processSpans(exp exporter.Traces, spans []request.Span, traceAttrs map[attr.Name]struct{}, sampler trace.Sampler) {
+	var ignored, notSampled, queueFull spanDrops
 	for i := range spans {
 		span := &spans[i]
 		if span.InternalSignal() {
 			continue
 		}
 		if tr.spanDiscarded(span) {
+			ignored.add(span)
 			continue
 		}
 
@@ -194,6 +225,7 @@ func (tr *tracesOTELReceiver) processSpans(exp exporter.Traces, spans []request.
 		})
 
 		if sr.Decision == trace.Drop {
+			notSampled.add(span)
 			continue
 		}
 
@@ -202,8 +234,33 @@ func (tr *tracesOTELReceiver) processSpans(exp exporter.Traces, spans []request.
 		err := exp.ConsumeTraces(tr.ctx, traces)
 		if err != nil {
 			slog.Error("error sending trace to consumer", "error", err)
+			if errors.Is(err, exporterqueue.ErrQueueIsFull) {
+				queueFull.add(span)
+			}
 		}
 	}
+	ignored.report(tr.ctxInfo.Metrics, imetrics.DropReasonIgnored)
+	notSampled.report(tr.ctxInfo.Metrics, imetrics.DropReasonNotSampled)
+	queueFull.report(tr.ctxInfo.Metrics, imetrics.DropReasonQueueFull)
+}
+
+// spanDrops accounts the spans of a batch that are discarded for a given reason
+type spanDrops struct {
+	count   int
+	example *request.Span
+}
+
+func (d *spanDrops) add(span *request.Span) {
+	if d.example == nil {
+		d.example = span
+	}
+	d.count++
+}
+
+func (d *spanDrops) report(metrics imetrics.Reporter, reason string) {
+	if d.count > 0 {
+		metrics.SpansDropped(reason, d.count, d.example.Summary())
+	}
 }
 
 func (tr *tracesOTELReceiver) provideLoop() (pipe.FinalFunc[[]request.Span], error) {
@@ -235,16 +292,48 @@ func (tr *tracesOTELReceiver) provideLoop() (pipe.FinalFunc[[]request.Span], err
 			return
 		}
 
-		sampler := tr.cfg.Sampler.Implementation()
+		sampler := newOverridableSampler(tr.cfg.Sampler.Implementation())
+		tr.ctxInfo.Admin.OnSampling(sampler.setRatio)
 
-		for spans := range in {
+		var probe *tracesEndpointProbe
+		if tr.cfg.BackendGracePeriod > 0 {
+			if probe = newTracesEndpointProbe(&tr.cfg, tr.ctxInfo.Metrics); probe != nil {
+				go probe.run(tr.ctx)
+			}
+		}
+		export := func(spans []request.Span) {
+			if probe.discarding() {
+				unreachable := spanDrops{}
+				for i := range spans {
+					if !spans[i].InternalSignal() {
+						unreachable.add(&spans[i])
+					}
+				}
+				unreachable.report(tr.ctxInfo.Metrics, imetrics.DropReasonEndpointUnreachable)
+				return
+			}
 			tr.processSpans(exp, spans, traceAttrs, sampler)
 		}
+
+		if tr.cfg.SDKReceiver.Enabled() {
+			index := newSDKSpanIndex(&tr.cfg.SDKReceiver)
+			stop := startSDKReceiver(&tr.cfg.SDKReceiver, &sdkReceiver{index: index, consume: exp.ConsumeTraces})
+			defer stop()
+			mergeSDKSpans(in, &tr.cfg.SDKReceiver, index, tr.ctxInfo.Metrics, export)
+			return
+		}
+
+		for spans := range in {
+			export(spans)
+		}
 	}, nil
 }
 
 // nolint:cyclop
 func getTracesExporter(ctx context.Context, cfg TracesConfig, ctxInfo *global.ContextInfo) (exporter.Traces, error) {
+	if err := cfg.OTLPCompatibility.validate(); err != nil {
+		return nil, err
+	}
 	switch proto := cfg.getProtocol(); proto {
 	case ProtocolHTTPJSON, ProtocolHTTPProtobuf, "": // zero value defaults to HTTP for backwards-compatibility
 		slog.Debug("instantiating HTTP TracesReporter", "protocol", proto)
@@ -278,19 +367,32 @@ func getTracesExporter(ctx context.Context, cfg TracesConfig, ctxInfo *global.Co
 				Insecure:           opts.Insecure,
 				InsecureSkipVerify: cfg.InsecureSkipVerify,
 			},
-			Headers: convertHeaders(opts.HTTPHeaders),
+			Headers:     convertHeaders(opts.HTTPHeaders),
+			Compression: configcompression.Type(opts.Compression),
+			// the exporter only logs the partial successes, so its transport is wrapped to read them
+			Auth: partialSuccessAuth(),
 		}
 		slog.Debug("getTracesExporter: confighttp.ClientConfig created", "endpoint", config.ClientConfig.Endpoint)
 		set := getTraceSettings(ctxInfo, t)
-		exporter, err := factory.CreateTraces(ctx, set, config)
+		host := newPartialSuccessHost(config.ClientConfig.Endpoint, internalMetricsReporter(ctxInfo))
+		// queue and retries are provided by the wrapping exporter helper. Otherwise, the errors
+		// of the endpoint wouldn't be reported to the batch splitter
+		inner := *config
+		inner.QueueConfig.Enabled = false
+		inner.RetryConfig.Enabled = false
+		exporter, err := factory.CreateTraces(ctx, set, &inner)
 		if err != nil {
 			slog.Error("can't create OTLP HTTP traces exporter", "error", err)
 			return nil, err
 		}
 		// TODO: remove this once the batcher helper is added to otlphttpexporter
 		return exporterhelper.NewTraces(ctx, set, cfg,
-			exporter.ConsumeTraces,
-			exporterhelper.WithStart(exporter.Start),
+			newBatchSplitter(config.ClientConfig.Endpoint,
+				newCompatNegotiator(config.ClientConfig.Endpoint, cfg.OTLPCompatibility, exporter.ConsumeTraces).ConsumeTraces,
+			).ConsumeTraces,
+			exporterhelper.WithStart(func(ctx context.Context, _ component.Host) error {
+				return exporter.Start(ctx, host)
+			}),
 			exporterhelper.WithShutdown(exporter.Shutdown),
 			exporterhelper.WithCapabilities(consumer.Capabilities{MutatesData: false}),
 			exporterhelper.WithQueue(config.QueueConfig),
@@ -332,9 +434,25 @@ func getTracesExporter(ctx context.Context, cfg TracesConfig, ctxInfo *global.Co
 				Insecure:           opts.Insecure,
 				InsecureSkipVerify: cfg.InsecureSkipVerify,
 			},
+			Compression: configcompression.Type(opts.Compression),
 		}
 		set := getTraceSettings(ctxInfo, t)
-		return factory.CreateTraces(ctx, set, config)
+		// queue, batching and retries are provided by the wrapping exporter helper, as for OTLP/HTTP
+		exporter := &grpcTracesExporter{
+			cfg:      &config.ClientConfig,
+			settings: set.TelemetrySettings,
+			internal: internalMetricsReporter(ctxInfo),
+		}
+		return exporterhelper.NewTraces(ctx, set, cfg,
+			newBatchSplitter(config.ClientConfig.Endpoint,
+				newCompatNegotiator(config.ClientConfig.Endpoint, cfg.OTLPCompatibility, exporter.pushTraces).ConsumeTraces,
+			).ConsumeTraces,
+			exporterhelper.WithStart(exporter.start),
+			exporterhelper.WithShutdown(exporter.shutdown),
+			exporterhelper.WithCapabilities(consumer.Capabilities{MutatesData: false}),
+			exporterhelper.WithQueue(config.QueueConfig),
+			exporterhelper.WithBatcher(config.BatcherConfig),
+			exporterhelper.WithRetry(config.RetryConfig))
 	default:
 		slog.Error(fmt.Sprintf("invalid protocol value: %q. Accepted values are: %s, %s, %s",
 			proto, ProtocolGRPC, ProtocolHTTPJSON, ProtocolHTTPProtobuf))
@@ -365,6 +483,13 @@ func instrumentTraceExporter(in trace.SpanExporter, internalMetrics imetrics.Rep
 	}
 }
 
+func internalMetricsReporter(ctxInfo *global.ContextInfo) imetrics.Reporter {
+	if ctxInfo.Metrics == nil {
+		return imetrics.NoopReporter{}
+	}
+	return ctxInfo.Metrics
+}
+
 func getTraceSettings(ctxInfo *global.ContextInfo, in trace.SpanExporter) exporter.Settings {
 	var traceProvider trace2.TracerProvider
 	telemetryLevel := configtelemetry.LevelNone
@@ -392,6 +517,7 @@ func getTraceSettings(ctxInfo *global.ContextInfo, in trace.SpanExporter) export
 	return exporter.Settings{
 		ID:                component.NewIDWithName(dataTypeMetrics, "beyla"),
 		TelemetrySettings: telemetrySettings,
+		BuildInfo:         beylaBuildInfo,
 	}
 }
 
@@ -466,6 +592,19 @@ func GenerateTracesWithAttributes(span *request.Span, hostID string, attrs []att
 	m := attrsToMap(attrs)
 	m.CopyTo(s.Attributes())
 
+	if span.RedisRedirect != nil {
+		addRedisRedirectEvent(&s, span.RedisRedirect, t.End)
+	}
+	if span.HTTP2Reset != nil {
+		addHTTP2ResetEvent(&s, span.HTTP2Reset, t.End)
+	}
+	if span.RequestBody != "" {
+		addRequestBodyEvent(&s, span.RequestBody, t.RequestStart)
+	}
+	if span.KafkaRecord != nil && span.KafkaRecord.ProducerTraceID.IsValid() {
+		addKafkaProducerLink(&s, span.KafkaRecord)
+	}
+
 	// Set status code
 	statusCode := codeToStatusCode(request.SpanStatusCode(span))
 	s.Status().SetCode(statusCode)
@@ -478,6 +617,46 @@ func GenerateTraces(span *request.Span, hostID string, userAttrs map[attr.Name]s
 	return GenerateTracesWithAttributes(span, hostID, traceAttributes(span, userAttrs), envResourceAttrs)
 }
 
+// addRedisRedirectEvent records the MOVED or ASK reply of a Redis Cluster node as a span event
+func addRedisRedirectEvent(s *ptrace.Span, redirect *request.RedisRedirect, ts time.Time) {
+	ev := s.Events().AppendEmpty()
+	ev.SetName("db.redis.redirect")
+	ev.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	attrsToMap([]attribute.KeyValue{
+		request.DBRedisRedirectType(redirect.Type),
+		request.DBRedisRedirectSlot(redirect.Slot),
+		request.DBRedisRedirectAddress(redirect.Address),
+	}).CopyTo(ev.Attributes())
+}
+
+// addHTTP2ResetEvent records the RST_STREAM frame that terminated an HTTP/2 or gRPC stream as a span event
+func addHTTP2ResetEvent(s *ptrace.Span, reset *request.HTTP2StreamReset, ts time.Time) {
+	ev := s.Events().AppendEmpty()
+	ev.SetName("http2.rst_stream")
+	ev.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	attrsToMap([]attribute.KeyValue{
+		request.HTTP2ResetErrorCode(reset.ErrorCode),
+		request.HTTP2ResetSender(reset.Sender),
+	}).CopyTo(ev.Attributes())
+}
+
+// addRequestBodyEvent records the captured part of the HTTP request body as a span event
+func addRequestBodyEvent(s *ptrace.Span, body string, ts time.Time) {
+	ev := s.Events().AppendEmpty()
+	ev.SetName("http.request.body")
+	ev.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	attrsToMap([]attribute.KeyValue{
+		request.HTTPRequestBodyContent(body),
+	}).CopyTo(ev.Attributes())
+}
+
+// addKafkaProducerLink links a Kafka consumer span to the span that produced the consumed record
+func addKafkaProducerLink(s *ptrace.Span, record *request.KafkaRecord) {
+	link := s.Links().AppendEmpty()
+	link.SetTraceID(pcommon.TraceID(record.ProducerTraceID))
+	link.SetSpanID(pcommon.SpanID(record.ProducerSpanID))
+}
+
 // createSubSpans creates the internal spans for a request.Span
 func createSubSpans(span *request.Span, parentSpanID pcommon.SpanID, traceID pcommon.TraceID, ss *ptrace.ScopeSpans, t request.Timings) {
 	// Create a child span showing the queue time
@@ -518,6 +697,12 @@ func attrsToMap(attrs []attribute.KeyValue) pcommon.Map {
 			m.PutDouble(string(attr.Key), v)
 		case bool:
 			m.PutBool(string(attr.Key), v)
+		case []string:
+			s := m.PutEmptySlice(string(attr.Key))
+			s.EnsureCapacity(len(v))
+			for _, str := range v {
+				s.AppendEmpty().SetStr(str)
+			}
 		}
 	}
 	return m
@@ -572,6 +757,18 @@ func (tr *tracesOTELReceiver) acceptSpan(span *request.Span) bool {
 		return tr.is.RedisEnabled()
 	case request.EventTypeKafkaClient, request.EventTypeKafkaServer:
 		return tr.is.KafkaEnabled()
+	case request.EventTypeWebSocketClient, request.EventTypeWebSocketServer:
+		return tr.is.HTTPEnabled()
+	case request.EventTypeFTPClient, request.EventTypeFTPServer:
+		return tr.is.FTPEnabled()
+	case request.EventTypeSSHClient, request.EventTypeSSHServer:
+		return tr.is.SSHEnabled()
+	case request.EventTypeTLSClient, request.EventTypeTLSServer:
+		return tr.is.TLSEnabled()
+	case request.EventTypeAMQPClient, request.EventTypeAMQPServer:
+		return tr.is.AMQPEnabled()
+	case request.EventTypeNATSClient, request.EventTypeNATSServer:
+		return tr.is.NATSEnabled()
 	}
 
 	return false
@@ -592,10 +789,34 @@ func traceAttributes(span *request.Span, optionalAttrs map[attr.Name]struct{}) [
 			request.ServerPort(span.HostPort),
 			request.HTTPRequestBodySize(int(span.RequestLength())),
 		}
+		if span.ResponseLength > 0 {
+			attrs = append(attrs, request.HTTPResponseBodySize(span.ResponseLength))
+		}
+		attrs = append(attrs, httpHeaderAttributes(span.RequestHeaders)...)
 		if span.Route != "" {
 			attrs = append(attrs, semconv.HTTPRoute(span.Route))
 		}
+		if span.SubType == request.HTTPSubtypeSOAP {
+			attrs = append(attrs, request.SOAPAction(span.SOAPAction))
+		}
+		if span.SubType == request.HTTPSubtypeJSONRPC && span.JSONRPC != nil {
+			attrs = append(attrs, jsonRPCAttributes(span.JSONRPC)...)
+		}
+		if span.SubType == request.HTTPSubtypeWebSocket {
+			attrs = append(attrs, request.HTTPUpgrade("websocket"))
+		}
+		if span.EnduserHash != "" {
+			attrs = append(attrs, request.EnduserPseudoID(span.EnduserHash))
+		}
 	case request.EventTypeGRPC:
+		if span.SubType != request.RPCSubtypeNone {
+			attrs = append(rpcAttributes(span),
+				request.ClientAddr(request.PeerAsClient(span)),
+				request.ServerAddr(request.SpanHost(span)),
+				request.ServerPort(span.HostPort),
+			)
+			break
+		}
 		attrs = []attribute.KeyValue{
 			semconv.RPCMethod(span.Path),
 			semconv.RPCSystemGRPC,
@@ -605,6 +826,10 @@ func traceAttributes(span *request.Span, optionalAttrs map[attr.Name]struct{}) [
 			request.ServerPort(span.HostPort),
 		}
 	case request.EventTypeHTTPClient:
+		if span.SubType == request.HTTPSubtypeElasticsearch && span.Elasticsearch != nil {
+			attrs = elasticsearchAttributes(span, optionalAttrs)
+			break
+		}
 		attrs = []attribute.KeyValue{
 			request.HTTPRequestMethod(span.Method),
 			request.HTTPResponseStatusCode(span.Status),
@@ -613,7 +838,27 @@ func traceAttributes(span *request.Span, optionalAttrs map[attr.Name]struct{}) [
 			request.ServerPort(span.HostPort),
 			request.HTTPRequestBodySize(int(span.RequestLength())),
 		}
+		if span.ResponseLength > 0 {
+			attrs = append(attrs, request.HTTPResponseBodySize(span.ResponseLength))
+		}
+		attrs = append(attrs, httpHeaderAttributes(span.RequestHeaders)...)
+		if span.SubType == request.HTTPSubtypeSOAP {
+			attrs = append(attrs, request.SOAPAction(span.SOAPAction))
+		}
+		if span.SubType == request.HTTPSubtypeJSONRPC && span.JSONRPC != nil {
+			attrs = append(attrs, jsonRPCAttributes(span.JSONRPC)...)
+		}
+		if span.SubType == request.HTTPSubtypeWebSocket {
+			attrs = append(attrs, request.HTTPUpgrade("websocket"))
+		}
 	case request.EventTypeGRPCClient:
+		if span.SubType != request.RPCSubtypeNone {
+			attrs = append(rpcAttributes(span),
+				request.ServerAddr(request.HostAsServer(span)),
+				request.ServerPort(span.HostPort),
+			)
+			break
+		}
 		attrs = []attribute.KeyValue{
 			semconv.RPCMethod(span.Path),
 			semconv.RPCSystemGRPC,
@@ -621,11 +866,17 @@ func traceAttributes(span *request.Span, optionalAttrs map[attr.Name]struct{}) [
 			request.ServerAddr(request.HostAsServer(span)),
 			request.ServerPort(span.HostPort),
 		}
+		if span.GRPCTimeout > 0 {
+			attrs = append(attrs, request.RPCGRPCTimeout(span.GRPCTimeout))
+		}
+		if span.GRPCPreviousAttempts > 0 {
+			attrs = append(attrs, request.RPCGRPCPreviousAttempts(span.GRPCPreviousAttempts))
+		}
 	case request.EventTypeSQLClient:
 		attrs = []attribute.KeyValue{
 			request.ServerAddr(request.HostAsServer(span)),
 			request.ServerPort(span.HostPort),
-			semconv.DBSystemOtherSQL, // We can distinguish in the future for MySQL, Postgres etc
+			request.DBSystem(span.DBSystemName()),
 		}
 		if _, ok := optionalAttrs[attr.DBQueryText]; ok {
 			attrs = append(attrs, request.DBQueryText(span.Statement))
@@ -638,6 +889,9 @@ func traceAttributes(span *request.Span, optionalAttrs map[attr.Name]struct{}) [
 				attrs = append(attrs, request.DBCollectionName(table))
 			}
 		}
+		if span.SubType == request.SQLSubtypeClickHouse && span.ClickHouse != nil {
+			attrs = append(attrs, clickHouseAttributes(span.ClickHouse)...)
+		}
 	case request.EventTypeRedisServer, request.EventTypeRedisClient:
 		attrs = []attribute.KeyValue{
 			request.ServerAddr(request.HostAsServer(span)),
@@ -664,18 +918,207 @@ func traceAttributes(span *request.Span, optionalAttrs map[attr.Name]struct{}) [
 			semconv.MessagingClientID(span.Statement),
 			operation,
 		}
+		if span.KafkaRecord != nil {
+			attrs = append(attrs, kafkaRecordAttributes(span.KafkaRecord)...)
+		}
+	case request.EventTypeWebSocketServer, request.EventTypeWebSocketClient:
+		attrs = []attribute.KeyValue{
+			request.ServerAddr(request.HostAsServer(span)),
+			request.ServerPort(span.HostPort),
+			request.WebSocketMessageType(span.Method),
+			request.WebSocketMessageDirection(span.WebSocketDirection()),
+			request.WebSocketMessageSize(int(span.RequestLength())),
+		}
+		if span.Type == request.EventTypeWebSocketServer {
+			attrs = append(attrs, request.ClientAddr(request.PeerAsClient(span)))
+		}
+	case request.EventTypeFTPServer, request.EventTypeFTPClient:
+		attrs = []attribute.KeyValue{
+			request.ServerAddr(request.HostAsServer(span)),
+			request.ServerPort(span.HostPort),
+			request.FTPCommand(span.Method),
+			request.FTPReplyCode(span.Status),
+		}
+		if span.Path != "" {
+			attrs = append(attrs, request.FTPPath(span.Path))
+		}
+		if span.ContentLength > 0 {
+			attrs = append(attrs, request.FTPTransferSize(span.ContentLength))
+		}
+		if span.Type == request.EventTypeFTPServer {
+			attrs = append(attrs, request.ClientAddr(request.PeerAsClient(span)))
+		}
+	case request.EventTypeSSHServer, request.EventTypeSSHClient:
+		attrs = []attribute.KeyValue{
+			request.ServerAddr(request.HostAsServer(span)),
+			request.ServerPort(span.HostPort),
+		}
+		if span.SSH != nil {
+			if span.SSH.ProtocolVersion != "" {
+				attrs = append(attrs, request.SSHProtocolVersion(span.SSH.ProtocolVersion))
+			}
+			if span.SSH.ClientSoftware != "" {
+				attrs = append(attrs, request.SSHClientSoftware(span.SSH.ClientSoftware))
+			}
+			if span.SSH.ServerSoftware != "" {
+				attrs = append(attrs, request.SSHServerSoftware(span.SSH.ServerSoftware))
+			}
+		}
+		if span.Type == request.EventTypeSSHServer {
+			attrs = append(attrs, request.ClientAddr(request.PeerAsClient(span)))
+		}
+	case request.EventTypeTLSServer, request.EventTypeTLSClient:
+		attrs = []attribute.KeyValue{
+			request.ServerAddr(request.HostAsServer(span)),
+			request.ServerPort(span.HostPort),
+		}
+		if span.TLS != nil {
+			if span.TLS.Version != "" {
+				attrs = append(attrs, request.TLSProtocolVersion(span.TLS.Version))
+			}
+			if span.TLS.CipherSuite != "" {
+				attrs = append(attrs, request.TLSCipher(span.TLS.CipherSuite))
+			}
+			if span.TLS.ServerName != "" {
+				attrs = append(attrs, request.TLSServerName(span.TLS.ServerName))
+			}
+		}
+		if span.Type == request.EventTypeTLSServer {
+			attrs = append(attrs, request.ClientAddr(request.PeerAsClient(span)))
+		}
+	case request.EventTypeAMQPServer, request.EventTypeAMQPClient:
+		attrs = []attribute.KeyValue{
+			request.ServerAddr(request.HostAsServer(span)),
+			request.ServerPort(span.HostPort),
+			semconv.MessagingSystemKey.String(span.MessagingSystemName()),
+			request.MessagingOperationType(span.Method),
+		}
+		if span.Path != "" {
+			attrs = append(attrs, semconv.MessagingDestinationName(span.Path))
+		}
+		if span.AMQP != nil {
+			if span.AMQP.LinkName != "" {
+				attrs = append(attrs, request.MessagingAMQPLinkName(span.AMQP.LinkName))
+			}
+			if span.AMQP.Outcome != "" {
+				attrs = append(attrs, request.MessagingAMQPDeliveryOutcome(span.AMQP.Outcome))
+			}
+		}
+	case request.EventTypeNATSServer, request.EventTypeNATSClient:
+		attrs = []attribute.KeyValue{
+			request.ServerAddr(request.HostAsServer(span)),
+			request.ServerPort(span.HostPort),
+			semconv.MessagingSystemKey.String(span.MessagingSystemName()),
+			request.MessagingOperationType(span.Method),
+		}
+		if span.Path != "" {
+			attrs = append(attrs, semconv.MessagingDestinationName(span.Path))
+		}
+		if span.NATS != nil && span.NATS.ReplyTo != "" {
+			attrs = append(attrs, request.MessagingNATSReplyTo(span.NATS.ReplyTo))
+		}
+	}
+
+	if span.DetectionConfidence > 0 {
+		attrs = append(attrs, request.ProtocolDetectionConfidence(span.DetectionConfidence))
+	}
+
+	return attrs
+}
+
+// elasticsearchAttributes follows the database semantic conventions for the Elasticsearch requests,
+// omitting the full URL to avoid high-cardinality values such as document IDs
+func elasticsearchAttributes(span *request.Span, optionalAttrs map[attr.Name]struct{}) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		request.HTTPRequestMethod(span.Method),
+		request.HTTPResponseStatusCode(span.Status),
+		request.ServerAddr(request.HostAsServer(span)),
+		request.ServerPort(span.HostPort),
+		semconv.DBSystemElasticsearch,
+		request.DBOperationName(span.Elasticsearch.DBOperationName),
+	}
+	if span.Elasticsearch.DBCollectionName != "" {
+		attrs = append(attrs, request.DBCollectionName(span.Elasticsearch.DBCollectionName))
+	}
+	if _, ok := optionalAttrs[attr.DBQueryText]; ok && span.Elasticsearch.DBQueryText != "" {
+		attrs = append(attrs, request.DBQueryText(span.Elasticsearch.DBQueryText))
+	}
+	return attrs
+}
+
+// kafkaRecordAttributes returns the partition, offset and lag of the record consumed or produced by a Kafka span
+func kafkaRecordAttributes(record *request.KafkaRecord) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.MessagingDestinationPartitionID(strconv.Itoa(record.Partition))}
+	if record.Offset >= 0 {
+		attrs = append(attrs, semconv.MessagingKafkaMessageOffset(int(record.Offset)))
+	}
+	if record.Lag >= 0 {
+		attrs = append(attrs, request.MessagingKafkaConsumerLag(record.Lag))
+	}
+	return attrs
+}
+
+// rpcAttributes follows the RPC semantic conventions for the non-gRPC requests (Apache Dubbo, RSocket...),
+// where the service, if any, and method are reported separately
+func rpcAttributes(span *request.Span) []attribute.KeyValue {
+	service, method := span.RPCServiceAndMethod()
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String(span.RPCSystemName()),
+		semconv.RPCMethod(method),
+	}
+	if service != "" {
+		attrs = append(attrs, semconv.RPCService(service))
+	}
+	return attrs
+}
+
+// jsonRPCAttributes follows the RPC semantic conventions for the JSON-RPC calls over HTTP
+func jsonRPCAttributes(call *request.JSONRPC) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String("jsonrpc"),
+		semconv.RPCMethod(call.Method),
+		semconv.RPCJsonrpcVersion("2.0"),
+	}
+	if call.RequestID != "" {
+		attrs = append(attrs, semconv.RPCJsonrpcRequestID(call.RequestID))
 	}
+	return attrs
+}
 
+// httpHeaderAttributes returns the captured request headers, sorted by name
+func httpHeaderAttributes(headers map[string][]string) []attribute.KeyValue {
+	if len(headers) == 0 {
+		return nil
+	}
+	attrs := make([]attribute.KeyValue, 0, len(headers))
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		attrs = append(attrs, request.HTTPRequestHeader(name, headers[name]))
+	}
+	return attrs
+}
+
+// clickHouseAttributes returns the attributes that are specific to the ClickHouse native protocol
+func clickHouseAttributes(ch *request.ClickHouse) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if ch.QueryID != "" {
+		attrs = append(attrs, attribute.String("db.clickhouse.query_id", ch.QueryID))
+	}
+	if len(ch.Settings) > 0 {
+		attrs = append(attrs, attribute.StringSlice("db.clickhouse.settings", ch.Settings))
+	}
 	return attrs
 }
 
 func spanKind(span *request.Span) trace2.SpanKind {
 	switch span.Type {
-	case request.EventTypeHTTP, request.EventTypeGRPC, request.EventTypeRedisServer:
+	case request.EventTypeHTTP, request.EventTypeGRPC, request.EventTypeRedisServer, request.EventTypeWebSocketServer,
+		request.EventTypeFTPServer, request.EventTypeSSHServer, request.EventTypeTLSServer:
 		return trace2.SpanKindServer
-	case request.EventTypeHTTPClient, request.EventTypeGRPCClient, request.EventTypeSQLClient, request.EventTypeRedisClient:
+	case request.EventTypeHTTPClient, request.EventTypeGRPCClient, request.EventTypeSQLClient, request.EventTypeRedisClient,
+		request.EventTypeWebSocketClient, request.EventTypeFTPClient, request.EventTypeSSHClient, request.EventTypeTLSClient:
 		return trace2.SpanKindClient
-	case request.EventTypeKafkaClient, request.EventTypeKafkaServer:
+	case request.EventTypeKafkaClient, request.EventTypeKafkaServer, request.EventTypeAMQPClient, request.EventTypeAMQPServer,
+		request.EventTypeNATSClient, request.EventTypeNATSServer:
 		switch span.Method {
 		case request.MessagingPublish:
 			return trace2.SpanKindProducer
@@ -753,6 +1196,11 @@ func getHTTPTracesEndpointOptions(cfg *TracesConfig) (otlpOptions, error) {
 		opts.SkipTLSVerify = true
 	}
 
+	opts.Compression = cfg.getCompression()
+	if err := opts.Compression.validate(); err != nil {
+		return opts, err
+	}
+
 	cfg.Grafana.setupOptions(&opts)
 	maps.Copy(opts.HTTPHeaders, headersFromEnv(envHeaders))
 	maps.Copy(opts.HTTPHeaders, headersFromEnv(envTracesHeaders))
@@ -781,6 +1229,11 @@ func getGRPCTracesEndpointOptions(cfg *TracesConfig) (otlpOptions, error) {
 		opts.SkipTLSVerify = true
 	}
 
+	opts.Compression = cfg.getCompression()
+	if err := opts.Compression.validate(); err != nil {
+		return opts, err
+	}
+
 	return opts, nil
 }
 
@@ -0,0 +1,93 @@
+package otel
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+)
+
+// injectable for testing
+var tracesProbeInterval = 10 * time.Second
+
+const tracesProbeTimeout = 5 * time.Second
+
+// tracesEndpointProbe checks, since startup, whether the traces endpoint can be resolved and connected.
+// If it can't be connected during the grace period, the traces are discarded and Beyla keeps running
+// with metrics only, while the probe keeps checking the endpoint until it is reachable.
+type tracesEndpointProbe struct {
+	address string
+	grace   time.Duration
+	metrics imetrics.Reporter
+	dial    func(ctx context.Context, network, address string) (net.Conn, error)
+
+	degraded atomic.Bool
+}
+
+// newTracesEndpointProbe returns nil if the endpoint can't be probed, e.g. if it is a unix socket
+func newTracesEndpointProbe(cfg *TracesConfig, metrics imetrics.Reporter) *tracesEndpointProbe {
+	endpoint, _, err := parseTracesEndpoint(cfg)
+	if err != nil {
+		return nil
+	}
+	address, ok := endpointAddress(endpoint)
+	if !ok {
+		return nil
+	}
+	dialer := net.Dialer{Timeout: tracesProbeTimeout}
+	return &tracesEndpointProbe{
+		address: address,
+		grace:   cfg.BackendGracePeriod,
+		metrics: metrics,
+		dial:    dialer.DialContext,
+	}
+}
+
+func endpointAddress(endpoint *url.URL) (string, bool) {
+	if port := endpoint.Port(); port != "" {
+		return endpoint.Host, true
+	}
+	switch endpoint.Scheme {
+	case "http":
+		return net.JoinHostPort(endpoint.Hostname(), "80"), true
+	case "https":
+		return net.JoinHostPort(endpoint.Hostname(), "443"), true
+	}
+	return "", false
+}
+
+// discarding returns whether the traces must be discarded because the endpoint is unreachable
+func (p *tracesEndpointProbe) discarding() bool {
+	return p != nil && p.degraded.Load()
+}
+
+func (p *tracesEndpointProbe) run(ctx context.Context) {
+	log := tlog().With("endpoint", p.address)
+	start := time.Now()
+	for {
+		conn, err := p.dial(ctx, "tcp", p.address)
+		if err == nil {
+			_ = conn.Close()
+			if p.degraded.Swap(false) {
+				log.Info("traces endpoint is reachable. Resuming the export of traces")
+				p.metrics.OTELTracesDegraded(false)
+			}
+			return
+		}
+		log.Debug("traces endpoint is unreachable", "error", err)
+		if !p.degraded.Load() && time.Since(start) >= p.grace {
+			log.Warn("traces endpoint has been unreachable since startup. Discarding the traces and"+
+				" exporting only metrics until it is reachable", "gracePeriod", p.grace, "error", err)
+			p.degraded.Store(true)
+			p.metrics.OTELTracesDegraded(true)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tracesProbeInterval):
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mariomac/guara/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/testutil"
+)
+
+type degradedReporter struct {
+	imetrics.NoopReporter
+	mt     sync.Mutex
+	states []bool
+}
+
+func (r *degradedReporter) OTELTracesDegraded(degraded bool) {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	r.states = append(r.states, degraded)
+}
+
+func (r *degradedReporter) reported() []bool {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	return append([]bool{}, r.states...)
+}
+
+func TestTracesEndpointProbe(t *testing.T) {
+	defer func(interval time.Duration) { tracesProbeInterval = interval }(tracesProbeInterval)
+	tracesProbeInterval = 5 * time.Millisecond
+
+	reachable := atomic.Bool{}
+	reporter := &degradedReporter{}
+	probe := &tracesEndpointProbe{
+		address: "traces:4318",
+		grace:   20 * time.Millisecond,
+		metrics: reporter,
+		dial: func(_ context.Context, _, address string) (net.Conn, error) {
+			assert.Equal(t, "traces:4318", address)
+			if reachable.Load() {
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+			return nil, errors.New("connection refused")
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		probe.run(context.Background())
+		close(done)
+	}()
+
+	// the traces are exported during the grace period
+	assert.False(t, probe.discarding())
+	test.Eventually(t, 5*time.Second, func(t require.TestingT) {
+		require.True(t, probe.discarding())
+	})
+	assert.Equal(t, []bool{true}, reporter.reported())
+
+	reachable.Store(true)
+	testutil.ReadChannel(t, done, 5*time.Second)
+	assert.False(t, probe.discarding())
+	assert.Equal(t, []bool{true, false}, reporter.reported())
+}
+
+func TestTracesEndpointProbe_Reachable(t *testing.T) {
+	reporter := &degradedReporter{}
+	probe := &tracesEndpointProbe{
+		address: "traces:4318",
+		metrics: reporter,
+		dial: func(_ context.Context, _, _ string) (net.Conn, error) {
+			client, server := net.Pipe()
+			_ = server.Close()
+			return client, nil
+		},
+	}
+	probe.run(context.Background())
+	assert.False(t, probe.discarding())
+	assert.Empty(t, reporter.reported())
+
+	// a nil probe never discards the traces
+	assert.False(t, (*tracesEndpointProbe)(nil).discarding())
+}
+
+func TestEndpointAddress(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint string
+		address  string
+		ok       bool
+	}{
+		{endpoint: "http://traces:4318/v1/traces", address: "traces:4318", ok: true},
+		{endpoint: "http://traces/v1/traces", address: "traces:80", ok: true},
+		{endpoint: "https://tempo.grafana.net", address: "tempo.grafana.net:443", ok: true},
+		{endpoint: "unix:///var/run/otel.sock"},
+	} {
+		t.Run(tc.endpoint, func(t *testing.T) {
+			u, err := url.Parse(tc.endpoint)
+			require.NoError(t, err)
+			address, ok := endpointAddress(u)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.address, address)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// spanLimit is the maximum number of spans per request that an OTLP endpoint accepted after
+// rejecting a request for being too large, or 0 if it never rejected any
+type spanLimit struct {
+	mt       sync.Mutex
+	maxSpans int
+}
+
+func (l *spanLimit) get() int {
+	l.mt.Lock()
+	defer l.mt.Unlock()
+	return l.maxSpans
+}
+
+// lower sets the maximum number of spans per request, if it is smaller than the currently learned limit
+func (l *spanLimit) lower(endpoint string, maxSpans int) {
+	l.mt.Lock()
+	defer l.mt.Unlock()
+	if l.maxSpans > 0 && l.maxSpans <= maxSpans {
+		return
+	}
+	l.maxSpans = maxSpans
+	tlog().Info("OTLP endpoint rejected a too large request. Splitting the following requests",
+		"endpoint", endpoint, "maxSpans", maxSpans)
+}
+
+// batchSplitter wraps the function that sends the traces batches to an OTLP endpoint. When the endpoint
+// rejects a batch for being too large, it splits the batch in halves until the endpoint accepts them, and
+// splits the following batches according to the learned limit, instead of failing them repeatedly.
+type batchSplitter struct {
+	endpoint string
+	limit    spanLimit
+	consume  consumer.ConsumeTracesFunc
+}
+
+func newBatchSplitter(endpoint string, consume consumer.ConsumeTracesFunc) *batchSplitter {
+	return &batchSplitter{endpoint: endpoint, consume: consume}
+}
+
+func (bs *batchSplitter) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	limit := bs.limit.get()
+	if limit <= 0 || td.SpanCount() <= limit {
+		return bs.consumeOrSplit(ctx, td)
+	}
+	return bs.consumeChunks(ctx, splitTraces(td, limit))
+}
+
+func (bs *batchSplitter) consumeOrSplit(ctx context.Context, td ptrace.Traces) error {
+	err := bs.consume(ctx, td)
+	if err == nil || !isRequestTooLarge(err) {
+		return err
+	}
+	spans := td.SpanCount()
+	if spans <= 1 {
+		// a single span is too large for the endpoint, so retrying it won't help
+		return consumererror.NewPermanent(err)
+	}
+	half := (spans + 1) / 2
+	bs.limit.lower(bs.endpoint, half)
+	return bs.consumeChunks(ctx, splitTraces(td, half))
+}
+
+// consumeChunks sends the chunks in order. The chunks that fail with a permanent error are dropped,
+// and the error is returned after sending the rest of chunks. After any other error, the chunks
+// that were accepted are not sent again: the returned error only contains the failed part and
+// the chunks that were not sent yet, so the retry sender only retries them.
+func (bs *batchSplitter) consumeChunks(ctx context.Context, chunks []ptrace.Traces) error {
+	var permanentErr error
+	for i, chunk := range chunks {
+		err := bs.consumeOrSplit(ctx, chunk)
+		if err == nil {
+			continue
+		}
+		if consumererror.IsPermanent(err) {
+			permanentErr = err
+			continue
+		}
+		failed := ptrace.NewTraces()
+		var partial consumererror.Traces
+		if errors.As(err, &partial) {
+			chunk = partial.Data()
+		}
+		chunk.ResourceSpans().MoveAndAppendTo(failed.ResourceSpans())
+		for _, pending := range chunks[i+1:] {
+			pending.ResourceSpans().MoveAndAppendTo(failed.ResourceSpans())
+		}
+		return consumererror.NewTraces(err, failed)
+	}
+	return permanentErr
+}
+
+// isRequestTooLarge returns whether the OTLP exporter failed because the endpoint
+// considered the request too large: HTTP 413 for OTLP/HTTP, or a ResourceExhausted
+// status reporting an exceeded message size for OTLP/gRPC
+func isRequestTooLarge(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch st.Code() {
+	case codes.ResourceExhausted:
+		return strings.Contains(st.Message(), "larger than max")
+	case codes.Unknown:
+		// the OTLP/HTTP exporter doesn't map 413 to any specific status code, but it adds it to the message
+		return strings.Contains(st.Message(), "HTTP Status Code 413")
+	}
+	return false
+}
+
+// splitTraces copies the traces into chunks of at most n spans, in a single pass. Resources and
+// scopes are copied to all the chunks that contain any of their spans.
+func splitTraces(td ptrace.Traces, n int) []ptrace.Traces {
+	var chunks []ptrace.Traces
+	spans := n
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		var dstRS ptrace.ResourceSpans
+		rsChunk := -1
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			var dstSS ptrace.ScopeSpans
+			ssChunk := -1
+			for k := 0; k < ss.Spans().Len(); k++ {
+				if spans == n {
+					chunks = append(chunks, ptrace.NewTraces())
+					spans = 0
+				}
+				last := len(chunks) - 1
+				if rsChunk != last {
+					dstRS = chunks[last].ResourceSpans().AppendEmpty()
+					rs.Resource().CopyTo(dstRS.Resource())
+					dstRS.SetSchemaUrl(rs.SchemaUrl())
+					rsChunk = last
+				}
+				if ssChunk != last {
+					dstSS = dstRS.ScopeSpans().AppendEmpty()
+					ss.Scope().CopyTo(dstSS.Scope())
+					dstSS.SetSchemaUrl(ss.SchemaUrl())
+					ssChunk = last
+				}
+				ss.Spans().At(k).CopyTo(dstSS.Spans().AppendEmpty())
+				spans++
+			}
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,179 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testTraces creates traces with a resource for each element of spansPerResource,
+// containing the given number of spans, whose names are consecutive numbers
+func testTraces(spansPerResource ...int) ptrace.Traces {
+	td := ptrace.NewTraces()
+	name := 0
+	for i, spans := range spansPerResource {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutInt("resource", int64(i))
+		ss := rs.ScopeSpans().AppendEmpty()
+		for j := 0; j < spans; j++ {
+			ss.Spans().AppendEmpty().SetName(fmt.Sprint(name))
+			name++
+		}
+	}
+	return td
+}
+
+func spanNames(td ptrace.Traces) []string {
+	var names []string
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				names = append(names, spans.At(k).Name())
+			}
+		}
+	}
+	return names
+}
+
+func TestSplitTraces(t *testing.T) {
+	td := testTraces(2, 3)
+
+	chunks := splitTraces(td, 3)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, []string{"0", "1", "2"}, spanNames(chunks[0]))
+	assert.Equal(t, []string{"3", "4"}, spanNames(chunks[1]))
+	// the second resource is copied to both chunks
+	require.Equal(t, 2, chunks[0].ResourceSpans().Len())
+	require.Equal(t, 1, chunks[1].ResourceSpans().Len())
+	res, _ := chunks[1].ResourceSpans().At(0).Resource().Attributes().Get("resource")
+	assert.Equal(t, int64(1), res.Int())
+
+	// the original traces are not modified
+	assert.Equal(t, 5, td.SpanCount())
+
+	chunks = splitTraces(td, 2)
+	require.Len(t, chunks, 3)
+	assert.Equal(t, []string{"0", "1"}, spanNames(chunks[0]))
+	assert.Equal(t, []string{"2", "3"}, spanNames(chunks[1]))
+	assert.Equal(t, []string{"4"}, spanNames(chunks[2]))
+	assert.Equal(t, 1, chunks[0].ResourceSpans().Len())
+	assert.Equal(t, 1, chunks[1].ResourceSpans().Len())
+}
+
+type fakeEndpoint struct {
+	maxSpans int
+	err      error
+	requests []int
+	received []string
+}
+
+func (fe *fakeEndpoint) consume(_ context.Context, td ptrace.Traces) error {
+	fe.requests = append(fe.requests, td.SpanCount())
+	if td.SpanCount() > fe.maxSpans {
+		return fe.err
+	}
+	fe.received = append(fe.received, spanNames(td)...)
+	return nil
+}
+
+func TestBatchSplitter(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+	}{{
+		name: "OTLP/HTTP",
+		err: consumererror.NewPermanent(status.New(codes.Unknown,
+			"error exporting items, request to http://collector:4318/v1/traces responded with HTTP Status Code 413").Err()),
+	}, {
+		name: "OTLP/gRPC",
+		err: consumererror.NewPermanent(status.New(codes.ResourceExhausted,
+			"grpc: received message larger than max (5000000 vs. 4194304)").Err()),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := &fakeEndpoint{maxSpans: 3, err: tc.err}
+			bs := newBatchSplitter("collector", endpoint.consume)
+
+			// the batch is split in halves until the endpoint accepts it
+			require.NoError(t, bs.ConsumeTraces(context.Background(), testTraces(4, 6)))
+			assert.Equal(t, []int{10, 5, 3, 2, 5, 3, 2}, endpoint.requests)
+			assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, endpoint.received)
+			assert.Equal(t, 3, bs.limit.get())
+
+			// the following batches are directly split according to the learned limit
+			endpoint.requests, endpoint.received = nil, nil
+			require.NoError(t, bs.ConsumeTraces(context.Background(), testTraces(7)))
+			assert.Equal(t, []int{3, 3, 1}, endpoint.requests)
+			assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6"}, endpoint.received)
+		})
+	}
+}
+
+func TestBatchSplitter_SingleSpanTooLarge(t *testing.T) {
+	tooLarge := status.New(codes.ResourceExhausted, "grpc: received message larger than max").Err()
+	endpoint := &fakeEndpoint{maxSpans: 0, err: tooLarge}
+	bs := newBatchSplitter("collector", endpoint.consume)
+
+	err := bs.ConsumeTraces(context.Background(), testTraces(2))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, tooLarge))
+	// the span is not retried
+	assert.True(t, consumererror.IsPermanent(err))
+	assert.Equal(t, []int{2, 1, 1}, endpoint.requests)
+}
+
+func TestBatchSplitter_PartialFailure(t *testing.T) {
+	unavailable := status.New(codes.Unavailable, "the collector is down").Err()
+	var requests []int
+	var received []string
+	bs := newBatchSplitter("collector", func(_ context.Context, td ptrace.Traces) error {
+		requests = append(requests, td.SpanCount())
+		if len(requests) == 2 {
+			return unavailable
+		}
+		received = append(received, spanNames(td)...)
+		return nil
+	})
+	bs.limit.lower("collector", 3)
+
+	err := bs.ConsumeTraces(context.Background(), testTraces(7))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, unavailable))
+	assert.False(t, consumererror.IsPermanent(err))
+	// the chunks after the failed one are not sent
+	assert.Equal(t, []int{3, 3}, requests)
+	assert.Equal(t, []string{"0", "1", "2"}, received)
+
+	// only the failed and the pending spans are retried
+	var retry consumererror.Traces
+	require.True(t, errors.As(err, &retry))
+	assert.Equal(t, []string{"3", "4", "5", "6"}, spanNames(retry.Data()))
+}
+
+func TestBatchSplitter_OtherErrors(t *testing.T) {
+	for _, err := range []error{
+		errors.New("connection refused"),
+		status.New(codes.Unavailable, "the collector is down").Err(),
+		// throttling is managed by the retry sender
+		status.New(codes.ResourceExhausted, "too many requests").Err(),
+		status.New(codes.Unknown, "error exporting items, request to http://collector:4318/v1/traces responded with HTTP Status Code 500").Err(),
+	} {
+		t.Run(err.Error(), func(t *testing.T) {
+			endpoint := &fakeEndpoint{maxSpans: 0, err: err}
+			bs := newBatchSplitter("collector", endpoint.consume)
+
+			assert.Equal(t, err, bs.ConsumeTraces(context.Background(), testTraces(4)))
+			assert.Equal(t, []int{4}, endpoint.requests)
+			assert.Zero(t, bs.limit.get())
+		})
+	}
+}
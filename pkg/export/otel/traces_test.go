@@ -75,6 +75,22 @@ func TestHTTPTracesEndpoint(t *testing.T) {
 	t.Run("testing with skip TLS verification", func(t *testing.T) {
 		testHTTPTracesOptions(t, otlpOptions{Scheme: "https", Endpoint: "localhost:3232", URLPath: "/v1/traces", SkipTLSVerify: true, HTTPHeaders: map[string]string{}}, &tcfg)
 	})
+
+	tcfg = TracesConfig{
+		CommonEndpoint:    "https://localhost:3232",
+		Compression:       CompressionGzip,
+		TracesCompression: CompressionZstd,
+		Instrumentations:  []string{instrumentations.InstrumentationALL},
+	}
+
+	t.Run("testing with compression", func(t *testing.T) {
+		testHTTPTracesOptions(t, otlpOptions{Scheme: "https", Endpoint: "localhost:3232", URLPath: "/v1/traces", Compression: CompressionZstd, HTTPHeaders: map[string]string{}}, &tcfg)
+	})
+
+	t.Run("invalid compression", func(t *testing.T) {
+		_, err := getHTTPTracesEndpointOptions(&TracesConfig{CommonEndpoint: "https://localhost:3232", Compression: "brotli"})
+		assert.Error(t, err)
+	})
 }
 
 func TestHTTPTracesWithGrafanaOptions(t *testing.T) {
@@ -572,6 +588,125 @@ func TestGenerateTracesAttributes(t *testing.T) {
 		ensureTraceStrAttr(t, attrs, semconv.DBSystemKey, "other_sql")
 		ensureTraceStrAttr(t, attrs, attribute.Key(attr.DBQueryText), "SELECT password FROM credentials WHERE username=\"bill\"")
 	})
+	t.Run("test HTTP response body size", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeHTTP, Method: "GET", Path: "/stream", Status: 200,
+			ContentLength: 78, ResponseLength: 65536}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+		attrs := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+		reqSize, ok := attrs.Get(string(attr.HTTPRequestBodySize))
+		require.True(t, ok)
+		assert.Equal(t, int64(78), reqSize.Int())
+		respSize, ok := attrs.Get(string(attr.HTTPResponseBodySize))
+		require.True(t, ok)
+		assert.Equal(t, int64(65536), respSize.Int())
+
+		// responses are not accounted when the span is sent before the response body
+		span.ResponseLength = 0
+		traces = GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+		attrs = traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+		ensureTraceAttrNotExists(t, attrs, attribute.Key(attr.HTTPResponseBodySize))
+	})
+	t.Run("test HTTP captured request headers", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeHTTPClient, Method: "GET", Path: "/", Status: 200,
+			RequestHeaders: map[string][]string{"x-request-id": {"abc-123"}, "accept": {"text/html", "*/*"}}}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+		attrs := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+		requestID, ok := attrs.Get("http.request.header.x-request-id")
+		require.True(t, ok)
+		assert.Equal(t, []any{"abc-123"}, requestID.Slice().AsRaw())
+		accept, ok := attrs.Get("http.request.header.accept")
+		require.True(t, ok)
+		assert.Equal(t, []any{"text/html", "*/*"}, accept.Slice().AsRaw())
+	})
+	t.Run("test Elasticsearch trace generation", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeHTTPClient, Method: "POST", Path: "/products/_search",
+			Status: 200, Host: "es", HostPort: 9200, SubType: request.HTTPSubtypeElasticsearch,
+			Elasticsearch: &request.Elasticsearch{
+				DBOperationName:  "search",
+				DBCollectionName: "products",
+				DBQueryText:      `{"query":{"term":{"sku":?}}}`,
+			}}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{attr.DBQueryText: {}}, []attribute.KeyValue{})
+
+		spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		assert.Equal(t, "search products", spans.At(0).Name())
+		attrs := spans.At(0).Attributes()
+		ensureTraceStrAttr(t, attrs, semconv.DBSystemKey, "elasticsearch")
+		ensureTraceStrAttr(t, attrs, attribute.Key(attr.DBOperation), "search")
+		ensureTraceStrAttr(t, attrs, attribute.Key(attr.DBCollectionName), "products")
+		ensureTraceStrAttr(t, attrs, attribute.Key(attr.DBQueryText), `{"query":{"term":{"sku":?}}}`)
+		ensureTraceAttrNotExists(t, attrs, attribute.Key(attr.HTTPUrlFull))
+	})
+	t.Run("test JSON-RPC trace generation", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeHTTP, Method: "POST", Path: "/", Status: 200,
+			SubType: request.HTTPSubtypeJSONRPC, JSONRPC: &request.JSONRPC{Method: "eth_getBalance", RequestID: "1"}}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+
+		spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		assert.Equal(t, "eth_getBalance", spans.At(0).Name())
+		attrs := spans.At(0).Attributes()
+		ensureTraceStrAttr(t, attrs, semconv.RPCSystemKey, "jsonrpc")
+		ensureTraceStrAttr(t, attrs, semconv.RPCMethodKey, "eth_getBalance")
+		ensureTraceStrAttr(t, attrs, semconv.RPCJsonrpcVersionKey, "2.0")
+		ensureTraceStrAttr(t, attrs, semconv.RPCJsonrpcRequestIDKey, "1")
+		ensureTraceStrAttr(t, attrs, semconv.HTTPRequestMethodKey, "POST")
+	})
+	t.Run("test Redis cluster redirect event", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeRedisClient, Method: "GET", Path: "GET user:1", Status: 1,
+			RequestStart: 100, Start: 100, End: 200,
+			RedisRedirect: &request.RedisRedirect{Type: "MOVED", Slot: 3999, Address: "10.0.0.3:6379"}}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+
+		spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		require.Equal(t, 1, spans.At(0).Events().Len())
+		event := spans.At(0).Events().At(0)
+		assert.Equal(t, "db.redis.redirect", event.Name())
+		assert.Equal(t, spans.At(0).EndTimestamp(), event.Timestamp())
+		ensureTraceStrAttr(t, event.Attributes(), attribute.Key(attr.DBRedisRedirectType), "MOVED")
+		ensureTraceStrAttr(t, event.Attributes(), "db.redis.redirect.address", "10.0.0.3:6379")
+		slot, ok := event.Attributes().Get("db.redis.redirect.slot")
+		require.True(t, ok)
+		assert.Equal(t, int64(3999), slot.Int())
+	})
+	t.Run("test HTTP/2 stream reset event", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeGRPC, Path: "/helloworld.Greeter/SayHello", Status: 0,
+			RequestStart: 100, Start: 100, End: 200,
+			HTTP2Reset: &request.HTTP2StreamReset{ErrorCode: "CANCEL", Sender: "client"}}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+
+		spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		require.Equal(t, 1, spans.At(0).Events().Len())
+		event := spans.At(0).Events().At(0)
+		assert.Equal(t, "http2.rst_stream", event.Name())
+		assert.Equal(t, spans.At(0).EndTimestamp(), event.Timestamp())
+		ensureTraceStrAttr(t, event.Attributes(), "http2.rst_stream.error_code", "CANCEL")
+		ensureTraceStrAttr(t, event.Attributes(), "http2.rst_stream.sender", "client")
+	})
+	t.Run("test gRPC client deadline and retries", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeGRPCClient, Path: "/helloworld.Greeter/SayHello", Status: 4,
+			RequestStart: 100, Start: 100, End: 200, GRPCTimeout: 250 * time.Millisecond, GRPCPreviousAttempts: 2}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+
+		attrs := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+		timeout, ok := attrs.Get("rpc.grpc.timeout")
+		require.True(t, ok)
+		assert.InDelta(t, 0.25, timeout.Double(), 1e-9)
+		attempts, ok := attrs.Get("rpc.grpc.previous_rpc_attempts")
+		require.True(t, ok)
+		assert.Equal(t, int64(2), attempts.Int())
+	})
+	t.Run("test HTTP request body event", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeHTTP, Method: "POST", Path: "/orders", Status: 400,
+			RequestStart: 100, Start: 100, End: 200, RequestBody: `{"id":`}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+
+		spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		require.Equal(t, 1, spans.At(0).Events().Len())
+		event := spans.At(0).Events().At(0)
+		assert.Equal(t, "http.request.body", event.Name())
+		assert.Equal(t, spans.At(0).StartTimestamp(), event.Timestamp())
+		ensureTraceStrAttr(t, event.Attributes(), "http.request.body.content", `{"id":`)
+	})
 	t.Run("test Kafka trace generation", func(t *testing.T) {
 		span := request.Span{Type: request.EventTypeKafkaClient, Method: "process", Path: "important-topic", Statement: "test"}
 		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
@@ -588,7 +723,43 @@ func TestGenerateTracesAttributes(t *testing.T) {
 		ensureTraceStrAttr(t, attrs, attribute.Key(attr.MessagingOpType), "process")
 		ensureTraceStrAttr(t, attrs, semconv.MessagingDestinationNameKey, "important-topic")
 		ensureTraceStrAttr(t, attrs, semconv.MessagingClientIDKey, "test")
+		ensureTraceAttrNotExists(t, attrs, semconv.MessagingDestinationPartitionIDKey)
+		assert.Equal(t, 0, spans.At(0).Links().Len())
+	})
+	t.Run("test Kafka consumer link to producer", func(t *testing.T) {
+		producerTraceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+		producerSpanID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+		span := request.Span{Type: request.EventTypeKafkaClient, Method: "process", Path: "important-topic", Statement: "test",
+			KafkaRecord: &request.KafkaRecord{Partition: 3, Offset: 100, Lag: 15,
+				ProducerTraceID: producerTraceID, ProducerSpanID: producerSpanID}}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
 
+		spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		attrs := spans.At(0).Attributes()
+		ensureTraceStrAttr(t, attrs, semconv.MessagingDestinationPartitionIDKey, "3")
+		offset, ok := attrs.Get(string(semconv.MessagingKafkaMessageOffsetKey))
+		require.True(t, ok)
+		assert.Equal(t, int64(100), offset.Int())
+		lag, ok := attrs.Get("messaging.kafka.consumer.lag")
+		require.True(t, ok)
+		assert.Equal(t, int64(15), lag.Int())
+
+		require.Equal(t, 1, spans.At(0).Links().Len())
+		link := spans.At(0).Links().At(0)
+		assert.Equal(t, pcommon.TraceID(producerTraceID), link.TraceID())
+		assert.Equal(t, pcommon.SpanID(producerSpanID), link.SpanID())
+	})
+	t.Run("test Kafka consumer without captured record batch", func(t *testing.T) {
+		span := request.Span{Type: request.EventTypeKafkaClient, Method: "process", Path: "important-topic",
+			KafkaRecord: &request.KafkaRecord{Partition: 3, Offset: -1, Lag: -1}}
+		traces := GenerateTraces(&span, "host-id", map[attr.Name]struct{}{}, []attribute.KeyValue{})
+
+		spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		attrs := spans.At(0).Attributes()
+		ensureTraceStrAttr(t, attrs, semconv.MessagingDestinationPartitionIDKey, "3")
+		ensureTraceAttrNotExists(t, attrs, semconv.MessagingKafkaMessageOffsetKey)
+		ensureTraceAttrNotExists(t, attrs, "messaging.kafka.consumer.lag")
+		assert.Equal(t, 0, spans.At(0).Links().Len())
 	})
 	t.Run("test env var resource attributes", func(t *testing.T) {
 		defer restoreEnvAfterExecution()()
@@ -1054,7 +1225,7 @@ func (f *fakeInternalTraces) OTELTraceExport(len int) {
 	f.sum.Add(int32(len))
 }
 
-func (f *fakeInternalTraces) OTELTraceExportError(_ error) {
+func (f *fakeInternalTraces) OTELTraceExportError(_ error, _ string) {
 	f.errs.Add(1)
 }
 
@@ -1351,7 +1522,7 @@ func makeTracesTestReceiver(instr []string) *tracesOTELReceiver {
 			ReportersCacheLen: 16,
 			Instrumentations:  instr,
 		},
-		&global.ContextInfo{},
+		&global.ContextInfo{Metrics: imetrics.NoopReporter{}},
 		attributes.Selection{},
 	)
 }
@@ -53,6 +53,18 @@ func (ex *Expirer[T]) WithLabelValues(lbls ...string) *MetricEntry[T] {
 	})
 }
 
+// DeleteWithLabelValue drops the labeled instances whose label value in the given position
+// (same order as the variable labels in Desc) is equal to the given value
+func (ex *Expirer[T]) DeleteWithLabelValue(index int, value string) {
+	deleted := ex.entries.DeleteMatching(func(labelValues []string) bool {
+		return index < len(labelValues) && labelValues[index] == value
+	})
+	for _, old := range deleted {
+		ex.wrapped.DeleteLabelValues(old.labelVals...)
+		plog().With("labelValues", old.labelVals).Debug("deleting Prometheus metric")
+	}
+}
+
 // Describe wraps prometheus.Collector Describe method
 func (ex *Expirer[T]) Describe(descs chan<- *prometheus.Desc) {
 	ex.wrapped.Describe(descs)
@@ -0,0 +1,46 @@
+package prom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/beyla/pkg/internal/kube"
+)
+
+// leaderCollector only exposes the wrapped metrics when the current instance is the leader.
+// Standby instances keep updating the metrics, so they can take over the export of their
+// own accumulated values if the leader goes down.
+type leaderCollector struct {
+	prometheus.Collector
+	leader *kube.LeaderElector
+}
+
+func (lc *leaderCollector) Collect(metrics chan<- prometheus.Metric) {
+	if lc.leader.IsLeader() {
+		lc.Collector.Collect(metrics)
+		return
+	}
+	// we still need to invoke the wrapped Collect method, as the
+	// metric expiration mechanism runs on each collection
+	discard := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for range discard {
+		}
+		close(done)
+	}()
+	lc.Collector.Collect(discard)
+	close(discard)
+	<-done
+}
+
+// leaderCollectors wraps the provided collectors into a leaderCollector, if the leader election is enabled
+func leaderCollectors(leader *kube.LeaderElector, collectors ...prometheus.Collector) []prometheus.Collector {
+	if leader == nil {
+		return collectors
+	}
+	wrapped := make([]prometheus.Collector, 0, len(collectors))
+	for _, c := range collectors {
+		wrapped = append(wrapped, &leaderCollector{Collector: c, leader: leader})
+	}
+	return wrapped
+}
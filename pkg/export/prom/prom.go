@@ -84,6 +84,9 @@ const (
 // metrics for Beyla statistics
 const (
 	BeylaBuildInfo = "beyla_build_info"
+	// BeylaLastEventTimestamp allows telling apart the series of the idle services, which keep
+	// their last event timestamp, from the series whose service stopped being instrumented
+	BeylaLastEventTimestamp = "beyla_last_event_timestamp_seconds"
 
 	LanguageLabel = "target_lang"
 )
@@ -95,6 +98,12 @@ var beylaInfoLabelNames = []string{LanguageLabel}
 type PrometheusConfig struct {
 	Port int    `yaml:"port" env:"BEYLA_PROMETHEUS_PORT"`
 	Path string `yaml:"path" env:"BEYLA_PROMETHEUS_PATH"`
+	// TargetsPath exposes the instrumented services as /api/v1/targets-style metadata when the application
+	// metrics are enabled. Empty value disables it.
+	TargetsPath string `yaml:"targets_path" env:"BEYLA_PROMETHEUS_TARGETS_PATH"`
+	// ServicePaths also exposes the metrics of each service and namespace in the <Path>/<namespace>/<service>
+	// and <Path>/<namespace> sub-paths, so each team can scrape only their own services.
+	ServicePaths bool `yaml:"service_paths" env:"BEYLA_PROMETHEUS_SERVICE_PATHS"`
 
 	// Deprecated. Going to be removed in Beyla 2.0. Use attributes.select instead
 	ReportTarget bool `yaml:"report_target" env:"BEYLA_METRICS_REPORT_TARGET"`
@@ -115,6 +124,11 @@ type PrometheusConfig struct {
 	TTL                         time.Duration `yaml:"ttl" env:"BEYLA_PROMETHEUS_TTL"`
 	SpanMetricsServiceCacheSize int           `yaml:"service_cache_size"`
 
+	// DeletedServiceTTL is the time since the process of a service instance ended until the metrics
+	// that have the instance label of the service instance are removed, even if their TTL didn't pass yet.
+	// Zero disables the removal.
+	DeletedServiceTTL time.Duration `yaml:"deleted_service_ttl" env:"BEYLA_PROMETHEUS_DELETED_SERVICE_TTL"`
+
 	AllowServiceGraphSelfReferences bool `yaml:"allow_service_graph_self_references" env:"BEYLA_PROMETHEUS_ALLOW_SERVICE_GRAPH_SELF_REFERENCES"`
 
 	// Registry is only used for embedding Beyla within the Grafana Agent.
@@ -156,11 +170,13 @@ type metricsReporter struct {
 	grpcDuration          *Expirer[prometheus.Histogram]
 	grpcClientDuration    *Expirer[prometheus.Histogram]
 	dbClientDuration      *Expirer[prometheus.Histogram]
+	dbRedisRedirects      *Expirer[prometheus.Counter]
 	msgPublishDuration    *Expirer[prometheus.Histogram]
 	msgProcessDuration    *Expirer[prometheus.Histogram]
 	httpRequestSize       *Expirer[prometheus.Histogram]
 	httpClientRequestSize *Expirer[prometheus.Histogram]
 	targetInfo            *Expirer[prometheus.Gauge]
+	lastEventTimestamp    *Expirer[prometheus.Gauge]
 
 	// user-selected attributes for the application-level metrics
 	attrHTTPDuration          []attributes.Field[*request.Span, string]
@@ -168,6 +184,7 @@ type metricsReporter struct {
 	attrGRPCDuration          []attributes.Field[*request.Span, string]
 	attrGRPCClientDuration    []attributes.Field[*request.Span, string]
 	attrDBClientDuration      []attributes.Field[*request.Span, string]
+	attrDBRedisRedirects      []attributes.Field[*request.Span, string]
 	attrMsgPublishDuration    []attributes.Field[*request.Span, string]
 	attrMsgProcessDuration    []attributes.Field[*request.Span, string]
 	attrHTTPRequestSize       []attributes.Field[*request.Span, string]
@@ -197,6 +214,22 @@ type metricsReporter struct {
 	hostID      string
 
 	serviceCache *expirable.LRU[svc.UID, svc.ID]
+
+	// instanceMetrics are the metrics whose series are removed after the process of their service instance ends
+	instanceMetrics []instanceMetric
+	// endedInstances stores the time when the process of each service instance ended
+	endedInstances map[svc.UID]time.Time
+}
+
+// labelValueDeleter is implemented by the Expirer of any metric type
+type labelValueDeleter interface {
+	DeleteWithLabelValue(index int, value string)
+}
+
+// instanceMetric is a metric that has the instance label in the given position
+type instanceMetric struct {
+	metric labelValueDeleter
+	index  int
 }
 
 func PrometheusEndpoint(
@@ -259,11 +292,13 @@ func newReporter(
 			attrsProvider.For(attributes.RPCClientDuration))
 	}
 
-	var attrDBClientDuration []attributes.Field[*request.Span, string]
+	var attrDBClientDuration, attrDBRedisRedirects []attributes.Field[*request.Span, string]
 
 	if is.DBEnabled() {
 		attrDBClientDuration = attributes.PrometheusGetters(request.SpanPromGetters,
 			attrsProvider.For(attributes.DBClientDuration))
+		attrDBRedisRedirects = attributes.PrometheusGetters(request.SpanPromGetters,
+			attrsProvider.For(attributes.DBClientRedisRedirects))
 	}
 
 	var attrMessagingProcessDuration, attrMessagingPublishDuration []attributes.Field[*request.Span, string]
@@ -293,6 +328,7 @@ func newReporter(
 		attrGRPCDuration:          attrGRPCDuration,
 		attrGRPCClientDuration:    attrGRPCClientDuration,
 		attrDBClientDuration:      attrDBClientDuration,
+		attrDBRedisRedirects:      attrDBRedisRedirects,
 		attrMsgPublishDuration:    attrMessagingPublishDuration,
 		attrMsgProcessDuration:    attrMessagingProcessDuration,
 		attrHTTPRequestSize:       attrHTTPRequestSize,
@@ -360,6 +396,12 @@ func newReporter(
 				NativeHistogramMinResetDuration: defaultHistogramMinResetDuration,
 			}, labelNames(attrDBClientDuration)).MetricVec, clock.Time, cfg.TTL)
 		}),
+		dbRedisRedirects: optionalCounterProvider(is.DBEnabled(), func() *Expirer[prometheus.Counter] {
+			return NewExpirer[prometheus.Counter](prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: attributes.DBClientRedisRedirects.Prom,
+				Help: "number of Redis Cluster commands that were redirected to another node with a MOVED or ASK reply",
+			}, labelNames(attrDBRedisRedirects)).MetricVec, clock.Time, cfg.TTL)
+		}),
 		msgPublishDuration: optionalHistogramProvider(is.MQEnabled(), func() *Expirer[prometheus.Histogram] {
 			return NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
 				Name:                            attributes.MessagingPublishDuration.Prom,
@@ -464,6 +506,10 @@ func newReporter(
 			Name: TargetInfo,
 			Help: "attributes associated to a given monitored entity",
 		}, labelNamesTargetInfo(kubeEnabled)).MetricVec, clock.Time, cfg.TTL),
+		lastEventTimestamp: NewExpirer[prometheus.Gauge](prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: BeylaLastEventTimestamp,
+			Help: "Unix time, in seconds, when the last request of a service instance finished",
+		}, labelNamesLastEvent()).MetricVec, clock.Time, cfg.TTL),
 	}
 
 	if cfg.SpanMetricsEnabled() {
@@ -473,7 +519,12 @@ func newReporter(
 		}, cfg.TTL)
 	}
 
-	registeredMetrics := []prometheus.Collector{mr.targetInfo}
+	if cfg.DeletedServiceTTL > 0 {
+		mr.instanceMetrics = mr.metricsWithInstanceLabel()
+		mr.endedInstances = map[svc.UID]time.Time{}
+	}
+
+	registeredMetrics := []prometheus.Collector{mr.targetInfo, mr.lastEventTimestamp}
 
 	if !mr.cfg.DisableBuildInfo {
 		registeredMetrics = append(registeredMetrics, mr.beylaInfo)
@@ -499,6 +550,7 @@ func newReporter(
 		if is.DBEnabled() {
 			registeredMetrics = append(registeredMetrics,
 				mr.dbClientDuration,
+				mr.dbRedisRedirects,
 			)
 		}
 
@@ -520,18 +572,29 @@ func newReporter(
 	}
 
 	if cfg.ServiceGraphMetricsEnabled() {
-		registeredMetrics = append(registeredMetrics,
+		registeredMetrics = append(registeredMetrics, leaderCollectors(ctxInfo.ServiceGraphLeader,
 			mr.serviceGraphClient,
 			mr.serviceGraphServer,
 			mr.serviceGraphFailed,
 			mr.serviceGraphTotal,
-		)
+		)...)
 	}
 
 	if mr.cfg.Registry != nil {
 		mr.cfg.Registry.MustRegister(registeredMetrics...)
 	} else {
 		mr.promConnect.Register(cfg.Port, cfg.Path, registeredMetrics...)
+		if cfg.ServicePaths {
+			mr.promConnect.ServeServicePaths(cfg.Port, cfg.Path)
+		}
+		if cfg.OTelMetricsEnabled() && cfg.TargetsPath != "" {
+			mr.promConnect.RegisterHandler(cfg.Port, cfg.TargetsPath, &targetsHandler{
+				metricsPath:  cfg.Path,
+				servicePaths: cfg.ServicePaths,
+				labelNames:   labelNamesTargetInfo(kubeEnabled),
+				targetInfo:   mr.targetInfo,
+			})
+		}
 	}
 
 	return mr, nil
@@ -567,12 +630,87 @@ func (r *metricsReporter) reportMetrics(input <-chan []request.Span) {
 }
 
 func (r *metricsReporter) collectMetrics(input <-chan []request.Span) {
-	for spans := range input {
-		// clock needs to be updated to let the expirer
-		// remove the old metrics
-		r.clock.Update()
-		for i := range spans {
-			r.observe(&spans[i])
+	var checkEnded <-chan time.Time
+	if r.endedInstances != nil {
+		ticker := time.NewTicker(max(r.cfg.DeletedServiceTTL/4, time.Second))
+		defer ticker.Stop()
+		checkEnded = ticker.C
+	}
+	for {
+		select {
+		case spans, ok := <-input:
+			if !ok {
+				return
+			}
+			// clock needs to be updated to let the expirer
+			// remove the old metrics
+			r.clock.Update()
+			for i := range spans {
+				r.observe(&spans[i])
+			}
+		case <-checkEnded:
+			r.clock.Update()
+			r.deleteEndedInstances()
+		}
+	}
+}
+
+// metricsWithInstanceLabel returns the registered metrics that have the instance label
+func (r *metricsReporter) metricsWithInstanceLabel() []instanceMetric {
+	var metrics []instanceMetric
+	add := func(metric labelValueDeleter, labelNames []string) {
+		if index := slices.Index(labelNames, serviceInstanceKey); index >= 0 {
+			metrics = append(metrics, instanceMetric{metric: metric, index: index})
+		}
+	}
+	add(r.targetInfo, labelNamesTargetInfo(r.kubeEnabled))
+	add(r.lastEventTimestamp, labelNamesLastEvent())
+	if r.cfg.OTelMetricsEnabled() {
+		if r.is.HTTPEnabled() {
+			add(r.httpDuration, labelNames(r.attrHTTPDuration))
+			add(r.httpClientDuration, labelNames(r.attrHTTPClientDuration))
+			add(r.httpRequestSize, labelNames(r.attrHTTPRequestSize))
+			add(r.httpClientRequestSize, labelNames(r.attrHTTPClientRequestSize))
+		}
+		if r.is.GRPCEnabled() {
+			add(r.grpcDuration, labelNames(r.attrGRPCDuration))
+			add(r.grpcClientDuration, labelNames(r.attrGRPCClientDuration))
+		}
+		if r.is.DBEnabled() {
+			add(r.dbClientDuration, labelNames(r.attrDBClientDuration))
+			add(r.dbRedisRedirects, labelNames(r.attrDBRedisRedirects))
+		}
+		if r.is.MQEnabled() {
+			add(r.msgPublishDuration, labelNames(r.attrMsgPublishDuration))
+			add(r.msgProcessDuration, labelNames(r.attrMsgProcessDuration))
+		}
+	}
+	if r.cfg.SpanMetricsEnabled() {
+		add(r.spanMetricsLatency, labelNamesSpans())
+		add(r.spanMetricsCallsTotal, labelNamesSpans())
+		add(r.spanMetricsSizeTotal, labelNamesSpans())
+	}
+	if r.cfg.SpanMetricsEnabled() || r.cfg.ServiceGraphMetricsEnabled() {
+		add(r.tracesTargetInfo, labelNamesTargetInfo(r.kubeEnabled))
+	}
+	return metrics
+}
+
+// deleteEndedInstances removes the metrics of the service instances whose process ended
+// before the DeletedServiceTTL, so Prometheus marks their series as stale in the next scrape
+func (r *metricsReporter) deleteEndedInstances() {
+	now := r.clock.Time()
+	for uid, ended := range r.endedInstances {
+		if now.Sub(ended) < r.cfg.DeletedServiceTTL {
+			continue
+		}
+		delete(r.endedInstances, uid)
+		if r.serviceCache != nil {
+			// decreases the traces target info before its removal
+			r.serviceCache.Remove(uid)
+		}
+		for _, im := range r.instanceMetrics {
+			im.metric.DeleteWithLabelValue(im.index, string(uid))
 		}
 	}
 }
@@ -584,14 +722,23 @@ func (r *metricsReporter) otelSpanObserved(span *request.Span) bool {
 // nolint:cyclop
 func (r *metricsReporter) observe(span *request.Span) {
 	if span.InternalSignal() {
+		if span.Type == request.EventTypeProcessDeleted && r.endedInstances != nil {
+			r.endedInstances[span.ServiceID.UID] = r.clock.Time()
+		}
 		return
 	}
 	t := span.Timings()
+	if ended, ok := r.endedInstances[span.ServiceID.UID]; ok && t.Start.After(ended) {
+		// the service instance was restarted or another of its processes is still alive
+		delete(r.endedInstances, span.ServiceID.UID)
+	}
 	r.beylaInfo.WithLabelValues(span.ServiceID.SDKLanguage.String()).metric.Set(1.0)
 	duration := t.End.Sub(t.RequestStart).Seconds()
 
 	targetInfoLabelValues := r.labelValuesTargetInfo(span.ServiceID)
 	r.targetInfo.WithLabelValues(targetInfoLabelValues...).metric.Set(1)
+	r.lastEventTimestamp.WithLabelValues(labelValuesLastEvent(span.ServiceID)...).
+		metric.Set(float64(t.End.UnixNano()) / float64(time.Second))
 
 	if r.otelSpanObserved(span) {
 		switch span.Type {
@@ -630,8 +777,14 @@ func (r *metricsReporter) observe(span *request.Span) {
 				r.dbClientDuration.WithLabelValues(
 					labelValues(span, r.attrDBClientDuration)...,
 				).metric.Observe(duration)
+				if span.RedisRedirect != nil {
+					r.dbRedisRedirects.WithLabelValues(
+						labelValues(span, r.attrDBRedisRedirects)...,
+					).metric.Add(1)
+				}
 			}
-		case request.EventTypeKafkaClient, request.EventTypeKafkaServer:
+		case request.EventTypeKafkaClient, request.EventTypeKafkaServer, request.EventTypeAMQPClient, request.EventTypeAMQPServer,
+			request.EventTypeNATSClient, request.EventTypeNATSServer:
 			if r.is.MQEnabled() {
 				switch span.Method {
 				case request.MessagingPublish:
@@ -746,6 +899,14 @@ func (r *metricsReporter) labelValuesTargetInfo(service svc.ID) []string {
 	return values
 }
 
+func labelNamesLastEvent() []string {
+	return []string{serviceKey, serviceNamespaceKey, serviceInstanceKey, serviceJobKey}
+}
+
+func labelValuesLastEvent(service svc.ID) []string {
+	return []string{service.Name, service.Namespace, string(service.UID), service.Job()}
+}
+
 func labelNamesServiceGraph() []string {
 	return []string{clientKey, clientNamespaceKey, serverKey, serverNamespaceKey, sourceKey}
 }
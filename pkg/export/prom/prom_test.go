@@ -2,6 +2,7 @@ package prom
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -112,6 +113,192 @@ func TestAppMetricsExpiration(t *testing.T) {
 	assert.Regexp(t, containsTargetInfo, exported)
 }
 
+func TestAppMetricsDeletedService(t *testing.T) {
+	now := syncedClock{now: time.Now()}
+	timeNow = now.Now
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	openPort, err := test.FreeTCPPort()
+	require.NoError(t, err)
+	promURL := fmt.Sprintf("http://127.0.0.1:%d/metrics", openPort)
+
+	// GIVEN a Prometheus Metrics Exporter that removes the metrics of the ended services after 2 seconds
+	exporter, err := PrometheusEndpoint(
+		ctx, &global.ContextInfo{Prometheus: &connector.PrometheusManager{}, HostID: "my-host"},
+		&PrometheusConfig{
+			Port:                        openPort,
+			Path:                        "/metrics",
+			TTL:                         time.Hour,
+			DeletedServiceTTL:           2 * time.Second,
+			SpanMetricsServiceCacheSize: 10,
+			Features:                    []string{otel.FeatureApplication},
+			Instrumentations:            []string{instrumentations.InstrumentationALL},
+		},
+		attributes.Selection{
+			attributes.HTTPServerDuration.Section: attributes.InclusionLists{
+				Include: []string{"url_path", "instance"},
+			},
+		},
+	)()
+	require.NoError(t, err)
+
+	metrics := make(chan []request.Span, 20)
+	go exporter(metrics)
+
+	foo := svc.ID{Name: "foo", UID: "foo-1"}
+	bar := svc.ID{Name: "bar", UID: "bar-1"}
+	metrics <- []request.Span{
+		{Type: request.EventTypeHTTP, ServiceID: foo, Path: "/foo", End: 123 * time.Second.Nanoseconds()},
+		{Type: request.EventTypeHTTP, ServiceID: bar, Path: "/bar", End: 456 * time.Second.Nanoseconds()},
+	}
+	test.Eventually(t, timeout, func(t require.TestingT) {
+		exported := getMetrics(t, promURL)
+		assert.Contains(t, exported, `http_server_request_duration_seconds_sum{instance="foo-1",url_path="/foo"} 123`)
+		assert.Contains(t, exported, `http_server_request_duration_seconds_sum{instance="bar-1",url_path="/bar"} 456`)
+		assert.Contains(t, exported, `beyla_last_event_timestamp_seconds{instance="foo-1",job="foo",service="foo",service_namespace=""}`)
+		assert.Contains(t, exported, `beyla_last_event_timestamp_seconds{instance="bar-1",job="bar",service="bar",service_namespace=""}`)
+	})
+
+	// WHEN the process of a service instance ends
+	metrics <- []request.Span{{Type: request.EventTypeProcessDeleted, ServiceID: foo}}
+	// AND its spans that were in flight are received afterwards
+	metrics <- []request.Span{{Type: request.EventTypeHTTP, ServiceID: foo, Path: "/foo", End: 123 * time.Second.Nanoseconds()}}
+
+	// THEN its metrics are removed after the deleted service TTL
+	var exported string
+	test.Eventually(t, 2*timeout, func(t require.TestingT) {
+		now.Advance(time.Second)
+		exported = getMetrics(t, promURL)
+		assert.NotContains(t, exported, `instance="foo-1"`)
+	})
+	// BUT not the metrics of the other service instances
+	assert.Contains(t, exported, `http_server_request_duration_seconds_sum{instance="bar-1",url_path="/bar"} 456`)
+	assert.Contains(t, exported, `target_info{`)
+}
+
+func TestAppMetricsTargets(t *testing.T) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	openPort, err := test.FreeTCPPort()
+	require.NoError(t, err)
+	targetsURL := fmt.Sprintf("http://127.0.0.1:%d/api/v1/targets", openPort)
+
+	// GIVEN a Prometheus Metrics Exporter with the targets metadata endpoint enabled
+	exporter, err := PrometheusEndpoint(
+		ctx, &global.ContextInfo{Prometheus: &connector.PrometheusManager{}, HostID: "my-host"},
+		&PrometheusConfig{
+			Port:                        openPort,
+			Path:                        "/metrics",
+			TargetsPath:                 "/api/v1/targets",
+			TTL:                         3 * time.Minute,
+			SpanMetricsServiceCacheSize: 10,
+			Features:                    []string{otel.FeatureApplication},
+			Instrumentations:            []string{instrumentations.InstrumentationALL},
+		},
+		attributes.Selection{},
+	)()
+	require.NoError(t, err)
+
+	metrics := make(chan []request.Span, 20)
+	go exporter(metrics)
+
+	// WHEN it receives metrics from a service
+	metrics <- []request.Span{{
+		Type: request.EventTypeHTTP, Path: "/foo", End: 123 * time.Second.Nanoseconds(),
+		ServiceID: svc.ID{Name: "my-svc", Namespace: "my-ns", UID: "my-instance"},
+	}}
+
+	// THEN the service is listed as an active target of the metrics path
+	test.Eventually(t, timeout, func(t require.TestingT) {
+		resp, err := http.Get(targetsURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		targets := targetsResponse{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&targets))
+		assert.Equal(t, "success", targets.Status)
+		require.Len(t, targets.Data.ActiveTargets, 1)
+		target := targets.Data.ActiveTargets[0]
+		assert.Equal(t, fmt.Sprintf("http://127.0.0.1:%d/metrics", openPort), target.ScrapeURL)
+		assert.Equal(t, "my-svc", target.Labels[serviceKey])
+		assert.Equal(t, "my-ns", target.Labels[serviceNamespaceKey])
+		assert.Equal(t, "my-instance", target.Labels[serviceInstanceKey])
+		assert.Equal(t, "my-host", target.Labels[hostIDKey])
+	})
+}
+
+func TestAppMetricsServicePaths(t *testing.T) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	openPort, err := test.FreeTCPPort()
+	require.NoError(t, err)
+
+	// GIVEN a Prometheus Metrics Exporter that serves the metrics of each service in its own path
+	exporter, err := PrometheusEndpoint(
+		ctx, &global.ContextInfo{Prometheus: &connector.PrometheusManager{}, HostID: "my-host"},
+		&PrometheusConfig{
+			Port:                        openPort,
+			Path:                        "/metrics",
+			TargetsPath:                 "/api/v1/targets",
+			ServicePaths:                true,
+			TTL:                         3 * time.Minute,
+			SpanMetricsServiceCacheSize: 10,
+			Features:                    []string{otel.FeatureApplication},
+			Instrumentations:            []string{instrumentations.InstrumentationALL},
+		},
+		attributes.Selection{
+			attributes.HTTPServerDuration.Section: attributes.InclusionLists{
+				Include: []string{"url_path", "service_name", "service_namespace"},
+			},
+		},
+	)()
+	require.NoError(t, err)
+
+	metrics := make(chan []request.Span, 20)
+	go exporter(metrics)
+
+	// WHEN it receives metrics from services of different namespaces
+	metrics <- []request.Span{{
+		Type: request.EventTypeHTTP, Path: "/foo", End: 123 * time.Second.Nanoseconds(),
+		ServiceID: svc.ID{Name: "orders", Namespace: "shop", UID: "orders-1"},
+	}, {
+		Type: request.EventTypeHTTP, Path: "/bar", End: 456 * time.Second.Nanoseconds(),
+		ServiceID: svc.ID{Name: "orders", Namespace: "other", UID: "orders-2"},
+	}}
+
+	// THEN each service path only contains the metrics of its service
+	test.Eventually(t, timeout, func(t require.TestingT) {
+		exported := getMetrics(t, fmt.Sprintf("http://127.0.0.1:%d/metrics/shop/orders", openPort))
+		assert.Contains(t, exported, `url_path="/foo"`)
+		assert.NotContains(t, exported, `url_path="/bar"`)
+		assert.Contains(t, exported, "target_info{")
+		assert.NotContains(t, exported, "beyla_build_info")
+	})
+	exported := getMetrics(t, fmt.Sprintf("http://127.0.0.1:%d/metrics/other", openPort))
+	assert.Contains(t, exported, `url_path="/bar"`)
+	assert.NotContains(t, exported, `url_path="/foo"`)
+	// AND the main path contains the metrics of all the services
+	exported = getMetrics(t, fmt.Sprintf("http://127.0.0.1:%d/metrics", openPort))
+	assert.Contains(t, exported, `url_path="/foo"`)
+	assert.Contains(t, exported, `url_path="/bar"`)
+
+	// AND the scrape URLs of the targets point to the service paths
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/v1/targets", openPort))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	targets := targetsResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&targets))
+	var scrapeURLs []string
+	for _, target := range targets.Data.ActiveTargets {
+		scrapeURLs = append(scrapeURLs, target.ScrapeURL)
+	}
+	assert.ElementsMatch(t, []string{
+		fmt.Sprintf("http://127.0.0.1:%d/metrics/shop/orders", openPort),
+		fmt.Sprintf("http://127.0.0.1:%d/metrics/other/orders", openPort),
+	}, scrapeURLs)
+}
+
 type InstrTest struct {
 	name       string
 	instr      []string
@@ -130,6 +317,7 @@ func TestAppMetrics_ByInstrumentation(t *testing.T) {
 				"rpc_server_duration_seconds",
 				"rpc_client_duration_seconds",
 				"db_client_operation_duration_seconds",
+				"db_client_redis_redirects_total",
 				"messaging_publish_duration_seconds",
 				"messaging_process_duration_seconds",
 			},
@@ -171,6 +359,7 @@ func TestAppMetrics_ByInstrumentation(t *testing.T) {
 			expected: []string{
 				"db_client_operation_duration_seconds",
 				"db_client_operation_duration_seconds",
+				`db_client_redis_redirects_total{db_operation_name="SET",db_redis_redirect_type="MOVED"`,
 			},
 			unexpected: []string{
 				"http_server_request_duration_seconds",
@@ -221,6 +410,7 @@ func TestAppMetrics_ByInstrumentation(t *testing.T) {
 				"rpc_server_duration_seconds",
 				"rpc_client_duration_seconds",
 				"db_client_operation_duration_seconds",
+				"db_client_redis_redirects_total",
 				"messaging_publish_duration_seconds",
 				"messaging_process_duration_seconds",
 			},
@@ -279,7 +469,8 @@ func TestAppMetrics_ByInstrumentation(t *testing.T) {
 				{ServiceID: svc.ID{UID: "foo"}, Type: request.EventTypeGRPC, Path: "/foo", RequestStart: 100, End: 200},
 				{ServiceID: svc.ID{UID: "foo"}, Type: request.EventTypeGRPCClient, Path: "/bar", RequestStart: 150, End: 175},
 				{ServiceID: svc.ID{UID: "foo"}, Type: request.EventTypeSQLClient, Path: "SELECT", RequestStart: 150, End: 175},
-				{ServiceID: svc.ID{UID: "foo"}, Type: request.EventTypeRedisClient, Method: "SET", RequestStart: 150, End: 175},
+				{ServiceID: svc.ID{UID: "foo"}, Type: request.EventTypeRedisClient, Method: "SET", RequestStart: 150, End: 175,
+					RedisRedirect: &request.RedisRedirect{Type: "MOVED", Slot: 3999, Address: "10.0.0.3:6379"}},
 				{ServiceID: svc.ID{UID: "foo"}, Type: request.EventTypeRedisServer, Method: "GET", RequestStart: 150, End: 175},
 				{ServiceID: svc.ID{UID: "foo"}, Type: request.EventTypeKafkaClient, Method: "publish", RequestStart: 150, End: 175},
 				{ServiceID: svc.ID{UID: "foo"}, Type: request.EventTypeKafkaServer, Method: "process", RequestStart: 150, End: 175},
@@ -0,0 +1,78 @@
+package prom
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultScrapePool = "beyla"
+
+// targetsResponse mimics the response of the Prometheus /api/v1/targets endpoint,
+// so the existing tooling can generate service discovery configurations from it
+type targetsResponse struct {
+	Status string      `json:"status"`
+	Data   targetsData `json:"data"`
+}
+
+type targetsData struct {
+	ActiveTargets []activeTarget `json:"activeTargets"`
+}
+
+type activeTarget struct {
+	DiscoveredLabels map[string]string `json:"discoveredLabels"`
+	Labels           map[string]string `json:"labels"`
+	ScrapePool       string            `json:"scrapePool"`
+	ScrapeURL        string            `json:"scrapeUrl"`
+	Health           string            `json:"health"`
+}
+
+// targetsHandler lists, as Prometheus targets, the instrumented services whose
+// application metrics are currently exposed in the metrics path. It takes them
+// from the target_info metric, so they expire with the same TTL as the metrics.
+type targetsHandler struct {
+	metricsPath string
+	// servicePaths makes the scrape URLs to point to the per-service sub-paths of the metrics path
+	servicePaths bool
+	labelNames   []string
+	targetInfo   *Expirer[prometheus.Gauge]
+}
+
+func (th *targetsHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	metricsURL := "http://" + req.Host + th.metricsPath
+	resp := targetsResponse{
+		Status: "success",
+		Data:   targetsData{ActiveTargets: []activeTarget{}},
+	}
+	for _, entry := range th.targetInfo.entries.All() {
+		labels := make(map[string]string, len(th.labelNames))
+		for i, name := range th.labelNames {
+			if i < len(entry.labelVals) && entry.labelVals[i] != "" {
+				labels[name] = entry.labelVals[i]
+			}
+		}
+		resp.Data.ActiveTargets = append(resp.Data.ActiveTargets, activeTarget{
+			DiscoveredLabels: map[string]string{},
+			Labels:           labels,
+			ScrapePool:       defaultScrapePool,
+			ScrapeURL:        th.scrapeURL(metricsURL, labels),
+			Health:           "up",
+		})
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		slog.With("component", "prom.targetsHandler").Debug("can't write targets response", "error", err)
+	}
+}
+
+func (th *targetsHandler) scrapeURL(metricsURL string, labels map[string]string) string {
+	namespace, service := labels[serviceNamespaceKey], labels[serviceKey]
+	if !th.servicePaths || namespace == "" || service == "" {
+		return metricsURL
+	}
+	return strings.TrimSuffix(metricsURL, "/") + "/" + url.PathEscape(namespace) + "/" + url.PathEscape(service)
+}
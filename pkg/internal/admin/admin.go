@@ -0,0 +1,240 @@
+// Package admin provides a local HTTP API to inspect and control a running Beyla instance
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// Config of the administration API
+type Config struct {
+	// Port where the administration API listens. It only accepts connections from the loopback
+	// interface. 0 (default) disables the API.
+	Port int `yaml:"port" env:"BEYLA_ADMIN_PORT"`
+}
+
+func (c *Config) Enabled() bool {
+	return c != nil && c.Port != 0
+}
+
+func alog() *slog.Logger {
+	return slog.With("component", "admin.Server")
+}
+
+// Process is an instrumented process, as reported by the administration API
+type Process struct {
+	PID              int32  `json:"pid"`
+	ServiceName      string `json:"service_name"`
+	ServiceNamespace string `json:"service_namespace,omitempty"`
+	Language         string `json:"language"`
+	// Tracer is the type of the eBPF tracer whose probes are attached to the process: go or generic
+	Tracer string `json:"tracer"`
+	// Probes are the functions of the executable and its libraries where the tracer attached
+	// uprobes. The kernel probes are shared by all the processes, so they are not listed.
+	Probes []string `json:"probes,omitempty"`
+}
+
+// Sampling is the trace sampling ratio that overrides the configured sampler. A nil Ratio
+// restores the configured sampler.
+type Sampling struct {
+	Ratio *float64 `json:"ratio"`
+}
+
+// Server of the administration API. All its methods can be invoked on a nil Server, which
+// represents a disabled API, so the instrumented components don't need to check it.
+type Server struct {
+	cfg   *Config
+	stats pipelineStats
+
+	mt        sync.Mutex
+	processes map[int32]Process
+	samplers  []func(ratio *float64)
+	sampling  Sampling
+
+	detach chan int32
+}
+
+// New returns the administration API server, or nil if it is disabled
+func New(cfg *Config) *Server {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &Server{
+		cfg:       cfg,
+		stats:     pipelineStats{dropped: map[string]uint64{}, queues: map[string]queue{}},
+		processes: map[int32]Process{},
+		detach:    make(chan int32, 10),
+	}
+}
+
+// Start listening in the background, until the context is cancelled
+func (s *Server) Start(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	server := &http.Server{
+		Addr:              fmt.Sprintf("127.0.0.1:%d", s.cfg.Port),
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log := alog().With("port", s.cfg.Port)
+	go func() {
+		log.Info("listening for administration requests")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("administration API stopped", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			log.Debug("error closing administration API", "error", err)
+		}
+	}()
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/processes", s.listProcesses)
+	mux.HandleFunc("DELETE /api/v1/processes/{pid}", s.detachProcess)
+	mux.HandleFunc("GET /api/v1/pipeline", s.pipelineStats)
+	mux.HandleFunc("GET /api/v1/sampling", s.getSampling)
+	mux.HandleFunc("PUT /api/v1/sampling", s.setSampling)
+	return mux
+}
+
+// ProcessAttached records a process whose spans are reported by the given tracer type, with the
+// functions where the tracer attached probes
+func (s *Server) ProcessAttached(pid int32, service *svc.ID, tracer string, probes []string) {
+	if s == nil {
+		return
+	}
+	s.mt.Lock()
+	defer s.mt.Unlock()
+	s.processes[pid] = Process{
+		PID:              pid,
+		ServiceName:      service.Name,
+		ServiceNamespace: service.Namespace,
+		Language:         service.SDKLanguage.String(),
+		Tracer:           tracer,
+		Probes:           probes,
+	}
+}
+
+// ProcessDetached forgets a process that ended, or whose instrumentation was removed
+func (s *Server) ProcessDetached(pid int32) {
+	if s == nil {
+		return
+	}
+	s.mt.Lock()
+	defer s.mt.Unlock()
+	delete(s.processes, pid)
+}
+
+// DetachRequests returns the PIDs of the processes whose instrumentation is requested to be
+// removed. A nil Server returns a nil channel, which never receives anything.
+func (s *Server) DetachRequests() <-chan int32 {
+	if s == nil {
+		return nil
+	}
+	return s.detach
+}
+
+// OnSampling registers a function that is invoked with the sampling ratio when it is changed
+// through the API. A nil ratio restores the configured sampler.
+func (s *Server) OnSampling(set func(ratio *float64)) {
+	if s == nil {
+		return
+	}
+	s.mt.Lock()
+	defer s.mt.Unlock()
+	s.samplers = append(s.samplers, set)
+	set(s.sampling.Ratio)
+}
+
+func (s *Server) listProcesses(rw http.ResponseWriter, _ *http.Request) {
+	s.mt.Lock()
+	processes := make([]Process, 0, len(s.processes))
+	for _, p := range s.processes {
+		processes = append(processes, p)
+	}
+	s.mt.Unlock()
+	slices.SortFunc(processes, func(a, b Process) int { return int(a.PID - b.PID) })
+	writeJSON(rw, processes)
+}
+
+func (s *Server) detachProcess(rw http.ResponseWriter, req *http.Request) {
+	pid, err := strconv.ParseInt(req.PathValue("pid"), 10, 32)
+	if err != nil {
+		http.Error(rw, "invalid PID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mt.Lock()
+	_, ok := s.processes[int32(pid)]
+	s.mt.Unlock()
+	if !ok {
+		http.Error(rw, "process not instrumented", http.StatusNotFound)
+		return
+	}
+	select {
+	case s.detach <- int32(pid):
+		rw.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(rw, "too many pending detach requests", http.StatusServiceUnavailable)
+	}
+}
+
+func (s *Server) pipelineStats(rw http.ResponseWriter, _ *http.Request) {
+	writeJSON(rw, s.stats.snapshot())
+}
+
+func (s *Server) getSampling(rw http.ResponseWriter, _ *http.Request) {
+	s.mt.Lock()
+	sampling := s.sampling
+	s.mt.Unlock()
+	writeJSON(rw, sampling)
+}
+
+func (s *Server) setSampling(rw http.ResponseWriter, req *http.Request) {
+	sampling := Sampling{}
+	if err := json.NewDecoder(req.Body).Decode(&sampling); err != nil {
+		http.Error(rw, "invalid sampling: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if sampling.Ratio != nil && (*sampling.Ratio < 0 || *sampling.Ratio > 1) {
+		http.Error(rw, "the sampling ratio must be between 0 and 1", http.StatusBadRequest)
+		return
+	}
+	s.mt.Lock()
+	defer s.mt.Unlock()
+	if len(s.samplers) == 0 {
+		http.Error(rw, "the traces exporter is not enabled", http.StatusConflict)
+		return
+	}
+	s.sampling = sampling
+	for _, set := range s.samplers {
+		set(sampling.Ratio)
+	}
+	if sampling.Ratio == nil {
+		alog().Info("trace sampling restored to the configured sampler")
+	} else {
+		alog().Info("trace sampling changed through the administration API", "ratio", *sampling.Ratio)
+	}
+	writeJSON(rw, sampling)
+}
+
+func writeJSON(rw http.ResponseWriter, body any) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(body); err != nil {
+		alog().Debug("error writing response", "error", err)
+	}
+}
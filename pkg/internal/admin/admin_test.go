@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func request(t *testing.T, s *Server, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	s.handler().ServeHTTP(rw, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rw
+}
+
+func TestDisabled(t *testing.T) {
+	s := New(&Config{})
+	require.Nil(t, s)
+	// a disabled server can be used without checking it
+	s.ProcessAttached(1, &svc.ID{}, "go", nil)
+	s.ProcessDetached(1)
+	s.OnSampling(func(_ *float64) {})
+	s.Queue("tracers", func() int { return 0 }, 10)
+	assert.Nil(t, s.DetachRequests())
+	reporter := imetrics.NoopReporter{}
+	assert.Equal(t, reporter, s.Reporter(reporter))
+}
+
+func TestProcesses(t *testing.T) {
+	s := New(&Config{Port: 1234})
+	s.ProcessAttached(30, &svc.ID{Name: "shop", Namespace: "prod", SDKLanguage: svc.InstrumentableJava}, "generic",
+		[]string{"SSL_read", "SSL_write"})
+	s.ProcessAttached(20, &svc.ID{Name: "users", SDKLanguage: svc.InstrumentableGolang}, "go",
+		[]string{"net/http.serverHandler.ServeHTTP"})
+
+	rw := request(t, s, http.MethodGet, "/api/v1/processes", "")
+	require.Equal(t, http.StatusOK, rw.Code)
+	var processes []Process
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &processes))
+	assert.Equal(t, []Process{
+		{PID: 20, ServiceName: "users", Language: "go", Tracer: "go",
+			Probes: []string{"net/http.serverHandler.ServeHTTP"}},
+		{PID: 30, ServiceName: "shop", ServiceNamespace: "prod", Language: "java", Tracer: "generic",
+			Probes: []string{"SSL_read", "SSL_write"}},
+	}, processes)
+
+	// the detach requests are forwarded to the attacher
+	rw = request(t, s, http.MethodDelete, "/api/v1/processes/30", "")
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+	assert.Equal(t, int32(30), <-s.DetachRequests())
+
+	rw = request(t, s, http.MethodDelete, "/api/v1/processes/40", "")
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	rw = request(t, s, http.MethodDelete, "/api/v1/processes/foo", "")
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	s.ProcessDetached(30)
+	rw = request(t, s, http.MethodGet, "/api/v1/processes", "")
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &processes))
+	assert.Equal(t, []Process{{PID: 20, ServiceName: "users", Language: "go", Tracer: "go",
+		Probes: []string{"net/http.serverHandler.ServeHTTP"}}}, processes)
+}
+
+func TestSampling(t *testing.T) {
+	s := New(&Config{Port: 1234})
+
+	// no traces exporter
+	rw := request(t, s, http.MethodPut, "/api/v1/sampling", `{"ratio":0.5}`)
+	assert.Equal(t, http.StatusConflict, rw.Code)
+
+	var ratio *float64
+	s.OnSampling(func(r *float64) { ratio = r })
+	assert.Nil(t, ratio)
+
+	rw = request(t, s, http.MethodPut, "/api/v1/sampling", `{"ratio":0.5}`)
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.NotNil(t, ratio)
+	assert.Equal(t, 0.5, *ratio)
+
+	rw = request(t, s, http.MethodGet, "/api/v1/sampling", "")
+	assert.JSONEq(t, `{"ratio":0.5}`, rw.Body.String())
+
+	rw = request(t, s, http.MethodPut, "/api/v1/sampling", `{"ratio":1.5}`)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	rw = request(t, s, http.MethodPut, "/api/v1/sampling", `{"ratio":`)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, 0.5, *ratio)
+
+	// restoring the configured sampler
+	rw = request(t, s, http.MethodPut, "/api/v1/sampling", `{"ratio":null}`)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Nil(t, ratio)
+}
+
+func TestPipelineStats(t *testing.T) {
+	s := New(&Config{Port: 1234})
+	reporter := s.Reporter(imetrics.NoopReporter{})
+	reporter.TracerFlush(10)
+	reporter.TracerFlush(5)
+	reporter.SpansDropped(imetrics.DropReasonQueueFull, 3, nil)
+	reporter.OTELTracesDegraded(true)
+	tracers := make(chan []svc.ID, 10)
+	tracers <- nil
+	tracers <- nil
+	s.Queue("tracers", func() int { return len(tracers) }, cap(tracers))
+
+	rw := request(t, s, http.MethodGet, "/api/v1/pipeline", "")
+	require.Equal(t, http.StatusOK, rw.Code)
+	var stats PipelineStats
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &stats))
+	assert.Equal(t, PipelineStats{
+		TracerFlushes:  2,
+		TracerSpans:    15,
+		DroppedSpans:   map[string]uint64{imetrics.DropReasonQueueFull: 3},
+		TracesDegraded: true,
+		Queues:         map[string]QueueStats{"tracers": {Length: 2, Capacity: 10}},
+	}, stats)
+}
@@ -0,0 +1,111 @@
+package admin
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+)
+
+// PipelineStats summarizes the flow of spans through the pipeline since Beyla started
+type PipelineStats struct {
+	// TracerFlushes is the number of batches that the eBPF tracers forwarded to the pipeline
+	TracerFlushes uint64 `json:"tracer_flushes"`
+	// TracerSpans is the number of spans in those batches
+	TracerSpans uint64 `json:"tracer_spans"`
+	// DroppedSpans by reason (see the imetrics.DropReason* constants)
+	DroppedSpans map[string]uint64 `json:"dropped_spans"`
+	// TracesDegraded is true while the traces are exported in a degraded mode
+	TracesDegraded bool `json:"traces_degraded"`
+	// Queues between the stages of the pipeline, by name
+	Queues map[string]QueueStats `json:"queues"`
+}
+
+// QueueStats is the current occupancy of a queue of the pipeline
+type QueueStats struct {
+	// Length is the number of span batches in the queue
+	Length int `json:"length"`
+	// Capacity is the maximum number of span batches in the queue
+	Capacity int `json:"capacity"`
+}
+
+type queue struct {
+	length   func() int
+	capacity int
+}
+
+type pipelineStats struct {
+	mt       sync.Mutex
+	flushes  uint64
+	spans    uint64
+	dropped  map[string]uint64
+	degraded bool
+	queues   map[string]queue
+}
+
+func (ps *pipelineStats) snapshot() PipelineStats {
+	ps.mt.Lock()
+	defer ps.mt.Unlock()
+	stats := PipelineStats{
+		TracerFlushes:  ps.flushes,
+		TracerSpans:    ps.spans,
+		DroppedSpans:   make(map[string]uint64, len(ps.dropped)),
+		TracesDegraded: ps.degraded,
+		Queues:         make(map[string]QueueStats, len(ps.queues)),
+	}
+	for reason, count := range ps.dropped {
+		stats.DroppedSpans[reason] = count
+	}
+	for name, q := range ps.queues {
+		stats.Queues[name] = QueueStats{Length: q.length(), Capacity: q.capacity}
+	}
+	return stats
+}
+
+// Queue registers a queue of the pipeline, whose occupancy is returned by the length function
+func (s *Server) Queue(name string, length func() int, capacity int) {
+	if s == nil {
+		return
+	}
+	s.stats.mt.Lock()
+	defer s.stats.mt.Unlock()
+	s.stats.queues[name] = queue{length: length, capacity: capacity}
+}
+
+// statsReporter accumulates the pipeline stats while forwarding all the internal metrics
+// to the decorated reporter
+type statsReporter struct {
+	imetrics.Reporter
+	stats *pipelineStats
+}
+
+// Reporter decorates the internal metrics reporter to accumulate the pipeline stats that are
+// exposed by the API. A nil Server returns the same reporter.
+func (s *Server) Reporter(reporter imetrics.Reporter) imetrics.Reporter {
+	if s == nil {
+		return reporter
+	}
+	return &statsReporter{Reporter: reporter, stats: &s.stats}
+}
+
+func (r *statsReporter) TracerFlush(len int) {
+	r.stats.mt.Lock()
+	r.stats.flushes++
+	r.stats.spans += uint64(len)
+	r.stats.mt.Unlock()
+	r.Reporter.TracerFlush(len)
+}
+
+func (r *statsReporter) SpansDropped(reason string, count int, example fmt.Stringer) {
+	r.stats.mt.Lock()
+	r.stats.dropped[reason] += uint64(count)
+	r.stats.mt.Unlock()
+	r.Reporter.SpansDropped(reason, count, example)
+}
+
+func (r *statsReporter) OTELTracesDegraded(degraded bool) {
+	r.stats.mt.Lock()
+	r.stats.degraded = degraded
+	r.stats.mt.Unlock()
+	r.Reporter.OTELTracesDegraded(degraded)
+}
@@ -34,11 +34,13 @@ type Instrumenter struct {
 // New Instrumenter, given a Config
 func New(ctx context.Context, ctxInfo *global.ContextInfo, config *beyla.Config) *Instrumenter {
 	setupFeatureContextInfo(ctx, ctxInfo, config)
+	tracesInput := make(chan []request.Span, config.ChannelBufferLen)
+	ctxInfo.Admin.Queue("tracers", func() int { return len(tracesInput) }, cap(tracesInput))
 	return &Instrumenter{
 		ctx:         ctx,
 		config:      config,
 		ctxInfo:     ctxInfo,
-		tracesInput: make(chan []request.Span, config.ChannelBufferLen),
+		tracesInput: tracesInput,
 	}
 }
 
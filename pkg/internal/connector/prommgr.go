@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"syscall"
 
@@ -27,18 +28,35 @@ type PrometheusManager struct {
 	started atomic.Bool
 	// key 1: port. Key 2: path
 	registries map[int]map[string]*prometheus.Registry
+	// key 1: port. Key 2: path
+	handlers map[int]map[string]http.Handler
+	// key 1: port. Key 2: path
+	servicePaths map[int]map[string]struct{}
+	// key 1: port. Key 2: path
+	openMetricsPaths map[int]map[string]struct{}
 
 	metrics internalIntrumenter
+	renamer metricRenamer
 }
 
 type internalIntrumenter interface {
 	PrometheusRequest(port, path string)
 }
 
+type metricRenamer interface {
+	MetricProm(name string) string
+	AttributeProm(label string) string
+}
+
 func (pm *PrometheusManager) InstrumentWith(ii internalIntrumenter) {
 	pm.metrics = ii
 }
 
+// RenameWith makes the scraped metric names and labels to be renamed by the passed renamer
+func (pm *PrometheusManager) RenameWith(r metricRenamer) {
+	pm.renamer = r
+}
+
 // Register a set of prometheus metrics to be accessible through an HTTP port/path.
 // This method is not thread-safe
 func (pm *PrometheusManager) Register(port int, path string, collectors ...prometheus.Collector) {
@@ -60,6 +78,55 @@ func (pm *PrometheusManager) Register(port int, path string, collectors ...prome
 	reg.MustRegister(collectors...)
 }
 
+// RegisterHandler makes an arbitrary HTTP handler accessible through the same port as the
+// Prometheus metrics. The path must not be used by any registered set of metrics.
+// This method is not thread-safe
+func (pm *PrometheusManager) RegisterHandler(port int, path string, handler http.Handler) {
+	log().Debug("registering HTTP handler", "port", port, "path", path)
+	if pm.handlers == nil {
+		pm.handlers = map[int]map[string]http.Handler{}
+	}
+	paths, ok := pm.handlers[port]
+	if !ok {
+		paths = map[string]http.Handler{}
+		pm.handlers[port] = paths
+	}
+	paths[path] = handler
+}
+
+// ServeServicePaths makes the metrics that are registered in a port/path to be also accessible,
+// filtered by service, through the <path>/<namespace>/<service> and <path>/<namespace> sub-paths.
+// This method is not thread-safe
+func (pm *PrometheusManager) ServeServicePaths(port int, path string) {
+	log().Debug("serving per-service metrics sub-paths", "port", port, "path", path)
+	if pm.servicePaths == nil {
+		pm.servicePaths = map[int]map[string]struct{}{}
+	}
+	paths, ok := pm.servicePaths[port]
+	if !ok {
+		paths = map[string]struct{}{}
+		pm.servicePaths[port] = paths
+	}
+	paths[path] = struct{}{}
+}
+
+// ServeOpenMetrics makes the metrics that are registered in a port/path to be served in the OpenMetrics
+// format, which includes the exemplars, when the scraper negotiates it. Other paths are always served in
+// the Prometheus text format.
+// This method is not thread-safe
+func (pm *PrometheusManager) ServeOpenMetrics(port int, path string) {
+	log().Debug("serving OpenMetrics format", "port", port, "path", path)
+	if pm.openMetricsPaths == nil {
+		pm.openMetricsPaths = map[int]map[string]struct{}{}
+	}
+	paths, ok := pm.openMetricsPaths[port]
+	if !ok {
+		paths = map[string]struct{}{}
+		pm.openMetricsPaths[port] = paths
+	}
+	paths[path] = struct{}{}
+}
+
 // StartHTTP serves metrics in background. Its invocation won't have effect if it has been invoked previously,
 // so invoke it only after you are sure that all the collectors have been registered via the Register method.
 func (pm *PrometheusManager) StartHTTP(ctx context.Context) {
@@ -68,19 +135,70 @@ func (pm *PrometheusManager) StartHTTP(ctx context.Context) {
 	}
 	log := log()
 	// Creating a serve mux for each port
+	muxes := map[int]*http.ServeMux{}
 	for port, paths := range pm.registries {
 		mux := http.NewServeMux()
+		muxes[port] = mux
 		for path, registry := range paths {
 			log.With("port", port, "path", path).Info("opening prometheus scrape endpoint")
-			promHandler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
-			promHandler = wrapDebugHandler(log, promHandler)
-			promHandler = wrapInstrumentedHandler(pm.metrics, port, path, promHandler)
-			mux.Handle(path, promHandler)
+			_, openMetrics := pm.openMetricsPaths[port][path]
+			mux.Handle(path, pm.metricsHandler(log, port, path, registry, registry, openMetrics))
+			if _, ok := pm.servicePaths[port][path]; ok {
+				subPath := strings.TrimSuffix(path, "/") + "/"
+				log.With("port", port, "path", subPath).Info("opening per-service prometheus scrape endpoints")
+				mux.Handle(subPath, pm.servicePathsHandler(log, port, subPath, registry, openMetrics))
+			}
+		}
+	}
+	for port, paths := range pm.handlers {
+		mux, ok := muxes[port]
+		if !ok {
+			mux = http.NewServeMux()
+			muxes[port] = mux
 		}
+		for path, handler := range paths {
+			log.With("port", port, "path", path).Info("opening HTTP endpoint")
+			mux.Handle(path, wrapDebugHandler(log, handler))
+		}
+	}
+	for port, mux := range muxes {
 		pm.listenAndServe(ctx, port, mux)
 	}
 }
 
+func (pm *PrometheusManager) metricsHandler(
+	log *slog.Logger, port int, path string, registry *prometheus.Registry, gatherer prometheus.Gatherer, openMetrics bool,
+) http.Handler {
+	if pm.renamer != nil {
+		gatherer = &renamingGatherer{Gatherer: gatherer, renamer: pm.renamer}
+	}
+	promHandler := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		Registry:          registry,
+		EnableOpenMetrics: openMetrics,
+	})
+	promHandler = wrapDebugHandler(log, promHandler)
+	return wrapInstrumentedHandler(pm.metrics, port, path, promHandler)
+}
+
+// servicePathsHandler serves the metrics of a registry that belong to the namespace and, optionally,
+// the service that are specified in the sub-path
+func (pm *PrometheusManager) servicePathsHandler(
+	log *slog.Logger, port int, subPath string, registry *prometheus.Registry, openMetrics bool,
+) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, subPath), "/"), "/")
+		if len(parts) > 2 || parts[0] == "" {
+			http.NotFound(rw, req)
+			return
+		}
+		filter := &serviceGatherer{Gatherer: registry, namespace: parts[0]}
+		if len(parts) == 2 {
+			filter.service = parts[1]
+		}
+		pm.metricsHandler(log, port, subPath, registry, filter, openMetrics).ServeHTTP(rw, req)
+	}
+}
+
 func wrapInstrumentedHandler(metrics internalIntrumenter, port int, path string, promHandler http.Handler) http.HandlerFunc {
 	// we don't wrap anything if the reporter is nil
 	if metrics == nil {
@@ -0,0 +1,28 @@
+package connector
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler_OpenMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "requests_total"}))
+	pm := &PrometheusManager{}
+
+	scrape := func(openMetrics bool) string {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		rw := httptest.NewRecorder()
+		pm.metricsHandler(slog.Default(), 8999, "/metrics", reg, reg, openMetrics).ServeHTTP(rw, req)
+		return rw.Header().Get("Content-Type")
+	}
+
+	assert.Contains(t, scrape(true), "application/openmetrics-text")
+	assert.Contains(t, scrape(false), "text/plain")
+}
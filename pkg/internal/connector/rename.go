@@ -0,0 +1,43 @@
+package connector
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// renamingGatherer renames the metric families and labels of the wrapped Gatherer
+// before they are exposed to the scrapers
+type renamingGatherer struct {
+	prometheus.Gatherer
+	renamer metricRenamer
+}
+
+func (rg *renamingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := rg.Gatherer.Gather()
+	// the Gatherer can return partial results together with the error
+	for _, mf := range families {
+		if mf.Name != nil {
+			name := rg.renamer.MetricProm(*mf.Name)
+			mf.Name = &name
+		}
+		for _, m := range mf.Metric {
+			for _, lp := range m.Label {
+				if lp.Name != nil {
+					label := rg.renamer.AttributeProm(*lp.Name)
+					lp.Name = &label
+				}
+			}
+			// metrics are expected to have their labels sorted by name
+			slices.SortFunc(m.Label, func(a, b *dto.LabelPair) int {
+				return strings.Compare(a.GetName(), b.GetName())
+			})
+		}
+	}
+	slices.SortFunc(families, func(a, b *dto.MetricFamily) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	return families, err
+}
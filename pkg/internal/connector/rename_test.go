@@ -0,0 +1,42 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRenamer map[string]string
+
+func (f fakeRenamer) rename(name string) string {
+	if newName, ok := f[name]; ok {
+		return newName
+	}
+	return name
+}
+
+func (f fakeRenamer) MetricProm(name string) string     { return f.rename(name) }
+func (f fakeRenamer) AttributeProm(label string) string { return f.rename(label) }
+
+func TestRenamingGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"a_label", "status"})
+	counter.WithLabelValues("foo", "200").Inc()
+	reg.MustRegister(counter, prometheus.NewGauge(prometheus.GaugeOpts{Name: "other"}))
+
+	rg := renamingGatherer{Gatherer: reg, renamer: fakeRenamer{
+		"requests_total": "calls_total",
+		"status":         "code",
+	}}
+	families, err := rg.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 2)
+	assert.Equal(t, "calls_total", families[0].GetName())
+	assert.Equal(t, "other", families[1].GetName())
+	labels := families[0].Metric[0].Label
+	require.Len(t, labels, 2)
+	assert.Equal(t, "a_label", labels[0].GetName())
+	assert.Equal(t, "code", labels[1].GetName())
+}
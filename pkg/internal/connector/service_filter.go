@@ -0,0 +1,55 @@
+package connector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// the application metrics are labeled with service_name, while
+	// target_info and the span metrics are labeled with service
+	serviceNameLabel      = "service_name"
+	serviceLabel          = "service"
+	serviceNamespaceLabel = "service_namespace"
+)
+
+// serviceGatherer only returns the metrics of the wrapped Gatherer that belong to the given
+// namespace and, if it is not empty, to the given service.
+// The metrics that aren't labeled with a service namespace are discarded.
+type serviceGatherer struct {
+	prometheus.Gatherer
+	namespace string
+	service   string
+}
+
+func (sg *serviceGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := sg.Gatherer.Gather()
+	// the Gatherer can return partial results together with the error
+	filtered := families[:0]
+	for _, mf := range families {
+		metrics := mf.Metric[:0]
+		for _, m := range mf.Metric {
+			if sg.matches(m) {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			mf.Metric = metrics
+			filtered = append(filtered, mf)
+		}
+	}
+	return filtered, err
+}
+
+func (sg *serviceGatherer) matches(m *dto.Metric) bool {
+	namespaceMatches, serviceMatches := false, sg.service == ""
+	for _, lp := range m.Label {
+		switch lp.GetName() {
+		case serviceNamespaceLabel:
+			namespaceMatches = lp.GetValue() == sg.namespace
+		case serviceNameLabel, serviceLabel:
+			serviceMatches = serviceMatches || lp.GetValue() == sg.service
+		}
+	}
+	return namespaceMatches && serviceMatches
+}
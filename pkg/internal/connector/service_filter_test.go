@@ -0,0 +1,46 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"},
+		[]string{"service_name", "service_namespace"})
+	requests.WithLabelValues("orders", "shop").Inc()
+	requests.WithLabelValues("payments", "shop").Inc()
+	requests.WithLabelValues("orders", "other").Inc()
+	targetInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "target_info"},
+		[]string{"service", "service_namespace"})
+	targetInfo.WithLabelValues("payments", "shop").Set(1)
+	reg.MustRegister(requests, targetInfo, prometheus.NewGauge(prometheus.GaugeOpts{Name: "no_service"}))
+
+	t.Run("namespace and service", func(t *testing.T) {
+		families, err := (&serviceGatherer{Gatherer: reg, namespace: "shop", service: "orders"}).Gather()
+		require.NoError(t, err)
+		require.Len(t, families, 1)
+		assert.Equal(t, "requests_total", families[0].GetName())
+		require.Len(t, families[0].Metric, 1)
+		assert.Equal(t, "orders", families[0].Metric[0].Label[0].GetValue())
+		assert.Equal(t, "shop", families[0].Metric[0].Label[1].GetValue())
+	})
+	t.Run("namespace", func(t *testing.T) {
+		families, err := (&serviceGatherer{Gatherer: reg, namespace: "shop"}).Gather()
+		require.NoError(t, err)
+		require.Len(t, families, 2)
+		assert.Equal(t, "requests_total", families[0].GetName())
+		assert.Len(t, families[0].Metric, 2)
+		assert.Equal(t, "target_info", families[1].GetName())
+		assert.Len(t, families[1].Metric, 1)
+	})
+	t.Run("unknown service", func(t *testing.T) {
+		families, err := (&serviceGatherer{Gatherer: reg, namespace: "shop", service: "unknown"}).Gather()
+		require.NoError(t, err)
+		assert.Empty(t, families)
+	})
+}
@@ -4,14 +4,17 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/cilium/ebpf/link"
 	"github.com/mariomac/pipes/pipe"
 
 	"github.com/grafana/beyla/pkg/beyla"
+	"github.com/grafana/beyla/pkg/internal/admin"
 	"github.com/grafana/beyla/pkg/internal/ebpf"
 	"github.com/grafana/beyla/pkg/internal/helpers/maps"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/overhead"
 	"github.com/grafana/beyla/pkg/internal/request"
 	"github.com/grafana/beyla/pkg/internal/svc"
 )
@@ -26,7 +29,9 @@ type TraceAttacher struct {
 	DiscoveredTracers chan *ebpf.Instrumentable
 	DeleteTracers     chan *ebpf.Instrumentable
 	Metrics           imetrics.Reporter
-	beylaPID          int
+	// Admin API, which can request removing the instrumentation of a process. It can be nil
+	Admin    *admin.Server
+	beylaPID int
 
 	// processInstances keeps track of the instances of each process. This will help making sure
 	// that we don't remove the BPF resources of an executable until all their instances are removed
@@ -39,6 +44,18 @@ type TraceAttacher struct {
 	reusableGoTracer    *ebpf.ProcessTracer
 	commonTracersLoaded bool
 
+	// process instances whose loaded libraries are periodically re-scanned. Key: PID
+	libsInstances map[int32]*libsInstance
+
+	// instrumented processes that can be detached through the admin API. Key: PID
+	instrumented map[int32]*ebpf.Instrumentable
+	// detached processes, whose deletion must be ignored as their instrumentation was already removed
+	detached map[int32]struct{}
+
+	// selfInstrumenter allows the overhead benchmark to instrument the Beyla process
+	// through the generic tracer, once it is loaded
+	selfInstrumenter *selfInstrumenter
+
 	// Usually, only ebpf.Tracer implementations will send spans data to the read decorator.
 	// But on each new process, we will send a "process alive" span type to the read decorator, whose
 	// unique purpose is to notify other parts of the system that this process is active, even
@@ -55,6 +72,8 @@ func (ta *TraceAttacher) attacherLoop() (pipe.FinalFunc[[]Event[ebpf.Instrumenta
 	ta.log = slog.With("component", "discover.TraceAttacher")
 	ta.existingTracers = map[uint64]*ebpf.ProcessTracer{}
 	ta.processInstances = maps.MultiCounter[uint64]{}
+	ta.instrumented = map[int32]*ebpf.Instrumentable{}
+	ta.detached = map[int32]struct{}{}
 	ta.beylaPID = os.Getpid()
 
 	if err := ta.init(); err != nil {
@@ -62,9 +81,42 @@ func (ta *TraceAttacher) attacherLoop() (pipe.FinalFunc[[]Event[ebpf.Instrumenta
 		return nil, err
 	}
 
+	if ta.Cfg.InternalMetrics.BPFStats.Enable {
+		go ebpf.ReportProgramStats(ta.Ctx, ta.Metrics, ta.Cfg.InternalMetrics.BPFStats.Interval)
+	}
+
+	if ta.Cfg.EBPF.MapSizes.FullCheckInterval > 0 {
+		go ebpf.ReportMapStats(ta.Ctx, ta.Metrics, ta.Cfg.EBPF.MapSizes.FullCheckInterval)
+	}
+
+	if ta.Cfg.InternalMetrics.OverheadBenchmark.Enable {
+		ta.startOverheadBenchmark()
+	}
+
 	return func(in <-chan []Event[ebpf.Instrumentable]) {
+		// a nil channel disables the libraries re-scan
+		var rescan <-chan time.Time
+		if ta.Cfg.Discovery.LibraryRescanInterval > 0 {
+			ticker := time.NewTicker(ta.Cfg.Discovery.LibraryRescanInterval)
+			defer ticker.Stop()
+			rescan = ticker.C
+		}
 	mainLoop:
-		for instrumentables := range in {
+		for {
+			var instrumentables []Event[ebpf.Instrumentable]
+			select {
+			case <-rescan:
+				ta.rescanLibraries()
+				continue
+			case pid := <-ta.Admin.DetachRequests():
+				ta.detachProcess(pid)
+				continue
+			case evs, ok := <-in:
+				if !ok {
+					break mainLoop
+				}
+				instrumentables = evs
+			}
 			for _, instr := range instrumentables {
 				ta.log.Debug("Instrumentable", "created", instr.Type, "type", instr.Obj.Type,
 					"exec", instr.Obj.FileInfo.CmdExePath, "pid", instr.Obj.FileInfo.Pid)
@@ -72,6 +124,7 @@ func (ta *TraceAttacher) attacherLoop() (pipe.FinalFunc[[]Event[ebpf.Instrumenta
 				case EventCreated:
 					ta.processInstances.Inc(instr.Obj.FileInfo.Ino)
 					if ok := ta.getTracer(&instr.Obj); ok {
+						ta.processAttached(&instr.Obj)
 						ta.DiscoveredTracers <- &instr.Obj
 						if ta.Cfg.Discovery.SystemWide {
 							ta.log.Info("system wide instrumentation. Creating a single instrumenter")
@@ -79,7 +132,13 @@ func (ta *TraceAttacher) attacherLoop() (pipe.FinalFunc[[]Event[ebpf.Instrumenta
 						}
 					}
 				case EventDeleted:
+					if _, ok := ta.detached[instr.Obj.FileInfo.Pid]; ok {
+						delete(ta.detached, instr.Obj.FileInfo.Pid)
+						continue
+					}
 					ta.notifyProcessDeletion(&instr.Obj)
+					delete(ta.instrumented, instr.Obj.FileInfo.Pid)
+					ta.Admin.ProcessDetached(instr.Obj.FileInfo.Pid)
 				}
 			}
 		}
@@ -90,6 +149,16 @@ func (ta *TraceAttacher) attacherLoop() (pipe.FinalFunc[[]Event[ebpf.Instrumenta
 	}, nil
 }
 
+func (ta *TraceAttacher) startOverheadBenchmark() {
+	// the benchmark would interfere with the instrumentation of the Beyla process
+	if ta.Cfg.Discovery.SystemWide || ta.Cfg.Discovery.AllowSelfInstrumentation {
+		ta.log.Warn("the overhead benchmark can't run with system-wide or self instrumentation. Disabling it")
+		return
+	}
+	ta.selfInstrumenter = newSelfInstrumenter(ta.beylaPID)
+	go overhead.New(&ta.Cfg.InternalMetrics.OverheadBenchmark, ta.Metrics, ta.selfInstrumenter).Run(ta.Ctx)
+}
+
 func (ta *TraceAttacher) skipSelfInstrumentation(ie *ebpf.Instrumentable) bool {
 	return ie.FileInfo.Pid == int32(ta.beylaPID) && !ta.Cfg.Discovery.AllowSelfInstrumentation
 }
@@ -104,13 +173,15 @@ func (ta *TraceAttacher) getTracer(ie *ebpf.Instrumentable) bool {
 		ie.FileInfo.Service.SDKLanguage = ie.Type
 		// allowing the tracer to forward traces from the new PID and its children processes
 		ta.monitorPIDs(tracer, ie)
-		ta.Metrics.InstrumentProcess(ie.FileInfo.ExecutableName())
+		ta.Metrics.InstrumentProcess(&ie.FileInfo.Service)
 		if tracer.Type == ebpf.Generic {
 			// We need to do this because generic tracers have shared libraries. For example,
 			// a python executable can run an SSL and non-SSL application, so it's not enough
 			// to look at the executable, we must ensure this process doesn't have different
 			// libraries attached
-			ok = ta.updateTracerProbes(tracer, ie)
+			if ok = ta.updateTracerProbes(tracer, ie); ok {
+				ta.trackLibraries(tracer, ie)
+			}
 		} else {
 			ta.monitorPIDs(ta.reusableGoTracer, ie)
 		}
@@ -125,7 +196,8 @@ func (ta *TraceAttacher) getTracer(ie *ebpf.Instrumentable) bool {
 
 	ta.log.Info("instrumenting process",
 		"cmd", ie.FileInfo.CmdExePath, "pid", ie.FileInfo.Pid, "ino", ie.FileInfo.Ino, "type", ie.Type)
-	ta.Metrics.InstrumentProcess(ie.FileInfo.ExecutableName())
+	ie.FileInfo.Service.SDKLanguage = ie.Type
+	ta.Metrics.InstrumentProcess(&ie.FileInfo.Service)
 
 	// builds a tracer for that executable
 	var programs []ebpf.Tracer
@@ -165,8 +237,6 @@ func (ta *TraceAttacher) getTracer(ie *ebpf.Instrumentable) bool {
 		return false
 	}
 
-	ie.FileInfo.Service.SDKLanguage = ie.Type
-
 	// Instead of the executable file in the disk, we pass the /proc/<pid>/exec
 	// to allow loading it from different container/pods in containerized environments
 	exe, ok := ta.loadExecutable(ie)
@@ -196,10 +266,14 @@ func (ta *TraceAttacher) getTracer(ie *ebpf.Instrumentable) bool {
 	ta.monitorPIDs(tracer, ie)
 	ta.existingTracers[ie.FileInfo.Ino] = tracer
 	if tracer.Type == ebpf.Generic {
+		ta.trackLibraries(tracer, ie)
 		if ta.reusableTracer != nil {
 			ta.monitorPIDs(ta.reusableTracer, ie)
 		} else {
 			ta.reusableTracer = tracer
+			if ta.selfInstrumenter != nil {
+				ta.selfInstrumenter.tracer.Store(tracer)
+			}
 		}
 	} else {
 		ta.reusableGoTracer = tracer
@@ -250,6 +324,9 @@ func (ta *TraceAttacher) reuseTracer(tracer *ebpf.ProcessTracer, ie *ebpf.Instru
 
 	ta.monitorPIDs(tracer, ie)
 	ta.existingTracers[ie.FileInfo.Ino] = tracer
+	if tracer.Type == ebpf.Generic {
+		ta.trackLibraries(tracer, ie)
+	}
 
 	return true
 }
@@ -312,6 +389,33 @@ func (ta *TraceAttacher) monitorPIDs(tracer *ebpf.ProcessTracer, ie *ebpf.Instru
 	}
 }
 
+func (ta *TraceAttacher) processAttached(ie *ebpf.Instrumentable) {
+	tracer, ok := ta.existingTracers[ie.FileInfo.Ino]
+	if !ok {
+		return
+	}
+	ta.instrumented[ie.FileInfo.Pid] = ie
+	tracerType := "generic"
+	if tracer.Type == ebpf.Go {
+		tracerType = "go"
+	}
+	ta.Admin.ProcessAttached(ie.FileInfo.Pid, &ie.FileInfo.Service, tracerType, tracer.Probes(ie.FileInfo.Ino))
+}
+
+// detachProcess removes the instrumentation of a running process, as if it had ended. The process
+// won't be instrumented again until Beyla restarts.
+func (ta *TraceAttacher) detachProcess(pid int32) {
+	ie, ok := ta.instrumented[pid]
+	if !ok {
+		return
+	}
+	ta.log.Info("detaching process on request", "pid", pid, "exec", ie.FileInfo.CmdExePath)
+	ta.notifyProcessDeletion(ie)
+	delete(ta.instrumented, pid)
+	ta.detached[pid] = struct{}{}
+	ta.Admin.ProcessDetached(pid)
+}
+
 func (ta *TraceAttacher) notifyProcessDeletion(ie *ebpf.Instrumentable) {
 	if tracer, ok := ta.existingTracers[ie.FileInfo.Ino]; ok {
 		ta.log.Debug("process ended for already instrumented executable",
@@ -320,8 +424,17 @@ func (ta *TraceAttacher) notifyProcessDeletion(ie *ebpf.Instrumentable) {
 		// notifying the tracer to block any trace from that PID
 		// to avoid that a new process reusing this PID could send traces
 		// unless explicitly allowed
-		ta.Metrics.UninstrumentProcess(ie.FileInfo.ExecutableName())
+		ta.Metrics.UninstrumentProcess(&ie.FileInfo.Service)
+		ta.untrackLibraries(ie)
 		tracer.BlockPID(uint32(ie.FileInfo.Pid), ie.FileInfo.Ns)
+		if ta.SpanSignalsShortcut != nil {
+			// lets the exporters forget the metrics of the process
+			ta.SpanSignalsShortcut <- []request.Span{{
+				Type:      request.EventTypeProcessDeleted,
+				ServiceID: ie.FileInfo.Service,
+				Pid:       request.PidInfo{HostPID: uint32(ie.FileInfo.Pid), Namespace: ie.FileInfo.Ns},
+			}}
+		}
 
 		// if there are no more trace instances for a program, we need to notify that
 		// the tracer needs to be stopped and deleted.
@@ -80,6 +80,7 @@ func (pf *ProcessFinder) Start() (<-chan *ebpf.Instrumentable, <-chan *ebpf.Inst
 		DiscoveredTracers:   discoveredTracers,
 		DeleteTracers:       deleteTracers,
 		Metrics:             pf.ctxInfo.Metrics,
+		Admin:               pf.ctxInfo.Admin,
 		SpanSignalsShortcut: pf.tracesInput,
 	}))
 	pipeline, err := gb.Build()
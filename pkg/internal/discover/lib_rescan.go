@@ -0,0 +1,92 @@
+package discover
+
+import (
+	"github.com/prometheus/procfs"
+
+	"github.com/grafana/beyla/pkg/internal/ebpf"
+	"github.com/grafana/beyla/pkg/internal/exec"
+)
+
+// injectable function for testing
+var processMaps = exec.FindLibMaps
+
+// instanceUpdater is implemented by the ebpf.ProcessTracer, which attaches the uprobes
+// of the libraries that weren't instrumented yet for a process instance
+type instanceUpdater interface {
+	NewExecutableInstance(ie *ebpf.Instrumentable) error
+}
+
+// libsInstance is a process instance instrumented by a generic tracer, whose loaded
+// libraries are periodically re-scanned
+type libsInstance struct {
+	tracer instanceUpdater
+	ie     *ebpf.Instrumentable
+	// number of distinct files that are mapped as executable in the process memory
+	// during the last scan
+	execFiles int
+}
+
+// trackLibraries remembers a process instance to look for the instrumentable libraries
+// (e.g. libssl.so) that it might load lazily after it has been instrumented,
+// e.g. via dlopen or plugins
+func (ta *TraceAttacher) trackLibraries(tracer instanceUpdater, ie *ebpf.Instrumentable) {
+	if ta.Cfg.Discovery.LibraryRescanInterval <= 0 {
+		return
+	}
+	maps, err := processMaps(ie.FileInfo.Pid)
+	if err != nil {
+		ta.log.Debug("can't read process maps. Not tracking its libraries",
+			"pid", ie.FileInfo.Pid, "error", err)
+		return
+	}
+	if ta.libsInstances == nil {
+		ta.libsInstances = map[int32]*libsInstance{}
+	}
+	ta.libsInstances[ie.FileInfo.Pid] = &libsInstance{
+		tracer:    tracer,
+		ie:        ie,
+		execFiles: executableFiles(maps),
+	}
+}
+
+func (ta *TraceAttacher) untrackLibraries(ie *ebpf.Instrumentable) {
+	delete(ta.libsInstances, ie.FileInfo.Pid)
+}
+
+// rescanLibraries attaches the uprobes of the libraries that have been loaded by the tracked
+// process instances since the last scan. The uprobes of the already instrumented libraries
+// aren't attached twice.
+func (ta *TraceAttacher) rescanLibraries() {
+	for pid, li := range ta.libsInstances {
+		maps, err := processMaps(pid)
+		if err != nil {
+			// the process has likely ended, and its deletion event will arrive soon
+			ta.log.Debug("can't read process maps. Forgetting it", "pid", pid, "error", err)
+			delete(ta.libsInstances, pid)
+			continue
+		}
+		execFiles := executableFiles(maps)
+		if execFiles == li.execFiles {
+			continue
+		}
+		ta.log.Debug("process loaded new executable regions. Looking for instrumentable libraries",
+			"pid", pid, "cmd", li.ie.FileInfo.CmdExePath)
+		if err := li.tracer.NewExecutableInstance(li.ie); err != nil {
+			ta.log.Warn("can't instrument the libraries loaded by the process", "pid", pid,
+				"cmd", li.ie.FileInfo.CmdExePath, "error", err)
+		}
+		li.execFiles = execFiles
+	}
+}
+
+// executableFiles returns the number of distinct files that are mapped as executable
+func executableFiles(maps []*procfs.ProcMap) int {
+	inodes := map[uint64]struct{}{}
+	for _, m := range maps {
+		if m.Pathname == "" || m.Inode == 0 || m.Perms == nil || !m.Perms.Execute {
+			continue
+		}
+		inodes[m.Inode] = struct{}{}
+	}
+	return len(inodes)
+}
@@ -0,0 +1,91 @@
+package discover
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/procfs"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/beyla"
+	"github.com/grafana/beyla/pkg/internal/ebpf"
+	"github.com/grafana/beyla/pkg/internal/exec"
+	"github.com/grafana/beyla/pkg/services"
+)
+
+type fakeUpdater struct {
+	updated []int32
+}
+
+func (f *fakeUpdater) NewExecutableInstance(ie *ebpf.Instrumentable) error {
+	f.updated = append(f.updated, ie.FileInfo.Pid)
+	return nil
+}
+
+func execMap(ino uint64, path string) *procfs.ProcMap {
+	return &procfs.ProcMap{Inode: ino, Pathname: path, Perms: &procfs.ProcMapPermissions{Read: true, Execute: true}}
+}
+
+func TestRescanLibraries(t *testing.T) {
+	maps := map[int32][]*procfs.ProcMap{
+		123: {
+			execMap(1, "/usr/bin/python3"),
+			{Inode: 1, Pathname: "/usr/bin/python3", Perms: &procfs.ProcMapPermissions{Read: true}},
+			execMap(2, "/usr/lib/libc.so.6"),
+		},
+	}
+	processMaps = func(pid int32) ([]*procfs.ProcMap, error) {
+		if m, ok := maps[pid]; ok {
+			return m, nil
+		}
+		return nil, errors.New("process not found")
+	}
+	defer func() { processMaps = exec.FindLibMaps }()
+
+	ta := &TraceAttacher{
+		log: slog.With("component", "discover.TraceAttacher"),
+		Cfg: &beyla.Config{Discovery: services.DiscoveryConfig{LibraryRescanInterval: time.Second}},
+	}
+	tracer := &fakeUpdater{}
+	ie := &ebpf.Instrumentable{FileInfo: &exec.FileInfo{Pid: 123}}
+	ta.trackLibraries(tracer, ie)
+
+	// nothing new has been loaded
+	ta.rescanLibraries()
+	assert.Empty(t, tracer.updated)
+
+	// non-executable mappings are ignored
+	maps[123] = append(maps[123], &procfs.ProcMap{Inode: 3, Pathname: "/tmp/data", Perms: &procfs.ProcMapPermissions{Read: true}})
+	ta.rescanLibraries()
+	assert.Empty(t, tracer.updated)
+
+	// the process loads OpenSSL
+	maps[123] = append(maps[123], execMap(4, "/usr/lib/libssl.so.3"))
+	ta.rescanLibraries()
+	assert.Equal(t, []int32{123}, tracer.updated)
+
+	// the new library is not instrumented twice
+	ta.rescanLibraries()
+	assert.Equal(t, []int32{123}, tracer.updated)
+
+	// the process ended
+	delete(maps, 123)
+	ta.rescanLibraries()
+	assert.Empty(t, ta.libsInstances)
+}
+
+func TestTrackLibraries_Disabled(t *testing.T) {
+	processMaps = func(_ int32) ([]*procfs.ProcMap, error) {
+		return []*procfs.ProcMap{execMap(1, "/usr/bin/node")}, nil
+	}
+	defer func() { processMaps = exec.FindLibMaps }()
+
+	ta := &TraceAttacher{
+		log: slog.With("component", "discover.TraceAttacher"),
+		Cfg: &beyla.Config{},
+	}
+	ta.trackLibraries(&fakeUpdater{}, &ebpf.Instrumentable{FileInfo: &exec.FileInfo{Pid: 123}})
+	assert.Empty(t, ta.libsInstances)
+}
@@ -0,0 +1,46 @@
+package discover
+
+import (
+	"sync/atomic"
+
+	"github.com/grafana/beyla/pkg/internal/ebpf"
+	"github.com/grafana/beyla/pkg/internal/exec"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// selfInstrumenter implements overhead.Instrumenter by allowing and blocking the Beyla process
+// in the generic tracer, which is shared by all the non-Go instrumented processes.
+type selfInstrumenter struct {
+	tracer  atomic.Pointer[ebpf.ProcessTracer]
+	pid     uint32
+	service svc.ID
+}
+
+func newSelfInstrumenter(pid int) *selfInstrumenter {
+	si := &selfInstrumenter{pid: uint32(pid), service: svc.ID{Name: "beyla"}}
+	si.service.SetOverheadBenchmark()
+	return si
+}
+
+func (si *selfInstrumenter) Instrument() bool {
+	tracer := si.tracer.Load()
+	if tracer == nil {
+		return false
+	}
+	ns, err := exec.FindNamespace(int32(si.pid))
+	if err != nil {
+		return false
+	}
+	tracer.AllowPID(si.pid, ns, &si.service)
+	return true
+}
+
+func (si *selfInstrumenter) Uninstrument() {
+	tracer := si.tracer.Load()
+	if tracer == nil {
+		return
+	}
+	if ns, err := exec.FindNamespace(int32(si.pid)); err == nil {
+		tracer.BlockPID(si.pid, ns)
+	}
+}
@@ -0,0 +1,485 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unsafe"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// AMQP 1.0, as used by Azure Service Bus, ActiveMQ Artemis or Qpid. It is not compatible with AMQP 0-9-1.
+// https://docs.oasis-open.org/amqp/core/v1.0/os/amqp-core-transport-v1.0-os.html
+const (
+	amqpFrameHeaderLen = 8
+	amqpFrameTypeAMQP  = 0
+
+	amqpPerformativeOpen        = 0x10
+	amqpPerformativeAttach      = 0x12
+	amqpPerformativeTransfer    = 0x14
+	amqpPerformativeDisposition = 0x15
+	amqpPerformativeClose       = 0x18
+
+	amqpSource = 0x28
+	amqpTarget = 0x29
+
+	amqpStateAccepted = 0x24
+	amqpStateRejected = 0x25
+	amqpStateReleased = 0x26
+	amqpStateModified = 0x27
+)
+
+var amqpProtocolHeader = []byte("AMQP\x00\x01\x00\x00")
+
+type amqpEndpoint struct {
+	addr [16]uint8
+	port uint16
+}
+
+// amqpLinkKey identifies a link by the endpoint that attached it, and the channel and
+// handle that this endpoint uses to refer to it in the frames that it sends
+type amqpLinkKey struct {
+	from    amqpEndpoint
+	to      amqpEndpoint
+	channel uint16
+	handle  uint32
+}
+
+type amqpLink struct {
+	name string
+	// address of the target node of the sending links, or the source node of the receiving links,
+	// e.g. the queue or topic name
+	address string
+	// receiver is the role of the endpoint that attached the link
+	receiver bool
+}
+
+// The transfer frames only refer to the links by their handles, so the links are remembered
+// when their attach frames are seen, at the beginning of the communication
+var amqpLinks, _ = lru.New[amqpLinkKey, *amqpLink](1024)
+
+// amqpDecoder reads values from the AMQP type system encoding. The buffer might be truncated.
+type amqpDecoder struct {
+	buf []byte
+}
+
+// value reads the next non-described value, returning its format code and its bytes, which
+// include the size prefix of the variable-width and compound values
+func (d *amqpDecoder) value() (byte, []byte, bool) {
+	if len(d.buf) == 0 {
+		return 0, nil, false
+	}
+	code := d.buf[0]
+	rest := d.buf[1:]
+	n := 0
+	switch code >> 4 {
+	case 0x4:
+		n = 0
+	case 0x5:
+		n = 1
+	case 0x6:
+		n = 2
+	case 0x7:
+		n = 4
+	case 0x8:
+		n = 8
+	case 0x9:
+		n = 16
+	case 0xa, 0xc, 0xe:
+		if len(rest) < 1 {
+			return 0, nil, false
+		}
+		n = 1 + int(rest[0])
+	case 0xb, 0xd, 0xf:
+		if len(rest) < 4 {
+			return 0, nil, false
+		}
+		n = 4 + int(binary.BigEndian.Uint32(rest))
+	default:
+		return 0, nil, false
+	}
+	if n < 0 || len(rest) < n {
+		return 0, nil, false
+	}
+	d.buf = rest[n:]
+	return code, rest[:n], true
+}
+
+// skip skips the next value, which might be described
+func (d *amqpDecoder) skip() bool {
+	if len(d.buf) > 0 && d.buf[0] == 0 {
+		d.buf = d.buf[1:]
+		return d.skip() && d.skip()
+	}
+	_, _, ok := d.value()
+	return ok
+}
+
+// described reads the numeric descriptor of a described value, which is followed by the value itself
+func (d *amqpDecoder) described() (uint64, bool) {
+	if len(d.buf) == 0 || d.buf[0] != 0 {
+		return 0, false
+	}
+	d.buf = d.buf[1:]
+	return d.uint()
+}
+
+// list reads a list, returning a decoder for its elements, which might be truncated
+func (d *amqpDecoder) list() (amqpDecoder, bool) {
+	if len(d.buf) == 0 {
+		return amqpDecoder{}, false
+	}
+	var headerLen, size int
+	switch d.buf[0] {
+	case 0x45: // list0
+		d.buf = d.buf[1:]
+		return amqpDecoder{}, true
+	case 0xc0: // list8
+		if len(d.buf) < 3 {
+			return amqpDecoder{}, false
+		}
+		headerLen, size = 2, int(d.buf[1])
+	case 0xd0: // list32
+		if len(d.buf) < 9 {
+			return amqpDecoder{}, false
+		}
+		headerLen, size = 5, int(binary.BigEndian.Uint32(d.buf[1:]))
+	default:
+		return amqpDecoder{}, false
+	}
+	// the size includes the elements count, whose width is the same as the size
+	countLen := headerLen - 1
+	if size < countLen {
+		return amqpDecoder{}, false
+	}
+	elems := d.buf[headerLen+countLen:]
+	if end := size - countLen; end <= len(elems) {
+		d.buf = elems[end:]
+		elems = elems[:end]
+	} else {
+		d.buf = nil
+	}
+	return amqpDecoder{buf: elems}, true
+}
+
+func (d *amqpDecoder) uint() (uint64, bool) {
+	code, val, ok := d.value()
+	if !ok {
+		return 0, false
+	}
+	switch code {
+	case 0x43, 0x44: // uint0, ulong0
+		return 0, true
+	case 0x50, 0x52, 0x53: // ubyte, smalluint, smallulong
+		return uint64(val[0]), true
+	case 0x60: // ushort
+		return uint64(binary.BigEndian.Uint16(val)), true
+	case 0x70: // uint
+		return uint64(binary.BigEndian.Uint32(val)), true
+	case 0x80: // ulong
+		return binary.BigEndian.Uint64(val), true
+	}
+	return 0, false
+}
+
+func (d *amqpDecoder) bool() (bool, bool) {
+	code, val, ok := d.value()
+	if !ok {
+		return false, false
+	}
+	switch code {
+	case 0x41:
+		return true, true
+	case 0x42:
+		return false, true
+	case 0x56:
+		return val[0] != 0, true
+	}
+	return false, false
+}
+
+// string reads a string, symbol or binary value
+func (d *amqpDecoder) string() (string, bool) {
+	code, val, ok := d.value()
+	if !ok {
+		return "", false
+	}
+	switch code {
+	case 0xa0, 0xa1, 0xa3:
+		return string(val[1:]), true
+	case 0xb0, 0xb1, 0xb3:
+		return string(val[4:]), true
+	}
+	return "", false
+}
+
+type amqpFrame struct {
+	channel      uint16
+	performative uint64
+	// fields of the performative
+	fields amqpDecoder
+}
+
+// amqpFrames returns the AMQP frames whose header fits in the buffer, skipping the protocol
+// header that starts the connection. It returns false if the buffer doesn't start with an AMQP frame.
+func amqpFrames(buf []byte) ([]amqpFrame, bool) {
+	buf = bytes.TrimPrefix(buf, amqpProtocolHeader)
+	var frames []amqpFrame
+	for len(buf) >= amqpFrameHeaderLen {
+		size := int(binary.BigEndian.Uint32(buf))
+		doff := int(buf[4]) * 4
+		if size < amqpFrameHeaderLen || doff < amqpFrameHeaderLen || doff > size || buf[5] != amqpFrameTypeAMQP {
+			break
+		}
+		channel := binary.BigEndian.Uint16(buf[6:])
+		if size > doff {
+			// frames without body are heartbeats
+			body := amqpDecoder{buf: buf[min(doff, len(buf)):min(size, len(buf))]}
+			performative, ok := body.described()
+			if !ok || performative < amqpPerformativeOpen || performative > amqpPerformativeClose {
+				break
+			}
+			fields, ok := body.list()
+			if !ok {
+				break
+			}
+			frames = append(frames, amqpFrame{channel: channel, performative: performative, fields: fields})
+		}
+		if size >= len(buf) {
+			break
+		}
+		buf = buf[size:]
+	}
+	return frames, len(frames) > 0
+}
+
+// parseAMQPAttach parses the name, handle and role fields of an attach performative, as well as
+// the address of its source or target node
+func parseAMQPAttach(fields amqpDecoder) (*amqpLink, uint32, bool) {
+	name, ok := fields.string()
+	if !ok {
+		return nil, 0, false
+	}
+	handle, ok := fields.uint()
+	if !ok {
+		return nil, 0, false
+	}
+	link := &amqpLink{name: name}
+	if link.receiver, ok = fields.bool(); !ok {
+		return link, uint32(handle), true
+	}
+	// settlement modes
+	if !fields.skip() || !fields.skip() {
+		return link, uint32(handle), true
+	}
+	// the receiving links are interested in the source node, and the sending links in the target node
+	node := uint64(amqpSource)
+	if !link.receiver {
+		node = amqpTarget
+		if !fields.skip() {
+			return link, uint32(handle), true
+		}
+	}
+	if descriptor, ok := fields.described(); ok && descriptor == node {
+		if terminus, ok := fields.list(); ok {
+			link.address, _ = terminus.string()
+		}
+	}
+	return link, uint32(handle), true
+}
+
+type amqpTransfer struct {
+	channel    uint16
+	handle     uint32
+	deliveryID uint64
+	// hasDeliveryID is false for the continuation frames of multi-frame deliveries
+	hasDeliveryID bool
+}
+
+func parseAMQPTransfer(frame *amqpFrame) (*amqpTransfer, bool) {
+	fields := frame.fields
+	handle, ok := fields.uint()
+	if !ok {
+		return nil, false
+	}
+	transfer := &amqpTransfer{channel: frame.channel, handle: uint32(handle)}
+	transfer.deliveryID, transfer.hasDeliveryID = fields.uint()
+	return transfer, true
+}
+
+// amqpOutcome returns the outcome of the delivery, e.g. accepted or rejected, that is settled
+// by a disposition frame that the receiver sent, or an empty string if there is none
+func amqpOutcome(frames []amqpFrame, deliveryID uint64) string {
+	for i := range frames {
+		if frames[i].performative != amqpPerformativeDisposition {
+			continue
+		}
+		fields := frames[i].fields
+		if receiver, ok := fields.bool(); !ok || !receiver {
+			continue
+		}
+		first, ok := fields.uint()
+		if !ok {
+			continue
+		}
+		last, ok := fields.uint()
+		if !ok {
+			// the disposition refers only to the first delivery
+			last = first
+		}
+		if deliveryID < first || deliveryID > last {
+			continue
+		}
+		// settled flag
+		if !fields.skip() {
+			continue
+		}
+		state, ok := fields.described()
+		if !ok {
+			continue
+		}
+		switch state {
+		case amqpStateAccepted:
+			return "accepted"
+		case amqpStateRejected:
+			return "rejected"
+		case amqpStateReleased:
+			return "released"
+		case amqpStateModified:
+			return "modified"
+		}
+	}
+	return ""
+}
+
+func rememberAMQPLinks(frames []amqpFrame, from, to amqpEndpoint) {
+	for i := range frames {
+		if frames[i].performative != amqpPerformativeAttach {
+			continue
+		}
+		if link, handle, ok := parseAMQPAttach(frames[i].fields); ok {
+			amqpLinks.Add(amqpLinkKey{from: from, to: to, channel: frames[i].channel, handle: handle}, link)
+		}
+	}
+}
+
+func firstAMQPTransfer(frames []amqpFrame) (*amqpTransfer, bool) {
+	for i := range frames {
+		if frames[i].performative == amqpPerformativeTransfer {
+			return parseAMQPTransfer(&frames[i])
+		}
+	}
+	return nil, false
+}
+
+// amqpEvent is the AMQP message transfer that a TCP event contains
+type amqpEvent struct {
+	transfer *amqpTransfer
+	link     *amqpLink
+	outcome  string
+	// published is true if the message was transferred from the source to the destination
+	// of the connection, and false if it was transferred in the opposite direction
+	published bool
+}
+
+// parseAMQPEvent parses the AMQP frames that have been sent from the source of the connection (req)
+// and from its destination (resp). It remembers the attached links, and returns the first message
+// transfer, if any.
+func parseAMQPEvent(trace *TCPRequestInfo, req, resp []byte) (*amqpEvent, bool) {
+	reqFrames, ok := amqpFrames(req)
+	if !ok {
+		return nil, false
+	}
+	respFrames, _ := amqpFrames(resp)
+
+	src := amqpEndpoint{addr: trace.ConnInfo.S_addr, port: trace.ConnInfo.S_port}
+	dst := amqpEndpoint{addr: trace.ConnInfo.D_addr, port: trace.ConnInfo.D_port}
+	rememberAMQPLinks(reqFrames, src, dst)
+	rememberAMQPLinks(respFrames, dst, src)
+
+	ev := &amqpEvent{published: true}
+	ev.transfer, ok = firstAMQPTransfer(reqFrames)
+	if ok {
+		ev.link, ok = amqpLinks.Get(amqpLinkKey{from: src, to: dst, channel: ev.transfer.channel, handle: ev.transfer.handle})
+		if !ok {
+			// the links are attached by the endpoint that sends the transfers, so a transfer from the
+			// destination might have been caught first, when the source granted the credit beforehand
+			if link, ok := amqpLinks.Get(amqpLinkKey{from: dst, to: src, channel: ev.transfer.channel, handle: ev.transfer.handle}); ok {
+				ev.link, ev.published = link, false
+			}
+		}
+	} else if ev.transfer, ok = firstAMQPTransfer(respFrames); ok {
+		ev.published = false
+		ev.link, _ = amqpLinks.Get(amqpLinkKey{from: dst, to: src, channel: ev.transfer.channel, handle: ev.transfer.handle})
+	} else {
+		// other performatives, e.g. the attach or flow frames, don't produce spans
+		return ev, true
+	}
+	if ev.published && ev.transfer.hasDeliveryID {
+		ev.outcome = amqpOutcome(respFrames, ev.transfer.deliveryID)
+	}
+	return ev, true
+}
+
+func TCPToAMQPToSpan(trace *TCPRequestInfo, ev *amqpEvent) request.Span {
+	peer := ""
+	hostname := ""
+	hostPort := 0
+
+	if trace.ConnInfo.S_port != 0 || trace.ConnInfo.D_port != 0 {
+		peer, hostname = (*BPFConnInfo)(unsafe.Pointer(&trace.ConnInfo)).reqHostInfo()
+		hostPort = int(trace.ConnInfo.D_port)
+	}
+
+	reqType := request.EventTypeAMQPClient
+	if trace.Direction == 0 {
+		reqType = request.EventTypeAMQPServer
+	}
+
+	method := request.MessagingPublish
+	contentLength := int64(trace.Len)
+	if !ev.published {
+		method = request.MessagingProcess
+		contentLength = int64(trace.RespLen)
+	}
+
+	status := 0
+	if ev.outcome == "rejected" {
+		status = 1
+	}
+
+	info := &request.AMQP{Outcome: ev.outcome}
+	path := ""
+	if ev.link != nil {
+		info.LinkName = ev.link.name
+		path = ev.link.address
+	}
+
+	return request.Span{
+		Type:          reqType,
+		Method:        method,
+		Path:          path,
+		Peer:          peer,
+		PeerPort:      int(trace.ConnInfo.S_port),
+		Host:          hostname,
+		HostPort:      hostPort,
+		ContentLength: contentLength,
+		RequestStart:  int64(trace.StartMonotimeNs),
+		Start:         int64(trace.StartMonotimeNs),
+		End:           int64(trace.EndMonotimeNs),
+		Status:        status,
+		TraceID:       trace2.TraceID(trace.Tp.TraceId),
+		SpanID:        trace2.SpanID(trace.Tp.SpanId),
+		ParentSpanID:  trace2.SpanID(trace.Tp.ParentId),
+		Flags:         trace.Tp.Flags,
+		Pid: request.PidInfo{
+			HostPID:   trace.Pid.HostPid,
+			UserPID:   trace.Pid.UserPid,
+			Namespace: trace.Pid.Ns,
+		},
+		AMQP: info,
+	}
+}
@@ -0,0 +1,208 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func amqpStr(s string) []byte {
+	return append([]byte{0xa1, byte(len(s))}, s...)
+}
+
+func amqpUint(v byte) []byte {
+	return []byte{0x52, v}
+}
+
+func amqpBool(v bool) []byte {
+	if v {
+		return []byte{0x41}
+	}
+	return []byte{0x42}
+}
+
+func amqpList(fields ...[]byte) []byte {
+	elems := bytes.Join(fields, nil)
+	return append([]byte{0xc0, byte(len(elems) + 1), byte(len(fields))}, elems...)
+}
+
+func amqpDescribed(descriptor byte, value []byte) []byte {
+	return append([]byte{0x00, 0x53, descriptor}, value...)
+}
+
+func amqpTestFrame(channel uint16, performative byte, fields ...[]byte) []byte {
+	body := amqpDescribed(performative, amqpList(fields...))
+	frame := binary.BigEndian.AppendUint32(nil, uint32(amqpFrameHeaderLen+len(body)))
+	frame = append(frame, 2, amqpFrameTypeAMQP)
+	frame = binary.BigEndian.AppendUint16(frame, channel)
+	return append(frame, body...)
+}
+
+func amqpAttachFrame(channel uint16, name string, handle byte, receiver bool, address string) []byte {
+	terminus := amqpDescribed(amqpSource, amqpList(amqpStr(address)))
+	nullTerminus := []byte{0x40}
+	source, target := terminus, nullTerminus
+	if !receiver {
+		source, target = nullTerminus, amqpDescribed(amqpTarget, amqpList(amqpStr(address)))
+	}
+	return amqpTestFrame(channel, amqpPerformativeAttach,
+		amqpStr(name), amqpUint(handle), amqpBool(receiver), amqpUint(0), amqpUint(0), source, target)
+}
+
+func amqpTransferFrame(channel uint16, handle, deliveryID byte) []byte {
+	// the message payload follows the performative
+	return append(amqpTestFrame(channel, amqpPerformativeTransfer, amqpUint(handle), amqpUint(deliveryID),
+		[]byte{0xa0, 1, deliveryID}), 0x00, 0x53, 0x75, 0xa0, 5, 'h', 'e', 'l', 'l', 'o')
+}
+
+func amqpDispositionFrame(channel uint16, first byte, state byte) []byte {
+	return amqpTestFrame(channel, amqpPerformativeDisposition,
+		amqpBool(true), amqpUint(first), []byte{0x40}, amqpBool(true), amqpDescribed(state, []byte{0x45}))
+}
+
+func amqpFlowFrame(channel uint16) []byte {
+	return amqpTestFrame(channel, 0x13, amqpUint(0), amqpUint(100), amqpUint(0), amqpUint(100))
+}
+
+func readAMQPEvent(t *testing.T, req, resp []byte, direction int) (request.Span, bool) {
+	trace := makeTCPReq(string(req), direction, 40000, 5672, 5)
+	copy(trace.Rbuf[:], resp)
+	trace.RespLen = uint32(len(resp))
+	binaryRecord := bytes.Buffer{}
+	require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+	span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+	require.NoError(t, err)
+	return span, ignore
+}
+
+func TestAMQPFrames(t *testing.T) {
+	buf := append(append([]byte{}, amqpProtocolHeader...), amqpAttachFrame(1, "sender-link", 0, false, "orders")...)
+	// heartbeat
+	buf = append(buf, 0, 0, 0, 8, 2, 0, 0, 0)
+	buf = append(buf, amqpTransferFrame(1, 0, 7)...)
+	frames, ok := amqpFrames(buf)
+	require.True(t, ok)
+	require.Len(t, frames, 2)
+	assert.Equal(t, uint64(amqpPerformativeAttach), frames[0].performative)
+	assert.Equal(t, uint16(1), frames[0].channel)
+	assert.Equal(t, uint64(amqpPerformativeTransfer), frames[1].performative)
+
+	link, handle, ok := parseAMQPAttach(frames[0].fields)
+	require.True(t, ok)
+	assert.Equal(t, uint32(0), handle)
+	assert.Equal(t, &amqpLink{name: "sender-link", address: "orders"}, link)
+
+	transfer, ok := parseAMQPTransfer(&frames[1])
+	require.True(t, ok)
+	assert.Equal(t, &amqpTransfer{channel: 1, handle: 0, deliveryID: 7, hasDeliveryID: true}, transfer)
+
+	// the name is still parsed from a truncated attach frame
+	attach := amqpAttachFrame(0, "receiver-link", 1, true, "orders")
+	frames, ok = amqpFrames(attach[:34])
+	require.True(t, ok)
+	link, handle, ok = parseAMQPAttach(frames[0].fields)
+	require.True(t, ok)
+	assert.Equal(t, uint32(1), handle)
+	assert.Equal(t, "receiver-link", link.name)
+	assert.Empty(t, link.address)
+
+	for _, buf := range [][]byte{
+		[]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+		[]byte("SELECT * FROM orders"),
+		{0, 0, 0, 20, 0, 3, 0, 0, 0, 0, 0, 1, 0, 6, 'c', 'l', 'i', 'e', 'n', 't'},
+		amqpProtocolHeader,
+	} {
+		_, ok := amqpFrames(buf)
+		assert.False(t, ok, "%q", buf)
+	}
+}
+
+func TestTCPToAMQPToSpan_Publish(t *testing.T) {
+	amqpLinks.Purge()
+
+	// the attach frames don't produce spans, but the link is remembered
+	_, ignore := readAMQPEvent(t,
+		append(append([]byte{}, amqpProtocolHeader...), amqpAttachFrame(0, "orders-sender", 2, false, "orders")...),
+		amqpAttachFrame(0, "orders-sender", 0, true, "orders"), 1)
+	assert.True(t, ignore)
+
+	for _, tc := range []struct {
+		name      string
+		resp      []byte
+		direction int
+		spanType  request.EventType
+		outcome   string
+		status    codes.Code
+	}{
+		{name: "accepted", resp: amqpDispositionFrame(0, 3, amqpStateAccepted), direction: 1,
+			spanType: request.EventTypeAMQPClient, outcome: "accepted", status: codes.Unset},
+		{name: "rejected", resp: amqpDispositionFrame(0, 3, amqpStateRejected), direction: 1,
+			spanType: request.EventTypeAMQPClient, outcome: "rejected", status: codes.Error},
+		{name: "other delivery", resp: amqpDispositionFrame(0, 2, amqpStateAccepted), direction: 1,
+			spanType: request.EventTypeAMQPClient, status: codes.Unset},
+		{name: "server", resp: amqpDispositionFrame(0, 3, amqpStateReleased), direction: 0,
+			spanType: request.EventTypeAMQPServer, outcome: "released", status: codes.Unset},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			span, ignore := readAMQPEvent(t, amqpTransferFrame(0, 2, 3), tc.resp, tc.direction)
+			require.False(t, ignore)
+			assert.Equal(t, tc.spanType, span.Type)
+			assert.Equal(t, request.MessagingPublish, span.Method)
+			assert.Equal(t, "orders", span.Path)
+			assert.Equal(t, "orders publish", span.TraceName())
+			assert.Equal(t, 5672, span.HostPort)
+			require.NotNil(t, span.AMQP)
+			assert.Equal(t, "orders-sender", span.AMQP.LinkName)
+			assert.Equal(t, tc.outcome, span.AMQP.Outcome)
+			assert.Equal(t, tc.status, request.SpanStatusCode(&span))
+			assert.Equal(t, "amqp", span.MessagingSystemName())
+		})
+	}
+}
+
+func TestTCPToAMQPToSpan_Process(t *testing.T) {
+	amqpLinks.Purge()
+
+	// the client attaches a receiving link, and the broker attaches the sending end of the link
+	_, ignore := readAMQPEvent(t,
+		amqpAttachFrame(1, "invoices-receiver", 0, true, "invoices"),
+		amqpAttachFrame(4, "invoices-receiver", 5, false, "invoices"), 1)
+	assert.True(t, ignore)
+
+	span, ignore := readAMQPEvent(t, amqpFlowFrame(1), amqpTransferFrame(4, 5, 0), 1)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeAMQPClient, span.Type)
+	assert.Equal(t, request.MessagingProcess, span.Method)
+	assert.Equal(t, "invoices", span.Path)
+	require.NotNil(t, span.AMQP)
+	assert.Equal(t, "invoices-receiver", span.AMQP.LinkName)
+	assert.Empty(t, span.AMQP.Outcome)
+
+	// the transfer is caught before any frame from the client, as it granted the credit in advance
+	span, ignore = readAMQPEvent(t, amqpTransferFrame(4, 5, 1), amqpDispositionFrame(1, 1, amqpStateAccepted), 1)
+	require.False(t, ignore)
+	assert.Equal(t, request.MessagingProcess, span.Method)
+	assert.Equal(t, "invoices", span.Path)
+	assert.Equal(t, "invoices-receiver", span.AMQP.LinkName)
+}
+
+func TestTCPToAMQPToSpan_UnknownLink(t *testing.T) {
+	amqpLinks.Purge()
+
+	span, ignore := readAMQPEvent(t, amqpTransferFrame(0, 9, 0), amqpDispositionFrame(0, 0, amqpStateAccepted), 1)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeAMQPClient, span.Type)
+	assert.Equal(t, request.MessagingPublish, span.Method)
+	assert.Empty(t, span.Path)
+	assert.Equal(t, "publish", span.TraceName())
+	require.NotNil(t, span.AMQP)
+	assert.Empty(t, span.AMQP.LinkName)
+	assert.Equal(t, "accepted", span.AMQP.Outcome)
+}
@@ -0,0 +1,227 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/sqlprune"
+)
+
+// ClickHouse native protocol packet types
+const (
+	clickHouseClientQuery     = 1
+	clickHouseServerException = 2
+)
+
+// ClickHouse native protocol revisions that introduced the fields of the Query packet
+// that we need to skip until reaching the query text
+const (
+	clickHouseRevisionWithQuotaKey          = 54060
+	clickHouseRevisionWithVersionPatch      = 54401
+	clickHouseRevisionWithStringSettings    = 54429
+	clickHouseRevisionWithInterserverSecret = 54441
+	clickHouseRevisionWithOpenTelemetry     = 54442
+	clickHouseRevisionWithDistributedDepth  = 54448
+	clickHouseRevisionWithQueryStartTime    = 54449
+	clickHouseRevisionWithParallelReplicas  = 54453
+	clickHouseRevisionWithScriptLineNumbers = 54475
+)
+
+const (
+	clickHouseQueryKindInitial  = 1
+	clickHouseQueryKindSecond   = 2
+	clickHouseInterfaceTCP      = 1
+	clickHouseMaxIdentifierSize = 128
+)
+
+type clickHouseQuery struct {
+	queryID  string
+	settings []string
+	query    string
+}
+
+// clickHouseReader decodes the primitive types of the ClickHouse native protocol.
+// Any read beyond the end of the buffer sets the failed flag.
+type clickHouseReader struct {
+	buf    []byte
+	pos    int
+	failed bool
+}
+
+func (r *clickHouseReader) uvarint() uint64 {
+	if r.failed {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.failed = true
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *clickHouseReader) uint8() uint8 {
+	if r.failed || r.pos >= len(r.buf) {
+		r.failed = true
+		return 0
+	}
+	r.pos++
+	return r.buf[r.pos-1]
+}
+
+func (r *clickHouseReader) skip(n int) {
+	if r.failed || r.pos+n > len(r.buf) {
+		r.failed = true
+		return
+	}
+	r.pos += n
+}
+
+// identifier reads a length-prefixed string that is expected to be short and printable,
+// such as user names, hosts or setting names. This allows discarding false positives early.
+func (r *clickHouseReader) identifier() string {
+	l := r.uvarint()
+	if r.failed || l > clickHouseMaxIdentifierSize || r.pos+int(l) > len(r.buf) {
+		r.failed = true
+		return ""
+	}
+	str := string(r.buf[r.pos : r.pos+int(l)])
+	r.pos += int(l)
+	for i := 0; i < len(str); i++ {
+		if str[i] < ' ' || str[i] > '~' {
+			r.failed = true
+			return ""
+		}
+	}
+	return str
+}
+
+// truncatedString reads a length-prefixed string that might be truncated by the
+// end of the captured buffer
+func (r *clickHouseReader) truncatedString() string {
+	l := r.uvarint()
+	if r.failed {
+		return ""
+	}
+	end := r.pos + int(l)
+	if end > len(r.buf) || end < r.pos {
+		end = len(r.buf)
+	}
+	str := string(r.buf[r.pos:end])
+	r.pos = end
+	return str
+}
+
+// parseClickHouseQuery parses the Query packet that a client sends through the ClickHouse native
+// TCP protocol. The layout of the packet depends on the protocol revision, which is negotiated
+// during the handshake. Since we don't see the handshake, we assume the revision that the client
+// reports in the packet, and we check the layout with and without the query start time field,
+// as it is placed before the revision.
+func parseClickHouseQuery(buf []byte) (*clickHouseQuery, bool) {
+	if q, ok := parseClickHouseQueryLayout(buf, true); ok {
+		return q, true
+	}
+	return parseClickHouseQueryLayout(buf, false)
+}
+
+// nolint:cyclop
+func parseClickHouseQueryLayout(buf []byte, withStartTime bool) (*clickHouseQuery, bool) {
+	r := clickHouseReader{buf: buf}
+	if r.uvarint() != clickHouseClientQuery || r.failed {
+		return nil, false
+	}
+	q := clickHouseQuery{queryID: r.identifier()}
+
+	// client info
+	if kind := r.uint8(); kind != clickHouseQueryKindInitial && kind != clickHouseQueryKindSecond {
+		// clients never send queries without client info (kind 0),
+		// which is required to infer the protocol revision
+		return nil, false
+	}
+	r.identifier() // initial user
+	r.identifier() // initial query ID
+	r.identifier() // initial address
+	if withStartTime {
+		r.skip(8)
+	}
+	if r.uint8() != clickHouseInterfaceTCP {
+		return nil, false
+	}
+	r.identifier() // OS user
+	r.identifier() // client hostname
+	r.identifier() // client name
+	r.uvarint()    // client major version
+	r.uvarint()    // client minor version
+	revision := r.uvarint()
+	if r.failed || revision < clickHouseRevisionWithStringSettings ||
+		withStartTime != (revision >= clickHouseRevisionWithQueryStartTime) {
+		return nil, false
+	}
+	if revision >= clickHouseRevisionWithQuotaKey {
+		r.identifier()
+	}
+	if revision >= clickHouseRevisionWithDistributedDepth {
+		r.uvarint()
+	}
+	if revision >= clickHouseRevisionWithVersionPatch {
+		r.uvarint()
+	}
+	if revision >= clickHouseRevisionWithOpenTelemetry {
+		if r.uint8() == 1 {
+			r.skip(16 + 8) // trace and span IDs
+			r.identifier() // trace state
+			r.uint8()      // trace flags
+		}
+	}
+	if revision >= clickHouseRevisionWithParallelReplicas {
+		r.uvarint() // collaborate with initiator
+		r.uvarint() // count participating replicas
+		r.uvarint() // number of current replica
+	}
+	if revision >= clickHouseRevisionWithScriptLineNumbers {
+		r.uvarint() // script query number
+		r.uvarint() // script line number
+	}
+
+	// settings, as a list of name, flags and value, terminated by an empty name
+	for !r.failed {
+		name := r.identifier()
+		if name == "" {
+			break
+		}
+		r.uvarint() // flags
+		value := r.identifier()
+		q.settings = append(q.settings, name+"="+value)
+	}
+	if revision >= clickHouseRevisionWithInterserverSecret {
+		r.identifier()
+	}
+	r.uvarint() // query processing stage
+	r.uvarint() // compression
+	q.query = r.truncatedString()
+	if r.failed || q.query == "" {
+		return nil, false
+	}
+	return &q, true
+}
+
+// clickHouseStatus returns 1 if the server response is an exception, 0 otherwise
+func clickHouseStatus(resp []byte) int {
+	if pkt, n := binary.Uvarint(resp); n > 0 && pkt == clickHouseServerException {
+		return 1
+	}
+	return 0
+}
+
+func TCPToClickHouseToSpan(trace *TCPRequestInfo, q *clickHouseQuery, status int) request.Span {
+	op, table := sqlprune.SQLParseOperationAndTable(q.query)
+	span := TCPToSQLToSpan(trace, op, table, q.query)
+	span.Status = status
+	span.SubType = request.SQLSubtypeClickHouse
+	span.ClickHouse = &request.ClickHouse{
+		QueryID:  q.queryID,
+		Settings: q.settings,
+	}
+	return span
+}
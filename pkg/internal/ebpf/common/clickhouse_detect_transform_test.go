@@ -0,0 +1,112 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+type clickHousePacket []byte
+
+func (p clickHousePacket) uvarint(v uint64) clickHousePacket {
+	return binary.AppendUvarint(p, v)
+}
+
+func (p clickHousePacket) str(s string) clickHousePacket {
+	return append(p.uvarint(uint64(len(s))), s...)
+}
+
+func (p clickHousePacket) bytes(b ...byte) clickHousePacket {
+	return append(p, b...)
+}
+
+// clickHouseQueryPacket builds a Query packet as it is sent by a client of the given protocol revision
+func clickHouseQueryPacket(revision uint64, query string, settings ...string) []byte {
+	p := clickHousePacket{}.uvarint(clickHouseClientQuery).
+		str("a9c4ce1e-0f4d-4b8e-8f6a-3b6a0c8d6f10").
+		bytes(clickHouseQueryKindInitial).
+		str("").str("").str("0.0.0.0:0")
+	if revision >= clickHouseRevisionWithQueryStartTime {
+		p = p.bytes(0, 0, 0, 0, 0, 0, 0, 0)
+	}
+	p = p.bytes(clickHouseInterfaceTCP).
+		str("app").str("analytics-7f8d").str("clickhouse-go/2.30.0").
+		uvarint(2).uvarint(30).uvarint(revision).
+		str("") // quota key
+	if revision >= clickHouseRevisionWithDistributedDepth {
+		p = p.uvarint(0)
+	}
+	p = p.uvarint(0) // version patch
+	p = p.bytes(0)   // no OpenTelemetry context
+	if revision >= clickHouseRevisionWithParallelReplicas {
+		p = p.uvarint(0).uvarint(0).uvarint(0)
+	}
+	for i := 0; i+1 < len(settings); i += 2 {
+		p = p.str(settings[i]).uvarint(1).str(settings[i+1])
+	}
+	p = p.str("").
+		str("").    // interserver secret
+		uvarint(2). // stage: complete
+		uvarint(0). // no compression
+		str(query)
+	return p
+}
+
+func TestParseClickHouseQuery(t *testing.T) {
+	for _, revision := range []uint64{54460, 54445} {
+		q, ok := parseClickHouseQuery(clickHouseQueryPacket(revision,
+			"SELECT count() FROM events WHERE type = 'click'",
+			"max_threads", "4", "max_execution_time", "30"))
+		require.True(t, ok, "revision %d", revision)
+		assert.Equal(t, "a9c4ce1e-0f4d-4b8e-8f6a-3b6a0c8d6f10", q.queryID)
+		assert.Equal(t, []string{"max_threads=4", "max_execution_time=30"}, q.settings)
+		assert.Equal(t, "SELECT count() FROM events WHERE type = 'click'", q.query)
+	}
+}
+
+func TestParseClickHouseQuery_Truncated(t *testing.T) {
+	query := "INSERT INTO logs (timestamp, message) VALUES"
+	packet := clickHouseQueryPacket(54460, query)
+	q, ok := parseClickHouseQuery(packet[:len(packet)-10])
+	require.True(t, ok)
+	assert.Equal(t, query[:len(query)-10], q.query)
+	assert.Empty(t, q.settings)
+
+	// truncated before reaching the query text
+	_, ok = parseClickHouseQuery(packet[:40])
+	assert.False(t, ok)
+}
+
+func TestParseClickHouseQuery_NotClickHouse(t *testing.T) {
+	for _, buf := range [][]byte{
+		[]byte("SELECT * FROM users"),
+		[]byte("*2\r\n$3\r\nGET\r\n$5\r\nbeyla"),
+		{1, 0, 0, 0, 3, 'S', 'E', 'L', 'E', 'C', 'T', ' ', '1'},
+		{},
+	} {
+		_, ok := parseClickHouseQuery(buf)
+		assert.False(t, ok, "%q", buf)
+	}
+}
+
+func TestTCPToClickHouseToSpan(t *testing.T) {
+	trace := makeTCPReq("", 0, 10, 20, 0)
+	q, ok := parseClickHouseQuery(clickHouseQueryPacket(54460, "SELECT * FROM events", "max_threads", "4"))
+	require.True(t, ok)
+
+	span := TCPToClickHouseToSpan(&trace, q, clickHouseStatus([]byte{clickHouseServerException}))
+	assert.Equal(t, request.EventTypeSQLClient, span.Type)
+	assert.Equal(t, request.SQLSubtypeClickHouse, span.SubType)
+	assert.Equal(t, "SELECT", span.Method)
+	assert.Equal(t, "events", span.Path)
+	assert.Equal(t, "SELECT * FROM events", span.Statement)
+	assert.Equal(t, 1, span.Status)
+	assert.Equal(t, []string{"max_threads=4"}, span.ClickHouse.Settings)
+	assert.Equal(t, "clickhouse", span.DBSystemName())
+
+	assert.Equal(t, 0, clickHouseStatus([]byte{1, 0}))
+}
@@ -71,7 +71,7 @@ var MisclassifiedEvents = make(chan MisclassifiedEvent)
 
 func ptlog() *slog.Logger { return slog.With("component", "ebpf.ProcessTracer") }
 
-func ReadBPFTraceAsSpan(record *ringbuf.Record, filter ServiceFilter) (request.Span, bool, error) {
+func (p *Parser) ReadBPFTraceAsSpan(record *ringbuf.Record, filter ServiceFilter) (request.Span, bool, error) {
 	var eventType uint8
 
 	// we read the type first, depending on the type we decide what kind of record we have
@@ -84,7 +84,7 @@ func ReadBPFTraceAsSpan(record *ringbuf.Record, filter ServiceFilter) (request.S
 	case EventTypeSQL:
 		return ReadSQLRequestTraceAsSpan(record)
 	case EventTypeKHTTP:
-		return ReadHTTPInfoIntoSpan(record, filter)
+		return p.ReadHTTPInfoIntoSpan(record, filter)
 	case EventTypeKHTTP2:
 		return ReadHTTP2InfoIntoSpan(record, filter)
 	case EventTypeTCP:
@@ -103,8 +103,14 @@ func ReadBPFTraceAsSpan(record *ringbuf.Record, filter ServiceFilter) (request.S
 	if err != nil {
 		return request.Span{}, true, err
 	}
+	pid := request.PidInfo{HostPID: event.Pid.HostPid, UserPID: event.Pid.UserPid, Namespace: event.Pid.Ns}
+	if p.healthChecks.count(event.Type, pid, cBytes(event.Path[:])) {
+		return request.Span{}, true, nil
+	}
 
-	return HTTPRequestTraceToSpan(&event), false, nil
+	span := HTTPRequestTraceToSpan(&event)
+	p.parseElasticsearchRequest(&span, nil)
+	return span, false, nil
 }
 
 func ReadSQLRequestTraceAsSpan(record *ringbuf.Record) (request.Span, bool, error) {
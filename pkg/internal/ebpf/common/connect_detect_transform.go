@@ -0,0 +1,131 @@
+package ebpfcommon
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+const (
+	// unary Connect requests use the application/<codec> media type, and streaming requests
+	// use application/connect+<codec>
+	connectProtoContentType  = "application/proto"
+	connectJSONContentType   = "application/json"
+	connectStreamContentType = "application/connect+"
+	connectProtocolHeader    = "connect-protocol-version"
+
+	twirpProtoContentType = "application/protobuf"
+	twirpPathPrefix       = "/twirp"
+)
+
+// parseConnectRequest checks whether an HTTP request is a Connect or Twirp RPC call. In that case, it
+// converts the span to a gRPC span, whose method is the /package.Service/Method part of the request path.
+// Unary Connect calls with a JSON body are only recognized if they send the Connect-Protocol-Version
+// header, as they can't be distinguished from any other REST call otherwise.
+func parseConnectRequest(span *request.Span, buf []byte) {
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	if span.SubType != request.HTTPSubtypeNone || span.Method != http.MethodPost {
+		return
+	}
+	headers := buf
+	if idx := strings.Index(cstr(buf), "\r\n\r\n"); idx >= 0 {
+		headers = buf[:idx]
+	}
+	mediaType, _, _ := strings.Cut(strings.ToLower(httpHeader(headers, "content-type")), ";")
+	mediaType = strings.TrimSpace(mediaType)
+
+	path, subType := span.Path, request.RPCSubtypeConnect
+	switch {
+	case strings.HasPrefix(path, twirpPathPrefix+"/") &&
+		(mediaType == twirpProtoContentType || mediaType == connectJSONContentType):
+		path, subType = strings.TrimPrefix(path, twirpPathPrefix), request.RPCSubtypeTwirp
+	case mediaType == connectProtoContentType, strings.HasPrefix(mediaType, connectStreamContentType):
+	case mediaType == connectJSONContentType && httpHeader(headers, connectProtocolHeader) != "":
+	default:
+		return
+	}
+	// protobuf method names start with a letter, which discards REST-like paths such as /users/123
+	if !isGRPCMethodPath(path) || !isASCIILetter(path[strings.LastIndexByte(path, '/')+1]) {
+		return
+	}
+	toConnectSpan(span, path, subType)
+}
+
+// parseTwirpPath checks whether a request that is captured by the Go HTTP uprobes, which don't capture
+// the request headers, is a Twirp RPC call. As the content type is missing, the request must follow
+// the structure of the paths that the Twirp generated code serves: POST /twirp/package.Service/Method
+func parseTwirpPath(span *request.Span) {
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	if span.SubType != request.HTTPSubtypeNone || span.Method != http.MethodPost {
+		return
+	}
+	path, ok := strings.CutPrefix(span.Path, twirpPathPrefix+"/")
+	if !ok {
+		return
+	}
+	service, method, _ := strings.Cut(path, "/")
+	// the generated service and method names are CamelCase
+	if !isGRPCMethodPath("/"+path) || !isASCIILetter(service[0]) || method[0] < 'A' || method[0] > 'Z' {
+		return
+	}
+	toConnectSpan(span, "/"+path, request.RPCSubtypeTwirp)
+}
+
+// toConnectSpan converts an HTTP span of a Connect or Twirp call to an RPC span
+func toConnectSpan(span *request.Span, path string, subType int) {
+	if span.Type == request.EventTypeHTTP {
+		span.Type = request.EventTypeGRPC
+	} else {
+		span.Type = request.EventTypeGRPCClient
+	}
+	span.SubType = subType
+	span.Path = path
+	span.Status = connectStatusFromHTTP(span.Status)
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// connectStatusFromHTTP maps the HTTP status of a Connect or Twirp response to a gRPC status code.
+// Both protocols send the actual error code in the response body, which isn't captured, so this
+// reverses the mapping of the error codes to HTTP statuses that the protocols define, choosing
+// the most representative code when many of them share the same HTTP status.
+// https://connectrpc.com/docs/protocol/#error-codes
+// https://twitchtv.github.io/twirp/docs/spec_v7.html#error-codes
+func connectStatusFromHTTP(status int) int {
+	switch status {
+	case http.StatusOK:
+		return grpcStatusOK
+	case http.StatusBadRequest:
+		return grpcStatusInvalidArgument
+	case http.StatusUnauthorized:
+		return grpcStatusUnauthenticated
+	case http.StatusForbidden:
+		return grpcStatusPermissionDenied
+	case http.StatusNotFound:
+		return grpcStatusNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return grpcStatusDeadlineExceeded
+	case http.StatusConflict:
+		return grpcStatusAborted
+	case http.StatusPreconditionFailed:
+		return grpcStatusFailedPrecondition
+	case http.StatusTooManyRequests:
+		return grpcStatusResourceExhausted
+	case 499: // client closed request
+		return grpcStatusCanceled
+	case http.StatusInternalServerError:
+		return grpcStatusInternal
+	case http.StatusNotImplemented:
+		return grpcStatusUnimplemented
+	case http.StatusServiceUnavailable:
+		return grpcStatusUnavailable
+	}
+	return grpcStatusUnknown
+}
@@ -0,0 +1,160 @@
+package ebpfcommon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseConnectRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		reqType       request.EventType
+		buf           string
+		status        int
+		expectType    request.EventType
+		expectSubType int
+		expectPath    string
+		expectStatus  int
+	}{
+		{
+			name:    "unary proto server request",
+			reqType: request.EventTypeHTTP,
+			buf: "POST /acme.user.v1.UserService/GetUser HTTP/1.1\r\nHost: api\r\n" +
+				"Content-Type: application/proto\r\nConnect-Protocol-Version: 1\r\n\r\n\x0a\x03abc",
+			status:        200,
+			expectType:    request.EventTypeGRPC,
+			expectSubType: request.RPCSubtypeConnect,
+			expectPath:    "/acme.user.v1.UserService/GetUser",
+			expectStatus:  0,
+		},
+		{
+			name:    "unary JSON client request",
+			reqType: request.EventTypeHTTPClient,
+			buf: "POST /acme.user.v1.UserService/GetUser HTTP/1.1\r\n" +
+				"content-type: application/json\r\nconnect-protocol-version: 1\r\n\r\n{\"id\":\"abc\"}",
+			status:        404,
+			expectType:    request.EventTypeGRPCClient,
+			expectSubType: request.RPCSubtypeConnect,
+			expectPath:    "/acme.user.v1.UserService/GetUser",
+			expectStatus:  5,
+		},
+		{
+			name:    "streaming request",
+			reqType: request.EventTypeHTTP,
+			buf: "POST /acme.chat.v1.ChatService/Say HTTP/1.1\r\n" +
+				"Content-Type: application/connect+json\r\n\r\n",
+			status:        200,
+			expectType:    request.EventTypeGRPC,
+			expectSubType: request.RPCSubtypeConnect,
+			expectPath:    "/acme.chat.v1.ChatService/Say",
+			expectStatus:  0,
+		},
+		{
+			name:    "Twirp protobuf request",
+			reqType: request.EventTypeHTTPClient,
+			buf: "POST /twirp/acme.haberdasher.Haberdasher/MakeHat HTTP/1.1\r\n" +
+				"Content-Type: application/protobuf\r\n\r\n",
+			status:        503,
+			expectType:    request.EventTypeGRPCClient,
+			expectSubType: request.RPCSubtypeTwirp,
+			expectPath:    "/acme.haberdasher.Haberdasher/MakeHat",
+			expectStatus:  14,
+		},
+		{
+			name:    "Twirp JSON request",
+			reqType: request.EventTypeHTTP,
+			buf: "POST /twirp/acme.haberdasher.Haberdasher/MakeHat HTTP/1.1\r\n" +
+				"Content-Type: application/json; charset=utf-8\r\n\r\n{\"inches\":10}",
+			status:        400,
+			expectType:    request.EventTypeGRPC,
+			expectSubType: request.RPCSubtypeTwirp,
+			expectPath:    "/acme.haberdasher.Haberdasher/MakeHat",
+			expectStatus:  3,
+		},
+		{
+			name:    "JSON request without Connect header",
+			reqType: request.EventTypeHTTP,
+			buf: "POST /acme.user.v1.UserService/GetUser HTTP/1.1\r\n" +
+				"Content-Type: application/json\r\n\r\n{}",
+			status:       200,
+			expectType:   request.EventTypeHTTP,
+			expectPath:   "/acme.user.v1.UserService/GetUser",
+			expectStatus: 200,
+		},
+		{
+			name:    "REST path",
+			reqType: request.EventTypeHTTP,
+			buf: "POST /users/123 HTTP/1.1\r\n" +
+				"Content-Type: application/proto\r\n\r\n",
+			status:       200,
+			expectType:   request.EventTypeHTTP,
+			expectPath:   "/users/123",
+			expectStatus: 200,
+		},
+		{
+			name:    "GET request",
+			reqType: request.EventTypeHTTP,
+			buf: "GET /acme.user.v1.UserService/GetUser HTTP/1.1\r\n" +
+				"Content-Type: application/proto\r\n\r\n",
+			status:       200,
+			expectType:   request.EventTypeHTTP,
+			expectPath:   "/acme.user.v1.UserService/GetUser",
+			expectStatus: 200,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := makeBPFInfoWithBuf([]byte(tc.buf))
+			event.Type = uint8(tc.reqType)
+			event.Status = uint16(tc.status)
+			span, ignore, err := testParser().HTTPInfoEventToSpan(event)
+			assert.NoError(t, err)
+			assert.False(t, ignore)
+			assert.Equal(t, tc.expectType, span.Type)
+			assert.Equal(t, tc.expectSubType, span.SubType)
+			assert.Equal(t, tc.expectPath, span.Path)
+			assert.Equal(t, tc.expectStatus, span.Status)
+		})
+	}
+}
+
+func TestConnectSpanRPCAttributes(t *testing.T) {
+	span := request.Span{Type: request.EventTypeHTTP, Method: "POST", Path: "/twirp/acme.haberdasher.Haberdasher/MakeHat"}
+	parseConnectRequest(&span, []byte("POST /twirp/acme.haberdasher.Haberdasher/MakeHat HTTP/1.1\r\n"+
+		"Content-Type: application/protobuf\r\n\r\n"))
+	assert.Equal(t, "/acme.haberdasher.Haberdasher/MakeHat", span.TraceName())
+	assert.Equal(t, "twirp", span.RPCSystemName())
+	service, method := span.RPCServiceAndMethod()
+	assert.Equal(t, "acme.haberdasher.Haberdasher", service)
+	assert.Equal(t, "MakeHat", method)
+}
+
+func TestParseTwirpPath(t *testing.T) {
+	span := request.Span{Type: request.EventTypeHTTP, Method: "POST", Path: "/twirp/acme.haberdasher.Haberdasher/MakeHat", Status: 404}
+	parseTwirpPath(&span)
+	assert.Equal(t, request.EventTypeGRPC, span.Type)
+	assert.Equal(t, request.RPCSubtypeTwirp, span.SubType)
+	assert.Equal(t, "/acme.haberdasher.Haberdasher/MakeHat", span.Path)
+	assert.Equal(t, grpcStatusNotFound, span.Status)
+
+	span = request.Span{Type: request.EventTypeHTTPClient, Method: "POST", Path: "/twirp/Haberdasher/MakeHat", Status: 200}
+	parseTwirpPath(&span)
+	assert.Equal(t, request.EventTypeGRPCClient, span.Type)
+	assert.Equal(t, "/Haberdasher/MakeHat", span.Path)
+
+	for _, notTwirp := range []request.Span{
+		{Type: request.EventTypeHTTP, Method: "GET", Path: "/twirp/acme.Haberdasher/MakeHat"},
+		{Type: request.EventTypeHTTP, Method: "POST", Path: "/twirp/acme.Haberdasher/makeHat"},
+		{Type: request.EventTypeHTTP, Method: "POST", Path: "/twirp/acme.Haberdasher/"},
+		{Type: request.EventTypeHTTP, Method: "POST", Path: "/twirp/"},
+		{Type: request.EventTypeHTTP, Method: "POST", Path: "/twirp/users/123/Orders"},
+		{Type: request.EventTypeHTTP, Method: "POST", Path: "/api/acme.Haberdasher/MakeHat"},
+	} {
+		span := notTwirp
+		parseTwirpPath(&span)
+		assert.Equal(t, request.EventTypeHTTP, span.Type, notTwirp.Path)
+		assert.Equal(t, notTwirp.Path, span.Path)
+	}
+}
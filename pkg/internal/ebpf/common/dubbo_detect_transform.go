@@ -0,0 +1,191 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// Dubbo2 protocol header: 2 bytes magic, 1 byte flags, 1 byte status,
+// 8 bytes request ID and 4 bytes body length
+const (
+	dubboHeaderLen     = 16
+	dubboMagic         = 0xdabb
+	dubboFlagRequest   = 0x80
+	dubboFlagEvent     = 0x20
+	dubboSerialization = 0x1f
+	// we only support the Hessian2 serialization, which is the default one
+	dubboHessian2 = 2
+)
+
+// Dubbo2 response status codes
+const (
+	dubboStatusOK                = 20
+	dubboStatusClientTimeout     = 30
+	dubboStatusServerTimeout     = 31
+	dubboStatusBadRequest        = 40
+	dubboStatusBadResponse       = 50
+	dubboStatusServiceNotFound   = 60
+	dubboStatusServiceError      = 70
+	dubboStatusServerError       = 80
+	dubboStatusClientError       = 90
+	dubboStatusThreadpoolExhaust = 100
+)
+
+type dubboRequest struct {
+	service string
+	version string
+	method  string
+}
+
+// isDubboRequest returns whether the buffer starts with the header of a Dubbo2 request
+// that is serialized with Hessian2. Heartbeats and other events are ignored.
+func isDubboRequest(buf []byte) bool {
+	if len(buf) < dubboHeaderLen || binary.BigEndian.Uint16(buf) != dubboMagic {
+		return false
+	}
+	flags := buf[2]
+	return flags&dubboFlagRequest != 0 && flags&dubboFlagEvent == 0 && flags&dubboSerialization == dubboHessian2
+}
+
+func isDubboResponse(buf []byte) bool {
+	return len(buf) >= dubboHeaderLen && binary.BigEndian.Uint16(buf) == dubboMagic && buf[2]&dubboFlagRequest == 0
+}
+
+// parseDubboRequest reads the first fields of a Dubbo2 request body: the Dubbo version,
+// the service path, the service version and the method name.
+func parseDubboRequest(buf []byte) (*dubboRequest, bool) {
+	if !isDubboRequest(buf) {
+		return nil, false
+	}
+	r := hessianReader{buf: buf[dubboHeaderLen:]}
+	r.string() // dubbo version
+	req := dubboRequest{
+		service: r.string(),
+		version: r.string(),
+		method:  r.string(),
+	}
+	if r.failed || req.service == "" || req.method == "" {
+		return nil, false
+	}
+	return &req, true
+}
+
+// hessianReader decodes the string values of the Hessian2 serialization format
+type hessianReader struct {
+	buf    []byte
+	pos    int
+	failed bool
+}
+
+func (r *hessianReader) byte() byte {
+	if r.failed || r.pos >= len(r.buf) {
+		r.failed = true
+		return 0
+	}
+	r.pos++
+	return r.buf[r.pos-1]
+}
+
+// string reads a non-chunked Hessian2 string, whose length is specified in UTF-16 characters
+func (r *hessianReader) string() string {
+	var length int
+	switch tag := r.byte(); {
+	case tag <= 0x1f:
+		length = int(tag)
+	case tag >= 0x30 && tag <= 0x33:
+		length = int(tag-0x30)<<8 | int(r.byte())
+	case tag == 'S':
+		length = int(r.byte())<<8 | int(r.byte())
+	case tag == 'N':
+		return ""
+	default:
+		r.failed = true
+		return ""
+	}
+	sb := strings.Builder{}
+	for i := 0; i < length && !r.failed; i++ {
+		c, size := utf8.DecodeRune(r.buf[r.pos:])
+		if c == utf8.RuneError {
+			r.failed = true
+			return ""
+		}
+		sb.WriteRune(c)
+		r.pos += size
+		// characters outside the basic multilingual plane count as two UTF-16 characters
+		if c > 0xFFFF {
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// dubboStatusToGRPC maps the status of a Dubbo2 response to a gRPC status code,
+// so Dubbo spans can be handled as any other RPC span.
+func dubboStatusToGRPC(resp []byte) int {
+	if !isDubboResponse(resp) {
+		return grpcStatusOK
+	}
+	switch resp[3] {
+	case dubboStatusOK:
+		return grpcStatusOK
+	case dubboStatusClientTimeout, dubboStatusServerTimeout:
+		return grpcStatusDeadlineExceeded
+	case dubboStatusBadRequest:
+		return grpcStatusInvalidArgument
+	case dubboStatusServiceNotFound:
+		return grpcStatusUnimplemented
+	case dubboStatusThreadpoolExhaust:
+		return grpcStatusResourceExhausted
+	case dubboStatusBadResponse, dubboStatusServerError, dubboStatusClientError:
+		return grpcStatusInternal
+	case dubboStatusServiceError:
+		return grpcStatusUnknown
+	}
+	return grpcStatusUnknown
+}
+
+func TCPToDubboToSpan(trace *TCPRequestInfo, req *dubboRequest, status int) request.Span {
+	peer := ""
+	hostname := ""
+	hostPort := 0
+
+	if trace.ConnInfo.S_port != 0 || trace.ConnInfo.D_port != 0 {
+		peer, hostname = (*BPFConnInfo)(unsafe.Pointer(&trace.ConnInfo)).reqHostInfo()
+		hostPort = int(trace.ConnInfo.D_port)
+	}
+
+	reqType := request.EventTypeGRPCClient
+	if trace.Direction == 0 {
+		reqType = request.EventTypeGRPC
+	}
+
+	return request.Span{
+		Type:          reqType,
+		SubType:       request.RPCSubtypeDubbo,
+		Path:          req.service + "/" + req.method,
+		Peer:          peer,
+		PeerPort:      int(trace.ConnInfo.S_port),
+		Host:          hostname,
+		HostPort:      hostPort,
+		ContentLength: int64(trace.Len),
+		RequestStart:  int64(trace.StartMonotimeNs),
+		Start:         int64(trace.StartMonotimeNs),
+		End:           int64(trace.EndMonotimeNs),
+		Status:        status,
+		TraceID:       trace2.TraceID(trace.Tp.TraceId),
+		SpanID:        trace2.SpanID(trace.Tp.SpanId),
+		ParentSpanID:  trace2.SpanID(trace.Tp.ParentId),
+		Flags:         trace.Tp.Flags,
+		Pid: request.PidInfo{
+			HostPID:   trace.Pid.HostPid,
+			UserPID:   trace.Pid.UserPid,
+			Namespace: trace.Pid.Ns,
+		},
+	}
+}
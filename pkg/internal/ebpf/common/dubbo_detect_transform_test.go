@@ -0,0 +1,122 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// hessianString encodes a short Hessian2 string
+func hessianString(s string) []byte {
+	l := len([]rune(s))
+	if l <= 0x1f {
+		return append([]byte{byte(l)}, s...)
+	}
+	return append([]byte{'S', byte(l >> 8), byte(l)}, s...)
+}
+
+func dubboPacket(flags, status byte, fields ...string) []byte {
+	body := []byte{}
+	for _, f := range fields {
+		body = append(body, hessianString(f)...)
+	}
+	header := []byte{0xda, 0xbb, flags, status}
+	header = binary.BigEndian.AppendUint64(header, 1)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(body)))
+	return append(header, body...)
+}
+
+func dubboRequestPacket(service, method string) []byte {
+	return dubboPacket(0xC2, 0, "2.0.2", service, "0.0.0", method, "Ljava/lang/String;")
+}
+
+func TestParseDubboRequest(t *testing.T) {
+	req, ok := parseDubboRequest(dubboRequestPacket("org.apache.dubbo.demo.DemoService", "sayHello"))
+	require.True(t, ok)
+	assert.Equal(t, "org.apache.dubbo.demo.DemoService", req.service)
+	assert.Equal(t, "0.0.0", req.version)
+	assert.Equal(t, "sayHello", req.method)
+
+	// non-ASCII characters are counted as characters and not as bytes
+	req, ok = parseDubboRequest(dubboRequestPacket("com.example.Ñandú.ServiceWithAVeryLongName", "get"))
+	require.True(t, ok)
+	assert.Equal(t, "com.example.Ñandú.ServiceWithAVeryLongName", req.service)
+	assert.Equal(t, "get", req.method)
+}
+
+func TestParseDubboRequest_Invalid(t *testing.T) {
+	valid := dubboRequestPacket("org.apache.dubbo.demo.DemoService", "sayHello")
+	for _, tc := range []struct {
+		name string
+		buf  []byte
+	}{
+		{name: "heartbeat", buf: dubboPacket(0xE2, 0, "N")},
+		{name: "response", buf: dubboPacket(0x02, dubboStatusOK, "hello")},
+		{name: "other serialization", buf: append([]byte{0xda, 0xbb, 0xC6}, valid[3:]...)},
+		{name: "truncated", buf: valid[:30]},
+		{name: "redis", buf: []byte("*1\r\n$4\r\nPING\r\n")},
+		{name: "http", buf: []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := parseDubboRequest(tc.buf)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestDubboStatusToGRPC(t *testing.T) {
+	assert.Equal(t, grpcStatusOK, dubboStatusToGRPC(dubboPacket(0x02, dubboStatusOK)))
+	assert.Equal(t, grpcStatusDeadlineExceeded, dubboStatusToGRPC(dubboPacket(0x02, dubboStatusServerTimeout)))
+	assert.Equal(t, grpcStatusUnimplemented, dubboStatusToGRPC(dubboPacket(0x02, dubboStatusServiceNotFound)))
+	assert.Equal(t, grpcStatusUnknown, dubboStatusToGRPC(dubboPacket(0x02, dubboStatusServiceError)))
+	assert.Equal(t, grpcStatusInternal, dubboStatusToGRPC(dubboPacket(0x02, dubboStatusServerError)))
+	// response not captured
+	assert.Equal(t, grpcStatusOK, dubboStatusToGRPC(nil))
+}
+
+func TestTCPToDubboToSpan(t *testing.T) {
+	req := dubboRequestPacket("org.apache.dubbo.demo.DemoService", "sayHello")
+	resp := dubboPacket(0x02, dubboStatusServiceNotFound)
+	for _, tc := range []struct {
+		name      string
+		direction int
+		reversed  bool
+		spanType  request.EventType
+	}{
+		{name: "server", direction: 0, spanType: request.EventTypeGRPC},
+		{name: "client", direction: 1, spanType: request.EventTypeGRPCClient},
+		{name: "reversed", direction: 1, reversed: true, spanType: request.EventTypeGRPC},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trace := makeTCPReq(string(req), tc.direction, 40000, 20880, 5)
+			copy(trace.Rbuf[:], resp)
+			trace.RespLen = uint32(len(resp))
+			if tc.reversed {
+				trace = makeTCPReq(string(resp), tc.direction, 20880, 40000, 5)
+				copy(trace.Rbuf[:], req)
+				trace.RespLen = uint32(len(req))
+			}
+			binaryRecord := bytes.Buffer{}
+			require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+			span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+			require.NoError(t, err)
+			require.False(t, ignore)
+			assert.Equal(t, tc.spanType, span.Type)
+			assert.Equal(t, request.RPCSubtypeDubbo, span.SubType)
+			assert.Equal(t, "org.apache.dubbo.demo.DemoService/sayHello", span.Path)
+			assert.Equal(t, grpcStatusUnimplemented, span.Status)
+			assert.Equal(t, 20880, span.HostPort)
+			assert.Equal(t, "apache_dubbo", span.RPCSystemName())
+
+			service, method := span.RPCServiceAndMethod()
+			assert.Equal(t, "org.apache.dubbo.demo.DemoService", service)
+			assert.Equal(t, "sayHello", method)
+		})
+	}
+}
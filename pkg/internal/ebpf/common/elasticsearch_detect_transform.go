@@ -0,0 +1,187 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// elasticsearchEndpoints are the REST API endpoints of Elasticsearch and OpenSearch
+// that allow us identifying their traffic by the shape of the request path
+var elasticsearchEndpoints = map[string]struct{}{
+	"_search":          {},
+	"_msearch":         {},
+	"_async_search":    {},
+	"_count":           {},
+	"_bulk":            {},
+	"_doc":             {},
+	"_create":          {},
+	"_update":          {},
+	"_source":          {},
+	"_mget":            {},
+	"_delete_by_query": {},
+	"_update_by_query": {},
+	"_reindex":         {},
+	"_refresh":         {},
+	"_flush":           {},
+	"_mapping":         {},
+	"_settings":        {},
+	"_analyze":         {},
+	"_explain":         {},
+	"_validate":        {},
+	"_field_caps":      {},
+	"_termvectors":     {},
+	"_mtermvectors":    {},
+	"_search_template": {},
+	"_knn_search":      {},
+	"_eql":             {},
+	"_sql":             {},
+	"_pit":             {},
+	"_rank_eval":       {},
+}
+
+// parseElasticsearchRequest checks whether an HTTP client request is targeting an
+// Elasticsearch or OpenSearch REST API. In that case, it decorates the span with the
+// target index, the operation and, if the request body was captured, the sanitized query DSL.
+// The shape of the path is not enough, as other REST APIs might have path segments starting
+// with underscore, so the request must also target a configured Elasticsearch port, or be sent
+// by an Elasticsearch or OpenSearch client. The buffer is nil for the Go requests.
+func (p *Parser) parseElasticsearchRequest(span *request.Span, buf []byte) {
+	if span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	index, operation, ok := elasticsearchPathParts(span.Path)
+	if !ok {
+		return
+	}
+	if _, ok := p.elasticsearchPorts[span.HostPort]; !ok && !isElasticsearchClient(buf) {
+		return
+	}
+	span.SubType = request.HTTPSubtypeElasticsearch
+	span.Elasticsearch = &request.Elasticsearch{
+		DBOperationName:  operation,
+		DBCollectionName: index,
+	}
+	if body := httpRequestBody(buf); len(body) > 0 {
+		span.Elasticsearch.DBQueryText = sanitizeQueryDSL(body)
+	}
+}
+
+// isElasticsearchClient returns whether the captured request carries the headers that the
+// official Elasticsearch and OpenSearch client libraries add
+func isElasticsearchClient(buf []byte) bool {
+	if len(buf) == 0 {
+		return false
+	}
+	if headerValue(buf, "x-elastic-client-meta") != "" {
+		return true
+	}
+	userAgent := strings.ToLower(headerValue(buf, "user-agent"))
+	return strings.Contains(userAgent, "elasticsearch") || strings.Contains(userAgent, "opensearch")
+}
+
+// elasticsearchPathParts extracts the index and operation from paths
+// with the shape [/{index}]/_{endpoint}[/...]
+func elasticsearchPathParts(path string) (index, operation string, ok bool) {
+	path = strings.TrimPrefix(removeQuery(path), "/")
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if _, ok := elasticsearchEndpoints[part]; !ok {
+			continue
+		}
+		if i > 0 && !strings.HasPrefix(parts[0], "_") {
+			index = parts[0]
+		}
+		return index, strings.TrimPrefix(part, "_"), true
+	}
+	return "", "", false
+}
+
+// httpRequestBody returns the part of the captured HTTP request buffer that follows the headers
+func httpRequestBody(buf []byte) []byte {
+	if end := bytes.IndexByte(buf, 0); end >= 0 {
+		buf = buf[:end]
+	}
+	idx := bytes.Index(buf, []byte("\r\n\r\n"))
+	if idx < 0 {
+		return nil
+	}
+	return buf[idx+4:]
+}
+
+// sanitizeQueryDSL returns the JSON query DSL (or the NDJSON bulk actions) keeping
+// its structure and field names but replacing all the literal values by a '?' symbol.
+// Since the body might be truncated, the sanitization is performed on a best-effort basis.
+// nolint:cyclop
+func sanitizeQueryDSL(body []byte) string {
+	sb := strings.Builder{}
+	// stack of the open JSON containers: '{' for objects or '[' for arrays
+	var containers []byte
+	expectKey := false
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch c {
+		case '{', '[':
+			containers = append(containers, c)
+			expectKey = c == '{'
+			sb.WriteByte(c)
+		case '}', ']':
+			if len(containers) > 0 {
+				containers = containers[:len(containers)-1]
+			}
+			expectKey = false
+			sb.WriteByte(c)
+		case ',':
+			expectKey = len(containers) > 0 && containers[len(containers)-1] == '{'
+			sb.WriteByte(c)
+		case ':':
+			expectKey = false
+			sb.WriteByte(c)
+		case ' ', '\t', '\r':
+			// ignore whitespaces
+		case '\n':
+			// keep NDJSON lines separated
+			if len(containers) == 0 && sb.Len() > 0 {
+				sb.WriteByte(c)
+			}
+		case '"':
+			end := jsonStringEnd(body, i+1)
+			if expectKey {
+				sb.Write(body[i:end])
+			} else {
+				sb.WriteByte('?')
+			}
+			i = end - 1
+		default:
+			// numbers, booleans and nulls
+			for i+1 < len(body) && !isJSONDelimiter(body[i+1]) {
+				i++
+			}
+			sb.WriteByte('?')
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// jsonStringEnd returns the position after the closing quote of a JSON string, or
+// the length of the buffer if the string is truncated
+func jsonStringEnd(buf []byte, start int) int {
+	for i := start; i < len(buf); i++ {
+		switch buf[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(buf)
+}
+
+func isJSONDelimiter(c byte) bool {
+	switch c {
+	case ',', ':', '{', '}', '[', ']', '"', ' ', '\t', '\r', '\n':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package ebpfcommon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/config"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestElasticsearchPathParts(t *testing.T) {
+	for _, tc := range []struct {
+		path      string
+		index     string
+		operation string
+		ok        bool
+	}{
+		{path: "/my-index/_search", index: "my-index", operation: "search", ok: true},
+		{path: "/my-index/_search?q=user:kimchy", index: "my-index", operation: "search", ok: true},
+		{path: "/_search", index: "", operation: "search", ok: true},
+		{path: "/_bulk", index: "", operation: "bulk", ok: true},
+		{path: "/logs-2024/_doc/12345", index: "logs-2024", operation: "doc", ok: true},
+		{path: "/idx1,idx2/_msearch", index: "idx1,idx2", operation: "msearch", ok: true},
+		{path: "/users/12345", ok: false},
+		{path: "/", ok: false},
+		{path: "", ok: false},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			index, operation, ok := elasticsearchPathParts(tc.path)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.index, index)
+			assert.Equal(t, tc.operation, operation)
+		})
+	}
+}
+
+func TestSanitizeQueryDSL(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		body   string
+		expect string
+	}{
+		{
+			name:   "simple query",
+			body:   `{"query": {"match": {"user.id": "kimchy"}}, "size": 10, "explain": true}`,
+			expect: `{"query":{"match":{"user.id":?}},"size":?,"explain":?}`,
+		},
+		{
+			name:   "arrays and escaped strings",
+			body:   `{"terms": {"tags": ["a \"quoted\" tag", 3, null]}}`,
+			expect: `{"terms":{"tags":[?,?,?]}}`,
+		},
+		{
+			name:   "bulk NDJSON",
+			body:   "{\"index\":{\"_index\":\"test\",\"_id\":\"1\"}}\n{\"field1\":\"value1\"}\n",
+			expect: "{\"index\":{\"_index\":?,\"_id\":?}}\n{\"field1\":?}",
+		},
+		{
+			name:   "truncated body",
+			body:   `{"query": {"match": {"message": "this is a trunc`,
+			expect: `{"query":{"match":{"message":?`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, sanitizeQueryDSL([]byte(tc.body)))
+		})
+	}
+}
+
+func TestParseElasticsearchRequest(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{ElasticsearchPorts: []int{9200}}, "", "")
+	buf := []byte("POST /products/_search HTTP/1.1\r\nHost: es:9200\r\nContent-Type: application/json\r\n\r\n" +
+		`{"query":{"term":{"sku":"ABC-123"}}}`)
+	span := request.Span{Type: request.EventTypeHTTPClient, Method: "POST", Path: "/products/_search", HostPort: 9200}
+	parser.parseElasticsearchRequest(&span, buf)
+	assert.Equal(t, request.HTTPSubtypeElasticsearch, span.SubType)
+	require.NotNil(t, span.Elasticsearch)
+	assert.Equal(t, request.Elasticsearch{
+		DBOperationName:  "search",
+		DBCollectionName: "products",
+		DBQueryText:      `{"query":{"term":{"sku":?}}}`,
+	}, *span.Elasticsearch)
+	assert.Equal(t, "search products", span.TraceName())
+
+	// Go requests are detected by their port
+	span = request.Span{Type: request.EventTypeHTTPClient, Method: "GET", Path: "/products/_count", HostPort: 9200}
+	parser.parseElasticsearchRequest(&span, nil)
+	assert.Equal(t, request.HTTPSubtypeElasticsearch, span.SubType)
+
+	// server spans and non-Elasticsearch paths are ignored
+	span = request.Span{Type: request.EventTypeHTTP, Method: "POST", Path: "/products/_search", HostPort: 9200}
+	parser.parseElasticsearchRequest(&span, nil)
+	assert.Equal(t, request.HTTPSubtypeNone, span.SubType)
+	assert.Nil(t, span.Elasticsearch)
+
+	span = request.Span{Type: request.EventTypeHTTPClient, Method: "GET", Path: "/products/123", HostPort: 9200}
+	parser.parseElasticsearchRequest(&span, nil)
+	assert.Equal(t, request.HTTPSubtypeNone, span.SubType)
+	assert.Nil(t, span.Elasticsearch)
+}
+
+func TestParseElasticsearchRequest_Corroboration(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{ElasticsearchPorts: []int{9200}}, "", "")
+	for _, tc := range []struct {
+		name   string
+		buf    string
+		port   int
+		expect bool
+	}{{
+		name: "other REST API with an Elasticsearch-like path",
+		buf:  "POST /api/_search HTTP/1.1\r\nHost: backend:8080\r\nUser-Agent: curl/8.5.0\r\n\r\n",
+		port: 8080,
+	}, {
+		name: "other REST API with an Elasticsearch-like document path",
+		buf:  "PUT /users/_doc/1 HTTP/1.1\r\nHost: backend:8080\r\n\r\n",
+		port: 8080,
+	}, {
+		name: "Go request without the request buffer",
+		port: 8080,
+	}, {
+		name:   "Elasticsearch client metadata header",
+		buf:    "POST /products/_search HTTP/1.1\r\nHost: es:443\r\nX-Elastic-Client-Meta: es=8.12.0,py=3.11.4\r\n\r\n",
+		port:   443,
+		expect: true,
+	}, {
+		name:   "OpenSearch client user agent",
+		buf:    "POST /_bulk HTTP/1.1\r\nHost: search:443\r\nUser-Agent: opensearch-py/2.4.2 (Python 3.11.4)\r\n\r\n",
+		port:   443,
+		expect: true,
+	}, {
+		name:   "Elasticsearch port",
+		buf:    "POST /products/_search HTTP/1.1\r\nHost: es:9200\r\n\r\n",
+		port:   9200,
+		expect: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			span := request.Span{Type: request.EventTypeHTTPClient, Method: "POST", HostPort: tc.port}
+			var buf []byte
+			if tc.buf != "" {
+				buf = []byte(tc.buf)
+				span.Path = string(requestPath(buf))
+			} else {
+				span.Path = "/products/_search"
+			}
+			parser.parseElasticsearchRequest(&span, buf)
+			if tc.expect {
+				assert.Equal(t, request.HTTPSubtypeElasticsearch, span.SubType)
+				assert.NotNil(t, span.Elasticsearch)
+			} else {
+				assert.Equal(t, request.HTTPSubtypeNone, span.SubType)
+				assert.Nil(t, span.Elasticsearch)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package ebpfcommon
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// ftpTransferCommands are the FTP commands that open a data channel to transfer a file
+// or a directory listing
+var ftpTransferCommands = map[string]struct{}{
+	"RETR": {},
+	"STOR": {},
+	"STOU": {},
+	"APPE": {},
+	"LIST": {},
+	"NLST": {},
+	"MLSD": {},
+}
+
+// ftpTransferSize matches the transfer size that most FTP servers report in the replies to the
+// transfer commands, e.g. "150 Opening BINARY mode data connection for file.txt (1234 bytes)"
+// or "226 Transfer complete. 1234 bytes transferred"
+var ftpTransferSize = regexp.MustCompile(`(\d+) bytes\b`)
+
+type ftpRequest struct {
+	command string
+	path    string
+}
+
+// parseFTPCommand parses a transfer command from the FTP control channel. The commands
+// are sent in their own line, so the line length must match the sent bytes.
+func parseFTPCommand(buf []byte, totalLen int64) (*ftpRequest, bool) {
+	line := string(buf)
+	if int64(len(line)) != totalLen || !strings.HasSuffix(line, "\r\n") {
+		return nil, false
+	}
+	line = line[:len(line)-2]
+	if strings.ContainsAny(line, "\r\n") {
+		return nil, false
+	}
+	command, path, _ := strings.Cut(line, " ")
+	command = strings.ToUpper(command)
+	if _, ok := ftpTransferCommands[command]; !ok {
+		return nil, false
+	}
+	return &ftpRequest{command: command, path: path}, true
+}
+
+// parseFTPReply returns the reply code of an FTP reply, as well as the transfer size,
+// if it is reported in the reply text
+func parseFTPReply(buf []byte) (int, int64, bool) {
+	reply := cstr(buf)
+	if len(reply) < 4 || (reply[3] != ' ' && reply[3] != '-') {
+		return 0, 0, false
+	}
+	code, err := strconv.Atoi(reply[:3])
+	if err != nil || code < 100 || code >= 600 {
+		return 0, 0, false
+	}
+	var size int64
+	line, _, _ := strings.Cut(reply, "\r\n")
+	if m := ftpTransferSize.FindStringSubmatch(line); m != nil {
+		size, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	return code, size, true
+}
+
+func TCPToFTPToSpan(trace *TCPRequestInfo, req *ftpRequest, code int, size int64) request.Span {
+	peer := ""
+	hostname := ""
+	hostPort := 0
+
+	if trace.ConnInfo.S_port != 0 || trace.ConnInfo.D_port != 0 {
+		peer, hostname = (*BPFConnInfo)(unsafe.Pointer(&trace.ConnInfo)).reqHostInfo()
+		hostPort = int(trace.ConnInfo.D_port)
+	}
+
+	reqType := request.EventTypeFTPClient
+	if trace.Direction == 0 {
+		reqType = request.EventTypeFTPServer
+	}
+
+	return request.Span{
+		Type:          reqType,
+		Method:        req.command,
+		Path:          req.path,
+		Peer:          peer,
+		PeerPort:      int(trace.ConnInfo.S_port),
+		Host:          hostname,
+		HostPort:      hostPort,
+		ContentLength: size,
+		RequestStart:  int64(trace.StartMonotimeNs),
+		Start:         int64(trace.StartMonotimeNs),
+		End:           int64(trace.EndMonotimeNs),
+		Status:        code,
+		TraceID:       trace2.TraceID(trace.Tp.TraceId),
+		SpanID:        trace2.SpanID(trace.Tp.SpanId),
+		ParentSpanID:  trace2.SpanID(trace.Tp.ParentId),
+		Flags:         trace.Tp.Flags,
+		Pid: request.PidInfo{
+			HostPID:   trace.Pid.HostPid,
+			UserPID:   trace.Pid.UserPid,
+			Namespace: trace.Pid.Ns,
+		},
+	}
+}
@@ -0,0 +1,129 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseFTPCommand(t *testing.T) {
+	for _, tc := range []struct {
+		line    string
+		command string
+		path    string
+	}{
+		{line: "RETR /pub/file.txt\r\n", command: "RETR", path: "/pub/file.txt"},
+		{line: "stor upload with spaces.bin\r\n", command: "STOR", path: "upload with spaces.bin"},
+		{line: "LIST\r\n", command: "LIST"},
+		{line: "MLSD /pub\r\n", command: "MLSD", path: "/pub"},
+	} {
+		t.Run(tc.line, func(t *testing.T) {
+			req, ok := parseFTPCommand([]byte(tc.line), int64(len(tc.line)))
+			require.True(t, ok)
+			assert.Equal(t, tc.command, req.command)
+			assert.Equal(t, tc.path, req.path)
+		})
+	}
+}
+
+func TestParseFTPCommand_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		line  string
+		total int64
+	}{
+		{name: "not a transfer command", line: "USER anonymous\r\n", total: 16},
+		{name: "length mismatch", line: "RETR file.txt\r\n", total: 100},
+		{name: "no line end", line: "RETR file.txt", total: 13},
+		{name: "several lines", line: "RETR a\r\nRETR b\r\n", total: 16},
+		{name: "redis", line: "*1\r\n$4\r\nPING\r\n", total: 14},
+		{name: "http", line: "GET / HTTP/1.1\r\n", total: 16},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := parseFTPCommand([]byte(tc.line), tc.total)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestParseFTPReply(t *testing.T) {
+	for _, tc := range []struct {
+		reply string
+		code  int
+		size  int64
+		ok    bool
+	}{
+		{reply: "150 Opening BINARY mode data connection for file.txt (1234 bytes).\r\n", code: 150, size: 1234, ok: true},
+		{reply: "226 Transfer complete. 5678 bytes transferred.\r\n", code: 226, size: 5678, ok: true},
+		{reply: "125 Data connection already open; transfer starting.\r\n", code: 125, ok: true},
+		{reply: "550 file.txt: No such file or directory\r\n", code: 550, ok: true},
+		{reply: "226-Transfer complete\r\n226 10 bytes sent\r\n", code: 226, ok: true},
+		{reply: "HTTP/1.1 200 OK\r\n"},
+		{reply: "999 unknown\r\n"},
+		{reply: "+OK\r\n"},
+	} {
+		t.Run(tc.reply, func(t *testing.T) {
+			code, size, ok := parseFTPReply([]byte(tc.reply))
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.size, size)
+		})
+	}
+}
+
+func TestTCPToFTPToSpan(t *testing.T) {
+	req := "RETR /pub/file.txt\r\n"
+	resp := "150 Opening BINARY mode data connection for /pub/file.txt (1234 bytes).\r\n"
+	for _, tc := range []struct {
+		name      string
+		direction int
+		reversed  bool
+		spanType  request.EventType
+	}{
+		{name: "server", direction: 0, spanType: request.EventTypeFTPServer},
+		{name: "client", direction: 1, spanType: request.EventTypeFTPClient},
+		{name: "reversed", direction: 1, reversed: true, spanType: request.EventTypeFTPServer},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trace := makeTCPReq(req, tc.direction, 40000, 21, 5)
+			copy(trace.Rbuf[:], resp)
+			trace.RespLen = uint32(len(resp))
+			if tc.reversed {
+				trace = makeTCPReq(resp, tc.direction, 21, 40000, 5)
+				copy(trace.Rbuf[:], req)
+				trace.RespLen = uint32(len(req))
+			}
+			binaryRecord := bytes.Buffer{}
+			require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+			span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+			require.NoError(t, err)
+			require.False(t, ignore)
+			assert.Equal(t, tc.spanType, span.Type)
+			assert.Equal(t, "RETR", span.Method)
+			assert.Equal(t, "/pub/file.txt", span.Path)
+			assert.Equal(t, "FTP RETR", span.TraceName())
+			assert.Equal(t, 150, span.Status)
+			assert.Equal(t, int64(1234), span.ContentLength)
+			assert.Equal(t, 21, span.HostPort)
+			assert.Equal(t, codes.Unset, request.SpanStatusCode(&span))
+		})
+	}
+}
+
+func TestTCPToFTPToSpan_Error(t *testing.T) {
+	trace := makeTCPReq("STOR report.csv\r\n", 1, 40000, 21, 5)
+	resp := "553 Could not create file.\r\n"
+	copy(trace.Rbuf[:], resp)
+	trace.RespLen = uint32(len(resp))
+
+	span := TCPToFTPToSpan(&trace, &ftpRequest{command: "STOR", path: "report.csv"}, 553, 0)
+	assert.Equal(t, request.EventTypeFTPClient, span.Type)
+	assert.Equal(t, codes.Error, request.SpanStatusCode(&span))
+}
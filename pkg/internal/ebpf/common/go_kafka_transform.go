@@ -22,6 +22,11 @@ func ReadGoSaramaRequestIntoSpan(record *ringbuf.Record) (request.Span, bool, er
 	info, err := ProcessKafkaRequest(event.Buf[:])
 
 	if err == nil {
+		if info.Operation == Produce {
+			// the header has been already validated by ProcessKafkaRequest
+			header, _ := parseKafkaHeader(event.Buf[:])
+			info.Record = parseKafkaProduceRequest(event.Buf[:], header)
+		}
 		return GoKafkaSaramaToSpan(&event, info), false, nil
 	}
 
@@ -57,6 +62,7 @@ func GoKafkaSaramaToSpan(event *GoSaramaClientInfo, data *KafkaInfo) request.Spa
 			UserPID:   event.Pid.UserPid,
 			Namespace: event.Pid.Ns,
 		},
+		KafkaRecord: data.Record,
 	}
 }
 
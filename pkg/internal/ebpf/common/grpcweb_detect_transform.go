@@ -0,0 +1,114 @@
+package ebpfcommon
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	// 1 byte for the flags and 4 bytes for the message length
+	grpcWebFrameHeaderLen = 5
+)
+
+// gRPC status codes, as defined in google.golang.org/grpc/codes
+const (
+	grpcStatusOK                 = 0
+	grpcStatusCanceled           = 1
+	grpcStatusUnknown            = 2
+	grpcStatusInvalidArgument    = 3
+	grpcStatusDeadlineExceeded   = 4
+	grpcStatusNotFound           = 5
+	grpcStatusPermissionDenied   = 7
+	grpcStatusResourceExhausted  = 8
+	grpcStatusFailedPrecondition = 9
+	grpcStatusAborted            = 10
+	grpcStatusUnimplemented      = 12
+	grpcStatusInternal           = 13
+	grpcStatusUnavailable        = 14
+	grpcStatusUnauthenticated    = 16
+)
+
+// parseGRPCWebRequest checks whether an HTTP/1.1 request carries gRPC-Web traffic. In that case,
+// it converts the span to a gRPC span, whose method is the request path, and whose content length
+// is the length of the first message in the gRPC-Web framing of the request body.
+func parseGRPCWebRequest(span *request.Span, buf []byte) {
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	if span.SubType != request.HTTPSubtypeNone || span.Method != http.MethodPost || !isGRPCMethodPath(span.Path) {
+		return
+	}
+	headers, body := buf, []byte(nil)
+	if idx := strings.Index(cstr(buf), "\r\n\r\n"); idx >= 0 {
+		headers, body = buf[:idx], buf[idx+4:]
+	}
+	mediaType, _, _ := strings.Cut(strings.ToLower(httpHeader(headers, "content-type")), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if !strings.HasPrefix(mediaType, grpcWebContentType) {
+		return
+	}
+	if span.Type == request.EventTypeHTTP {
+		span.Type = request.EventTypeGRPC
+	} else {
+		span.Type = request.EventTypeGRPCClient
+	}
+	span.Status = grpcStatusFromHTTP(span.Status)
+	if l, ok := grpcWebMessageLength(body, strings.HasPrefix(mediaType, grpcWebTextContentType)); ok {
+		span.ContentLength = l
+	}
+}
+
+// isGRPCMethodPath returns whether the path has the /package.Service/Method shape
+func isGRPCMethodPath(path string) bool {
+	service, method, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return ok && service != "" && method != "" && !strings.ContainsAny(method, "/?")
+}
+
+// grpcWebMessageLength returns the message length from the header of the first gRPC-Web frame.
+// In the grpc-web-text format, the frames are base64-encoded.
+func grpcWebMessageLength(body []byte, text bool) (int64, bool) {
+	if text {
+		// 8 base64 characters decode into 6 bytes, which contain the whole frame header
+		if len(body) < 8 {
+			return 0, false
+		}
+		decoded := make([]byte, 6)
+		if _, err := base64.StdEncoding.Decode(decoded, body[:8]); err != nil {
+			return 0, false
+		}
+		body = decoded
+	}
+	// the most significant bit of the flags marks trailer frames, which aren't sent by clients
+	if len(body) < grpcWebFrameHeaderLen || body[0]&0x80 != 0 {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint32(body[1:grpcWebFrameHeaderLen])), true
+}
+
+// grpcStatusFromHTTP maps the HTTP status of a gRPC-Web response to a gRPC status code, according to
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+// Since the gRPC status is sent in the response trailers, which aren't captured, successful HTTP
+// responses are reported as OK.
+func grpcStatusFromHTTP(status int) int {
+	switch status {
+	case http.StatusOK:
+		return grpcStatusOK
+	case http.StatusBadRequest:
+		return grpcStatusInternal
+	case http.StatusUnauthorized:
+		return grpcStatusUnauthenticated
+	case http.StatusForbidden:
+		return grpcStatusPermissionDenied
+	case http.StatusNotFound:
+		return grpcStatusUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcStatusUnavailable
+	}
+	return grpcStatusUnknown
+}
@@ -0,0 +1,94 @@
+package ebpfcommon
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseGRPCWebRequest(t *testing.T) {
+	// message of 7 bytes
+	frame := []byte{0, 0, 0, 0, 7, 0x0a, 0x05, 'h', 'e', 'l', 'l', 'o'}
+	for _, tc := range []struct {
+		name          string
+		reqType       request.EventType
+		buf           []byte
+		status        int
+		expectType    request.EventType
+		expectStatus  int
+		expectContent int64
+	}{
+		{
+			name:    "binary server request",
+			reqType: request.EventTypeHTTP,
+			buf: append([]byte("POST /helloworld.Greeter/SayHello HTTP/1.1\r\nHost: api\r\n"+
+				"Content-Type: application/grpc-web+proto\r\nX-Grpc-Web: 1\r\n\r\n"), frame...),
+			status:        200,
+			expectType:    request.EventTypeGRPC,
+			expectStatus:  0,
+			expectContent: 7,
+		},
+		{
+			name:    "text client request",
+			reqType: request.EventTypeHTTPClient,
+			buf: []byte("POST /helloworld.Greeter/SayHello HTTP/1.1\r\n" +
+				"content-type: application/grpc-web-text\r\n\r\n" + base64.StdEncoding.EncodeToString(frame)),
+			status:        503,
+			expectType:    request.EventTypeGRPCClient,
+			expectStatus:  14,
+			expectContent: 7,
+		},
+		{
+			name:          "body not captured",
+			reqType:       request.EventTypeHTTP,
+			buf:           []byte("POST /helloworld.Greeter/SayHello HTTP/1.1\r\nContent-Type: application/grpc-web\r\nUser-Agent: grpc-web-javascript/0.1"),
+			status:        404,
+			expectType:    request.EventTypeGRPC,
+			expectStatus:  12,
+			expectContent: 123,
+		},
+		{
+			name:    "not gRPC-Web",
+			reqType: request.EventTypeHTTP,
+			buf: []byte("POST /helloworld.Greeter/SayHello HTTP/1.1\r\n" +
+				"Content-Type: application/json\r\n\r\n{}"),
+			status:        200,
+			expectType:    request.EventTypeHTTP,
+			expectStatus:  200,
+			expectContent: 123,
+		},
+		{
+			name:    "not a gRPC method",
+			reqType: request.EventTypeHTTP,
+			buf: []byte("POST /api/v1/users/1 HTTP/1.1\r\n" +
+				"Content-Type: application/grpc-web\r\n\r\n"),
+			status:        200,
+			expectType:    request.EventTypeHTTP,
+			expectStatus:  200,
+			expectContent: 123,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := makeBPFInfoWithBuf(tc.buf)
+			event.Type = uint8(tc.reqType)
+			event.Status = uint16(tc.status)
+			event.Len = 123
+			span, ignore, err := testParser().HTTPInfoEventToSpan(event)
+			assert.NoError(t, err)
+			assert.False(t, ignore)
+			assert.Equal(t, tc.expectType, span.Type)
+			assert.Equal(t, tc.expectStatus, span.Status)
+			assert.Equal(t, tc.expectContent, span.ContentLength)
+		})
+	}
+}
+
+func TestGRPCWebSpanName(t *testing.T) {
+	span := request.Span{Type: request.EventTypeHTTP, Method: "POST", Path: "/helloworld.Greeter/SayHello"}
+	parseGRPCWebRequest(&span, []byte("POST /helloworld.Greeter/SayHello HTTP/1.1\r\n"+
+		"Content-Type: application/grpc-web\r\n\r\n"))
+	assert.Equal(t, "/helloworld.Greeter/SayHello", span.TraceName())
+}
@@ -0,0 +1,111 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// healthCheckCounter is safe for concurrent access, as the paths can't change after its creation.
+// The requests to each path are counted by process, and the processes are resolved to their
+// services when the counts are reported.
+type healthCheckCounter struct {
+	paths map[string]*healthCheckPath
+}
+
+type healthCheckPath struct {
+	mt       sync.Mutex
+	requests map[request.PidInfo]int
+}
+
+// healthCheckKey identifies the health-check requests of a service instance to a path
+type healthCheckKey struct {
+	uid  svc.UID
+	path string
+}
+
+func newHealthCheckCounter(paths []string) *healthCheckCounter {
+	hc := &healthCheckCounter{paths: make(map[string]*healthCheckPath, len(paths))}
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path != "" {
+			hc.paths[path] = &healthCheckPath{requests: map[request.PidInfo]int{}}
+		}
+	}
+	return hc
+}
+
+// count returns whether the path of a server request of the given type is a health check, and
+// counts it for the process in that case. The path is compared without converting it to a string,
+// so the requests that are not health checks don't allocate memory.
+func (hc *healthCheckCounter) count(eventType uint8, pid request.PidInfo, path []byte) bool {
+	if len(hc.paths) == 0 || eventType != uint8(request.EventTypeHTTP) {
+		return false
+	}
+	if query := bytes.IndexByte(path, '?'); query >= 0 {
+		path = path[:query]
+	}
+	hp, ok := hc.paths[string(path)]
+	if !ok {
+		return false
+	}
+	hp.mt.Lock()
+	hp.requests[pid]++
+	hp.mt.Unlock()
+	return true
+}
+
+// report submits the health-check requests by service and path. The services of the processes are
+// resolved through the same filter that decorates the spans, which also discards the requests of
+// the processes that are not instrumented anymore.
+func (hc *healthCheckCounter) report(metrics imetrics.Reporter, filter ServiceFilter) {
+	counts := map[healthCheckKey]int{}
+	services := map[svc.UID]*svc.ID{}
+	for path, hp := range hc.paths {
+		hp.mt.Lock()
+		requests := hp.requests
+		if len(requests) > 0 {
+			hp.requests = map[request.PidInfo]int{}
+		}
+		hp.mt.Unlock()
+		for pid, n := range requests {
+			spans := filter.Filter([]request.Span{{Pid: pid}})
+			if len(spans) == 0 {
+				continue
+			}
+			service := spans[0].ServiceID
+			counts[healthCheckKey{uid: service.UID, path: path}] += n
+			services[service.UID] = &service
+		}
+	}
+	for key, n := range counts {
+		metrics.HealthCheckRequests(services[key.uid], key.path, n)
+	}
+}
+
+// requestPath returns the URL in the request line of the captured buffer of an HTTP request
+func requestPath(buf []byte) []byte {
+	if end := bytes.IndexByte(buf, 0); end >= 0 {
+		buf = buf[:end]
+	}
+	space := bytes.IndexByte(buf, ' ')
+	if space < 0 {
+		return nil
+	}
+	buf = buf[space+1:]
+	if end := bytes.IndexAny(buf, " \r\n"); end >= 0 {
+		buf = buf[:end]
+	}
+	return buf
+}
+
+// cBytes returns the bytes of a zero-terminated C string
+func cBytes(chars []uint8) []byte {
+	if end := bytes.IndexByte(chars, 0); end >= 0 {
+		return chars[:end]
+	}
+	return chars
+}
@@ -0,0 +1,97 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/config"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+type healthCheckReporter struct {
+	imetrics.NoopReporter
+	requests map[string]int
+}
+
+func (r *healthCheckReporter) HealthCheckRequests(service *svc.ID, path string, count int) {
+	r.requests[service.Name+" "+path] += count
+}
+
+func TestHealthChecks(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{HealthCheckPaths: []string{"/healthz", " /ready "}}, "", "")
+	fltr := TestPidsFilter{services: map[uint32]svc.ID{
+		10: {UID: "pod-1.users", Name: "users"},
+		11: {UID: "pod-1.users", Name: "users"},
+		20: {UID: "pod-2.orders", Name: "orders"},
+	}}
+
+	readKernel := func(pid uint32, eventType uint8, req string) bool {
+		var event BPFHTTPInfo
+		event.Pid.HostPid = pid
+		event.Type = eventType
+		copy(event.Buf[:], req)
+		buf := bytes.Buffer{}
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, &event))
+		_, ignore, err := parser.ReadHTTPInfoIntoSpan(&ringbuf.Record{RawSample: buf.Bytes()}, &fltr)
+		require.NoError(t, err)
+		return ignore
+	}
+	readGo := func(pid uint32, eventType uint8, path string) bool {
+		var event HTTPRequestTrace
+		event.Pid.HostPid = pid
+		event.Type = eventType
+		copy(event.Path[:], path)
+		buf := bytes.Buffer{}
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, &event))
+		_, ignore, err := parser.ReadBPFTraceAsSpan(&ringbuf.Record{RawSample: buf.Bytes()}, &fltr)
+		require.NoError(t, err)
+		return ignore
+	}
+
+	assert.True(t, readKernel(10, uint8(request.EventTypeHTTP), "GET /healthz HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	assert.True(t, readKernel(20, uint8(request.EventTypeHTTP), "GET /ready?probe=liveness HTTP/1.1\r\n"))
+	assert.False(t, readKernel(10, uint8(request.EventTypeHTTP), "GET /healthz/details HTTP/1.1\r\n"))
+	// the client requests to the health checks of other services are traced
+	assert.False(t, readKernel(10, uint8(request.EventTypeHTTPClient), "GET /healthz HTTP/1.1\r\n"))
+
+	// the processes of the same service are accounted together
+	assert.True(t, readGo(11, uint8(request.EventTypeHTTP), "/healthz"))
+	assert.True(t, readGo(20, uint8(request.EventTypeHTTP), "/healthz"))
+	assert.False(t, readGo(10, uint8(request.EventTypeHTTP), "/users"))
+	assert.False(t, readGo(10, uint8(request.EventTypeHTTPClient), "/ready"))
+
+	reporter := &healthCheckReporter{requests: map[string]int{}}
+	parser.healthChecks.report(reporter, &fltr)
+	assert.Equal(t, map[string]int{
+		"users /healthz":  2,
+		"orders /healthz": 1,
+		"orders /ready":   1,
+	}, reporter.requests)
+
+	// the counters are reset after each report
+	reporter.requests = map[string]int{}
+	parser.healthChecks.report(reporter, &fltr)
+	assert.Empty(t, reporter.requests)
+}
+
+func TestHealthChecks_NoAllocations(t *testing.T) {
+	hc := newHealthCheckCounter([]string{"/healthz"})
+	path := []byte("/users/1234?page=2")
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		hc.count(uint8(request.EventTypeHTTP), request.PidInfo{HostPID: 10}, path)
+	}))
+}
+
+func TestRequestPath(t *testing.T) {
+	assert.Equal(t, "/healthz", string(requestPath([]byte("GET /healthz HTTP/1.1\r\n"))))
+	assert.Equal(t, "/healthz", string(requestPath([]byte("GET /healthz\x00 HTTP/1.1"))))
+	assert.Equal(t, "/health", string(requestPath([]byte("GET /health"))))
+	assert.Empty(t, requestPath([]byte("GET")))
+}
@@ -0,0 +1,40 @@
+package ebpfcommon
+
+import (
+	"sync"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// streamResets accumulates the HTTP/2 streams that were reset with an error, by error code and
+// sender, until they are reported to the internal metrics. The number of error codes is bounded
+// by the HTTP/2 specification, so the cardinality of the counts is bounded too.
+var streamResets = streamResetCounter{resets: map[request.HTTP2StreamReset]int{}}
+
+type streamResetCounter struct {
+	mt     sync.Mutex
+	resets map[request.HTTP2StreamReset]int
+}
+
+func (s *streamResetCounter) add(reset *request.HTTP2StreamReset) {
+	s.mt.Lock()
+	defer s.mt.Unlock()
+	s.resets[*reset]++
+}
+
+func (s *streamResetCounter) report(metrics imetrics.Reporter) {
+	s.mt.Lock()
+	resets := s.resets
+	s.resets = map[request.HTTP2StreamReset]int{}
+	s.mt.Unlock()
+	for reset, count := range resets {
+		metrics.HTTP2StreamResets(reset.ErrorCode, reset.Sender, count)
+	}
+}
+
+// ReportHTTP2StreamResets submits to the internal metrics the HTTP/2 streams that were reset with
+// an error since the last invocation
+func ReportHTTP2StreamResets(metrics imetrics.Reporter) {
+	streamResets.report(metrics)
+}
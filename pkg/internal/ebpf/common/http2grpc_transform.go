@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"strconv"
 	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/cilium/ebpf/ringbuf"
@@ -28,15 +29,18 @@ const (
 )
 
 type h2Connection struct {
-	hdec     *bhpack.Decoder
-	hdecRet  *bhpack.Decoder
+	hdec    *bhpack.Decoder
+	hdecRet *bhpack.Decoder
+	// protocol of the connection, or zero if it is still unknown
 	protocol Protocol
 }
 
-// not all requests for a given stream specify the protocol, but one must
-// we remember if we see grpc mentioned and tag the rest of the streams for
-// a given connection as grpc. default assumes plain HTTP2
-var activeGRPCConnections, _ = lru.New[BPFConnInfo, h2Connection](1024 * 10)
+// not all requests for a given stream specify the protocol, so we remember the
+// protocol of each connection from the streams that specify it, and tag with it
+// the rest of the streams of the same connection. Servers multiplexing HTTP and gRPC
+// on the same listener (e.g. cmux) route each connection to a single protocol handler,
+// so the protocol is remembered per connection, and never per port.
+var activeGRPCConnections, _ = lru.New[BPFConnInfo, *h2Connection](1024 * 10)
 
 func byteFramer(data []uint8) *http2.Framer {
 	buf := bytes.NewBuffer(data)
@@ -49,26 +53,43 @@ func getOrInitH2Conn(conn *BPFConnInfo) *h2Connection {
 	v, ok := activeGRPCConnections.Get(*conn)
 
 	if !ok {
-		h := h2Connection{
-			hdec:     bhpack.NewDecoder(0, nil),
-			hdecRet:  bhpack.NewDecoder(0, nil),
-			protocol: HTTP2,
-		}
-		activeGRPCConnections.Add(*conn, h)
-		v, ok = activeGRPCConnections.Get(*conn)
-		if !ok {
-			return nil
+		v = &h2Connection{
+			hdec:    bhpack.NewDecoder(0, nil),
+			hdecRet: bhpack.NewDecoder(0, nil),
 		}
+		activeGRPCConnections.Add(*conn, v)
 	}
 
-	return &v
+	return v
+}
+
+func isGRPCContentType(contentType string) bool {
+	return contentType == "application/grpc" ||
+		strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc;")
 }
 
-func protocolIsGRPC(conn *BPFConnInfo) {
+// streamProtocol classifies a stream as gRPC or plain HTTP2. The request and response content types,
+// and the grpc-status response header take precedence, and they are remembered for the rest of the
+// streams of the connection that do not specify them.
+func streamProtocol(conn *BPFConnInfo, contentType, retContentType string, grpcInStatus bool, ssl uint8) Protocol {
 	h2c := getOrInitH2Conn(conn)
-	if h2c != nil {
+	switch {
+	case grpcInStatus || isGRPCContentType(contentType) || isGRPCContentType(retContentType):
 		h2c.protocol = GRPC
+		return GRPC
+	case contentType != "" || retContentType != "":
+		if h2c.protocol == 0 {
+			h2c.protocol = HTTP2
+		}
+		return HTTP2
+	case h2c.protocol != 0:
+		return h2c.protocol
+	case ssl == 0:
+		// if we don't have protocol, assume gRPC if it's not ssl. HTTP2 is almost always SSL.
+		return GRPC
 	}
+	return HTTP2
 }
 
 var commonHDec = bhpack.NewDecoder(0, nil)
@@ -85,70 +106,113 @@ func knownFrameKeys(fr *http2.Framer, hf *http2.HeadersFrame) bool {
 	// Lose reference to MetaHeadersFrame:
 	defer commonHDec.SetEmitFunc(func(_ bhpack.HeaderField) {})
 
+	decodeHeaderBlock(commonHDec, fr, hf)
+
+	return known
+}
+
+// decodeHeaderBlock decodes the header block fragments of the HEADERS frame and the CONTINUATION frames
+// that follow it. It returns false if the header block can't be fully decoded, e.g. because it is
+// truncated in the captured buffer.
+func decodeHeaderBlock(dec *bhpack.Decoder, fr *http2.Framer, hf *http2.HeadersFrame) bool {
+	frag, ended := hf.HeaderBlockFragment(), hf.HeadersEnded()
 	for {
-		frag := hf.HeaderBlockFragment()
-		if _, err := commonHDec.Write(frag); err != nil {
-			break
+		if _, err := dec.Write(frag); err != nil {
+			return false
 		}
-
-		if hf.HeadersEnded() {
-			break
+		if ended {
+			return true
 		}
-		if _, err := fr.ReadFrame(); err != nil {
-			break
+		// the CONTINUATION frames of a header block can't be interleaved with frames of other streams
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return false
 		}
+		cf, ok := f.(*http2.ContinuationFrame)
+		if !ok || cf.StreamID != hf.StreamID {
+			return false
+		}
+		frag, ended = cf.HeaderBlockFragment(), cf.HeadersEnded()
 	}
+}
 
-	return known
+// h2RequestMeta contains the headers of an HTTP/2 request that are reported in its span
+type h2RequestMeta struct {
+	method      string
+	path        string
+	contentType string
+	authority   string
+	// grpcTimeout is the deadline of the gRPC call, from the grpc-timeout header
+	grpcTimeout time.Duration
+	// grpcPreviousAttempts is the number of previous attempts of a retried gRPC call,
+	// from the grpc-previous-rpc-attempts header
+	grpcPreviousAttempts int
 }
 
-func readMetaFrame(conn *BPFConnInfo, fr *http2.Framer, hf *http2.HeadersFrame) (string, string, string, bool) {
+func readMetaFrame(conn *BPFConnInfo, fr *http2.Framer, hf *http2.HeadersFrame) (h2RequestMeta, bool) {
 	h2c := getOrInitH2Conn(conn)
 
 	ok := false
-	method := ""
-	path := ""
-	contentType := ""
-
-	if h2c == nil {
-		return method, path, contentType, ok
-	}
+	meta := h2RequestMeta{}
 
 	h2c.hdec.SetEmitFunc(func(hf bhpack.HeaderField) {
 		hfKey := strings.ToLower(hf.Name)
 		switch hfKey {
 		case ":method":
-			method = hf.Value
+			meta.method = hf.Value
 			ok = true
 		case ":path":
-			path = hf.Value
+			meta.path = hf.Value
 			ok = true
 		case "content-type":
-			contentType = strings.ToLower(hf.Value)
-			if contentType == "application/grpc" {
-				protocolIsGRPC(conn)
-			}
+			meta.contentType = strings.ToLower(hf.Value)
 			ok = true
+		case ":authority":
+			meta.authority = hf.Value
+		case "grpc-timeout":
+			meta.grpcTimeout, _ = parseGRPCTimeout(hf.Value)
+		case "grpc-previous-rpc-attempts":
+			if attempts, err := strconv.Atoi(hf.Value); err == nil && attempts > 0 {
+				meta.grpcPreviousAttempts = attempts
+			}
 		}
 	})
 	// Lose reference to MetaHeadersFrame:
 	defer h2c.hdec.SetEmitFunc(func(_ bhpack.HeaderField) {})
 
-	for {
-		frag := hf.HeaderBlockFragment()
-		if _, err := h2c.hdec.Write(frag); err != nil {
-			return method, path, contentType, ok
-		}
+	decodeHeaderBlock(h2c.hdec, fr, hf)
 
-		if hf.HeadersEnded() {
-			break
-		}
-		if _, err := fr.ReadFrame(); err != nil {
-			return method, path, contentType, ok
-		}
-	}
+	return meta, ok
+}
 
-	return method, path, contentType, ok
+// parseGRPCTimeout parses the value of the grpc-timeout header: an integer of at most 8 digits
+// followed by its unit (H, M, S, m, u or n)
+func parseGRPCTimeout(val string) (time.Duration, bool) {
+	if len(val) < 2 || len(val) > 9 {
+		return 0, false
+	}
+	amount, err := strconv.ParseInt(val[:len(val)-1], 10, 64)
+	if err != nil || amount < 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch val[len(val)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	return time.Duration(amount) * unit, true
 }
 
 func http2grpcStatus(status int) int {
@@ -162,17 +226,14 @@ func http2grpcStatus(status int) int {
 	return 2 // Unknown
 }
 
-func readRetMetaFrame(conn *BPFConnInfo, fr *http2.Framer, hf *http2.HeadersFrame) (int, bool, bool) {
+func readRetMetaFrame(conn *BPFConnInfo, fr *http2.Framer, hf *http2.HeadersFrame) (int, string, bool, bool) {
 	h2c := getOrInitH2Conn(conn)
 
 	ok := false
 	status := 0
+	contentType := ""
 	grpc := false
 
-	if h2c == nil {
-		return status, grpc, ok
-	}
-
 	h2c.hdecRet.SetEmitFunc(func(hf bhpack.HeaderField) {
 		hfKey := strings.ToLower(hf.Name)
 		// grpc requests may have :status and grpc-status. :status will be HTTP code.
@@ -184,29 +245,18 @@ func readRetMetaFrame(conn *BPFConnInfo, fr *http2.Framer, hf *http2.HeadersFram
 			ok = true
 		case "grpc-status":
 			status, _ = strconv.Atoi(hf.Value)
-			protocolIsGRPC(conn)
 			grpc = true
 			ok = true
+		case "content-type":
+			contentType = strings.ToLower(hf.Value)
 		}
 	})
 	// Lose reference to MetaHeadersFrame:
 	defer h2c.hdecRet.SetEmitFunc(func(_ bhpack.HeaderField) {})
 
-	for {
-		frag := hf.HeaderBlockFragment()
-		if _, err := h2c.hdecRet.Write(frag); err != nil {
-			return status, grpc, ok
-		}
-
-		if hf.HeadersEnded() {
-			break
-		}
-		if _, err := fr.ReadFrame(); err != nil {
-			return status, grpc, ok
-		}
-	}
+	decodeHeaderBlock(h2c.hdecRet, fr, hf)
 
-	return status, grpc, ok
+	return status, contentType, grpc, ok
 }
 
 func http2InfoToSpan(info *BPFHTTP2Info, method, path, peer, host string, status int, protocol Protocol) request.Span {
@@ -274,7 +324,6 @@ func http2FromBuffers(event *BPFHTTP2Info) (request.Span, bool, error) {
 	// partial buffers.
 
 	status := 0
-	eventType := HTTP2
 
 	for {
 		f, err := framer.ReadFrame()
@@ -285,13 +334,16 @@ func http2FromBuffers(event *BPFHTTP2Info) (request.Span, bool, error) {
 
 		if ff, ok := f.(*http2.HeadersFrame); ok {
 			rok := false
-			method, path, contentType, ok := readMetaFrame((*BPFConnInfo)(&event.ConnInfo), framer, ff)
+			meta, ok := readMetaFrame((*BPFConnInfo)(&event.ConnInfo), framer, ff)
 
+			path := meta.path
 			if path == "" {
 				path = "*"
 			}
 
 			grpcInStatus := false
+			retContentType := ""
+			reset := streamReset(framer, ff.StreamID, resetByClient)
 
 			for {
 				retF, err := retFramer.ReadFrame()
@@ -300,9 +352,16 @@ func http2FromBuffers(event *BPFHTTP2Info) (request.Span, bool, error) {
 					break
 				}
 
-				if ff, ok := retF.(*http2.HeadersFrame); ok {
-					status, grpcInStatus, rok = readRetMetaFrame((*BPFConnInfo)(&event.ConnInfo), retFramer, ff)
-					break
+				// with concurrent streams, the response buffer might contain frames of other streams
+				switch rf := retF.(type) {
+				case *http2.HeadersFrame:
+					if rf.StreamID == ff.StreamID && !rok {
+						status, retContentType, grpcInStatus, rok = readRetMetaFrame((*BPFConnInfo)(&event.ConnInfo), retFramer, rf)
+					}
+				case *http2.RSTStreamFrame:
+					if rf.StreamID == ff.StreamID && reset == nil && rf.ErrCode != http2.ErrCodeNo {
+						reset = &request.HTTP2StreamReset{ErrorCode: rf.ErrCode.String(), Sender: resetByServer}
+					}
 				}
 			}
 
@@ -311,9 +370,8 @@ func http2FromBuffers(event *BPFHTTP2Info) (request.Span, bool, error) {
 				return request.Span{}, true, nil
 			}
 
-			// if we don't have protocol, assume gRPC if it's not ssl. HTTP2 is almost always SSL.
-			if eventType != GRPC && (grpcInStatus || contentType == "application/grpc" || (contentType == "" && event.Ssl == 0)) {
-				eventType = GRPC
+			eventType := streamProtocol((*BPFConnInfo)(&event.ConnInfo), meta.contentType, retContentType, grpcInStatus, event.Ssl)
+			if eventType == GRPC {
 				status = http2grpcStatus(status)
 			}
 
@@ -325,13 +383,43 @@ func http2FromBuffers(event *BPFHTTP2Info) (request.Span, bool, error) {
 				peer = source
 			}
 
-			return http2InfoToSpan(event, method, path, peer, host, status, eventType), false, nil
+			span := http2InfoToSpan(event, meta.method, path, peer, host, status, eventType)
+			span.HTTP2Reset = reset
+			if reset != nil {
+				streamResets.add(reset)
+			}
+			if span.Type == request.EventTypeGRPCClient {
+				span.Authority = meta.authority
+				span.GRPCTimeout = meta.grpcTimeout
+				span.GRPCPreviousAttempts = meta.grpcPreviousAttempts
+			}
+			return span, false, nil
 		}
 	}
 
 	return request.Span{}, true, nil // ignore if we couldn't parse it
 }
 
+const (
+	resetByClient = "client"
+	resetByServer = "server"
+)
+
+// streamReset looks, in the rest of the frames of a buffer, for a RST_STREAM frame that abruptly
+// terminates the stream. The resets with the NO_ERROR code are ignored, since the servers send them
+// to gracefully close the streams whose response was fully sent before the client finished sending.
+func streamReset(fr *http2.Framer, streamID uint32, sender string) *request.HTTP2StreamReset {
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return nil
+		}
+		if rf, ok := f.(*http2.RSTStreamFrame); ok && rf.StreamID == streamID && rf.ErrCode != http2.ErrCodeNo {
+			return &request.HTTP2StreamReset{ErrorCode: rf.ErrCode.String(), Sender: sender}
+		}
+	}
+}
+
 func ReadHTTP2InfoIntoSpan(record *ringbuf.Record, filter ServiceFilter) (request.Span, bool, error) {
 	var event BPFHTTP2Info
 
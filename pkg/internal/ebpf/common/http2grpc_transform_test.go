@@ -1,10 +1,17 @@
 package ebpfcommon
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
 )
 
 func TestHTTP2QuickDetection(t *testing.T) {
@@ -119,10 +126,10 @@ func TestHTTP2Parsing(t *testing.T) {
 
 				if ff, ok := f.(*http2.HeadersFrame); ok {
 					connInfo := BPFConnInfo{}
-					method, path, contentType, _ := readMetaFrame(&connInfo, framer, ff)
-					assert.Equal(t, method, tt.method)
-					assert.Equal(t, path, tt.path)
-					assert.Equal(t, contentType, tt.contentType)
+					meta, _ := readMetaFrame(&connInfo, framer, ff)
+					assert.Equal(t, meta.method, tt.method)
+					assert.Equal(t, meta.path, tt.path)
+					assert.Equal(t, meta.contentType, tt.contentType)
 				}
 			}
 		})
@@ -177,3 +184,259 @@ func makeBPFHTTP2Info(buf, rbuf []byte, len int) BPFHTTP2Info {
 
 	return info
 }
+
+// h2cConn encodes the headers frames of a cleartext HTTP2 connection, keeping the
+// HPACK dynamic tables of each direction, as a real client and server would do
+type h2cConn struct {
+	info   BPFHTTP2Info
+	reqEnc *hpack.Encoder
+	reqBuf bytes.Buffer
+	retEnc *hpack.Encoder
+	retBuf bytes.Buffer
+	stream uint32
+}
+
+func newH2CConn(clientPort uint16) *h2cConn {
+	c := &h2cConn{stream: 1}
+	c.info.Type = uint8(request.EventTypeHTTP)
+	c.info.ConnInfo.S_addr = [16]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 255, 255, 10, 0, 0, 1}
+	c.info.ConnInfo.D_addr = [16]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 255, 255, 10, 0, 0, 2}
+	c.info.ConnInfo.S_port = clientPort
+	c.info.ConnInfo.D_port = 8080
+	c.reqEnc = hpack.NewEncoder(&c.reqBuf)
+	c.retEnc = hpack.NewEncoder(&c.retBuf)
+	return c
+}
+
+func headerBlock(enc *hpack.Encoder, buf *bytes.Buffer, fields []string) []byte {
+	buf.Reset()
+	for i := 0; i < len(fields); i += 2 {
+		_ = enc.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+	}
+	return bytes.Clone(buf.Bytes())
+}
+
+func (c *h2cConn) headersFrame(enc *hpack.Encoder, buf *bytes.Buffer, fields []string) []byte {
+	frame := bytes.Buffer{}
+	_ = http2.NewFramer(&frame, nil).WriteHeaders(http2.HeadersFrameParam{
+		StreamID: c.stream, BlockFragment: headerBlock(enc, buf, fields), EndHeaders: true,
+	})
+	return frame.Bytes()
+}
+
+// roundTrip returns the span of a stream with the passed request and response headers
+func (c *h2cConn) roundTrip(t *testing.T, reqFields, retFields []string) request.Span {
+	req := c.headersFrame(c.reqEnc, &c.reqBuf, reqFields)
+	ret := c.headersFrame(c.retEnc, &c.retBuf, retFields)
+	c.stream += 2
+	info := c.info
+	copy(info.Data[:], req)
+	copy(info.RetData[:], ret)
+	info.Len = int32(len(req))
+	span, ignore, err := http2FromBuffers(&info)
+	require.NoError(t, err)
+	require.False(t, ignore)
+	return span
+}
+
+func TestHTTP2MultiplexedListener(t *testing.T) {
+	// HTTP and gRPC connections to the same server port, as cmux does
+	grpcConn := newH2CConn(40001)
+	httpConn := newH2CConn(40002)
+
+	span := grpcConn.roundTrip(t,
+		[]string{":method", "POST", ":path", "/routeguide.RouteGuide/GetFeature", "content-type", "application/grpc+proto"},
+		[]string{":status", "200", "content-type", "application/grpc"})
+	assert.Equal(t, request.EventTypeGRPC, span.Type)
+	assert.Equal(t, "/routeguide.RouteGuide/GetFeature", span.Path)
+	assert.Equal(t, 0, span.Status)
+
+	span = httpConn.roundTrip(t,
+		[]string{":method", "GET", ":path", "/index.html"},
+		[]string{":status", "200", "content-type", "text/html; charset=utf-8"})
+	assert.Equal(t, request.EventTypeHTTP, span.Type)
+	assert.Equal(t, "/index.html", span.Path)
+	assert.Equal(t, 200, span.Status)
+
+	// streams without content type are classified according to their connection
+	span = httpConn.roundTrip(t,
+		[]string{":method", "GET", ":path", "/favicon.ico"},
+		[]string{":status", "404"})
+	assert.Equal(t, request.EventTypeHTTP, span.Type)
+	assert.Equal(t, 404, span.Status)
+
+	span = grpcConn.roundTrip(t,
+		[]string{":method", "POST", ":path", "/routeguide.RouteGuide/ListFeatures"},
+		[]string{":status", "200"})
+	assert.Equal(t, request.EventTypeGRPC, span.Type)
+	assert.Equal(t, "/routeguide.RouteGuide/ListFeatures", span.Path)
+}
+
+func TestHTTP2StreamProtocol(t *testing.T) {
+	conn := BPFConnInfo{S_port: 40003, D_port: 9090}
+	// unknown cleartext connection: assume gRPC
+	assert.Equal(t, GRPC, streamProtocol(&conn, "", "", false, 0))
+	// unknown TLS connection: assume HTTP2
+	assert.Equal(t, HTTP2, streamProtocol(&conn, "", "", false, 1))
+	assert.Equal(t, HTTP2, streamProtocol(&conn, "application/json", "", false, 0))
+	assert.Equal(t, HTTP2, streamProtocol(&conn, "", "", false, 0))
+	// gRPC evidence overrides a previous HTTP2 classification of the connection
+	assert.Equal(t, GRPC, streamProtocol(&conn, "", "", true, 0))
+	assert.Equal(t, GRPC, streamProtocol(&conn, "", "", false, 1))
+	// explicit content types are still classified per stream
+	assert.Equal(t, HTTP2, streamProtocol(&conn, "application/grpc-web", "", false, 0))
+	assert.Equal(t, GRPC, streamProtocol(&conn, "", "", false, 0))
+}
+
+func TestHTTP2InterleavedStreams(t *testing.T) {
+	c := newH2CConn(40004)
+	info := c.info
+
+	// the request header block is split across a HEADERS and a CONTINUATION frame
+	block := headerBlock(c.reqEnc, &c.reqBuf, []string{
+		":method", "POST", ":scheme", "http", ":path", "/helloworld.Greeter/SayHello",
+		"content-type", "application/grpc", "te", "trailers",
+	})
+	req := bytes.Buffer{}
+	reqFramer := http2.NewFramer(&req, nil)
+	require.NoError(t, reqFramer.WriteHeaders(http2.HeadersFrameParam{StreamID: 5, BlockFragment: block[:10]}))
+	require.NoError(t, reqFramer.WriteContinuation(5, true, block[10:]))
+	require.NoError(t, reqFramer.WriteData(5, true, []byte{0, 0, 0, 0, 2, 10, 0}))
+
+	// the response of a concurrent stream precedes the response of the request
+	ret := bytes.Buffer{}
+	retFramer := http2.NewFramer(&ret, nil)
+	otherBuf := bytes.Buffer{}
+	require.NoError(t, retFramer.WriteHeaders(http2.HeadersFrameParam{StreamID: 3, EndHeaders: true,
+		BlockFragment: headerBlock(hpack.NewEncoder(&otherBuf), &otherBuf, []string{":status", "503"})}))
+	require.NoError(t, retFramer.WriteHeaders(http2.HeadersFrameParam{StreamID: 5, EndHeaders: true,
+		BlockFragment: headerBlock(c.retEnc, &c.retBuf, []string{":status", "200"})}))
+
+	copy(info.Data[:], req.Bytes())
+	copy(info.RetData[:], ret.Bytes())
+	info.Len = int32(req.Len())
+	span, ignore, err := http2FromBuffers(&info)
+	require.NoError(t, err)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeGRPC, span.Type)
+	assert.Equal(t, "/helloworld.Greeter/SayHello", span.Path)
+	assert.Equal(t, 0, span.Status)
+}
+
+type streamResetsReporter struct {
+	imetrics.NoopReporter
+	resets map[string]int
+}
+
+func (r *streamResetsReporter) HTTP2StreamResets(errorCode, sender string, count int) {
+	r.resets[errorCode+" "+sender] += count
+}
+
+func TestHTTP2StreamReset(t *testing.T) {
+	reqFields := []string{":method", "POST", ":scheme", "http", ":path", "/helloworld.Greeter/SayHello",
+		"content-type", "application/grpc"}
+	// discard the resets accounted by other tests
+	ReportHTTP2StreamResets(imetrics.NoopReporter{})
+
+	t.Run("reset by the client", func(t *testing.T) {
+		c := newH2CConn(40005)
+		info := c.info
+		req := bytes.NewBuffer(c.headersFrame(c.reqEnc, &c.reqBuf, reqFields))
+		fr := http2.NewFramer(req, nil)
+		// a reset of a concurrent stream is ignored
+		require.NoError(t, fr.WriteRSTStream(c.stream+2, http2.ErrCodeInternal))
+		require.NoError(t, fr.WriteRSTStream(c.stream, http2.ErrCodeCancel))
+
+		copy(info.Data[:], req.Bytes())
+		info.Len = int32(req.Len())
+		span, ignore, err := http2FromBuffers(&info)
+		require.NoError(t, err)
+		require.False(t, ignore)
+		assert.Equal(t, &request.HTTP2StreamReset{ErrorCode: "CANCEL", Sender: "client"}, span.HTTP2Reset)
+	})
+
+	t.Run("reset by the server", func(t *testing.T) {
+		c := newH2CConn(40006)
+		info := c.info
+		req := c.headersFrame(c.reqEnc, &c.reqBuf, reqFields)
+		ret := bytes.NewBuffer(c.headersFrame(c.retEnc, &c.retBuf, []string{":status", "200"}))
+		require.NoError(t, http2.NewFramer(ret, nil).WriteRSTStream(c.stream, http2.ErrCodeFlowControl))
+
+		copy(info.Data[:], req)
+		copy(info.RetData[:], ret.Bytes())
+		info.Len = int32(len(req))
+		span, ignore, err := http2FromBuffers(&info)
+		require.NoError(t, err)
+		require.False(t, ignore)
+		assert.Equal(t, 0, span.Status)
+		assert.Equal(t, &request.HTTP2StreamReset{ErrorCode: "FLOW_CONTROL_ERROR", Sender: "server"}, span.HTTP2Reset)
+	})
+
+	t.Run("graceful resets are ignored", func(t *testing.T) {
+		c := newH2CConn(40007)
+		info := c.info
+		req := c.headersFrame(c.reqEnc, &c.reqBuf, reqFields)
+		ret := bytes.NewBuffer(c.headersFrame(c.retEnc, &c.retBuf, []string{":status", "200"}))
+		require.NoError(t, http2.NewFramer(ret, nil).WriteRSTStream(c.stream, http2.ErrCodeNo))
+
+		copy(info.Data[:], req)
+		copy(info.RetData[:], ret.Bytes())
+		info.Len = int32(len(req))
+		span, ignore, err := http2FromBuffers(&info)
+		require.NoError(t, err)
+		require.False(t, ignore)
+		assert.Nil(t, span.HTTP2Reset)
+	})
+
+	reporter := &streamResetsReporter{resets: map[string]int{}}
+	ReportHTTP2StreamResets(reporter)
+	assert.Equal(t, map[string]int{"CANCEL client": 1, "FLOW_CONTROL_ERROR server": 1}, reporter.resets)
+}
+
+func TestHTTP2GRPCClientAuthority(t *testing.T) {
+	c := newH2CConn(40008)
+	c.info.Type = uint8(request.EventTypeHTTPClient)
+	span := c.roundTrip(t, []string{":method", "POST", ":scheme", "http", ":path", "/helloworld.Greeter/SayHello",
+		":authority", "greeter.default.svc.cluster.local:50051", "content-type", "application/grpc"},
+		[]string{":status", "200", "content-type", "application/grpc"})
+	assert.Equal(t, request.EventTypeGRPCClient, span.Type)
+	assert.Equal(t, "greeter.default.svc.cluster.local:50051", span.Authority)
+
+	// the authority is only kept for the clients
+	c = newH2CConn(40009)
+	span = c.roundTrip(t, []string{":method", "POST", ":scheme", "http", ":path", "/helloworld.Greeter/SayHello",
+		":authority", "greeter:50051", "content-type", "application/grpc"},
+		[]string{":status", "200", "content-type", "application/grpc"})
+	assert.Equal(t, request.EventTypeGRPC, span.Type)
+	assert.Empty(t, span.Authority)
+}
+
+func TestHTTP2GRPCClientRetryAndTimeout(t *testing.T) {
+	c := newH2CConn(40010)
+	c.info.Type = uint8(request.EventTypeHTTPClient)
+	span := c.roundTrip(t, []string{":method", "POST", ":scheme", "http", ":path", "/helloworld.Greeter/SayHello",
+		"content-type", "application/grpc", "grpc-timeout", "250m", "grpc-previous-rpc-attempts", "2"},
+		[]string{":status", "200", "content-type", "application/grpc"})
+	assert.Equal(t, request.EventTypeGRPCClient, span.Type)
+	assert.Equal(t, 250*time.Millisecond, span.GRPCTimeout)
+	assert.Equal(t, 2, span.GRPCPreviousAttempts)
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	for val, expected := range map[string]time.Duration{
+		"1H":        time.Hour,
+		"3M":        3 * time.Minute,
+		"10S":       10 * time.Second,
+		"99999999m": 99999999 * time.Millisecond,
+		"250u":      250 * time.Microsecond,
+		"7n":        7,
+	} {
+		timeout, ok := parseGRPCTimeout(val)
+		assert.True(t, ok, val)
+		assert.Equal(t, expected, timeout, val)
+	}
+	for _, val := range []string{"", "S", "10", "10s", "-1S", "123456789S"} {
+		_, ok := parseGRPCTimeout(val)
+		assert.False(t, ok, val)
+	}
+}
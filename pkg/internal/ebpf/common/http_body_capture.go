@@ -0,0 +1,55 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// requestBufSize is the size of the request buffer that the kernel probes capture. The request
+// line, the headers and the body that are parsed in user space must fit in it.
+const requestBufSize = len(BPFHTTPInfo{}.Buf)
+
+// parseCapturedBody attaches the first bytes of the request body to a sampled subset of HTTP spans.
+// The body is only available for the part of the request that is captured by the eBPF probes,
+// after the request line and the headers, so it is never longer than requestBufSize. This is a
+// partial implementation: capturing longer bodies, or the response bodies, would require the
+// eBPF probes to send them in separate events.
+func (p *Parser) parseCapturedBody(span *request.Span, buf []byte) {
+	if p.bodyCapture.Size <= 0 {
+		return
+	}
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	if !sampleBody(span, p.bodyCapture.SampleRatio) {
+		return
+	}
+	body := httpRequestBody(buf)
+	if len(body) == 0 {
+		return
+	}
+	if len(body) > p.bodyCapture.Size {
+		body = body[:p.bodyCapture.Size]
+	}
+	// binary bodies, or truncated multi-byte characters, must not break the string attributes
+	span.RequestBody = strings.ToValidUTF8(string(body), "\uFFFD")
+}
+
+// sampleBody decides whether the body of the span is captured. Like the OpenTelemetry ratio-based
+// samplers, the decision is taken from the trace ID, so all the spans of the same trace get the same one.
+func sampleBody(span *request.Span, ratio float64) bool {
+	switch {
+	case ratio >= 1:
+		return true
+	case ratio <= 0:
+		return false
+	}
+	bound := uint64(ratio * (1 << 63))
+	if !span.TraceID.IsValid() {
+		return rand.Uint64()>>1 < bound
+	}
+	return binary.BigEndian.Uint64(span.TraceID[8:16])>>1 < bound
+}
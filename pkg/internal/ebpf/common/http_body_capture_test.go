@@ -0,0 +1,81 @@
+package ebpfcommon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/config"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseCapturedBody(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{CaptureBodies: config.BodyCapture{Size: 8, SampleRatio: 1}}, "", "")
+
+	for _, tc := range []struct {
+		name     string
+		buf      string
+		spanType request.EventType
+		expected string
+	}{{
+		name:     "short body",
+		buf:      "POST /orders HTTP/1.1\r\nHost: example.com\r\n\r\n{\"a\":1}",
+		spanType: request.EventTypeHTTP,
+		expected: `{"a":1}`,
+	}, {
+		name:     "body longer than the captured size",
+		buf:      "POST /orders HTTP/1.1\r\nHost: example.com\r\n\r\n{\"id\":12345}",
+		spanType: request.EventTypeHTTPClient,
+		expected: `{"id":12`,
+	}, {
+		name:     "truncated multi-byte character",
+		buf:      "POST /orders HTTP/1.1\r\n\r\nabcdefgñ",
+		spanType: request.EventTypeHTTP,
+		expected: "abcdefg�",
+	}, {
+		name:     "no body",
+		buf:      "GET /orders HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		spanType: request.EventTypeHTTP,
+	}, {
+		name:     "headers are truncated",
+		buf:      "POST /orders HTTP/1.1\r\nHost: exam",
+		spanType: request.EventTypeHTTP,
+	}, {
+		name:     "not HTTP",
+		buf:      "POST /orders HTTP/1.1\r\n\r\n{\"a\":1}",
+		spanType: request.EventTypeGRPC,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			span := request.Span{Type: tc.spanType}
+			buf := make([]byte, 192)
+			copy(buf, tc.buf)
+			parser.parseCapturedBody(&span, buf)
+			assert.Equal(t, tc.expected, span.RequestBody)
+		})
+	}
+}
+
+func TestParseCapturedBody_Sampling(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{CaptureBodies: config.BodyCapture{Size: 64, SampleRatio: 0.5}}, "", "")
+
+	buf := []byte("POST /orders HTTP/1.1\r\n\r\n{\"a\":1}")
+	// the decision is taken from the lower half of the trace ID
+	sampled := request.Span{Type: request.EventTypeHTTP, TraceID: [16]byte{15: 1, 8: 0x10}}
+	parser.parseCapturedBody(&sampled, buf)
+	assert.Equal(t, `{"a":1}`, sampled.RequestBody)
+
+	notSampled := request.Span{Type: request.EventTypeHTTP, TraceID: [16]byte{15: 1, 8: 0xf0}}
+	parser.parseCapturedBody(&notSampled, buf)
+	assert.Empty(t, notSampled.RequestBody)
+}
+
+func TestParseCapturedBody_Disabled(t *testing.T) {
+	span := request.Span{Type: request.EventTypeHTTP}
+	testParser().parseCapturedBody(&span, []byte("POST / HTTP/1.1\r\n\r\n{\"a\":1}"))
+	assert.Empty(t, span.RequestBody)
+}
+
+func TestParseCapturedBody_SizeBoundedByRequestBuffer(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{CaptureBodies: config.BodyCapture{Size: 4096, SampleRatio: 1}}, "", "")
+	assert.Equal(t, 192, parser.bodyCapture.Size)
+}
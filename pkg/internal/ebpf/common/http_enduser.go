@@ -0,0 +1,37 @@
+package ebpfcommon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// parseEnduser attaches the hash of the subject of the bearer token of the HTTP server spans,
+// if the Authorization header fully fits in the captured part of the request (requestBufSize bytes,
+// which most JWTs exceed)
+func (p *Parser) parseEnduser(span *request.Span, buf []byte) {
+	if !p.jwtSubject.Enable || span.Type != request.EventTypeHTTP {
+		return
+	}
+	token := headerValue(buf, "authorization")
+	if token == "" {
+		return
+	}
+	subject := jwtClaim(token, p.jwtSubject.Claim)
+	if subject == "" {
+		return
+	}
+	span.EnduserHash = hashSubject(subject, p.jwtSubject.HashKey)
+}
+
+func hashSubject(subject, key string) string {
+	if key == "" {
+		sum := sha256.Sum256([]byte(subject))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(subject))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,47 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// parseCapturedHeaders attaches the allowlisted headers that are found in the captured part of the
+// request. It only parses, in user space, the fixed-size request buffer of the eBPF probes
+// (requestBufSize), so the headers that are placed after it are missing, and the last header line is
+// ignored if it was truncated. The response headers are not captured.
+func (p *Parser) parseCapturedHeaders(span *request.Span, buf []byte) {
+	if len(p.capturedHeaders) == 0 {
+		return
+	}
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	if end := bytes.IndexByte(buf, 0); end >= 0 {
+		buf = buf[:end]
+	}
+	if end := bytes.Index(buf, []byte("\r\n\r\n")); end >= 0 {
+		// keeping the line break of the last header, so it is not considered truncated
+		buf = buf[:end+2]
+	}
+	lines := bytes.Split(buf, []byte("\r\n"))
+	if len(lines) < 2 {
+		return
+	}
+	// ignoring the request line and the last line, which is either empty or truncated
+	for _, line := range lines[1 : len(lines)-1] {
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(string(bytes.TrimSpace(key)))
+		if _, ok := p.capturedHeaders[name]; !ok {
+			continue
+		}
+		if span.RequestHeaders == nil {
+			span.RequestHeaders = map[string][]string{}
+		}
+		span.RequestHeaders[name] = append(span.RequestHeaders[name], string(bytes.TrimSpace(value)))
+	}
+}
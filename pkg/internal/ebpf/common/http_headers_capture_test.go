@@ -0,0 +1,58 @@
+package ebpfcommon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/config"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseCapturedHeaders(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{CaptureHeaders: []string{"X-Request-ID", " user-agent ", "accept", ""}}, "", "")
+
+	for _, tc := range []struct {
+		name     string
+		buf      string
+		spanType request.EventType
+		expected map[string][]string
+	}{{
+		name: "complete headers",
+		buf: "GET /hello HTTP/1.1\r\nHost: example.com\r\nx-request-id: abc-123\r\n" +
+			"User-Agent: curl/8.5.0\r\nAccept: text/html\r\nAccept: application/json\r\n\r\nbody: not a header\r\n",
+		spanType: request.EventTypeHTTP,
+		expected: map[string][]string{
+			"x-request-id": {"abc-123"},
+			"user-agent":   {"curl/8.5.0"},
+			"accept":       {"text/html", "application/json"},
+		},
+	}, {
+		name:     "truncated header is ignored",
+		buf:      "GET /hello HTTP/1.1\r\nUser-Agent: curl/8.5.0\r\nX-Request-ID: abc",
+		spanType: request.EventTypeHTTPClient,
+		expected: map[string][]string{"user-agent": {"curl/8.5.0"}},
+	}, {
+		name:     "no allowlisted headers",
+		buf:      "GET /hello HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		spanType: request.EventTypeHTTP,
+	}, {
+		name:     "not HTTP",
+		buf:      "GET /hello HTTP/1.1\r\nUser-Agent: curl/8.5.0\r\n\r\n",
+		spanType: request.EventTypeGRPC,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			span := request.Span{Type: tc.spanType}
+			buf := make([]byte, 192)
+			copy(buf, tc.buf)
+			parser.parseCapturedHeaders(&span, buf)
+			assert.Equal(t, tc.expected, span.RequestHeaders)
+		})
+	}
+}
+
+func TestParseCapturedHeaders_Disabled(t *testing.T) {
+	span := request.Span{Type: request.EventTypeHTTP}
+	testParser().parseCapturedHeaders(&span, []byte("GET / HTTP/1.1\r\nUser-Agent: curl/8.5.0\r\n\r\n"))
+	assert.Nil(t, span.RequestHeaders)
+}
@@ -0,0 +1,76 @@
+package ebpfcommon
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// normalizeProxyIP returns the IP in the same format as the span hosts, so IPv6 addresses
+// with different notations match
+func normalizeProxyIP(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+func (p *Parser) isKnownProxy(host string, port int) bool {
+	if len(p.knownProxies) == 0 || host == "" {
+		return false
+	}
+	if _, ok := p.knownProxies[host]; ok {
+		return true
+	}
+	_, ok := p.knownProxies[host+":"+strconv.Itoa(port)]
+	return ok
+}
+
+// parseProxyRequest attributes the HTTP client spans that are sent to a known forward proxy to the
+// host in their Host header instead of the proxy address, e.g. the HTTPS requests that are captured
+// by the TLS uprobes inside a CONNECT tunnel.
+// The CONNECT requests and the requests with an absolute-form URL (GET http://host/path) can't be
+// used, as the kernel probes only classify as HTTP the requests with an origin-form URL (GET /path).
+func (p *Parser) parseProxyRequest(span *request.Span, buf []byte, ssl bool) {
+	if span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	if !p.isKnownProxy(span.Host, span.HostPort) {
+		return
+	}
+	defaultPort := 80
+	if ssl {
+		defaultPort = 443
+	}
+	if host, port, ok := splitTargetHost(hostHeader(buf), defaultPort); ok {
+		span.Host, span.HostPort = host, port
+	}
+}
+
+// splitTargetHost splits a host with an optional port, returning the default port if it is missing
+func splitTargetHost(hostPort string, defaultPort int) (string, int, bool) {
+	if hostPort == "" {
+		return "", 0, false
+	}
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// no port, or an invalid address
+		host = strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]")
+		if strings.ContainsAny(host, " /[]") {
+			return "", 0, false
+		}
+		return host, defaultPort, true
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 || host == "" {
+		return "", 0, false
+	}
+	return host, port, true
+}
+
+// hostHeader returns the value of the Host header, if it fully fits in the captured part of the request
+func hostHeader(buf []byte) string {
+	return headerValue(buf, "host")
+}
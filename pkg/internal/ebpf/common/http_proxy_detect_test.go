@@ -0,0 +1,110 @@
+package ebpfcommon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/config"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseProxyRequest(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{HTTPProxies: []string{"10.0.0.5", " 10.0.0.6:3128 ", "[::1]:8080", ""}}, "", "")
+
+	for _, tc := range []struct {
+		name         string
+		buf          string
+		host         string
+		port         int
+		ssl          bool
+		spanType     request.EventType
+		expectedHost string
+		expectedPort int
+		expectedPath string
+	}{{
+		name:         "known proxy IP",
+		buf:          "GET /cart HTTP/1.1\r\nhost: shop.example.com\r\n\r\n",
+		host:         "10.0.0.5",
+		port:         8080,
+		ssl:          true,
+		expectedHost: "shop.example.com",
+		expectedPort: 443,
+		expectedPath: "/cart",
+	}, {
+		name:         "known proxy IP and port",
+		buf:          "GET /cart HTTP/1.1\r\nHost: shop.example.com:8000\r\n\r\n",
+		host:         "10.0.0.6",
+		port:         3128,
+		expectedHost: "shop.example.com",
+		expectedPort: 8000,
+		expectedPath: "/cart",
+	}, {
+		name:         "known proxy IPv6",
+		buf:          "GET /cart HTTP/1.1\r\nHost: shop.example.com\r\n\r\n",
+		host:         "::1",
+		port:         8080,
+		expectedHost: "shop.example.com",
+		expectedPort: 80,
+		expectedPath: "/cart",
+	}, {
+		name:         "known proxy IP, other port",
+		buf:          "GET /cart HTTP/1.1\r\nHost: shop.example.com\r\n\r\n",
+		host:         "10.0.0.6",
+		port:         8080,
+		expectedHost: "10.0.0.6",
+		expectedPort: 8080,
+		expectedPath: "/cart",
+	}, {
+		name:         "truncated Host header",
+		buf:          "GET /cart HTTP/1.1\r\nHost: shop.exa",
+		host:         "10.0.0.5",
+		port:         8080,
+		expectedHost: "10.0.0.5",
+		expectedPort: 8080,
+		expectedPath: "/cart",
+	}, {
+		name:         "not a proxy",
+		buf:          "GET /cart HTTP/1.1\r\nHost: shop.example.com\r\n\r\n",
+		host:         "10.0.0.7",
+		port:         8080,
+		expectedHost: "10.0.0.7",
+		expectedPort: 8080,
+		expectedPath: "/cart",
+	}, {
+		name:         "server span",
+		buf:          "GET /cart HTTP/1.1\r\nHost: shop.example.com\r\n\r\n",
+		host:         "10.0.0.5",
+		port:         3128,
+		spanType:     request.EventTypeHTTP,
+		expectedHost: "10.0.0.5",
+		expectedPort: 3128,
+		expectedPath: "/cart",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := BPFHTTPInfo{}
+			copy(event.Buf[:], tc.buf)
+			span := request.Span{
+				Type:     request.EventTypeHTTPClient,
+				Method:   event.method(),
+				Path:     removeQuery(event.url()),
+				Host:     tc.host,
+				HostPort: tc.port,
+			}
+			if tc.spanType != 0 {
+				span.Type = tc.spanType
+			}
+			parser.parseProxyRequest(&span, event.Buf[:], tc.ssl)
+			assert.Equal(t, tc.expectedHost, span.Host)
+			assert.Equal(t, tc.expectedPort, span.HostPort)
+			assert.Equal(t, tc.expectedPath, span.Path)
+		})
+	}
+}
+
+func TestParseProxyRequest_NoKnownProxies(t *testing.T) {
+	span := request.Span{Type: request.EventTypeHTTPClient, Method: "GET", Path: "/cart", Host: "10.0.0.5", HostPort: 3128}
+	testParser().parseProxyRequest(&span, []byte("GET /cart HTTP/1.1\r\nHost: shop.example.com\r\n\r\n"), false)
+	assert.Equal(t, "10.0.0.5", span.Host)
+	assert.Equal(t, 3128, span.HostPort)
+}
@@ -0,0 +1,80 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// parseTenant sets the tenant of the HTTP spans from the configured header, if it fully fits
+// in the captured part of the request (requestBufSize bytes, which most JWTs exceed)
+func (p *Parser) parseTenant(span *request.Span, buf []byte) {
+	if p.tenantHeader == "" {
+		return
+	}
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	value := headerValue(buf, p.tenantHeader)
+	if value == "" {
+		return
+	}
+	if p.tenantClaim == "" {
+		span.Tenant = value
+		return
+	}
+	span.Tenant = jwtClaim(value, p.tenantClaim)
+}
+
+// headerValue returns the value of the first header with the given lowercase name, ignoring the
+// last header line, which is either empty or truncated
+func headerValue(buf []byte, name string) string {
+	if end := bytes.IndexByte(buf, 0); end >= 0 {
+		buf = buf[:end]
+	}
+	lines := bytes.Split(buf, []byte("\r\n"))
+	if len(lines) < 2 {
+		return ""
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if len(line) == 0 {
+			break
+		}
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if ok && strings.EqualFold(string(bytes.TrimSpace(key)), name) {
+			return string(bytes.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// jwtClaim returns the value of a top-level claim of the payload of a JWT, with an optional
+// authentication scheme prefix (e.g. Bearer). The signature of the token is not verified.
+func jwtClaim(token, claim string) string {
+	if scheme, credentials, ok := strings.Cut(token, " "); ok && !strings.Contains(scheme, ".") {
+		token = strings.TrimSpace(credentials)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	switch value := claims[claim].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package ebpfcommon
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/config"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseTenant_Header(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{}, " X-Tenant-ID ", "")
+
+	span := request.Span{Type: request.EventTypeHTTP}
+	parser.parseTenant(&span, []byte("GET /cart HTTP/1.1\r\nHost: shop\r\nx-tenant-id: acme\r\n\r\n"))
+	assert.Equal(t, "acme", span.Tenant)
+
+	// truncated header line
+	span = request.Span{Type: request.EventTypeHTTP}
+	parser.parseTenant(&span, []byte("GET /cart HTTP/1.1\r\nHost: shop\r\nX-Tenant-ID: ac"))
+	assert.Empty(t, span.Tenant)
+
+	span = request.Span{Type: request.EventTypeGRPC}
+	parser.parseTenant(&span, []byte("GET /cart HTTP/1.1\r\nX-Tenant-ID: acme\r\n\r\n"))
+	assert.Empty(t, span.Tenant)
+}
+
+func TestParseTenant_JWTClaim(t *testing.T) {
+	parser := NewParser(&config.EPPFTracer{}, "", "org")
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1","org":"acme","num":1234}`))
+	token := "eyJhbGciOiJIUzI1NiJ9." + payload + ".c2lnbmF0dXJl"
+	span := request.Span{Type: request.EventTypeHTTPClient}
+	parser.parseTenant(&span, []byte("GET /cart HTTP/1.1\r\nAuthorization: Bearer "+token+"\r\n\r\n"))
+	assert.Equal(t, "acme", span.Tenant)
+
+	assert.Equal(t, "1234", jwtClaim(token, "num"))
+	assert.Equal(t, "acme", jwtClaim(token, "org"))
+	assert.Empty(t, jwtClaim(token, "missing"))
+	assert.Empty(t, jwtClaim("Basic dXNlcjpwYXNz", "org"))
+	assert.Empty(t, jwtClaim("a.!!!.c", "org"))
+}
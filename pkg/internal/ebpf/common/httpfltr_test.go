@@ -9,12 +9,18 @@ import (
 	"github.com/cilium/ebpf/ringbuf"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/grafana/beyla/pkg/config"
 	"github.com/grafana/beyla/pkg/internal/request"
 	"github.com/grafana/beyla/pkg/internal/svc"
 )
 
 const bufSize = 192
 
+// testParser returns a parser without any of the optional HTTP parsing options
+func testParser() *Parser {
+	return NewParser(&config.EPPFTracer{}, "", "")
+}
+
 func TestURL(t *testing.T) {
 	event := BPFHTTPInfo{
 		Buf: [bufSize]byte{'G', 'E', 'T', ' ', '/', 'p', 'a', 't', 'h', '?', 'q', 'u', 'e', 'r', 'y', '=', '1', '2', '3', '4', ' ', 'H', 'T', 'T', 'P', '/', '1', '.', '1'},
@@ -98,26 +104,31 @@ func TestToRequestTrace(t *testing.T) {
 	record.ConnInfo.S_addr = [16]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 192, 168, 0, 1}
 	record.ConnInfo.D_addr = [16]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 8, 8, 8, 8}
 	copy(record.Buf[:], "GET /hello HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	record.Len = 42
+	// bytes sent in all the chunks of the response
+	record.RespLen = 12345
 
 	buf := new(bytes.Buffer)
 	err := binary.Write(buf, binary.LittleEndian, &record)
 	assert.NoError(t, err)
 
-	result, _, err := ReadHTTPInfoIntoSpan(&ringbuf.Record{RawSample: buf.Bytes()}, &fltr)
+	result, _, err := testParser().ReadHTTPInfoIntoSpan(&ringbuf.Record{RawSample: buf.Bytes()}, &fltr)
 	assert.NoError(t, err)
 
 	expected := request.Span{
-		Host:         "8.8.8.8",
-		Peer:         "192.168.0.1",
-		Path:         "/hello",
-		Method:       "GET",
-		Status:       200,
-		Type:         request.EventTypeHTTP,
-		RequestStart: 123456,
-		Start:        123456,
-		End:          789012,
-		HostPort:     1,
-		ServiceID:    svc.ID{},
+		Host:           "8.8.8.8",
+		Peer:           "192.168.0.1",
+		Path:           "/hello",
+		Method:         "GET",
+		Status:         200,
+		Type:           request.EventTypeHTTP,
+		RequestStart:   123456,
+		Start:          123456,
+		End:            789012,
+		HostPort:       1,
+		ContentLength:  42,
+		ResponseLength: 12345,
+		ServiceID:      svc.ID{},
 	}
 	assert.Equal(t, expected, result)
 }
@@ -138,7 +149,7 @@ func TestToRequestTraceNoConnection(t *testing.T) {
 	err := binary.Write(buf, binary.LittleEndian, &record)
 	assert.NoError(t, err)
 
-	result, _, err := ReadHTTPInfoIntoSpan(&ringbuf.Record{RawSample: buf.Bytes()}, &fltr)
+	result, _, err := testParser().ReadHTTPInfoIntoSpan(&ringbuf.Record{RawSample: buf.Bytes()}, &fltr)
 	assert.NoError(t, err)
 
 	// change the expected port just before testing
@@ -176,7 +187,7 @@ func TestToRequestTrace_BadHost(t *testing.T) {
 	err := binary.Write(buf, binary.LittleEndian, &record)
 	assert.NoError(t, err)
 
-	result, _, err := ReadHTTPInfoIntoSpan(&ringbuf.Record{RawSample: buf.Bytes()}, &fltr)
+	result, _, err := testParser().ReadHTTPInfoIntoSpan(&ringbuf.Record{RawSample: buf.Bytes()}, &fltr)
 	assert.NoError(t, err)
 
 	expected := request.Span{
@@ -17,22 +17,23 @@ import (
 // misses serviceID
 func httpInfoToSpan(info *HTTPInfo) request.Span {
 	return request.Span{
-		Type:          request.EventType(info.Type),
-		Method:        info.Method,
-		Path:          removeQuery(info.URL),
-		Peer:          info.Peer,
-		PeerPort:      int(info.ConnInfo.S_port),
-		Host:          info.Host,
-		HostPort:      int(info.ConnInfo.D_port),
-		ContentLength: int64(info.Len),
-		RequestStart:  int64(info.StartMonotimeNs),
-		Start:         int64(info.StartMonotimeNs),
-		End:           int64(info.EndMonotimeNs),
-		Status:        int(info.Status),
-		TraceID:       info.Tp.TraceId,
-		SpanID:        info.Tp.SpanId,
-		ParentSpanID:  info.Tp.ParentId,
-		Flags:         info.Tp.Flags,
+		Type:           request.EventType(info.Type),
+		Method:         info.Method,
+		Path:           removeQuery(info.URL),
+		Peer:           info.Peer,
+		PeerPort:       int(info.ConnInfo.S_port),
+		Host:           info.Host,
+		HostPort:       int(info.ConnInfo.D_port),
+		ContentLength:  int64(info.Len),
+		ResponseLength: int64(info.RespLen),
+		RequestStart:   int64(info.StartMonotimeNs),
+		Start:          int64(info.StartMonotimeNs),
+		End:            int64(info.EndMonotimeNs),
+		Status:         int(info.Status),
+		TraceID:        info.Tp.TraceId,
+		SpanID:         info.Tp.SpanId,
+		ParentSpanID:   info.Tp.ParentId,
+		Flags:          info.Tp.Flags,
 		Pid: request.PidInfo{
 			HostPID:   info.Pid.HostPid,
 			UserPID:   info.Pid.UserPid,
@@ -57,7 +58,7 @@ type HTTPInfo struct {
 	Peer   string
 }
 
-func ReadHTTPInfoIntoSpan(record *ringbuf.Record, filter ServiceFilter) (request.Span, bool, error) {
+func (p *Parser) ReadHTTPInfoIntoSpan(record *ringbuf.Record, filter ServiceFilter) (request.Span, bool, error) {
 	var event BPFHTTPInfo
 	err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event)
 	if err != nil {
@@ -68,11 +69,15 @@ func ReadHTTPInfoIntoSpan(record *ringbuf.Record, filter ServiceFilter) (request
 	if !filter.ValidPID(event.Pid.UserPid, event.Pid.Ns, PIDTypeKProbes) {
 		return request.Span{}, true, nil
 	}
+	pid := request.PidInfo{HostPID: event.Pid.HostPid, UserPID: event.Pid.UserPid, Namespace: event.Pid.Ns}
+	if p.healthChecks.count(event.Type, pid, requestPath(event.Buf[:])) {
+		return request.Span{}, true, nil
+	}
 
-	return HTTPInfoEventToSpan(event)
+	return p.HTTPInfoEventToSpan(event)
 }
 
-func HTTPInfoEventToSpan(event BPFHTTPInfo) (request.Span, bool, error) {
+func (p *Parser) HTTPInfoEventToSpan(event BPFHTTPInfo) (request.Span, bool, error) {
 	result := HTTPInfo{BPFHTTPInfo: event}
 
 	// When we can't find the connection info, we signal that through making the
@@ -92,7 +97,20 @@ func HTTPInfoEventToSpan(event BPFHTTPInfo) (request.Span, bool, error) {
 	result.URL = event.url()
 	result.Method = event.method()
 
-	return httpInfoToSpan(&result), false, nil
+	span := httpInfoToSpan(&result)
+	parseGRPCWebRequest(&span, event.Buf[:])
+	parseConnectRequest(&span, event.Buf[:])
+	p.parseElasticsearchRequest(&span, event.Buf[:])
+	parseSOAPRequest(&span, event.Buf[:])
+	parseJSONRPCRequest(&span, event.Buf[:])
+	parseWebSocketUpgrade(&span, event.Buf[:])
+	p.parseProxyRequest(&span, event.Buf[:], event.Ssl == 1)
+	p.parseCapturedHeaders(&span, event.Buf[:])
+	p.parseTenant(&span, event.Buf[:])
+	p.parseEnduser(&span, event.Buf[:])
+	p.parseCapturedBody(&span, event.Buf[:])
+
+	return span, false, nil
 }
 
 func (event *BPFHTTPInfo) url() string {
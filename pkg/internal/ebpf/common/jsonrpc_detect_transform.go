@@ -0,0 +1,139 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+const (
+	jsonRPCVersion = "2.0"
+	// jsonRPCMaxMethodLen discards the method names that are too long to be a method name,
+	// and prevents attacker-controlled values from flooding the span names
+	jsonRPCMaxMethodLen = 64
+)
+
+// jsonRPCContentTypes are the media types of the JSON-RPC requests over HTTP.
+// Most implementations use application/json, and the rest are legacy or draft types.
+var jsonRPCContentTypes = map[string]struct{}{
+	"application/json":        {},
+	"application/json-rpc":    {},
+	"application/jsonrequest": {},
+}
+
+// parseJSONRPCRequest checks whether an HTTP request carries a JSON-RPC 2.0 call. In that case, it
+// decorates the span with the called method, which is taken from the captured (likely truncated) body.
+// The body must contain the "jsonrpc": "2.0" member before it is truncated, as any REST API might
+// otherwise receive a JSON object with a "method" field. For batch calls, the method of the first
+// call in the batch is taken.
+func parseJSONRPCRequest(span *request.Span, buf []byte) {
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	if span.SubType != request.HTTPSubtypeNone || span.Method != http.MethodPost {
+		return
+	}
+	if end := bytes.IndexByte(buf, 0); end >= 0 {
+		buf = buf[:end]
+	}
+	idx := bytes.Index(buf, []byte("\r\n\r\n"))
+	if idx < 0 {
+		return
+	}
+	mediaType, _, _ := strings.Cut(strings.ToLower(httpHeader(buf[:idx], "content-type")), ";")
+	if _, ok := jsonRPCContentTypes[strings.TrimSpace(mediaType)]; !ok {
+		return
+	}
+	call, ok := parseJSONRPCBody(buf[idx+4:])
+	if !ok {
+		return
+	}
+	span.SubType = request.HTTPSubtypeJSONRPC
+	span.JSONRPC = call
+}
+
+// parseJSONRPCBody extracts the method and the request ID of the first JSON-RPC 2.0 call in the body,
+// stopping at the point where the body is truncated
+func parseJSONRPCBody(body []byte) (*request.JSONRPC, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, false
+	}
+	if tok == json.Delim('[') {
+		// batch call
+		if tok, err = dec.Token(); err != nil {
+			return nil, false
+		}
+	}
+	if tok != json.Delim('{') {
+		return nil, false
+	}
+	call := request.JSONRPC{}
+	version := ""
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch key {
+		case "jsonrpc":
+			version, err = jsonRPCStringToken(dec)
+		case "method":
+			call.Method, err = jsonRPCStringToken(dec)
+		case "id":
+			call.RequestID, err = jsonRPCStringToken(dec)
+		default:
+			err = skipJSONValue(dec)
+		}
+		if err != nil || (version != "" && call.Method != "" && call.RequestID != "") {
+			break
+		}
+	}
+	if version != jsonRPCVersion || call.Method == "" || len(call.Method) > jsonRPCMaxMethodLen {
+		return nil, false
+	}
+	return &call, true
+}
+
+// jsonRPCStringToken returns the next token as a string, if it is a string or a number
+func jsonRPCStringToken(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	switch v := tok.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case json.Delim:
+		return "", errors.New("unexpected JSON object or array")
+	}
+	return "", nil
+}
+
+// skipJSONValue consumes the next value from the decoder, including all the nested values
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ebpfcommon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseJSONRPCRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		method    string
+		buf       string
+		rpcMethod string
+		requestID string
+	}{
+		{
+			name: "Ethereum node call",
+			buf: "POST / HTTP/1.1\r\nHost: geth:8545\r\nContent-Type: application/json\r\n\r\n" +
+				`{"jsonrpc":"2.0","method":"eth_getBalance","params":["0x407d73d8a49eeb85d32cf465507dd71d507100c1","latest"],"id":1}`,
+			rpcMethod: "eth_getBalance",
+			requestID: "1",
+		},
+		{
+			name: "members in any order, with the params before the method",
+			buf: "POST /rpc HTTP/1.1\r\nContent-Type: application/json-rpc; charset=utf-8\r\n\r\n" +
+				`{"id": "abc", "params": {"uri": "file:///main.go", "nested": [1, {"a": 2}]}, "method": "textDocument/hover", "jsonrpc": "2.0"}`,
+			rpcMethod: "textDocument/hover",
+			requestID: "abc",
+		},
+		{
+			name:      "notification",
+			buf:       "POST /rpc HTTP/1.1\r\nContent-Type: application/json\r\n\r\n" + `{"jsonrpc":"2.0","method":"update","params":[1,2]}`,
+			rpcMethod: "update",
+		},
+		{
+			name: "batch call",
+			buf: "POST /rpc HTTP/1.1\r\nContent-Type: application/json\r\n\r\n" +
+				`[{"jsonrpc":"2.0","method":"sum","params":[1,2,4],"id":"1"},{"jsonrpc":"2.0","method":"notify_hello"}]`,
+			rpcMethod: "sum",
+			requestID: "1",
+		},
+		{
+			name: "truncated params after the method",
+			buf: "POST / HTTP/1.1\r\nContent-Type: application/json\r\n\r\n" +
+				`{"jsonrpc":"2.0","id":7,"method":"eth_call","params":[{"to":"0x6b175474e89094c44da98b954e`,
+			rpcMethod: "eth_call",
+			requestID: "7",
+		},
+		{
+			name:   "truncated method",
+			method: "POST",
+			buf:    "POST / HTTP/1.1\r\nContent-Type: application/json\r\n\r\n" + `{"jsonrpc":"2.0","method":"eth_ca`,
+		},
+		{
+			name:   "JSON-RPC 1.0",
+			method: "POST",
+			buf:    "POST / HTTP/1.1\r\nContent-Type: application/json\r\n\r\n" + `{"method":"echo","params":["Hello"],"id":1}`,
+		},
+		{
+			name:   "REST call with a method field",
+			method: "POST",
+			buf:    "POST /payments HTTP/1.1\r\nContent-Type: application/json\r\n\r\n" + `{"method":"card","amount":100}`,
+		},
+		{
+			name:   "not JSON",
+			method: "POST",
+			buf:    "POST / HTTP/1.1\r\nContent-Type: text/plain\r\n\r\n" + `{"jsonrpc":"2.0","method":"eth_call"}`,
+		},
+		{
+			name:   "GET request",
+			method: "GET",
+			buf:    "GET / HTTP/1.1\r\nContent-Type: application/json\r\n\r\n" + `{"jsonrpc":"2.0","method":"eth_call"}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			method := "POST"
+			if tc.method != "" {
+				method = tc.method
+			}
+			span := request.Span{Type: request.EventTypeHTTP, Method: method, Path: "/"}
+			parseJSONRPCRequest(&span, []byte(tc.buf))
+			if tc.rpcMethod == "" {
+				assert.Equal(t, request.HTTPSubtypeNone, span.SubType)
+				assert.Nil(t, span.JSONRPC)
+				return
+			}
+			assert.Equal(t, request.HTTPSubtypeJSONRPC, span.SubType)
+			require.NotNil(t, span.JSONRPC)
+			assert.Equal(t, tc.rpcMethod, span.JSONRPC.Method)
+			assert.Equal(t, tc.requestID, span.JSONRPC.RequestID)
+		})
+	}
+}
+
+func TestJSONRPCSpanName(t *testing.T) {
+	event := makeBPFInfoWithBuf([]byte("POST / HTTP/1.1\r\nContent-Type: application/json\r\n\r\n" +
+		`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":83}`))
+	event.Type = uint8(request.EventTypeHTTPClient)
+	span, ignore, err := testParser().HTTPInfoEventToSpan(event)
+	require.NoError(t, err)
+	assert.False(t, ignore)
+	assert.Equal(t, request.HTTPSubtypeJSONRPC, span.SubType)
+	assert.Equal(t, "eth_blockNumber", span.TraceName())
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"regexp"
+	"strings"
 	"unsafe"
 
 	trace2 "go.opentelemetry.io/otel/trace"
@@ -31,6 +32,9 @@ type KafkaInfo struct {
 	Topic       string
 	ClientID    string
 	TopicOffset int
+	// Record is the information of the first record returned by a Fetch response, or the partition
+	// and offset of the records written by a Produce request, if they were captured
+	Record *request.KafkaRecord
 }
 
 func (k Operation) String() string {
@@ -59,6 +63,20 @@ func ProcessPossibleKafkaEvent(event *TCPRequestInfo, pkt []byte, rpkt []byte) (
 		k, err = ProcessKafkaRequest(rpkt)
 		if err == nil {
 			reverseTCPEvent(event)
+			pkt, rpkt = rpkt, pkt
+		}
+	}
+	if err == nil {
+		// the header has been already validated by ProcessKafkaRequest
+		header, _ := parseKafkaHeader(pkt)
+		switch k.Operation {
+		case Fetch:
+			k.Record = parseKafkaFetchResponse(rpkt, header.APIVersion)
+		case Produce:
+			k.Record = parseKafkaProduceRequest(pkt, header)
+			if k.Record != nil {
+				k.Record.Offset = parseKafkaProduceResponse(rpkt, header.APIVersion)
+			}
 		}
 	}
 	return k, err
@@ -342,6 +360,290 @@ func readUnsignedVarint(data []byte) (int, error) {
 	return 0, errors.New("data ended before varint was complete")
 }
 
+// kafkaReader decodes the primitive types of the Kafka protocol.
+// Any read beyond the end of the buffer sets the failed flag.
+type kafkaReader struct {
+	buf    []byte
+	pos    int
+	failed bool
+}
+
+func (r *kafkaReader) skip(n int) {
+	if r.failed || n < 0 || r.pos+n > len(r.buf) {
+		r.failed = true
+		return
+	}
+	r.pos += n
+}
+
+func (r *kafkaReader) int8() int8 {
+	r.skip(1)
+	if r.failed {
+		return 0
+	}
+	return int8(r.buf[r.pos-1])
+}
+
+func (r *kafkaReader) int16() int16 {
+	r.skip(2)
+	if r.failed {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(r.buf[r.pos-2:]))
+}
+
+func (r *kafkaReader) int32() int32 {
+	r.skip(4)
+	if r.failed {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(r.buf[r.pos-4:]))
+}
+
+func (r *kafkaReader) int64() int64 {
+	r.skip(8)
+	if r.failed {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(r.buf[r.pos-8:]))
+}
+
+func (r *kafkaReader) uvarint() int {
+	if r.failed {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 || v > KafkaMaxPayload {
+		r.failed = true
+		return 0
+	}
+	r.pos += n
+	return int(v)
+}
+
+// varint reads the zig-zag encoded integers of the record batches
+func (r *kafkaReader) varint() int {
+	if r.failed {
+		return 0
+	}
+	v, n := binary.Varint(r.buf[r.pos:])
+	if n <= 0 || v > KafkaMaxPayload || v < -1 {
+		r.failed = true
+		return 0
+	}
+	r.pos += n
+	return int(v)
+}
+
+// recordBytes reads the nullable key, value and header fields of a record
+func (r *kafkaReader) recordBytes() []byte {
+	n := r.varint()
+	if n <= 0 {
+		return nil
+	}
+	r.skip(n)
+	if r.failed {
+		return nil
+	}
+	return r.buf[r.pos-n : r.pos]
+}
+
+// length reads the size of an array or bytes field. Flexible versions use
+// compact fields, whose size is encoded as an unsigned varint plus one.
+func (r *kafkaReader) length(flexible bool) int {
+	if flexible {
+		return r.uvarint() - 1
+	}
+	return int(r.int32())
+}
+
+func (r *kafkaReader) stringLength(flexible bool) int {
+	if flexible {
+		return r.uvarint() - 1
+	}
+	return int(r.int16())
+}
+
+func (r *kafkaReader) skipTaggedFields() {
+	for n := r.uvarint(); n > 0 && !r.failed; n-- {
+		r.uvarint() // tag
+		r.skip(r.uvarint())
+	}
+}
+
+const (
+	kafkaRecordBatchMagic          = 2
+	kafkaRecordBatchCompressedMask = 0x07
+	kafkaTraceparentHeader         = "traceparent"
+)
+
+// parseKafkaFetchResponse reads the partition and the first record batch that a Fetch response returns
+// for its first topic. The captured buffer only contains the beginning of the response, so the
+// information that doesn't fit on it is left unset. It returns nil if the partition couldn't be read.
+// https://kafka.apache.org/protocol.html#The_Messages_Fetch
+// nolint:cyclop
+func parseKafkaFetchResponse(pkt []byte, apiVersion int16) *request.KafkaRecord {
+	flexible := apiVersion >= 12
+	r := kafkaReader{buf: pkt}
+	r.skip(8) // message size + correlation_id
+	if flexible {
+		r.skipTaggedFields()
+	}
+	if apiVersion >= 1 {
+		r.skip(4) // throttle_time_ms
+	}
+	if apiVersion >= 7 {
+		r.skip(2 + 4) // error_code + session_id
+	}
+	if r.length(flexible) < 1 {
+		return nil
+	}
+	if apiVersion >= 13 {
+		r.skip(16) // topic_id
+	} else {
+		r.skip(r.stringLength(flexible)) // topic name, which we already got from the request
+	}
+	if r.length(flexible) < 1 {
+		return nil
+	}
+	partition := r.int32()
+	r.skip(2) // error_code
+	highWatermark := r.int64()
+	if r.failed {
+		return nil
+	}
+	record := &request.KafkaRecord{Partition: int(partition), Offset: -1, Lag: -1}
+
+	if apiVersion >= 4 {
+		r.skip(8) // last_stable_offset
+	}
+	if apiVersion >= 5 {
+		r.skip(8) // log_start_offset
+	}
+	if apiVersion >= 4 {
+		for n := r.length(flexible); n > 0 && !r.failed; n-- {
+			r.skip(8 + 8) // aborted transaction: producer_id + first_offset
+			if flexible {
+				r.skipTaggedFields()
+			}
+		}
+	}
+	if apiVersion >= 11 {
+		r.skip(4) // preferred_read_replica
+	}
+	if r.length(flexible) <= 0 {
+		return record // no records to return
+	}
+
+	baseOffset := r.int64()
+	r.skip(4 + 4) // batch_length + partition_leader_epoch
+	magic := r.int8()
+	r.skip(4) // crc
+	attributes := r.int16()
+	lastOffsetDelta := r.int32()
+	if r.failed || magic != kafkaRecordBatchMagic || baseOffset < 0 {
+		return record
+	}
+	record.Offset = baseOffset
+	record.Lag = max(highWatermark-(baseOffset+int64(lastOffsetDelta)+1), 0)
+
+	if attributes&kafkaRecordBatchCompressedMask != 0 {
+		return record // we can't read the headers of compressed records
+	}
+	r.skip(8 + 8 + 8 + 2 + 4 + 4) // timestamps, producer id & epoch, base_sequence, records count
+
+	// headers of the first record
+	r.varint()      // length
+	r.skip(1)       // attributes
+	r.varint()      // timestamp_delta
+	r.varint()      // offset_delta
+	r.recordBytes() // key
+	r.recordBytes() // value
+	for n := r.varint(); n > 0 && !r.failed; n-- {
+		key := r.recordBytes()
+		value := r.recordBytes()
+		if !r.failed && string(key) == kafkaTraceparentHeader {
+			record.ProducerTraceID, record.ProducerSpanID = parseTraceparent(string(value))
+			break
+		}
+	}
+	return record
+}
+
+// parseKafkaProduceRequest reads the partition that a Produce request writes to for its first topic.
+// The offset of the written records is only known from the response, so it is left unset.
+// It returns nil if the partition couldn't be read.
+// https://kafka.apache.org/protocol.html#The_Messages_Produce
+func parseKafkaProduceRequest(pkt []byte, header *Header) *request.KafkaRecord {
+	flexible := header.APIVersion >= 9
+	r := kafkaReader{buf: pkt}
+	r.skip(KafkaMinLength + max(int(header.ClientIDSize), 0))
+	if flexible {
+		r.skipTaggedFields()
+	}
+	if header.APIVersion >= 3 {
+		r.skip(max(r.stringLength(flexible), 0)) // nullable transactional_id
+	}
+	r.skip(2 + 4) // acks + timeout_ms
+	if r.length(flexible) < 1 {
+		return nil
+	}
+	r.skip(r.stringLength(flexible)) // topic name
+	if r.length(flexible) < 1 {
+		return nil
+	}
+	partition := r.int32()
+	if r.failed {
+		return nil
+	}
+	return &request.KafkaRecord{Partition: int(partition), Offset: -1, Lag: -1}
+}
+
+// parseKafkaProduceResponse returns the base offset that the broker assigned to the records
+// written to the first partition of a Produce request, or -1 if it couldn't be read or
+// the partition returned an error
+func parseKafkaProduceResponse(pkt []byte, apiVersion int16) int64 {
+	flexible := apiVersion >= 9
+	r := kafkaReader{buf: pkt}
+	r.skip(8) // message size + correlation_id
+	if flexible {
+		r.skipTaggedFields()
+	}
+	if r.length(flexible) < 1 {
+		return -1
+	}
+	r.skip(r.stringLength(flexible)) // topic name
+	if r.length(flexible) < 1 {
+		return -1
+	}
+	r.skip(4) // partition index
+	errorCode := r.int16()
+	baseOffset := r.int64()
+	if r.failed || errorCode != 0 || baseOffset < 0 {
+		return -1
+	}
+	return baseOffset
+}
+
+// parseTraceparent returns the trace and span IDs of a W3C traceparent value, or empty IDs if
+// the value is not valid
+func parseTraceparent(value string) (trace2.TraceID, trace2.SpanID) {
+	// 00-<trace id>-<span id>-<flags>
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || len(parts[0]) != 2 {
+		return trace2.TraceID{}, trace2.SpanID{}
+	}
+	traceID, err := trace2.TraceIDFromHex(parts[1])
+	if err != nil {
+		return trace2.TraceID{}, trace2.SpanID{}
+	}
+	spanID, err := trace2.SpanIDFromHex(parts[2])
+	if err != nil {
+		return trace2.TraceID{}, trace2.SpanID{}
+	}
+	return traceID, spanID
+}
+
 func TCPToKafkaToSpan(trace *TCPRequestInfo, data *KafkaInfo) request.Span {
 	peer := ""
 	hostname := ""
@@ -380,5 +682,6 @@ func TCPToKafkaToSpan(trace *TCPRequestInfo, data *KafkaInfo) request.Span {
 			UserPID:   trace.Pid.UserPid,
 			Namespace: trace.Pid.Ns,
 		},
+		KafkaRecord: data.Record,
 	}
 }
@@ -1,10 +1,15 @@
 package ebpfcommon
 
 import (
+	"encoding/binary"
 	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
 )
 
 func TestProcessKafkaRequest(t *testing.T) {
@@ -302,3 +307,204 @@ func TestExtractTopic(t *testing.T) {
 		})
 	}
 }
+
+// kafkaFetchResponse builds a non-flexible (v11) Fetch response with a single uncompressed record
+func kafkaFetchResponse(partition int32, highWatermark, baseOffset int64, lastOffsetDelta int32, headers map[string]string) []byte {
+	pkt := binary.BigEndian.AppendUint32(nil, 0)  // size
+	pkt = binary.BigEndian.AppendUint32(pkt, 224) // correlation_id
+	pkt = binary.BigEndian.AppendUint32(pkt, 0)   // throttle_time_ms
+	pkt = binary.BigEndian.AppendUint16(pkt, 0)   // error_code
+	pkt = binary.BigEndian.AppendUint32(pkt, 0)   // session_id
+	pkt = binary.BigEndian.AppendUint32(pkt, 1)   // responses
+	pkt = binary.BigEndian.AppendUint16(pkt, 9)
+	pkt = append(pkt, "important"...)
+	pkt = binary.BigEndian.AppendUint32(pkt, 1) // partitions
+	pkt = binary.BigEndian.AppendUint32(pkt, uint32(partition))
+	pkt = binary.BigEndian.AppendUint16(pkt, 0) // error_code
+	pkt = binary.BigEndian.AppendUint64(pkt, uint64(highWatermark))
+	pkt = binary.BigEndian.AppendUint64(pkt, uint64(highWatermark)) // last_stable_offset
+	pkt = binary.BigEndian.AppendUint64(pkt, 0)                     // log_start_offset
+	pkt = binary.BigEndian.AppendUint32(pkt, 0xFFFFFFFF)            // null aborted_transactions
+	pkt = binary.BigEndian.AppendUint32(pkt, 0xFFFFFFFF)            // preferred_read_replica
+
+	record := []byte{0}                      // attributes
+	record = binary.AppendVarint(record, 0)  // timestamp_delta
+	record = binary.AppendVarint(record, 0)  // offset_delta
+	record = binary.AppendVarint(record, -1) // null key
+	record = binary.AppendVarint(record, 5)  // value
+	record = append(record, "hello"...)
+	record = binary.AppendVarint(record, int64(len(headers)))
+	for k, v := range headers {
+		record = binary.AppendVarint(record, int64(len(k)))
+		record = append(record, k...)
+		record = binary.AppendVarint(record, int64(len(v)))
+		record = append(record, v...)
+	}
+
+	batch := binary.BigEndian.AppendUint64(nil, uint64(baseOffset))
+	batch = binary.BigEndian.AppendUint32(batch, 0) // batch_length
+	batch = binary.BigEndian.AppendUint32(batch, 0) // partition_leader_epoch
+	batch = append(batch, 2)                        // magic
+	batch = binary.BigEndian.AppendUint32(batch, 0) // crc
+	batch = binary.BigEndian.AppendUint16(batch, 0) // attributes
+	batch = binary.BigEndian.AppendUint32(batch, uint32(lastOffsetDelta))
+	batch = append(batch, make([]byte, 8+8+8+2+4)...)
+	batch = binary.BigEndian.AppendUint32(batch, 1) // records count
+	batch = binary.AppendVarint(batch, int64(len(record)))
+	batch = append(batch, record...)
+
+	pkt = binary.BigEndian.AppendUint32(pkt, uint32(len(batch)))
+	return append(pkt, batch...)
+}
+
+func TestParseKafkaFetchResponse(t *testing.T) {
+	traceID, _ := trace2.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanID, _ := trace2.SpanIDFromHex("b7ad6b7169203331")
+	resp := kafkaFetchResponse(3, 120, 100, 4, map[string]string{
+		"origin":      "checkout",
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	})
+
+	t.Run("full response", func(t *testing.T) {
+		assert.Equal(t, &request.KafkaRecord{
+			Partition: 3, Offset: 100, Lag: 15,
+			ProducerTraceID: traceID, ProducerSpanID: spanID,
+		}, parseKafkaFetchResponse(resp, 11))
+	})
+	t.Run("headers not captured", func(t *testing.T) {
+		assert.Equal(t, &request.KafkaRecord{Partition: 3, Offset: 100, Lag: 15},
+			parseKafkaFetchResponse(resp[:128], 11))
+	})
+	t.Run("record batch not captured", func(t *testing.T) {
+		assert.Equal(t, &request.KafkaRecord{Partition: 3, Offset: -1, Lag: -1},
+			parseKafkaFetchResponse(resp[:80], 11))
+	})
+	t.Run("partition not captured", func(t *testing.T) {
+		assert.Nil(t, parseKafkaFetchResponse(resp[:40], 11))
+	})
+	t.Run("no traceparent", func(t *testing.T) {
+		assert.Equal(t, &request.KafkaRecord{Partition: 0, Offset: 7, Lag: 0},
+			parseKafkaFetchResponse(kafkaFetchResponse(0, 8, 7, 0, map[string]string{"origin": "checkout"}), 11))
+	})
+}
+
+func TestParseKafkaFetchResponseFlexible(t *testing.T) {
+	pkt := binary.BigEndian.AppendUint32(nil, 0)  // size
+	pkt = binary.BigEndian.AppendUint32(pkt, 224) // correlation_id
+	pkt = append(pkt, 0)                          // tagged fields
+	pkt = append(pkt, make([]byte, 4+2+4)...)     // throttle_time_ms, error_code, session_id
+	pkt = append(pkt, 2)                          // responses
+	pkt = append(pkt, make([]byte, 16)...)        // topic_id
+	pkt = append(pkt, 2)                          // partitions
+	pkt = binary.BigEndian.AppendUint32(pkt, 5)
+	pkt = binary.BigEndian.AppendUint16(pkt, 0)  // error_code
+	pkt = binary.BigEndian.AppendUint64(pkt, 50) // high_watermark
+	pkt = append(pkt, make([]byte, 8+8)...)      // last_stable_offset, log_start_offset
+	pkt = append(pkt, 2)                         // aborted_transactions
+	pkt = append(pkt, make([]byte, 8+8)...)      // producer_id, first_offset
+	pkt = append(pkt, 0)                         // tagged fields
+	pkt = binary.BigEndian.AppendUint32(pkt, 0)  // preferred_read_replica
+	pkt = append(pkt, 100)                       // records
+	pkt = binary.BigEndian.AppendUint64(pkt, 40) // base_offset
+	pkt = append(pkt, make([]byte, 4+4)...)      // batch_length, partition_leader_epoch
+	pkt = append(pkt, 2)                         // magic
+	pkt = append(pkt, make([]byte, 4)...)        // crc
+	pkt = binary.BigEndian.AppendUint16(pkt, 4)  // attributes: zstd compression
+	pkt = binary.BigEndian.AppendUint32(pkt, 2)  // last_offset_delta
+
+	assert.Equal(t, &request.KafkaRecord{Partition: 5, Offset: 40, Lag: 7}, parseKafkaFetchResponse(pkt, 13))
+}
+
+func TestProcessPossibleKafkaFetchEvent(t *testing.T) {
+	req := []byte{0, 0, 0, 94, 0, 1, 0, 11, 0, 0, 0, 224, 0, 6, 115, 97, 114, 97, 109, 97, 255, 255, 255, 255, 0, 0, 1, 244, 0, 0, 0, 1, 6, 64, 0, 0, 0, 0, 0, 0, 0, 255, 255, 255, 255, 0, 0, 0, 1, 0, 9, 105, 109, 112, 111, 114, 116, 97, 110, 116, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 19, 0, 0, 0, 0, 0, 0, 0, 0, 0, 16, 0, 0, 0, 0, 0, 0, 0, 0}
+	resp := kafkaFetchResponse(1, 30, 19, 0, nil)[:128]
+	expected := &request.KafkaRecord{Partition: 1, Offset: 19, Lag: 10}
+
+	t.Run("request and response", func(t *testing.T) {
+		event := TCPRequestInfo{Direction: 1}
+		k, err := ProcessPossibleKafkaEvent(&event, req, resp)
+		require.NoError(t, err)
+		assert.Equal(t, "important", k.Topic)
+		assert.Equal(t, expected, k.Record)
+		assert.Equal(t, expected, TCPToKafkaToSpan(&event, k).KafkaRecord)
+	})
+	t.Run("reversed event", func(t *testing.T) {
+		event := TCPRequestInfo{Direction: 1}
+		k, err := ProcessPossibleKafkaEvent(&event, resp, req)
+		require.NoError(t, err)
+		assert.Equal(t, expected, k.Record)
+	})
+}
+
+// kafkaProduceResponse builds a Produce response for a single topic partition
+func kafkaProduceResponse(flexible bool, partition int32, errorCode int16, baseOffset int64) []byte {
+	pkt := binary.BigEndian.AppendUint32(nil, 0)  // size
+	pkt = binary.BigEndian.AppendUint32(pkt, 224) // correlation_id
+	if flexible {
+		pkt = append(pkt, 0)    // tagged fields
+		pkt = append(pkt, 2, 9) // responses, topic name
+		pkt = append(pkt, "my-topic"...)
+		pkt = append(pkt, 2) // partitions
+	} else {
+		pkt = binary.BigEndian.AppendUint32(pkt, 1) // responses
+		pkt = binary.BigEndian.AppendUint16(pkt, 9)
+		pkt = append(pkt, "important"...)
+		pkt = binary.BigEndian.AppendUint32(pkt, 1) // partitions
+	}
+	pkt = binary.BigEndian.AppendUint32(pkt, uint32(partition))
+	pkt = binary.BigEndian.AppendUint16(pkt, uint16(errorCode))
+	return binary.BigEndian.AppendUint64(pkt, uint64(baseOffset))
+}
+
+func TestParseKafkaProduceRequest(t *testing.T) {
+	v7 := []byte{0, 0, 0, 123, 0, 0, 0, 7, 0, 0, 0, 2, 0, 6, 115, 97, 114, 97, 109, 97, 255, 255, 255, 255, 0, 0, 39, 16, 0, 0, 0, 1, 0, 9, 105, 109, 112, 111, 114, 116, 97, 110, 116, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 72}
+	v9 := []byte{0, 0, 0, 124, 0, 0, 0, 9, 0, 0, 0, 8, 0, 10, 112, 114, 111, 100, 117, 99, 101, 114, 45, 49, 0, 0, 0, 1, 0, 0, 117, 48, 2, 9, 109, 121, 45, 116, 111, 112, 105, 99, 2, 0, 0, 0, 6, 78, 103}
+
+	for _, tc := range []struct {
+		name     string
+		pkt      []byte
+		expected *request.KafkaRecord
+	}{
+		{name: "v7", pkt: v7, expected: &request.KafkaRecord{Partition: 2, Offset: -1, Lag: -1}},
+		{name: "v9", pkt: v9, expected: &request.KafkaRecord{Partition: 6, Offset: -1, Lag: -1}},
+		{name: "partition not captured", pkt: v7[:48]},
+		{name: "no partitions", pkt: append(append([]byte{}, v7[:43]...), 0, 0, 0, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			header, err := parseKafkaHeader(tc.pkt)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, parseKafkaProduceRequest(tc.pkt, header))
+		})
+	}
+}
+
+func TestParseKafkaProduceResponse(t *testing.T) {
+	assert.Equal(t, int64(1234), parseKafkaProduceResponse(kafkaProduceResponse(false, 2, 0, 1234), 7))
+	assert.Equal(t, int64(77), parseKafkaProduceResponse(kafkaProduceResponse(true, 6, 0, 77), 9))
+	// NOT_LEADER_OR_FOLLOWER
+	assert.Equal(t, int64(-1), parseKafkaProduceResponse(kafkaProduceResponse(false, 2, 6, -1), 7))
+	assert.Equal(t, int64(-1), parseKafkaProduceResponse(kafkaProduceResponse(false, 2, 0, 1234)[:30], 7))
+	// no response captured, e.g. for acks=0
+	assert.Equal(t, int64(-1), parseKafkaProduceResponse(nil, 7))
+}
+
+func TestProcessPossibleKafkaProduceEvent(t *testing.T) {
+	req := []byte{0, 0, 0, 123, 0, 0, 0, 7, 0, 0, 0, 2, 0, 6, 115, 97, 114, 97, 109, 97, 255, 255, 255, 255, 0, 0, 39, 16, 0, 0, 0, 1, 0, 9, 105, 109, 112, 111, 114, 116, 97, 110, 116, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 72}
+	event := TCPRequestInfo{Direction: 1}
+	k, err := ProcessPossibleKafkaEvent(&event, req, kafkaProduceResponse(false, 2, 0, 1234))
+	require.NoError(t, err)
+	assert.Equal(t, Produce, k.Operation)
+	assert.Equal(t, &request.KafkaRecord{Partition: 2, Offset: 1234, Lag: -1}, TCPToKafkaToSpan(&event, k).KafkaRecord)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, spanID := parseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", traceID.String())
+	assert.Equal(t, "b7ad6b7169203331", spanID.String())
+
+	for _, invalid := range []string{"", "00-0af7651916cd43dd8448eb211c80319c", "00-zzz-b7ad6b7169203331-01", "00-0af7651916cd43dd8448eb211c80319c-b7ad-01"} {
+		traceID, spanID := parseTraceparent(invalid)
+		assert.False(t, traceID.IsValid(), invalid)
+		assert.False(t, spanID.IsValid(), invalid)
+	}
+}
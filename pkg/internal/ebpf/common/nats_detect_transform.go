@@ -0,0 +1,234 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// NATS client protocol, as used by nats-io/nats.go and the other NATS clients.
+// https://docs.nats.io/reference/reference-protocols/nats-protocol
+const (
+	natsOpPub  = "PUB"
+	natsOpHPub = "HPUB"
+	natsOpMsg  = "MSG"
+	natsOpHMsg = "HMSG"
+
+	natsHeadersVersion    = "NATS/1.0"
+	natsTraceparentHeader = "traceparent"
+)
+
+// natsMessage is the first message that is published (PUB/HPUB) or delivered (MSG/HMSG)
+// in a NATS TCP event
+type natsMessage struct {
+	published bool
+	subject   string
+	replyTo   string
+	size      int64
+	// headers of HPUB and HMSG messages, if they fully fit in the captured buffer
+	headers []byte
+}
+
+// natsControlOps are the other operations of the NATS protocol, which might precede the
+// published or delivered messages in the same buffer
+var natsControlOps = map[string]struct{}{
+	"INFO": {}, "CONNECT": {}, "SUB": {}, "UNSUB": {}, "PING": {}, "PONG": {}, "+OK": {}, "-ERR": {},
+}
+
+// parseNATSMessage returns the first published or delivered message of the buffer. The buffer
+// must start with a NATS operation, and only the NATS control operations can precede the message.
+func parseNATSMessage(buf []byte) (*natsMessage, bool) {
+	for len(buf) > 0 {
+		line, rest, ok := bytes.Cut(buf, []byte("\r\n"))
+		if !ok {
+			return nil, false
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			return nil, false
+		}
+		op := strings.ToUpper(fields[0])
+		if _, ok := natsControlOps[op]; ok {
+			buf = rest
+			continue
+		}
+		msg, ok := parseNATSMessageLine(op, fields[1:])
+		if !ok {
+			return nil, false
+		}
+		if op == natsOpHPub || op == natsOpHMsg {
+			hdrLen, _ := strconv.Atoi(fields[len(fields)-2])
+			if hdrLen <= len(rest) && bytes.HasPrefix(rest, []byte(natsHeadersVersion)) {
+				msg.headers = rest[:hdrLen]
+			}
+		}
+		return msg, true
+	}
+	return nil, false
+}
+
+// isNATSOperation returns whether the buffer starts with a complete line of any operation of the
+// NATS protocol
+func isNATSOperation(buf []byte) bool {
+	line, _, ok := bytes.Cut(buf, []byte("\r\n"))
+	if !ok {
+		return false
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return false
+	}
+	op := strings.ToUpper(fields[0])
+	if _, ok := natsControlOps[op]; ok {
+		return true
+	}
+	_, ok = parseNATSMessageLine(op, fields[1:])
+	return ok
+}
+
+// parseNATSMessageLine parses the arguments of the PUB, HPUB, MSG and HMSG operations:
+//
+//	PUB <subject> [reply-to] <#bytes>
+//	HPUB <subject> [reply-to] <#header bytes> <#total bytes>
+//	MSG <subject> <sid> [reply-to] <#bytes>
+//	HMSG <subject> <sid> [reply-to] <#header bytes> <#total bytes>
+func parseNATSMessageLine(op string, args []string) (*natsMessage, bool) {
+	sizes := 1
+	msg := &natsMessage{}
+	switch op {
+	case natsOpPub:
+		msg.published = true
+	case natsOpHPub:
+		msg.published, sizes = true, 2
+	case natsOpMsg:
+	case natsOpHMsg:
+		sizes = 2
+	default:
+		return nil, false
+	}
+	// the subscription ID of the delivered messages is not reported
+	fixed := 1 + sizes
+	if !msg.published {
+		fixed++
+	}
+	if len(args) != fixed && len(args) != fixed+1 {
+		return nil, false
+	}
+	if !validNATSSubject(args[0]) {
+		return nil, false
+	}
+	msg.subject = args[0]
+	if len(args) == fixed+1 {
+		msg.replyTo = args[len(args)-sizes-1]
+		if !validNATSSubject(msg.replyTo) {
+			return nil, false
+		}
+	}
+	prev := int64(0)
+	for _, arg := range args[len(args)-sizes:] {
+		size, err := strconv.ParseInt(arg, 10, 64)
+		// the header bytes can't exceed the total bytes
+		if err != nil || size < prev {
+			return nil, false
+		}
+		prev = size
+	}
+	msg.size = prev
+	return msg, true
+}
+
+func validNATSSubject(subject string) bool {
+	if subject == "" || subject[0] == '.' || subject[len(subject)-1] == '.' {
+		return false
+	}
+	for _, c := range []byte(subject) {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// natsTraceparent returns the trace and span IDs of the traceparent header of a message, or
+// empty IDs if the message has no valid traceparent header
+func natsTraceparent(headers []byte) (trace2.TraceID, trace2.SpanID) {
+	lines := bytes.Split(headers, []byte("\r\n"))
+	for _, line := range lines[1:] {
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if ok && strings.EqualFold(string(bytes.TrimSpace(key)), natsTraceparentHeader) {
+			return parseTraceparent(string(bytes.TrimSpace(value)))
+		}
+	}
+	return trace2.TraceID{}, trace2.SpanID{}
+}
+
+// natsStatus returns 1 if the server replied to the published message with an error
+func natsStatus(resp []byte) int {
+	if bytes.HasPrefix(resp, []byte("-ERR")) {
+		return 1
+	}
+	return 0
+}
+
+func TCPToNATSToSpan(trace *TCPRequestInfo, msg *natsMessage, status int) request.Span {
+	peer := ""
+	hostname := ""
+	hostPort := 0
+
+	if trace.ConnInfo.S_port != 0 || trace.ConnInfo.D_port != 0 {
+		peer, hostname = (*BPFConnInfo)(unsafe.Pointer(&trace.ConnInfo)).reqHostInfo()
+		hostPort = int(trace.ConnInfo.D_port)
+	}
+
+	reqType := request.EventTypeNATSClient
+	if trace.Direction == 0 {
+		reqType = request.EventTypeNATSServer
+	}
+
+	method := request.MessagingPublish
+	if !msg.published {
+		method = request.MessagingProcess
+		status = 0
+	}
+
+	span := request.Span{
+		Type:          reqType,
+		Method:        method,
+		Path:          msg.subject,
+		Peer:          peer,
+		PeerPort:      int(trace.ConnInfo.S_port),
+		Host:          hostname,
+		HostPort:      hostPort,
+		ContentLength: msg.size,
+		RequestStart:  int64(trace.StartMonotimeNs),
+		Start:         int64(trace.StartMonotimeNs),
+		End:           int64(trace.EndMonotimeNs),
+		Status:        status,
+		TraceID:       trace2.TraceID(trace.Tp.TraceId),
+		SpanID:        trace2.SpanID(trace.Tp.SpanId),
+		ParentSpanID:  trace2.SpanID(trace.Tp.ParentId),
+		Flags:         trace.Tp.Flags,
+		Pid: request.PidInfo{
+			HostPID:   trace.Pid.HostPid,
+			UserPID:   trace.Pid.UserPid,
+			Namespace: trace.Pid.Ns,
+		},
+		NATS: &request.NATS{ReplyTo: msg.replyTo},
+	}
+
+	// the processing of a message continues the trace of the publisher, when its client
+	// propagated the trace context in the message headers
+	if !msg.published && msg.headers != nil {
+		if traceID, spanID := natsTraceparent(msg.headers); traceID.IsValid() && spanID.IsValid() {
+			span.TraceID = traceID
+			span.ParentSpanID = spanID
+		}
+	}
+
+	return span
+}
@@ -0,0 +1,142 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func readNATSEvent(t *testing.T, req, resp string, direction int) (request.Span, bool) {
+	trace := makeTCPReq(req, direction, 40000, 4222, 5)
+	copy(trace.Rbuf[:], resp)
+	trace.RespLen = uint32(len(resp))
+	binaryRecord := bytes.Buffer{}
+	require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+	span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+	require.NoError(t, err)
+	return span, ignore
+}
+
+func TestParseNATSMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		buf       string
+		ok        bool
+		published bool
+		subject   string
+		replyTo   string
+		size      int64
+	}{
+		{name: "pub", buf: "PUB orders.created 5\r\nhello\r\n",
+			ok: true, published: true, subject: "orders.created", size: 5},
+		{name: "pub with reply", buf: "PUB orders.get _INBOX.abc.1 2\r\nid\r\n",
+			ok: true, published: true, subject: "orders.get", replyTo: "_INBOX.abc.1", size: 2},
+		{name: "hpub", buf: "HPUB orders.created 12 17\r\nNATS/1.0\r\n\r\nhello\r\n",
+			ok: true, published: true, subject: "orders.created", size: 17},
+		{name: "msg", buf: "MSG orders.created 9 5\r\nhello\r\n",
+			ok: true, subject: "orders.created", size: 5},
+		{name: "hmsg with reply", buf: "HMSG orders.get 3 _INBOX.abc.1 12 14\r\nNATS/1.0\r\n\r\nid\r\n",
+			ok: true, subject: "orders.get", replyTo: "_INBOX.abc.1", size: 14},
+		{name: "after control operations", buf: "CONNECT {\"verbose\":false}\r\nPING\r\nsub foo 1\r\npub foo 0\r\n\r\n",
+			ok: true, published: true, subject: "foo"},
+		{name: "only control operations", buf: "PING\r\nPONG\r\n"},
+		{name: "missing size", buf: "PUB orders.created\r\n"},
+		{name: "invalid size", buf: "PUB orders.created five\r\n"},
+		{name: "headers larger than message", buf: "HPUB orders 20 10\r\n"},
+		{name: "too many arguments", buf: "MSG orders 1 reply 2 3\r\n"},
+		{name: "invalid subject", buf: "PUB .orders 1\r\n"},
+		{name: "truncated line", buf: "PUB orders.created 5"},
+		{name: "other protocol", buf: "GET / HTTP/1.1\r\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, ok := parseNATSMessage([]byte(tc.buf))
+			require.Equal(t, tc.ok, ok)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tc.published, msg.published)
+			assert.Equal(t, tc.subject, msg.subject)
+			assert.Equal(t, tc.replyTo, msg.replyTo)
+			assert.Equal(t, tc.size, msg.size)
+		})
+	}
+}
+
+func TestTCPToNATSToSpan_Publish(t *testing.T) {
+	span, ignore := readNATSEvent(t, "PUB orders.get _INBOX.abc.1 2\r\nid\r\n", "+OK\r\n", 1)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeNATSClient, span.Type)
+	assert.Equal(t, request.MessagingPublish, span.Method)
+	assert.Equal(t, "orders.get", span.Path)
+	assert.Equal(t, int64(2), span.ContentLength)
+	assert.Equal(t, "orders.get publish", span.TraceName())
+	assert.Equal(t, "nats", span.MessagingSystemName())
+	assert.Equal(t, "SPAN_KIND_PRODUCER", span.ServiceGraphKind())
+	assert.Equal(t, codes.Unset, request.SpanStatusCode(&span))
+	require.NotNil(t, span.NATS)
+	assert.Equal(t, "_INBOX.abc.1", span.NATS.ReplyTo)
+
+	span, ignore = readNATSEvent(t, "PUB orders.get 2\r\nid\r\n", "-ERR 'Permissions Violation for Publish to orders.get'\r\n", 1)
+	require.False(t, ignore)
+	assert.Equal(t, codes.Error, request.SpanStatusCode(&span))
+}
+
+func TestTCPToNATSToSpan_Process(t *testing.T) {
+	headers := "NATS/1.0\r\ntraceparent: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01\r\n\r\n"
+	msg := "HMSG orders.get 3 _INBOX.abc.1 82 84\r\n" + headers + "id\r\n"
+	require.Len(t, headers, 82)
+
+	// the delivered message is caught as the response of the client
+	span, ignore := readNATSEvent(t, "PONG\r\n", msg, 1)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeNATSServer, span.Type)
+	assert.Equal(t, request.MessagingProcess, span.Method)
+	assert.Equal(t, "orders.get", span.Path)
+	assert.Equal(t, int64(84), span.ContentLength)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span.TraceID.String())
+	assert.Equal(t, "b7ad6b7169203331", span.ParentSpanID.String())
+	require.NotNil(t, span.NATS)
+	assert.Equal(t, "_INBOX.abc.1", span.NATS.ReplyTo)
+
+	span, ignore = readNATSEvent(t, "MSG orders.created 9 5\r\nhello\r\n", "PING\r\n", 1)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeNATSClient, span.Type)
+	assert.Equal(t, request.MessagingProcess, span.Method)
+	assert.Equal(t, "SPAN_KIND_CONSUMER", span.ServiceGraphKind())
+	assert.False(t, span.TraceID.IsValid())
+}
+
+func TestTCPToNATSToSpan_RequiresBothSides(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		req  string
+		resp string
+	}{
+		{name: "no response", req: "PUB orders.created 5\r\nhello\r\n"},
+		{name: "non-NATS response", req: "PUB orders.created 5\r\nhello\r\n", resp: "HTTP/1.1 200 OK\r\n\r\n"},
+		{name: "response-only match", req: "GET /orders HTTP/1.1\r\n\r\n", resp: "MSG orders.created 9 5\r\nhello\r\n"},
+		{name: "response-only match without request", resp: "MSG orders.created 9 5\r\nhello\r\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			span, _ := readNATSEvent(t, tc.req, tc.resp, 1)
+			assert.NotEqual(t, request.EventTypeNATSClient, span.Type)
+			assert.NotEqual(t, request.EventTypeNATSServer, span.Type)
+		})
+	}
+}
+
+func TestIsNATSOperation(t *testing.T) {
+	assert.True(t, isNATSOperation([]byte("+OK\r\n")))
+	assert.True(t, isNATSOperation([]byte("-ERR 'Unknown Protocol Operation'\r\n")))
+	assert.True(t, isNATSOperation([]byte("MSG orders.created 9 5\r\nhello\r\n")))
+	assert.False(t, isNATSOperation([]byte("+OK")))
+	assert.False(t, isNATSOperation([]byte("HTTP/1.1 200 OK\r\n")))
+	assert.False(t, isNATSOperation(nil))
+}
@@ -0,0 +1,238 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/sqlprune"
+)
+
+// TNS (Transparent Network Substrate) packet types, as used by Oracle Database (SQL*Net)
+const (
+	tnsPacketConnect  = 1
+	tnsPacketAccept   = 2
+	tnsPacketRefuse   = 4
+	tnsPacketRedirect = 5
+	tnsPacketData     = 6
+	tnsPacketResend   = 11
+	tnsPacketMarker   = 12
+
+	tnsHeaderLen = 8
+	// data packets start with two bytes of data flags
+	tnsDataFlagsLen = 2
+	// since TNS version 315, the length of the packets might take the 4 bytes that were
+	// formerly reserved for the length and the packet checksum
+	tnsMaxPacketSize = 2 * 1024 * 1024
+)
+
+// TTC (Two-Task Common) messages, which are carried inside the TNS data packets
+const (
+	ttcMsgFunction  = 3
+	ttcMsgPiggyback = 17
+
+	ttcFuncReexecute         = 4
+	ttcFuncFetch             = 5
+	ttcFuncCommit            = 14
+	ttcFuncRollback          = 15
+	ttcFuncReexecuteAndFetch = 78
+	ttcFuncExecute           = 94
+)
+
+// ttcFunctionOps maps the TTC functions that we report to the operation of the span.
+// Other functions (authentication, pings, LOB operations...) are ignored.
+var ttcFunctionOps = map[byte]string{
+	ttcFuncReexecute:         "EXECUTE",
+	ttcFuncFetch:             "FETCH",
+	ttcFuncCommit:            "COMMIT",
+	ttcFuncRollback:          "ROLLBACK",
+	ttcFuncReexecuteAndFetch: "EXECUTE",
+	ttcFuncExecute:           "EXECUTE",
+}
+
+// oracleSQLKeywords that can start the text of the statements sent in the execute calls
+var oracleSQLKeywords = []string{
+	"SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "WITH",
+	"BEGIN", "DECLARE", "CALL", "CREATE", "ALTER", "DROP", "TRUNCATE",
+}
+
+type oracleQuery struct {
+	op        string
+	table     string
+	statement string
+}
+
+// tnsPacketType returns the type of a TNS packet, after checking that its header is valid
+func tnsPacketType(buf []byte) (byte, bool) {
+	if len(buf) < tnsHeaderLen {
+		return 0, false
+	}
+	length := int(binary.BigEndian.Uint16(buf[0:2]))
+	if length == 0 {
+		length = int(binary.BigEndian.Uint32(buf[0:4]))
+	} else if buf[2] != 0 || buf[3] != 0 {
+		// the packet checksum is not used
+		return 0, false
+	}
+	// the header checksum is not used either
+	if length < tnsHeaderLen || length > tnsMaxPacketSize || buf[6] != 0 || buf[7] != 0 {
+		return 0, false
+	}
+	switch packetType := buf[4]; packetType {
+	case tnsPacketConnect, tnsPacketAccept, tnsPacketRefuse, tnsPacketRedirect,
+		tnsPacketData, tnsPacketResend, tnsPacketMarker:
+		return packetType, true
+	}
+	return 0, false
+}
+
+// parseOracleRequest coarsely parses the requests of the Oracle TNS protocol: the connection
+// establishment, and the execute and fetch calls of the data packets. The statements are
+// extracted on a best-effort basis, as the execute calls are only partially decoded.
+func parseOracleRequest(buf []byte) (*oracleQuery, bool) {
+	packetType, ok := tnsPacketType(buf)
+	if !ok {
+		return nil, false
+	}
+	switch packetType {
+	case tnsPacketConnect:
+		return oracleConnect(buf[tnsHeaderLen:])
+	case tnsPacketData:
+		if len(buf) < tnsHeaderLen+tnsDataFlagsLen {
+			return nil, false
+		}
+		return oracleCall(buf[tnsHeaderLen+tnsDataFlagsLen:])
+	}
+	return nil, false
+}
+
+// oracleConnect reports the service name (or SID) of the connect descriptor, e.g.
+// (DESCRIPTION=(CONNECT_DATA=(SERVICE_NAME=ORCLPDB1))(ADDRESS=(PROTOCOL=TCP)(HOST=db)(PORT=1521)))
+func oracleConnect(body []byte) (*oracleQuery, bool) {
+	descriptor := asciiToUpper(string(body))
+	if !strings.Contains(descriptor, "(DESCRIPTION=") && !strings.Contains(descriptor, "(CONNECT_DATA=") {
+		return nil, false
+	}
+	q := &oracleQuery{op: "CONNECT"}
+	for _, key := range []string{"(SERVICE_NAME=", "(SID="} {
+		if start := strings.Index(descriptor, key); start >= 0 {
+			value := string(body[start+len(key):])
+			if end := strings.IndexByte(value, ')'); end >= 0 {
+				q.table = value[:end]
+				break
+			}
+		}
+	}
+	return q, true
+}
+
+// oracleCall looks for the TTC function of a data packet. Some calls piggyback other
+// functions (e.g. closing the cursors) before the actual one.
+func oracleCall(body []byte) (*oracleQuery, bool) {
+	if len(body) < 2 {
+		return nil, false
+	}
+	var function byte
+	switch body[0] {
+	case ttcMsgFunction:
+		function = body[1]
+	case ttcMsgPiggyback:
+		for i := 2; i < len(body)-1; i++ {
+			if body[i] == ttcMsgFunction {
+				if _, ok := ttcFunctionOps[body[i+1]]; ok {
+					function = body[i+1]
+					break
+				}
+			}
+		}
+	}
+	op, ok := ttcFunctionOps[function]
+	if !ok {
+		return nil, false
+	}
+	q := &oracleQuery{op: op}
+	if function == ttcFuncExecute {
+		q.statement = oracleSQLText(body[2:])
+		if q.statement != "" {
+			q.op, q.table = oracleSQLOperation(q.statement)
+		}
+	}
+	return q, true
+}
+
+// oracleSQLText returns the printable text that starts with the first SQL keyword, as the statement
+// is preceded by a variable amount of encoded fields that we don't decode. The byte that precedes
+// the statement is its length, so it can't be used to check the start of the keyword.
+func oracleSQLText(body []byte) string {
+	upper := asciiToUpper(string(body))
+	start := -1
+	for _, kw := range oracleSQLKeywords {
+		if idx := strings.Index(upper, kw); idx >= 0 && (start < 0 || idx < start) {
+			start = idx
+		}
+	}
+	if start < 0 {
+		return ""
+	}
+	end := start
+	for end < len(body) && (body[end] >= ' ' && body[end] < 0x7f || body[end] == '\t' || body[end] == '\n' || body[end] == '\r') {
+		end++
+	}
+	return strings.TrimSpace(sanitizeTSQL(string(body[start:end])))
+}
+
+// oracleSQLOperation returns the operation and table of a statement. PL/SQL blocks are reported as
+// executions of the first procedure that they invoke.
+func oracleSQLOperation(text string) (string, string) {
+	upper := asciiToUpper(text)
+	for _, kw := range []string{"BEGIN ", "CALL "} {
+		if strings.HasPrefix(upper, kw) {
+			proc := strings.TrimSpace(text[len(kw):])
+			if end := strings.IndexAny(proc, "(; \t\r\n"); end >= 0 {
+				proc = proc[:end]
+			}
+			return "EXECUTE", proc
+		}
+	}
+	if strings.HasPrefix(upper, "DECLARE") || strings.HasPrefix(upper, "BEGIN") {
+		return "EXECUTE", ""
+	}
+	if op, table := sqlprune.SQLParseOperationAndTable(text); op != "" {
+		return op, table
+	}
+	return "EXECUTE", ""
+}
+
+// oracleStatus returns 1 if the server refused the connection or reported an error, 0 otherwise.
+// As only the first bytes of the response are captured, this is only detected when the
+// error message is at the start of the response.
+func oracleStatus(resp []byte) int {
+	packetType, ok := tnsPacketType(resp)
+	if !ok {
+		return 0
+	}
+	switch packetType {
+	case tnsPacketRefuse:
+		return 1
+	case tnsPacketData:
+		// ORA-01403 (no data found) is reported at the end of the fetched rows
+		if idx := bytes.Index(resp, []byte("ORA-")); idx >= 0 && !bytes.HasPrefix(resp[idx:], []byte("ORA-01403")) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// isOracleResponse checks whether the buffer is a TNS packet that a server sends as a response
+func isOracleResponse(buf []byte) bool {
+	packetType, ok := tnsPacketType(buf)
+	return ok && packetType != tnsPacketConnect
+}
+
+func TCPToOracleToSpan(trace *TCPRequestInfo, q *oracleQuery, status int) request.Span {
+	span := TCPToSQLToSpan(trace, q.op, q.table, q.statement)
+	span.Status = status
+	span.SubType = request.SQLSubtypeOracle
+	return span
+}
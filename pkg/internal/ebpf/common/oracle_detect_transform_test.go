@@ -0,0 +1,138 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// tnsPacket builds a TNS packet with the 2-bytes length of the older protocol versions
+func tnsPacket(packetType byte, body []byte) []byte {
+	p := []byte{0, 0, 0, 0, packetType, 0, 0, 0}
+	binary.BigEndian.PutUint16(p[0:2], uint16(tnsHeaderLen+len(body)))
+	return append(p, body...)
+}
+
+// tnsDataPacket builds a TNS data packet with the 4-bytes length of the newer protocol versions
+func tnsDataPacket(body ...byte) []byte {
+	p := []byte{0, 0, 0, 0, tnsPacketData, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(p[0:4], uint32(tnsHeaderLen+tnsDataFlagsLen+len(body)))
+	return append(p, body...)
+}
+
+// ttcExecute builds an execute call, with some of the encoded fields that precede the statement
+func ttcExecute(sql string) []byte {
+	body := []byte{ttcMsgFunction, ttcFuncExecute, 0x05, 0x01, 0x01, 0x02, 0x03, 0xE1, 0x01, 0x01, byte(len(sql))}
+	return tnsDataPacket(append(body, sql...)...)
+}
+
+func TestParseOracleRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		packet    []byte
+		op        string
+		table     string
+		statement string
+	}{{
+		name: "connect",
+		packet: tnsPacket(tnsPacketConnect, append([]byte{1, 0x3B, 1, 0x2C, 0, 0}, []byte(
+			"(DESCRIPTION=(CONNECT_DATA=(SERVICE_NAME=ORCLPDB1)(CID=(PROGRAM=app)))(ADDRESS=(PROTOCOL=tcp)(HOST=db)(PORT=1521)))")...)),
+		op:    "CONNECT",
+		table: "ORCLPDB1",
+	}, {
+		name:   "connect with SID",
+		packet: tnsPacket(tnsPacketConnect, []byte("(description=(connect_data=(sid=XE)))")),
+		op:     "CONNECT",
+		table:  "XE",
+	}, {
+		name:      "execute query",
+		packet:    ttcExecute("SELECT id, name FROM customers WHERE id = :1"),
+		op:        "SELECT",
+		table:     "customers",
+		statement: "SELECT id, name FROM customers WHERE id = :1",
+	}, {
+		name:      "execute DML with piggybacked close cursors",
+		packet:    tnsDataPacket(append([]byte{ttcMsgPiggyback, 0x69, 0x00, 0x01, ttcMsgFunction, ttcFuncExecute, 0x21, 0x01, 0x30}, "UPDATE orders SET status = :1"...)...),
+		op:        "UPDATE",
+		table:     "orders",
+		statement: "UPDATE orders SET status = :1",
+	}, {
+		name:      "PL/SQL block",
+		packet:    ttcExecute("BEGIN billing.charge(:1, :2); END;"),
+		op:        "EXECUTE",
+		table:     "billing.charge",
+		statement: "BEGIN billing.charge(:1, :2); END;",
+	}, {
+		name:   "execute without the statement text",
+		packet: tnsDataPacket(ttcMsgFunction, ttcFuncExecute, 0x05, 0x01),
+		op:     "EXECUTE",
+	}, {
+		name:   "re-execute of a cached cursor",
+		packet: tnsDataPacket(ttcMsgFunction, ttcFuncReexecuteAndFetch, 0x07, 0x01, 0x0A),
+		op:     "EXECUTE",
+	}, {
+		name:   "fetch",
+		packet: tnsPacket(tnsPacketData, []byte{0, 0, ttcMsgFunction, ttcFuncFetch, 0x08, 0x01, 0x0A, 0x01, 0x32}),
+		op:     "FETCH",
+	}, {
+		name:   "commit",
+		packet: tnsDataPacket(ttcMsgFunction, ttcFuncCommit, 0x09),
+		op:     "COMMIT",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			q, ok := parseOracleRequest(tc.packet)
+			require.True(t, ok)
+			assert.Equal(t, tc.op, q.op)
+			assert.Equal(t, tc.table, q.table)
+			assert.Equal(t, tc.statement, q.statement)
+		})
+	}
+}
+
+func TestParseOracleRequest_NotOracle(t *testing.T) {
+	for _, buf := range [][]byte{
+		[]byte("SELECT * FROM users"),
+		[]byte("*2\r\n$3\r\nGET\r\n$5\r\nbeyla"),
+		tdsSQLBatch("SELECT * FROM Orders"),
+		clickHouseQueryPacket(54460, "SELECT * FROM events"),
+		// connect packet without a connect descriptor
+		tnsPacket(tnsPacketConnect, []byte("hello world")),
+		// authentication call
+		tnsDataPacket(ttcMsgFunction, 0x76, 0x01),
+		// response
+		tnsPacket(tnsPacketAccept, []byte{1, 0x3B, 0, 0}),
+		// non-zero header checksum
+		{0, 20, 0, 0, tnsPacketData, 0, 1, 1, 0, 0, ttcMsgFunction, ttcFuncFetch},
+		{},
+	} {
+		_, ok := parseOracleRequest(buf)
+		assert.False(t, ok, "%q", buf)
+	}
+}
+
+func TestOracleStatus(t *testing.T) {
+	assert.Equal(t, 0, oracleStatus(tnsPacket(tnsPacketAccept, []byte{1, 0x3B, 0, 0})))
+	assert.Equal(t, 1, oracleStatus(tnsPacket(tnsPacketRefuse, []byte("(DESCRIPTION=(ERR=12514))"))))
+	assert.Equal(t, 0, oracleStatus(tnsDataPacket(0x10, 0x17, 0x01, 0x02)))
+	assert.Equal(t, 1, oracleStatus(tnsDataPacket(append([]byte{0x04, 0x01, 0x01}, "ORA-00942: table or view does not exist"...)...)))
+	assert.Equal(t, 0, oracleStatus(tnsDataPacket(append([]byte{0x04, 0x01, 0x01}, "ORA-01403: no data found"...)...)))
+	assert.Equal(t, 0, oracleStatus([]byte("ORA-00942")))
+}
+
+func TestTCPToOracleToSpan(t *testing.T) {
+	trace := makeTCPReq("", 0, 10, 20, 0)
+	q, ok := parseOracleRequest(ttcExecute("DELETE FROM sessions WHERE id = :1"))
+	require.True(t, ok)
+
+	span := TCPToOracleToSpan(&trace, q, 0)
+	assert.Equal(t, request.EventTypeSQLClient, span.Type)
+	assert.Equal(t, request.SQLSubtypeOracle, span.SubType)
+	assert.Equal(t, "DELETE", span.Method)
+	assert.Equal(t, "sessions", span.Path)
+	assert.Equal(t, "DELETE FROM sessions WHERE id = :1", span.Statement)
+	assert.Equal(t, "oracle", span.DBSystemName())
+}
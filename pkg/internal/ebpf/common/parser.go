@@ -0,0 +1,84 @@
+package ebpfcommon
+
+import (
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/config"
+)
+
+// Parser decodes the events that the tracers send through the shared ring buffer into spans,
+// according to the user-space parsing options of the HTTP requests.
+// These options are applied on the request buffer that the kernel probes capture (BPFHTTPInfo.Buf,
+// 192 bytes), so they only see the request line and the headers that fit in it. The response headers
+// and the requests of the Go services, whose probes don't capture the request buffer, are not parsed.
+type Parser struct {
+	// capturedHeaders is the allowlist of the HTTP request headers that are attached to the spans,
+	// keyed by their lowercase name
+	capturedHeaders map[string]struct{}
+	// bodyCapture configures the sampled capture of the HTTP request bodies
+	bodyCapture config.BodyCapture
+	// knownProxies contains the addresses of the forward proxies, as "ip" or "ip:port", whose
+	// client spans are attributed to the target of the request instead of the proxy
+	knownProxies map[string]struct{}
+	// elasticsearchPorts corroborate that the requests with the shape of the Elasticsearch REST API
+	// are Elasticsearch requests
+	elasticsearchPorts map[int]struct{}
+	// tenantHeader is the lowercase name of the HTTP request header that identifies the tenant
+	// of the requests, and tenantClaim, if not empty, is the claim of the JWT in that header
+	// that contains the tenant
+	tenantHeader, tenantClaim string
+	// jwtSubject configures the attribution of the server spans to the subject of their bearer token
+	jwtSubject config.JWTSubject
+	// healthChecks counts the HTTP server requests to the configured health-check paths, which are
+	// discarded while they are decoded, before any span is created for them
+	healthChecks *healthCheckCounter
+}
+
+// NewParser creates a Parser from the eBPF tracer configuration and the HTTP request header, and
+// optionally the claim of the JWT in that header, that identifies the tenant of the requests.
+// If only the claim is provided, the JWT is taken from the Authorization header.
+func NewParser(cfg *config.EPPFTracer, tenantHeader, tenantClaim string) *Parser {
+	p := &Parser{
+		capturedHeaders:    make(map[string]struct{}, len(cfg.CaptureHeaders)),
+		bodyCapture:        cfg.CaptureBodies,
+		knownProxies:       make(map[string]struct{}, len(cfg.HTTPProxies)),
+		elasticsearchPorts: make(map[int]struct{}, len(cfg.ElasticsearchPorts)),
+		tenantHeader:       strings.ToLower(strings.TrimSpace(tenantHeader)),
+		tenantClaim:        strings.TrimSpace(tenantClaim),
+		jwtSubject:         cfg.JWTSubject,
+		healthChecks:       newHealthCheckCounter(cfg.HealthCheckPaths),
+	}
+	for _, name := range cfg.CaptureHeaders {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			p.capturedHeaders[name] = struct{}{}
+		}
+	}
+	for _, addr := range cfg.HTTPProxies {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			addr = normalizeProxyIP(host) + ":" + port
+		} else {
+			addr = normalizeProxyIP(addr)
+		}
+		p.knownProxies[addr] = struct{}{}
+	}
+	for _, port := range cfg.ElasticsearchPorts {
+		p.elasticsearchPorts[port] = struct{}{}
+	}
+	if p.tenantHeader == "" && p.tenantClaim != "" {
+		p.tenantHeader = "authorization"
+	}
+	if p.jwtSubject.Claim == "" {
+		p.jwtSubject.Claim = "sub"
+	}
+	if p.bodyCapture.Size > requestBufSize {
+		slog.Warn("the captured HTTP request bodies can't be longer than the request buffer of the eBPF probes",
+			"component", "ebpfcommon.Parser", "size", p.bodyCapture.Size, "maxSize", requestBufSize)
+		p.bodyCapture.Size = requestBufSize
+	}
+	return p
+}
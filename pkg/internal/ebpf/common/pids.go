@@ -141,6 +141,9 @@ func (pf *PIDsFilter) Filter(inputSpans []request.Span) []request.Span {
 		// saw. We don't check for the host pid, because we can't be sure of the number
 		// of container layers. The Host PID is always the outer most layer.
 		if info, pidExists := ns[span.Pid.UserPID]; pidExists {
+			if info.service.OverheadBenchmark() {
+				continue
+			}
 			if pf.detectOtel {
 				checkIfExportsOTel(info.service, span)
 			}
@@ -3,6 +3,7 @@ package ebpfcommon
 import (
 	"bytes"
 	"encoding/binary"
+	"strconv"
 	"strings"
 	"unsafe"
 
@@ -140,6 +141,28 @@ func redisStatus(buf []byte) int {
 	return status
 }
 
+// redisRedirect parses the MOVED and ASK error replies that Redis Cluster nodes send when the key
+// of the command belongs to a hash slot that is served by another node, e.g.
+// "-MOVED 3999 127.0.0.1:6381\r\n"
+func redisRedirect(buf []byte) *request.RedisRedirect {
+	if len(buf) == 0 || buf[0] != '-' {
+		return nil
+	}
+	line, _, ok := strings.Cut(string(buf[1:]), "\r\n")
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return nil
+	}
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil
+	}
+	return &request.RedisRedirect{Type: fields[0], Slot: slot, Address: fields[2]}
+}
+
 func TCPToRedisToSpan(trace *TCPRequestInfo, op, text string, status int) request.Span {
 	peer := ""
 	hostname := ""
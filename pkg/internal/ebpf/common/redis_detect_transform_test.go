@@ -1,10 +1,16 @@
 package ebpfcommon
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"testing"
 
+	"github.com/cilium/ebpf/ringbuf"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
 )
 
 type crlfTest struct {
@@ -74,3 +80,48 @@ func TestIsRedis(t *testing.T) {
 	assert.True(t, isRedis(buf))
 	assert.True(t, isRedis(rbuf))
 }
+
+func TestRedisRedirect(t *testing.T) {
+	assert.Equal(t, &request.RedisRedirect{Type: "MOVED", Slot: 3999, Address: "127.0.0.1:6381"},
+		redisRedirect([]byte("-MOVED 3999 127.0.0.1:6381\r\n")))
+	assert.Equal(t, &request.RedisRedirect{Type: "ASK", Slot: 12182, Address: "redis-2:6379"},
+		redisRedirect([]byte("-ASK 12182 redis-2:6379\r\n")))
+
+	assert.Nil(t, redisRedirect([]byte("-ERR unknown command 'FOO'\r\n")))
+	assert.Nil(t, redisRedirect([]byte("+OK\r\n")))
+	assert.Nil(t, redisRedirect([]byte("-MOVED abc 127.0.0.1:6381\r\n")))
+	assert.Nil(t, redisRedirect([]byte("-MOVED 3999 127.0.0.1:6381")))
+	assert.Nil(t, redisRedirect(nil))
+}
+
+func TestRedisRedirectSpan(t *testing.T) {
+	req := "*2\r\n$3\r\nGET\r\n$6\r\nuser:1\r\n"
+	resp := "-MOVED 3999 10.0.0.3:6379\r\n"
+	for _, tc := range []struct {
+		name     string
+		reversed bool
+		spanType request.EventType
+	}{
+		{name: "client", spanType: request.EventTypeRedisClient},
+		{name: "reversed", reversed: true, spanType: request.EventTypeRedisServer},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trace := makeTCPReq(req, 1, 40000, 6379, 5)
+			copy(trace.Rbuf[:], resp)
+			trace.RespLen = uint32(len(resp))
+			if tc.reversed {
+				trace = makeTCPReq(resp, 1, 6379, 40000, 5)
+				copy(trace.Rbuf[:], req)
+				trace.RespLen = uint32(len(req))
+			}
+			binaryRecord := bytes.Buffer{}
+			require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+			span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+			require.NoError(t, err)
+			require.False(t, ignore)
+			assert.Equal(t, tc.spanType, span.Type)
+			assert.Equal(t, "GET", span.Method)
+			assert.Equal(t, &request.RedisRedirect{Type: "MOVED", Slot: 3999, Address: "10.0.0.3:6379"}, span.RedisRedirect)
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cilium/ebpf"
@@ -20,7 +22,9 @@ import (
 // dependency injection during tests
 type ringBufReader interface {
 	io.Closer
-	Read() (ringbuf.Record, error)
+	ReadInto(*ringbuf.Record) error
+	SetDeadline(time.Time)
+	Flush() error
 }
 
 // readerFactory instantiates a ringBufReader from a ring buffer. In unit tests, we can
@@ -38,11 +42,24 @@ type ringBufForwarder struct {
 	spansLen   int
 	access     sync.Mutex
 	ticker     *time.Ticker
-	reader     func(*ringbuf.Record, ServiceFilter) (request.Span, bool, error)
+	// reader decodes a record into a span. The records are reused between reads,
+	// so the reader must not keep references to their RawSample.
+	reader func(*ringbuf.Record, ServiceFilter) (request.Span, bool, error)
 	// filter the input spans, eliminating these from processes whose PID
 	// belong to a process that does not match the discovery policies
 	filter  ServiceFilter
 	metrics imetrics.Reporter
+	// parser of the shared ring buffer events. It is nil for the other ring buffers.
+	parser *Parser
+
+	// watchdog state. lastRead is the time when the reader started waiting for a new event,
+	// and lastEvent is the time when the last event was received from the ring buffer.
+	reading   atomic.Bool
+	flushing  atomic.Bool
+	lastRead  atomic.Int64
+	lastEvent atomic.Int64
+	// flushedEvents is only accessed from the reader goroutine
+	flushedEvents int
 }
 
 var singleRbf *ringBufForwarder
@@ -53,6 +70,7 @@ var singleRbfLock sync.Mutex
 // instances.
 func SharedRingbuf(
 	cfg *config.EPPFTracer,
+	parser *Parser,
 	filter ServiceFilter,
 	ringbuffer *ebpf.Map,
 	metrics imetrics.Reporter,
@@ -67,8 +85,8 @@ func SharedRingbuf(
 	log := slog.With("component", "ringbuf.Tracer")
 	rbf := ringBufForwarder{
 		cfg: cfg, logger: log, ringbuffer: ringbuffer,
-		closers: nil, reader: ReadBPFTraceAsSpan,
-		filter: filter, metrics: metrics,
+		closers: nil, reader: parser.ReadBPFTraceAsSpan,
+		filter: filter, metrics: metrics, parser: parser,
 	}
 	singleRbf = &rbf
 	return singleRbf.sharedReadAndForward
@@ -137,7 +155,7 @@ func (rbf *ringBufForwarder) readAndForwardInner(eventsReader ringBufReader, spa
 	}
 
 	// Main loop:
-	// 1. Listen for content in the ring buffer
+	// 1. Listen for content in the ring buffer, and read all the available events on each wakeup
 	// 2. Decode binary data into HTTPRequestTrace instance
 	// 3. Accumulate the HTTPRequestTrace into a batch slice
 	// 4. When the length of the batch slice reaches cfg.BatchLength,
@@ -147,33 +165,147 @@ func (rbf *ringBufForwarder) readAndForwardInner(eventsReader ringBufReader, spa
 	// Logging each message adds few information and a lot of noise to the debug logs
 	// in production systems with thousands of messages per second
 	rbf.logger.Debug("starting to read ring buffer")
-	record, err := eventsReader.Read()
+	if rbf.cfg.RingbufWatchdogTimeout > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go rbf.bgWatchdog(eventsReader, done)
+	}
+	// the records are reused between batches, to avoid allocating memory for each event
+	records := make([]ringbuf.Record, rbf.cfg.BatchLength)
 	for {
+		n, err := rbf.readBatch(eventsReader, records)
+		if n > 0 {
+			if rbf.flushing.Load() {
+				rbf.flushedEvents += n
+			}
+			rbf.processAndForward(records[:n], spansChan)
+		}
 		if err != nil {
-			if errors.Is(err, ringbuf.ErrClosed) {
+			switch {
+			case errors.Is(err, ringbuf.ErrClosed):
 				rbf.logger.Debug("ring buffer is closed")
 				return
+			case errors.Is(err, ringbuf.ErrFlushed):
+				rbf.checkFlushedEvents()
+			case errors.Is(err, os.ErrDeadlineExceeded):
+				// the poll timeout expired after reading all the pending events
+			default:
+				rbf.logger.Error("error reading from perf reader", "error", err)
 			}
-			rbf.logger.Error("error reading from perf reader", "error", err)
-			continue
 		}
-		rbf.processAndForward(record, spansChan)
+	}
+}
+
+// readBatch blocks until there are events in the ring buffer, and then reads all the events
+// that are already available, up to the length of the records slice, without waiting for
+// further wakeups. It returns the number of read records.
+func (rbf *ringBufForwarder) readBatch(eventsReader ringBufReader, records []ringbuf.Record) (int, error) {
+	if rbf.cfg.RingbufPollTimeout > 0 {
+		eventsReader.SetDeadline(time.Now().Add(rbf.cfg.RingbufPollTimeout))
+	}
+	if err := rbf.read(eventsReader, &records[0]); err != nil {
+		return 0, err
+	}
+	n := 1
+	for n < len(records) && records[n-1].Remaining > 0 {
+		if err := eventsReader.ReadInto(&records[n]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (rbf *ringBufForwarder) read(eventsReader ringBufReader, record *ringbuf.Record) error {
+	rbf.lastRead.Store(time.Now().UnixNano())
+	rbf.reading.Store(true)
+	err := eventsReader.ReadInto(record)
+	rbf.reading.Store(false)
+	if err == nil {
+		rbf.lastEvent.Store(time.Now().UnixNano())
+	}
+	return err
+}
+
+// bgWatchdog periodically checks that the reader keeps making progress. If the reader has been
+// waiting for events longer than the watchdog timeout, it flushes the reader, forcing it to read
+// any event that might be pending in the ring buffer.
+func (rbf *ringBufForwarder) bgWatchdog(eventsReader ringBufReader, done <-chan struct{}) {
+	timeout := rbf.cfg.RingbufWatchdogTimeout
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			rbf.checkProgress(eventsReader, timeout)
+		}
+	}
+}
+
+func (rbf *ringBufForwarder) checkProgress(eventsReader ringBufReader, timeout time.Duration) {
+	now := time.Now()
+	if !rbf.reading.Load() {
+		// the reader is processing an event. If it takes too long, it is
+		// usually because the next pipeline stages are not consuming the spans
+		if lastEvent := time.Unix(0, rbf.lastEvent.Load()); now.Sub(lastEvent) > timeout {
+			rbf.logger.Warn("ring buffer reader is blocked forwarding events to the next pipeline stage",
+				"since", lastEvent)
+		}
+		return
+	}
+	if now.Sub(time.Unix(0, rbf.lastRead.Load())) <= timeout || rbf.flushing.Load() {
+		return
+	}
+	rbf.flushing.Store(true)
+	if err := eventsReader.Flush(); err != nil {
+		rbf.flushing.Store(false)
+		rbf.logger.Debug("can't flush ring buffer reader", "error", err)
+	}
+}
 
-		// read another event before the next loop iteration
-		record, err = eventsReader.Read()
+// checkFlushedEvents is invoked after the reader is flushed by the watchdog. If the reader
+// received pending events that didn't wake it up, it reports a stalled reader.
+func (rbf *ringBufForwarder) checkFlushedEvents() {
+	if !rbf.flushing.Swap(false) {
+		return
 	}
+	flushed := rbf.flushedEvents
+	rbf.flushedEvents = 0
+	if flushed == 0 {
+		return
+	}
+	// when the wakeup length is larger than 1, the eBPF side doesn't wake up the
+	// reader for each event, so having pending events is expected
+	if rbf.cfg.WakeupLen > 1 {
+		rbf.logger.Debug("watchdog flushed pending ring buffer events", "len", flushed)
+		return
+	}
+	rbf.logger.Warn("ring buffer reader was stalled with pending events. Flushed it",
+		"pending", flushed, "lastEvent", time.Unix(0, rbf.lastEvent.Load()))
+	rbf.metrics.RingbufReaderStall()
 }
 
 func (rbf *ringBufForwarder) alreadyForwarded(ctx context.Context, _ []io.Closer, _ chan<- []request.Span) {
 	<-ctx.Done()
 }
 
-func (rbf *ringBufForwarder) processAndForward(record ringbuf.Record, spansChan chan<- []request.Span) {
+// processAndForward decodes a batch of records, accumulating the resulting spans and
+// forwarding them when the spans batch is full
+func (rbf *ringBufForwarder) processAndForward(records []ringbuf.Record, spansChan chan<- []request.Span) {
 	rbf.access.Lock()
 	defer rbf.access.Unlock()
-	s, ignore, err := rbf.reader(&record, rbf.filter)
+	for i := range records {
+		rbf.processRecord(&records[i], spansChan)
+	}
+}
+
+func (rbf *ringBufForwarder) processRecord(record *ringbuf.Record, spansChan chan<- []request.Span) {
+	s, ignore, err := rbf.reader(record, rbf.filter)
 	if err != nil {
 		rbf.logger.Error("error parsing perf event", "error", err)
+		rbf.metrics.SpansDropped(imetrics.DropReasonDecodeError, 1, nil)
 		return
 	}
 	if ignore {
@@ -181,6 +313,7 @@ func (rbf *ringBufForwarder) processAndForward(record ringbuf.Record, spansChan
 	}
 	if !s.IsValid() {
 		rbf.logger.Debug("invalid span", "span", s)
+		rbf.metrics.SpansDropped(imetrics.DropReasonInvalid, 1, s.Summary())
 		return
 	}
 	rbf.spans[rbf.spansLen] = s
@@ -198,11 +331,18 @@ func (rbf *ringBufForwarder) processAndForward(record ringbuf.Record, spansChan
 
 func (rbf *ringBufForwarder) flushEvents(spansChan chan<- []request.Span) {
 	rbf.metrics.TracerFlush(rbf.spansLen)
+	rbf.reportHealthChecks()
 	spansChan <- rbf.filter.Filter(rbf.spans[:rbf.spansLen])
 	rbf.spans = make([]request.Span, rbf.cfg.BatchLength)
 	rbf.spansLen = 0
 }
 
+func (rbf *ringBufForwarder) reportHealthChecks() {
+	if rbf.parser != nil {
+		rbf.parser.healthChecks.report(rbf.metrics, rbf.filter)
+	}
+}
+
 func (rbf *ringBufForwarder) bgFlushOnTimeout(spansChan chan<- []request.Span) {
 	for {
 		<-rbf.ticker.C
@@ -210,6 +350,9 @@ func (rbf *ringBufForwarder) bgFlushOnTimeout(spansChan chan<- []request.Span) {
 		if rbf.spansLen > 0 {
 			rbf.logger.Debug("submitting traces on timeout", "len", rbf.spansLen)
 			rbf.flushEvents(spansChan)
+		} else {
+			// the health checks are reported even if all the requests were health checks
+			rbf.reportHealthChecks()
 		}
 		rbf.access.Unlock()
 	}
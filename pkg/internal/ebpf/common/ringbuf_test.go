@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/binary"
 	"log/slog"
+	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -37,7 +38,7 @@ func TestForwardRingbuf_CapacityFull(t *testing.T) {
 		&config.EPPFTracer{BatchLength: 10},
 		nil, // the source ring buffer can be null
 		&fltr,
-		ReadBPFTraceAsSpan,
+		testParser().ReadBPFTraceAsSpan,
 		slog.With("test", "TestForwardRingbuf_CapacityFull"),
 		metrics,
 		nil,
@@ -89,7 +90,7 @@ func TestForwardRingbuf_Deadline(t *testing.T) {
 		&config.EPPFTracer{BatchLength: 10, BatchTimeout: 20 * time.Millisecond},
 		nil,   // the source ring buffer can be null
 		&fltr, // change fltr to a pointer
-		ReadBPFTraceAsSpan,
+		testParser().ReadBPFTraceAsSpan,
 		slog.With("test", "TestForwardRingbuf_Deadline"),
 		metrics,
 	)(context.Background(), forwardedMessages)
@@ -129,7 +130,7 @@ func TestForwardRingbuf_Close(t *testing.T) {
 		&config.EPPFTracer{BatchLength: 10},
 		nil, // the source ring buffer can be null
 		(&IdentityPidsFilter{}),
-		ReadBPFTraceAsSpan,
+		testParser().ReadBPFTraceAsSpan,
 		slog.With("test", "TestForwardRingbuf_Close"),
 		metrics,
 		&closable,
@@ -152,10 +153,100 @@ func TestForwardRingbuf_Close(t *testing.T) {
 	assert.Equal(t, 0, metrics.flushedLen)
 }
 
+func TestForwardRingbuf_Watchdog(t *testing.T) {
+	// GIVEN a ring buffer forwarder with a watchdog
+	ringBuf, restore := replaceTestRingBuf()
+	defer restore()
+	metrics := &metricsReporter{}
+	forwardedMessages := make(chan []request.Span, 100)
+	go ForwardRingbuf(
+		&config.EPPFTracer{BatchLength: 1, RingbufWatchdogTimeout: 20 * time.Millisecond},
+		nil, // the source ring buffer can be null
+		&IdentityPidsFilter{},
+		testParser().ReadBPFTraceAsSpan,
+		slog.With("test", "TestForwardRingbuf_Watchdog"),
+		metrics,
+	)(context.Background(), forwardedMessages)
+
+	// WHEN there are pending events that don't wake up the reader
+	ringBuf.stalled <- HTTPRequestTrace{Type: 1, ContentLength: 123}
+
+	// THEN the watchdog flushes the reader and the events are forwarded
+	batch := testutil.ReadChannel(t, forwardedMessages, testTimeout)
+	require.Len(t, batch, 1)
+	assert.Equal(t, int64(123), batch[0].ContentLength)
+
+	// AND the stall is reported
+	test.Eventually(t, testTimeout, func(t require.TestingT) {
+		assert.Equal(t, int32(1), metrics.stalls.Load())
+	})
+
+	// AND the reader keeps reading the events after the flush
+	ringBuf.events <- HTTPRequestTrace{Type: 1, ContentLength: 456}
+	batch = testutil.ReadChannel(t, forwardedMessages, testTimeout)
+	require.Len(t, batch, 1)
+	assert.Equal(t, int64(456), batch[0].ContentLength)
+	assert.Equal(t, int32(1), metrics.stalls.Load())
+}
+
+func TestForwardRingbuf_PollTimeout(t *testing.T) {
+	// GIVEN a ring buffer forwarder with a poll timeout
+	ringBuf, restore := replaceTestRingBuf()
+	defer restore()
+	forwardedMessages := make(chan []request.Span, 100)
+	go ForwardRingbuf(
+		&config.EPPFTracer{BatchLength: 1, RingbufPollTimeout: 5 * time.Millisecond},
+		nil, // the source ring buffer can be null
+		&IdentityPidsFilter{},
+		testParser().ReadBPFTraceAsSpan,
+		slog.With("test", "TestForwardRingbuf_PollTimeout"),
+		&metricsReporter{},
+	)(context.Background(), forwardedMessages)
+
+	// WHEN the reader doesn't receive any event during the poll timeout
+	test.Eventually(t, testTimeout, func(t require.TestingT) {
+		assert.GreaterOrEqual(t, ringBuf.deadlines.Load(), int32(2))
+	})
+
+	// THEN it keeps reading the events after the timeout
+	ringBuf.events <- HTTPRequestTrace{Type: 1, ContentLength: 123}
+	batch := testutil.ReadChannel(t, forwardedMessages, testTimeout)
+	require.Len(t, batch, 1)
+	assert.Equal(t, int64(123), batch[0].ContentLength)
+}
+
+func TestReadBatch(t *testing.T) {
+	// GIVEN a ring buffer with pending events
+	ringBuf := fakeRingBufReader{events: make(chan HTTPRequestTrace, 10)}
+	for i := 0; i < 5; i++ {
+		ringBuf.events <- HTTPRequestTrace{Type: 1, ContentLength: int64(i)}
+	}
+	rbf := ringBufForwarder{cfg: &config.EPPFTracer{RingbufPollTimeout: 5 * time.Millisecond}}
+	records := make([]ringbuf.Record, 3)
+
+	// THEN all the available events are read at once, up to the length of the records slice
+	n, err := rbf.readBatch(&ringBuf, records)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = rbf.readBatch(&ringBuf, records)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// AND the reader stops waiting after the poll timeout
+	n, err = rbf.readBatch(&ringBuf, records)
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Zero(t, n)
+}
+
 // replaces the original ring buffer factory by a fake ring buffer creator and returns it,
 // along with a function to invoke deferred to restore the real ring buffer factory
 func replaceTestRingBuf() (ringBuf *fakeRingBufReader, restorer func()) {
-	rb := fakeRingBufReader{events: make(chan HTTPRequestTrace, 100), closeCh: make(chan struct{})}
+	rb := fakeRingBufReader{
+		events:  make(chan HTTPRequestTrace, 100),
+		stalled: make(chan HTTPRequestTrace, 100),
+		flushCh: make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
 	// required to silence some data race warnings in tests
 	mt := sync.Mutex{}
 	mt.Lock()
@@ -172,9 +263,24 @@ func replaceTestRingBuf() (ringBuf *fakeRingBufReader, restorer func()) {
 }
 
 type fakeRingBufReader struct {
-	events        chan HTTPRequestTrace
+	events chan HTTPRequestTrace
+	// stalled events are only read after the reader is flushed
+	stalled       chan HTTPRequestTrace
+	flushCh       chan struct{}
+	flushing      bool
 	closeCh       chan struct{}
 	explicitClose atomic.Bool
+	deadline      time.Time
+	deadlines     atomic.Int32
+}
+
+func (f *fakeRingBufReader) SetDeadline(t time.Time) {
+	f.deadline = t
+}
+
+func (f *fakeRingBufReader) Flush() error {
+	f.flushCh <- struct{}{}
+	return nil
 }
 
 func (f *fakeRingBufReader) Close() error {
@@ -183,19 +289,44 @@ func (f *fakeRingBufReader) Close() error {
 	return nil
 }
 
-func (f *fakeRingBufReader) Read() (ringbuf.Record, error) {
+func (f *fakeRingBufReader) ReadInto(rec *ringbuf.Record) error {
+	if f.flushing {
+		select {
+		case traceEvent := <-f.stalled:
+			return asRecord(traceEvent, rec, len(f.stalled))
+		default:
+			f.flushing = false
+			return ringbuf.ErrFlushed
+		}
+	}
+	var timeout <-chan time.Time
+	if !f.deadline.IsZero() {
+		timeout = time.After(time.Until(f.deadline))
+	}
 	select {
 	case traceEvent := <-f.events:
-		binaryRecord := bytes.Buffer{}
-		if err := binary.Write(&binaryRecord, binary.LittleEndian, traceEvent); err != nil {
-			return ringbuf.Record{}, err
-		}
-		return ringbuf.Record{RawSample: binaryRecord.Bytes()}, nil
+		return asRecord(traceEvent, rec, len(f.events))
+	case <-f.flushCh:
+		f.flushing = true
+		return f.ReadInto(rec)
 	case <-f.closeCh:
-		return ringbuf.Record{}, ringbuf.ErrClosed
+		return ringbuf.ErrClosed
+	case <-timeout:
+		f.deadlines.Add(1)
+		return os.ErrDeadlineExceeded
 	}
 }
 
+func asRecord(traceEvent HTTPRequestTrace, rec *ringbuf.Record, remaining int) error {
+	binaryRecord := bytes.Buffer{}
+	if err := binary.Write(&binaryRecord, binary.LittleEndian, traceEvent); err != nil {
+		return err
+	}
+	rec.RawSample = binaryRecord.Bytes()
+	rec.Remaining = remaining
+	return nil
+}
+
 type closableObject struct {
 	closed bool
 }
@@ -209,6 +340,11 @@ type metricsReporter struct {
 	imetrics.NoopReporter
 	flushes    int
 	flushedLen int
+	stalls     atomic.Int32
+}
+
+func (m *metricsReporter) RingbufReaderStall() {
+	m.stalls.Add(1)
 }
 
 func (m *metricsReporter) TracerFlush(len int) {
@@ -0,0 +1,231 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+	"unsafe"
+
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// RSocket frames over TCP are prefixed by a 3 bytes frame length, followed by the 4 bytes
+// stream ID and 2 bytes containing the frame type (6 bits) and flags (10 bits)
+const (
+	rsocketLengthLen     = 3
+	rsocketHeaderLen     = 6
+	rsocketMetadataLen   = 3
+	rsocketFlagMetadata  = 0x100
+	rsocketStreamIDMask  = 0x7fffffff
+	rsocketFrameTypeBits = 10
+)
+
+// RSocket frame types
+const (
+	rsocketRequestResponse = 0x04
+	rsocketRequestStream   = 0x06
+	rsocketPayload         = 0x0A
+	rsocketError           = 0x0B
+)
+
+// RSocket error codes of the ERROR frames that are sent in a stream
+const (
+	rsocketRejected = 0x00000202
+	rsocketCanceled = 0x00000203
+	rsocketInvalid  = 0x00000204
+)
+
+const (
+	// well-known MIME type ID of message/x.rsocket.routing.v0 in the composite metadata
+	rsocketRoutingMimeID = 0x7E
+	rsocketRoutingMime   = "message/x.rsocket.routing.v0"
+)
+
+type rsocketFrame struct {
+	streamID  uint32
+	frameType uint8
+	flags     uint16
+	// frame contents after the header
+	body []byte
+}
+
+// parseRSocketFrame parses the header of an RSocket frame over TCP. Since the captured buffer
+// can be truncated, the frame body might be incomplete.
+func parseRSocketFrame(buf []byte) (*rsocketFrame, int, bool) {
+	if len(buf) < rsocketLengthLen+rsocketHeaderLen {
+		return nil, 0, false
+	}
+	frameLen := int(buf[0])<<16 | int(buf[1])<<8 | int(buf[2])
+	if frameLen < rsocketHeaderLen {
+		return nil, 0, false
+	}
+	streamID := binary.BigEndian.Uint32(buf[rsocketLengthLen:])
+	typeAndFlags := binary.BigEndian.Uint16(buf[rsocketLengthLen+4:])
+	if streamID&^rsocketStreamIDMask != 0 {
+		return nil, 0, false
+	}
+	return &rsocketFrame{
+		streamID:  streamID,
+		frameType: uint8(typeAndFlags >> rsocketFrameTypeBits),
+		flags:     typeAndFlags & (1<<rsocketFrameTypeBits - 1),
+		body:      buf[rsocketLengthLen+rsocketHeaderLen:],
+	}, frameLen + rsocketLengthLen, true
+}
+
+// parseRSocketRequest returns the REQUEST_RESPONSE or REQUEST_STREAM frame that is sent in the
+// passed buffer, as well as its route. The route is extracted from the routing metadata, as sent
+// by Spring Messaging and other RSocket frameworks.
+func parseRSocketRequest(buf []byte, totalLen int64) (*rsocketFrame, string, bool) {
+	f, size, ok := parseRSocketFrame(buf)
+	// requests are sent in their own frame, so the frame length must match the sent bytes
+	if !ok || int64(size) != totalLen || f.streamID == 0 || f.flags&rsocketFlagMetadata == 0 {
+		return nil, "", false
+	}
+	body := f.body
+	switch f.frameType {
+	case rsocketRequestResponse:
+	case rsocketRequestStream:
+		// initial request N
+		if len(body) < 4 {
+			return nil, "", false
+		}
+		body = body[4:]
+	default:
+		return nil, "", false
+	}
+	if len(body) < rsocketMetadataLen {
+		return nil, "", false
+	}
+	metadataLen := int(body[0])<<16 | int(body[1])<<8 | int(body[2])
+	metadata := body[rsocketMetadataLen:]
+	if len(metadata) > metadataLen {
+		metadata = metadata[:metadataLen]
+	}
+	route, ok := rsocketCompositeRoute(metadata)
+	if !ok {
+		// the connection might have been set up with routing as its metadata MIME type
+		route, ok = rsocketRoute(metadata)
+	}
+	if !ok {
+		return nil, "", false
+	}
+	return f, route, true
+}
+
+// rsocketCompositeRoute looks for the routing entry in composite metadata
+func rsocketCompositeRoute(metadata []byte) (string, bool) {
+	for len(metadata) > 0 {
+		// the MIME type is either a well-known ID, if the most significant bit is set,
+		// or a string whose length minus one is specified in the first byte
+		id := metadata[0]
+		metadata = metadata[1:]
+		isRouting := id == 0x80|rsocketRoutingMimeID
+		if id&0x80 == 0 {
+			mimeLen := int(id) + 1
+			if len(metadata) < mimeLen {
+				return "", false
+			}
+			isRouting = string(metadata[:mimeLen]) == rsocketRoutingMime
+			metadata = metadata[mimeLen:]
+		}
+		if len(metadata) < rsocketMetadataLen {
+			return "", false
+		}
+		entryLen := int(metadata[0])<<16 | int(metadata[1])<<8 | int(metadata[2])
+		metadata = metadata[rsocketMetadataLen:]
+		if isRouting {
+			if len(metadata) > entryLen {
+				metadata = metadata[:entryLen]
+			}
+			return rsocketRoute(metadata)
+		}
+		if len(metadata) < entryLen {
+			return "", false
+		}
+		metadata = metadata[entryLen:]
+	}
+	return "", false
+}
+
+// rsocketRoute returns the first tag of the routing metadata, which is the route
+func rsocketRoute(routing []byte) (string, bool) {
+	if len(routing) < 2 {
+		return "", false
+	}
+	tagLen := int(routing[0])
+	if tagLen == 0 || len(routing) < tagLen+1 {
+		return "", false
+	}
+	route := routing[1 : tagLen+1]
+	for _, c := range route {
+		if c < 0x20 || c > 0x7e {
+			return "", false
+		}
+	}
+	return string(route), true
+}
+
+// isRSocketResponse returns whether the buffer contains a response frame for the request stream
+func isRSocketResponse(buf []byte, streamID uint32) bool {
+	f, _, ok := parseRSocketFrame(buf)
+	return ok && f.streamID == streamID && (f.frameType == rsocketPayload || f.frameType == rsocketError)
+}
+
+// rsocketStatusToGRPC maps the response of an RSocket request to a gRPC status code,
+// so RSocket spans can be handled as any other RPC span.
+func rsocketStatusToGRPC(resp []byte, streamID uint32) int {
+	f, _, ok := parseRSocketFrame(resp)
+	if !ok || f.streamID != streamID || f.frameType != rsocketError || len(f.body) < 4 {
+		return grpcStatusOK
+	}
+	switch binary.BigEndian.Uint32(f.body) {
+	case rsocketRejected:
+		return grpcStatusUnavailable
+	case rsocketCanceled:
+		return grpcStatusCanceled
+	case rsocketInvalid:
+		return grpcStatusInvalidArgument
+	}
+	// application errors and connection errors
+	return grpcStatusUnknown
+}
+
+func TCPToRSocketToSpan(trace *TCPRequestInfo, route string, status int) request.Span {
+	peer := ""
+	hostname := ""
+	hostPort := 0
+
+	if trace.ConnInfo.S_port != 0 || trace.ConnInfo.D_port != 0 {
+		peer, hostname = (*BPFConnInfo)(unsafe.Pointer(&trace.ConnInfo)).reqHostInfo()
+		hostPort = int(trace.ConnInfo.D_port)
+	}
+
+	reqType := request.EventTypeGRPCClient
+	if trace.Direction == 0 {
+		reqType = request.EventTypeGRPC
+	}
+
+	return request.Span{
+		Type:          reqType,
+		SubType:       request.RPCSubtypeRSocket,
+		Path:          route,
+		Peer:          peer,
+		PeerPort:      int(trace.ConnInfo.S_port),
+		Host:          hostname,
+		HostPort:      hostPort,
+		ContentLength: int64(trace.Len),
+		RequestStart:  int64(trace.StartMonotimeNs),
+		Start:         int64(trace.StartMonotimeNs),
+		End:           int64(trace.EndMonotimeNs),
+		Status:        status,
+		TraceID:       trace2.TraceID(trace.Tp.TraceId),
+		SpanID:        trace2.SpanID(trace.Tp.SpanId),
+		ParentSpanID:  trace2.SpanID(trace.Tp.ParentId),
+		Flags:         trace.Tp.Flags,
+		Pid: request.PidInfo{
+			HostPID:   trace.Pid.HostPid,
+			UserPID:   trace.Pid.UserPid,
+			Namespace: trace.Pid.Ns,
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func uint24(l int) []byte {
+	return []byte{byte(l >> 16), byte(l >> 8), byte(l)}
+}
+
+func rsocketFrameBytes(streamID uint32, frameType uint8, flags uint16, body []byte) []byte {
+	frame := binary.BigEndian.AppendUint32(nil, streamID)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(frameType)<<rsocketFrameTypeBits|flags)
+	frame = append(frame, body...)
+	return append(uint24(len(frame)), frame...)
+}
+
+// rsocketRoutingMetadata returns the composite metadata with a routing entry,
+// as sent by Spring Messaging
+func rsocketRoutingMetadata(route string) []byte {
+	routing := append([]byte{byte(len(route))}, route...)
+	entry := append([]byte{0x80 | rsocketRoutingMimeID}, uint24(len(routing))...)
+	return append(entry, routing...)
+}
+
+func rsocketRequestBytes(streamID uint32, frameType uint8, metadata []byte, data string) []byte {
+	body := []byte{}
+	if frameType == rsocketRequestStream {
+		body = binary.BigEndian.AppendUint32(body, 256)
+	}
+	body = append(body, uint24(len(metadata))...)
+	body = append(body, metadata...)
+	body = append(body, data...)
+	return rsocketFrameBytes(streamID, frameType, rsocketFlagMetadata, body)
+}
+
+func TestParseRSocketRequest(t *testing.T) {
+	mimeMetadata := append([]byte{byte(len("application/json") - 1)}, "application/json"...)
+	mimeMetadata = append(mimeMetadata, uint24(2)...)
+	mimeMetadata = append(mimeMetadata, "{}"...)
+	mimeRouting := append([]byte{byte(len(rsocketRoutingMime) - 1)}, rsocketRoutingMime...)
+	mimeRouting = append(mimeRouting, uint24(13)...)
+	mimeRouting = append(mimeRouting, 12)
+	mimeRouting = append(mimeRouting, "greet.stream"...)
+
+	for _, tc := range []struct {
+		name     string
+		buf      []byte
+		route    string
+		streamID uint32
+	}{
+		{
+			name:     "request-response",
+			buf:      rsocketRequestBytes(1, rsocketRequestResponse, rsocketRoutingMetadata("greeting"), `{"name":"Beyla"}`),
+			route:    "greeting",
+			streamID: 1,
+		},
+		{
+			name:     "request-stream",
+			buf:      rsocketRequestBytes(3, rsocketRequestStream, rsocketRoutingMetadata("prices.{symbol}"), `GRAF`),
+			route:    "prices.{symbol}",
+			streamID: 3,
+		},
+		{
+			name:     "routing after other composite entries",
+			buf:      rsocketRequestBytes(5, rsocketRequestResponse, append(mimeMetadata, mimeRouting...), ""),
+			route:    "greet.stream",
+			streamID: 5,
+		},
+		{
+			name:     "routing metadata",
+			buf:      rsocketRequestBytes(7, rsocketRequestResponse, append([]byte{5}, "hello"...), ""),
+			route:    "hello",
+			streamID: 7,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, route, ok := parseRSocketRequest(tc.buf, int64(len(tc.buf)))
+			require.True(t, ok)
+			assert.Equal(t, tc.route, route)
+			assert.Equal(t, tc.streamID, f.streamID)
+		})
+	}
+}
+
+func TestParseRSocketRequest_Invalid(t *testing.T) {
+	valid := rsocketRequestBytes(1, rsocketRequestResponse, rsocketRoutingMetadata("greeting"), "hello")
+	for _, tc := range []struct {
+		name  string
+		buf   []byte
+		total int64
+	}{
+		{name: "length mismatch", buf: valid, total: int64(len(valid)) + 10},
+		{name: "connection stream", buf: rsocketRequestBytes(0, rsocketRequestResponse, rsocketRoutingMetadata("greeting"), ""), total: int64(len(valid)) - 5},
+		{name: "payload frame", buf: rsocketRequestBytes(1, rsocketPayload, rsocketRoutingMetadata("greeting"), "hello"), total: int64(len(valid))},
+		{name: "no metadata", buf: rsocketFrameBytes(1, rsocketRequestResponse, 0, []byte("hello")), total: 14},
+		{name: "binary route", buf: rsocketRequestBytes(1, rsocketRequestResponse, []byte{2, 0x01, 0x02}, ""), total: 15},
+		{name: "redis", buf: []byte("*1\r\n$4\r\nPING\r\n"), total: 14},
+		{name: "too short", buf: valid[:5], total: 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, ok := parseRSocketRequest(tc.buf, tc.total)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestRSocketStatusToGRPC(t *testing.T) {
+	rsocketErr := func(streamID, code uint32) []byte {
+		return rsocketFrameBytes(streamID, rsocketError, 0, append(binary.BigEndian.AppendUint32(nil, code), "error"...))
+	}
+	assert.Equal(t, grpcStatusOK, rsocketStatusToGRPC(rsocketFrameBytes(1, rsocketPayload, 0x60, []byte("hi")), 1))
+	assert.Equal(t, grpcStatusUnavailable, rsocketStatusToGRPC(rsocketErr(1, rsocketRejected), 1))
+	assert.Equal(t, grpcStatusCanceled, rsocketStatusToGRPC(rsocketErr(1, rsocketCanceled), 1))
+	assert.Equal(t, grpcStatusInvalidArgument, rsocketStatusToGRPC(rsocketErr(1, rsocketInvalid), 1))
+	assert.Equal(t, grpcStatusUnknown, rsocketStatusToGRPC(rsocketErr(1, 0x201), 1))
+	// error from another stream
+	assert.Equal(t, grpcStatusOK, rsocketStatusToGRPC(rsocketErr(3, rsocketRejected), 1))
+}
+
+func TestTCPToRSocketToSpan(t *testing.T) {
+	req := rsocketRequestBytes(1, rsocketRequestResponse, rsocketRoutingMetadata("greeting"), `{"name":"Beyla"}`)
+	resp := rsocketFrameBytes(1, rsocketError, 0, append(binary.BigEndian.AppendUint32(nil, rsocketInvalid), "invalid"...))
+	for _, tc := range []struct {
+		name      string
+		direction int
+		reversed  bool
+		spanType  request.EventType
+	}{
+		{name: "server", direction: 0, spanType: request.EventTypeGRPC},
+		{name: "client", direction: 1, spanType: request.EventTypeGRPCClient},
+		{name: "reversed", direction: 1, reversed: true, spanType: request.EventTypeGRPC},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trace := makeTCPReq(string(req), tc.direction, 40000, 7000, 5)
+			copy(trace.Rbuf[:], resp)
+			trace.RespLen = uint32(len(resp))
+			if tc.reversed {
+				trace = makeTCPReq(string(resp), tc.direction, 7000, 40000, 5)
+				copy(trace.Rbuf[:], req)
+				trace.RespLen = uint32(len(req))
+			}
+			binaryRecord := bytes.Buffer{}
+			require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+			span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+			require.NoError(t, err)
+			require.False(t, ignore)
+			assert.Equal(t, tc.spanType, span.Type)
+			assert.Equal(t, request.RPCSubtypeRSocket, span.SubType)
+			assert.Equal(t, "greeting", span.Path)
+			assert.Equal(t, "greeting", span.TraceName())
+			assert.Equal(t, grpcStatusInvalidArgument, span.Status)
+			assert.Equal(t, 7000, span.HostPort)
+			assert.Equal(t, "rsocket", span.RPCSystemName())
+
+			service, method := span.RPCServiceAndMethod()
+			assert.Empty(t, service)
+			assert.Equal(t, "greeting", method)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// parseSOAPRequest checks whether an HTTP request carries a SOAP message. In that case, it
+// decorates the span with the SOAP action, which is taken from the SOAPAction header (SOAP 1.1),
+// the action parameter of the Content-Type header (SOAP 1.2) or, if none of them is present,
+// the name of the first element of the SOAP body.
+func parseSOAPRequest(span *request.Span, buf []byte) {
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	if span.SubType != request.HTTPSubtypeNone {
+		return
+	}
+	if end := bytes.IndexByte(buf, 0); end >= 0 {
+		buf = buf[:end]
+	}
+	headers := buf
+	var body []byte
+	if idx := bytes.Index(buf, []byte("\r\n\r\n")); idx >= 0 {
+		headers, body = buf[:idx], buf[idx+4:]
+	}
+	contentType := httpHeader(headers, "content-type")
+	mediaType, params, _ := strings.Cut(contentType, ";")
+	var action string
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case "text/xml":
+		action = httpHeader(headers, "soapaction")
+	case "application/soap+xml":
+		for _, param := range strings.Split(params, ";") {
+			if name, value, ok := strings.Cut(param, "="); ok && strings.EqualFold(strings.TrimSpace(name), "action") {
+				action = value
+			}
+		}
+	default:
+		return
+	}
+	action = strings.Trim(strings.TrimSpace(action), `"`)
+	if action == "" {
+		action = soapBodyFirstElement(body)
+	}
+	if action == "" {
+		return
+	}
+	span.SubType = request.HTTPSubtypeSOAP
+	span.SOAPAction = action
+}
+
+// httpHeader returns the value of the header with the given case-insensitive name
+func httpHeader(headers []byte, name string) string {
+	lines := bytes.Split(headers, []byte("\r\n"))
+	// ignoring the request line
+	for _, line := range lines[1:] {
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if ok && strings.EqualFold(string(bytes.TrimSpace(key)), name) {
+			return string(bytes.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// soapBodyFirstElement returns the local name of the first child element of the SOAP Body,
+// or an empty string if it can't be found in the (likely truncated) captured body.
+func soapBodyFirstElement(body []byte) string {
+	idx := bytes.Index(body, []byte(":Body"))
+	if idx < 0 {
+		if idx = bytes.Index(body, []byte("<Body")); idx < 0 {
+			return ""
+		}
+	}
+	body = body[idx:]
+	// skip the Body element tag and look for the next opening element
+	for {
+		start := bytes.IndexByte(body, '>')
+		if start < 0 {
+			return ""
+		}
+		body = body[start+1:]
+		start = bytes.IndexByte(body, '<')
+		if start < 0 || start+1 >= len(body) {
+			return ""
+		}
+		body = body[start+1:]
+		// ignore closing tags, comments and processing instructions
+		if body[0] == '/' || body[0] == '!' || body[0] == '?' {
+			continue
+		}
+		end := bytes.IndexAny(body, " \t\r\n/>")
+		if end < 0 {
+			// the element name is truncated
+			return ""
+		}
+		name := string(body[:end])
+		if colon := strings.IndexByte(name, ':'); colon >= 0 {
+			name = name[colon+1:]
+		}
+		return name
+	}
+}
@@ -0,0 +1,71 @@
+package ebpfcommon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestParseSOAPRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		buf     string
+		subType int
+		action  string
+	}{
+		{
+			name: "SOAP 1.1 action header",
+			buf: "POST /ws HTTP/1.1\r\nHost: quotes\r\nContent-Type: text/xml; charset=utf-8\r\n" +
+				"SOAPAction: \"http://example.com/StockQuote/GetQuote\"\r\n\r\n<?xml version=\"1.0\"?>",
+			subType: request.HTTPSubtypeSOAP,
+			action:  "http://example.com/StockQuote/GetQuote",
+		},
+		{
+			name: "SOAP 1.2 action parameter",
+			buf: "POST /ws HTTP/1.1\r\ncontent-type: application/soap+xml; charset=utf-8; action=\"urn:GetQuote\"\r\n" +
+				"\r\n<?xml version=\"1.0\"?>",
+			subType: request.HTTPSubtypeSOAP,
+			action:  "urn:GetQuote",
+		},
+		{
+			name: "first body element",
+			buf: "POST /ws HTTP/1.1\r\nContent-Type: text/xml\r\nSOAPAction: \"\"\r\n\r\n" +
+				"<soap:Envelope xmlns:soap=\"http://www.w3.org/2003/05/soap-envelope\"><soap:Body>\n" +
+				"  <!-- comment --><m:GetPrice xmlns:m=\"https://www.w3schools.com/prices\">",
+			subType: request.HTTPSubtypeSOAP,
+			action:  "GetPrice",
+		},
+		{
+			name:    "truncated body",
+			buf:     "POST /ws HTTP/1.1\r\nContent-Type: text/xml\r\n\r\n<soap:Envelope><soap:Body><m:GetPr",
+			subType: request.HTTPSubtypeNone,
+		},
+		{
+			name:    "not SOAP",
+			buf:     "POST /api HTTP/1.1\r\nContent-Type: application/json\r\nSOAPAction: foo\r\n\r\n{}",
+			subType: request.HTTPSubtypeNone,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			span := request.Span{Type: request.EventTypeHTTP, Method: "POST", Path: "/ws"}
+			parseSOAPRequest(&span, []byte(tc.buf))
+			assert.Equal(t, tc.subType, span.SubType)
+			assert.Equal(t, tc.action, span.SOAPAction)
+		})
+	}
+}
+
+func TestSOAPSpanName(t *testing.T) {
+	event := makeBPFInfoWithBuf([]byte("POST /ws HTTP/1.1\r\nContent-Type: text/xml\r\n" +
+		"SOAPAction: \"http://example.com/StockQuote/GetQuote\"\r\n\r\n"))
+	event.Type = uint8(request.EventTypeHTTP)
+	span, ignore, err := testParser().HTTPInfoEventToSpan(event)
+	assert.NoError(t, err)
+	assert.False(t, ignore)
+	assert.Equal(t, "GetQuote", span.TraceName())
+
+	span.SOAPAction = "urn:GetQuote"
+	assert.Equal(t, "GetQuote", span.TraceName())
+}
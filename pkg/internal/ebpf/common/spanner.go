@@ -36,7 +36,7 @@ func HTTPRequestTraceToSpan(trace *HTTPRequestTrace) request.Span {
 		hostPort = int(trace.Conn.D_port)
 	}
 
-	return request.Span{
+	span := request.Span{
 		Type:          request.EventType(trace.Type),
 		Method:        method,
 		Path:          path,
@@ -59,6 +59,9 @@ func HTTPRequestTraceToSpan(trace *HTTPRequestTrace) request.Span {
 			Namespace: trace.Pid.Ns,
 		},
 	}
+	parseTwirpPath(&span)
+
+	return span
 }
 
 func SQLRequestTraceToSpan(trace *SQLRequestTrace) request.Span {
@@ -72,7 +75,7 @@ func SQLRequestTraceToSpan(trace *SQLRequestTrace) request.Span {
 	if sqlLen < 0 {
 		sqlLen = len(trace.Sql)
 	}
-	sql := string(trace.Sql[:sqlLen])
+	sql := sqlprune.SQLObfuscate(string(trace.Sql[:sqlLen]))
 
 	method, path := sqlprune.SQLParseOperationAndTable(sql)
 
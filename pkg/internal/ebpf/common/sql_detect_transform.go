@@ -83,7 +83,7 @@ func detectSQL(buf string) (string, string, string) {
 	for _, q := range []string{"SELECT", "UPDATE", "DELETE", "INSERT", "ALTER", "CREATE", "DROP"} {
 		i := strings.Index(b, q)
 		if i >= 0 {
-			sql := cstr([]uint8(buf[i:]))
+			sql := sqlprune.SQLObfuscate(cstr([]uint8(buf[i:])))
 
 			op, table := sqlprune.SQLParseOperationAndTable(sql)
 			return op, table, sql
@@ -0,0 +1,154 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"strings"
+	"unsafe"
+
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// sshMaxBannerLen is the maximum length of the SSH identification string, including the CR LF
+// https://datatracker.ietf.org/doc/html/rfc4253#section-4.2
+const sshMaxBannerLen = 255
+
+type sshBanner struct {
+	protoVersion string
+	software     string
+}
+
+// parseSSHBanner parses the identification string that both the SSH client and server send
+// when the connection is established, e.g. "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n".
+// It is the only plain-text message of the protocol, as the rest of the connection is encrypted
+// after the key exchange.
+func parseSSHBanner(buf []byte) (*sshBanner, bool) {
+	if !bytes.HasPrefix(buf, []byte("SSH-")) {
+		return nil, false
+	}
+	end := bytes.IndexByte(buf, '\n')
+	if end < 0 || end >= sshMaxBannerLen {
+		return nil, false
+	}
+	line := strings.TrimSuffix(string(buf[len("SSH-"):end]), "\r")
+	protoVersion, softwareAndComments, ok := strings.Cut(line, "-")
+	if !ok || protoVersion == "" || softwareAndComments == "" {
+		return nil, false
+	}
+	for _, c := range []byte(line) {
+		if c < ' ' || c > '~' {
+			return nil, false
+		}
+	}
+	software, _, _ := strings.Cut(softwareAndComments, " ")
+	return &sshBanner{protoVersion: protoVersion, software: software}, true
+}
+
+// sshStatus returns 1 if the peer replied to the identification string with an error message,
+// e.g. "Protocol major versions differ.\r\n", or if any of the identification strings announces a
+// protocol version that is not compatible with SSH 2.0.
+func sshStatus(req *sshBanner, resp []byte) int {
+	respBanner, ok := parseSSHBanner(resp)
+	if !ok {
+		if len(resp) > 0 && isPrintableLine(resp) {
+			return 1
+		}
+		return 0
+	}
+	if !sshCompatible(req.protoVersion) || !sshCompatible(respBanner.protoVersion) {
+		return 1
+	}
+	return 0
+}
+
+// sshCompatible returns whether a protocol version is compatible with SSH 2.0. Version 1.99
+// is announced by the servers that support both the legacy 1.x and the 2.0 protocols.
+func sshCompatible(protoVersion string) bool {
+	return protoVersion == "2.0" || protoVersion == "1.99"
+}
+
+func isPrintableLine(buf []byte) bool {
+	line, _, _ := bytes.Cut(buf, []byte("\n"))
+	for _, c := range bytes.TrimSuffix(line, []byte("\r")) {
+		if c < ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// sshSetServerSide makes the destination of the connection info to be the SSH server. Both peers send
+// their identification string as soon as the connection is established, so the direction of the
+// first message can't tell which one is the server. Instead, we assume that the server listens on a
+// lower port than the ephemeral port of the client (e.g. 22).
+func sshSetServerSide(trace *TCPRequestInfo) {
+	// the destination port is the remote port if the first message was sent, and the local
+	// port if it was received
+	remotePort, localPort := trace.ConnInfo.D_port, trace.ConnInfo.S_port
+	if trace.Direction == 0 {
+		remotePort, localPort = localPort, remotePort
+	}
+	isServer := localPort < remotePort
+	if isServer == (trace.Direction != 0) {
+		reverseTCPEvent(trace)
+	}
+}
+
+// TCPToSSHToSpan creates a span from the identification strings of the request and the response.
+// The response identification string might be missing if the peer replied with an error.
+func TCPToSSHToSpan(trace *TCPRequestInfo, req, resp *sshBanner, status int) request.Span {
+	// the request identification string is the one of the instrumented process if it sent it first
+	local, remote := req, resp
+	if trace.Direction == 0 {
+		local, remote = resp, req
+	}
+	sshSetServerSide(trace)
+
+	peer := ""
+	hostname := ""
+	hostPort := 0
+
+	if trace.ConnInfo.S_port != 0 || trace.ConnInfo.D_port != 0 {
+		peer, hostname = (*BPFConnInfo)(unsafe.Pointer(&trace.ConnInfo)).reqHostInfo()
+		hostPort = int(trace.ConnInfo.D_port)
+	}
+
+	reqType := request.EventTypeSSHClient
+	client, server := local, remote
+	if trace.Direction == 0 {
+		reqType = request.EventTypeSSHServer
+		client, server = remote, local
+	}
+	ssh := &request.SSH{}
+	if client != nil {
+		ssh.ClientSoftware = client.software
+		ssh.ProtocolVersion = client.protoVersion
+	}
+	if server != nil {
+		ssh.ServerSoftware = server.software
+	}
+
+	return request.Span{
+		Type:         reqType,
+		Method:       "session",
+		Peer:         peer,
+		PeerPort:     int(trace.ConnInfo.S_port),
+		Host:         hostname,
+		HostPort:     hostPort,
+		RequestStart: int64(trace.StartMonotimeNs),
+		Start:        int64(trace.StartMonotimeNs),
+		End:          int64(trace.EndMonotimeNs),
+		Status:       status,
+		TraceID:      trace2.TraceID(trace.Tp.TraceId),
+		SpanID:       trace2.SpanID(trace.Tp.SpanId),
+		ParentSpanID: trace2.SpanID(trace.Tp.ParentId),
+		Flags:        trace.Tp.Flags,
+		Pid: request.PidInfo{
+			HostPID:   trace.Pid.HostPid,
+			UserPID:   trace.Pid.UserPid,
+			Namespace: trace.Pid.Ns,
+		},
+		SSH: ssh,
+	}
+}
@@ -0,0 +1,128 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+const (
+	sshClientBanner = "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n"
+	sshServerBanner = "SSH-2.0-dropbear_2022.83\r\n"
+)
+
+func TestParseSSHBanner(t *testing.T) {
+	for _, tc := range []struct {
+		banner       string
+		protoVersion string
+		software     string
+	}{
+		{banner: sshClientBanner, protoVersion: "2.0", software: "OpenSSH_9.6p1"},
+		{banner: sshServerBanner, protoVersion: "2.0", software: "dropbear_2022.83"},
+		{banner: "SSH-1.99-Cisco-1.25\r\n", protoVersion: "1.99", software: "Cisco-1.25"},
+		{banner: "SSH-2.0-Go\n", protoVersion: "2.0", software: "Go"},
+		// the key exchange init message might be sent in the same packet
+		{banner: "SSH-2.0-paramiko_3.4.0\r\n\x00\x00\x04\xbc\x07\x14", protoVersion: "2.0", software: "paramiko_3.4.0"},
+	} {
+		t.Run(tc.banner, func(t *testing.T) {
+			b, ok := parseSSHBanner([]byte(tc.banner))
+			require.True(t, ok)
+			assert.Equal(t, tc.protoVersion, b.protoVersion)
+			assert.Equal(t, tc.software, b.software)
+		})
+	}
+}
+
+func TestParseSSHBanner_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		banner string
+	}{
+		{name: "no line end", banner: "SSH-2.0-OpenSSH_9.6p1"},
+		{name: "no software", banner: "SSH-2.0\r\n"},
+		{name: "empty software", banner: "SSH-2.0-\r\n"},
+		{name: "binary", banner: "SSH-2.0-Open\x01SSH\r\n"},
+		{name: "too long", banner: "SSH-2.0-" + string(bytes.Repeat([]byte("a"), sshMaxBannerLen)) + "\r\n"},
+		{name: "http", banner: "GET / HTTP/1.1\r\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := parseSSHBanner([]byte(tc.banner))
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestSSHStatus(t *testing.T) {
+	req := &sshBanner{protoVersion: "2.0", software: "OpenSSH_9.6p1"}
+	assert.Equal(t, 0, sshStatus(req, []byte(sshServerBanner)))
+	assert.Equal(t, 0, sshStatus(req, []byte("SSH-1.99-Cisco-1.25\r\n")))
+	assert.Equal(t, 1, sshStatus(req, []byte("SSH-1.5-legacy\r\n")))
+	assert.Equal(t, 1, sshStatus(req, []byte("Protocol major versions differ.\r\n")))
+	assert.Equal(t, 1, sshStatus(&sshBanner{protoVersion: "1.5", software: "legacy"}, []byte(sshServerBanner)))
+	assert.Equal(t, 0, sshStatus(req, nil))
+}
+
+func TestTCPToSSHToSpan(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		req       string
+		resp      string
+		direction int
+		peerPort  uint32
+		hostPort  uint32
+		spanType  request.EventType
+	}{
+		{name: "client sends first", req: sshClientBanner, resp: sshServerBanner,
+			direction: 1, peerPort: 40000, hostPort: 22, spanType: request.EventTypeSSHClient},
+		{name: "client receives first", req: sshServerBanner, resp: sshClientBanner,
+			direction: 0, peerPort: 22, hostPort: 40000, spanType: request.EventTypeSSHClient},
+		{name: "server receives first", req: sshClientBanner, resp: sshServerBanner,
+			direction: 0, peerPort: 40000, hostPort: 22, spanType: request.EventTypeSSHServer},
+		{name: "server sends first", req: sshServerBanner, resp: sshClientBanner,
+			direction: 1, peerPort: 22, hostPort: 40000, spanType: request.EventTypeSSHServer},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trace := makeTCPReq(tc.req, tc.direction, tc.peerPort, tc.hostPort, 5)
+			copy(trace.Rbuf[:], tc.resp)
+			trace.RespLen = uint32(len(tc.resp))
+			binaryRecord := bytes.Buffer{}
+			require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+			span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+			require.NoError(t, err)
+			require.False(t, ignore)
+			assert.Equal(t, tc.spanType, span.Type)
+			assert.Equal(t, "SSH session", span.TraceName())
+			assert.Equal(t, 22, span.HostPort)
+			assert.Equal(t, 40000, span.PeerPort)
+			require.NotNil(t, span.SSH)
+			assert.Equal(t, "2.0", span.SSH.ProtocolVersion)
+			assert.Equal(t, "OpenSSH_9.6p1", span.SSH.ClientSoftware)
+			assert.Equal(t, "dropbear_2022.83", span.SSH.ServerSoftware)
+			assert.Equal(t, codes.Unset, request.SpanStatusCode(&span))
+		})
+	}
+}
+
+func TestTCPToSSHToSpan_Error(t *testing.T) {
+	resp := "Protocol major versions differ.\r\n"
+	trace := makeTCPReq("SSH-1.5-legacy_1.0\r\n", 1, 40000, 22, 5)
+	copy(trace.Rbuf[:], resp)
+	trace.RespLen = uint32(len(resp))
+	binaryRecord := bytes.Buffer{}
+	require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+	span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+	require.NoError(t, err)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeSSHClient, span.Type)
+	require.NotNil(t, span.SSH)
+	assert.Equal(t, "legacy_1.0", span.SSH.ClientSoftware)
+	assert.Empty(t, span.SSH.ServerSoftware)
+	assert.Equal(t, codes.Error, request.SpanStatusCode(&span))
+}
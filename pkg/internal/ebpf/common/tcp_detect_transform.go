@@ -9,7 +9,16 @@ import (
 	"github.com/grafana/beyla/pkg/internal/request"
 )
 
-// nolint:cyclop
+// Confidence scores of the protocol detection of the spans that are inferred from the TCP traffic
+const (
+	// confidenceHigh: both the request and the response are recognized as the detected protocol
+	confidenceHigh = 1.0
+	// confidenceMedium: the request is recognized by the framing or the magic bytes of the protocol
+	confidenceMedium = 0.75
+	// confidenceLow: the request is recognized by heuristics, such as the SQL keywords in its payload
+	confidenceLow = 0.5
+)
+
 func ReadTCPRequestIntoSpan(record *ringbuf.Record, filter ServiceFilter) (request.Span, bool, error) {
 	var event TCPRequestInfo
 
@@ -22,6 +31,24 @@ func ReadTCPRequestIntoSpan(record *ringbuf.Record, filter ServiceFilter) (reque
 		return request.Span{}, true, nil
 	}
 
+	direction := event.Direction
+	span, known, ignore := detectTCPProtocol(&event)
+	if !known {
+		unknownTraffic.add(&event)
+		return request.Span{}, true, nil
+	}
+	if ignore {
+		return request.Span{}, true, nil
+	}
+	span.DetectionConfidence = detectionConfidence(&span, event.Direction != direction)
+	return span, false, nil
+}
+
+// detectTCPProtocol converts the TCP event into a span of the protocol that is recognized in its
+// request and response bytes. The known return value is false if the protocol couldn't be detected,
+// and the ignore return value is true if the protocol was detected but the event must be ignored.
+// nolint:cyclop
+func detectTCPProtocol(event *TCPRequestInfo) (span request.Span, known, ignore bool) {
 	l := int(event.Len)
 	if l < 0 || len(event.Buf) < l {
 		l = len(event.Buf)
@@ -34,45 +61,173 @@ func ReadTCPRequestIntoSpan(record *ringbuf.Record, filter ServiceFilter) (reque
 
 	b := event.Buf[:l]
 
+	// ClickHouse queries must be checked before the generic SQL detection,
+	// as the latter would also match the query text in the native protocol packet
+	if q, ok := parseClickHouseQuery(b); ok {
+		return TCPToClickHouseToSpan(event, q, clickHouseStatus(event.Rbuf[:rl])), true, false
+	}
+
+	// TDS requests must also be checked before the generic SQL detection,
+	// as the latter might match the varchar parameters of the RPC requests
+	if q, ok := parseTDSRequest(b); ok {
+		return TCPToTDSToSpan(event, q, tdsStatus(event.Rbuf[:rl])), true, false
+	}
+	if q, ok := parseTDSRequest(event.Rbuf[:rl]); ok && isTDSPacket(b, tdsPacketTabularData) {
+		// We've caught the event reversed in the middle of communication
+		reverseTCPEvent(event)
+		return TCPToTDSToSpan(event, q, tdsStatus(b)), true, false
+	}
+
+	// Oracle execute calls contain the SQL text, so they must be checked before the generic SQL detection
+	if q, ok := parseOracleRequest(b); ok {
+		return TCPToOracleToSpan(event, q, oracleStatus(event.Rbuf[:rl])), true, false
+	}
+	if q, ok := parseOracleRequest(event.Rbuf[:rl]); ok && isOracleResponse(b) {
+		// We've caught the event reversed in the middle of communication
+		reverseTCPEvent(event)
+		return TCPToOracleToSpan(event, q, oracleStatus(b)), true, false
+	}
+
+	if req, ok := parseDubboRequest(b); ok {
+		return TCPToDubboToSpan(event, req, dubboStatusToGRPC(event.Rbuf[:rl])), true, false
+	}
+	if req, ok := parseDubboRequest(event.Rbuf[:rl]); ok && isDubboResponse(b) {
+		// We've caught the event reversed in the middle of communication
+		reverseTCPEvent(event)
+		return TCPToDubboToSpan(event, req, dubboStatusToGRPC(b)), true, false
+	}
+
+	if f, route, ok := parseRSocketRequest(b, int64(event.Len)); ok {
+		return TCPToRSocketToSpan(event, route, rsocketStatusToGRPC(event.Rbuf[:rl], f.streamID)), true, false
+	}
+	if f, route, ok := parseRSocketRequest(event.Rbuf[:rl], int64(event.RespLen)); ok && isRSocketResponse(b, f.streamID) {
+		// We've caught the event reversed in the middle of communication
+		reverseTCPEvent(event)
+		return TCPToRSocketToSpan(event, route, rsocketStatusToGRPC(b, f.streamID)), true, false
+	}
+
+	if f, ok := isWebSocket(b, int64(event.Len), event.Rbuf[:rl], int64(event.RespLen)); ok {
+		return TCPToWebSocketToSpan(event, f), true, false
+	}
+
+	if req, ok := parseFTPCommand(b, int64(event.Len)); ok {
+		if code, size, ok := parseFTPReply(event.Rbuf[:rl]); ok {
+			return TCPToFTPToSpan(event, req, code, size), true, false
+		}
+	}
+	if req, ok := parseFTPCommand(event.Rbuf[:rl], int64(event.RespLen)); ok {
+		if code, size, ok := parseFTPReply(b); ok {
+			// We've caught the event reversed in the middle of communication
+			reverseTCPEvent(event)
+			return TCPToFTPToSpan(event, req, code, size), true, false
+		}
+	}
+
+	if req, ok := parseSSHBanner(b); ok {
+		// the peer replies with its own identification string or, on failure, with a plain text error
+		if resp, ok := parseSSHBanner(event.Rbuf[:rl]); ok || (rl > 0 && isPrintableLine(event.Rbuf[:rl])) {
+			return TCPToSSHToSpan(event, req, resp, sshStatus(req, event.Rbuf[:rl])), true, false
+		}
+	}
+
+	// AMQP must be checked before the generic SQL detection, as the latter might match the message payloads
+	if ev, ok := parseAMQPEvent(event, b, event.Rbuf[:rl]); ok {
+		if ev.transfer == nil {
+			return request.Span{}, true, true
+		}
+		return TCPToAMQPToSpan(event, ev), true, false
+	}
+
 	// Check if we have a SQL statement
 	op, table, sql := detectSQLBytes(b)
 	switch {
 	case validSQL(op, table):
-		return TCPToSQLToSpan(&event, op, table, sql), false, nil
+		return TCPToSQLToSpan(event, op, table, sql), true, false
 	case isRedis(b) && isRedis(event.Rbuf[:rl]):
 		op, text, ok := parseRedisRequest(string(b))
 
 		if ok {
-			var status int
+			resp := event.Rbuf[:rl]
 			if op == "" {
 				op, text, ok = parseRedisRequest(string(event.Rbuf[:rl]))
 				if !ok || op == "" {
-					return request.Span{}, true, nil // ignore if we couldn't parse it
+					return request.Span{}, true, true // ignore if we couldn't parse it
 				}
 				// We've caught the event reversed in the middle of communication, let's
 				// reverse the event
-				reverseTCPEvent(&event)
-				status = redisStatus(b)
-			} else {
-				status = redisStatus(event.Rbuf[:rl])
+				reverseTCPEvent(event)
+				resp = b
 			}
 
-			return TCPToRedisToSpan(&event, op, text, status), false, nil
+			span := TCPToRedisToSpan(event, op, text, redisStatus(resp))
+			span.RedisRedirect = redisRedirect(resp)
+			return span, true, false
 		}
+		return request.Span{}, true, true
 	default:
 		// Kafka and gRPC can look very similar in terms of bytes. We can mistake one for another.
 		// We try gRPC first because it's more reliable in detecting false gRPC sequences.
 		if isHTTP2(b, int(event.Len)) || isHTTP2(event.Rbuf[:rl], int(event.RespLen)) {
-			MisclassifiedEvents <- MisclassifiedEvent{EventType: EventTypeKHTTP2, TCPInfo: &event}
+			MisclassifiedEvents <- MisclassifiedEvent{EventType: EventTypeKHTTP2, TCPInfo: event}
+			return request.Span{}, true, true
 		} else {
-			k, err := ProcessPossibleKafkaEvent(&event, b, event.Rbuf[:rl])
+			k, err := ProcessPossibleKafkaEvent(event, b, event.Rbuf[:rl])
 			if err == nil {
-				return TCPToKafkaToSpan(&event, k), false, nil
+				return TCPToKafkaToSpan(event, k), true, false
 			}
 		}
 	}
 
-	return request.Span{}, true, nil // ignore if we couldn't parse it
+	// The following protocols are checked after the established ones, so they can't shadow them
+
+	if hello, ok := parseTLSClientHello(b); ok {
+		return TCPToTLSToSpan(event, hello, event.Rbuf[:rl]), true, false
+	}
+	if hello, ok := parseTLSClientHello(event.Rbuf[:rl]); ok {
+		// We've caught the event reversed in the middle of communication
+		reverseTCPEvent(event)
+		return TCPToTLSToSpan(event, hello, b), true, false
+	}
+
+	// both sides must be NATS operations, as the text protocol of NATS is easy to match by chance
+	if msg, ok := parseNATSMessage(b); ok && isNATSOperation(event.Rbuf[:rl]) {
+		return TCPToNATSToSpan(event, msg, natsStatus(event.Rbuf[:rl])), true, false
+	}
+	if msg, ok := parseNATSMessage(event.Rbuf[:rl]); ok && isNATSOperation(b) {
+		// We've caught the event reversed in the middle of communication
+		reverseTCPEvent(event)
+		return TCPToNATSToSpan(event, msg, natsStatus(b)), true, false
+	}
+
+	return request.Span{}, false, true // ignore if we couldn't parse it
+}
+
+// detectionConfidence returns the confidence score of the protocol detection of a span. The reversed
+// argument is true if the span was detected from an event caught in the middle of the communication.
+func detectionConfidence(span *request.Span, reversed bool) float64 {
+	switch span.Type {
+	case request.EventTypeRedisClient, request.EventTypeRedisServer,
+		request.EventTypeFTPClient, request.EventTypeFTPServer,
+		request.EventTypeSSHClient, request.EventTypeSSHServer:
+		// their detection requires recognizing the response as well
+		return confidenceHigh
+	case request.EventTypeKafkaClient, request.EventTypeKafkaServer:
+		// Kafka requests can be mistaken for other binary protocols
+		return confidenceLow
+	case request.EventTypeSQLClient:
+		if span.SubType == request.SQLSubtypeNone {
+			return confidenceLow
+		}
+	case request.EventTypeTLSClient, request.EventTypeTLSServer,
+		request.EventTypeNATSClient, request.EventTypeNATSServer:
+		// the reversed events of these protocols are detected from the request only
+		return confidenceMedium
+	}
+	if reversed {
+		// the reversed events are only accepted if the response matches the request protocol
+		return confidenceHigh
+	}
+	return confidenceMedium
 }
 
 func reverseTCPEvent(trace *TCPRequestInfo) {
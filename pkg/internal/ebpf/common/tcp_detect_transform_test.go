@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/request"
 	"github.com/grafana/beyla/pkg/internal/svc"
 )
@@ -39,6 +40,13 @@ func TestTCPReqSQLParsing(t *testing.T) {
 	assert.Equal(t, request.EventTypeSQLClient, s.Type)
 }
 
+func TestTCPReqSQLObfuscation(t *testing.T) {
+	op, table, sql := detectSQL("\x03UPDATE accounts SET owner = 'Alice' WHERE id = 42; DELETE FROM audit")
+	assert.Equal(t, "UPDATE", op)
+	assert.Equal(t, "accounts", table)
+	assert.Equal(t, "UPDATE accounts SET owner = ? WHERE id = ?", sql)
+}
+
 func TestTCPReqParsing(t *testing.T) {
 	sql := "Not a sql or any known protocol"
 	r := makeTCPReq(sql, tcpSend, 343534, 8080, 2000)
@@ -106,6 +114,55 @@ func TestReadTCPRequestIntoSpan_Overflow(t *testing.T) {
 	assert.Equal(t, request.EventTypeSQLClient, span.Type)
 	assert.Equal(t, "SELECT", span.Method)
 	assert.Equal(t, "foo", span.Path)
+	assert.Equal(t, confidenceLow, span.DetectionConfidence)
+}
+
+type unknownTrafficReporter struct {
+	imetrics.NoopReporter
+	bytes map[int]uint64
+}
+
+func (r *unknownTrafficReporter) UnknownProtocolTraffic(serverPort int, bytes uint64) {
+	r.bytes[serverPort] += bytes
+}
+
+func TestReadTCPRequestIntoSpan_UnknownProtocol(t *testing.T) {
+	fltr := TestPidsFilter{services: map[uint32]svc.ID{}}
+	read := func(tri TCPRequestInfo) (request.Span, bool) {
+		binaryRecord := bytes.Buffer{}
+		require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, tri))
+		span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &fltr)
+		require.NoError(t, err)
+		return span, ignore
+	}
+	// discard the traffic accounted by other tests
+	ReportUnknownTraffic(imetrics.NoopReporter{})
+
+	// redis requests are detected in both the request and the response
+	redis := makeTCPReq("*2\r\n$3\r\nGET\r\n$5\r\nbeyla\r\n", tcpSend, 34343, 6379, 10)
+	resp := "+OK\r\n"
+	copy(redis.Rbuf[:], resp)
+	redis.RespLen = uint32(len(resp))
+	span, ignore := read(redis)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeRedisClient, span.Type)
+	assert.Equal(t, confidenceHigh, span.DetectionConfidence)
+
+	unknown := makeTCPReq("Not a sql or any known protocol", tcpSend, 34343, 9999, 10)
+	unknown.RespLen = 20
+	_, ignore = read(unknown)
+	assert.True(t, ignore)
+	_, ignore = read(unknown)
+	assert.True(t, ignore)
+
+	reporter := &unknownTrafficReporter{bytes: map[int]uint64{}}
+	ReportUnknownTraffic(reporter)
+	assert.Equal(t, map[int]uint64{9999: 2 * (31 + 20)}, reporter.bytes)
+
+	// the counters are reset after each report
+	reporter.bytes = map[int]uint64{}
+	ReportUnknownTraffic(reporter)
+	assert.Empty(t, reporter.bytes)
 }
 
 func TestRedisDetection(t *testing.T) {
@@ -0,0 +1,409 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/sqlprune"
+)
+
+// TDS (Tabular Data Stream) packet types, as used by Microsoft SQL Server
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/
+const (
+	tdsPacketSQLBatch    = 0x01
+	tdsPacketRPC         = 0x03
+	tdsPacketTabularData = 0x04
+
+	tdsHeaderLen     = 8
+	tdsMaxPacketSize = 32767
+	// the ALL_HEADERS section of the requests contains, at most, the query notifications,
+	// transaction descriptor and trace activity headers
+	tdsMaxAllHeadersLen = 1024
+)
+
+// TDS tokens of the server responses
+const (
+	tdsTokenError     = 0xAA
+	tdsTokenInfo      = 0xAB
+	tdsTokenEnvChange = 0xE3
+	tdsTokenDone      = 0xFD
+	tdsTokenDoneProc  = 0xFE
+	tdsTokenDoneInPrc = 0xFF
+
+	tdsDoneError = 0x02
+)
+
+// TDS data types of the RPC parameters that we decode
+const (
+	tdsTypeIntN     = 0x26
+	tdsTypeVarChar  = 0xA7
+	tdsTypeNVarChar = 0xE7
+
+	tdsCollationLen = 5
+	tdsNullLen      = 0xFFFF
+)
+
+// IDs of the special stored procedures that the clients can invoke through RPC requests,
+// without sending their name
+const (
+	tdsProcCursorOpen     = 2
+	tdsProcCursorPrepExec = 5
+	tdsProcExecuteSQL     = 10
+	tdsProcPrepare        = 11
+	tdsProcExecute        = 12
+	tdsProcPrepExec       = 13
+)
+
+// tdsStatementParam is the position of the parameter that contains the SQL text in the
+// calls to the special stored procedures that receive it
+var tdsStatementParam = map[int]int{
+	tdsProcCursorOpen:     1,
+	tdsProcCursorPrepExec: 3,
+	tdsProcExecuteSQL:     0,
+	tdsProcPrepare:        2,
+	tdsProcPrepExec:       2,
+}
+
+// tdsProcNames maps the names of the special stored procedures to their IDs,
+// as some clients invoke them by name
+var tdsProcNames = map[string]int{
+	"sp_cursoropen":     tdsProcCursorOpen,
+	"sp_cursorprepexec": tdsProcCursorPrepExec,
+	"sp_executesql":     tdsProcExecuteSQL,
+	"sp_prepare":        tdsProcPrepare,
+	"sp_execute":        tdsProcExecute,
+	"sp_prepexec":       tdsProcPrepExec,
+}
+
+type tdsQuery struct {
+	op        string
+	table     string
+	statement string
+}
+
+// tdsReader decodes the primitive types of the TDS protocol, which are little endian.
+// Any read beyond the end of the buffer sets the failed flag.
+type tdsReader struct {
+	buf    []byte
+	pos    int
+	failed bool
+}
+
+func (r *tdsReader) skip(n int) {
+	if r.failed || n < 0 || r.pos+n > len(r.buf) {
+		r.failed = true
+		return
+	}
+	r.pos += n
+}
+
+func (r *tdsReader) uint8() int {
+	if r.failed || r.pos >= len(r.buf) {
+		r.failed = true
+		return 0
+	}
+	r.pos++
+	return int(r.buf[r.pos-1])
+}
+
+func (r *tdsReader) uint16() int {
+	if r.failed || r.pos+2 > len(r.buf) {
+		r.failed = true
+		return 0
+	}
+	r.pos += 2
+	return int(binary.LittleEndian.Uint16(r.buf[r.pos-2:]))
+}
+
+func (r *tdsReader) uint32() int {
+	if r.failed || r.pos+4 > len(r.buf) {
+		r.failed = true
+		return 0
+	}
+	r.pos += 4
+	return int(binary.LittleEndian.Uint32(r.buf[r.pos-4:]))
+}
+
+// ucs2 reads n UCS-2 characters
+func (r *tdsReader) ucs2(n int) string {
+	if r.failed || r.pos+2*n > len(r.buf) {
+		r.failed = true
+		return ""
+	}
+	str := decodeUCS2(r.buf[r.pos : r.pos+2*n])
+	r.pos += 2 * n
+	return str
+}
+
+// truncatedBytes reads n bytes, or the rest of the buffer if it is truncated before
+func (r *tdsReader) truncatedBytes(n int) []byte {
+	if r.failed {
+		return nil
+	}
+	end := r.pos + n
+	if n < 0 || end > len(r.buf) {
+		end = len(r.buf)
+	}
+	b := r.buf[r.pos:end]
+	r.pos = end
+	return b
+}
+
+// decodeUCS2 decodes the UTF-16LE text of the TDS packets, ignoring any trailing odd byte
+// of the truncated buffers
+func decodeUCS2(b []byte) string {
+	chars := make([]uint16, len(b)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+// isTDSPacket checks the header of a TDS packet of the given type
+func isTDSPacket(buf []byte, packetType byte) bool {
+	if len(buf) < tdsHeaderLen || buf[0] != packetType {
+		return false
+	}
+	length := int(binary.BigEndian.Uint16(buf[2:4]))
+	// the window byte is unused and must be zero
+	return length > tdsHeaderLen && length <= tdsMaxPacketSize && buf[7] == 0
+}
+
+// parseTDSRequest parses the SQL Batch and RPC requests of the TDS protocol, used by Microsoft SQL Server.
+// Only the first packet of a request is captured, so the SQL text might be truncated.
+func parseTDSRequest(buf []byte) (*tdsQuery, bool) {
+	if len(buf) < tdsHeaderLen {
+		return nil, false
+	}
+	var packetType byte
+	switch buf[0] {
+	case tdsPacketSQLBatch, tdsPacketRPC:
+		packetType = buf[0]
+	default:
+		return nil, false
+	}
+	if !isTDSPacket(buf, packetType) {
+		return nil, false
+	}
+	r := tdsReader{buf: buf, pos: tdsHeaderLen}
+	if !r.skipAllHeaders() {
+		return nil, false
+	}
+	if packetType == tdsPacketSQLBatch {
+		text := r.truncatedBytes(len(buf))
+		return tdsSQLQuery(decodeUCS2(text))
+	}
+	return r.rpcQuery()
+}
+
+// skipAllHeaders skips the ALL_HEADERS section, which is mandatory since TDS 7.2
+func (r *tdsReader) skipAllHeaders() bool {
+	start := r.pos
+	total := r.uint32()
+	if r.failed || total < 4 || total > tdsMaxAllHeadersLen {
+		return false
+	}
+	for !r.failed && r.pos < start+total {
+		length := r.uint32()
+		headerType := r.uint16()
+		// query notifications (1), transaction descriptor (2) and trace activity (3)
+		if headerType < 1 || headerType > 3 || length < 6 {
+			return false
+		}
+		r.skip(length - 6)
+	}
+	return !r.failed && r.pos == start+total
+}
+
+// tdsSQLQuery extracts the operation and table of a T-SQL text
+func tdsSQLQuery(text string) (*tdsQuery, bool) {
+	text = strings.TrimSpace(sanitizeTSQL(text))
+	if text == "" {
+		return nil, false
+	}
+	upper := asciiToUpper(text)
+	if strings.HasPrefix(upper, "EXEC ") || strings.HasPrefix(upper, "EXECUTE ") {
+		parts := strings.Fields(text)
+		procName := strings.NewReplacer("[", "", "]", "").Replace(parts[1])
+		return &tdsQuery{op: "EXECUTE", table: procName, statement: text}, true
+	}
+	op, table := sqlprune.SQLParseOperationAndTable(tsqlToParseable(text))
+	if op == "" {
+		return nil, false
+	}
+	return &tdsQuery{op: op, table: table, statement: text}, true
+}
+
+// sanitizeTSQL removes the control characters that might be decoded from the binary noise
+// that follows a truncated statement
+func sanitizeTSQL(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return ' '
+		case r < ' ' || r == 0xFFFD:
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// tsqlToParseable replaces the T-SQL bracket-delimited identifiers, which aren't understood by the
+// SQL parser, by backtick-delimited identifiers
+func tsqlToParseable(text string) string {
+	return strings.NewReplacer("[", "`", "]", "`").Replace(text)
+}
+
+// rpcQuery parses the body of an RPC request. The calls to the special stored procedures that receive
+// a SQL statement (e.g. sp_executesql or sp_prepexec) are reported as the statement. Any other call is
+// reported as the execution of the stored procedure.
+func (r *tdsReader) rpcQuery() (*tdsQuery, bool) {
+	nameLen := r.uint16()
+	procID := 0
+	procName := ""
+	if nameLen == 0xFFFF {
+		procID = r.uint16()
+	} else {
+		procName = r.ucs2(nameLen)
+		if id, ok := tdsProcNames[strings.ToLower(strings.TrimPrefix(procName, "sys."))]; ok {
+			procID = id
+		}
+	}
+	r.uint16() // option flags
+	if r.failed || (procID == 0 && !isTDSIdentifier(procName)) {
+		return nil, false
+	}
+
+	if procID == 0 {
+		return &tdsQuery{op: "EXECUTE", table: procName, statement: "EXECUTE " + procName}, true
+	}
+	if procID == tdsProcExecute {
+		// the first parameter is the handle of a previously prepared statement
+		handle, ok := r.rpcParam()
+		if !ok {
+			return nil, false
+		}
+		return &tdsQuery{op: "EXECUTE", table: handle, statement: "EXECUTE " + handle}, true
+	}
+	stmtParam, ok := tdsStatementParam[procID]
+	if !ok {
+		// cursor fetching, unprepare...
+		return nil, false
+	}
+	for i := 0; i < stmtParam; i++ {
+		if _, ok := r.rpcParam(); !ok {
+			return nil, false
+		}
+	}
+	text, ok := r.rpcParam()
+	if !ok {
+		return nil, false
+	}
+	return tdsSQLQuery(text)
+}
+
+// rpcParam reads an RPC parameter and returns its value as a string. Only the integer
+// and character types are supported, as they are the only ones that are expected
+// before the statement parameter. The value of the last read parameter might be truncated.
+func (r *tdsReader) rpcParam() (string, bool) {
+	nameLen := r.uint8()
+	r.skip(2 * nameLen)
+	r.uint8() // status flags
+	switch dataType := r.uint8(); dataType {
+	case tdsTypeIntN:
+		r.uint8() // max length
+		switch length := r.uint8(); length {
+		case 0:
+			return "", !r.failed
+		case 1, 2, 4, 8:
+			value := r.truncatedBytes(length)
+			if r.failed || len(value) != length {
+				return "", false
+			}
+			var n uint64
+			for i := length - 1; i >= 0; i-- {
+				n = n<<8 | uint64(value[i])
+			}
+			return strconv.FormatUint(n, 10), true
+		}
+		return "", false
+	case tdsTypeNVarChar, tdsTypeVarChar:
+		maxLen := r.uint16()
+		r.skip(tdsCollationLen)
+		var value []byte
+		if maxLen == tdsNullLen {
+			value = r.plpValue()
+		} else {
+			length := r.uint16()
+			if length == tdsNullLen {
+				return "", !r.failed
+			}
+			value = r.truncatedBytes(length)
+		}
+		if r.failed {
+			return "", false
+		}
+		if dataType == tdsTypeNVarChar {
+			return decodeUCS2(value), true
+		}
+		return string(value), true
+	}
+	return "", false
+}
+
+// plpValue reads the first chunk of a partially length-prefixed value, such as nvarchar(max)
+func (r *tdsReader) plpValue() []byte {
+	r.skip(8) // total length
+	chunkLen := r.uint32()
+	return r.truncatedBytes(chunkLen)
+}
+
+// isTDSIdentifier checks whether a stored procedure name is a valid, possibly qualified,
+// T-SQL identifier, to discard false positives
+func isTDSIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isASCIILetter(c) && (c < '0' || c > '9') && c != '_' && c != '.' && c != '[' && c != ']' && c != '#' && c != '@' {
+			return false
+		}
+	}
+	return true
+}
+
+// tdsStatus returns 1 if the server response reports an error, 0 otherwise. It checks the tokens that
+// precede the results or the final DONE token, which are the only ones that fit in the captured buffer.
+func tdsStatus(resp []byte) int {
+	if !isTDSPacket(resp, tdsPacketTabularData) {
+		return 0
+	}
+	r := tdsReader{buf: resp, pos: tdsHeaderLen}
+	for !r.failed {
+		switch r.uint8() {
+		case tdsTokenError:
+			return 1
+		case tdsTokenInfo, tdsTokenEnvChange:
+			r.skip(r.uint16())
+		case tdsTokenDone, tdsTokenDoneProc, tdsTokenDoneInPrc:
+			if r.uint16()&tdsDoneError != 0 {
+				return 1
+			}
+			return 0
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+func TCPToTDSToSpan(trace *TCPRequestInfo, q *tdsQuery, status int) request.Span {
+	span := TCPToSQLToSpan(trace, q.op, q.table, q.statement)
+	span.Status = status
+	span.SubType = request.SQLSubtypeMSSQL
+	return span
+}
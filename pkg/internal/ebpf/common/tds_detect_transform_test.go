@@ -0,0 +1,186 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+type tdsPacket []byte
+
+func (p tdsPacket) bytes(b ...byte) tdsPacket {
+	return append(p, b...)
+}
+
+func (p tdsPacket) uint16(v int) tdsPacket {
+	return binary.LittleEndian.AppendUint16(p, uint16(v))
+}
+
+func (p tdsPacket) uint32(v int) tdsPacket {
+	return binary.LittleEndian.AppendUint32(p, uint32(v))
+}
+
+func (p tdsPacket) ucs2(s string) tdsPacket {
+	for _, c := range utf16.Encode([]rune(s)) {
+		p = p.uint16(int(c))
+	}
+	return p
+}
+
+// nvarcharParam appends an unnamed nvarchar RPC parameter
+func (p tdsPacket) nvarcharParam(value string) tdsPacket {
+	return p.bytes(0, 0, tdsTypeNVarChar).uint16(8000).bytes(0x09, 0x04, 0xD0, 0x00, 0x34).
+		uint16(2 * len(value)).ucs2(value)
+}
+
+// intParam appends an unnamed int RPC parameter
+func (p tdsPacket) intParam(value int) tdsPacket {
+	return p.bytes(0, 0, tdsTypeIntN, 4, 4).uint32(value)
+}
+
+// tdsRequest builds a TDS packet with the transaction descriptor header
+// that precedes the body of all the requests
+func tdsRequest(packetType byte, body tdsPacket) []byte {
+	p := tdsPacket{packetType, 0x01, 0, 0, 0, 0, 1, 0}.
+		uint32(22).                    // ALL_HEADERS total length
+		uint32(18).uint16(2).          // transaction descriptor header
+		bytes(0, 0, 0, 0, 0, 0, 0, 0). // transaction descriptor
+		uint32(1).bytes(body...)       // outstanding request count
+	binary.BigEndian.PutUint16(p[2:4], uint16(len(p)))
+	return p
+}
+
+func tdsSQLBatch(query string) []byte {
+	return tdsRequest(tdsPacketSQLBatch, tdsPacket{}.ucs2(query))
+}
+
+func TestParseTDSRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		packet    []byte
+		op        string
+		table     string
+		statement string
+	}{{
+		name:      "SQL batch",
+		packet:    tdsSQLBatch("SELECT TOP 10 * FROM [dbo].[Orders]\r\nWHERE [status] = 'open'"),
+		op:        "SELECT",
+		table:     "dbo.Orders",
+		statement: "SELECT TOP 10 * FROM [dbo].[Orders]  WHERE [status] = 'open'",
+	}, {
+		name:      "SQL batch executing a procedure",
+		packet:    tdsSQLBatch("EXEC [dbo].[GetCustomer] @id = 3"),
+		op:        "EXECUTE",
+		table:     "dbo.GetCustomer",
+		statement: "EXEC [dbo].[GetCustomer] @id = 3",
+	}, {
+		name: "sp_executesql by ID, as sent by SqlClient",
+		packet: tdsRequest(tdsPacketRPC, tdsPacket{}.uint16(0xFFFF).uint16(tdsProcExecuteSQL).uint16(0).
+			nvarcharParam("UPDATE Customers SET name = @P1 WHERE id = @P2").
+			nvarcharParam("@P1 nvarchar(4000),@P2 int")),
+		op:        "UPDATE",
+		table:     "Customers",
+		statement: "UPDATE Customers SET name = @P1 WHERE id = @P2",
+	}, {
+		name: "sp_executesql by name",
+		packet: tdsRequest(tdsPacketRPC, tdsPacket{}.uint16(13).ucs2("sp_executesql").uint16(0).
+			nvarcharParam("DELETE FROM Sessions WHERE id = @id")),
+		op:        "DELETE",
+		table:     "Sessions",
+		statement: "DELETE FROM Sessions WHERE id = @id",
+	}, {
+		name: "sp_prepexec, as sent by the JDBC driver",
+		packet: tdsRequest(tdsPacketRPC, tdsPacket{}.uint16(0xFFFF).uint16(tdsProcPrepExec).uint16(0).
+			bytes(0, 1, tdsTypeIntN, 4, 0). // NULL handle output parameter
+			nvarcharParam("@P0 int").
+			nvarcharParam("INSERT INTO Payments (amount) VALUES (@P0)")),
+		op:        "INSERT",
+		table:     "Payments",
+		statement: "INSERT INTO Payments (amount) VALUES (@P0)",
+	}, {
+		name:      "sp_execute with a prepared statement handle",
+		packet:    tdsRequest(tdsPacketRPC, tdsPacket{}.uint16(0xFFFF).uint16(tdsProcExecute).uint16(0).intParam(7)),
+		op:        "EXECUTE",
+		table:     "7",
+		statement: "EXECUTE 7",
+	}, {
+		name:      "stored procedure",
+		packet:    tdsRequest(tdsPacketRPC, tdsPacket{}.uint16(15).ucs2("dbo.GetCustomer").uint16(0).intParam(3)),
+		op:        "EXECUTE",
+		table:     "dbo.GetCustomer",
+		statement: "EXECUTE dbo.GetCustomer",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			q, ok := parseTDSRequest(tc.packet)
+			require.True(t, ok)
+			assert.Equal(t, tc.op, q.op)
+			assert.Equal(t, tc.table, q.table)
+			assert.Equal(t, tc.statement, q.statement)
+		})
+	}
+}
+
+func TestParseTDSRequest_Truncated(t *testing.T) {
+	packet := tdsSQLBatch("SELECT id, name, email FROM Customers WHERE country = 'NL'")
+	// the odd byte of the last character is ignored
+	q, ok := parseTDSRequest(packet[:len(packet)-21])
+	require.True(t, ok)
+	assert.Equal(t, "SELECT id, name, email FROM Customers WHERE cou", q.statement)
+	assert.Equal(t, "Customers", q.table)
+
+	// truncated before reaching the query text
+	_, ok = parseTDSRequest(packet[:20])
+	assert.False(t, ok)
+}
+
+func TestParseTDSRequest_NotTDS(t *testing.T) {
+	for _, buf := range [][]byte{
+		[]byte("SELECT * FROM users"),
+		[]byte("*2\r\n$3\r\nGET\r\n$5\r\nbeyla"),
+		// ClickHouse query
+		clickHouseQueryPacket(54460, "SELECT * FROM events"),
+		// without ALL_HEADERS
+		append([]byte{tdsPacketSQLBatch, 1, 0, 20, 0, 0, 1, 0}, tdsPacket{}.ucs2("SELECT 1")...),
+		// cursor fetch
+		tdsRequest(tdsPacketRPC, tdsPacket{}.uint16(0xFFFF).uint16(7).uint16(0).intParam(1)),
+		// not a SQL statement
+		tdsSQLBatch("hello world"),
+		{},
+	} {
+		_, ok := parseTDSRequest(buf)
+		assert.False(t, ok, "%q", buf)
+	}
+}
+
+func TestTDSStatus(t *testing.T) {
+	header := []byte{tdsPacketTabularData, 0x01, 0, 0x40, 0, 0x33, 1, 0}
+	// DONE token without errors
+	assert.Equal(t, 0, tdsStatus(append(header, tdsTokenDone, 0x10, 0, 0xC1, 0)))
+	// ERROR token, preceded by an ENVCHANGE token
+	assert.Equal(t, 1, tdsStatus(append(header, tdsTokenEnvChange, 3, 0, 1, 2, 3, tdsTokenError, 0x40, 0)))
+	// DONE token with the error flag
+	assert.Equal(t, 1, tdsStatus(append(header, tdsTokenDone, 0x12, 0, 0xC1, 0)))
+	// column metadata of the results
+	assert.Equal(t, 0, tdsStatus(append(header, 0x81, 1, 0)))
+	assert.Equal(t, 0, tdsStatus([]byte{tdsTokenError}))
+}
+
+func TestTCPToTDSToSpan(t *testing.T) {
+	trace := makeTCPReq("", 0, 10, 20, 0)
+	q, ok := parseTDSRequest(tdsSQLBatch("SELECT * FROM Orders"))
+	require.True(t, ok)
+
+	span := TCPToTDSToSpan(&trace, q, 1)
+	assert.Equal(t, request.EventTypeSQLClient, span.Type)
+	assert.Equal(t, request.SQLSubtypeMSSQL, span.SubType)
+	assert.Equal(t, "SELECT", span.Method)
+	assert.Equal(t, "Orders", span.Path)
+	assert.Equal(t, "SELECT * FROM Orders", span.Statement)
+	assert.Equal(t, 1, span.Status)
+	assert.Equal(t, "mssql", span.DBSystemName())
+}
@@ -0,0 +1,230 @@
+package ebpfcommon
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"unsafe"
+
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc8446#section-5.1
+const (
+	tlsRecordHeaderLen    = 5
+	tlsHandshakeHeaderLen = 4
+	tlsRandomLen          = 32
+
+	tlsContentAlert     = 21
+	tlsContentHandshake = 22
+
+	tlsHandshakeClientHello = 1
+	tlsHandshakeServerHello = 2
+
+	tlsExtServerName        = 0
+	tlsExtSupportedVersions = 43
+)
+
+type tlsClientHello struct {
+	// serverName is the SNI requested by the client. It might be empty if the client didn't
+	// send it, or if it didn't fit in the captured bytes
+	serverName string
+}
+
+type tlsServerHello struct {
+	// version is the negotiated protocol version, or zero if it didn't fit in the captured bytes
+	version     uint16
+	cipherSuite uint16
+}
+
+// tlsHandshakeMessage returns the body of the handshake message of the given type that
+// starts the TLS record of the buffer. The body might be truncated.
+func tlsHandshakeMessage(buf []byte, msgType uint8) ([]byte, bool) {
+	if len(buf) < tlsRecordHeaderLen+tlsHandshakeHeaderLen+2+tlsRandomLen ||
+		buf[0] != tlsContentHandshake || buf[1] != 3 || buf[2] > 4 ||
+		buf[tlsRecordHeaderLen] != msgType {
+		return nil, false
+	}
+	body := buf[tlsRecordHeaderLen+tlsHandshakeHeaderLen:]
+	// legacy_version of the message
+	if body[0] != 3 || body[1] > 4 {
+		return nil, false
+	}
+	msgLen := int(buf[6])<<16 | int(buf[7])<<8 | int(buf[8])
+	if msgLen < len(body) {
+		body = body[:msgLen]
+	}
+	if len(body) < 2+tlsRandomLen {
+		return nil, false
+	}
+	return body, true
+}
+
+// tlsSkipVector returns the rest of the buffer after a vector whose length is encoded in
+// lenBytes bytes, or false if the vector is truncated
+func tlsSkipVector(buf []byte, lenBytes int) ([]byte, bool) {
+	if len(buf) < lenBytes {
+		return nil, false
+	}
+	l := 0
+	for _, b := range buf[:lenBytes] {
+		l = l<<8 | int(b)
+	}
+	if len(buf) < lenBytes+l {
+		return nil, false
+	}
+	return buf[lenBytes+l:], true
+}
+
+// tlsExtensions invokes the passed function for each extension that fully fits in the buffer, until
+// the function returns false. It returns false if the extensions list is truncated.
+func tlsExtensions(buf []byte, fn func(extType uint16, data []byte) bool) bool {
+	if len(buf) < 2 {
+		return false
+	}
+	extLen := int(binary.BigEndian.Uint16(buf))
+	buf = buf[2:]
+	complete := len(buf) >= extLen
+	if complete {
+		buf = buf[:extLen]
+	}
+	for len(buf) >= 4 {
+		extType := binary.BigEndian.Uint16(buf)
+		dataLen := int(binary.BigEndian.Uint16(buf[2:]))
+		if len(buf) < 4+dataLen {
+			return false
+		}
+		if !fn(extType, buf[4:4+dataLen]) {
+			return true
+		}
+		buf = buf[4+dataLen:]
+	}
+	return complete
+}
+
+// parseTLSClientHello parses the ClientHello message that starts a TLS handshake. The eBPF probes only
+// capture the first bytes of the message, so the SNI might be missing if the client sent many cipher suites.
+func parseTLSClientHello(buf []byte) (*tlsClientHello, bool) {
+	body, ok := tlsHandshakeMessage(buf, tlsHandshakeClientHello)
+	if !ok {
+		return nil, false
+	}
+	hello := &tlsClientHello{}
+	rest := body[2+tlsRandomLen:]
+	// session ID, cipher suites and compression methods
+	for _, lenBytes := range []int{1, 2, 1} {
+		if rest, ok = tlsSkipVector(rest, lenBytes); !ok {
+			return hello, true
+		}
+	}
+	tlsExtensions(rest, func(extType uint16, data []byte) bool {
+		if extType != tlsExtServerName {
+			return true
+		}
+		// server name list, containing a host_name (type 0) entry
+		if len(data) >= 5 && data[2] == 0 {
+			nameLen := int(binary.BigEndian.Uint16(data[3:]))
+			if len(data) >= 5+nameLen {
+				hello.serverName = string(data[5 : 5+nameLen])
+			}
+		}
+		return false
+	})
+	return hello, true
+}
+
+// parseTLSServerHello parses the ServerHello message that replies to a ClientHello. In TLS 1.3, the negotiated
+// version is in the supported_versions extension, as the legacy version field is always TLS 1.2.
+func parseTLSServerHello(buf []byte) (*tlsServerHello, bool) {
+	body, ok := tlsHandshakeMessage(buf, tlsHandshakeServerHello)
+	if !ok {
+		return nil, false
+	}
+	rest, ok := tlsSkipVector(body[2+tlsRandomLen:], 1) // session ID
+	if !ok || len(rest) < 3 {
+		return nil, false
+	}
+	hello := &tlsServerHello{cipherSuite: binary.BigEndian.Uint16(rest)}
+	version := binary.BigEndian.Uint16(body)
+	if complete := tlsExtensions(rest[3:], func(extType uint16, data []byte) bool {
+		if extType == tlsExtSupportedVersions && len(data) == 2 {
+			version = binary.BigEndian.Uint16(data)
+			hello.version = version
+			return false
+		}
+		return true
+	}); complete || len(rest) == 3 {
+		// no extensions or all of them were captured, so the legacy version is the negotiated version
+		hello.version = version
+	}
+	return hello, true
+}
+
+func isTLSAlert(buf []byte) bool {
+	return len(buf) >= tlsRecordHeaderLen && buf[0] == tlsContentAlert && buf[1] == 3
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+	return ""
+}
+
+// TCPToTLSToSpan creates a span from the ClientHello message and the server reply, which is either
+// a ServerHello message or an alert if the server refused the handshake
+func TCPToTLSToSpan(trace *TCPRequestInfo, client *tlsClientHello, resp []byte) request.Span {
+	peer := ""
+	hostname := ""
+	hostPort := 0
+
+	if trace.ConnInfo.S_port != 0 || trace.ConnInfo.D_port != 0 {
+		peer, hostname = (*BPFConnInfo)(unsafe.Pointer(&trace.ConnInfo)).reqHostInfo()
+		hostPort = int(trace.ConnInfo.D_port)
+	}
+
+	reqType := request.EventTypeTLSClient
+	if trace.Direction == 0 {
+		reqType = request.EventTypeTLSServer
+	}
+
+	status := 0
+	handshake := &request.TLS{ServerName: client.serverName}
+	if server, ok := parseTLSServerHello(resp); ok {
+		handshake.Version = tlsVersionName(server.version)
+		handshake.CipherSuite = tls.CipherSuiteName(server.cipherSuite)
+	} else if isTLSAlert(resp) {
+		status = 1
+	}
+
+	return request.Span{
+		Type:         reqType,
+		Method:       "handshake",
+		Peer:         peer,
+		PeerPort:     int(trace.ConnInfo.S_port),
+		Host:         hostname,
+		HostPort:     hostPort,
+		RequestStart: int64(trace.StartMonotimeNs),
+		Start:        int64(trace.StartMonotimeNs),
+		End:          int64(trace.EndMonotimeNs),
+		Status:       status,
+		TraceID:      trace2.TraceID(trace.Tp.TraceId),
+		SpanID:       trace2.SpanID(trace.Tp.SpanId),
+		ParentSpanID: trace2.SpanID(trace.Tp.ParentId),
+		Flags:        trace.Tp.Flags,
+		Pid: request.PidInfo{
+			HostPID:   trace.Pid.HostPid,
+			UserPID:   trace.Pid.UserPid,
+			Namespace: trace.Pid.Ns,
+		},
+		TLS: handshake,
+	}
+}
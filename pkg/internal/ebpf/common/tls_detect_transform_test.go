@@ -0,0 +1,147 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// goClientHello returns the first bytes of the ClientHello message that the Go TLS client sends
+func goClientHello(t *testing.T, serverName string) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		_ = tls.Client(client, &tls.Config{ServerName: serverName}).Handshake()
+		client.Close()
+	}()
+	buf := make([]byte, 256)
+	n, err := server.Read(buf)
+	require.NoError(t, err)
+	return buf[:n]
+}
+
+// serverHello builds a ServerHello message. If version is TLS 1.3, it is sent in the
+// supported_versions extension.
+func serverHello(version, cipherSuite uint16) []byte {
+	msg := []byte{3, 3}
+	msg = append(msg, make([]byte, tlsRandomLen)...)
+	msg = append(msg, 0) // empty session ID
+	msg = binary.BigEndian.AppendUint16(msg, cipherSuite)
+	msg = append(msg, 0) // no compression
+	if version == tls.VersionTLS13 {
+		msg = append(msg, 0, 6, 0, tlsExtSupportedVersions, 0, 2)
+		msg = binary.BigEndian.AppendUint16(msg, version)
+	} else {
+		msg = append(msg, 0, 0)
+		binary.BigEndian.PutUint16(msg, version)
+	}
+	hs := append([]byte{tlsHandshakeServerHello, 0, byte(len(msg) >> 8), byte(len(msg))}, msg...)
+	return append([]byte{tlsContentHandshake, 3, 3, byte(len(hs) >> 8), byte(len(hs))}, hs...)
+}
+
+func TestParseTLSClientHello(t *testing.T) {
+	hello, ok := parseTLSClientHello(goClientHello(t, "shop.example.com"))
+	require.True(t, ok)
+	assert.Equal(t, "shop.example.com", hello.serverName)
+
+	// SNI is not sent for IP addresses
+	hello, ok = parseTLSClientHello(goClientHello(t, "10.0.0.1"))
+	require.True(t, ok)
+	assert.Empty(t, hello.serverName)
+
+	_, ok = parseTLSClientHello(serverHello(tls.VersionTLS13, tls.TLS_AES_128_GCM_SHA256))
+	assert.False(t, ok)
+	_, ok = parseTLSClientHello([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	assert.False(t, ok)
+	_, ok = parseTLSClientHello([]byte{tlsContentHandshake, 3, 1, 0, 200, tlsHandshakeClientHello})
+	assert.False(t, ok)
+}
+
+func TestParseTLSServerHello(t *testing.T) {
+	hello, ok := parseTLSServerHello(serverHello(tls.VersionTLS13, tls.TLS_AES_128_GCM_SHA256))
+	require.True(t, ok)
+	assert.Equal(t, uint16(tls.VersionTLS13), hello.version)
+	assert.Equal(t, tls.TLS_AES_128_GCM_SHA256, hello.cipherSuite)
+
+	hello, ok = parseTLSServerHello(serverHello(tls.VersionTLS12, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256))
+	require.True(t, ok)
+	assert.Equal(t, uint16(tls.VersionTLS12), hello.version)
+	assert.Equal(t, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, hello.cipherSuite)
+
+	// the extensions didn't fit in the captured bytes, so the version is unknown
+	msg := serverHello(tls.VersionTLS13, tls.TLS_AES_128_GCM_SHA256)
+	hello, ok = parseTLSServerHello(msg[:len(msg)-3])
+	require.True(t, ok)
+	assert.Zero(t, hello.version)
+	assert.Equal(t, tls.TLS_AES_128_GCM_SHA256, hello.cipherSuite)
+}
+
+func TestTCPToTLSToSpan(t *testing.T) {
+	clientHello := goClientHello(t, "shop.example.com")
+	resp := serverHello(tls.VersionTLS13, tls.TLS_AES_256_GCM_SHA384)
+	for _, tc := range []struct {
+		name       string
+		req        []byte
+		resp       []byte
+		direction  int
+		peerPort   uint32
+		hostPort   uint32
+		spanType   request.EventType
+		serverName string
+	}{
+		{name: "client", req: clientHello, resp: resp, direction: 1, peerPort: 40000, hostPort: 443,
+			spanType: request.EventTypeTLSClient, serverName: "shop.example.com"},
+		{name: "server", req: clientHello, resp: resp, direction: 0, peerPort: 40000, hostPort: 443,
+			spanType: request.EventTypeTLSServer, serverName: "shop.example.com"},
+		// the response buffer is shorter, so the SNI doesn't fit in it
+		{name: "client, reversed", req: resp, resp: clientHello, direction: 0, peerPort: 443, hostPort: 40000,
+			spanType: request.EventTypeTLSClient},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			trace := makeTCPReq(string(tc.req), tc.direction, tc.peerPort, tc.hostPort, 5)
+			copy(trace.Rbuf[:], tc.resp)
+			trace.RespLen = uint32(len(tc.resp))
+			binaryRecord := bytes.Buffer{}
+			require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+			span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+			require.NoError(t, err)
+			require.False(t, ignore)
+			assert.Equal(t, tc.spanType, span.Type)
+			assert.Equal(t, "TLS handshake", span.TraceName())
+			assert.Equal(t, 443, span.HostPort)
+			assert.Equal(t, 40000, span.PeerPort)
+			require.NotNil(t, span.TLS)
+			assert.Equal(t, "1.3", span.TLS.Version)
+			assert.Equal(t, "TLS_AES_256_GCM_SHA384", span.TLS.CipherSuite)
+			assert.Equal(t, tc.serverName, span.TLS.ServerName)
+			assert.Equal(t, codes.Unset, request.SpanStatusCode(&span))
+		})
+	}
+}
+
+func TestTCPToTLSToSpan_Alert(t *testing.T) {
+	// handshake_failure alert
+	alert := []byte{tlsContentAlert, 3, 3, 0, 2, 2, 40}
+	trace := makeTCPReq(string(goClientHello(t, "legacy.example.com")), 1, 40000, 443, 5)
+	copy(trace.Rbuf[:], alert)
+	trace.RespLen = uint32(len(alert))
+	binaryRecord := bytes.Buffer{}
+	require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+	span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+	require.NoError(t, err)
+	require.False(t, ignore)
+	assert.Equal(t, request.EventTypeTLSClient, span.Type)
+	require.NotNil(t, span.TLS)
+	assert.Equal(t, "legacy.example.com", span.TLS.ServerName)
+	assert.Empty(t, span.TLS.Version)
+	assert.Equal(t, codes.Error, request.SpanStatusCode(&span))
+}
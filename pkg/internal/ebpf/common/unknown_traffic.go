@@ -0,0 +1,53 @@
+package ebpfcommon
+
+import (
+	"sync"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+)
+
+// maxUnknownTrafficPorts limits the number of server ports that are distinguished in the unknown
+// traffic metrics. The bytes towards any other port are accounted in the port 0.
+const maxUnknownTrafficPorts = 100
+
+// unknownTraffic accumulates the bytes of the TCP requests and responses whose protocol couldn't be
+// detected, by server port, until they are reported to the internal metrics
+var unknownTraffic = unknownTrafficCounter{bytes: map[int]uint64{}, ports: map[int]struct{}{}}
+
+type unknownTrafficCounter struct {
+	mt    sync.Mutex
+	bytes map[int]uint64
+	// ports that are distinguished in the metrics
+	ports map[int]struct{}
+}
+
+func (u *unknownTrafficCounter) add(event *TCPRequestInfo) {
+	// the destination of the request is the server port, whatever the direction of the event
+	port := int(event.ConnInfo.D_port)
+	u.mt.Lock()
+	defer u.mt.Unlock()
+	if _, ok := u.ports[port]; !ok {
+		if len(u.ports) >= maxUnknownTrafficPorts {
+			port = 0
+		} else {
+			u.ports[port] = struct{}{}
+		}
+	}
+	u.bytes[port] += uint64(event.Len) + uint64(event.RespLen)
+}
+
+func (u *unknownTrafficCounter) report(metrics imetrics.Reporter) {
+	u.mt.Lock()
+	bytes := u.bytes
+	u.bytes = map[int]uint64{}
+	u.mt.Unlock()
+	for port, b := range bytes {
+		metrics.UnknownProtocolTraffic(port, b)
+	}
+}
+
+// ReportUnknownTraffic submits to the internal metrics the bytes of the TCP traffic whose protocol
+// couldn't be detected since the last invocation
+func ReportUnknownTraffic(metrics imetrics.Reporter) {
+	unknownTraffic.report(metrics)
+}
@@ -0,0 +1,180 @@
+package ebpfcommon
+
+import (
+	"encoding/binary"
+	"strings"
+	"unsafe"
+
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// WebSocket frame opcodes, as defined in RFC 6455
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+const (
+	wsFinBit       = 0x80
+	wsRsvBits      = 0x70
+	wsOpcodeMask   = 0x0F
+	wsMaskBit      = 0x80
+	wsPayloadMask  = 0x7F
+	wsMaxCtrlFrame = 125
+)
+
+type wsFrame struct {
+	opcode     uint8
+	masked     bool
+	payloadLen int64
+}
+
+// parseWebSocketUpgrade checks whether an HTTP request upgraded the connection to the WebSocket protocol.
+// In that case, the span finishes with the "101 Switching Protocols" response, and the
+// exchanged messages are reported as separate WebSocket spans.
+func parseWebSocketUpgrade(span *request.Span, buf []byte) {
+	if span.Status != 101 || span.SubType != request.HTTPSubtypeNone {
+		return
+	}
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	headers := cstr(buf)
+	if idx := strings.Index(headers, "\r\n\r\n"); idx >= 0 {
+		headers = headers[:idx]
+	}
+	if strings.EqualFold(httpHeader([]byte(headers), "upgrade"), "websocket") {
+		span.SubType = request.HTTPSubtypeWebSocket
+	}
+}
+
+// parseWebSocketFrame parses the header of a WebSocket frame. To discard false positives,
+// the frame must be well-formed and its declared length must match the total length
+// of the captured TCP message, so we only detect messages that are sent in a single frame.
+func parseWebSocketFrame(buf []byte, totalLen int64) (wsFrame, bool) {
+	if len(buf) < 2 || buf[0]&wsRsvBits != 0 {
+		return wsFrame{}, false
+	}
+	f := wsFrame{
+		opcode: buf[0] & wsOpcodeMask,
+		masked: buf[1]&wsMaskBit != 0,
+	}
+	fin := buf[0]&wsFinBit != 0
+	switch f.opcode {
+	case wsOpContinuation, wsOpText, wsOpBinary:
+	case wsOpClose, wsOpPing, wsOpPong:
+		// control frames can't be fragmented
+		if !fin || buf[1]&wsPayloadMask > wsMaxCtrlFrame {
+			return wsFrame{}, false
+		}
+	default:
+		return wsFrame{}, false
+	}
+	headerLen := int64(2)
+	switch l := buf[1] & wsPayloadMask; l {
+	case 126:
+		if len(buf) < 4 {
+			return wsFrame{}, false
+		}
+		f.payloadLen = int64(binary.BigEndian.Uint16(buf[2:4]))
+		headerLen += 2
+	case 127:
+		if len(buf) < 10 {
+			return wsFrame{}, false
+		}
+		l64 := binary.BigEndian.Uint64(buf[2:10])
+		// the most significant bit must be 0
+		if l64 > 1<<62 {
+			return wsFrame{}, false
+		}
+		f.payloadLen = int64(l64)
+		headerLen += 8
+	default:
+		f.payloadLen = int64(l)
+	}
+	if f.masked {
+		headerLen += 4
+	}
+	if headerLen+f.payloadLen != totalLen {
+		return wsFrame{}, false
+	}
+	return f, true
+}
+
+// isWebSocket returns whether the request is a frame sent by a WebSocket client,
+// which must always be masked, and the response is either empty or a frame sent
+// by a WebSocket server, which is never masked.
+func isWebSocket(req []byte, reqLen int64, resp []byte, respLen int64) (wsFrame, bool) {
+	f, ok := parseWebSocketFrame(req, reqLen)
+	if !ok || !f.masked {
+		return wsFrame{}, false
+	}
+	if respLen > 0 {
+		if rf, ok := parseWebSocketFrame(resp, respLen); !ok || rf.masked {
+			return wsFrame{}, false
+		}
+	}
+	return f, true
+}
+
+func wsMessageType(opcode uint8) string {
+	switch opcode {
+	case wsOpContinuation:
+		return request.WebSocketContinuation
+	case wsOpText:
+		return request.WebSocketText
+	case wsOpBinary:
+		return request.WebSocketBinary
+	case wsOpClose:
+		return request.WebSocketClose
+	case wsOpPing:
+		return request.WebSocketPing
+	case wsOpPong:
+		return request.WebSocketPong
+	}
+	return ""
+}
+
+func TCPToWebSocketToSpan(trace *TCPRequestInfo, f wsFrame) request.Span {
+	peer := ""
+	hostname := ""
+	hostPort := 0
+
+	if trace.ConnInfo.S_port != 0 || trace.ConnInfo.D_port != 0 {
+		peer, hostname = (*BPFConnInfo)(unsafe.Pointer(&trace.ConnInfo)).reqHostInfo()
+		hostPort = int(trace.ConnInfo.D_port)
+	}
+
+	reqType := request.EventTypeWebSocketClient
+	if trace.Direction == 0 {
+		reqType = request.EventTypeWebSocketServer
+	}
+
+	return request.Span{
+		Type:          reqType,
+		Method:        wsMessageType(f.opcode),
+		Peer:          peer,
+		PeerPort:      int(trace.ConnInfo.S_port),
+		Host:          hostname,
+		HostPort:      hostPort,
+		ContentLength: f.payloadLen,
+		RequestStart:  int64(trace.StartMonotimeNs),
+		Start:         int64(trace.StartMonotimeNs),
+		End:           int64(trace.EndMonotimeNs),
+		TraceID:       trace2.TraceID(trace.Tp.TraceId),
+		SpanID:        trace2.SpanID(trace.Tp.SpanId),
+		ParentSpanID:  trace2.SpanID(trace.Tp.ParentId),
+		Flags:         trace.Tp.Flags,
+		Pid: request.PidInfo{
+			HostPID:   trace.Pid.HostPid,
+			UserPID:   trace.Pid.UserPid,
+			Namespace: trace.Pid.Ns,
+		},
+	}
+}
@@ -0,0 +1,156 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// wsFrameBytes builds a single, final WebSocket frame. Client frames are masked.
+func wsFrameBytes(opcode uint8, masked bool, payload []byte) []byte {
+	frame := []byte{wsFinBit | opcode}
+	maskBit := uint8(0)
+	if masked {
+		maskBit = wsMaskBit
+	}
+	switch l := len(payload); {
+	case l < 126:
+		frame = append(frame, maskBit|uint8(l))
+	case l <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(l))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(l))
+	}
+	if masked {
+		key := []byte{0x12, 0x34, 0x56, 0x78}
+		frame = append(frame, key...)
+		for i, b := range payload {
+			frame = append(frame, b^key[i%4])
+		}
+		return frame
+	}
+	return append(frame, payload...)
+}
+
+func TestParseWebSocketFrame(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		frame   []byte
+		opcode  uint8
+		masked  bool
+		payload int64
+	}{
+		{name: "masked text", frame: wsFrameBytes(wsOpText, true, []byte("hello")), opcode: wsOpText, masked: true, payload: 5},
+		{name: "unmasked binary", frame: wsFrameBytes(wsOpBinary, false, make([]byte, 300)), opcode: wsOpBinary, payload: 300},
+		{name: "64-bit length", frame: wsFrameBytes(wsOpBinary, true, make([]byte, 70000)), opcode: wsOpBinary, masked: true, payload: 70000},
+		{name: "ping", frame: wsFrameBytes(wsOpPing, true, nil), opcode: wsOpPing, masked: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// the captured buffer is usually truncated
+			buf := tc.frame
+			if len(buf) > 256 {
+				buf = buf[:256]
+			}
+			f, ok := parseWebSocketFrame(buf, int64(len(tc.frame)))
+			require.True(t, ok)
+			assert.Equal(t, tc.opcode, f.opcode)
+			assert.Equal(t, tc.masked, f.masked)
+			assert.Equal(t, tc.payload, f.payloadLen)
+		})
+	}
+}
+
+func TestParseWebSocketFrame_Invalid(t *testing.T) {
+	valid := wsFrameBytes(wsOpText, true, []byte("hello"))
+	for _, tc := range []struct {
+		name  string
+		buf   []byte
+		total int64
+	}{
+		{name: "length mismatch", buf: valid, total: int64(len(valid)) + 1},
+		{name: "reserved bits", buf: append([]byte{0xC1}, valid[1:]...), total: int64(len(valid))},
+		{name: "unknown opcode", buf: append([]byte{0x83}, valid[1:]...), total: int64(len(valid))},
+		{name: "fragmented control frame", buf: append([]byte{wsOpPing}, valid[1:]...), total: int64(len(valid))},
+		{name: "redis", buf: []byte("*1\r\n$4\r\nPING\r\n"), total: 14},
+		{name: "postgres", buf: []byte("Q\x00\x00\x00\x0dSELECT 1\x00"), total: 14},
+		{name: "too short", buf: []byte{0x81}, total: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := parseWebSocketFrame(tc.buf, tc.total)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestIsWebSocket(t *testing.T) {
+	req := wsFrameBytes(wsOpText, true, []byte(`{"subscribe":"prices"}`))
+	resp := wsFrameBytes(wsOpText, false, []byte(`{"ok":true}`))
+
+	f, ok := isWebSocket(req, int64(len(req)), resp, int64(len(resp)))
+	require.True(t, ok)
+	assert.Equal(t, int64(22), f.payloadLen)
+
+	// messages without response
+	_, ok = isWebSocket(req, int64(len(req)), nil, 0)
+	assert.True(t, ok)
+
+	// client frames must be masked, and server frames unmasked
+	_, ok = isWebSocket(resp, int64(len(resp)), nil, 0)
+	assert.False(t, ok)
+	_, ok = isWebSocket(req, int64(len(req)), req, int64(len(req)))
+	assert.False(t, ok)
+}
+
+func TestTCPToWebSocketToSpan(t *testing.T) {
+	frame := wsFrameBytes(wsOpBinary, true, make([]byte, 1000))
+	for _, tc := range []struct {
+		direction int
+		spanType  request.EventType
+		kind      string
+		dir       string
+	}{
+		{direction: 0, spanType: request.EventTypeWebSocketServer, kind: "SPAN_KIND_SERVER", dir: "received"},
+		{direction: 1, spanType: request.EventTypeWebSocketClient, kind: "SPAN_KIND_CLIENT", dir: "sent"},
+	} {
+		trace := makeTCPReq(string(frame), tc.direction, 40000, 8080, 5)
+		binaryRecord := bytes.Buffer{}
+		require.NoError(t, binary.Write(&binaryRecord, binary.LittleEndian, trace))
+		span, ignore, err := ReadTCPRequestIntoSpan(&ringbuf.Record{RawSample: binaryRecord.Bytes()}, &IdentityPidsFilter{})
+		require.NoError(t, err)
+		require.False(t, ignore)
+		assert.Equal(t, tc.spanType, span.Type)
+		assert.Equal(t, request.WebSocketBinary, span.Method)
+		assert.Equal(t, int64(1000), span.ContentLength)
+		assert.Equal(t, 8080, span.HostPort)
+		assert.Equal(t, "websocket binary", span.TraceName())
+		assert.Equal(t, tc.kind, span.ServiceGraphKind())
+		assert.Equal(t, tc.dir, span.WebSocketDirection())
+	}
+}
+
+func TestParseWebSocketUpgrade(t *testing.T) {
+	buf := "GET /chat HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+
+	span := request.Span{Type: request.EventTypeHTTP, Status: 101}
+	parseWebSocketUpgrade(&span, []byte(buf))
+	assert.Equal(t, request.HTTPSubtypeWebSocket, span.SubType)
+
+	// the server didn't accept the upgrade
+	span = request.Span{Type: request.EventTypeHTTP, Status: 400}
+	parseWebSocketUpgrade(&span, []byte(buf))
+	assert.Equal(t, request.HTTPSubtypeNone, span.SubType)
+
+	// upgrade to another protocol
+	span = request.Span{Type: request.EventTypeHTTPClient, Status: 101}
+	parseWebSocketUpgrade(&span, []byte("GET / HTTP/1.1\r\nUpgrade: h2c\r\n\r\n"))
+	assert.Equal(t, request.HTTPSubtypeNone, span.SubType)
+}
@@ -40,6 +40,7 @@ var libsMux sync.Mutex
 
 type Tracer struct {
 	pidsFilter     ebpfcommon.ServiceFilter
+	parser         *ebpfcommon.Parser
 	cfg            *beyla.Config
 	metrics        imetrics.Reporter
 	bpfObjects     bpfObjects
@@ -55,6 +56,7 @@ func New(cfg *beyla.Config, metrics imetrics.Reporter) *Tracer {
 	return &Tracer{
 		log:            log,
 		cfg:            cfg,
+		parser:         ebpfcommon.NewParser(&cfg.EBPF, cfg.Attributes.Tenant.Header, cfg.Attributes.Tenant.JWTClaim),
 		metrics:        metrics,
 		pidsFilter:     ebpfcommon.CommonPIDsFilter(&cfg.Discovery),
 		qdiscs:         map[ifaces.Interface]*netlink.GenericQdisc{},
@@ -324,6 +326,23 @@ func (p *Tracer) UProbes() map[string]map[string]ebpfcommon.FunctionPrograms {
 				Start:    p.bpfObjects.UprobeSslShutdown,
 			},
 		},
+		// The GnuTLS record functions receive the session, buffer and size arguments in the same
+		// order as SSL_read and SSL_write, and return the number of bytes too, so they are
+		// instrumented with the same programs. NSS is not instrumented: its exported PR_Read and
+		// PR_Write functions are the generic NSPR I/O layer, which is also used for plain sockets
+		// and files, and the functions of its TLS layer are not exported.
+		"libgnutls.so": {
+			"gnutls_record_recv": {
+				Required: false,
+				Start:    p.bpfObjects.UprobeSslRead,
+				End:      p.bpfObjects.UretprobeSslRead,
+			},
+			"gnutls_record_send": {
+				Required: false,
+				Start:    p.bpfObjects.UprobeSslWrite,
+				End:      p.bpfObjects.UretprobeSslWrite,
+			},
+		},
 		"node": {
 			"_ZN4node9AsyncWrap13EmitAsyncInitEPNS_11EnvironmentEN2v85LocalINS3_6ObjectEEENS4_INS3_6StringEEEdd": {
 				Required: false,
@@ -429,10 +448,12 @@ func (p *Tracer) Run(ctx context.Context, eventsChan chan<- []request.Span) {
 
 	go p.watchForMisclassifedEvents()
 	go p.lookForTimeouts(timeoutTicker, eventsChan)
+	go p.reportTrafficMetrics(ctx)
 	defer timeoutTicker.Stop()
 
 	ebpfcommon.SharedRingbuf(
 		&p.cfg.EBPF,
+		p.parser,
 		p.pidsFilter,
 		p.bpfObjects.Events,
 		p.metrics,
@@ -461,7 +482,7 @@ func (p *Tracer) lookForTimeouts(ticker *time.Ticker, eventsChan chan<- []reques
 				if v.EndMonotimeNs != 0 && t.After(kernelTime(v.EndMonotimeNs).Add(2*time.Second)) {
 					// Must use unsafe here, the two bpfHttpInfoTs are the same but generated from different
 					// ebpf2go outputs
-					s, ignore, err := ebpfcommon.HTTPInfoEventToSpan(*(*ebpfcommon.BPFHTTPInfo)(unsafe.Pointer(&v)))
+					s, ignore, err := p.parser.HTTPInfoEventToSpan(*(*ebpfcommon.BPFHTTPInfo)(unsafe.Pointer(&v)))
 					if !ignore && err == nil {
 						eventsChan <- p.pidsFilter.Filter([]request.Span{s})
 					}
@@ -471,7 +492,7 @@ func (p *Tracer) lookForTimeouts(ticker *time.Ticker, eventsChan chan<- []reques
 				} else if v.EndMonotimeNs == 0 && p.cfg.EBPF.HTTPRequestTimeout.Milliseconds() > 0 && t.After(kernelTime(v.StartMonotimeNs).Add(p.cfg.EBPF.HTTPRequestTimeout)) {
 					// If we don't have a request finish with endTime by the configured request timeout, terminate the
 					// waiting request with a timeout 408
-					s, ignore, err := ebpfcommon.HTTPInfoEventToSpan(*(*ebpfcommon.BPFHTTPInfo)(unsafe.Pointer(&v)))
+					s, ignore, err := p.parser.HTTPInfoEventToSpan(*(*ebpfcommon.BPFHTTPInfo)(unsafe.Pointer(&v)))
 
 					if !ignore && err == nil {
 						s.Status = 408 // timeout
@@ -491,6 +512,24 @@ func (p *Tracer) lookForTimeouts(ticker *time.Ticker, eventsChan chan<- []reques
 	}
 }
 
+// trafficReportInterval is the period to submit to the internal metrics the bytes of the TCP traffic
+// of unknown protocol and the HTTP/2 stream resets
+const trafficReportInterval = 5 * time.Second
+
+func (p *Tracer) reportTrafficMetrics(ctx context.Context) {
+	ticker := time.NewTicker(trafficReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ebpfcommon.ReportUnknownTraffic(p.metrics)
+			ebpfcommon.ReportHTTP2StreamResets(p.metrics)
+		}
+	}
+}
+
 func (p *Tracer) watchForMisclassifedEvents() {
 	for e := range ebpfcommon.MisclassifiedEvents {
 		if e.EventType == ebpfcommon.EventTypeKHTTP2 {
@@ -5,7 +5,9 @@ package generictracer
 import (
 	"testing"
 
+	"github.com/cilium/ebpf"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBitPositionCalculation(t *testing.T) {
@@ -27,3 +29,27 @@ func TestBitPositionCalculation(t *testing.T) {
 func makeKey(first, second uint32) uint64 {
 	return uint64((uint64(first) << 32) | uint64(second))
 }
+
+func TestTLSLibrariesUProbes(t *testing.T) {
+	p := &Tracer{}
+	p.bpfObjects.UprobeSslRead = &ebpf.Program{}
+	p.bpfObjects.UretprobeSslRead = &ebpf.Program{}
+	p.bpfObjects.UprobeSslWrite = &ebpf.Program{}
+	p.bpfObjects.UretprobeSslWrite = &ebpf.Program{}
+	probes := p.UProbes()
+
+	require.Contains(t, probes, "libssl.so")
+	require.Contains(t, probes, "libgnutls.so")
+	gnutls := probes["libgnutls.so"]
+	require.Len(t, gnutls, 2)
+	// GnuTLS reuses the programs of the SSL_read/SSL_write functions of OpenSSL
+	assert.Same(t, p.bpfObjects.UprobeSslRead, gnutls["gnutls_record_recv"].Start)
+	assert.Same(t, p.bpfObjects.UretprobeSslRead, gnutls["gnutls_record_recv"].End)
+	assert.Same(t, p.bpfObjects.UprobeSslWrite, gnutls["gnutls_record_send"].Start)
+	assert.Same(t, p.bpfObjects.UretprobeSslWrite, gnutls["gnutls_record_send"].End)
+	assert.False(t, gnutls["gnutls_record_recv"].Required)
+	assert.False(t, gnutls["gnutls_record_send"].Required)
+
+	// the generic NSPR I/O functions are not instrumented, as they are also used for plain sockets
+	assert.NotContains(t, probes, "libnspr4.so")
+}
@@ -40,6 +40,7 @@ import (
 type Tracer struct {
 	log        *slog.Logger
 	pidsFilter ebpfcommon.ServiceFilter
+	parser     *ebpfcommon.Parser
 	cfg        *config.EPPFTracer
 	metrics    imetrics.Reporter
 	bpfObjects bpfObjects
@@ -51,6 +52,7 @@ func New(cfg *beyla.Config, metrics imetrics.Reporter) *Tracer {
 	return &Tracer{
 		log:        log,
 		pidsFilter: ebpfcommon.CommonPIDsFilter(&cfg.Discovery),
+		parser:     ebpfcommon.NewParser(&cfg.EBPF, cfg.Attributes.Tenant.Header, cfg.Attributes.Tenant.JWTClaim),
 		cfg:        &cfg.EBPF,
 		metrics:    metrics,
 	}
@@ -391,6 +393,7 @@ func (p *Tracer) SetupTC() {}
 func (p *Tracer) Run(ctx context.Context, eventsChan chan<- []request.Span) {
 	ebpfcommon.SharedRingbuf(
 		p.cfg,
+		p.parser,
 		p.pidsFilter,
 		p.bpfObjects.Events,
 		p.metrics,
@@ -42,6 +42,9 @@ func (i *instrumenter) goprobes(p Tracer) error {
 			}); err != nil {
 				return fmt.Errorf("instrumenting function %q: %w", funcName, err)
 			}
+			if offs.Start != 0 || len(offs.Inlined) > 0 {
+				i.goProbes[funcName] = struct{}{}
+			}
 			p.AddCloser(i.closables...)
 		}
 	}
@@ -50,6 +53,17 @@ func (i *instrumenter) goprobes(p Tracer) error {
 }
 
 func (i *instrumenter) goprobe(probe ebpfcommon.Probe) error {
+	// the inlined copies of the function are instrumented as any other copy
+	for _, inlined := range probe.Offsets.Inlined {
+		if err := i.goprobe(ebpfcommon.Probe{Offsets: inlined, Programs: probe.Programs}); err != nil {
+			return fmt.Errorf("instrumenting inlined copy at 0x%x: %w", inlined.Start, err)
+		}
+	}
+	if probe.Offsets.Start == 0 {
+		// the function has no out-of-line copy
+		return nil
+	}
+
 	// Attach BPF programs as start and return probes
 	if probe.Programs.Start != nil {
 		up, err := i.exe.Uprobe("", probe.Programs.Start, &link.UprobeOptions{
@@ -122,6 +136,8 @@ type uprobeModule struct {
 	lib       string
 	instrPath string
 	probes    []map[string]ebpfcommon.FunctionPrograms
+	// ssl is true if the module contains the TLS library functions
+	ssl bool
 }
 
 func (i *instrumenter) uprobeModules(p Tracer, pid int32, maps []*procfs.ProcMap, exePath string, exeIno uint64, log *slog.Logger) map[uint64]*uprobeModule {
@@ -162,8 +178,10 @@ func (i *instrumenter) uprobeModules(p Tracer, pid int32, maps []*procfs.ProcMap
 		if ok {
 			mod.probes = append(mod.probes, pMap)
 		} else {
-			modules[instrumentedIno] = &uprobeModule{lib: lib, instrPath: instrPath, probes: []map[string]ebpfcommon.FunctionPrograms{pMap}}
+			mod = &uprobeModule{lib: lib, instrPath: instrPath, probes: []map[string]ebpfcommon.FunctionPrograms{pMap}}
+			modules[instrumentedIno] = mod
 		}
+		mod.ssl = mod.ssl || lib == sslLib
 	}
 
 	return modules
@@ -221,16 +239,33 @@ func (i *instrumenter) uprobes(pid int32, p Tracer) error {
 			return err
 		}
 
+		// Different TLS libraries (or versions of them) require different probes
+		ssl := exec.SSLLibrary{Variant: exec.SSLUnknown}
+		var offsets map[string]uint64
+		if m.ssl {
+			ssl = findSSLLibrary(pid, maps, m, exePath, log)
+			log.Info("detected TLS library", "path", m.instrPath, "ino", instrumentedIno, "library", ssl.String())
+			if m.instrPath == exePath {
+				offsets = staticSSLOffsets(m, log)
+			}
+		}
+
 		for _, pMap := range m.probes {
 			for funcName, funcPrograms := range pMap {
+				if !sslFunctionSupported(ssl, funcName) {
+					log.Debug("function not supported by the TLS library, ignoring", "function", funcName, "library", ssl.String())
+					continue
+				}
 				log.Debug("going to instrument function", "function", funcName, "programs", funcPrograms)
-				if err := i.uprobe(p, instrumentedIno, funcName, libExe, funcPrograms); err != nil {
+				if err := i.uprobe(p, instrumentedIno, funcName, offsets[funcName], libExe, funcPrograms); err != nil {
 					if funcPrograms.Required {
 						return fmt.Errorf("instrumenting function %q: %w", funcName, err)
 					}
 
 					// error will be common here since this could be no openssl loaded
 					log.Debug("error instrumenting uprobe", "function", funcName, "error", err)
+				} else {
+					i.addModuleProbe(instrumentedIno, funcName)
 				}
 			}
 		}
@@ -245,9 +280,15 @@ func (i *instrumenter) uprobes(pid int32, p Tracer) error {
 	return nil
 }
 
-func (i *instrumenter) uprobe(p Tracer, instrumentedIno uint64, funcName string, exe *link.Executable, probe ebpfcommon.FunctionPrograms) error {
+// uprobe attaches the programs to a function. If the file offset of the function is zero,
+// it is looked up in the symbols of the executable.
+func (i *instrumenter) uprobe(p Tracer, instrumentedIno uint64, funcName string, offset uint64, exe *link.Executable, probe ebpfcommon.FunctionPrograms) error {
+	var opts *link.UprobeOptions
+	if offset != 0 {
+		opts = &link.UprobeOptions{Address: offset}
+	}
 	if probe.Start != nil {
-		up, err := exe.Uprobe(funcName, probe.Start, nil)
+		up, err := exe.Uprobe(funcName, probe.Start, opts)
 		if err != nil {
 			return fmt.Errorf("setting uprobe: %w", err)
 		}
@@ -255,7 +296,7 @@ func (i *instrumenter) uprobe(p Tracer, instrumentedIno uint64, funcName string,
 	}
 
 	if probe.End != nil {
-		up, err := exe.Uretprobe(funcName, probe.End, nil)
+		up, err := exe.Uretprobe(funcName, probe.End, opts)
 		if err != nil {
 			return fmt.Errorf("setting uretprobe: %w", err)
 		}
@@ -331,6 +372,15 @@ func (i *instrumenter) addModule(ino uint64) {
 	i.modules[ino] = struct{}{}
 }
 
+func (i *instrumenter) addModuleProbe(ino uint64, funcName string) {
+	probes, ok := i.moduleProbes[ino]
+	if !ok {
+		probes = map[string]struct{}{}
+		i.moduleProbes[ino] = probes
+	}
+	probes[funcName] = struct{}{}
+}
+
 func isLittleEndian() bool {
 	var a uint16 = 1
 
@@ -0,0 +1,145 @@
+package ebpf
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+)
+
+func mslog() *slog.Logger { return slog.With("component", "ebpf.MapStats") }
+
+type mapStats struct {
+	tracer string
+	name   string
+	bpfMap *ebpf.Map
+	// full is true if the map was found full in the last check
+	full bool
+}
+
+var concurrencyMaps = map[string]struct{}{}
+var loadedMaps []*mapStats
+var loadedMapsMux sync.Mutex
+
+// markConcurrencyMap records the name of a map that is sized after the maximum number of
+// concurrent requests, so its occupation is reported once it is loaded
+func markConcurrencyMap(name string) {
+	loadedMapsMux.Lock()
+	defer loadedMapsMux.Unlock()
+	concurrencyMaps[name] = struct{}{}
+}
+
+// registerMapStats keeps track of the concurrency maps of a tracer, after it is loaded, to
+// periodically check whether they are full. Maps that are shared among tracers are only registered once.
+func registerMapStats(tracer any, objects any) {
+	tracerName := path.Base(reflect.Indirect(reflect.ValueOf(tracer)).Type().PkgPath())
+
+	loadedMapsMux.Lock()
+	defer loadedMapsMux.Unlock()
+	forEachMap(reflect.ValueOf(objects), func(name string, m *ebpf.Map) {
+		if _, ok := concurrencyMaps[name]; !ok {
+			return
+		}
+		for _, ms := range loadedMaps {
+			if ms.bpfMap == m {
+				return
+			}
+		}
+		loadedMaps = append(loadedMaps, &mapStats{tracer: tracerName, name: name, bpfMap: m})
+	})
+}
+
+// forEachMap iterates the non-nil *ebpf.Map fields of the structs generated by bpf2go,
+// where the map name is specified by the `ebpf` field tag
+func forEachMap(val reflect.Value, fn func(name string, m *ebpf.Map)) {
+	val = reflect.Indirect(val)
+	if val.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		if !field.CanInterface() {
+			continue
+		}
+		if m, ok := field.Interface().(*ebpf.Map); ok {
+			if m != nil {
+				fn(val.Type().Field(i).Tag.Get("ebpf"), m)
+			}
+			continue
+		}
+		forEachMap(field, fn)
+	}
+}
+
+// ReportMapStats periodically checks the occupation of the maps that track the in-flight requests,
+// reporting to the internal metrics each time that a map is found full. The kernel
+// doesn't notify the evictions of the LRU maps, so a full map is the only evidence that its
+// entries are being evicted. It returns when the passed context is done.
+func ReportMapStats(ctx context.Context, metrics imetrics.Reporter, interval time.Duration) {
+	mslog().Debug("checking eBPF maps occupation", "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			collectMapStats(metrics)
+		}
+	}
+}
+
+func collectMapStats(metrics imetrics.Reporter) {
+	log := mslog()
+	loadedMapsMux.Lock()
+	defer loadedMapsMux.Unlock()
+	alive := loadedMaps[:0]
+	for _, ms := range loadedMaps {
+		maxEntries := ms.bpfMap.MaxEntries()
+		entries, err := countEntries(ms.bpfMap, maxEntries)
+		if err != nil {
+			// the map has been closed
+			log.Debug("removing map from statistics", "tracer", ms.tracer, "map", ms.name, "error", err)
+			continue
+		}
+		alive = append(alive, ms)
+		full := entries >= maxEntries
+		if full {
+			if !ms.full {
+				log.Warn("eBPF map is full. Some requests might not be reported. Consider increasing the"+
+					" maximum requests or shared requests of the ebpf.map_sizes configuration",
+					"tracer", ms.tracer, "map", ms.name, "maxEntries", maxEntries)
+			}
+			metrics.BPFMapFull(ms.tracer, ms.name)
+		}
+		ms.full = full
+	}
+	loadedMaps = alive
+}
+
+// countEntries iterates the keys of a map, up to the passed limit
+func countEntries(m *ebpf.Map, limit uint32) (uint32, error) {
+	key, next := make([]byte, m.KeySize()), make([]byte, m.KeySize())
+	var prev any
+	count := uint32(0)
+	for count < limit {
+		if err := m.NextKey(prev, next); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				break
+			}
+			return 0, err
+		}
+		key, next = next, key
+		prev = key
+		count++
+	}
+	return count, nil
+}
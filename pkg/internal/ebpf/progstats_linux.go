@@ -0,0 +1,132 @@
+package ebpf
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+)
+
+const bpfStatsSysctl = "/proc/sys/kernel/bpf_stats_enabled"
+
+func pslog() *slog.Logger { return slog.With("component", "ebpf.ProgramStats") }
+
+type programStats struct {
+	tracer  string
+	name    string
+	prog    *ebpf.Program
+	runTime time.Duration
+	runs    uint64
+}
+
+var loadedPrograms []*programStats
+var loadedProgramsMux sync.Mutex
+
+// registerProgramStats keeps track of the eBPF programs of a tracer, after it is loaded,
+// to periodically report their CPU usage. The tracer is identified by its package name
+// (e.g. gotracer, generictracer...), which corresponds to a Beyla feature.
+func registerProgramStats(tracer any, objects any) {
+	tracerName := path.Base(reflect.Indirect(reflect.ValueOf(tracer)).Type().PkgPath())
+
+	loadedProgramsMux.Lock()
+	defer loadedProgramsMux.Unlock()
+	forEachProgram(reflect.ValueOf(objects), func(name string, prog *ebpf.Program) {
+		loadedPrograms = append(loadedPrograms, &programStats{tracer: tracerName, name: name, prog: prog})
+	})
+}
+
+// forEachProgram iterates the non-nil *ebpf.Program fields of the structs generated by bpf2go,
+// where the program name is specified by the `ebpf` field tag
+func forEachProgram(val reflect.Value, fn func(name string, prog *ebpf.Program)) {
+	val = reflect.Indirect(val)
+	if val.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		if !field.CanInterface() {
+			continue
+		}
+		if prog, ok := field.Interface().(*ebpf.Program); ok {
+			if prog != nil {
+				fn(val.Type().Field(i).Tag.Get("ebpf"), prog)
+			}
+			continue
+		}
+		forEachProgram(field, fn)
+	}
+}
+
+// ReportProgramStats enables the kernel statistics of the eBPF programs and periodically reports,
+// to the internal metrics, the run time and run count of each program loaded by Beyla.
+// It returns when the passed context is done.
+func ReportProgramStats(ctx context.Context, metrics imetrics.Reporter, interval time.Duration) {
+	log := pslog()
+	stats, err := enableProgramStats()
+	if err != nil {
+		log.Warn("can't enable the eBPF programs statistics. Beyla won't report their CPU usage", "error", err)
+		return
+	}
+	defer stats.Close()
+	log.Debug("reporting eBPF programs statistics", "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			collectProgramStats(metrics)
+		}
+	}
+}
+
+func collectProgramStats(metrics imetrics.Reporter) {
+	loadedProgramsMux.Lock()
+	defer loadedProgramsMux.Unlock()
+	alive := loadedPrograms[:0]
+	for _, ps := range loadedPrograms {
+		info, err := ps.prog.Info()
+		if err != nil {
+			// the program has been closed
+			pslog().Debug("removing program from statistics", "tracer", ps.tracer, "program", ps.name, "error", err)
+			continue
+		}
+		alive = append(alive, ps)
+		runTime, ok := info.Runtime()
+		if !ok {
+			continue
+		}
+		runs, _ := info.RunCount()
+		if runTime > ps.runTime || runs > ps.runs {
+			metrics.BPFProgramRun(ps.tracer, ps.name, runTime-ps.runTime, runs-ps.runs)
+			ps.runTime, ps.runs = runTime, runs
+		}
+	}
+	loadedPrograms = alive
+}
+
+// enableProgramStats enables the kernel statistics until the returned io.Closer is closed.
+// If Beyla does not have permissions to enable them, it checks whether they have been
+// already enabled through the kernel.bpf_stats_enabled sysctl.
+func enableProgramStats() (io.Closer, error) {
+	closer, err := ebpf.EnableStats(uint32(unix.BPF_STATS_RUN_TIME))
+	if err == nil {
+		return closer, nil
+	}
+	if enabled, rerr := os.ReadFile(bpfStatsSysctl); rerr == nil && strings.TrimSpace(string(enabled)) == "1" {
+		return io.NopCloser(nil), nil
+	}
+	return nil, err
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package ebpf
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/prometheus/procfs"
+
+	"github.com/grafana/beyla/pkg/internal/exec"
+)
+
+const sslLib = "libssl.so"
+
+// unsupportedSSLFunctions lists, for each TLS library variant, the functions whose probes
+// must not be attached
+var unsupportedSSLFunctions = map[exec.SSLVariant]map[string]struct{}{
+	// LibreSSL implements SSL_read_ex and SSL_write_ex as wrappers of SSL_read and SSL_write,
+	// so instrumenting them would capture the same buffers twice
+	exec.LibreSSL: {"SSL_read_ex": {}, "SSL_write_ex": {}},
+}
+
+func sslFunctionSupported(ssl exec.SSLLibrary, funcName string) bool {
+	_, unsupported := unsupportedSSLFunctions[ssl.Variant][funcName]
+	return !unsupported
+}
+
+// findSSLLibrary detects the variant and version of the TLS library of a module. If the module
+// is the libssl shared library, the version is taken from the libcrypto library that is loaded
+// along with it. Otherwise, the TLS library is statically linked in the module.
+func findSSLLibrary(pid int32, maps []*procfs.ProcMap, m *uprobeModule, exePath string, log *slog.Logger) exec.SSLLibrary {
+	path := m.instrPath
+	if path != exePath {
+		cryptoMap := exec.LibPath("libcrypto.so", maps)
+		if cryptoMap == nil {
+			log.Debug("libcrypto not loaded. Can't find the TLS library version", "path", m.instrPath)
+			return exec.SSLLibrary{Variant: exec.SSLUnknown}
+		}
+		path = fmt.Sprintf("/proc/%d/map_files/%x-%x", pid, cryptoMap.StartAddr, cryptoMap.EndAddr)
+	}
+	ssl, err := exec.FindSSLLibrary(path)
+	if err != nil {
+		log.Debug("can't find the TLS library version", "path", path, "error", err)
+	}
+	return ssl
+}
+
+// staticSSLOffsets returns the file offsets of the TLS library functions that are statically linked in
+// the executable of a module. The symbols of some statically linked TLS libraries are prefixed
+// (e.g. BoringSSL in Envoy), so the uprobes can't be attached by the function names.
+func staticSSLOffsets(m *uprobeModule, log *slog.Logger) map[string]uint64 {
+	var funcs []string
+	for _, pMap := range m.probes {
+		for funcName := range pMap {
+			funcs = append(funcs, funcName)
+		}
+	}
+	offsets, err := exec.FindStaticSymbols(m.instrPath, funcs)
+	if err != nil {
+		log.Debug("can't read the symbols of the executable", "path", m.instrPath, "error", err)
+		return nil
+	}
+	if len(offsets) == 0 {
+		log.Debug("no TLS library functions found in the executable. It might not be linked, or the"+
+			" executable might be stripped", "path", m.instrPath)
+	}
+	return offsets
+}
@@ -115,6 +115,18 @@ type ProcessTracer struct {
 	SystemWide      bool
 	Type            ProcessTracerType
 	Instrumentables map[uint64]*instrumenter
+
+	mapSizes mapSizes //nolint:unused
+	// moduleProbes are the names of the functions with attached uprobes, by inode of the
+	// executable or shared library. They are shared by all the executables that load the module.
+	moduleProbes map[uint64]map[string]struct{} //nolint:unused
+}
+
+// mapSizes of the maps that track the in-flight requests. Zero values keep the sizes that are
+// compiled into the eBPF programs.
+type mapSizes struct {
+	sharedRequests uint32
+	requests       uint32
 }
 
 func (pt *ProcessTracer) AllowPID(pid, ns uint32, svc *svc.ID) {
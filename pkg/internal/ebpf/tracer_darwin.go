@@ -2,11 +2,13 @@ package ebpf
 
 import (
 	"context"
+	"time"
 
 	"github.com/cilium/ebpf/link"
 
 	"github.com/grafana/beyla/pkg/beyla"
 	"github.com/grafana/beyla/pkg/internal/exec"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/request"
 )
 
@@ -35,6 +37,14 @@ func (pt *ProcessTracer) NewExecutableInstance(_ *Instrumentable) error {
 
 func (pt *ProcessTracer) UnlinkExecutable(_ *exec.FileInfo) {}
 
+func (pt *ProcessTracer) Probes(_ uint64) []string {
+	return nil
+}
+
 func RunUtilityTracer(_ UtilityTracer) error {
 	return nil
 }
+
+func ReportProgramStats(_ context.Context, _ imetrics.Reporter, _ time.Duration) {}
+
+func ReportMapStats(_ context.Context, _ imetrics.Reporter, _ time.Duration) {}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 
@@ -37,6 +39,10 @@ type instrumenter struct {
 	exe       *link.Executable
 	closables []io.Closer
 	modules   map[uint64]struct{}
+	// goProbes are the names of the Go functions of the executable with attached probes
+	goProbes map[string]struct{}
+	// moduleProbes is shared with the ProcessTracer
+	moduleProbes map[uint64]map[string]struct{}
 }
 
 func roundToNearestMultiple(x, n uint32) uint32 {
@@ -50,8 +56,89 @@ func alignMaxEntriesIfRingBuf(m *ebpf.MapSpec) {
 	}
 }
 
+// sharedRequestsMaps are sized after MAX_CONCURRENT_SHARED_REQUESTS in bpf/map_sizing.h. Most of
+// them are pinned and shared among the tracers, so they hold the in-flight requests of all the
+// instrumented processes.
+var sharedRequestsMaps = map[string]struct{}{
+	"active_ssl_connections":     {},
+	"active_ssl_handshakes":      {},
+	"active_ssl_read_args":       {},
+	"active_ssl_write_args":      {},
+	"client_connect_info":        {},
+	"clone_map":                  {},
+	"go_trace_map":               {},
+	"ongoing_goroutines":         {},
+	"ongoing_http":               {},
+	"ongoing_http2_grpc":         {},
+	"ongoing_server_connections": {},
+	"ongoing_tcp_req":            {},
+	"pid_tid_to_conn":            {},
+	"server_traces":              {},
+	"ssl_to_conn":                {},
+	"trace_map":                  {},
+}
+
+// requestsMaps are sized after MAX_CONCURRENT_REQUESTS in bpf/map_sizing.h
+var requestsMaps = map[string]struct{}{
+	"active_accept_args":                {},
+	"active_connect_args":               {},
+	"active_recv_args":                  {},
+	"active_send_args":                  {},
+	"active_send_sock_args":             {},
+	"fetch_requests":                    {},
+	"framer_invocation_map":             {},
+	"grpc_framer_invocation_map":        {},
+	"header_req_map":                    {},
+	"http2_req_map":                     {},
+	"incoming_trace_map":                {},
+	"kafka_requests":                    {},
+	"newproc1":                          {},
+	"nodejs_parent_map":                 {},
+	"ongoing_client_connections":        {},
+	"ongoing_go_http":                   {},
+	"ongoing_grpc_client_requests":      {},
+	"ongoing_grpc_header_writes":        {},
+	"ongoing_grpc_operate_headers":      {},
+	"ongoing_grpc_request_status":       {},
+	"ongoing_grpc_server_requests":      {},
+	"ongoing_grpc_transports":           {},
+	"ongoing_http2_connections":         {},
+	"ongoing_http_client_requests":      {},
+	"ongoing_http_client_requests_data": {},
+	"ongoing_http_server_requests":      {},
+	"ongoing_kafka_requests":            {},
+	"ongoing_produce_messages":          {},
+	"ongoing_produce_topics":            {},
+	"ongoing_redis_requests":            {},
+	"ongoing_sql_queries":               {},
+	"ongoing_streams":                   {},
+	"outgoing_trace_map":                {},
+	"produce_requests":                  {},
+	"produce_traceparents":              {},
+	"redis_writes":                      {},
+	"ssl_to_pid_tid":                    {},
+}
+
+// resizeConcurrencyMap overrides the size of the hash maps that are sized after the maximum number
+// of concurrent requests. It returns whether the map is one of them.
+func resizeConcurrencyMap(name string, m *ebpf.MapSpec, sizes mapSizes) bool {
+	if _, ok := sharedRequestsMaps[name]; ok {
+		if sizes.sharedRequests != 0 {
+			m.MaxEntries = sizes.sharedRequests
+		}
+		return true
+	}
+	if _, ok := requestsMaps[name]; ok {
+		if sizes.requests != 0 {
+			m.MaxEntries = sizes.requests
+		}
+		return true
+	}
+	return false
+}
+
 // sets up internal maps and ensures sane max entries values
-func resolveMaps(spec *ebpf.CollectionSpec) (*ebpf.CollectionOptions, error) {
+func resolveMaps(spec *ebpf.CollectionSpec, sizes mapSizes) (*ebpf.CollectionOptions, error) {
 	collOpts := ebpf.CollectionOptions{MapReplacements: map[string]*ebpf.Map{}}
 
 	internalMapsMux.Lock()
@@ -59,6 +146,9 @@ func resolveMaps(spec *ebpf.CollectionSpec) (*ebpf.CollectionOptions, error) {
 
 	for k, v := range spec.Maps {
 		alignMaxEntriesIfRingBuf(v)
+		if resizeConcurrencyMap(k, v, sizes) {
+			markConcurrencyMap(k)
+		}
 
 		if v.Pinning != PinInternal {
 			continue
@@ -69,7 +159,10 @@ func resolveMaps(spec *ebpf.CollectionSpec) (*ebpf.CollectionOptions, error) {
 
 		var err error
 
-		if internalMap == nil {
+		if internalMap != nil {
+			// maps that are shared among tracers keep the size from the first tracer that loaded them
+			v.MaxEntries = internalMap.MaxEntries()
+		} else {
 			internalMap, err = ebpf.NewMap(v)
 
 			if err != nil {
@@ -87,11 +180,23 @@ func resolveMaps(spec *ebpf.CollectionSpec) (*ebpf.CollectionOptions, error) {
 }
 
 func NewProcessTracer(cfg *beyla.Config, tracerType ProcessTracerType, programs []Tracer) *ProcessTracer {
+	sizes := mapSizes{
+		sharedRequests: cfg.EBPF.MapSizes.GenericMaxSharedRequests,
+		requests:       cfg.EBPF.MapSizes.GenericMaxRequests,
+	}
+	if tracerType == Go {
+		sizes = mapSizes{
+			sharedRequests: cfg.EBPF.MapSizes.GoMaxSharedRequests,
+			requests:       cfg.EBPF.MapSizes.GoMaxRequests,
+		}
+	}
 	return &ProcessTracer{
 		Programs:        programs,
 		SystemWide:      cfg.Discovery.SystemWide,
 		Type:            tracerType,
 		Instrumentables: map[uint64]*instrumenter{},
+		mapSizes:        sizes,
+		moduleProbes:    map[uint64]map[string]struct{}{},
 	}
 }
 
@@ -136,7 +241,7 @@ func (pt *ProcessTracer) loadAndAssign(p Tracer) error {
 		return err
 	}
 
-	collOpts, err := resolveMaps(spec)
+	collOpts, err := resolveMaps(spec, pt.mapSizes)
 
 	if err != nil {
 		return err
@@ -169,6 +274,9 @@ func (pt *ProcessTracer) loadTracer(p Tracer, log *slog.Logger) error {
 		return fmt.Errorf("loading and assigning BPF objects: %w", err)
 	}
 
+	registerProgramStats(p, p.BpfObjects())
+	registerMapStats(p, p.BpfObjects())
+
 	// Setup any tail call jump tables
 	p.SetupTailCalls()
 
@@ -237,9 +345,11 @@ func (pt *ProcessTracer) NewExecutableInstance(ie *Instrumentable) error {
 
 func (pt *ProcessTracer) NewExecutable(exe *link.Executable, ie *Instrumentable) error {
 	i := instrumenter{
-		exe:     exe,
-		offsets: ie.Offsets, // this is needed for the function offsets, not fields
-		modules: map[uint64]struct{}{},
+		exe:          exe,
+		offsets:      ie.Offsets, // this is needed for the function offsets, not fields
+		modules:      map[uint64]struct{}{},
+		goProbes:     map[string]struct{}{},
+		moduleProbes: pt.moduleProbes,
 	}
 
 	for _, p := range pt.Programs {
@@ -281,6 +391,21 @@ func (pt *ProcessTracer) UnlinkExecutable(info *exec.FileInfo) {
 	}
 }
 
+// Probes returns the sorted names of the functions with attached uprobes in the executable with the
+// given inode, and in the shared libraries that it loads
+func (pt *ProcessTracer) Probes(ino uint64) []string {
+	i, ok := pt.Instrumentables[ino]
+	if !ok {
+		return nil
+	}
+	probes := map[string]struct{}{}
+	maps.Copy(probes, i.goProbes)
+	for module := range i.modules {
+		maps.Copy(probes, pt.moduleProbes[module])
+	}
+	return slices.Sorted(maps.Keys(probes))
+}
+
 func printVerifierErrorInfo(err error) {
 	var ve *ebpf.VerifierError
 	if errors.As(err, &ve) {
@@ -297,7 +422,7 @@ func RunUtilityTracer(p UtilityTracer) error {
 		return fmt.Errorf("loading eBPF program: %w", err)
 	}
 
-	collOpts, err := resolveMaps(spec)
+	collOpts, err := resolveMaps(spec, mapSizes{})
 	if err != nil {
 		return err
 	}
@@ -307,6 +432,8 @@ func RunUtilityTracer(p UtilityTracer) error {
 		return fmt.Errorf("loading and assigning BPF objects: %w", err)
 	}
 
+	registerProgramStats(p, p.BpfObjects())
+
 	if err := i.kprobes(p); err != nil {
 		printVerifierErrorInfo(err)
 		return err
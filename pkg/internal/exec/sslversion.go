@@ -0,0 +1,69 @@
+package exec
+
+import (
+	"debug/elf"
+	"fmt"
+	"regexp"
+)
+
+// SSLVariant is the implementation of the TLS library that is used by an executable
+type SSLVariant string
+
+const (
+	SSLUnknown SSLVariant = "unknown"
+	OpenSSL    SSLVariant = "OpenSSL"
+	LibreSSL   SSLVariant = "LibreSSL"
+	BoringSSL  SSLVariant = "BoringSSL"
+)
+
+// SSLLibrary describes the variant and version of a TLS library
+type SSLLibrary struct {
+	Variant SSLVariant
+	// Version is empty if it couldn't be found, e.g. for BoringSSL, which doesn't have versions
+	Version string
+}
+
+func (s SSLLibrary) String() string {
+	if s.Version == "" {
+		return string(s.Variant)
+	}
+	return string(s.Variant) + " " + s.Version
+}
+
+var (
+	sslVersionText = regexp.MustCompile(`(OpenSSL|LibreSSL) (\d+\.\d+\.\d+[a-z]*)`)
+	boringSSLText  = regexp.MustCompile(`\(compatible; BoringSSL\)`)
+)
+
+// FindSSLLibrary looks for the version text of the TLS library in the read-only data of an ELF file.
+// The version text is defined in the libcrypto library, so the path must point either to the libcrypto
+// that is loaded by the process, or to an executable that statically links the TLS library (e.g. NodeJS).
+func FindSSLLibrary(path string) (SSLLibrary, error) {
+	elfF, err := elf.Open(path)
+	if err != nil {
+		return SSLLibrary{Variant: SSLUnknown}, fmt.Errorf("opening ELF file %s: %w", path, err)
+	}
+	defer elfF.Close()
+	rodata := elfF.Section(".rodata")
+	if rodata == nil {
+		return SSLLibrary{Variant: SSLUnknown}, fmt.Errorf("can't find .rodata section in %s", path)
+	}
+	data, err := rodata.Data()
+	if err != nil {
+		return SSLLibrary{Variant: SSLUnknown}, fmt.Errorf("reading .rodata section of %s: %w", path, err)
+	}
+	return parseSSLVersion(data), nil
+}
+
+func parseSSLVersion(rodata []byte) SSLLibrary {
+	// BoringSSL claims to be OpenSSL for compatibility (e.g. "OpenSSL 1.1.1 (compatible; BoringSSL)"),
+	// so it has to be checked first
+	if boringSSLText.Match(rodata) {
+		return SSLLibrary{Variant: BoringSSL}
+	}
+	match := sslVersionText.FindSubmatch(rodata)
+	if match == nil {
+		return SSLLibrary{Variant: SSLUnknown}
+	}
+	return SSLLibrary{Variant: SSLVariant(match[1]), Version: string(match[2])}
+}
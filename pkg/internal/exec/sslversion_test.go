@@ -0,0 +1,24 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSSLVersion(t *testing.T) {
+	for _, tc := range []struct {
+		rodata   string
+		expected SSLLibrary
+	}{
+		{rodata: "\x00foo\x00OpenSSL 3.0.2 15 Mar 2022\x00bar", expected: SSLLibrary{Variant: OpenSSL, Version: "3.0.2"}},
+		{rodata: "\x00OpenSSL 1.1.1w  11 Sep 2023\x00", expected: SSLLibrary{Variant: OpenSSL, Version: "1.1.1w"}},
+		{rodata: "\x00LibreSSL 3.8.2\x00", expected: SSLLibrary{Variant: LibreSSL, Version: "3.8.2"}},
+		{rodata: "\x00OpenSSL 1.1.1 (compatible; BoringSSL)\x00", expected: SSLLibrary{Variant: BoringSSL}},
+		{rodata: "\x00OpenSSL rules\x00", expected: SSLLibrary{Variant: SSLUnknown}},
+	} {
+		t.Run(tc.expected.String(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseSSLVersion([]byte(tc.rodata)))
+		})
+	}
+}
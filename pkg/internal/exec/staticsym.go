@@ -0,0 +1,99 @@
+package exec
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FindStaticSymbols returns the file offsets of the given functions of a library that is statically linked
+// in an executable, keyed by function name. It is used to attach the uprobes to the functions whose symbols
+// are prefixed at build time to avoid clashes with the system libraries, such as the BoringSSL that is
+// embedded in Envoy or Chromium-based builds (BORINGSSL_PREFIX). Besides its exact name, a function is
+// found by the only symbol whose name is the function name preceded by a prefix and an underscore,
+// e.g. "envoy_SSL_read" for SSL_read. Stripped executables don't contain any symbol, so nothing is returned.
+func FindStaticSymbols(path string, funcs []string) (map[string]uint64, error) {
+	elfF, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening ELF file %s: %w", path, err)
+	}
+	defer elfF.Close()
+
+	syms, err := elfF.Symbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("reading symbols of %s: %w", path, err)
+	}
+	dynsyms, err := elfF.DynamicSymbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("reading dynamic symbols of %s: %w", path, err)
+	}
+	offsets := map[string]uint64{}
+	for _, sym := range append(syms, dynsyms...) {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Section == elf.SHN_UNDEF || sym.Value == 0 {
+			continue
+		}
+		if off, ok := fileOffset(elfF, sym.Value); ok {
+			offsets[sym.Name] = off
+		}
+	}
+	return matchStaticSymbols(offsets, funcs), nil
+}
+
+// fileOffset converts the virtual address of a function into its offset in the file
+func fileOffset(elfF *elf.File, addr uint64) (uint64, bool) {
+	for _, prog := range elfF.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Flags&elf.PF_X == 0 {
+			continue
+		}
+		if prog.Vaddr <= addr && addr < prog.Vaddr+prog.Memsz {
+			return addr - prog.Vaddr + prog.Off, true
+		}
+	}
+	return 0, false
+}
+
+func matchStaticSymbols(symbols map[string]uint64, funcs []string) map[string]uint64 {
+	found := make(map[string]uint64, len(funcs))
+	prefixed := map[string][]string{}
+	for _, fn := range funcs {
+		if off, ok := symbols[fn]; ok {
+			found[fn] = off
+		} else {
+			prefixed["_"+fn] = nil
+		}
+	}
+	if len(prefixed) == 0 {
+		return found
+	}
+	for name := range symbols {
+		idx := strings.IndexByte(name, '_')
+		for idx > 0 {
+			if _, ok := prefixed[name[idx:]]; ok && isSymbolPrefix(name[:idx]) {
+				prefixed[name[idx:]] = append(prefixed[name[idx:]], name)
+			}
+			next := strings.IndexByte(name[idx+1:], '_')
+			if next < 0 {
+				break
+			}
+			idx += next + 1
+		}
+	}
+	for suffix, names := range prefixed {
+		// ambiguous matches are ignored, as they likely belong to different functions
+		if len(names) == 1 {
+			found[suffix[1:]] = symbols[names[0]]
+		}
+	}
+	return found
+}
+
+// isSymbolPrefix returns whether the prefix of a symbol name is a C identifier
+func isSymbolPrefix(prefix string) bool {
+	for _, c := range prefix {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package exec
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchStaticSymbols(t *testing.T) {
+	symbols := map[string]uint64{
+		"SSL_write":           0x100,
+		"envoy_SSL_read":      0x200,
+		"envoy_SSL_read_ex":   0x300,
+		"a_SSL_shutdown":      0x400,
+		"b_SSL_shutdown":      0x500,
+		"my.lib_SSL_peek":     0x600,
+		"SSL_do_handshake_v2": 0x700,
+	}
+	// ambiguous prefixes, non-identifier prefixes and partial names are ignored
+	assert.Equal(t, map[string]uint64{
+		"SSL_write":   0x100,
+		"SSL_read":    0x200,
+		"SSL_read_ex": 0x300,
+	}, matchStaticSymbols(symbols,
+		[]string{"SSL_write", "SSL_read", "SSL_read_ex", "SSL_shutdown", "SSL_peek", "SSL_do_handshake"}))
+}
+
+func TestFindStaticSymbols(t *testing.T) {
+	// go test strips the symbols of the test executable, so the system C library is used instead
+	libs, _ := filepath.Glob("/lib*/*/libc.so.6")
+	if len(libs) == 0 {
+		t.Skip("can't find the C library")
+	}
+	offsets, err := FindStaticSymbols(libs[0], []string{"malloc", "SSL_read"})
+	require.NoError(t, err)
+	assert.NotZero(t, offsets["malloc"])
+	assert.NotContains(t, offsets, "SSL_read")
+}
@@ -23,6 +23,14 @@ type AttributeFamilyConfig map[string]MatchDefinition
 // ByAttribute provides a pipeline node that drops all the records of type T (*ebpf.Record, or *request.Span)
 // that do not match the provided AttributeFamilyConfig.
 func ByAttribute[T any](config AttributeFamilyConfig, getters attributes.NamedGetters[T, string]) pipe.MiddleProvider[[]T, []T] {
+	return ByAttributeReporting(config, getters, nil)
+}
+
+// ByAttributeReporting works as ByAttribute, but it also invokes the onDrop function for each batch with
+// discarded records, passing the number of discarded records and a copy of one of them as an example.
+func ByAttributeReporting[T any](
+	config AttributeFamilyConfig, getters attributes.NamedGetters[T, string], onDrop func(dropped int, example T),
+) pipe.MiddleProvider[[]T, []T] {
 	return func() (pipe.MiddleFunc[[]T, []T], error) {
 		if len(config) == 0 {
 			// No filter configuration provided. The node will be ignored
@@ -33,12 +41,14 @@ func ByAttribute[T any](config AttributeFamilyConfig, getters attributes.NamedGe
 		if err != nil {
 			return nil, err
 		}
+		f.onDrop = onDrop
 		return f.doFilter, nil
 	}
 }
 
 type filter[T any] struct {
 	matchers []Matcher[T]
+	onDrop   func(dropped int, example T)
 }
 
 func newFilter[T any](config AttributeFamilyConfig, getters attributes.NamedGetters[T, string]) (*filter[T], error) {
@@ -109,15 +119,23 @@ func (f *filter[T]) doFilter(in <-chan []T, out chan<- []T) {
 // the user-provided attribute matchers
 func (f *filter[T]) filterBatch(batch []T) []T {
 	w := 0
+	var example T
 batchLoop:
 	for t := range batch {
 		for m := range f.matchers {
 			if !f.matchers[m].Matches(batch[t]) {
+				// the discarded records might be overwritten by the next accepted records
+				if w == t {
+					example = batch[t]
+				}
 				continue batchLoop
 			}
 		}
 		batch[w] = batch[t]
 		w++
 	}
+	if f.onDrop != nil && w < len(batch) {
+		f.onDrop(len(batch)-w, example)
+	}
 	return batch[:w]
 }
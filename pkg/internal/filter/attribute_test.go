@@ -145,3 +145,34 @@ func TestAttributeFilter_SpanMetrics(t *testing.T) {
 	}
 
 }
+
+func TestAttributeFilter_ReportDrops(t *testing.T) {
+	var dropped []int
+	var examples []*request.Span
+	filterFunc, err := ByAttributeReporting[*request.Span](AttributeFamilyConfig{
+		"server": MatchDefinition{NotMatch: "filtered"},
+	}, request.SpanPromGetters, func(count int, example *request.Span) {
+		dropped = append(dropped, count)
+		examples = append(examples, example)
+	})()
+	require.NoError(t, err)
+
+	in := make(chan []*request.Span, 10)
+	out := make(chan []*request.Span, 10)
+	in <- []*request.Span{
+		{Type: request.EventTypeHTTP, PeerName: "client", Host: "server"},
+		{Type: request.EventTypeHTTP, PeerName: "client", Host: "filtered", Path: "/first"},
+		{Type: request.EventTypeHTTP, PeerName: "client", Host: "server"},
+		{Type: request.EventTypeHTTP, PeerName: "client", Host: "filtered", Path: "/second"},
+	}
+	// no record will be dropped
+	in <- []*request.Span{{Type: request.EventTypeHTTP, PeerName: "client", Host: "server"}}
+	close(in)
+	filterFunc(in, out)
+
+	assert.Len(t, testutil.ReadChannel(t, out, timeout), 2)
+	assert.Len(t, testutil.ReadChannel(t, out, timeout), 1)
+	assert.Equal(t, []int{2}, dropped)
+	require.Len(t, examples, 1)
+	assert.Equal(t, "/first", examples[0].Path)
+}
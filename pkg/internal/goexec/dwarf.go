@@ -0,0 +1,75 @@
+package goexec
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/beyla/pkg/config"
+)
+
+// dwarfDeadlineCheckEntries is the number of DWARF entries that are read between two checks of the deadline
+const dwarfDeadlineCheckEntries = 4096
+
+var (
+	errDWARFTimeout  = errors.New("timeout reading DWARF information")
+	errDWARFTooLarge = errors.New("DWARF information too large")
+)
+
+// dwarfInfo provides readers of the DWARF information of an executable that fail after a common deadline
+type dwarfInfo struct {
+	data     *dwarf.Data
+	deadline time.Time
+}
+
+// loadDWARF loads the DWARF information of the executable, if its size is within the provided limits
+func loadDWARF(elfF *elf.File, limits *config.DWARFLimits) (*dwarfInfo, error) {
+	if limits.MaxSize > 0 {
+		if size := dwarfSize(elfF); size > limits.MaxSize {
+			return nil, fmt.Errorf("%w: %d bytes exceed the limit of %d bytes", errDWARFTooLarge, size, limits.MaxSize)
+		}
+	}
+	data, err := elfF.DWARF()
+	if err != nil {
+		return nil, fmt.Errorf("reading DWARF data: %w", err)
+	}
+	dw := &dwarfInfo{data: data}
+	if limits.Timeout > 0 {
+		dw.deadline = time.Now().Add(limits.Timeout)
+	}
+	return dw, nil
+}
+
+// dwarfSize returns the uncompressed size of the DWARF sections of the executable, which
+// is the memory that is required to load them
+func dwarfSize(elfF *elf.File) uint64 {
+	size := uint64(0)
+	for _, s := range elfF.Sections {
+		if strings.HasPrefix(s.Name, ".debug_") || strings.HasPrefix(s.Name, ".zdebug_") {
+			size += s.Size
+		}
+	}
+	return size
+}
+
+func (d *dwarfInfo) reader() *deadlineReader {
+	return &deadlineReader{Reader: d.data.Reader(), deadline: d.deadline}
+}
+
+// deadlineReader is a dwarf.Reader that fails with errDWARFTimeout after the deadline
+type deadlineReader struct {
+	*dwarf.Reader
+	deadline time.Time
+	entries  int
+}
+
+func (r *deadlineReader) Next() (*dwarf.Entry, error) {
+	r.entries++
+	if !r.deadline.IsZero() && r.entries%dwarfDeadlineCheckEntries == 0 && time.Now().After(r.deadline) {
+		return nil, errDWARFTimeout
+	}
+	return r.Reader.Next()
+}
@@ -0,0 +1,50 @@
+package goexec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/config"
+)
+
+func TestLoadDWARF_Limits(t *testing.T) {
+	assert.NotZero(t, dwarfSize(debugELF))
+	assert.Zero(t, dwarfSize(smallELF))
+
+	_, err := loadDWARF(debugELF, &config.DWARFLimits{MaxSize: 1024})
+	require.ErrorIs(t, err, errDWARFTooLarge)
+
+	dw, err := loadDWARF(debugELF, &config.DWARFLimits{MaxSize: 1 << 30, Timeout: time.Minute})
+	require.NoError(t, err)
+	assert.NotNil(t, dw.data)
+	assert.False(t, dw.deadline.IsZero())
+
+	_, err = loadDWARF(smallELF, &config.DWARFLimits{})
+	require.Error(t, err)
+}
+
+func TestStructMemberOffsets_Timeout(t *testing.T) {
+	expired := &dwarfInfo{data: debugData, deadline: time.Now().Add(-time.Second)}
+	_, missing := structMemberOffsetsFromDwarf(expired)
+	assert.NotEmpty(t, missing)
+
+	// the missing offsets are taken from the prefetched database
+	offsets, err := structMemberOffsets(debugELF, expired)
+	require.NoError(t, err)
+	mustMatch(t, FieldOffsets{
+		URLPtrPos:    uint64(16),
+		PathPtrPos:   uint64(56),
+		ConnFdPos:    uint64(0),
+		FdLaddrPos:   uint64(96),
+		MethodPtrPos: uint64(0),
+	}, offsets)
+}
+
+func BenchmarkStructMemberOffsetsFromDwarf(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		structMemberOffsetsFromDwarf(&dwarfInfo{data: debugData})
+	}
+}
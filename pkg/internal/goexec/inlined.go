@@ -0,0 +1,110 @@
+package goexec
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"strings"
+)
+
+// inlinedCallSites looks in the DWARF information of the executable for the call sites where the
+// compiler inlined any of the provided functions, which happens more often in PGO-optimized builds.
+// The start of each inlined copy is its lowest address, and its returns are the addresses that
+// follow each of its address ranges, where the execution continues in the caller.
+func inlinedCallSites(elfF *elf.File, dw *dwarfInfo, functions map[string]struct{}) (map[string][]FuncOffsets, error) {
+	origins, err := inlinedOrigins(dw, functions)
+	if err != nil || len(origins) == 0 {
+		return nil, err
+	}
+
+	sites := map[string][]FuncOffsets{}
+	reader := dw.reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return sites, fmt.Errorf("reading DWARF entry: %w", err)
+		}
+		if entry == nil {
+			return sites, nil
+		}
+		if entry.Tag != dwarf.TagInlinedSubroutine {
+			continue
+		}
+		origin, _ := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+		fName, ok := origins[origin]
+		if !ok {
+			continue
+		}
+		ranges, err := dw.data.Ranges(entry)
+		if err != nil {
+			return sites, fmt.Errorf("reading address ranges of inlined %s: %w", fName, err)
+		}
+		if site, ok := inlinedSiteOffsets(elfF, ranges); ok {
+			sites[fName] = append(sites[fName], site)
+		}
+	}
+}
+
+// inlinedOrigins returns the names of the provided functions that have been inlined, by the
+// DWARF offset of their abstract instance
+func inlinedOrigins(dw *dwarfInfo, functions map[string]struct{}) (map[dwarf.Offset]string, error) {
+	origins := map[dwarf.Offset]string{}
+	reader := dw.reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return nil, fmt.Errorf("reading DWARF entry: %w", err)
+		}
+		if entry == nil {
+			return origins, nil
+		}
+		if entry.Tag != dwarf.TagSubprogram {
+			continue
+		}
+		// the inlined copies are not children of the abstract instances, but of their callers
+		if _, inlined := entry.Val(dwarf.AttrInline).(int64); !inlined {
+			continue
+		}
+		reader.SkipChildren()
+		fName, _ := entry.Val(dwarf.AttrName).(string)
+		// fetch short path of function for vendor scene
+		if paths := strings.Split(fName, "/vendor/"); len(paths) > 1 {
+			fName = paths[1]
+		}
+		if _, ok := functions[fName]; ok {
+			origins[entry.Offset] = fName
+		}
+	}
+}
+
+func inlinedSiteOffsets(elfF *elf.File, ranges [][2]uint64) (FuncOffsets, bool) {
+	site := FuncOffsets{}
+	for _, rng := range ranges {
+		start, ok := fileOffset(elfF, rng[0])
+		if !ok {
+			return FuncOffsets{}, false
+		}
+		end, ok := fileOffset(elfF, rng[1])
+		if !ok {
+			return FuncOffsets{}, false
+		}
+		if site.Start == 0 || start < site.Start {
+			site.Start = start
+		}
+		site.Returns = append(site.Returns, end)
+	}
+	return site, site.Start != 0
+}
+
+// fileOffset converts a virtual address of the executable code to its offset in the file
+func fileOffset(elfF *elf.File, addr uint64) (uint64, bool) {
+	for _, prog := range elfF.Progs {
+		if prog.Type != elf.PT_LOAD || (prog.Flags&elf.PF_X) == 0 {
+			continue
+		}
+		if prog.Vaddr <= addr && addr < prog.Vaddr+prog.Memsz {
+			return addr - prog.Vaddr + prog.Off, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,69 @@
+package goexec
+
+import (
+	"debug/elf"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/config"
+)
+
+// inlinedSum is small enough to be inlined by the compiler in its callers
+func inlinedSum(a, b int) int {
+	return a + b
+}
+
+//go:noinline
+func notInlinedCaller(a, b int) int {
+	return inlinedSum(a, b) * 2
+}
+
+func TestInlinedCallSites(t *testing.T) {
+	require.Equal(t, 6, notInlinedCaller(1, 2))
+
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	elfF, err := elf.Open(exe)
+	require.NoError(t, err)
+	defer elfF.Close()
+	// the test executables are stripped unless they are built with -ldflags="-w=false -s=false"
+	if _, err := elfF.DWARF(); err != nil || elfF.Section(".symtab") == nil {
+		t.Skip("test executable without DWARF information or symbol table")
+	}
+
+	const inlined = "github.com/grafana/beyla/pkg/internal/goexec.inlinedSum"
+	const caller = "github.com/grafana/beyla/pkg/internal/goexec.notInlinedCaller"
+	dw, err := loadDWARF(elfF, &config.DWARFLimits{})
+	require.NoError(t, err)
+	offsets, err := instrumentationPoints(elfF, []string{inlined, caller}, dw)
+	require.NoError(t, err)
+
+	require.Contains(t, offsets, caller)
+	callerOffs := offsets[caller]
+	assert.Empty(t, callerOffs.Inlined)
+
+	require.Contains(t, offsets, inlined)
+	sites := offsets[inlined].Inlined
+	require.NotEmpty(t, sites)
+	// at least one of the inlined copies is in the code of the caller
+	inCaller := false
+	for _, site := range sites {
+		assert.NotZero(t, site.Start)
+		require.NotEmpty(t, site.Returns)
+		for _, ret := range site.Returns {
+			assert.Greater(t, ret, site.Start)
+		}
+		if site.Start > callerOffs.Start && site.Start < callerOffs.Returns[len(callerOffs.Returns)-1] {
+			inCaller = true
+		}
+	}
+	assert.True(t, inCaller, "no inlined copy found in the caller code")
+
+	// the inlined copies are not searched unless requested
+	offsets, err = instrumentationPoints(elfF, []string{inlined, caller}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, offsets[inlined].Inlined)
+}
@@ -11,10 +11,11 @@ import (
 )
 
 // instrumentationPoints loads the provided executable and looks for the addresses
-// where the start and return probes must be inserted.
+// where the start and return probes must be inserted. If the inlined DWARF information is
+// provided, it also looks for the inlined copies of the functions.
 //
 //nolint:cyclop
-func instrumentationPoints(elfF *elf.File, funcNames []string) (map[string]FuncOffsets, error) {
+func instrumentationPoints(elfF *elf.File, funcNames []string, inlined *dwarfInfo) (map[string]FuncOffsets, error) {
 	ilog := slog.With("component", "goexec.instructions")
 	ilog.Debug("searching for instrumentation points", "functions", funcNames)
 	functions := map[string]struct{}{}
@@ -74,9 +75,29 @@ func instrumentationPoints(elfF *elf.File, funcNames []string) (map[string]FuncO
 		}
 	}
 
+	if inlined != nil {
+		addInlinedCallSites(elfF, inlined, functions, allOffsets, ilog)
+	}
+
 	return allOffsets, nil
 }
 
+func addInlinedCallSites(
+	elfF *elf.File, dw *dwarfInfo, functions map[string]struct{}, allOffsets map[string]FuncOffsets, ilog *slog.Logger,
+) {
+	// on error, the call sites that were found before it are still instrumented
+	sites, err := inlinedCallSites(elfF, dw, functions)
+	if err != nil {
+		ilog.Debug("can't look for all the inlined functions", "error", err)
+	}
+	for fName, fSites := range sites {
+		ilog.Debug("found inlined copies of function", "function", fName, "callSites", len(fSites))
+		offs := allOffsets[fName]
+		offs.Inlined = fSites
+		allOffsets[fName] = offs
+	}
+}
+
 func handleStaticSymbol(fName string, allOffsets map[string]FuncOffsets, allSyms map[string]exec.Sym, ilog *slog.Logger) {
 	s, ok := allSyms[fName]
 
@@ -2,8 +2,10 @@
 package goexec
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/grafana/beyla/pkg/config"
 	"github.com/grafana/beyla/pkg/internal/exec"
 )
 
@@ -14,21 +16,41 @@ type Offsets struct {
 }
 
 type FuncOffsets struct {
+	// Start and Returns of the out-of-line copy of the function. They are zero if the function
+	// has been inlined in all its call sites.
 	Start   uint64
 	Returns []uint64
+	// Inlined copies of the function in the code of its callers
+	Inlined []FuncOffsets
 }
 
 type FieldOffsets map[GoOffset]any
 
 // InspectOffsets gets the memory addresses/offsets of the instrumenting function, as well as the required
-// parameters fields to be read from the eBPF code
-func InspectOffsets(execElf *exec.FileInfo, funcs []string) (*Offsets, error) {
+// parameters fields to be read from the eBPF code. If inlined is true, it also looks for the call sites where
+// the functions have been inlined. The DWARF information of the executable is only read within the provided
+// limits, and the missing struct field offsets are taken from the prefetched offsets database.
+func InspectOffsets(execElf *exec.FileInfo, funcs []string, inlined bool, limits *config.DWARFLimits) (*Offsets, error) {
 	if execElf == nil {
 		return nil, fmt.Errorf("executable not found")
 	}
 
+	dw, err := loadDWARF(execElf.ELF, limits)
+	switch {
+	case errors.Is(err, errDWARFTooLarge):
+		log().Info("ignoring the DWARF information of the executable. Using the prefetched offsets",
+			"exec", execElf.CmdExePath, "error", err)
+	case err != nil:
+		log().Debug("can't use the DWARF information of the executable",
+			"exec", execElf.CmdExePath, "error", err)
+	}
+	var inlinedDWARF *dwarfInfo
+	if inlined {
+		inlinedDWARF = dw
+	}
+
 	// Analyse executable ELF file and find instrumentation points
-	found, err := instrumentationPoints(execElf.ELF, funcs)
+	found, err := instrumentationPoints(execElf.ELF, funcs, inlinedDWARF)
 	if err != nil {
 		return nil, fmt.Errorf("finding instrumentation points: %w", err)
 	}
@@ -37,7 +59,7 @@ func InspectOffsets(execElf *exec.FileInfo, funcs []string) (*Offsets, error) {
 	}
 
 	// check the offsets of the required fields from the method arguments
-	structFieldOffsets, err := structMemberOffsets(execElf.ELF)
+	structFieldOffsets, err := structMemberOffsets(execElf.ELF, dw)
 	if err != nil {
 		return nil, fmt.Errorf("checking struct members in file %s: %w", execElf.ProExeLinkPath, err)
 	}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/beyla/pkg/config"
 	"github.com/grafana/beyla/pkg/internal/testutil"
 )
 
@@ -18,7 +19,7 @@ func TestProcessNotFound(t *testing.T) {
 	finish := make(chan struct{})
 	go func() {
 		defer close(finish)
-		_, err := InspectOffsets(nil, nil)
+		_, err := InspectOffsets(nil, nil, false, &config.DWARFLimits{})
 		require.Error(t, err)
 	}()
 	testutil.ReadChannel(t, finish, 5*time.Second)
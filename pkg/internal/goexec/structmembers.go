@@ -5,6 +5,7 @@ import (
 	"debug/dwarf"
 	"debug/elf"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -287,13 +288,14 @@ var structMembers = map[string]structInfo{
 	},
 }
 
-func structMemberOffsets(elfFile *elf.File) (FieldOffsets, error) {
+// structMemberOffsets returns the offsets of the struct fields that are read from the eBPF code. The
+// DWARF information can be nil if the executable has no DWARF information or it exceeds the limits.
+func structMemberOffsets(elfFile *elf.File, dw *dwarfInfo) (FieldOffsets, error) {
 	// first, try to read offsets from DWARF debug info
 	var offs FieldOffsets
 	var expected map[GoOffset]struct{}
-	dwarfData, err := elfFile.DWARF()
-	if err == nil {
-		offs, expected = structMemberOffsetsFromDwarf(dwarfData)
+	if dw != nil {
+		offs, expected = structMemberOffsetsFromDwarf(dw)
 		if len(expected) > 0 {
 			log().Debug("Fields not found in the DWARF file", "fields", expected)
 		} else {
@@ -353,7 +355,7 @@ func structMemberPreFetchedOffsets(elfFile *elf.File, fieldOffsets FieldOffsets)
 
 // structMemberOffsetsFromDwarf reads the executable dwarf information to get
 // the offsets specified in the structMembers map
-func structMemberOffsetsFromDwarf(data *dwarf.Data) (FieldOffsets, map[GoOffset]struct{}) {
+func structMemberOffsetsFromDwarf(dw *dwarfInfo) (FieldOffsets, map[GoOffset]struct{}) {
 	log := log().With("function", "structMemberOffsetsFromDwarf")
 	expectedReturns := map[GoOffset]struct{}{}
 	for _, str := range structMembers {
@@ -364,9 +366,15 @@ func structMemberOffsetsFromDwarf(data *dwarf.Data) (FieldOffsets, map[GoOffset]
 	log.Debug("searching offests for field constants", "constants", expectedReturns)
 
 	fieldOffsets := FieldOffsets{}
-	reader := data.Reader()
-	for {
+	reader := dw.reader()
+	// the struct types are looked up until all the expected fields are found
+	for len(expectedReturns) > 0 {
 		entry, err := reader.Next()
+		if errors.Is(err, errDWARFTimeout) {
+			log.Warn("timeout reading the DWARF information. Using the prefetched offsets for the missing fields",
+				"fields", expectedReturns)
+			return fieldOffsets, expectedReturns
+		}
 		if err != nil {
 			log.Debug("error reading DRWARF info", "data", err)
 			return fieldOffsets, expectedReturns
@@ -391,9 +399,11 @@ func structMemberOffsetsFromDwarf(data *dwarf.Data) (FieldOffsets, map[GoOffset]
 		log.Debug("inspecting fields for struct type", "type", typeName)
 		if err := readMembers(reader, structMember.fields, expectedReturns, fieldOffsets); err != nil {
 			log.Debug("error reading DWARF info", "type", typeName, "error", err)
-			return nil, expectedReturns
+			// the offsets that were found are kept, and the rest are taken from the prefetched database
+			return fieldOffsets, expectedReturns
 		}
 	}
+	return fieldOffsets, expectedReturns
 }
 
 type dwarfReader interface {
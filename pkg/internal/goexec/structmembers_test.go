@@ -16,6 +16,7 @@ import (
 	"github.com/grafana/beyla/test/tools"
 )
 
+var debugELF *elf.File
 var debugData *dwarf.Data
 var grpcElf *dwarf.Data
 var smallELF *elf.File
@@ -46,7 +47,8 @@ func TestMain(m *testing.M) {
 	var err error
 	baseDir := tools.ProjectDir()
 	// Compiling the same executable twice, with and without debug data so we can inspect it later in the tests
-	debugData, err = compileELF(baseDir + "/test/cmd/pingserver/server.go").DWARF()
+	debugELF = compileELF(baseDir + "/test/cmd/pingserver/server.go")
+	debugData, err = debugELF.DWARF()
 	if err != nil {
 		panic(err)
 	}
@@ -66,7 +68,7 @@ func mustMatch(t *testing.T, expected, actual FieldOffsets) {
 }
 
 func TestGoOffsetsFromDwarf(t *testing.T) {
-	offsets, _ := structMemberOffsetsFromDwarf(debugData)
+	offsets, _ := structMemberOffsetsFromDwarf(&dwarfInfo{data: debugData})
 	// this test might fail if a future Go version updates the internal structure of the used structs.
 	mustMatch(t, FieldOffsets{
 		URLPtrPos:         uint64(16),
@@ -80,7 +82,7 @@ func TestGoOffsetsFromDwarf(t *testing.T) {
 }
 
 func TestGrpcOffsetsFromDwarf(t *testing.T) {
-	offsets, _ := structMemberOffsetsFromDwarf(grpcElf)
+	offsets, _ := structMemberOffsetsFromDwarf(&dwarfInfo{data: grpcElf})
 	// this test might fail if a future Go gRPC version updates the internal structure of the used structs.
 	mustMatch(t, FieldOffsets{
 		GrpcStreamStPtrPos:     uint64(8),
@@ -93,7 +95,7 @@ func TestGrpcOffsetsFromDwarf(t *testing.T) {
 }
 
 func TestGoOffsetsWithoutDwarf(t *testing.T) {
-	offsets, err := structMemberOffsets(smallELF)
+	offsets, err := structMemberOffsets(smallELF, nil)
 	require.NoError(t, err)
 	// this test might fail if a future Go version updates the internal structure of the used structs.
 	mustMatch(t, FieldOffsets{
@@ -106,7 +108,7 @@ func TestGoOffsetsWithoutDwarf(t *testing.T) {
 }
 
 func TestGrpcOffsetsWithoutDwarf(t *testing.T) {
-	offsets, _ := structMemberOffsets(smallGRPCElf)
+	offsets, _ := structMemberOffsets(smallGRPCElf, nil)
 	// this test might fail if a future Go gRPC version updates the internal structure of the used structs.
 	mustMatch(t, FieldOffsets{
 		GrpcStreamStPtrPos:     uint64(8),
@@ -125,7 +127,7 @@ func TestGoOffsetsFromDwarf_ErrorIfConstantNotFound(t *testing.T) {
 			"tralara": 123456,
 		},
 	}
-	_, missing := structMemberOffsetsFromDwarf(debugData)
+	_, missing := structMemberOffsetsFromDwarf(&dwarfInfo{data: debugData})
 	assert.Contains(t, missing, GoOffset(123456))
 }
 
@@ -0,0 +1,58 @@
+package imetrics
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+func dlog() *slog.Logger {
+	return slog.With("component", "imetrics.DroppedSpans")
+}
+
+// droppedSpansLogger decorates a Reporter to log an example of the discarded spans, at most
+// once per interval for each discard reason, so users can find where their missing spans went.
+type droppedSpansLogger struct {
+	Reporter
+	interval time.Duration
+
+	mt      sync.Mutex
+	lastLog map[string]time.Time
+}
+
+// injectable function for testing
+var droppedTimeNow = time.Now
+
+// NewDroppedSpansLogger returns a Reporter that forwards all the events to the passed reporter,
+// and also logs an example of the discarded spans according to the passed configuration.
+// If logging the examples is disabled, it returns the passed reporter.
+func NewDroppedSpansLogger(cfg *DroppedSpansConfig, reporter Reporter) Reporter {
+	if cfg == nil || cfg.LogInterval <= 0 {
+		return reporter
+	}
+	return &droppedSpansLogger{
+		Reporter: reporter,
+		interval: cfg.LogInterval,
+		lastLog:  map[string]time.Time{},
+	}
+}
+
+func (d *droppedSpansLogger) SpansDropped(reason string, count int, example fmt.Stringer) {
+	d.Reporter.SpansDropped(reason, count, example)
+	if example == nil || !d.shouldLog(reason) {
+		return
+	}
+	dlog().Info("spans dropped", "reason", reason, "count", count, "example", example.String())
+}
+
+func (d *droppedSpansLogger) shouldLog(reason string) bool {
+	now := droppedTimeNow()
+	d.mt.Lock()
+	defer d.mt.Unlock()
+	if last, ok := d.lastLog[reason]; ok && now.Sub(last) < d.interval {
+		return false
+	}
+	d.lastLog[reason] = now
+	return true
+}
@@ -0,0 +1,37 @@
+package imetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestDroppedSpansLogger(t *testing.T) {
+	now := time.Now()
+	defer func() { droppedTimeNow = time.Now }()
+	droppedTimeNow = func() time.Time { return now }
+
+	counter := &countingReporter{calls: map[string]int{}}
+	// logging disabled
+	assert.Same(t, counter, NewDroppedSpansLogger(&DroppedSpansConfig{}, counter))
+
+	reporter := NewDroppedSpansLogger(&DroppedSpansConfig{LogInterval: time.Minute}, counter)
+	dl := reporter.(*droppedSpansLogger)
+	example := &svc.ID{Name: "foo"}
+
+	// the events are always forwarded, but the examples are logged once per interval and reason
+	assert.True(t, dl.shouldLog(DropReasonFiltered))
+	assert.False(t, dl.shouldLog(DropReasonFiltered))
+	assert.True(t, dl.shouldLog(DropReasonNotSampled))
+	reporter.SpansDropped(DropReasonFiltered, 3, example)
+	reporter.SpansDropped(DropReasonDecodeError, 1, nil)
+	assert.Equal(t, 2, counter.calls["SpansDropped"])
+	assert.NotContains(t, dl.lastLog, DropReasonDecodeError)
+
+	now = now.Add(time.Minute)
+	assert.True(t, dl.shouldLog(DropReasonFiltered))
+	assert.False(t, dl.shouldLog(DropReasonFiltered))
+}
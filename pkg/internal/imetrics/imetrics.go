@@ -3,11 +3,72 @@ package imetrics
 
 import (
 	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
 )
 
 // Config options for the different metrics exporters
 type Config struct {
-	Prometheus PrometheusConfig `yaml:"prometheus,omitempty"`
+	Prometheus        PrometheusConfig        `yaml:"prometheus,omitempty"`
+	OTEL              OTELConfig              `yaml:"otel,omitempty"`
+	BPFStats          BPFStatsConfig          `yaml:"bpf_stats,omitempty"`
+	OverheadBenchmark OverheadBenchmarkConfig `yaml:"overhead_benchmark,omitempty"`
+	DroppedSpans      DroppedSpansConfig      `yaml:"dropped_spans,omitempty"`
+}
+
+// DroppedSpansConfig enables logging an example of the spans that the pipeline discards,
+// for each discard reason.
+type DroppedSpansConfig struct {
+	// LogInterval is the minimum time between two logged examples of the same discard reason.
+	// Zero disables logging the examples.
+	LogInterval time.Duration `yaml:"log_interval,omitempty" env:"BEYLA_INTERNAL_METRICS_DROPPED_SPANS_LOG_INTERVAL"`
+}
+
+// Reasons for the pipeline to discard a span, as reported by Reporter.SpansDropped
+const (
+	// DropReasonDecodeError: the event from the eBPF tracer could not be read
+	DropReasonDecodeError = "decode_error"
+	// DropReasonInvalid: the event from the eBPF tracer was read, but its contents are not valid
+	DropReasonInvalid = "invalid"
+	// DropReasonFiltered: the span does not match the attribute filters
+	DropReasonFiltered = "attribute_filter"
+	// DropReasonDuplicate: the span was also captured by another tracer or at another network interface
+	DropReasonDuplicate = "duplicate"
+	// DropReasonProxyHop: the span belongs to a connection between a service mesh sidecar and its application
+	DropReasonProxyHop = "proxy_hop"
+	// DropReasonIgnored: the span is ignored for the traces, e.g. because of the routes configuration
+	DropReasonIgnored = "ignored"
+	// DropReasonNotSampled: the traces sampler discarded the span
+	DropReasonNotSampled = "not_sampled"
+	// DropReasonQueueFull: the queue of the traces exporter is full
+	DropReasonQueueFull = "queue_full"
+	// DropReasonEndpointUnreachable: the traces endpoint has been unreachable since startup
+	DropReasonEndpointUnreachable = "endpoint_unreachable"
+)
+
+// BPFStatsConfig enables the report of the CPU usage of the eBPF programs loaded by Beyla.
+// It requires enabling the kernel statistics of all the eBPF programs in the host, which
+// might add a small overhead, so it is disabled by default.
+type BPFStatsConfig struct {
+	Enable   bool          `yaml:"enable,omitempty" env:"BEYLA_INTERNAL_METRICS_BPF_STATS_ENABLE"`
+	Interval time.Duration `yaml:"interval,omitempty" env:"BEYLA_INTERNAL_METRICS_BPF_STATS_INTERVAL"`
+}
+
+// OverheadBenchmarkConfig enables a self-benchmark that periodically measures the latency of the
+// requests towards a built-in echo server, with and without Beyla instrumenting them.
+type OverheadBenchmarkConfig struct {
+	Enable   bool          `yaml:"enable,omitempty" env:"BEYLA_INTERNAL_METRICS_OVERHEAD_BENCHMARK_ENABLE"`
+	Interval time.Duration `yaml:"interval,omitempty" env:"BEYLA_INTERNAL_METRICS_OVERHEAD_BENCHMARK_INTERVAL"`
+	// Requests that are sent to the echo server on each measurement
+	Requests int `yaml:"requests,omitempty" env:"BEYLA_INTERNAL_METRICS_OVERHEAD_BENCHMARK_REQUESTS"`
+}
+
+// OTELConfig enables the export of the internal metrics through OpenTelemetry. It uses the
+// endpoint and interval from the global OTEL metrics export configuration.
+type OTELConfig struct {
+	Enable bool `yaml:"enable,omitempty" env:"BEYLA_INTERNAL_METRICS_OTEL_ENABLE"`
 }
 
 // Reporter of internal metrics
@@ -16,33 +77,85 @@ type Reporter interface {
 	Start(ctx context.Context)
 	// TracerFlush is invoked every time the eBPF tracer flushes a group of len traces.
 	TracerFlush(len int)
+	// RingbufReaderStall is invoked every time an eBPF ring buffer reader is found stalled
+	// with pending events, and it is flushed to read them.
+	RingbufReaderStall()
+	// BPFProgramRun accounts the CPU time and the number of runs of an eBPF program since the
+	// last invocation. The tracer argument is the Beyla component that loaded the program.
+	BPFProgramRun(tracer, program string, runTime time.Duration, runs uint64)
+	// BPFMapFull is invoked every time that an eBPF map that tracks the in-flight requests
+	// is found at its maximum capacity, so its least recently used entries are being evicted.
+	BPFMapFull(tracer, bpfMap string)
+	// OverheadBenchmark is invoked after each round of the overhead self-benchmark, with the median
+	// latency of the benchmark requests without and with Beyla instrumenting them.
+	OverheadBenchmark(uninstrumented, instrumented time.Duration)
 	// OTELMetricExport is invoked every time the OpenTelemetry Metrics exporter successfully exports metrics to
 	// a remote collector. It accounts the length, in metrics, for each invocation.
 	OTELMetricExport(len int)
-	// OTELMetricExportError is invoked every time the OpenTelemetry Metrics export fails with an error
-	OTELMetricExportError(err error)
+	// OTELMetricExportError is invoked every time the OpenTelemetry Metrics export fails with an error.
+	// It accounts the length, in metrics, of the failed batch.
+	OTELMetricExportError(err error, len int)
 	// OTELTraceExport is invoked every time the OpenTelemetry Traces exporter successfully exports traces to
 	// a remote collector. It accounts the length, in traces, for each invocation.
 	OTELTraceExport(i int)
-	// OTELTraceExportError is invoked every time the OpenTelemetry Traces export fails with an error
-	OTELTraceExportError(err error)
+	// OTELTraceExportError is invoked every time the OpenTelemetry Traces export fails with an error.
+	// The traceID argument identifies a trace from the failed batch, or it is empty if unknown.
+	OTELTraceExportError(err error, traceID string)
+	// OTELExportRejected is invoked every time an OTLP endpoint partially accepts an export. It accounts the
+	// number of items that the endpoint rejected: spans for the traces signal, or data points for the metrics signal.
+	OTELExportRejected(signal, endpoint string, rejected int)
+	// OTELTracesDegraded is invoked when the traces exporter starts discarding the traces, because the
+	// traces endpoint has been unreachable since startup for longer than the grace period, and when
+	// the endpoint becomes reachable and the traces exporter resumes the export.
+	OTELTracesDegraded(degraded bool)
 	// PrometheusRequest is invoked every time the Prometheus exporter is invoked, for a given port and path
 	PrometheusRequest(port, path string)
-	// InstrumentProcess is invoked every time a new process is instrumented
-	InstrumentProcess(processName string)
+	// InstrumentProcess is invoked every time a new process is instrumented, for the service that it belongs to
+	InstrumentProcess(service *svc.ID)
 	// UninstrumentProcess is invoked every time a process is removed from the instrumented processed
-	UninstrumentProcess(processName string)
+	UninstrumentProcess(service *svc.ID)
+	// ServiceSilenced is invoked when an instrumented process stops producing spans for longer than
+	// the silence detection timeout, while the process is still alive
+	ServiceSilenced(service *svc.ID)
+	// ServiceResumed is invoked when a silenced process produces spans again, or when it ends
+	ServiceResumed(service *svc.ID)
+	// SpansDropped is invoked every time the pipeline discards count spans for the given reason
+	// (see the DropReason* constants). The example describes one of the discarded spans, and it
+	// is only used for logging, so it can be nil.
+	SpansDropped(reason string, count int, example fmt.Stringer)
+	// UnknownProtocolTraffic accounts the bytes of the TCP requests and responses towards the given
+	// server port that the generic tracer captured, but whose application protocol couldn't be detected.
+	// The port 0 accounts the traffic towards the server ports that exceed the cardinality limit.
+	UnknownProtocolTraffic(serverPort int, bytes uint64)
+	// HealthCheckRequests accounts the HTTP server requests of a service to the given health-check path,
+	// which are discarded before being converted into spans
+	HealthCheckRequests(service *svc.ID, path string, count int)
+	// HTTP2StreamResets accounts the HTTP/2 streams that were abruptly terminated by a RST_STREAM frame
+	// with the given error code (e.g. CANCEL), sent by the client or the server side of the stream
+	HTTP2StreamResets(errorCode, sender string, count int)
 }
 
 // NoopReporter is a metrics Reporter that just does nothing
 type NoopReporter struct{}
 
-func (n NoopReporter) Start(_ context.Context)       {}
-func (n NoopReporter) TracerFlush(_ int)             {}
-func (n NoopReporter) OTELMetricExport(_ int)        {}
-func (n NoopReporter) OTELMetricExportError(_ error) {}
-func (n NoopReporter) OTELTraceExport(_ int)         {}
-func (n NoopReporter) OTELTraceExportError(_ error)  {}
-func (n NoopReporter) PrometheusRequest(_, _ string) {}
-func (n NoopReporter) InstrumentProcess(_ string)    {}
-func (n NoopReporter) UninstrumentProcess(_ string)  {}
+func (n NoopReporter) Start(_ context.Context)                              {}
+func (n NoopReporter) TracerFlush(_ int)                                    {}
+func (n NoopReporter) RingbufReaderStall()                                  {}
+func (n NoopReporter) BPFProgramRun(_, _ string, _ time.Duration, _ uint64) {}
+func (n NoopReporter) BPFMapFull(_, _ string)                               {}
+func (n NoopReporter) OverheadBenchmark(_, _ time.Duration)                 {}
+func (n NoopReporter) OTELMetricExport(_ int)                               {}
+func (n NoopReporter) OTELMetricExportError(_ error, _ int)                 {}
+func (n NoopReporter) OTELTraceExport(_ int)                                {}
+func (n NoopReporter) OTELTraceExportError(_ error, _ string)               {}
+func (n NoopReporter) OTELExportRejected(_, _ string, _ int)                {}
+func (n NoopReporter) OTELTracesDegraded(_ bool)                            {}
+func (n NoopReporter) PrometheusRequest(_, _ string)                        {}
+func (n NoopReporter) InstrumentProcess(_ *svc.ID)                          {}
+func (n NoopReporter) UninstrumentProcess(_ *svc.ID)                        {}
+func (n NoopReporter) ServiceSilenced(_ *svc.ID)                            {}
+func (n NoopReporter) ServiceResumed(_ *svc.ID)                             {}
+func (n NoopReporter) SpansDropped(_ string, _ int, _ fmt.Stringer)         {}
+func (n NoopReporter) UnknownProtocolTraffic(_ int, _ uint64)               {}
+func (n NoopReporter) HealthCheckRequests(_ *svc.ID, _ string, _ int)       {}
+func (n NoopReporter) HTTP2StreamResets(_, _ string, _ int)                 {}
@@ -0,0 +1,146 @@
+package imetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// invokeReporterMethods invokes all the methods of the Reporter interface but Start,
+// calling the check function after each invocation
+func invokeReporterMethods(t *testing.T, r Reporter, check func(method string)) {
+	rt := reflect.TypeOf((*Reporter)(nil)).Elem()
+	rv := reflect.ValueOf(r)
+	for i := 0; i < rt.NumMethod(); i++ {
+		method := rt.Method(i)
+		if method.Name == "Start" {
+			continue
+		}
+		var args []reflect.Value
+		for a := 0; a < method.Type.NumIn(); a++ {
+			switch in := method.Type.In(a); in.Kind() {
+			case reflect.Int, reflect.Int64, reflect.Uint64:
+				// also covers time.Duration arguments
+				args = append(args, reflect.ValueOf(3).Convert(in))
+			case reflect.String:
+				args = append(args, reflect.ValueOf("foo"))
+			case reflect.Bool:
+				args = append(args, reflect.ValueOf(true))
+			default:
+				switch in {
+				case reflect.TypeOf((*error)(nil)).Elem():
+					args = append(args, reflect.ValueOf(errors.New("failed")))
+				case reflect.TypeOf((*fmt.Stringer)(nil)).Elem():
+					args = append(args, reflect.ValueOf(&svc.ID{Name: "foo"}))
+				case reflect.TypeOf(&svc.ID{}):
+					args = append(args, reflect.ValueOf(&svc.ID{Name: "foo", SDKLanguage: svc.InstrumentableGolang}))
+				default:
+					require.Failf(t, "unsupported argument type", "%s in method %s", in, method.Name)
+				}
+			}
+		}
+		rv.MethodByName(method.Name).Call(args)
+		check(method.Name)
+	}
+}
+
+// promSnapshot returns a textual representation of the values of all the gathered metrics
+func promSnapshot(t *testing.T, registry *prometheus.Registry) string {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	sb := strings.Builder{}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			fmt.Fprintf(&sb, "%s %v %v %v %d\n", mf.GetName(), m.GetLabel(),
+				m.GetCounter().GetValue(), m.GetGauge().GetValue(), m.GetHistogram().GetSampleCount())
+		}
+	}
+	return sb.String()
+}
+
+// TestPrometheusReporter_Coverage verifies that every method of the Reporter interface
+// is reflected in the exported Prometheus metrics
+func TestPrometheusReporter_Coverage(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	pr := NewPrometheusReporter(&PrometheusConfig{}, nil, registry)
+	last := promSnapshot(t, registry)
+	invokeReporterMethods(t, pr, func(method string) {
+		current := promSnapshot(t, registry)
+		assert.NotEqual(t, last, current, "method %s did not update any metric", method)
+		last = current
+	})
+}
+
+type countingReporter struct {
+	NoopReporter
+	calls map[string]int
+}
+
+func (c *countingReporter) Start(_ context.Context) { c.calls["Start"]++ }
+func (c *countingReporter) TracerFlush(_ int)       { c.calls["TracerFlush"]++ }
+func (c *countingReporter) RingbufReaderStall()     { c.calls["RingbufReaderStall"]++ }
+func (c *countingReporter) BPFProgramRun(_, _ string, _ time.Duration, _ uint64) {
+	c.calls["BPFProgramRun"]++
+}
+func (c *countingReporter) BPFMapFull(_, _ string) { c.calls["BPFMapFull"]++ }
+func (c *countingReporter) OverheadBenchmark(_, _ time.Duration) {
+	c.calls["OverheadBenchmark"]++
+}
+func (c *countingReporter) OTELMetricExport(_ int) { c.calls["OTELMetricExport"]++ }
+func (c *countingReporter) OTELMetricExportError(_ error, _ int) {
+	c.calls["OTELMetricExportError"]++
+}
+func (c *countingReporter) OTELTraceExport(_ int) { c.calls["OTELTraceExport"]++ }
+func (c *countingReporter) OTELTraceExportError(_ error, _ string) {
+	c.calls["OTELTraceExportError"]++
+}
+func (c *countingReporter) OTELExportRejected(_, _ string, _ int) {
+	c.calls["OTELExportRejected"]++
+}
+func (c *countingReporter) OTELTracesDegraded(_ bool)     { c.calls["OTELTracesDegraded"]++ }
+func (c *countingReporter) PrometheusRequest(_, _ string) { c.calls["PrometheusRequest"]++ }
+func (c *countingReporter) InstrumentProcess(_ *svc.ID)   { c.calls["InstrumentProcess"]++ }
+func (c *countingReporter) UninstrumentProcess(_ *svc.ID) { c.calls["UninstrumentProcess"]++ }
+func (c *countingReporter) ServiceSilenced(_ *svc.ID)     { c.calls["ServiceSilenced"]++ }
+func (c *countingReporter) ServiceResumed(_ *svc.ID)      { c.calls["ServiceResumed"]++ }
+func (c *countingReporter) SpansDropped(_ string, _ int, _ fmt.Stringer) {
+	c.calls["SpansDropped"]++
+}
+func (c *countingReporter) UnknownProtocolTraffic(_ int, _ uint64) {
+	c.calls["UnknownProtocolTraffic"]++
+}
+
+func (c *countingReporter) HealthCheckRequests(_ *svc.ID, _ string, _ int) {
+	c.calls["HealthCheckRequests"]++
+}
+
+func (c *countingReporter) HTTP2StreamResets(_, _ string, _ int) {
+	c.calls["HTTP2StreamResets"]++
+}
+
+func TestMultiReporter(t *testing.T) {
+	assert.Equal(t, NoopReporter{}, NewMultiReporter())
+	single := &countingReporter{calls: map[string]int{}}
+	assert.Same(t, single, NewMultiReporter(single))
+
+	r1 := &countingReporter{calls: map[string]int{}}
+	r2 := &countingReporter{calls: map[string]int{}}
+	multi := NewMultiReporter(r1, r2)
+	multi.Start(context.Background())
+	invokeReporterMethods(t, multi, func(method string) {
+		assert.Equal(t, 1, r1.calls[method], "method %s not forwarded", method)
+		assert.Equal(t, 1, r2.calls[method], "method %s not forwarded", method)
+	})
+	assert.Equal(t, 1, r1.calls["Start"])
+	assert.Equal(t, 1, r2.calls["Start"])
+}
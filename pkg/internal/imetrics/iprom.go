@@ -2,13 +2,18 @@ package imetrics
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"runtime"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/grafana/beyla/pkg/buildinfo"
 	"github.com/grafana/beyla/pkg/internal/connector"
+	"github.com/grafana/beyla/pkg/internal/svc"
 )
 
 // pipelineBufferLengths buckets for histogram metrics about the number of traces submitted from one stage to another
@@ -25,15 +30,33 @@ type PrometheusConfig struct {
 type PrometheusReporter struct {
 	connector             *connector.PrometheusManager
 	tracerFlushes         prometheus.Histogram
+	ringbufReaderStalls   prometheus.Counter
+	bpfProgramRunTime     *prometheus.CounterVec
+	bpfProgramRuns        *prometheus.CounterVec
+	bpfMapFull            *prometheus.CounterVec
+	overheadBenchmark     *prometheus.GaugeVec
 	otelMetricExports     prometheus.Counter
 	otelMetricExportErrs  *prometheus.CounterVec
 	otelTraceExports      prometheus.Counter
 	otelTraceExportErrs   *prometheus.CounterVec
+	otelExportRejected    *prometheus.CounterVec
+	otelTracesDegraded    prometheus.Gauge
 	prometheusRequests    *prometheus.CounterVec
 	instrumentedProcesses *prometheus.GaugeVec
+	silentProcesses       *prometheus.GaugeVec
+	spansDropped          *prometheus.CounterVec
+	unknownProtocolBytes  *prometheus.CounterVec
+	healthCheckRequests   *prometheus.CounterVec
+	http2StreamResets     *prometheus.CounterVec
 	beylaInfo             prometheus.Gauge
+
+	tracesDegraded atomic.Bool
 }
 
+// ReadinessPath is served in the port of the internal metrics Prometheus endpoint, and reports
+// whether Beyla runs in a degraded mode
+const ReadinessPath = "/readyz"
+
 func NewPrometheusReporter(cfg *PrometheusConfig, manager *connector.PrometheusManager, registry *prometheus.Registry) *PrometheusReporter {
 	pr := &PrometheusReporter{
 		connector: manager,
@@ -45,6 +68,26 @@ func NewPrometheusReporter(cfg *PrometheusConfig, manager *connector.PrometheusM
 			NativeHistogramMaxBucketNumber:  100,
 			NativeHistogramMinResetDuration: 1 * time.Hour,
 		}),
+		ringbufReaderStalls: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "beyla_ebpf_ringbuf_reader_stalls_total",
+			Help: "Times that an eBPF ring buffer reader was found stalled with pending events and flushed",
+		}),
+		bpfProgramRunTime: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "beyla_bpf_program_run_seconds_total",
+			Help: "CPU time spent in the kernel by each eBPF program loaded by Beyla",
+		}, []string{"tracer", "program"}),
+		bpfProgramRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "beyla_bpf_program_runs_total",
+			Help: "Number of times that each eBPF program loaded by Beyla has been run",
+		}, []string{"tracer", "program"}),
+		bpfMapFull: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "beyla_bpf_map_full_total",
+			Help: "Times that an eBPF map tracking in-flight requests was found full, evicting its oldest entries",
+		}, []string{"tracer", "map"}),
+		overheadBenchmark: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "beyla_overhead_benchmark_request_duration_seconds",
+			Help: "Median latency of the overhead self-benchmark requests, with and without Beyla instrumenting them",
+		}, []string{"instrumented"}),
 		otelMetricExports: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "beyla_otel_metric_exports_total",
 			Help: "Length of the metric batches submitted to the remote OTEL collector",
@@ -61,14 +104,42 @@ func NewPrometheusReporter(cfg *PrometheusConfig, manager *connector.PrometheusM
 			Name: "beyla_otel_trace_export_errors_total",
 			Help: "Error count on each failed OTEL trace export",
 		}, []string{"error"}),
+		otelExportRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "beyla_otel_export_rejected_items_total",
+			Help: "Spans or metric data points rejected by the remote OTEL collector in partially successful exports",
+		}, []string{"signal", "endpoint"}),
+		otelTracesDegraded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "beyla_otel_traces_degraded",
+			Help: "1 if the traces are discarded because the traces endpoint has been unreachable since startup, 0 otherwise",
+		}),
 		prometheusRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "beyla_prometheus_http_requests_total",
 			Help: "Requests towards the Prometheus Scrape endpoint",
 		}, []string{"port", "path"}),
 		instrumentedProcesses: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "beyla_instrumented_processes",
-			Help: "Instrumented processes by Beyla",
-		}, []string{"process_name"}),
+			Help: "Instrumented processes by Beyla, by service",
+		}, []string{"service_name", "service_namespace", "language"}),
+		silentProcesses: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "beyla_silent_processes",
+			Help: "Instrumented processes that stopped producing spans while they are still alive, by service",
+		}, []string{"service_name", "service_namespace", "language"}),
+		spansDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "beyla_dropped_spans_total",
+			Help: "Spans discarded by the Beyla pipeline before being exported, by reason",
+		}, []string{"reason"}),
+		unknownProtocolBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "beyla_unknown_protocol_bytes_total",
+			Help: "Bytes of the TCP traffic whose application protocol couldn't be detected, by server port",
+		}, []string{"server_port"}),
+		healthCheckRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "beyla_health_check_requests_total",
+			Help: "HTTP server requests to the configured health-check paths, which are not converted into spans, by service and path",
+		}, []string{"service_name", "service_namespace", "path"}),
+		http2StreamResets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "beyla_http2_stream_resets_total",
+			Help: "HTTP/2 streams abruptly terminated by a RST_STREAM frame, by error code and sender",
+		}, []string{"error_code", "sender"}),
 		beylaInfo: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "beyla_internal_build_info",
 			Help: "A metric with a constant '1' value labeled by version, revision, branch, " +
@@ -84,23 +155,51 @@ func NewPrometheusReporter(cfg *PrometheusConfig, manager *connector.PrometheusM
 	}
 	if registry != nil {
 		registry.MustRegister(pr.tracerFlushes,
+			pr.ringbufReaderStalls,
+			pr.bpfProgramRunTime,
+			pr.bpfProgramRuns,
+			pr.bpfMapFull,
+			pr.overheadBenchmark,
 			pr.otelMetricExports,
 			pr.otelMetricExportErrs,
 			pr.otelTraceExports,
 			pr.otelTraceExportErrs,
+			pr.otelExportRejected,
+			pr.otelTracesDegraded,
 			pr.prometheusRequests,
 			pr.instrumentedProcesses,
+			pr.silentProcesses,
+			pr.spansDropped,
+			pr.unknownProtocolBytes,
+			pr.healthCheckRequests,
+			pr.http2StreamResets,
 			pr.beylaInfo)
 	} else {
 		manager.Register(cfg.Port, cfg.Path,
 			pr.tracerFlushes,
+			pr.ringbufReaderStalls,
+			pr.bpfProgramRunTime,
+			pr.bpfProgramRuns,
+			pr.bpfMapFull,
+			pr.overheadBenchmark,
 			pr.otelMetricExports,
 			pr.otelMetricExportErrs,
 			pr.otelTraceExports,
 			pr.otelTraceExportErrs,
+			pr.otelExportRejected,
+			pr.otelTracesDegraded,
 			pr.prometheusRequests,
 			pr.instrumentedProcesses,
+			pr.silentProcesses,
+			pr.spansDropped,
+			pr.unknownProtocolBytes,
+			pr.healthCheckRequests,
+			pr.http2StreamResets,
 			pr.beylaInfo)
+		// only the internal metrics path is served in OpenMetrics format, as the export errors
+		// have exemplars
+		manager.ServeOpenMetrics(cfg.Port, cfg.Path)
+		manager.RegisterHandler(cfg.Port, ReadinessPath, http.HandlerFunc(pr.serveReadiness))
 	}
 
 	return pr
@@ -117,30 +216,118 @@ func (p *PrometheusReporter) TracerFlush(len int) {
 	p.tracerFlushes.Observe(float64(len))
 }
 
+func (p *PrometheusReporter) RingbufReaderStall() {
+	p.ringbufReaderStalls.Inc()
+}
+
+func (p *PrometheusReporter) BPFProgramRun(tracer, program string, runTime time.Duration, runs uint64) {
+	p.bpfProgramRunTime.WithLabelValues(tracer, program).Add(runTime.Seconds())
+	p.bpfProgramRuns.WithLabelValues(tracer, program).Add(float64(runs))
+}
+
+func (p *PrometheusReporter) BPFMapFull(tracer, bpfMap string) {
+	p.bpfMapFull.WithLabelValues(tracer, bpfMap).Inc()
+}
+
+func (p *PrometheusReporter) OverheadBenchmark(uninstrumented, instrumented time.Duration) {
+	p.overheadBenchmark.WithLabelValues("false").Set(uninstrumented.Seconds())
+	p.overheadBenchmark.WithLabelValues("true").Set(instrumented.Seconds())
+}
+
 func (p *PrometheusReporter) OTELMetricExport(len int) {
 	p.otelMetricExports.Add(float64(len))
 }
 
-func (p *PrometheusReporter) OTELMetricExportError(err error) {
-	p.otelMetricExportErrs.WithLabelValues(err.Error()).Inc()
+// OTELMetricExportError attaches an exemplar with the size of the failed batch, which is
+// exposed when the internal metrics endpoint is scraped with the OpenMetrics format.
+func (p *PrometheusReporter) OTELMetricExportError(err error, len int) {
+	addWithExemplar(p.otelMetricExportErrs.WithLabelValues(err.Error()),
+		prometheus.Labels{"batch_size": strconv.Itoa(len)})
 }
 
 func (p *PrometheusReporter) OTELTraceExport(len int) {
 	p.otelTraceExports.Add(float64(len))
 }
 
-func (p *PrometheusReporter) OTELTraceExportError(err error) {
-	p.otelTraceExportErrs.WithLabelValues(err.Error()).Inc()
+// OTELTraceExportError attaches an exemplar pointing to a trace from the failed batch, which is
+// exposed when the internal metrics endpoint is scraped with the OpenMetrics format.
+func (p *PrometheusReporter) OTELTraceExportError(err error, traceID string) {
+	var exemplar prometheus.Labels
+	if traceID != "" {
+		exemplar = prometheus.Labels{"trace_id": traceID}
+	}
+	addWithExemplar(p.otelTraceExportErrs.WithLabelValues(err.Error()), exemplar)
+}
+
+func (p *PrometheusReporter) OTELExportRejected(signal, endpoint string, rejected int) {
+	p.otelExportRejected.WithLabelValues(signal, endpoint).Add(float64(rejected))
+}
+
+func (p *PrometheusReporter) OTELTracesDegraded(degraded bool) {
+	p.tracesDegraded.Store(degraded)
+	if degraded {
+		p.otelTracesDegraded.Set(1)
+	} else {
+		p.otelTracesDegraded.Set(0)
+	}
+}
+
+// serveReadiness reports whether Beyla runs with all its exporters, or in a degraded mode
+func (p *PrometheusReporter) serveReadiness(rw http.ResponseWriter, _ *http.Request) {
+	if p.tracesDegraded.Load() {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = rw.Write([]byte("degraded: the traces endpoint is unreachable. Exporting only metrics\n"))
+		return
+	}
+	_, _ = rw.Write([]byte("ready\n"))
 }
 
 func (p *PrometheusReporter) PrometheusRequest(port, path string) {
 	p.prometheusRequests.WithLabelValues(port, path).Inc()
 }
 
-func (p *PrometheusReporter) InstrumentProcess(processName string) {
-	p.instrumentedProcesses.WithLabelValues(processName).Inc()
+func (p *PrometheusReporter) InstrumentProcess(service *svc.ID) {
+	p.instrumentedProcesses.WithLabelValues(
+		service.Name, service.Namespace, service.SDKLanguage.String()).Inc()
+}
+
+func (p *PrometheusReporter) UninstrumentProcess(service *svc.ID) {
+	p.instrumentedProcesses.WithLabelValues(
+		service.Name, service.Namespace, service.SDKLanguage.String()).Dec()
+}
+
+func (p *PrometheusReporter) ServiceSilenced(service *svc.ID) {
+	p.silentProcesses.WithLabelValues(
+		service.Name, service.Namespace, service.SDKLanguage.String()).Inc()
+}
+
+func (p *PrometheusReporter) ServiceResumed(service *svc.ID) {
+	p.silentProcesses.WithLabelValues(
+		service.Name, service.Namespace, service.SDKLanguage.String()).Dec()
 }
 
-func (p *PrometheusReporter) UninstrumentProcess(processName string) {
-	p.instrumentedProcesses.WithLabelValues(processName).Dec()
+func (p *PrometheusReporter) SpansDropped(reason string, count int, _ fmt.Stringer) {
+	p.spansDropped.WithLabelValues(reason).Add(float64(count))
+}
+
+func (p *PrometheusReporter) UnknownProtocolTraffic(serverPort int, bytes uint64) {
+	p.unknownProtocolBytes.WithLabelValues(strconv.Itoa(serverPort)).Add(float64(bytes))
+}
+
+func (p *PrometheusReporter) HealthCheckRequests(service *svc.ID, path string, count int) {
+	p.healthCheckRequests.WithLabelValues(service.Name, service.Namespace, path).Add(float64(count))
+}
+
+func (p *PrometheusReporter) HTTP2StreamResets(errorCode, sender string, count int) {
+	p.http2StreamResets.WithLabelValues(errorCode, sender).Add(float64(count))
+}
+
+// addWithExemplar increments the counter by one, attaching the passed exemplar labels
+// if they are not empty
+func addWithExemplar(counter prometheus.Counter, exemplar prometheus.Labels) {
+	if ea, ok := counter.(prometheus.ExemplarAdder); ok && len(exemplar) > 0 {
+		ea.AddWithExemplar(1, exemplar)
+		return
+	}
+	counter.Inc()
 }
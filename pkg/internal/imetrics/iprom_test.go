@@ -0,0 +1,115 @@
+package imetrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestExportErrorExemplars(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	pr := NewPrometheusReporter(&PrometheusConfig{}, nil, registry)
+
+	pr.OTELTraceExportError(errors.New("connection refused"), "0102030405060708090a0b0c0d0e0f10")
+	pr.OTELMetricExportError(errors.New("connection refused"), 123)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	exemplars := map[string]map[string]string{}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			if m.GetCounter() == nil || m.GetCounter().GetExemplar() == nil {
+				continue
+			}
+			labels := map[string]string{}
+			for _, l := range m.GetCounter().GetExemplar().GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			exemplars[mf.GetName()] = labels
+		}
+	}
+	assert.Equal(t, map[string]map[string]string{
+		"beyla_otel_trace_export_errors_total":  {"trace_id": "0102030405060708090a0b0c0d0e0f10"},
+		"beyla_otel_metric_export_errors_total": {"batch_size": "123"},
+	}, exemplars)
+}
+
+func TestExportError_NoTraceID(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	pr := NewPrometheusReporter(&PrometheusConfig{}, nil, registry)
+
+	pr.OTELTraceExportError(errors.New("timeout"), "")
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != "beyla_otel_trace_export_errors_total" {
+			continue
+		}
+		require.Len(t, mf.GetMetric(), 1)
+		assert.EqualValues(t, 1, mf.GetMetric()[0].GetCounter().GetValue())
+		assert.Nil(t, mf.GetMetric()[0].GetCounter().GetExemplar())
+		return
+	}
+	t.Fatal("beyla_otel_trace_export_errors_total not found")
+}
+
+func TestInstrumentedProcesses(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	pr := NewPrometheusReporter(&PrometheusConfig{}, nil, registry)
+
+	checkout := svc.ID{Name: "checkout", Namespace: "shop", SDKLanguage: svc.InstrumentableJava}
+	frontend := svc.ID{Name: "frontend", Namespace: "shop", SDKLanguage: svc.InstrumentableNodejs}
+	pr.InstrumentProcess(&checkout)
+	pr.InstrumentProcess(&checkout)
+	pr.InstrumentProcess(&frontend)
+	pr.UninstrumentProcess(&frontend)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	processes := map[string]float64{}
+	for _, mf := range families {
+		if mf.GetName() != "beyla_instrumented_processes" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := ""
+			for _, l := range m.GetLabel() {
+				labels += l.GetName() + "=" + l.GetValue() + ","
+			}
+			processes[labels] = m.GetGauge().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"language=java,service_name=checkout,service_namespace=shop,":   2,
+		"language=nodejs,service_name=frontend,service_namespace=shop,": 0,
+	}, processes)
+}
+
+func TestReadiness(t *testing.T) {
+	pr := NewPrometheusReporter(&PrometheusConfig{}, nil, prometheus.NewRegistry())
+
+	rw := httptest.NewRecorder()
+	pr.serveReadiness(rw, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "ready\n", rw.Body.String())
+
+	pr.OTELTracesDegraded(true)
+	rw = httptest.NewRecorder()
+	pr.serveReadiness(rw, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Contains(t, rw.Body.String(), "degraded")
+
+	pr.OTELTracesDegraded(false)
+	rw = httptest.NewRecorder()
+	pr.serveReadiness(rw, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "ready\n", rw.Body.String())
+}
@@ -0,0 +1,151 @@
+package imetrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// MultiReporter forwards the internal metrics events to multiple reporters,
+// so the same metrics can be exported e.g. through Prometheus and OpenTelemetry.
+type MultiReporter []Reporter
+
+// NewMultiReporter returns a Reporter that forwards the events to all the passed reporters.
+// If no reporters are passed, it returns a NoopReporter.
+func NewMultiReporter(reporters ...Reporter) Reporter {
+	switch len(reporters) {
+	case 0:
+		return NoopReporter{}
+	case 1:
+		return reporters[0]
+	}
+	return MultiReporter(reporters)
+}
+
+func (mr MultiReporter) Start(ctx context.Context) {
+	for _, r := range mr {
+		r.Start(ctx)
+	}
+}
+
+func (mr MultiReporter) TracerFlush(len int) {
+	for _, r := range mr {
+		r.TracerFlush(len)
+	}
+}
+
+func (mr MultiReporter) RingbufReaderStall() {
+	for _, r := range mr {
+		r.RingbufReaderStall()
+	}
+}
+
+func (mr MultiReporter) BPFProgramRun(tracer, program string, runTime time.Duration, runs uint64) {
+	for _, r := range mr {
+		r.BPFProgramRun(tracer, program, runTime, runs)
+	}
+}
+
+func (mr MultiReporter) BPFMapFull(tracer, bpfMap string) {
+	for _, r := range mr {
+		r.BPFMapFull(tracer, bpfMap)
+	}
+}
+
+func (mr MultiReporter) OverheadBenchmark(uninstrumented, instrumented time.Duration) {
+	for _, r := range mr {
+		r.OverheadBenchmark(uninstrumented, instrumented)
+	}
+}
+
+func (mr MultiReporter) OTELMetricExport(len int) {
+	for _, r := range mr {
+		r.OTELMetricExport(len)
+	}
+}
+
+func (mr MultiReporter) OTELMetricExportError(err error, len int) {
+	for _, r := range mr {
+		r.OTELMetricExportError(err, len)
+	}
+}
+
+func (mr MultiReporter) OTELTraceExport(len int) {
+	for _, r := range mr {
+		r.OTELTraceExport(len)
+	}
+}
+
+func (mr MultiReporter) OTELTraceExportError(err error, traceID string) {
+	for _, r := range mr {
+		r.OTELTraceExportError(err, traceID)
+	}
+}
+
+func (mr MultiReporter) OTELExportRejected(signal, endpoint string, rejected int) {
+	for _, r := range mr {
+		r.OTELExportRejected(signal, endpoint, rejected)
+	}
+}
+
+func (mr MultiReporter) OTELTracesDegraded(degraded bool) {
+	for _, r := range mr {
+		r.OTELTracesDegraded(degraded)
+	}
+}
+
+func (mr MultiReporter) PrometheusRequest(port, path string) {
+	for _, r := range mr {
+		r.PrometheusRequest(port, path)
+	}
+}
+
+func (mr MultiReporter) InstrumentProcess(service *svc.ID) {
+	for _, r := range mr {
+		r.InstrumentProcess(service)
+	}
+}
+
+func (mr MultiReporter) UninstrumentProcess(service *svc.ID) {
+	for _, r := range mr {
+		r.UninstrumentProcess(service)
+	}
+}
+
+func (mr MultiReporter) ServiceSilenced(service *svc.ID) {
+	for _, r := range mr {
+		r.ServiceSilenced(service)
+	}
+}
+
+func (mr MultiReporter) ServiceResumed(service *svc.ID) {
+	for _, r := range mr {
+		r.ServiceResumed(service)
+	}
+}
+
+func (mr MultiReporter) SpansDropped(reason string, count int, example fmt.Stringer) {
+	for _, r := range mr {
+		r.SpansDropped(reason, count, example)
+	}
+}
+
+func (mr MultiReporter) UnknownProtocolTraffic(serverPort int, bytes uint64) {
+	for _, r := range mr {
+		r.UnknownProtocolTraffic(serverPort, bytes)
+	}
+}
+
+func (mr MultiReporter) HealthCheckRequests(service *svc.ID, path string, count int) {
+	for _, r := range mr {
+		r.HealthCheckRequests(service, path, count)
+	}
+}
+
+func (mr MultiReporter) HTTP2StreamResets(errorCode, sender string, count int) {
+	for _, r := range mr {
+		r.HTTP2StreamResets(errorCode, sender, count)
+	}
+}
@@ -0,0 +1,128 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+func llog() *slog.Logger {
+	return slog.With("component", "kube.LeaderElector")
+}
+
+// LeaderElectionConfig allows running several redundant Beyla instances that process the same
+// traffic (e.g. a central decoration/export deployment), electing a single leader through a
+// Kubernetes Lease. Only the leader exports the aggregated service graph metrics, while the
+// other instances keep aggregating them from the traffic that they process, as warm standby
+// replicas. The aggregated state is not handed off between instances.
+type LeaderElectionConfig struct {
+	Enable bool `yaml:"enable" env:"BEYLA_LEADER_ELECTION_ENABLE"`
+	// LeaseName is the name of the Kubernetes Lease object that is used as a lock
+	LeaseName string `yaml:"lease_name" env:"BEYLA_LEADER_ELECTION_LEASE_NAME"`
+	// LeaseNamespace of the Lease object. If empty, it uses the namespace of the Beyla Pod.
+	LeaseNamespace string `yaml:"lease_namespace" env:"BEYLA_LEADER_ELECTION_LEASE_NAMESPACE"`
+	// LeaseDuration is the time that non-leader candidates wait until forcing the acquisition
+	// of the leadership
+	LeaseDuration time.Duration `yaml:"lease_duration" env:"BEYLA_LEADER_ELECTION_LEASE_DURATION"`
+	// RenewDeadline is the time that the leader retries refreshing the leadership before giving it up
+	RenewDeadline time.Duration `yaml:"renew_deadline" env:"BEYLA_LEADER_ELECTION_RENEW_DEADLINE"`
+	// RetryPeriod is the time that the candidates wait between tries of actions
+	RetryPeriod time.Duration `yaml:"retry_period" env:"BEYLA_LEADER_ELECTION_RETRY_PERIOD"`
+}
+
+// LeaderElector keeps track of whether the current Beyla instance is the leader of its group.
+// A nil *LeaderElector means that leader election is disabled, so the instance always
+// considers itself the leader.
+type LeaderElector struct {
+	cfg      *LeaderElectionConfig
+	identity string
+	leader   atomic.Bool
+}
+
+// NewLeaderElector returns nil if the leader election is not enabled in the configuration
+func NewLeaderElector(cfg *LeaderElectionConfig) *LeaderElector {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+	identity, err := os.Hostname()
+	if err != nil || identity == "" {
+		identity = fmt.Sprintf("beyla-%d", os.Getpid())
+	}
+	return &LeaderElector{cfg: cfg, identity: identity}
+}
+
+// IsLeader returns true if the current instance holds the leadership, or if the
+// leader election is disabled.
+func (le *LeaderElector) IsLeader() bool {
+	if le == nil {
+		return true
+	}
+	return le.leader.Load()
+}
+
+// Run the leader election loop until the passed context is cancelled. After losing the
+// leadership, the instance becomes a candidate again. On context cancellation, the lease
+// is released so any standby instance can immediately take over.
+func (le *LeaderElector) Run(ctx context.Context, client kubernetes.Interface) error {
+	log := llog().With("identity", le.identity, "lease", le.cfg.LeaseName)
+	namespace := le.cfg.LeaseNamespace
+	if namespace == "" {
+		nsBytes, err := os.ReadFile(serviceAccountNamespaceFile)
+		if err != nil {
+			return fmt.Errorf("lease namespace not set and can't read it from the service account: %w", err)
+		}
+		namespace = strings.TrimSpace(string(nsBytes))
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      le.cfg.LeaseName,
+			Namespace: namespace,
+		},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: le.identity},
+	}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   le.cfg.LeaseDuration,
+		RenewDeadline:   le.cfg.RenewDeadline,
+		RetryPeriod:     le.cfg.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            le.cfg.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(_ context.Context) {
+				log.Info("acquired leadership. Exporting service graph metrics")
+				le.leader.Store(true)
+			},
+			OnStoppedLeading: func() {
+				log.Info("lost leadership. Running as standby")
+				le.leader.Store(false)
+			},
+			OnNewLeader: func(identity string) {
+				log.Debug("new leader elected", "leader", identity)
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("creating leader elector: %w", err)
+	}
+	go func() {
+		// elector.Run returns when the leadership is lost, so we need to
+		// restart it for the instance to become a candidate again
+		for ctx.Err() == nil {
+			elector.Run(ctx)
+		}
+		log.Debug("leader election stopped")
+	}()
+	return nil
+}
@@ -2,6 +2,7 @@ package kube
 
 import (
 	"log/slog"
+	"net"
 	"slices"
 	"strings"
 	"sync"
@@ -321,6 +322,39 @@ func (s *Store) ServiceNameNamespaceForIP(ip string) (string, string) {
 	return name, namespace
 }
 
+// ServiceNameNamespaceForHost returns the service name and namespace of the Kubernetes Service that is
+// addressed by a host name, with an optional port (e.g. "name", "name.namespace" or
+// "name.namespace.svc.cluster.local:8080"). Host names without namespace are looked up in the
+// defaultNamespace. It is used to name the peers of the clients that don't connect to the IP of
+// the Service, such as the proxyless gRPC clients that balance across the pods of an xds:/// target.
+func (s *Store) ServiceNameNamespaceForHost(host, defaultNamespace string) (string, string) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return "", ""
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	host = strings.TrimSuffix(host, ".cluster.local")
+	host = strings.TrimSuffix(host, ".svc")
+	name, namespace, _ := strings.Cut(host, ".")
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if strings.Contains(namespace, ".") {
+		// not a Kubernetes service name
+		return "", ""
+	}
+
+	s.access.RLock()
+	defer s.access.RUnlock()
+	om, ok := s.objectMetaByQName[qualifiedName{name: name, namespace: namespace, kind: "Service"}]
+	if !ok {
+		return "", ""
+	}
+	return s.serviceNameNamespaceForMetadata(om)
+}
+
 func (s *Store) serviceNameNamespaceForOwner(om *informer.ObjectMeta) (string, string) {
 	ownerKey := ownerID(om.Namespace, om.Name)
 	return s.serviceNameNamespaceOwnerID(ownerKey, om.Name, om.Namespace)
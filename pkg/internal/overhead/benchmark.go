@@ -0,0 +1,159 @@
+// Package overhead provides a self-benchmark that measures the latency that Beyla adds to the
+// requests of the instrumented processes.
+package overhead
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+)
+
+// size of the body of the benchmark requests, which is echoed back in the responses
+const payloadSize = 1024
+
+func blog() *slog.Logger {
+	return slog.With("component", "overhead.Benchmark")
+}
+
+// Instrumenter enables and disables the instrumentation of the Beyla process, so the
+// requests towards the echo server are measured with and without Beyla instrumenting them.
+type Instrumenter interface {
+	// Instrument starts instrumenting the Beyla process. It returns false if it can't be
+	// instrumented yet (e.g. because the required eBPF programs haven't been loaded).
+	Instrument() bool
+	// Uninstrument stops instrumenting the Beyla process.
+	Uninstrument()
+}
+
+// Benchmark periodically sends requests to a built-in echo server, measuring their latency
+// with and without Beyla instrumenting them.
+type Benchmark struct {
+	log     *slog.Logger
+	cfg     *imetrics.OverheadBenchmarkConfig
+	metrics imetrics.Reporter
+	instr   Instrumenter
+	url     string
+	payload []byte
+}
+
+func New(cfg *imetrics.OverheadBenchmarkConfig, metrics imetrics.Reporter, instr Instrumenter) *Benchmark {
+	return &Benchmark{
+		log:     blog(),
+		cfg:     cfg,
+		metrics: metrics,
+		instr:   instr,
+		payload: bytes.Repeat([]byte("beyla"), payloadSize/len("beyla")),
+	}
+}
+
+// Run starts the echo server and the periodic measurements. It returns when the passed
+// context is done.
+func (b *Benchmark) Run(ctx context.Context) {
+	if err := b.startEchoServer(ctx); err != nil {
+		b.log.Warn("can't start the overhead benchmark echo server", "error", err)
+		return
+	}
+	b.log.Debug("starting overhead benchmark", "url", b.url, "interval", b.cfg.Interval)
+
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.round(ctx); err != nil && ctx.Err() == nil {
+				b.log.Warn("overhead benchmark failed", "error", err)
+			}
+		}
+	}
+}
+
+func (b *Benchmark) startEchoServer(ctx context.Context) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	b.url = "http://" + listener.Addr().String() + "/echo"
+	server := http.Server{
+		ReadHeaderTimeout: 5 * time.Second,
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			_, _ = io.Copy(rw, req.Body)
+		}),
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			b.log.Warn("overhead benchmark echo server stopped", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	return nil
+}
+
+// round measures the latency of the benchmark requests without and with Beyla instrumenting them,
+// and reports both values
+func (b *Benchmark) round(ctx context.Context) error {
+	uninstrumented, err := b.measure(ctx)
+	if err != nil {
+		return fmt.Errorf("measuring uninstrumented requests: %w", err)
+	}
+	if !b.instr.Instrument() {
+		b.log.Debug("Beyla can't instrument the overhead benchmark yet. Skipping")
+		return nil
+	}
+	instrumented, err := b.measure(ctx)
+	b.instr.Uninstrument()
+	if err != nil {
+		return fmt.Errorf("measuring instrumented requests: %w", err)
+	}
+
+	b.log.Info("overhead benchmark",
+		"uninstrumented", uninstrumented,
+		"instrumented", instrumented,
+		"overhead", instrumented-uninstrumented)
+	b.metrics.OverheadBenchmark(uninstrumented, instrumented)
+	return nil
+}
+
+// measure returns the median latency of the configured number of requests. Each measurement
+// opens new connections, so Beyla tracks them since their creation.
+func (b *Benchmark) measure(ctx context.Context) (time.Duration, error) {
+	client := http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+
+	latencies := make([]time.Duration, 0, b.cfg.Requests)
+	for i := 0; i < b.cfg.Requests; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewReader(b.payload))
+		if err != nil {
+			return 0, err
+		}
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return 0, err
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	if len(latencies) == 0 {
+		return 0, errors.New("no requests were sent")
+	}
+	slices.Sort(latencies)
+	return latencies[len(latencies)/2], nil
+}
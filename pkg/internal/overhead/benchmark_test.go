@@ -0,0 +1,77 @@
+package overhead
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+)
+
+type fakeInstrumenter struct {
+	loaded       bool
+	instrumented bool
+	rounds       int
+}
+
+func (f *fakeInstrumenter) Instrument() bool {
+	if !f.loaded {
+		return false
+	}
+	f.instrumented = true
+	f.rounds++
+	return true
+}
+
+func (f *fakeInstrumenter) Uninstrument() {
+	f.instrumented = false
+}
+
+type benchmarkReporter struct {
+	imetrics.NoopReporter
+	uninstrumented []time.Duration
+	instrumented   []time.Duration
+}
+
+func (r *benchmarkReporter) OverheadBenchmark(uninstrumented, instrumented time.Duration) {
+	r.uninstrumented = append(r.uninstrumented, uninstrumented)
+	r.instrumented = append(r.instrumented, instrumented)
+}
+
+func TestBenchmarkRound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	instr := &fakeInstrumenter{}
+	reporter := &benchmarkReporter{}
+	b := New(&imetrics.OverheadBenchmarkConfig{Requests: 10}, reporter, instr)
+	require.NoError(t, b.startEchoServer(ctx))
+
+	// the tracer isn't loaded yet, so nothing is reported
+	require.NoError(t, b.round(ctx))
+	assert.Empty(t, reporter.instrumented)
+
+	instr.loaded = true
+	require.NoError(t, b.round(ctx))
+	assert.Equal(t, 1, instr.rounds)
+	assert.False(t, instr.instrumented)
+	require.Len(t, reporter.instrumented, 1)
+	assert.Positive(t, reporter.uninstrumented[0])
+	assert.Positive(t, reporter.instrumented[0])
+}
+
+func TestBenchmarkRound_ServerDown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	instr := &fakeInstrumenter{loaded: true}
+	reporter := &benchmarkReporter{}
+	b := New(&imetrics.OverheadBenchmarkConfig{Requests: 10}, reporter, instr)
+	require.NoError(t, b.startEchoServer(ctx))
+	cancel()
+
+	assert.Error(t, b.round(context.Background()))
+	assert.False(t, instr.instrumented)
+	assert.Empty(t, reporter.instrumented)
+}
@@ -2,6 +2,7 @@ package global
 
 import (
 	"github.com/grafana/beyla/pkg/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/admin"
 	"github.com/grafana/beyla/pkg/internal/connector"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	kube2 "github.com/grafana/beyla/pkg/internal/kube"
@@ -24,8 +25,16 @@ type ContextInfo struct {
 	// MetricAttributeGroups will selectively enable or disable diverse groups of attributes
 	// in the metric exporters
 	MetricAttributeGroups attributes.AttrGroups
+	// MetricRenamer renames the exported metric names and attribute keys. It is nil if
+	// the user did not configure any renaming
+	MetricRenamer *attributes.Renamer
 	// K8sInformer enables direct access to the Kubernetes API
 	K8sInformer *kube2.MetadataProvider
+	// ServiceGraphLeader tells whether this instance must export the service graph metrics.
+	// It is nil (always leader) unless the leader election is enabled.
+	ServiceGraphLeader *kube2.LeaderElector
+	// Admin API to inspect and control the running instance. It is nil if it is disabled
+	Admin *admin.Server
 }
 
 // AppO11y stores context information that is only required for application observability.
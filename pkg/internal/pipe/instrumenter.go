@@ -25,14 +25,26 @@ import (
 type nodesMap struct {
 	TracesReader pipe.Start[[]request.Span]
 
+	// SpanDedup drops the spans of the requests that are captured more than once
+	SpanDedup pipe.Middle[[]request.Span, []request.Span]
+
 	// Routes is an optional pipe. If not enabled, data will be bypassed to the next stage in the pipeline.
 	Routes pipe.Middle[[]request.Span, []request.Span]
 
 	// Kubernetes is an optional pipe. If not enabled, data will be bypassed to the exporters.
 	Kubernetes pipe.Middle[[]request.Span, []request.Span]
 
+	// ServiceMesh holds the spans of the requests forwarded by the sidecars until it finds their real client
+	ServiceMesh pipe.Middle[[]request.Span, []request.Span]
+
 	NameResolver pipe.Middle[[]request.Span, []request.Span]
 
+	// SpanEnricher groups the transformations that modify the spans without holding them
+	SpanEnricher pipe.Middle[[]request.Span, []request.Span]
+
+	// SilenceDetector observes the spans to report the processes that stop sending them
+	SilenceDetector pipe.Middle[[]request.Span, []request.Span]
+
 	AttributeFilter pipe.Middle[[]request.Span, []request.Span]
 
 	AlloyTraces pipe.Final[[]request.Span]
@@ -40,6 +52,7 @@ type nodesMap struct {
 	Traces      pipe.Final[[]request.Span]
 	Prometheus  pipe.Final[[]request.Span]
 	Printer     pipe.Final[[]request.Span]
+	StateDump   pipe.Final[[]request.Span]
 
 	ProcessReport pipe.Final[[]request.Span]
 }
@@ -48,24 +61,33 @@ type nodesMap struct {
 // at build time will be Bypassed (e.g. if the Routes node is disabled, the pipes library
 // will directly connect TracesReader to Kubernetes node).
 func (n *nodesMap) Connect() {
-	n.TracesReader.SendTo(n.Routes)
+	n.TracesReader.SendTo(n.SpanDedup)
+	n.SpanDedup.SendTo(n.Routes)
 	n.Routes.SendTo(n.Kubernetes)
-	n.Kubernetes.SendTo(n.NameResolver)
-	n.NameResolver.SendTo(n.AttributeFilter)
-	n.AttributeFilter.SendTo(n.AlloyTraces, n.Metrics, n.Traces, n.Prometheus, n.Printer, n.ProcessReport)
+	n.Kubernetes.SendTo(n.ServiceMesh)
+	n.ServiceMesh.SendTo(n.NameResolver)
+	n.NameResolver.SendTo(n.SpanEnricher)
+	n.SpanEnricher.SendTo(n.SilenceDetector)
+	n.SilenceDetector.SendTo(n.AttributeFilter)
+	n.AttributeFilter.SendTo(n.AlloyTraces, n.Metrics, n.Traces, n.Prometheus, n.Printer, n.StateDump, n.ProcessReport)
 }
 
 // accessor functions to each field. Grouped here for code brevity during the pipeline build
 func tracesReader(n *nodesMap) *pipe.Start[[]request.Span]                  { return &n.TracesReader }
+func spanDedup(n *nodesMap) *pipe.Middle[[]request.Span, []request.Span]    { return &n.SpanDedup }
 func router(n *nodesMap) *pipe.Middle[[]request.Span, []request.Span]       { return &n.Routes }
 func kubernetes(n *nodesMap) *pipe.Middle[[]request.Span, []request.Span]   { return &n.Kubernetes }
+func serviceMesh(n *nodesMap) *pipe.Middle[[]request.Span, []request.Span]  { return &n.ServiceMesh }
 func nameResolver(n *nodesMap) *pipe.Middle[[]request.Span, []request.Span] { return &n.NameResolver }
+func spanEnricher(n *nodesMap) *pipe.Middle[[]request.Span, []request.Span] { return &n.SpanEnricher }
+func silences(n *nodesMap) *pipe.Middle[[]request.Span, []request.Span]     { return &n.SilenceDetector }
 func attrFilter(n *nodesMap) *pipe.Middle[[]request.Span, []request.Span]   { return &n.AttributeFilter }
 func alloyTraces(n *nodesMap) *pipe.Final[[]request.Span]                   { return &n.AlloyTraces }
 func otelMetrics(n *nodesMap) *pipe.Final[[]request.Span]                   { return &n.Metrics }
 func otelTraces(n *nodesMap) *pipe.Final[[]request.Span]                    { return &n.Traces }
 func printer(n *nodesMap) *pipe.Final[[]request.Span]                       { return &n.Printer }
 func prometheus(n *nodesMap) *pipe.Final[[]request.Span]                    { return &n.Prometheus }
+func stateDump(n *nodesMap) *pipe.Final[[]request.Span]                     { return &n.StateDump }
 func processReport(n *nodesMap) *pipe.Final[[]request.Span]                 { return &n.ProcessReport }
 
 // builder with injectable instantiators for unit testing
@@ -106,10 +128,22 @@ func newGraphBuilder(ctx context.Context, config *beyla.Config, ctxInfo *global.
 		TracesInput: gb.tracesCh,
 	}))
 
+	pipe.AddMiddleProvider(gnb, spanDedup, transform.SpanDeduperProvider(&config.SpanDedup, ctxInfo.Metrics))
 	pipe.AddMiddleProvider(gnb, router, transform.RoutesProvider(config.Routes))
 	pipe.AddMiddleProvider(gnb, kubernetes, transform.KubeDecoratorProvider(ctx, &config.Attributes.Kubernetes, ctxInfo))
+	pipe.AddMiddleProvider(gnb, serviceMesh, transform.ServiceMeshProvider(ctx, &config.ServiceMesh, ctxInfo))
 	pipe.AddMiddleProvider(gnb, nameResolver, transform.NameResolutionProvider(ctx, gb.ctxInfo, config.NameResolver))
-	pipe.AddMiddleProvider(gnb, attrFilter, filter.ByAttribute(config.Filters.Application, spanPtrPromGetters))
+	pipe.AddMiddleProvider(gnb, spanEnricher, transform.SpanEnricherProvider(&transform.SpanEnricherConfig{
+		SpanStatus:  &config.SpanStatus,
+		GRPCGateway: &config.GRPCGateway,
+		SpanNames:   &config.SpanNames,
+		Tenant:      &config.Attributes.Tenant,
+	}))
+	pipe.AddMiddleProvider(gnb, silences, transform.SilenceDetectorProvider(&config.SilenceDetection, ctxInfo.Metrics))
+	pipe.AddMiddleProvider(gnb, attrFilter, filter.ByAttributeReporting(config.Filters.Application, spanPtrPromGetters,
+		func(dropped int, example request.Span) {
+			ctxInfo.Metrics.SpansDropped(imetrics.DropReasonFiltered, dropped, example.Summary())
+		}))
 	config.Metrics.Grafana = &gb.config.Grafana.OTLP
 	pipe.AddFinalProvider(gnb, otelMetrics, otel.ReportMetrics(ctx, gb.ctxInfo, &config.Metrics, config.Attributes.Select))
 	config.Traces.Grafana = &gb.config.Grafana.OTLP
@@ -118,6 +152,7 @@ func newGraphBuilder(ctx context.Context, config *beyla.Config, ctxInfo *global.
 	pipe.AddFinalProvider(gnb, alloyTraces, alloy.TracesReceiver(ctx, gb.ctxInfo, &config.TracesReceiver, config.Attributes.Select))
 
 	pipe.AddFinalProvider(gnb, printer, debug.PrinterNode(config.TracePrinter))
+	pipe.AddFinalProvider(gnb, stateDump, debug.StateDumpNode(&config.StateDump, gb.tracesCh))
 
 	// process subpipeline will start another pipeline only to collect and export data
 	// about the processes of an instrumented application
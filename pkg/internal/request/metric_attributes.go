@@ -1,6 +1,8 @@
 package request
 
 import (
+	"time"
+
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
 
@@ -19,6 +21,10 @@ func HTTPUrlPath(val string) attribute.KeyValue {
 	return attribute.Key(attr.HTTPUrlPath).String(val)
 }
 
+func TenantID(val string) attribute.KeyValue {
+	return attribute.Key(attr.TenantID).String(val)
+}
+
 func HTTPUrlFull(val string) attribute.KeyValue {
 	return attribute.Key(attr.HTTPUrlFull).String(val)
 }
@@ -39,6 +45,10 @@ func HTTPRequestBodySize(val int) attribute.KeyValue {
 	return attribute.Key(attr.HTTPRequestBodySize).Int(val)
 }
 
+func HTTPResponseBodySize(val int64) attribute.KeyValue {
+	return attribute.Key(attr.HTTPResponseBodySize).Int64(val)
+}
+
 func SpanKindMetric(val string) attribute.KeyValue {
 	return attribute.Key(attr.SpanKind).String(val)
 }
@@ -95,6 +105,129 @@ func DBSystem(val string) attribute.KeyValue {
 	return attribute.Key(semconv.DBSystemKey).String(val)
 }
 
+func SOAPAction(val string) attribute.KeyValue {
+	return attribute.Key("soap.action").String(val)
+}
+
+func HTTPUpgrade(val string) attribute.KeyValue {
+	return attribute.Key("http.upgrade").String(val)
+}
+
+// HTTPRequestHeader returns the attribute of a captured request header. The name must be lowercase.
+func HTTPRequestHeader(name string, values []string) attribute.KeyValue {
+	return attribute.Key("http.request.header." + name).StringSlice(values)
+}
+
+// EnduserPseudoID returns the pseudonymous identifier of the principal that sent a request
+func EnduserPseudoID(val string) attribute.KeyValue {
+	return attribute.Key("enduser.pseudo.id").String(val)
+}
+
+func HTTPRequestBodyContent(val string) attribute.KeyValue {
+	return attribute.Key("http.request.body.content").String(val)
+}
+
+// RPCGRPCTimeout returns the deadline of a gRPC call, in seconds
+func RPCGRPCTimeout(val time.Duration) attribute.KeyValue {
+	return attribute.Key("rpc.grpc.timeout").Float64(val.Seconds())
+}
+
+func RPCGRPCPreviousAttempts(val int) attribute.KeyValue {
+	return attribute.Key("rpc.grpc.previous_rpc_attempts").Int(val)
+}
+
+func HTTP2ResetErrorCode(val string) attribute.KeyValue {
+	return attribute.Key("http2.rst_stream.error_code").String(val)
+}
+
+func HTTP2ResetSender(val string) attribute.KeyValue {
+	return attribute.Key("http2.rst_stream.sender").String(val)
+}
+
+func WebSocketMessageType(val string) attribute.KeyValue {
+	return attribute.Key("websocket.message.type").String(val)
+}
+
+func WebSocketMessageDirection(val string) attribute.KeyValue {
+	return attribute.Key("websocket.message.direction").String(val)
+}
+
+func WebSocketMessageSize(val int) attribute.KeyValue {
+	return attribute.Key("websocket.message.size").Int(val)
+}
+
+func DBRedisRedirectType(val string) attribute.KeyValue {
+	return attribute.Key(attr.DBRedisRedirectType).String(val)
+}
+
+func DBRedisRedirectSlot(val int) attribute.KeyValue {
+	return attribute.Key("db.redis.redirect.slot").Int(val)
+}
+
+func DBRedisRedirectAddress(val string) attribute.KeyValue {
+	return attribute.Key("db.redis.redirect.address").String(val)
+}
+
+func FTPCommand(val string) attribute.KeyValue {
+	return attribute.Key("ftp.command").String(val)
+}
+
+func FTPPath(val string) attribute.KeyValue {
+	return attribute.Key("ftp.path").String(val)
+}
+
+func FTPReplyCode(val int) attribute.KeyValue {
+	return attribute.Key("ftp.reply_code").Int(val)
+}
+
+func FTPTransferSize(val int64) attribute.KeyValue {
+	return attribute.Key("ftp.transfer.size").Int64(val)
+}
+
+func SSHProtocolVersion(val string) attribute.KeyValue {
+	return attribute.Key("ssh.protocol.version").String(val)
+}
+
+func SSHClientSoftware(val string) attribute.KeyValue {
+	return attribute.Key("ssh.client.software").String(val)
+}
+
+func SSHServerSoftware(val string) attribute.KeyValue {
+	return attribute.Key("ssh.server.software").String(val)
+}
+
+func TLSProtocolVersion(val string) attribute.KeyValue {
+	return attribute.Key("tls.protocol.version").String(val)
+}
+
+func ProtocolDetectionConfidence(val float64) attribute.KeyValue {
+	return attribute.Key("beyla.protocol.detection.confidence").Float64(val)
+}
+
+func TLSCipher(val string) attribute.KeyValue {
+	return attribute.Key("tls.cipher").String(val)
+}
+
+func TLSServerName(val string) attribute.KeyValue {
+	return attribute.Key("tls.client.server_name").String(val)
+}
+
+func MessagingAMQPLinkName(val string) attribute.KeyValue {
+	return attribute.Key("messaging.amqp.link.name").String(val)
+}
+
+func MessagingAMQPDeliveryOutcome(val string) attribute.KeyValue {
+	return attribute.Key("messaging.amqp.delivery.outcome").String(val)
+}
+
+func MessagingNATSReplyTo(val string) attribute.KeyValue {
+	return attribute.Key("messaging.nats.reply_to").String(val)
+}
+
+func MessagingKafkaConsumerLag(val int64) attribute.KeyValue {
+	return attribute.Key("messaging.kafka.consumer.lag").Int64(val)
+}
+
 func ErrorType(val string) attribute.KeyValue {
 	return attribute.Key(attr.ErrorType).String(val)
 }
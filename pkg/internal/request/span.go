@@ -32,6 +32,20 @@ const (
 	EventTypeKafkaClient
 	EventTypeRedisServer
 	EventTypeKafkaServer
+	EventTypeWebSocketClient
+	EventTypeWebSocketServer
+	EventTypeFTPClient
+	EventTypeFTPServer
+	EventTypeSSHClient
+	EventTypeSSHServer
+	EventTypeTLSClient
+	EventTypeTLSServer
+	EventTypeAMQPClient
+	EventTypeAMQPServer
+	EventTypeNATSClient
+	EventTypeNATSServer
+	// EventTypeProcessDeleted is an internal signal that notifies that an instrumented process ended
+	EventTypeProcessDeleted
 )
 
 const (
@@ -63,6 +77,32 @@ func (t EventType) String() string {
 		return "RedisServer"
 	case EventTypeKafkaServer:
 		return "KafkaServer"
+	case EventTypeWebSocketClient:
+		return "WebSocketClient"
+	case EventTypeWebSocketServer:
+		return "WebSocketServer"
+	case EventTypeFTPClient:
+		return "FTPClient"
+	case EventTypeFTPServer:
+		return "FTPServer"
+	case EventTypeSSHClient:
+		return "SSHClient"
+	case EventTypeSSHServer:
+		return "SSHServer"
+	case EventTypeTLSClient:
+		return "TLSClient"
+	case EventTypeTLSServer:
+		return "TLSServer"
+	case EventTypeAMQPClient:
+		return "AMQPClient"
+	case EventTypeAMQPServer:
+		return "AMQPServer"
+	case EventTypeNATSClient:
+		return "NATSClient"
+	case EventTypeNATSServer:
+		return "NATSServer"
+	case EventTypeProcessDeleted:
+		return "ProcessDeleted"
 	default:
 		return fmt.Sprintf("UNKNOWN (%d)", t)
 	}
@@ -101,6 +141,148 @@ const (
 	MessagingProcess = "process"
 )
 
+// The following constants are the values of the Span.SubType field, which further
+// specifies the application protocol of a span, depending on its Type.
+const (
+	// HTTPSubtypeNone, HTTPSubtypeElasticsearch, HTTPSubtypeSOAP, HTTPSubtypeWebSocket and
+	// HTTPSubtypeJSONRPC apply to HTTP spans
+	HTTPSubtypeNone          = 0
+	HTTPSubtypeElasticsearch = 1
+	HTTPSubtypeSOAP          = 2
+	// HTTPSubtypeWebSocket is set to the requests that upgraded the connection to WebSocket
+	HTTPSubtypeWebSocket = 3
+	HTTPSubtypeJSONRPC   = 4
+)
+
+// WebSocket message types, which are stored in the Method field of the WebSocket spans
+const (
+	WebSocketContinuation = "continuation"
+	WebSocketText         = "text"
+	WebSocketBinary       = "binary"
+	WebSocketClose        = "close"
+	WebSocketPing         = "ping"
+	WebSocketPong         = "pong"
+)
+
+const (
+	// RPCSubtypeNone, RPCSubtypeDubbo, RPCSubtypeRSocket, RPCSubtypeConnect and RPCSubtypeTwirp
+	// apply to gRPC spans, which are also used to report other RPC protocols
+	RPCSubtypeNone    = 0
+	RPCSubtypeDubbo   = 1
+	RPCSubtypeRSocket = 2
+	RPCSubtypeConnect = 3
+	RPCSubtypeTwirp   = 4
+)
+
+const (
+	// SQLSubtypeNone, SQLSubtypeClickHouse, SQLSubtypeMSSQL and SQLSubtypeOracle apply to SQL spans
+	SQLSubtypeNone       = 0
+	SQLSubtypeClickHouse = 1
+	SQLSubtypeMSSQL      = 2
+	SQLSubtypeOracle     = 3
+)
+
+// JSONRPC contains the information of a JSON-RPC 2.0 call over HTTP
+type JSONRPC struct {
+	// Method is the name of the called method (e.g. eth_getBalance)
+	Method string
+	// RequestID is the string or numeric id of the call. It is empty for notifications.
+	RequestID string
+}
+
+// Elasticsearch contains the information of an Elasticsearch/OpenSearch REST request
+type Elasticsearch struct {
+	// DBOperationName is the invoked endpoint, without the leading underscore (e.g. search, bulk)
+	DBOperationName string
+	// DBCollectionName is the target index, if any
+	DBCollectionName string
+	// DBQueryText is the query DSL of the request body, with the literal values removed
+	DBQueryText string
+}
+
+// ClickHouse contains the information of a query that is sent through the ClickHouse native protocol
+type ClickHouse struct {
+	// QueryID is the ID that the client assigned to the query, if any
+	QueryID string
+	// Settings that are overridden for the query, in the form name=value
+	Settings []string
+}
+
+// SSH contains the identification strings that the SSH client and server exchange when the connection
+// is established. Any further information about the session is encrypted.
+type SSH struct {
+	// ProtocolVersion announced by the client, e.g. 2.0
+	ProtocolVersion string
+	// ClientSoftware is the software version of the client, e.g. OpenSSH_9.6p1
+	ClientSoftware string
+	// ServerSoftware is the software version of the server. It is empty if the server
+	// replied with an error message.
+	ServerSoftware string
+}
+
+// TLS contains the handshake information that the TLS client and server exchange in plain text
+type TLS struct {
+	// Version is the negotiated protocol version, e.g. 1.3. It is empty if it didn't fit in the captured bytes
+	Version string
+	// CipherSuite is the name of the negotiated cipher suite, e.g. TLS_AES_128_GCM_SHA256
+	CipherSuite string
+	// ServerName requested by the client through the SNI extension
+	ServerName string
+}
+
+// AMQP contains the information of an AMQP 1.0 message transfer
+type AMQP struct {
+	// LinkName is the name of the link that transferred the message. It is empty if the link
+	// was attached before the process was instrumented
+	LinkName string
+	// Outcome of the delivery that the receiver settled, e.g. accepted or rejected. It is empty if
+	// the delivery wasn't settled along with the transfer, or if the message was pre-settled
+	Outcome string
+}
+
+// NATS contains the information of a published or delivered NATS message
+type NATS struct {
+	// ReplyTo is the subject where the replies to the message are expected, for request/reply
+	ReplyTo string
+}
+
+// RedisRedirect contains the MOVED or ASK error reply that a Redis Cluster node sends when the
+// key of the command is served by another node
+type RedisRedirect struct {
+	// Type is either MOVED, if the hash slot has been permanently reassigned, or ASK, if the
+	// slot is being migrated
+	Type string
+	// Slot is the hash slot of the key
+	Slot int
+	// Address is the host:port of the node that serves the slot
+	Address string
+}
+
+// HTTP2StreamReset contains the RST_STREAM frame that abruptly terminated an HTTP/2 or gRPC stream
+type HTTP2StreamReset struct {
+	// ErrorCode is the name of the HTTP/2 error code of the frame, e.g. CANCEL or FLOW_CONTROL_ERROR
+	ErrorCode string
+	// Sender is either "client" or "server", depending on the side of the stream that reset it
+	Sender string
+}
+
+// KafkaRecord contains the position of the first record that a Kafka Fetch response returns,
+// and the trace context that its producer propagated in the record headers, if any.
+// For Produce requests, it contains the position of the written records.
+type KafkaRecord struct {
+	// Partition is the ID of the topic partition
+	Partition int
+	// Offset of the first returned or written record, or -1 if the record batch or the
+	// Produce response weren't captured
+	Offset int64
+	// Lag is the number of records between the end of the returned batch and the
+	// high watermark of the partition, or -1 if unknown
+	Lag int64
+	// ProducerTraceID and ProducerSpanID are taken from the traceparent record header
+	ProducerTraceID trace2.TraceID
+	ProducerSpanID  trace2.SpanID
+}
+
 type converter struct {
 	clock     func() time.Time
 	monoClock func() time.Duration
@@ -135,6 +317,7 @@ type Span struct {
 	HostPort       int            `json:"hostPort,string"`
 	Status         int            `json:"-"`
 	ContentLength  int64          `json:"-"`
+	ResponseLength int64          `json:"-"`
 	RequestStart   int64          `json:"-"`
 	Start          int64          `json:"-"`
 	End            int64          `json:"-"`
@@ -148,6 +331,43 @@ type Span struct {
 	HostName       string         `json:"hostName"`
 	OtherNamespace string         `json:"-"`
 	Statement      string         `json:"-"`
+	SubType        int            `json:"-"`
+	Elasticsearch  *Elasticsearch `json:"-"`
+	ClickHouse     *ClickHouse    `json:"-"`
+	SOAPAction     string         `json:"-"`
+	JSONRPC        *JSONRPC       `json:"-"`
+	RedisRedirect  *RedisRedirect `json:"-"`
+	KafkaRecord    *KafkaRecord   `json:"-"`
+	SSH            *SSH           `json:"-"`
+	TLS            *TLS           `json:"-"`
+	AMQP           *AMQP          `json:"-"`
+	NATS           *NATS          `json:"-"`
+	// Authority is the :authority pseudo-header of the HTTP/2 requests, which names the target
+	// of the gRPC clients
+	Authority string `json:"-"`
+	// StatusOverride, when it is not Unset, replaces the status that is derived from the Status
+	// field, after the user configuration: Error for errors and Ok for non-errors
+	StatusOverride codes.Code `json:"-"`
+	// NameOverride, when it is not empty, replaces the name of the span, after the user configuration
+	NameOverride string `json:"-"`
+	// DetectionConfidence is a score, between 0 and 1, of the protocol detection of the spans that
+	// are inferred from the TCP traffic bytes. It is zero for the rest of the spans.
+	DetectionConfidence float64 `json:"-"`
+	// GRPCTimeout is the deadline that the gRPC clients sent in the grpc-timeout header, if any
+	GRPCTimeout time.Duration `json:"-"`
+	// GRPCPreviousAttempts is the number of previous attempts of the gRPC client calls that
+	// are retried, as sent in the grpc-previous-rpc-attempts header
+	GRPCPreviousAttempts int `json:"-"`
+	// HTTP2Reset is set when the HTTP/2 stream of the request was reset with an error
+	HTTP2Reset *HTTP2StreamReset `json:"-"`
+	// Tenant that sent the request, as extracted from the configured request header
+	Tenant string `json:"-"`
+	// EnduserHash is the hash of the principal that authenticated the request
+	EnduserHash string `json:"-"`
+	// RequestHeaders that have been captured, keyed by their lowercase name
+	RequestHeaders map[string][]string `json:"-"`
+	// RequestBody contains the first bytes of the request body, for the spans that have been sampled for it
+	RequestBody string `json:"-"`
 }
 
 func (s *Span) Inside(parent *Span) bool {
@@ -157,7 +377,7 @@ func (s *Span) Inside(parent *Span) bool {
 // InternalSignal returns whether a span is not aimed to be exported as a metric
 // or a trace, because it's used to internally send messages through the pipeline.
 func (s *Span) InternalSignal() bool {
-	return s.Type == EventTypeProcessAlive
+	return s.Type == EventTypeProcessAlive || s.Type == EventTypeProcessDeleted
 }
 
 // helper attribute functions used by JSON serialization
@@ -166,7 +386,7 @@ type SpanAttributes map[string]string
 func spanAttributes(s *Span) SpanAttributes {
 	switch s.Type {
 	case EventTypeHTTP:
-		return SpanAttributes{
+		attrs := SpanAttributes{
 			"method":     s.Method,
 			"status":     strconv.Itoa(s.Status),
 			"url":        s.Path,
@@ -176,8 +396,21 @@ func spanAttributes(s *Span) SpanAttributes {
 			"serverAddr": SpanHost(s),
 			"serverPort": strconv.Itoa(s.HostPort),
 		}
+		if s.ResponseLength > 0 {
+			attrs["responseLen"] = strconv.FormatInt(s.ResponseLength, 10)
+		}
+		if s.SubType == HTTPSubtypeSOAP {
+			attrs["soapAction"] = s.SOAPAction
+		}
+		if s.SubType == HTTPSubtypeJSONRPC && s.JSONRPC != nil {
+			attrs["jsonrpcMethod"] = s.JSONRPC.Method
+		}
+		if s.SubType == HTTPSubtypeWebSocket {
+			attrs["upgrade"] = "websocket"
+		}
+		return attrs
 	case EventTypeHTTPClient:
-		return SpanAttributes{
+		attrs := SpanAttributes{
 			"method":     s.Method,
 			"status":     strconv.Itoa(s.Status),
 			"url":        s.Path,
@@ -185,6 +418,24 @@ func spanAttributes(s *Span) SpanAttributes {
 			"serverAddr": SpanHost(s),
 			"serverPort": strconv.Itoa(s.HostPort),
 		}
+		if s.ResponseLength > 0 {
+			attrs["responseLen"] = strconv.FormatInt(s.ResponseLength, 10)
+		}
+		if s.SubType == HTTPSubtypeElasticsearch && s.Elasticsearch != nil {
+			attrs["operation"] = s.Elasticsearch.DBOperationName
+			attrs["index"] = s.Elasticsearch.DBCollectionName
+			attrs["query"] = s.Elasticsearch.DBQueryText
+		}
+		if s.SubType == HTTPSubtypeSOAP {
+			attrs["soapAction"] = s.SOAPAction
+		}
+		if s.SubType == HTTPSubtypeJSONRPC && s.JSONRPC != nil {
+			attrs["jsonrpcMethod"] = s.JSONRPC.Method
+		}
+		if s.SubType == HTTPSubtypeWebSocket {
+			attrs["upgrade"] = "websocket"
+		}
+		return attrs
 	case EventTypeGRPC:
 		return SpanAttributes{
 			"method":     s.Path,
@@ -201,21 +452,32 @@ func spanAttributes(s *Span) SpanAttributes {
 			"serverPort": strconv.Itoa(s.HostPort),
 		}
 	case EventTypeSQLClient:
-		return SpanAttributes{
+		attrs := SpanAttributes{
 			"serverAddr": SpanHost(s),
 			"serverPort": strconv.Itoa(s.HostPort),
 			"operation":  s.Method,
 			"table":      s.Path,
 			"statement":  s.Statement,
 		}
+		if s.SubType == SQLSubtypeClickHouse && s.ClickHouse != nil {
+			attrs["queryId"] = s.ClickHouse.QueryID
+			attrs["settings"] = strings.Join(s.ClickHouse.Settings, ",")
+		}
+		return attrs
 	case EventTypeRedisServer:
-		return SpanAttributes{
+		attrs := SpanAttributes{
 			"serverAddr": SpanHost(s),
 			"serverPort": strconv.Itoa(s.HostPort),
 			"operation":  s.Method,
 			"statement":  s.Statement,
 			"query":      s.Path,
 		}
+		if s.RedisRedirect != nil {
+			attrs["redirectType"] = s.RedisRedirect.Type
+			attrs["redirectSlot"] = strconv.Itoa(s.RedisRedirect.Slot)
+			attrs["redirectAddr"] = s.RedisRedirect.Address
+		}
+		return attrs
 	case EventTypeKafkaServer:
 		return SpanAttributes{
 			"serverAddr": SpanHost(s),
@@ -223,6 +485,68 @@ func spanAttributes(s *Span) SpanAttributes {
 			"operation":  s.Method,
 			"clientId":   s.OtherNamespace,
 		}
+	case EventTypeWebSocketClient, EventTypeWebSocketServer:
+		return SpanAttributes{
+			"serverAddr":  SpanHost(s),
+			"serverPort":  strconv.Itoa(s.HostPort),
+			"messageType": s.Method,
+			"direction":   s.WebSocketDirection(),
+			"size":        strconv.FormatInt(s.ContentLength, 10),
+		}
+	case EventTypeFTPClient, EventTypeFTPServer:
+		return SpanAttributes{
+			"serverAddr":   SpanHost(s),
+			"serverPort":   strconv.Itoa(s.HostPort),
+			"command":      s.Method,
+			"path":         s.Path,
+			"replyCode":    strconv.Itoa(s.Status),
+			"transferSize": strconv.FormatInt(s.ContentLength, 10),
+		}
+	case EventTypeSSHClient, EventTypeSSHServer:
+		attrs := SpanAttributes{
+			"serverAddr": SpanHost(s),
+			"serverPort": strconv.Itoa(s.HostPort),
+		}
+		if s.SSH != nil {
+			attrs["protocolVersion"] = s.SSH.ProtocolVersion
+			attrs["clientSoftware"] = s.SSH.ClientSoftware
+			attrs["serverSoftware"] = s.SSH.ServerSoftware
+		}
+		return attrs
+	case EventTypeTLSClient, EventTypeTLSServer:
+		attrs := SpanAttributes{
+			"serverAddr": SpanHost(s),
+			"serverPort": strconv.Itoa(s.HostPort),
+		}
+		if s.TLS != nil {
+			attrs["version"] = s.TLS.Version
+			attrs["cipherSuite"] = s.TLS.CipherSuite
+			attrs["serverName"] = s.TLS.ServerName
+		}
+		return attrs
+	case EventTypeAMQPClient, EventTypeAMQPServer:
+		attrs := SpanAttributes{
+			"serverAddr":  SpanHost(s),
+			"serverPort":  strconv.Itoa(s.HostPort),
+			"operation":   s.Method,
+			"destination": s.Path,
+		}
+		if s.AMQP != nil {
+			attrs["linkName"] = s.AMQP.LinkName
+			attrs["outcome"] = s.AMQP.Outcome
+		}
+		return attrs
+	case EventTypeNATSClient, EventTypeNATSServer:
+		attrs := SpanAttributes{
+			"serverAddr":  SpanHost(s),
+			"serverPort":  strconv.Itoa(s.HostPort),
+			"operation":   s.Method,
+			"destination": s.Path,
+		}
+		if s.NATS != nil {
+			attrs["replyTo"] = s.NATS.ReplyTo
+		}
+		return attrs
 	}
 
 	return SpanAttributes{}
@@ -298,9 +622,28 @@ func (s *Span) IsValid() bool {
 	return true
 }
 
+// spanSummary describes a span in a single line, for logging purposes
+type spanSummary struct {
+	span *Span
+}
+
+func (ss spanSummary) String() string {
+	s := ss.span
+	return fmt.Sprintf("%s %q service=%s peer=%s:%d host=%s:%d traceID=%s",
+		s.Type, s.TraceName(), s.ServiceID.String(), s.Peer, s.PeerPort, s.Host, s.HostPort, s.TraceID)
+}
+
+// Summary returns a short description of the span, e.g. to log an example of the discarded spans.
+// The description is generated lazily, so the span must not be modified before it is printed.
+func (s *Span) Summary() fmt.Stringer {
+	return spanSummary{span: s}
+}
+
 func (s *Span) IsClientSpan() bool {
 	switch s.Type {
-	case EventTypeGRPCClient, EventTypeHTTPClient, EventTypeRedisClient, EventTypeKafkaClient, EventTypeSQLClient:
+	case EventTypeGRPCClient, EventTypeHTTPClient, EventTypeRedisClient, EventTypeKafkaClient, EventTypeSQLClient,
+		EventTypeWebSocketClient, EventTypeFTPClient, EventTypeSSHClient, EventTypeTLSClient, EventTypeAMQPClient,
+		EventTypeNATSClient:
 		return true
 	}
 
@@ -332,16 +675,30 @@ func (s *Span) IgnoreTraces() bool {
 }
 
 func SpanStatusCode(span *Span) codes.Code {
+	switch span.StatusOverride {
+	case codes.Error:
+		return codes.Error
+	case codes.Ok:
+		return codes.Unset
+	}
 	switch span.Type {
 	case EventTypeHTTP, EventTypeHTTPClient:
 		return HTTPSpanStatusCode(span)
 	case EventTypeGRPC, EventTypeGRPCClient:
 		return GrpcSpanStatusCode(span)
-	case EventTypeSQLClient, EventTypeRedisClient, EventTypeRedisServer:
+	case EventTypeSQLClient, EventTypeRedisClient, EventTypeRedisServer, EventTypeSSHClient, EventTypeSSHServer,
+		EventTypeTLSClient, EventTypeTLSServer, EventTypeAMQPClient, EventTypeAMQPServer, EventTypeNATSClient,
+		EventTypeNATSServer:
 		if span.Status != 0 {
 			return codes.Error
 		}
 		return codes.Unset
+	case EventTypeFTPClient, EventTypeFTPServer:
+		// 4xx and 5xx FTP reply codes are transient and permanent failures
+		if span.Status >= 400 {
+			return codes.Error
+		}
+		return codes.Unset
 	}
 	return codes.Unset
 }
@@ -399,11 +756,13 @@ func (s *Span) RequestLength() int64 {
 // ServiceGraphKind returns the Kind string representation that is compliant with service graph metrics specification
 func (s *Span) ServiceGraphKind() string {
 	switch s.Type {
-	case EventTypeHTTP, EventTypeGRPC, EventTypeKafkaServer, EventTypeRedisServer:
+	case EventTypeHTTP, EventTypeGRPC, EventTypeKafkaServer, EventTypeRedisServer, EventTypeWebSocketServer,
+		EventTypeFTPServer, EventTypeSSHServer, EventTypeTLSServer, EventTypeAMQPServer, EventTypeNATSServer:
 		return "SPAN_KIND_SERVER"
-	case EventTypeHTTPClient, EventTypeGRPCClient, EventTypeSQLClient, EventTypeRedisClient:
+	case EventTypeHTTPClient, EventTypeGRPCClient, EventTypeSQLClient, EventTypeRedisClient, EventTypeWebSocketClient,
+		EventTypeFTPClient, EventTypeSSHClient, EventTypeTLSClient:
 		return "SPAN_KIND_CLIENT"
-	case EventTypeKafkaClient:
+	case EventTypeKafkaClient, EventTypeAMQPClient, EventTypeNATSClient:
 		switch s.Method {
 		case MessagingPublish:
 			return "SPAN_KIND_PRODUCER"
@@ -415,8 +774,17 @@ func (s *Span) ServiceGraphKind() string {
 }
 
 func (s *Span) TraceName() string {
+	if s.NameOverride != "" {
+		return s.NameOverride
+	}
 	switch s.Type {
 	case EventTypeHTTP:
+		if s.SubType == HTTPSubtypeSOAP {
+			return SOAPOperation(s.SOAPAction)
+		}
+		if s.SubType == HTTPSubtypeJSONRPC && s.JSONRPC != nil {
+			return s.JSONRPC.Method
+		}
 		name := s.Method
 		if s.Route != "" {
 			name += " " + s.Route
@@ -425,6 +793,19 @@ func (s *Span) TraceName() string {
 	case EventTypeGRPC, EventTypeGRPCClient:
 		return s.Path
 	case EventTypeHTTPClient:
+		if s.SubType == HTTPSubtypeElasticsearch && s.Elasticsearch != nil {
+			name := s.Elasticsearch.DBOperationName
+			if s.Elasticsearch.DBCollectionName != "" {
+				name += " " + s.Elasticsearch.DBCollectionName
+			}
+			return name
+		}
+		if s.SubType == HTTPSubtypeSOAP {
+			return SOAPOperation(s.SOAPAction)
+		}
+		if s.SubType == HTTPSubtypeJSONRPC && s.JSONRPC != nil {
+			return s.JSONRPC.Method
+		}
 		return s.Method
 	case EventTypeSQLClient:
 		operation := s.Method
@@ -441,15 +822,117 @@ func (s *Span) TraceName() string {
 			return "REDIS"
 		}
 		return s.Method
-	case EventTypeKafkaClient, EventTypeKafkaServer:
+	case EventTypeKafkaClient, EventTypeKafkaServer, EventTypeAMQPClient, EventTypeAMQPServer, EventTypeNATSClient,
+		EventTypeNATSServer:
 		if s.Path == "" {
 			return s.Method
 		}
 		return fmt.Sprintf("%s %s", s.Path, s.Method)
+	case EventTypeWebSocketClient, EventTypeWebSocketServer:
+		return "websocket " + s.Method
+	case EventTypeFTPClient, EventTypeFTPServer:
+		return "FTP " + s.Method
+	case EventTypeSSHClient, EventTypeSSHServer:
+		return "SSH " + s.Method
+	case EventTypeTLSClient, EventTypeTLSServer:
+		return "TLS " + s.Method
+	}
+	return ""
+}
+
+// WebSocketDirection returns whether a WebSocket message was sent or received
+// by the instrumented process, or an empty string if it isn't a WebSocket span.
+func (s *Span) WebSocketDirection() string {
+	switch s.Type {
+	case EventTypeWebSocketClient:
+		return "sent"
+	case EventTypeWebSocketServer:
+		return "received"
 	}
 	return ""
 }
 
+// SOAPOperation returns the operation name of a SOAP action, which usually is its last
+// segment, e.g. GetQuote for http://example.com/StockQuote/GetQuote or urn:GetQuote
+func SOAPOperation(action string) string {
+	if idx := strings.LastIndexAny(action, "/#:"); idx >= 0 && idx < len(action)-1 {
+		return action[idx+1:]
+	}
+	return action
+}
+
+// RPCSystemName returns the value of the rpc.system attribute for RPC spans
+func (s *Span) RPCSystemName() string {
+	switch s.SubType {
+	case RPCSubtypeDubbo:
+		return semconv.RPCSystemApacheDubbo.Value.AsString()
+	case RPCSubtypeRSocket:
+		return "rsocket"
+	case RPCSubtypeConnect:
+		return "connect_rpc"
+	case RPCSubtypeTwirp:
+		return "twirp"
+	}
+	return semconv.RPCSystemGRPC.Value.AsString()
+}
+
+// RPCServiceAndMethod splits the path of an RPC span into its service and method names,
+// e.g. /helloworld.Greeter/SayHello into helloworld.Greeter and SayHello.
+// RSocket routes don't have a service, so they are entirely reported as the method.
+func (s *Span) RPCServiceAndMethod() (service, method string) {
+	if s.SubType == RPCSubtypeRSocket {
+		return "", s.Path
+	}
+	idx := strings.LastIndexByte(s.Path, '/')
+	if idx < 0 {
+		return "", s.Path
+	}
+	return strings.TrimPrefix(s.Path[:idx], "/"), s.Path[idx+1:]
+}
+
+// DBSystemName returns the value of the db.system attribute for database spans,
+// or "unknown" if the span does not belong to a database client or server.
+func (s *Span) DBSystemName() string {
+	switch s.Type {
+	case EventTypeSQLClient:
+		switch s.SubType {
+		case SQLSubtypeClickHouse:
+			return semconv.DBSystemClickhouse.Value.AsString()
+		case SQLSubtypeMSSQL:
+			return semconv.DBSystemMSSQL.Value.AsString()
+		case SQLSubtypeOracle:
+			return semconv.DBSystemOracle.Value.AsString()
+		}
+		return semconv.DBSystemOtherSQL.Value.AsString()
+	case EventTypeRedisClient, EventTypeRedisServer:
+		return semconv.DBSystemRedis.Value.AsString()
+	}
+	return "unknown"
+}
+
+// MessagingSystemName returns the value of the messaging.system attribute for message queue spans,
+// or "unknown" if the span does not belong to a message queue client or server.
+func (s *Span) MessagingSystemName() string {
+	switch s.Type {
+	case EventTypeKafkaClient, EventTypeKafkaServer:
+		return "kafka"
+	case EventTypeAMQPClient, EventTypeAMQPServer:
+		return "amqp"
+	case EventTypeNATSClient, EventTypeNATSServer:
+		return "nats"
+	}
+	return "unknown"
+}
+
+// RedisRedirectType returns the type of the Redis Cluster redirection (MOVED or ASK) that the
+// server replied with, or an empty string if the command wasn't redirected
+func (s *Span) RedisRedirectType() string {
+	if s.RedisRedirect == nil {
+		return ""
+	}
+	return s.RedisRedirect.Type
+}
+
 func (s *Span) isHTTPOrGRPCClient() bool {
 	return s.Type == EventTypeHTTPClient || s.Type == EventTypeGRPCClient
 }
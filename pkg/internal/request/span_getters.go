@@ -34,6 +34,8 @@ func SpanOTELGetters(name attr.Name) (attributes.Getter[*Span, attribute.KeyValu
 		getter = func(s *Span) attribute.KeyValue { return semconv.HTTPRoute(s.Route) }
 	case attr.HTTPUrlPath:
 		getter = func(s *Span) attribute.KeyValue { return HTTPUrlPath(s.Path) }
+	case attr.TenantID:
+		getter = func(s *Span) attribute.KeyValue { return TenantID(s.Tenant) }
 	case attr.ClientAddr:
 		getter = func(s *Span) attribute.KeyValue { return ClientAddr(PeerAsClient(s)) }
 	case attr.ServerAddr:
@@ -43,7 +45,7 @@ func SpanOTELGetters(name attr.Name) (attributes.Getter[*Span, attribute.KeyValu
 	case attr.RPCMethod:
 		getter = func(s *Span) attribute.KeyValue { return semconv.RPCMethod(s.Path) }
 	case attr.RPCSystem:
-		getter = func(_ *Span) attribute.KeyValue { return semconv.RPCSystemGRPC }
+		getter = func(s *Span) attribute.KeyValue { return semconv.RPCSystemKey.String(s.RPCSystemName()) }
 	case attr.RPCGRPCStatusCode:
 		getter = func(s *Span) attribute.KeyValue { return semconv.RPCGRPCStatusCodeKey.Int(s.Status) }
 	case attr.Server:
@@ -74,15 +76,9 @@ func SpanOTELGetters(name attr.Name) (attributes.Getter[*Span, attribute.KeyValu
 	case attr.DBOperation:
 		getter = func(span *Span) attribute.KeyValue { return DBOperationName(span.Method) }
 	case attr.DBSystem:
-		getter = func(span *Span) attribute.KeyValue {
-			switch span.Type {
-			case EventTypeSQLClient:
-				return DBSystem(semconv.DBSystemOtherSQL.Value.AsString())
-			case EventTypeRedisClient, EventTypeRedisServer:
-				return DBSystem(semconv.DBSystemRedis.Value.AsString())
-			}
-			return DBSystem("unknown")
-		}
+		getter = func(span *Span) attribute.KeyValue { return DBSystem(span.DBSystemName()) }
+	case attr.DBRedisRedirectType:
+		getter = func(span *Span) attribute.KeyValue { return DBRedisRedirectType(span.RedisRedirectType()) }
 	case attr.ErrorType:
 		getter = func(span *Span) attribute.KeyValue {
 			if SpanStatusCode(span) == codes.Error {
@@ -92,14 +88,11 @@ func SpanOTELGetters(name attr.Name) (attributes.Getter[*Span, attribute.KeyValu
 		}
 	case attr.MessagingSystem:
 		getter = func(span *Span) attribute.KeyValue {
-			if span.Type == EventTypeKafkaClient || span.Type == EventTypeKafkaServer {
-				return semconv.MessagingSystem("kafka")
-			}
-			return semconv.MessagingSystem("unknown")
+			return semconv.MessagingSystem(span.MessagingSystemName())
 		}
 	case attr.MessagingDestination:
 		getter = func(span *Span) attribute.KeyValue {
-			if span.Type == EventTypeKafkaClient || span.Type == EventTypeKafkaServer {
+			if span.MessagingSystemName() != "unknown" {
 				return semconv.MessagingDestinationName(span.Path)
 			}
 			return semconv.MessagingDestinationName("")
@@ -124,6 +117,8 @@ func SpanPromGetters(attrName attr.Name) (attributes.Getter[*Span, string], bool
 		getter = func(s *Span) string { return s.Route }
 	case attr.HTTPUrlPath:
 		getter = func(s *Span) string { return s.Path }
+	case attr.TenantID:
+		getter = func(s *Span) string { return s.Tenant }
 	case attr.Client, attr.ClientAddr:
 		getter = PeerAsClient
 	case attr.Server, attr.ServerAddr:
@@ -133,7 +128,7 @@ func SpanPromGetters(attrName attr.Name) (attributes.Getter[*Span, string], bool
 	case attr.RPCMethod:
 		getter = func(s *Span) string { return s.Path }
 	case attr.RPCSystem:
-		getter = func(_ *Span) string { return "grpc" }
+		getter = func(s *Span) string { return s.RPCSystemName() }
 	case attr.RPCGRPCStatusCode:
 		getter = func(s *Span) string { return strconv.Itoa(s.Status) }
 	case attr.DBOperation:
@@ -146,15 +141,9 @@ func SpanPromGetters(attrName attr.Name) (attributes.Getter[*Span, string], bool
 			return ""
 		}
 	case attr.DBSystem:
-		getter = func(span *Span) string {
-			switch span.Type {
-			case EventTypeSQLClient:
-				return semconv.DBSystemOtherSQL.Value.AsString()
-			case EventTypeRedisClient, EventTypeRedisServer:
-				return semconv.DBSystemRedis.Value.AsString()
-			}
-			return "unknown"
-		}
+		getter = func(span *Span) string { return span.DBSystemName() }
+	case attr.DBRedisRedirectType:
+		getter = func(span *Span) string { return span.RedisRedirectType() }
 	case attr.DBCollectionName:
 		getter = func(span *Span) string {
 			if span.Type == EventTypeSQLClient {
@@ -163,15 +152,10 @@ func SpanPromGetters(attrName attr.Name) (attributes.Getter[*Span, string], bool
 			return ""
 		}
 	case attr.MessagingSystem:
-		getter = func(span *Span) string {
-			if span.Type == EventTypeKafkaClient || span.Type == EventTypeKafkaServer {
-				return "kafka"
-			}
-			return "unknown"
-		}
+		getter = func(span *Span) string { return span.MessagingSystemName() }
 	case attr.MessagingDestination:
 		getter = func(span *Span) string {
-			if span.Type == EventTypeKafkaClient || span.Type == EventTypeKafkaServer {
+			if span.MessagingSystemName() != "unknown" {
 				return span.Path
 			}
 			return ""
@@ -0,0 +1,187 @@
+package sqlprune
+
+import (
+	"strings"
+)
+
+// SQLObfuscate returns the first statement of a query, replacing its string and numeric literals
+// by a '?' placeholder, so the values of the query are not leaked into the telemetry.
+// The statements that follow a ';' separator of a multi-statement batch are dropped. The identifiers,
+// quoted identifiers, comments and placeholders (e.g. ?, $1, :name) are kept as they are.
+// Since the query might have been truncated by the eBPF probes, a literal that isn't closed
+// is also replaced by the placeholder, and the rest of the query is discarded.
+// nolint:cyclop
+func SQLObfuscate(query string) string {
+	sb := strings.Builder{}
+	sb.Grow(len(query))
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ';':
+			// end of the first statement
+			return strings.TrimRight(sb.String(), " \t\r\n")
+		case c == '\'':
+			end, ok := quotedEnd(query, i, '\'', true)
+			sb.WriteByte('?')
+			if !ok {
+				return sb.String()
+			}
+			i = end
+		case c == '"' || c == '`':
+			// quoted identifiers
+			end, ok := quotedEnd(query, i, c, false)
+			if !ok {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+4])
+			i += end + 4
+		case c == '$':
+			end, literal, ok := dollarEnd(query, i)
+			if !ok {
+				sb.WriteByte('?')
+				return sb.String()
+			}
+			if literal {
+				sb.WriteByte('?')
+			} else {
+				sb.WriteString(query[i:end])
+			}
+			i = end
+		case isDigit(c) || (c == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			sb.WriteByte('?')
+			i = numberEnd(query, i)
+		case isIdentifierStart(c):
+			end := i + 1
+			for end < len(query) && isIdentifierPart(query[end]) {
+				end++
+			}
+			// prefixed string literals: E'...', N'...', X'...', B'...'
+			if end == i+1 && end < len(query) && query[end] == '\'' && strings.IndexByte("eEnNxXbB", c) >= 0 {
+				strEnd, ok := quotedEnd(query, end, '\'', true)
+				sb.WriteByte('?')
+				if !ok {
+					return sb.String()
+				}
+				i = strEnd
+				continue
+			}
+			sb.WriteString(query[i:end])
+			i = end
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// quotedEnd returns the position after the closing quote of the literal or identifier that starts
+// at the start position. A doubled quote is an escaped quote. If backslashes are enabled, a backslash
+// also escapes the next character, as MySQL does.
+func quotedEnd(query string, start int, quote byte, backslashes bool) (int, bool) {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if backslashes {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return len(query), false
+}
+
+// dollarEnd returns the position after a PostgreSQL positional parameter (e.g. $1) or
+// dollar-quoted string (e.g. $$text$$ or $tag$text$tag$), and whether it is a string literal
+func dollarEnd(query string, start int) (int, bool, bool) {
+	i := start + 1
+	if i < len(query) && isDigit(query[i]) {
+		for i < len(query) && isDigit(query[i]) {
+			i++
+		}
+		return i, false, true
+	}
+	for i < len(query) && isIdentifierPart(query[i]) && query[i] != '$' {
+		i++
+	}
+	if i >= len(query) || query[i] != '$' {
+		// not a dollar-quoted string, just a dollar symbol
+		return i, false, true
+	}
+	tag := query[start : i+1]
+	end := strings.Index(query[i+1:], tag)
+	if end < 0 {
+		return len(query), true, false
+	}
+	return i + 1 + end + len(tag), true, true
+}
+
+// numberEnd returns the position after the integer, decimal, exponential or hexadecimal number
+// that starts at the start position
+func numberEnd(query string, start int) int {
+	i := start
+	if strings.HasPrefix(query[i:], "0x") || strings.HasPrefix(query[i:], "0X") {
+		i += 2
+		for i < len(query) && isHexDigit(query[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+		i++
+	}
+	if i < len(query) && (query[i] == 'e' || query[i] == 'E') {
+		exp := i + 1
+		if exp < len(query) && (query[exp] == '+' || query[exp] == '-') {
+			exp++
+		}
+		if exp < len(query) && isDigit(query[exp]) {
+			i = exp
+			for i < len(query) && isDigit(query[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isIdentifierStart also accepts the characters of the named placeholders (:name, @name)
+// and of the SQL Server temporary tables (#name), so they aren't split
+func isIdentifierStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == '@' || c == '#' || c == ':' ||
+		c >= 0x80
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c) || c == '$'
+}
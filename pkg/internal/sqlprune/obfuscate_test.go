@@ -0,0 +1,48 @@
+package sqlprune
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLObfuscate(t *testing.T) {
+	tests := map[string]string{
+		"SELECT * FROM users WHERE id = 1234":                         "SELECT * FROM users WHERE id = ?",
+		"SELECT * FROM users WHERE name = 'O''Brien' AND age > 30.5":  "SELECT * FROM users WHERE name = ? AND age > ?",
+		`SELECT * FROM users WHERE name = 'it\'s' AND k = 0xCAFE`:     "SELECT * FROM users WHERE name = ? AND k = ?",
+		"SELECT 1e10, -2.5E-3, .5 FROM dual":                          "SELECT ?, -?, ? FROM dual",
+		"SELECT * FROM t1 WHERE col2 IN (1, 2, 3)":                    "SELECT * FROM t1 WHERE col2 IN (?, ?, ?)",
+		`SELECT "col1", ` + "`col2`" + ` FROM "My Table"`:             `SELECT "col1", ` + "`col2`" + ` FROM "My Table"`,
+		"UPDATE users SET name = E'bob\\n', hash = X'0F' WHERE id=$1": "UPDATE users SET name = ?, hash = ? WHERE id=$1",
+		"INSERT INTO t VALUES (:name, @id, ?)":                        "INSERT INTO t VALUES (:name, @id, ?)",
+		"SELECT $$it's a 'literal'$$, $tag$x$tag$ FROM t":             "SELECT ?, ? FROM t",
+		"SELECT a::int FROM t -- id = 'comment'\nWHERE b = 'x'":       "SELECT a::int FROM t -- id = 'comment'\nWHERE b = ?",
+		"SELECT /* 'not a literal' */ * FROM t WHERE c = 'y'":         "SELECT /* 'not a literal' */ * FROM t WHERE c = ?",
+		"SELECT * FROM #tmp":                                          "SELECT * FROM #tmp",
+	}
+	for query, expected := range tests {
+		assert.Equal(t, expected, SQLObfuscate(query), query)
+	}
+}
+
+func TestSQLObfuscate_MultiStatement(t *testing.T) {
+	assert.Equal(t, "UPDATE accounts SET balance = ? WHERE id = ?",
+		SQLObfuscate("UPDATE accounts SET balance = 100 WHERE id = 3 ; DELETE FROM accounts"))
+	// separators inside literals, quoted identifiers and comments don't end the statement
+	assert.Equal(t, `SELECT ? FROM "a;b" /* ; */`,
+		SQLObfuscate(`SELECT 'x;y' FROM "a;b" /* ; */; SELECT 2`))
+}
+
+func TestSQLObfuscate_Truncated(t *testing.T) {
+	tests := map[string]string{
+		"SELECT * FROM users WHERE password = 'sec":  "SELECT * FROM users WHERE password = ?",
+		"SELECT * FROM users WHERE code = $x$secret": "SELECT * FROM users WHERE code = ?",
+		"SELECT * FROM users WHERE id = 12":          "SELECT * FROM users WHERE id = ?",
+		`SELECT * FROM "unclosed`:                    `SELECT * FROM "unclosed`,
+		"SELECT * FROM users /* unclosed":            "SELECT * FROM users /* unclosed",
+	}
+	for query, expected := range tests {
+		assert.Equal(t, expected, SQLObfuscate(query), query)
+	}
+}
@@ -57,13 +57,15 @@ func SQLParseOperationAndTable(query string) (string, string) {
 
 		if tokenType == 46 && addedTable { // a dot
 			tokenType, data = tokens.Scan()
-			if tokenType == sqlparser.ID {
+			if tokenType == sqlparser.ID || tokenType == sqlparser.STRING {
 				tables[len(tables)-1] = tables[len(tables)-1] + "." + string(data)
 				continue
 			}
 		}
 
-		if tokenType == sqlparser.ID || tokenType == sqlparser.VALUE_ARG {
+		// double-quoted identifiers are tokenized as strings, as MySQL does. String literals are
+		// never expected in the table positions, so they are accepted as tables (e.g. PostgreSQL "users")
+		if tokenType == sqlparser.ID || tokenType == sqlparser.VALUE_ARG || tokenType == sqlparser.STRING {
 			if lastType == sqlparser.TABLE || lastType == sqlparser.FROM || lastType == sqlparser.INTO ||
 				lastType == sqlparser.UPDATE || lastType == sqlparser.JOIN || addMoreTables {
 				if tokenType == sqlparser.VALUE_ARG {
@@ -126,6 +126,19 @@ func TestSQLExtraction(t *testing.T) {
 		}
 	})
 
+	t.Run("test double-quoted identifiers", func(t *testing.T) {
+		tests := map[string]result{
+			`SELECT * FROM "users" WHERE id = $1`:          {op: "SELECT", table: "users"},
+			`update "public"."orders" set total = ?`:       {op: "UPDATE", table: "public.orders"},
+			`SELECT * FROM users WHERE name = "not table"`: {op: "SELECT", table: "users"},
+		}
+
+		for q, r := range tests {
+			op, tab := SQLParseOperationAndTable(q)
+			assert.Equal(t, r, result{op: op, table: tab})
+		}
+	})
+
 	t.Run("test Non-sense", func(t *testing.T) {
 		tests := map[string]result{
 			"and now for something completely different": {op: "", table: ""},
@@ -52,6 +52,7 @@ const (
 	autoName           idFlags = 0x1
 	exportsOTelMetrics idFlags = 0x2
 	exportsOTelTraces  idFlags = 0x4
+	overheadBenchmark  idFlags = 0x8
 )
 
 // ID stores the metadata attributes of a service/resource
@@ -130,3 +131,13 @@ func (i *ID) SetExportsOTelTraces() {
 func (i *ID) ExportsOTelTraces() bool {
 	return i.getFlag(exportsOTelTraces)
 }
+
+// SetOverheadBenchmark marks the service as the Beyla process while it runs the overhead
+// self-benchmark. Its spans are only generated to measure the overhead, and must be discarded.
+func (i *ID) SetOverheadBenchmark() {
+	i.setFlag(overheadBenchmark)
+}
+
+func (i *ID) OverheadBenchmark() bool {
+	return i.getFlag(overheadBenchmark)
+}
@@ -55,6 +55,11 @@ type DiscoveryConfig struct {
 	// process inspections
 	PollInterval time.Duration `yaml:"poll_interval" env:"BEYLA_DISCOVERY_POLL_INTERVAL"`
 
+	// LibraryRescanInterval specifies the interval time between the inspections of the libraries that
+	// the instrumented processes load after they are discovered (e.g. OpenSSL loaded lazily via dlopen),
+	// to attach them the uprobes late. Zero disables the inspection.
+	LibraryRescanInterval time.Duration `yaml:"library_rescan_interval" env:"BEYLA_DISCOVERY_LIBRARY_RESCAN_INTERVAL"`
+
 	// SystemWide allows instrumentation of all HTTP (no gRPC) calls, incoming and outgoing at a system wide scale.
 	// No filtering per application will be done. Using this option may result in reduced quality of information
 	// gathered for certain languages, such as Golang.
@@ -0,0 +1,101 @@
+package transform
+
+import (
+	"net"
+	"time"
+
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// GRPCGatewayConfig configures the naming of the HTTP server spans of the Go services that
+// use grpc-gateway, which translates REST requests to gRPC calls to the same process through
+// a loopback connection.
+type GRPCGatewayConfig struct {
+	// Enable names the HTTP server spans of Go services after the gRPC method that they invoke
+	// through the loopback interface, so the REST request and the gRPC call share the same name.
+	Enable bool `yaml:"enable" env:"BEYLA_GRPC_GATEWAY_ENABLE"`
+}
+
+// gatewayCallExpiry is the time that the loopback gRPC calls are remembered, waiting for the
+// HTTP server span that invoked them, which finishes later
+const gatewayCallExpiry = 30 * time.Second
+
+// injectable function for testing
+var gatewayTimeNow = time.Now
+
+type gatewayCallKey struct {
+	traceID trace2.TraceID
+	// parentID is the span ID of the HTTP server span that invoked the gRPC call
+	parentID trace2.SpanID
+}
+
+type gatewayCall struct {
+	method string
+	expiry time.Time
+}
+
+// gatewayNamer is not safe for concurrent access
+type gatewayNamer struct {
+	calls     map[gatewayCallKey]gatewayCall
+	nextSweep time.Time
+}
+
+// gatewayNaming returns the span enricher that names the HTTP server spans after the gRPC method
+// that they invoke, or nil if it is not enabled
+func gatewayNaming(cfg *GRPCGatewayConfig) spanEnricher {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+	gn := &gatewayNamer{calls: map[gatewayCallKey]gatewayCall{}}
+	return gn.process
+}
+
+func (gn *gatewayNamer) process(spans []request.Span) {
+	now := gatewayTimeNow()
+	// the gRPC client spans finish before the HTTP server span, but they might be in the same batch
+	for i := range spans {
+		if isGatewayCall(&spans[i]) {
+			gn.calls[gatewayCallKey{traceID: spans[i].TraceID, parentID: spans[i].ParentSpanID}] = gatewayCall{
+				method: spans[i].Path,
+				expiry: now.Add(gatewayCallExpiry),
+			}
+		}
+	}
+	for i := range spans {
+		span := &spans[i]
+		if span.Type != request.EventTypeHTTP || span.ServiceID.SDKLanguage != svc.InstrumentableGolang {
+			continue
+		}
+		key := gatewayCallKey{traceID: span.TraceID, parentID: span.SpanID}
+		if call, ok := gn.calls[key]; ok {
+			span.NameOverride = call.method
+			delete(gn.calls, key)
+		}
+	}
+	if now.After(gn.nextSweep) {
+		for key, call := range gn.calls {
+			if now.After(call.expiry) {
+				delete(gn.calls, key)
+			}
+		}
+		gn.nextSweep = now.Add(gatewayCallExpiry)
+	}
+}
+
+// isGatewayCall returns whether the span is a gRPC call of a Go service to a loopback address
+func isGatewayCall(span *request.Span) bool {
+	if span.Type != request.EventTypeGRPCClient || span.ServiceID.SDKLanguage != svc.InstrumentableGolang {
+		return false
+	}
+	if !span.TraceID.IsValid() || !span.ParentSpanID.IsValid() {
+		return false
+	}
+	if span.Host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(span.Host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,60 @@
+package transform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestGatewayNamer(t *testing.T) {
+	now := time.Now()
+	defer func() { gatewayTimeNow = time.Now }()
+	gatewayTimeNow = func() time.Time { return now }
+
+	gateway := svc.ID{Name: "users", SDKLanguage: svc.InstrumentableGolang}
+	traceID := trace2.TraceID{1, 2, 3}
+	serverID := trace2.SpanID{4, 5, 6}
+	call := request.Span{Type: request.EventTypeGRPCClient, ServiceID: gateway, Host: "127.0.0.1",
+		Path: "/users.v1.UserService/GetUser", TraceID: traceID, ParentSpanID: serverID, SpanID: trace2.SpanID{7}}
+	server := request.Span{Type: request.EventTypeHTTP, ServiceID: gateway, Method: "GET",
+		Route: "/v1/users/{id}", TraceID: traceID, SpanID: serverID}
+
+	gn := &gatewayNamer{calls: map[gatewayCallKey]gatewayCall{}}
+
+	// in the same batch
+	spans := []request.Span{call, server}
+	gn.process(spans)
+	assert.Equal(t, "/users.v1.UserService/GetUser", spans[1].TraceName())
+	assert.Empty(t, gn.calls)
+
+	// in different batches
+	gn.process([]request.Span{call})
+	spans = []request.Span{server}
+	gn.process(spans)
+	assert.Equal(t, "/users.v1.UserService/GetUser", spans[0].TraceName())
+
+	// the calls to other hosts are not translated by a gateway
+	remote := call
+	remote.Host = "10.0.0.3"
+	spans = []request.Span{remote, server}
+	gn.process(spans)
+	assert.Equal(t, "GET /v1/users/{id}", spans[1].TraceName())
+
+	// other HTTP server span
+	other := server
+	other.SpanID = trace2.SpanID{8}
+	spans = []request.Span{call, other}
+	gn.process(spans)
+	assert.Equal(t, "GET /v1/users/{id}", spans[1].TraceName())
+	assert.Len(t, gn.calls, 1)
+
+	// the calls that are not claimed are eventually forgotten
+	now = now.Add(2 * gatewayCallExpiry)
+	gn.process(nil)
+	assert.Empty(t, gn.calls)
+}
@@ -102,6 +102,12 @@ func (md *metadataDecorator) do(span *request.Span) {
 	// override the peer and host names from Kubernetes metadata, if found
 	if name, _ := md.db.ServiceNameNamespaceForIP(span.Host); name != "" {
 		span.HostName = name
+	} else if span.Type == request.EventTypeGRPCClient && span.Authority != "" {
+		// proxyless gRPC clients (e.g. xds:/// targets) connect to the pods, whose IPs might not be
+		// known, but the authority of the request still names the logical service
+		if name, _ := md.db.ServiceNameNamespaceForHost(span.Authority, span.ServiceID.Metadata[attr.K8sNamespaceName]); name != "" {
+			span.HostName = name
+		}
 	}
 	if name, _ := md.db.ServiceNameNamespaceForIP(span.Peer); name != "" {
 		span.PeerName = name
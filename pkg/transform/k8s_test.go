@@ -126,6 +126,30 @@ func TestDecoration(t *testing.T) {
 			"k8s.cluster.name":   "the-cluster",
 		}, deco[0].ServiceID.Metadata)
 	})
+	t.Run("gRPC clients to unknown pods are named after the service of their authority", func(t *testing.T) {
+		inf.Notify(&informer.Event{Type: informer.EventType_CREATED, Resource: &informer.ObjectMeta{
+			Name: "greeter", Namespace: "the-ns", Kind: "Service", Ips: []string{"10.96.0.10"},
+		}})
+		for _, authority := range []string{"greeter:50051", "greeter.the-ns.svc.cluster.local:50051"} {
+			inputCh <- []request.Span{{
+				Type: request.EventTypeGRPCClient, Pid: request.PidInfo{Namespace: 1012}, ServiceID: autoNameSvc,
+				Host: "10.244.1.7", Authority: authority,
+			}}
+			deco := testutil.ReadChannel(t, outputhCh, timeout)
+			require.Len(t, deco, 1)
+			assert.Equal(t, "greeter", deco[0].HostName, authority)
+		}
+		// authorities that aren't Kubernetes services are ignored
+		for _, authority := range []string{"10.244.1.7:50051", "greeter.other-ns:50051", "api.example.com"} {
+			inputCh <- []request.Span{{
+				Type: request.EventTypeGRPCClient, Pid: request.PidInfo{Namespace: 1012}, ServiceID: autoNameSvc,
+				Host: "10.244.1.7", Authority: authority,
+			}}
+			deco := testutil.ReadChannel(t, outputhCh, timeout)
+			require.Len(t, deco, 1)
+			assert.Empty(t, deco[0].HostName, authority)
+		}
+	})
 	t.Run("process without pod Info won't be decorated", func(t *testing.T) {
 		svc := svc.ID{Name: "exec"}
 		svc.SetAutoName()
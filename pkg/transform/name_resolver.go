@@ -125,11 +125,11 @@ func (nr *NameResolver) resolveNames(span *request.Span) {
 		span.ServiceID.Namespace = ns
 	}
 	// don't set names if the peer and host names have been already decorated
-	// in a previous stage (e.g. Kubernetes decorator)
-	if pn != "" {
+	// in a previous stage (e.g. Kubernetes decorator), and they couldn't be resolved here
+	if pn != "" && (span.PeerName == "" || pn != span.Peer) {
 		span.PeerName = pn
 	}
-	if hn != "" {
+	if hn != "" && (span.HostName == "" || hn != span.Host) {
 		span.HostName = hn
 	}
 }
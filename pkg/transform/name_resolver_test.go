@@ -100,6 +100,18 @@ func TestResolvePodsFromK8s(t *testing.T) {
 	assert.Equal(t, "", serverSpan.OtherNamespace)
 	assert.Equal(t, "pod2", serverSpan.HostName)
 	assert.Equal(t, "something", serverSpan.ServiceID.Namespace)
+
+	// the unresolved IPs don't override the names from previous stages
+	nr.sources = resolverSources([]string{"k8s"})
+	grpcSpan := request.Span{
+		Type:     request.EventTypeGRPCClient,
+		Peer:     "10.0.0.1",
+		Host:     "10.0.0.9",
+		HostName: "greeter",
+	}
+	nr.resolveNames(&grpcSpan)
+	assert.Equal(t, "pod1", grpcSpan.PeerName)
+	assert.Equal(t, "greeter", grpcSpan.HostName)
 }
 
 func TestResolveServiceFromK8s(t *testing.T) {
@@ -0,0 +1,264 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/mariomac/pipes/pipe"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/kube"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/kubecache/informer"
+)
+
+func smlog() *slog.Logger {
+	return slog.With("component", "transform.ServiceMesh")
+}
+
+// ServiceMeshConfig configures how the requests of the Kubernetes Pods with a service mesh sidecar
+// proxy (Istio or Linkerd) are reported. The sidecar intercepts the traffic of the Pod, so the
+// instrumented applications receive the requests from a loopback address, and each request is
+// also captured in the proxy, if it is instrumented too.
+type ServiceMeshConfig struct {
+	// Enable the detection of the service mesh sidecars. It requires the Kubernetes metadata decoration.
+	Enable bool `yaml:"enable" env:"BEYLA_SERVICE_MESH_ENABLE"`
+	// MatchWindow is the maximum time that the spans of the requests forwarded by the sidecar are held,
+	// waiting for the span of the sidecar that received the request from the real client.
+	MatchWindow time.Duration `yaml:"match_window" env:"BEYLA_SERVICE_MESH_MATCH_WINDOW"`
+	// SuppressProxyHops drops the spans of the connections between the sidecar and the application
+	// and the spans of the sidecar listeners, which duplicate the spans of the application.
+	SuppressProxyHops bool `yaml:"suppress_proxy_hops" env:"BEYLA_SERVICE_MESH_SUPPRESS_PROXY_HOPS"`
+}
+
+// sidecarPorts are the ports where a sidecar proxy receives the traffic that is
+// redirected from (outbound) and to (inbound) the application
+type sidecarPorts struct {
+	inbound  int
+	outbound int
+}
+
+var (
+	istioSidecar   = sidecarPorts{inbound: 15006, outbound: 15001}
+	linkerdSidecar = sidecarPorts{inbound: 4143, outbound: 4140}
+)
+
+// istioInboundSource is the address from which the Istio sidecar forwards the inbound requests to the application
+const istioInboundSource = "127.0.0.6"
+
+// injectable function for testing
+var meshTimeNow = time.Now
+
+// podSidecar returns the ports of the sidecar proxy injected in a Pod, from the labels
+// that the Istio and Linkerd injectors add to the Pod
+func podSidecar(pod *informer.ObjectMeta) (sidecarPorts, bool) {
+	if pod == nil {
+		return sidecarPorts{}, false
+	}
+	if _, ok := pod.Labels["security.istio.io/tlsMode"]; ok {
+		return istioSidecar, true
+	}
+	if _, ok := pod.Labels["linkerd.io/control-plane-ns"]; ok {
+		return linkerdSidecar, true
+	}
+	return sidecarPorts{}, false
+}
+
+// inboundHop is a request that the sidecar received from the real client, and forwarded to the application
+type inboundHop struct {
+	peer       string
+	peerPort   int
+	start, end int64
+	expiry     time.Time
+}
+
+type forwardedSpan struct {
+	span   request.Span
+	pod    string
+	expiry time.Time
+}
+
+// meshDecorator is not safe for concurrent access
+type meshDecorator struct {
+	cfg *ServiceMeshConfig
+	// pods is an injectable dependency for testing
+	pods interface {
+		PodByPIDNs(pidns uint32) *informer.ObjectMeta
+		ServiceNameNamespaceForIP(ip string) (string, string)
+	}
+	// inbound hops of the sidecars, by Pod UID
+	hops map[string][]inboundHop
+	// spans of the requests forwarded by the sidecars, waiting for their inbound hop
+	held    []forwardedSpan
+	metrics imetrics.Reporter
+}
+
+func ServiceMeshProvider(ctx context.Context, cfg *ServiceMeshConfig, ctxInfo *global.ContextInfo) pipe.MiddleProvider[[]request.Span, []request.Span] {
+	return func() (pipe.MiddleFunc[[]request.Span, []request.Span], error) {
+		if cfg == nil || !cfg.Enable || !ctxInfo.K8sInformer.IsKubeEnabled() {
+			return pipe.Bypass[[]request.Span](), nil
+		}
+		store, err := ctxInfo.K8sInformer.Get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("initializing ServiceMeshProvider: %w", err)
+		}
+		return newMeshDecorator(cfg, store, ctxInfo.Metrics).nodeLoop, nil
+	}
+}
+
+func newMeshDecorator(cfg *ServiceMeshConfig, store *kube.Store, metrics imetrics.Reporter) *meshDecorator {
+	return &meshDecorator{cfg: cfg, pods: store, hops: map[string][]inboundHop{}, metrics: metrics}
+}
+
+func (md *meshDecorator) nodeLoop(in <-chan []request.Span, out chan<- []request.Span) {
+	ticker := time.NewTicker(max(md.cfg.MatchWindow/2, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case spans, ok := <-in:
+			if !ok {
+				if released := md.release(time.Time{}); len(released) > 0 {
+					out <- released
+				}
+				return
+			}
+			if fwd := md.process(spans); len(fwd) > 0 {
+				out <- fwd
+			}
+		case <-ticker.C:
+			if released := md.release(meshTimeNow()); len(released) > 0 {
+				out <- released
+			}
+		}
+	}
+}
+
+// process forwards the spans that don't belong to a meshed Pod, holds the spans whose
+// peer is the sidecar, and forwards the held spans whose real peer is found.
+func (md *meshDecorator) process(spans []request.Span) []request.Span {
+	now := meshTimeNow()
+	fwd := make([]request.Span, 0, len(spans))
+	for i := range spans {
+		span := &spans[i]
+		if span.InternalSignal() {
+			fwd = append(fwd, *span)
+			continue
+		}
+		pod := md.pods.PodByPIDNs(span.Pid.Namespace)
+		ports, meshed := podSidecar(pod)
+		if !meshed || pod.Pod == nil {
+			fwd = append(fwd, *span)
+			continue
+		}
+		switch {
+		case !span.IsClientSpan() && span.HostPort == ports.inbound:
+			// the sidecar received a request from the real client
+			md.hops[pod.Pod.Uid] = append(md.hops[pod.Pod.Uid], inboundHop{
+				peer: span.Peer, peerPort: span.PeerPort, start: span.RequestStart, end: span.End,
+				expiry: now.Add(md.cfg.MatchWindow),
+			})
+			if md.cfg.SuppressProxyHops {
+				md.suppress(span)
+			} else {
+				fwd = append(fwd, *span)
+			}
+		case isProxyHop(span, ports):
+			if md.cfg.SuppressProxyHops {
+				md.suppress(span)
+			} else {
+				fwd = append(fwd, *span)
+			}
+		case !span.IsClientSpan() && isLoopback(span.Peer):
+			// the sidecar forwarded the request: its real peer is known when the span of the sidecar arrives
+			md.held = append(md.held, forwardedSpan{span: *span, pod: pod.Pod.Uid, expiry: now.Add(md.cfg.MatchWindow)})
+		default:
+			fwd = append(fwd, *span)
+		}
+	}
+	return append(fwd, md.matchHeld()...)
+}
+
+func (md *meshDecorator) suppress(span *request.Span) {
+	md.metrics.SpansDropped(imetrics.DropReasonProxyHop, 1, span.Summary())
+}
+
+// isProxyHop returns whether a span belongs to the connections between the application and
+// its sidecar, which duplicate the spans of the application
+func isProxyHop(span *request.Span, ports sidecarPorts) bool {
+	if span.IsClientSpan() {
+		// the application connecting to the outbound listener of the sidecar, or
+		// the Istio sidecar forwarding an inbound request to the application
+		return (span.HostPort == ports.outbound && isLoopback(span.Host)) || span.Peer == istioInboundSource
+	}
+	// the sidecar receiving a request from the application
+	return span.HostPort == ports.outbound
+}
+
+func isLoopback(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && addr.IsLoopback()
+}
+
+// matchHeld returns the held spans whose inbound hop is known, after replacing their peer
+// by the real client. A span matches the inbound hop of the same Pod that encloses it.
+func (md *meshDecorator) matchHeld() []request.Span {
+	var matched []request.Span
+	held := md.held[:0]
+	for _, h := range md.held {
+		hop, ok := md.enclosingHop(&h)
+		if !ok {
+			held = append(held, h)
+			continue
+		}
+		h.span.Peer, h.span.PeerPort = hop.peer, hop.peerPort
+		h.span.PeerName, _ = md.pods.ServiceNameNamespaceForIP(hop.peer)
+		matched = append(matched, h.span)
+	}
+	md.held = held
+	return matched
+}
+
+func (md *meshDecorator) enclosingHop(h *forwardedSpan) (inboundHop, bool) {
+	for _, hop := range md.hops[h.pod] {
+		if hop.start <= h.span.RequestStart && h.span.End <= hop.end {
+			return hop, true
+		}
+	}
+	return inboundHop{}, false
+}
+
+// release returns the held spans whose inbound hop wasn't found before their expiry, with
+// the sidecar as peer, and forgets the expired inbound hops. A zero time releases everything.
+func (md *meshDecorator) release(now time.Time) []request.Span {
+	var released []request.Span
+	held := md.held[:0]
+	for _, h := range md.held {
+		if now.IsZero() || now.After(h.expiry) {
+			released = append(released, h.span)
+		} else {
+			held = append(held, h)
+		}
+	}
+	md.held = held
+	for pod, hops := range md.hops {
+		live := hops[:0]
+		for _, hop := range hops {
+			if !now.IsZero() && !now.After(hop.expiry) {
+				live = append(live, hop)
+			}
+		}
+		if len(live) == 0 {
+			delete(md.hops, pod)
+		} else {
+			md.hops[pod] = live
+		}
+	}
+	if len(released) > 0 {
+		smlog().Debug("the sidecar inbound requests of some spans were not found", "spans", len(released))
+	}
+	return released
+}
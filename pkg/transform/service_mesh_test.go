@@ -0,0 +1,135 @@
+package transform
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/helpers/container"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/kube"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/kubecache/informer"
+)
+
+func meshTestStore() *kube.Store {
+	inf := &fakeInformer{}
+	store := kube.NewStore(inf)
+	// the application (12) and its Istio sidecar (13) run in the same Pod
+	inf.Notify(&informer.Event{Type: informer.EventType_CREATED, Resource: &informer.ObjectMeta{
+		Name: "backend-1", Namespace: "the-ns", Kind: "Pod", Ips: []string{"10.0.0.9"},
+		Labels: map[string]string{"security.istio.io/tlsMode": "istio"},
+		Pod: &informer.PodInfo{
+			Uid:        "uid-backend",
+			Owners:     []*informer.Owner{{Kind: "Deployment", Name: "backend"}},
+			Containers: []*informer.ContainerInfo{{Id: "container-12"}, {Id: "container-13"}},
+		},
+	}})
+	inf.Notify(&informer.Event{Type: informer.EventType_CREATED, Resource: &informer.ObjectMeta{
+		Name: "plain-1", Namespace: "the-ns", Kind: "Pod", Ips: []string{"10.0.0.8"},
+		Pod: &informer.PodInfo{
+			Uid:        "uid-plain",
+			Containers: []*informer.ContainerInfo{{Id: "container-34"}},
+		},
+	}})
+	inf.Notify(&informer.Event{Type: informer.EventType_CREATED, Resource: &informer.ObjectMeta{
+		Name: "frontend-1", Namespace: "the-ns", Kind: "Pod", Ips: []string{"10.0.0.5"},
+		Pod: &informer.PodInfo{
+			Uid:    "uid-frontend",
+			Owners: []*informer.Owner{{Kind: "Deployment", Name: "frontend"}},
+		},
+	}})
+	kube.InfoForPID = func(pid uint32) (container.Info, error) {
+		return container.Info{
+			ContainerID:  fmt.Sprintf("container-%d", pid),
+			PIDNamespace: 1000 + pid,
+		}, nil
+	}
+	store.AddProcess(12)
+	store.AddProcess(13)
+	store.AddProcess(34)
+	return store
+}
+
+func TestServiceMesh_RealPeer(t *testing.T) {
+	now := time.Now()
+	meshTimeNow = func() time.Time { return now }
+	defer func() { meshTimeNow = time.Now }()
+	md := newMeshDecorator(&ServiceMeshConfig{Enable: true, MatchWindow: time.Second}, meshTestStore(), imetrics.NoopReporter{})
+
+	forwarded := request.Span{Type: request.EventTypeHTTP, Pid: request.PidInfo{Namespace: 1012},
+		Peer: istioInboundSource, PeerPort: 40000, Host: "10.0.0.9", HostPort: 8080, RequestStart: 200, End: 300}
+	plain := request.Span{Type: request.EventTypeHTTP, Pid: request.PidInfo{Namespace: 1034},
+		Peer: "127.0.0.1", PeerPort: 40001, Host: "127.0.0.1", HostPort: 8080, RequestStart: 200, End: 300}
+	// the spans forwarded by the sidecar are held until the span of the sidecar arrives
+	assert.Equal(t, []request.Span{plain}, md.process([]request.Span{forwarded, plain}))
+
+	inbound := request.Span{Type: request.EventTypeHTTP, Pid: request.PidInfo{Namespace: 1013},
+		Peer: "10.0.0.5", PeerPort: 5555, Host: "10.0.0.9", HostPort: 15006, RequestStart: 100, End: 400}
+	toApp := request.Span{Type: request.EventTypeHTTPClient, Pid: request.PidInfo{Namespace: 1013},
+		Peer: istioInboundSource, PeerPort: 40000, Host: "10.0.0.9", HostPort: 8080, RequestStart: 150, End: 350}
+	out := md.process([]request.Span{inbound, toApp})
+	require.Len(t, out, 3)
+	assert.Equal(t, inbound, out[0])
+	assert.Equal(t, toApp, out[1])
+	assert.Equal(t, "10.0.0.5", out[2].Peer)
+	assert.Equal(t, 5555, out[2].PeerPort)
+	assert.Equal(t, "frontend", out[2].PeerName)
+	assert.Equal(t, 8080, out[2].HostPort)
+	assert.Empty(t, md.held)
+}
+
+func TestServiceMesh_SuppressProxyHops(t *testing.T) {
+	md := newMeshDecorator(&ServiceMeshConfig{Enable: true, MatchWindow: time.Second, SuppressProxyHops: true},
+		meshTestStore(), imetrics.NoopReporter{})
+
+	out := md.process([]request.Span{
+		// the application calls another service through the outbound listener of the sidecar
+		{Type: request.EventTypeHTTPClient, Pid: request.PidInfo{Namespace: 1012},
+			Peer: "10.0.0.9", PeerPort: 41000, Host: "127.0.0.1", HostPort: 15001, RequestStart: 10, End: 90},
+		{Type: request.EventTypeHTTP, Pid: request.PidInfo{Namespace: 1013},
+			Peer: "10.0.0.9", PeerPort: 41000, Host: "127.0.0.1", HostPort: 15001, RequestStart: 20, End: 80},
+		// the sidecar calls the other service
+		{Type: request.EventTypeHTTPClient, Pid: request.PidInfo{Namespace: 1013},
+			Peer: "10.0.0.9", PeerPort: 41001, Host: "10.0.0.8", HostPort: 8080, RequestStart: 30, End: 70},
+		// an inbound request
+		{Type: request.EventTypeHTTP, Pid: request.PidInfo{Namespace: 1012},
+			Peer: istioInboundSource, PeerPort: 40000, Host: "10.0.0.9", HostPort: 8080, RequestStart: 200, End: 300},
+		{Type: request.EventTypeHTTPClient, Pid: request.PidInfo{Namespace: 1013},
+			Peer: istioInboundSource, PeerPort: 40000, Host: "10.0.0.9", HostPort: 8080, RequestStart: 150, End: 350},
+		{Type: request.EventTypeHTTP, Pid: request.PidInfo{Namespace: 1013},
+			Peer: "10.0.0.5", PeerPort: 5555, Host: "10.0.0.9", HostPort: 15006, RequestStart: 100, End: 400},
+	})
+	require.Len(t, out, 2)
+	assert.Equal(t, "10.0.0.8", out[0].Host)
+	assert.Equal(t, "10.0.0.5", out[1].Peer)
+	assert.Equal(t, 8080, out[1].HostPort)
+}
+
+func TestServiceMesh_Release(t *testing.T) {
+	now := time.Now()
+	meshTimeNow = func() time.Time { return now }
+	defer func() { meshTimeNow = time.Now }()
+	md := newMeshDecorator(&ServiceMeshConfig{Enable: true, MatchWindow: time.Second}, meshTestStore(), imetrics.NoopReporter{})
+
+	forwarded := request.Span{Type: request.EventTypeHTTP, Pid: request.PidInfo{Namespace: 1012},
+		Peer: istioInboundSource, PeerPort: 40000, Host: "10.0.0.9", HostPort: 8080, RequestStart: 200, End: 300}
+	assert.Empty(t, md.process([]request.Span{forwarded}))
+	assert.Empty(t, md.release(now))
+
+	// if the span of the sidecar doesn't arrive, the span is forwarded with the sidecar as peer
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, []request.Span{forwarded}, md.release(now))
+	assert.Empty(t, md.held)
+
+	// the inbound requests of the sidecar are forgotten after the match window
+	_ = md.process([]request.Span{{Type: request.EventTypeHTTP, Pid: request.PidInfo{Namespace: 1013},
+		Peer: "10.0.0.5", PeerPort: 5555, Host: "10.0.0.9", HostPort: 15006, RequestStart: 100, End: 400}})
+	assert.Len(t, md.hops["uid-backend"], 1)
+	now = now.Add(2 * time.Second)
+	assert.Empty(t, md.release(now))
+	assert.Empty(t, md.hops)
+}
@@ -0,0 +1,135 @@
+package transform
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mariomac/pipes/pipe"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func sdlog() *slog.Logger {
+	return slog.With("component", "transform.SilenceDetector")
+}
+
+// SilenceDetectionConfig configures the detection of the instrumented processes that stop producing
+// spans while they are still alive. It helps distinguishing a service that doesn't receive
+// traffic from a service whose instrumentation broke, e.g. after a library upgrade.
+type SilenceDetectionConfig struct {
+	// Timeout is the time that an instrumented process that previously produced spans must
+	// stay without producing them to be reported as silent. Zero disables the detection.
+	Timeout time.Duration `yaml:"timeout" env:"BEYLA_SILENCE_DETECTION_TIMEOUT"`
+}
+
+// injectable functions for testing
+var (
+	silenceTimeNow = time.Now
+	processAlive   = func(pid uint32) bool {
+		_, err := os.Stat("/proc/" + strconv.FormatUint(uint64(pid), 10))
+		return err == nil
+	}
+)
+
+type silenceEntry struct {
+	service  svc.ID
+	pid      uint32
+	lastSpan time.Time
+	silent   bool
+}
+
+// silenceDetector tracks the time of the last span of each instrumented process.
+// It is not safe for concurrent access.
+type silenceDetector struct {
+	timeout   time.Duration
+	metrics   imetrics.Reporter
+	processes map[svc.UID]*silenceEntry
+}
+
+func SilenceDetectorProvider(cfg *SilenceDetectionConfig, metrics imetrics.Reporter) pipe.MiddleProvider[[]request.Span, []request.Span] {
+	return func() (pipe.MiddleFunc[[]request.Span, []request.Span], error) {
+		if cfg == nil || cfg.Timeout <= 0 {
+			return pipe.Bypass[[]request.Span](), nil
+		}
+		sd := newSilenceDetector(cfg, metrics)
+		return sd.nodeLoop, nil
+	}
+}
+
+func newSilenceDetector(cfg *SilenceDetectionConfig, metrics imetrics.Reporter) *silenceDetector {
+	if metrics == nil {
+		metrics = imetrics.NoopReporter{}
+	}
+	return &silenceDetector{
+		timeout:   cfg.Timeout,
+		metrics:   metrics,
+		processes: map[svc.UID]*silenceEntry{},
+	}
+}
+
+func (sd *silenceDetector) nodeLoop(in <-chan []request.Span, out chan<- []request.Span) {
+	// checking often enough to report the silences shortly after the timeout
+	ticker := time.NewTicker(max(sd.timeout/4, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case spans, ok := <-in:
+			if !ok {
+				return
+			}
+			sd.observe(spans)
+			out <- spans
+		case <-ticker.C:
+			sd.checkSilences()
+		}
+	}
+}
+
+func (sd *silenceDetector) observe(spans []request.Span) {
+	now := silenceTimeNow()
+	for i := range spans {
+		span := &spans[i]
+		if span.InternalSignal() || span.ServiceID.UID == "" {
+			continue
+		}
+		entry, ok := sd.processes[span.ServiceID.UID]
+		if !ok {
+			entry = &silenceEntry{service: span.ServiceID, pid: span.Pid.HostPID}
+			sd.processes[span.ServiceID.UID] = entry
+		}
+		entry.lastSpan = now
+		if entry.silent {
+			entry.silent = false
+			sdlog().Info("instrumented process produces spans again",
+				"service", entry.service.Name, "namespace", entry.service.Namespace, "pid", entry.pid)
+			sd.metrics.ServiceResumed(&entry.service)
+		}
+	}
+}
+
+// checkSilences reports the processes that have been silent for longer than the timeout,
+// and forgets the processes that ended
+func (sd *silenceDetector) checkSilences() {
+	now := silenceTimeNow()
+	for uid, entry := range sd.processes {
+		if !processAlive(entry.pid) {
+			if entry.silent {
+				sd.metrics.ServiceResumed(&entry.service)
+			}
+			delete(sd.processes, uid)
+			continue
+		}
+		if !entry.silent && now.Sub(entry.lastSpan) >= sd.timeout {
+			entry.silent = true
+			sdlog().Warn("instrumented process stopped producing spans while it is still alive."+
+				" It might not be receiving traffic, or its instrumentation might be broken",
+				"service", entry.service.Name, "namespace", entry.service.Namespace, "pid", entry.pid,
+				"lastSpan", entry.lastSpan)
+			sd.metrics.ServiceSilenced(&entry.service)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package transform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+type silenceReporter struct {
+	imetrics.NoopReporter
+	silent map[string]int
+}
+
+func (sr *silenceReporter) ServiceSilenced(service *svc.ID) { sr.silent[service.Name]++ }
+func (sr *silenceReporter) ServiceResumed(service *svc.ID)  { sr.silent[service.Name]-- }
+
+func TestSilenceDetector(t *testing.T) {
+	now := time.Now()
+	silenceTimeNow = func() time.Time { return now }
+	alive := map[uint32]bool{1: true, 2: true}
+	processAlive = func(pid uint32) bool { return alive[pid] }
+
+	reporter := &silenceReporter{silent: map[string]int{}}
+	sd := newSilenceDetector(&SilenceDetectionConfig{Timeout: 5 * time.Minute}, reporter)
+
+	foo := request.Span{Type: request.EventTypeHTTP, ServiceID: svc.ID{Name: "foo", UID: "foo-1"}, Pid: request.PidInfo{HostPID: 1}}
+	bar := request.Span{Type: request.EventTypeHTTP, ServiceID: svc.ID{Name: "bar", UID: "bar-1"}, Pid: request.PidInfo{HostPID: 2}}
+	sd.observe([]request.Span{foo, bar})
+
+	// services that produced spans during the timeout are not silent
+	now = now.Add(3 * time.Minute)
+	sd.observe([]request.Span{foo})
+	sd.checkSilences()
+	assert.Empty(t, reporter.silent)
+
+	// services that don't produce spans during the timeout are silent, and only reported once
+	now = now.Add(3 * time.Minute)
+	sd.checkSilences()
+	sd.checkSilences()
+	assert.Equal(t, map[string]int{"bar": 1}, reporter.silent)
+
+	// silent services are not silent anymore when they produce spans again
+	sd.observe([]request.Span{bar})
+	assert.Equal(t, map[string]int{"bar": 0}, reporter.silent)
+
+	// services whose process ended are forgotten, and not reported as silent anymore
+	now = now.Add(10 * time.Minute)
+	sd.checkSilences()
+	assert.Equal(t, map[string]int{"bar": 1, "foo": 1}, reporter.silent)
+	delete(alive, 1)
+	sd.checkSilences()
+	assert.Equal(t, map[string]int{"bar": 1, "foo": 0}, reporter.silent)
+	assert.Len(t, sd.processes, 1)
+}
@@ -0,0 +1,174 @@
+package transform
+
+import (
+	"container/list"
+	"log/slog"
+	"time"
+
+	"github.com/mariomac/pipes/pipe"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func ddlog() *slog.Logger {
+	return slog.With("component", "transform.SpanDeduper")
+}
+
+// SpanDedupConfig configures the removal of duplicate spans. The same request might be captured
+// more than once, for example when a host-network Pod is captured by multiple capture points
+// (traffic control, socket filter and uprobes), or by multiple Beyla instances.
+type SpanDedupConfig struct {
+	Enable bool `yaml:"enable" env:"BEYLA_SPAN_DEDUP_ENABLE"`
+	// Tolerance is the maximum difference in the start and end times of two spans with the
+	// same connection tuple and direction to be considered the same request
+	Tolerance time.Duration `yaml:"tolerance" env:"BEYLA_SPAN_DEDUP_TOLERANCE"`
+	// ExpireTime is the time that a span is remembered to detect its duplicates
+	ExpireTime time.Duration `yaml:"expire_time" env:"BEYLA_SPAN_DEDUP_EXPIRE_TIME"`
+}
+
+// injectable function for testing
+var dedupTimeNow = time.Now
+
+// dedupTuple identifies a request by its connection tuple and direction
+type dedupTuple struct {
+	client   bool
+	peer     string
+	peerPort int
+	host     string
+	hostPort int
+	// start and end are the request times, in units of the dedup tolerance
+	start int64
+	end   int64
+}
+
+type dedupEntry struct {
+	key        dedupTuple
+	start      time.Time
+	end        time.Time
+	expiryTime time.Time
+}
+
+// spanDeduper implements a cache whose elements are evicted after the expire time.
+// It is not safe for concurrent access.
+type spanDeduper struct {
+	tolerance time.Duration
+	expire    time.Duration
+	spans     map[dedupTuple]*list.Element
+	// element: dedupEntry structs of the spans map ordered by expiry time
+	entries *list.List
+	metrics imetrics.Reporter
+}
+
+func SpanDeduperProvider(cfg *SpanDedupConfig, metrics imetrics.Reporter) pipe.MiddleProvider[[]request.Span, []request.Span] {
+	return func() (pipe.MiddleFunc[[]request.Span, []request.Span], error) {
+		if cfg == nil || !cfg.Enable {
+			return pipe.Bypass[[]request.Span](), nil
+		}
+		dd := newSpanDeduper(cfg)
+		dd.metrics = metrics
+		return dd.nodeLoop, nil
+	}
+}
+
+func newSpanDeduper(cfg *SpanDedupConfig) *spanDeduper {
+	tolerance := cfg.Tolerance
+	if tolerance <= 0 {
+		tolerance = time.Nanosecond
+	}
+	return &spanDeduper{
+		tolerance: tolerance,
+		expire:    cfg.ExpireTime,
+		spans:     map[dedupTuple]*list.Element{},
+		entries:   list.New(),
+	}
+}
+
+func (dd *spanDeduper) nodeLoop(in <-chan []request.Span, out chan<- []request.Span) {
+	for spans := range in {
+		dd.removeExpired()
+		fwd := make([]request.Span, 0, len(spans))
+		var dupe *request.Span
+		for i := range spans {
+			if !spans[i].InternalSignal() && dd.isDupe(&spans[i]) {
+				if dupe == nil {
+					dupe = &spans[i]
+				}
+				continue
+			}
+			fwd = append(fwd, spans[i])
+		}
+		if dupe != nil {
+			dd.metrics.SpansDropped(imetrics.DropReasonDuplicate, len(spans)-len(fwd), dupe.Summary())
+		}
+		if len(fwd) > 0 {
+			out <- fwd
+		}
+	}
+}
+
+// isDupe returns whether an equivalent span has been already forwarded. Spans are considered
+// equivalent if they share the connection tuple and direction, and their start and end
+// times differ less than the configured tolerance.
+func (dd *spanDeduper) isDupe(span *request.Span) bool {
+	t := span.Timings()
+	key := dedupTuple{
+		client:   span.IsClientSpan(),
+		peer:     span.Peer,
+		peerPort: span.PeerPort,
+		host:     span.Host,
+		hostPort: span.HostPort,
+		start:    t.RequestStart.UnixNano() / int64(dd.tolerance),
+		end:      t.End.UnixNano() / int64(dd.tolerance),
+	}
+	// two equivalent spans might fall into contiguous time slots,
+	// so we need to look for them in the neighbour slots
+	for ds := int64(-1); ds <= 1; ds++ {
+		for de := int64(-1); de <= 1; de++ {
+			nk := key
+			nk.start += ds
+			nk.end += de
+			if ele, ok := dd.spans[nk]; ok {
+				e := ele.Value.(*dedupEntry)
+				if absDuration(e.start.Sub(t.RequestStart)) <= dd.tolerance &&
+					absDuration(e.end.Sub(t.End)) <= dd.tolerance {
+					return true
+				}
+			}
+		}
+	}
+	if ele, ok := dd.spans[key]; ok {
+		dd.entries.Remove(ele)
+	}
+	dd.spans[key] = dd.entries.PushFront(&dedupEntry{
+		key:        key,
+		start:      t.RequestStart,
+		end:        t.End,
+		expiryTime: dedupTimeNow().Add(dd.expire),
+	})
+	return false
+}
+
+func (dd *spanDeduper) removeExpired() {
+	now := dedupTimeNow()
+	ele := dd.entries.Back()
+	evicted := 0
+	for ele != nil && now.After(ele.Value.(*dedupEntry).expiryTime) {
+		evicted++
+		dd.entries.Remove(ele)
+		delete(dd.spans, ele.Value.(*dedupEntry).key)
+		ele = dd.entries.Back()
+	}
+	if evicted > 0 {
+		ddlog().Debug("entries evicted from the span deduper cache",
+			"current", dd.entries.Len(),
+			"evicted", evicted)
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
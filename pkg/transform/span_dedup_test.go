@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/testutil"
+)
+
+func TestSpanDeduper(t *testing.T) {
+	dedup, err := SpanDeduperProvider(&SpanDedupConfig{
+		Enable:     true,
+		Tolerance:  time.Millisecond,
+		ExpireTime: time.Minute,
+	}, imetrics.NoopReporter{})()
+	require.NoError(t, err)
+	in, out := make(chan []request.Span, 10), make(chan []request.Span, 10)
+	defer close(in)
+	go dedup(in, out)
+
+	ms := int64(time.Millisecond)
+	srv := request.Span{Type: request.EventTypeHTTP, Peer: "1.1.1.1", PeerPort: 3333, Host: "2.2.2.2", HostPort: 80,
+		RequestStart: 100 * ms, End: 200 * ms, Path: "/first"}
+	in <- []request.Span{srv}
+	assert.Equal(t, []request.Span{srv}, testutil.ReadChannel(t, out, testTimeout))
+
+	// same request captured from another capture point, with slightly different timings
+	dupe := srv
+	dupe.RequestStart += ms / 2
+	dupe.End -= ms / 2
+	dupe.Path = "/dupe"
+	// same tuple, but client side
+	cli := srv
+	cli.Type = request.EventTypeHTTPClient
+	cli.Path = "/client"
+	// same tuple and direction, but a different request in time
+	next := srv
+	next.RequestStart, next.End = 300*ms, 400*ms
+	next.Path = "/next"
+	in <- []request.Span{dupe, cli, next}
+	assert.Equal(t, []request.Span{cli, next}, testutil.ReadChannel(t, out, testTimeout))
+
+	// batches only containing duplicates are not forwarded
+	in <- []request.Span{dupe}
+	in <- []request.Span{{Type: request.EventTypeProcessAlive}}
+	assert.Equal(t, []request.Span{{Type: request.EventTypeProcessAlive}}, testutil.ReadChannel(t, out, testTimeout))
+}
+
+func TestSpanDeduper_Expiration(t *testing.T) {
+	now := time.Now()
+	dedupTimeNow = func() time.Time { return now }
+	defer func() { dedupTimeNow = time.Now }()
+
+	dd := newSpanDeduper(&SpanDedupConfig{Tolerance: time.Millisecond, ExpireTime: time.Minute})
+	span := request.Span{Type: request.EventTypeHTTP, Peer: "1.1.1.1", PeerPort: 3333, Host: "2.2.2.2", HostPort: 80,
+		RequestStart: int64(time.Second), End: 2 * int64(time.Second)}
+	assert.False(t, dd.isDupe(&span))
+	assert.True(t, dd.isDupe(&span))
+
+	now = now.Add(2 * time.Minute)
+	dd.removeExpired()
+	assert.Zero(t, dd.entries.Len())
+	assert.False(t, dd.isDupe(&span))
+}
@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"github.com/mariomac/pipes/pipe"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// spanEnricher modifies in place the spans of a batch. It is not safe for concurrent access.
+type spanEnricher func(spans []request.Span)
+
+// SpanEnricherConfig groups the configuration of the transformations that are applied by the
+// span enricher node
+type SpanEnricherConfig struct {
+	SpanStatus  *SpanStatusConfig
+	GRPCGateway *GRPCGatewayConfig
+	SpanNames   *SpanNamesConfig
+	Tenant      *TenantConfig
+}
+
+// SpanEnricherProvider applies, in a single pipeline node, the transformations that modify the
+// spans of each batch without holding or dropping them: the status overrides, the naming of
+// the grpc-gateway spans, the span name overrides and the tenant reporting. If none of them is
+// enabled, the spans are bypassed to the next stage of the pipeline.
+func SpanEnricherProvider(cfg *SpanEnricherConfig) pipe.MiddleProvider[[]request.Span, []request.Span] {
+	return func() (pipe.MiddleFunc[[]request.Span, []request.Span], error) {
+		enrichers, err := newSpanEnrichers(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if len(enrichers) == 0 {
+			return pipe.Bypass[[]request.Span](), nil
+		}
+		return func(in <-chan []request.Span, out chan<- []request.Span) {
+			for spans := range in {
+				for _, enrich := range enrichers {
+					enrich(spans)
+				}
+				out <- spans
+			}
+		}, nil
+	}
+}
+
+// newSpanEnrichers returns the enabled transformations. The grpc-gateway naming runs before the
+// span name overrides, so the names that are explicitly configured take precedence.
+func newSpanEnrichers(cfg *SpanEnricherConfig) ([]spanEnricher, error) {
+	names, err := spanNameOverrider(cfg.SpanNames)
+	if err != nil {
+		return nil, err
+	}
+	var enrichers []spanEnricher
+	for _, enrich := range []spanEnricher{
+		spanStatusOverrider(cfg.SpanStatus),
+		gatewayNaming(cfg.GRPCGateway),
+		names,
+		tenantReporter(cfg.Tenant),
+	} {
+		if enrich != nil {
+			enrichers = append(enrichers, enrich)
+		}
+	}
+	return enrichers, nil
+}
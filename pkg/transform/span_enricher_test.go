@@ -0,0 +1,55 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestSpanEnricher_Disabled(t *testing.T) {
+	enrichers, err := newSpanEnrichers(&SpanEnricherConfig{
+		SpanStatus:  &SpanStatusConfig{},
+		GRPCGateway: &GRPCGatewayConfig{},
+		SpanNames:   &SpanNamesConfig{},
+		Tenant:      &TenantConfig{},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, enrichers)
+}
+
+func TestSpanEnricher_ConfiguredNamesOverrideGateway(t *testing.T) {
+	node, err := SpanEnricherProvider(&SpanEnricherConfig{
+		GRPCGateway: &GRPCGatewayConfig{Enable: true},
+		SpanNames:   &SpanNamesConfig{Rules: []SpanNameRule{{Name: "get user", Route: "/v1/users/.*"}}},
+	})()
+	require.NoError(t, err)
+
+	gateway := svc.ID{Name: "users", SDKLanguage: svc.InstrumentableGolang}
+	traceID := trace2.TraceID{1, 2, 3}
+	serverID := trace2.SpanID{4, 5, 6}
+	in := make(chan []request.Span, 1)
+	out := make(chan []request.Span, 1)
+	in <- []request.Span{
+		{Type: request.EventTypeGRPCClient, ServiceID: gateway, Host: "127.0.0.1",
+			Path: "/users.v1.UserService/GetUser", TraceID: traceID, ParentSpanID: serverID, SpanID: trace2.SpanID{7}},
+		{Type: request.EventTypeHTTP, ServiceID: gateway, Method: "GET",
+			Route: "/v1/users/{id}", TraceID: traceID, SpanID: serverID},
+	}
+	close(in)
+	node(in, out)
+	spans := <-out
+	require.Len(t, spans, 2)
+	assert.Equal(t, "get user", spans[1].TraceName())
+}
+
+func TestSpanEnricher_InvalidConfig(t *testing.T) {
+	_, err := SpanEnricherProvider(&SpanEnricherConfig{
+		SpanNames: &SpanNamesConfig{Rules: []SpanNameRule{{Name: "search", Route: "/search("}}},
+	})()
+	require.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+// SpanNamesConfig overrides the names of the HTTP spans, to name them after the logical
+// operations of the application (e.g. checkout, search) instead of their method and route.
+type SpanNamesConfig struct {
+	// Rules are evaluated in order. The first rule that matches a span sets its name.
+	Rules []SpanNameRule `yaml:"rules"`
+}
+
+// SpanNameRule selects the HTTP spans whose name is overridden
+type SpanNameRule struct {
+	// Name that is given to the matching spans
+	Name string `yaml:"name"`
+	// Service is the name of the service whose spans are renamed. Empty matches any service.
+	Service string `yaml:"service"`
+	// Namespace of the service whose spans are renamed. Empty matches any namespace.
+	Namespace string `yaml:"namespace"`
+	// Methods of the requests, case-insensitive. Empty matches any method.
+	Methods []string `yaml:"methods"`
+	// Route is a regular expression that must match the whole route of the span, or its path
+	// if the route is unknown. Empty matches any route.
+	Route string `yaml:"route"`
+	// Headers are the request headers that must be present, with a regular expression that must
+	// match their whole value. The request headers are only available when they are tracked.
+	Headers map[string]string `yaml:"headers"`
+}
+
+type spanNameRule struct {
+	*SpanNameRule
+	route   *regexp.Regexp
+	headers map[string]*regexp.Regexp
+}
+
+// spanNameOverrider returns the span enricher that overrides the names of the HTTP spans,
+// or nil if there are no rules
+func spanNameOverrider(cfg *SpanNamesConfig) (spanEnricher, error) {
+	if cfg == nil || len(cfg.Rules) == 0 {
+		return nil, nil
+	}
+	rules, err := newSpanNameRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func(spans []request.Span) {
+		for i := range spans {
+			overrideName(rules, &spans[i])
+		}
+	}, nil
+}
+
+func newSpanNameRules(cfg *SpanNamesConfig) ([]spanNameRule, error) {
+	rules := make([]spanNameRule, 0, len(cfg.Rules))
+	for i := range cfg.Rules {
+		rule := spanNameRule{SpanNameRule: &cfg.Rules[i]}
+		if rule.Name == "" {
+			return nil, fmt.Errorf("span_names rule %d: missing name", i)
+		}
+		if rule.Route != "" {
+			re, err := regexp.Compile("^(?:" + rule.Route + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("span_names rule %q: invalid route: %w", rule.Name, err)
+			}
+			rule.route = re
+		}
+		if len(rule.Headers) > 0 {
+			rule.headers = make(map[string]*regexp.Regexp, len(rule.Headers))
+			for name, value := range rule.Headers {
+				re, err := regexp.Compile("^(?:" + value + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("span_names rule %q: invalid value of header %s: %w", rule.Name, name, err)
+				}
+				// the captured request headers are keyed by their lowercase name
+				rule.headers[strings.ToLower(name)] = re
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func overrideName(rules []spanNameRule, span *request.Span) {
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	for i := range rules {
+		if rules[i].matches(span) {
+			span.NameOverride = rules[i].Name
+			return
+		}
+	}
+}
+
+func (r *spanNameRule) matches(span *request.Span) bool {
+	if r.Service != "" && r.Service != span.ServiceID.Name {
+		return false
+	}
+	if r.Namespace != "" && r.Namespace != span.ServiceID.Namespace {
+		return false
+	}
+	if len(r.Methods) > 0 && !r.matchesMethod(span.Method) {
+		return false
+	}
+	if r.route != nil {
+		route := span.Route
+		if route == "" {
+			route = span.Path
+		}
+		if !r.route.MatchString(route) {
+			return false
+		}
+	}
+	for name, value := range r.headers {
+		if !matchesAny(value, span.RequestHeaders[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *spanNameRule) matchesMethod(method string) bool {
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(re *regexp.Regexp, values []string) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
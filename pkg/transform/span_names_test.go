@@ -0,0 +1,62 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestSpanNameOverride(t *testing.T) {
+	rules, err := newSpanNameRules(&SpanNamesConfig{Rules: []SpanNameRule{
+		{Name: "checkout", Service: "shop", Methods: []string{"post"}, Route: "/cart/[^/]+/checkout"},
+		{Name: "search", Route: "/search|/products"},
+		{Name: "graphql mutation", Headers: map[string]string{"X-Operation-Type": "mutation"}},
+	}})
+	require.NoError(t, err)
+	shop := svc.ID{Name: "shop"}
+
+	for _, tc := range []struct {
+		name     string
+		span     request.Span
+		expected string
+	}{
+		{name: "method and route", expected: "checkout",
+			span: request.Span{Type: request.EventTypeHTTP, ServiceID: shop, Method: "POST", Path: "/cart/12/checkout"}},
+		{name: "other method", expected: "GET /cart/12/checkout",
+			span: request.Span{Type: request.EventTypeHTTP, ServiceID: shop, Method: "GET", Route: "/cart/12/checkout"}},
+		{name: "other service", expected: "POST /cart/12/checkout",
+			span: request.Span{Type: request.EventTypeHTTP, Method: "POST", Route: "/cart/12/checkout"}},
+		{name: "route is fully matched", expected: "GET /search/users",
+			span: request.Span{Type: request.EventTypeHTTP, Method: "GET", Route: "/search/users"}},
+		{name: "route set by the routes decorator", expected: "search",
+			span: request.Span{Type: request.EventTypeHTTP, Method: "GET", Route: "/products", Path: "/products/3"}},
+		{name: "client span", expected: "search",
+			span: request.Span{Type: request.EventTypeHTTPClient, Method: "GET", Path: "/search"}},
+		{name: "header", expected: "graphql mutation",
+			span: request.Span{Type: request.EventTypeHTTP, Method: "POST", Route: "/graphql",
+				RequestHeaders: map[string][]string{"x-operation-type": {"query", "mutation"}}}},
+		{name: "other header value", expected: "POST /graphql",
+			span: request.Span{Type: request.EventTypeHTTP, Method: "POST", Route: "/graphql",
+				RequestHeaders: map[string][]string{"x-operation-type": {"query"}}}},
+		{name: "not HTTP", expected: "/search",
+			span: request.Span{Type: request.EventTypeGRPC, Path: "/search"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			overrideName(rules, &tc.span)
+			assert.Equal(t, tc.expected, tc.span.TraceName())
+		})
+	}
+}
+
+func TestSpanNameRules_Invalid(t *testing.T) {
+	_, err := newSpanNameRules(&SpanNamesConfig{Rules: []SpanNameRule{{Route: "/search"}}})
+	require.Error(t, err)
+	_, err = newSpanNameRules(&SpanNamesConfig{Rules: []SpanNameRule{{Name: "search", Route: "/search("}}})
+	require.Error(t, err)
+	_, err = newSpanNameRules(&SpanNamesConfig{Rules: []SpanNameRule{{Name: "search", Headers: map[string]string{"x-op": "[a"}}}})
+	require.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package transform
+
+import (
+	"slices"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/transform/route"
+)
+
+// SpanStatusConfig overrides which HTTP response status codes are reported as errors, for
+// example to accept the 404 responses of a link checker, or to report as errors the 499
+// responses of a proxy whose clients closed the connection. The overrides are applied
+// to the status of the spans and to the error-rate metrics.
+type SpanStatusConfig struct {
+	// Rules are evaluated in order. The first rule that matches a span and lists its status
+	// code decides whether the span is an error.
+	Rules []SpanStatusRule `yaml:"rules"`
+}
+
+// SpanStatusRule selects the HTTP spans whose status codes are overridden
+type SpanStatusRule struct {
+	// Service is the name of the service whose spans are overridden. Empty matches any service.
+	Service string `yaml:"service"`
+	// Namespace of the service whose spans are overridden. Empty matches any namespace.
+	Namespace string `yaml:"namespace"`
+	// Routes of the spans that are overridden, with the same format as the routes patterns
+	// (e.g. /users/:id or /users/{id}). Empty matches any route.
+	Routes []string `yaml:"routes"`
+	// OK are the status codes that are not reported as errors
+	OK []int `yaml:"ok"`
+	// Error are the status codes that are reported as errors
+	Error []int `yaml:"error"`
+}
+
+type spanStatusRule struct {
+	*SpanStatusRule
+	routes *route.Matcher
+}
+
+// spanStatusOverrider returns the span enricher that overrides the status of the HTTP spans,
+// or nil if there are no rules
+func spanStatusOverrider(cfg *SpanStatusConfig) spanEnricher {
+	if cfg == nil || len(cfg.Rules) == 0 {
+		return nil
+	}
+	rules := newSpanStatusRules(cfg)
+	return func(spans []request.Span) {
+		for i := range spans {
+			overrideStatus(rules, &spans[i])
+		}
+	}
+}
+
+func newSpanStatusRules(cfg *SpanStatusConfig) []spanStatusRule {
+	rules := make([]spanStatusRule, 0, len(cfg.Rules))
+	for i := range cfg.Rules {
+		rule := spanStatusRule{SpanStatusRule: &cfg.Rules[i]}
+		if len(rule.Routes) > 0 {
+			matcher := route.NewMatcher(rule.Routes)
+			rule.routes = &matcher
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func overrideStatus(rules []spanStatusRule, span *request.Span) {
+	if span.Type != request.EventTypeHTTP && span.Type != request.EventTypeHTTPClient {
+		return
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.matches(span) {
+			continue
+		}
+		if slices.Contains(rule.Error, span.Status) {
+			span.StatusOverride = codes.Error
+			return
+		}
+		if slices.Contains(rule.OK, span.Status) {
+			span.StatusOverride = codes.Ok
+			return
+		}
+	}
+}
+
+func (r *spanStatusRule) matches(span *request.Span) bool {
+	if r.Service != "" && r.Service != span.ServiceID.Name {
+		return false
+	}
+	if r.Namespace != "" && r.Namespace != span.ServiceID.Namespace {
+		return false
+	}
+	if r.routes == nil {
+		return true
+	}
+	// the route might have been already set to the same pattern by the routes decorator
+	return slices.Contains(r.Routes, span.Route) || r.routes.Find(span.Path) != ""
+}
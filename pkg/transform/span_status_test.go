@@ -0,0 +1,65 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestSpanStatusOverride(t *testing.T) {
+	rules := newSpanStatusRules(&SpanStatusConfig{Rules: []SpanStatusRule{
+		{Service: "link-checker", OK: []int{404}},
+		{Namespace: "edge", Routes: []string{"/users/{id}"}, Error: []int{499}, OK: []int{500}},
+		{Error: []int{418}},
+	}})
+	checker := svc.ID{Name: "link-checker", Namespace: "tools"}
+	proxy := svc.ID{Name: "proxy", Namespace: "edge"}
+
+	for _, tc := range []struct {
+		name     string
+		span     request.Span
+		expected codes.Code
+	}{
+		{name: "accepted not found", expected: codes.Unset,
+			span: request.Span{Type: request.EventTypeHTTPClient, ServiceID: checker, Path: "/a", Status: 404}},
+		{name: "other status of the service", expected: codes.Error,
+			span: request.Span{Type: request.EventTypeHTTPClient, ServiceID: checker, Path: "/a", Status: 403}},
+		{name: "client closed request", expected: codes.Error,
+			span: request.Span{Type: request.EventTypeHTTP, ServiceID: proxy, Path: "/users/3", Status: 499}},
+		{name: "accepted server error", expected: codes.Unset,
+			span: request.Span{Type: request.EventTypeHTTP, ServiceID: proxy, Path: "/users/3", Status: 500}},
+		{name: "route already set", expected: codes.Error,
+			span: request.Span{Type: request.EventTypeHTTP, ServiceID: proxy, Route: "/users/{id}", Status: 499}},
+		{name: "other route", expected: codes.Error,
+			span: request.Span{Type: request.EventTypeHTTP, ServiceID: proxy, Path: "/orders/3", Status: 500}},
+		{name: "any service", expected: codes.Error,
+			span: request.Span{Type: request.EventTypeHTTP, ServiceID: checker, Path: "/tea", Status: 418}},
+		{name: "not HTTP", expected: codes.Unset,
+			span: request.Span{Type: request.EventTypeGRPC, ServiceID: checker, Path: "/a", Status: 418}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			overrideStatus(rules, &tc.span)
+			assert.Equal(t, tc.expected, request.SpanStatusCode(&tc.span))
+		})
+	}
+}
+
+func TestSpanStatusNode(t *testing.T) {
+	node, err := SpanEnricherProvider(&SpanEnricherConfig{
+		SpanStatus: &SpanStatusConfig{Rules: []SpanStatusRule{{OK: []int{404}}}},
+	})()
+	require.NoError(t, err)
+	in := make(chan []request.Span, 1)
+	out := make(chan []request.Span, 1)
+	in <- []request.Span{{Type: request.EventTypeHTTPClient, Status: 404}}
+	close(in)
+	node(in, out)
+	spans := <-out
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Ok, spans[0].StatusOverride)
+}
@@ -0,0 +1,82 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func tnlog() *slog.Logger {
+	return slog.With("component", "transform.Tenant")
+}
+
+// TenantOther is the tenant that is reported for the requests of the tenants that exceed the
+// maximum number of distinct tenants
+const TenantOther = "other"
+
+// TenantConfig configures the extraction of the tenant of the HTTP requests, which is reported
+// in the tenant.id attribute of the metrics, to enable per-tenant SLOs.
+type TenantConfig struct {
+	// Header is the HTTP request header that contains the tenant. If JWTClaim is set, the header
+	// contains a JWT, and it defaults to the Authorization header.
+	Header string `yaml:"header" env:"BEYLA_TENANT_HEADER"`
+	// JWTClaim is the claim of the JWT that contains the tenant. The header must fit in the first
+	// 192 bytes of the request that the eBPF probes capture, which most JWTs exceed.
+	JWTClaim string `yaml:"jwt_claim" env:"BEYLA_TENANT_JWT_CLAIM"`
+	// Hash reports a hash of the tenant instead of its value, e.g. when it is an email address
+	Hash bool `yaml:"hash" env:"BEYLA_TENANT_HASH"`
+	// MaxTenants bounds the cardinality of the tenant attribute. The requests of the tenants that
+	// are seen after this number of distinct tenants are reported with the "other" tenant.
+	MaxTenants int `yaml:"max_tenants" env:"BEYLA_TENANT_MAX_TENANTS"`
+}
+
+func (c *TenantConfig) Enabled() bool {
+	return c != nil && (c.Header != "" || c.JWTClaim != "")
+}
+
+// tenantLimiter is not safe for concurrent access
+type tenantLimiter struct {
+	cfg     *TenantConfig
+	tenants map[string]string
+	warned  bool
+}
+
+// tenantReporter returns the span enricher that replaces the tenant of the spans by its reported
+// value, or nil if the tenant extraction is not enabled
+func tenantReporter(cfg *TenantConfig) spanEnricher {
+	if !cfg.Enabled() {
+		return nil
+	}
+	tl := &tenantLimiter{cfg: cfg, tenants: map[string]string{}}
+	return func(spans []request.Span) {
+		for i := range spans {
+			if spans[i].Tenant != "" {
+				spans[i].Tenant = tl.tenant(spans[i].Tenant)
+			}
+		}
+	}
+}
+
+// tenant returns the reported tenant for the tenant of a request
+func (tl *tenantLimiter) tenant(raw string) string {
+	if reported, ok := tl.tenants[raw]; ok {
+		return reported
+	}
+	if tl.cfg.MaxTenants > 0 && len(tl.tenants) >= tl.cfg.MaxTenants {
+		if !tl.warned {
+			tnlog().Warn("maximum number of tenants reached. Further tenants are reported as "+TenantOther,
+				"maxTenants", tl.cfg.MaxTenants)
+			tl.warned = true
+		}
+		return TenantOther
+	}
+	reported := raw
+	if tl.cfg.Hash {
+		sum := sha256.Sum256([]byte(raw))
+		reported = hex.EncodeToString(sum[:8])
+	}
+	tl.tenants[raw] = reported
+	return reported
+}
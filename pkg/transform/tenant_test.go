@@ -0,0 +1,43 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func TestTenantLimiter(t *testing.T) {
+	tl := &tenantLimiter{cfg: &TenantConfig{Header: "x-tenant", MaxTenants: 2}, tenants: map[string]string{}}
+	assert.Equal(t, "acme", tl.tenant("acme"))
+	assert.Equal(t, "globex", tl.tenant("globex"))
+	assert.Equal(t, TenantOther, tl.tenant("initech"))
+	// already seen tenants are still reported
+	assert.Equal(t, "acme", tl.tenant("acme"))
+
+	tl = &tenantLimiter{cfg: &TenantConfig{Header: "x-tenant", Hash: true}, tenants: map[string]string{}}
+	hashed := tl.tenant("someone@example.com")
+	assert.Len(t, hashed, 16)
+	assert.NotEqual(t, "someone@example.com", hashed)
+	assert.Equal(t, hashed, tl.tenant("someone@example.com"))
+	assert.NotEqual(t, hashed, tl.tenant("other@example.com"))
+}
+
+func TestTenantNode(t *testing.T) {
+	node, err := SpanEnricherProvider(&SpanEnricherConfig{
+		Tenant: &TenantConfig{JWTClaim: "org", MaxTenants: 1},
+	})()
+	require.NoError(t, err)
+	in := make(chan []request.Span, 1)
+	out := make(chan []request.Span, 1)
+	in <- []request.Span{{Tenant: "acme"}, {}, {Tenant: "globex"}}
+	close(in)
+	node(in, out)
+	spans := <-out
+	require.Len(t, spans, 3)
+	assert.Equal(t, "acme", spans[0].Tenant)
+	assert.Empty(t, spans[1].Tenant)
+	assert.Equal(t, TenantOther, spans[2].Tenant)
+}